@@ -17,6 +17,7 @@
 package abi
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -34,6 +35,7 @@ type ABI struct {
 	Constructor Method
 	Methods     map[string]Method
 	Events      map[string]Event
+	Errors      map[string]Error
 }
 
 // JSON returns a parsed ABI interface and error if it failed.
@@ -79,8 +81,7 @@ func (abi ABI) Pack(name string, args ...interface{}) ([]byte, error) {
 
 // toGoSliceType prses the input and casts it to the proper slice defined by the ABI
 // argument in T.
-func toGoSlice(i int, t Argument, output []byte) (interface{}, error) {
-	index := i * 32
+func toGoSlice(index int, t Argument, output []byte) (interface{}, error) {
 	// The slice must, at very least be large enough for the index+32 which is exactly the size required
 	// for the [offset in output, size of offset].
 	if index+32 > len(output) {
@@ -147,13 +148,20 @@ func toGoSlice(i int, t Argument, output []byte) (interface{}, error) {
 
 // toGoType parses the input and casts it to the proper type defined by the ABI
 // argument in T.
-func toGoType(i int, t Argument, output []byte) (interface{}, error) {
+func toGoType(index int, t Argument, output []byte) (interface{}, error) {
+	// tuples and slices of tuples or dynamic types are decoded recursively
+	if t.Type.T == TupleTy || (t.Type.T != BytesTy && t.Type.T != FixedBytesTy && t.Type.Elem != nil && (t.Type.Elem.T == TupleTy || t.Type.Elem.isDynamic())) {
+		value, err := readValue(t.Type, output, index)
+		if err != nil {
+			return nil, err
+		}
+		return value.Interface(), nil
+	}
 	// we need to treat slices differently
 	if (t.Type.IsSlice || t.Type.IsArray) && t.Type.T != BytesTy && t.Type.T != StringTy && t.Type.T != FixedBytesTy {
-		return toGoSlice(i, t, output)
+		return toGoSlice(index, t, output)
 	}
 
-	index := i * 32
 	if index+32 > len(output) {
 		return nil, fmt.Errorf("abi: cannot marshal in to go type: length insufficient %d require %d", len(output), index+32)
 	}
@@ -183,30 +191,9 @@ func toGoType(i int, t Argument, output []byte) (interface{}, error) {
 	// convert the bytes to whatever is specified by the ABI.
 	switch t.Type.T {
 	case IntTy, UintTy:
-		bigNum := new(big.Int).SetBytes(returnOutput)
-
 		// If the type is a integer convert to the integer type
 		// specified by the ABI.
-		switch t.Type.Kind {
-		case reflect.Uint8:
-			return uint8(bigNum.Uint64()), nil
-		case reflect.Uint16:
-			return uint16(bigNum.Uint64()), nil
-		case reflect.Uint32:
-			return uint32(bigNum.Uint64()), nil
-		case reflect.Uint64:
-			return uint64(bigNum.Uint64()), nil
-		case reflect.Int8:
-			return int8(bigNum.Int64()), nil
-		case reflect.Int16:
-			return int16(bigNum.Int64()), nil
-		case reflect.Int32:
-			return int32(bigNum.Int64()), nil
-		case reflect.Int64:
-			return int64(bigNum.Int64()), nil
-		case reflect.Ptr:
-			return bigNum, nil
-		}
+		return readInteger(t.Type.Kind, returnOutput), nil
 	case BoolTy:
 		return new(big.Int).SetBytes(returnOutput).Uint64() > 0, nil
 	case AddressTy:
@@ -228,9 +215,21 @@ var (
 	r_byte       = reflect.TypeOf(byte(0))
 )
 
-// Unpack output in v according to the abi specification
+// Unpack output in v according to the abi specification. The name refers to
+// either a method, whose return values are unpacked, or an event, whose
+// non-indexed inputs are unpacked from the log data.
 func (abi ABI) Unpack(v interface{}, name string, output []byte) error {
-	var method = abi.Methods[name]
+	var outputs []Argument
+	if method, ok := abi.Methods[name]; ok {
+		outputs = method.Outputs
+	} else if event, ok := abi.Events[name]; ok {
+		// Events without data fields have nothing to unpack
+		if outputs = event.NonIndexed(); len(outputs) == 0 {
+			return nil
+		}
+	} else {
+		return fmt.Errorf("abi: could not locate named method or event '%s'", name)
+	}
 
 	if len(output) == 0 {
 		return fmt.Errorf("abi: unmarshalling empty output")
@@ -246,24 +245,24 @@ func (abi ABI) Unpack(v interface{}, name string, output []byte) error {
 		value = valueOf.Elem()
 		typ   = value.Type()
 	)
-
-	if len(method.Outputs) > 1 {
+	values, err := unpackOutputs(outputs, output)
+	if err != nil {
+		return err
+	}
+	// a lone tuple is unpacked into the struct itself, not one of its fields
+	if len(outputs) > 1 || (value.Kind() == reflect.Struct && outputs[0].Type.T != TupleTy) {
 		switch value.Kind() {
 		// struct will match named return values to the struct's field
 		// names
 		case reflect.Struct:
-			for i := 0; i < len(method.Outputs); i++ {
-				marshalledValue, err := toGoType(i, method.Outputs[i], output)
-				if err != nil {
-					return err
-				}
-				reflectValue := reflect.ValueOf(marshalledValue)
+			for i := 0; i < len(outputs); i++ {
+				reflectValue := reflect.ValueOf(values[i])
 
 				for j := 0; j < typ.NumField(); j++ {
 					field := typ.Field(j)
 					// TODO read tags: `abi:"fieldName"`
-					if field.Name == strings.ToUpper(method.Outputs[i].Name[:1])+method.Outputs[i].Name[1:] {
-						if err := set(value.Field(j), reflectValue, method.Outputs[i]); err != nil {
+					if outputs[i].Name != "" && field.Name == strings.ToUpper(outputs[i].Name[:1])+outputs[i].Name[1:] {
+						if err := set(value.Field(j), reflectValue, outputs[i]); err != nil {
 							return err
 						}
 					}
@@ -276,17 +275,13 @@ func (abi ABI) Unpack(v interface{}, name string, output []byte) error {
 
 			// if the slice already contains values, set those instead of the interface slice itself.
 			if value.Len() > 0 {
-				if len(method.Outputs) > value.Len() {
-					return fmt.Errorf("abi: cannot marshal in to slices of unequal size (require: %v, got: %v)", len(method.Outputs), value.Len())
+				if len(outputs) > value.Len() {
+					return fmt.Errorf("abi: cannot marshal in to slices of unequal size (require: %v, got: %v)", len(outputs), value.Len())
 				}
 
-				for i := 0; i < len(method.Outputs); i++ {
-					marshalledValue, err := toGoType(i, method.Outputs[i], output)
-					if err != nil {
-						return err
-					}
-					reflectValue := reflect.ValueOf(marshalledValue)
-					if err := set(value.Index(i).Elem(), reflectValue, method.Outputs[i]); err != nil {
+				for i := 0; i < len(outputs); i++ {
+					reflectValue := reflect.ValueOf(values[i])
+					if err := set(value.Index(i).Elem(), reflectValue, outputs[i]); err != nil {
 						return err
 					}
 				}
@@ -295,13 +290,9 @@ func (abi ABI) Unpack(v interface{}, name string, output []byte) error {
 
 			// create a new slice and start appending the unmarshalled
 			// values to the new interface slice.
-			z := reflect.MakeSlice(typ, 0, len(method.Outputs))
-			for i := 0; i < len(method.Outputs); i++ {
-				marshalledValue, err := toGoType(i, method.Outputs[i], output)
-				if err != nil {
-					return err
-				}
-				z = reflect.Append(z, reflect.ValueOf(marshalledValue))
+			z := reflect.MakeSlice(typ, 0, len(outputs))
+			for i := 0; i < len(outputs); i++ {
+				z = reflect.Append(z, reflect.ValueOf(values[i]))
 			}
 			value.Set(z)
 		default:
@@ -309,11 +300,7 @@ func (abi ABI) Unpack(v interface{}, name string, output []byte) error {
 		}
 
 	} else {
-		marshalledValue, err := toGoType(0, method.Outputs[0], output)
-		if err != nil {
-			return err
-		}
-		if err := set(value, reflect.ValueOf(marshalledValue), method.Outputs[0]); err != nil {
+		if err := set(value, reflect.ValueOf(values[0]), outputs[0]); err != nil {
 			return err
 		}
 	}
@@ -323,12 +310,12 @@ func (abi ABI) Unpack(v interface{}, name string, output []byte) error {
 
 func (abi *ABI) UnmarshalJSON(data []byte) error {
 	var fields []struct {
-		Type     string
-		Name     string
-		Constant bool
-		Indexed  bool
-		Inputs   []Argument
-		Outputs  []Argument
+		Type      string
+		Name      string
+		Constant  bool
+		Anonymous bool
+		Inputs    []Argument
+		Outputs   []Argument
 	}
 
 	if err := json.Unmarshal(data, &fields); err != nil {
@@ -337,6 +324,7 @@ func (abi *ABI) UnmarshalJSON(data []byte) error {
 
 	abi.Methods = make(map[string]Method)
 	abi.Events = make(map[string]Event)
+	abi.Errors = make(map[string]Error)
 	for _, field := range fields {
 		switch field.Type {
 		case "constructor":
@@ -353,6 +341,12 @@ func (abi *ABI) UnmarshalJSON(data []byte) error {
 			}
 		case "event":
 			abi.Events[field.Name] = Event{
+				Name:      field.Name,
+				Anonymous: field.Anonymous,
+				Inputs:    field.Inputs,
+			}
+		case "error":
+			abi.Errors[field.Name] = Error{
 				Name:   field.Name,
 				Inputs: field.Inputs,
 			}
@@ -361,3 +355,17 @@ func (abi *ABI) UnmarshalJSON(data []byte) error {
 
 	return nil
 }
+
+// ErrorById looks up the custom error the revert data of a failed call was
+// encoded with, by the selector in its first 4 bytes.
+func (abi ABI) ErrorById(data []byte) (*Error, error) {
+	if len(data) < 4 {
+		return nil, fmt.Errorf("abi: revert data too short for an error selector: %d bytes", len(data))
+	}
+	for _, e := range abi.Errors {
+		if bytes.Equal(e.Id(), data[:4]) {
+			return &e, nil
+		}
+	}
+	return nil, fmt.Errorf("abi: no error with id %x", data[:4])
+}
@@ -1150,3 +1150,174 @@ func TestUnmarshal(t *testing.T) {
 		t.Fatal("expected error:", err)
 	}
 }
+
+func TestUnpackRevert(t *testing.T) {
+	tests := []struct {
+		input  string
+		reason string
+		err    bool
+	}{
+		{"", "", true},
+		{"08c379a1", "", true},
+		{"08c379a0", "", true},
+		{"08c379a0" + "0000000000000000000000000000000000000000000000000000000000000020" + "0000000000000000000000000000000000000000000000000000000000000000", "", false},
+		{"08c379a0" + "0000000000000000000000000000000000000000000000000000000000000020" + "000000000000000000000000000000000000000000000000000000000000000d" + "72657665727420726561736f6e00000000000000000000000000000000000000", "revert reason", false},
+		{"08c379a0" + "0000000000000000000000000000000000000000000000000000000000000020" + "00000000000000000000000000000000000000000000000000000000000000ff" + "72657665727420726561736f6e00000000000000000000000000000000000000", "", true},
+		{"08c379a0" + "ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff" + "000000000000000000000000000000000000000000000000000000000000000d", "", true},
+		{"4e487b71" + "0000000000000000000000000000000000000000000000000000000000000001", "assert(false)", false},
+		{"4e487b71" + "0000000000000000000000000000000000000000000000000000000000000012", "division or modulo by zero", false},
+		{"4e487b71" + "00000000000000000000000000000000000000000000000000000000000000ff", "unknown panic code: 0xff", false},
+		{"4e487b71" + "00000000000000000000000000000000000000000000000000000000000000", "", true},
+	}
+	for i, test := range tests {
+		reason, err := UnpackRevert(common.FromHex(test.input))
+		if test.err != (err != nil) {
+			t.Errorf("test %d: error mismatch: have %v, want error %v", i, err, test.err)
+			continue
+		}
+		if reason != test.reason {
+			t.Errorf("test %d: reason mismatch: have %q, want %q", i, reason, test.reason)
+		}
+	}
+}
+
+const tupleDefinition = `[
+	{ "type" : "function", "name" : "set", "inputs" : [ { "name" : "record", "type" : "tuple", "components" : [ { "name" : "id", "type" : "uint256" }, { "name" : "label", "type" : "string" }, { "name" : "owners", "type" : "address[]" } ] } ] },
+	{ "type" : "function", "name" : "get", "constant" : true, "outputs" : [ { "name" : "record", "type" : "tuple", "components" : [ { "name" : "id", "type" : "uint256" }, { "name" : "label", "type" : "string" }, { "name" : "owners", "type" : "address[]" } ] } ] },
+	{ "type" : "function", "name" : "points", "constant" : true, "outputs" : [ { "name" : "", "type" : "tuple[]", "components" : [ { "name" : "x", "type" : "uint64" }, { "name" : "y", "type" : "uint64" } ] }, { "name" : "", "type" : "bool" } ] },
+	{ "type" : "function", "name" : "names", "inputs" : [ { "name" : "names", "type" : "string[]" } ], "outputs" : [ { "name" : "names", "type" : "string[]" } ] },
+	{ "type" : "error", "name" : "Unauthorized", "inputs" : [ { "name" : "caller", "type" : "address" }, { "name" : "reason", "type" : "string" } ] }
+]`
+
+// word left pads a number into an abi word.
+func word(n int64) []byte {
+	return common.LeftPadBytes(big.NewInt(n).Bytes(), 32)
+}
+
+func TestTuplePackUnpack(t *testing.T) {
+	abi, err := JSON(strings.NewReader(tupleDefinition))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sig := abi.Methods["set"].Sig(); sig != "set((uint256,string,address[]))" {
+		t.Errorf("signature mismatch: have %s, want %s", sig, "set((uint256,string,address[]))")
+	}
+	type record struct {
+		Id     *big.Int
+		Label  string
+		Owners []common.Address
+	}
+	in := record{Id: big.NewInt(1), Label: "a", Owners: []common.Address{{1}}}
+
+	packed, err := abi.Pack("set", in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want []byte
+	want = append(want, abi.Methods["set"].Id()...)
+	want = append(want, word(0x20)...)                               // offset of the tuple
+	want = append(want, word(1)...)                                  // id
+	want = append(want, word(0x60)...)                               // offset of the label within the tuple
+	want = append(want, word(0xa0)...)                               // offset of the owners within the tuple
+	want = append(want, word(1)...)                                  // label length
+	want = append(want, pad([]byte("a"), 32, false)...)              // label
+	want = append(want, word(1)...)                                  // owner count
+	want = append(want, pad(common.Address{1}.Bytes(), 32, true)...) // owner
+	if !bytes.Equal(packed, want) {
+		t.Fatalf("packed tuple mismatch:\nhave %x\nwant %x", packed, want)
+	}
+	var out record
+	if err := abi.Unpack(&out, "get", packed[4:]); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("unpacked tuple mismatch: have %+v, want %+v", out, in)
+	}
+}
+
+func TestTupleSliceUnpack(t *testing.T) {
+	abi, err := JSON(strings.NewReader(tupleDefinition))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var output []byte
+	for _, n := range []int64{0x40, 1, 2, 1, 2, 3, 4} {
+		output = append(output, word(n)...)
+	}
+	var (
+		points []struct{ X, Y uint64 }
+		ok     bool
+	)
+	if err := abi.Unpack(&[]interface{}{&points, &ok}, "points", output); err != nil {
+		t.Fatal(err)
+	}
+	if len(points) != 2 || points[0].X != 1 || points[0].Y != 2 || points[1].X != 3 || points[1].Y != 4 || !ok {
+		t.Errorf("unpacked points mismatch: have %+v %v", points, ok)
+	}
+}
+
+func TestDynamicSlicePackUnpack(t *testing.T) {
+	abi, err := JSON(strings.NewReader(tupleDefinition))
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{"one", "two", "three"}
+
+	packed, err := abi.Pack("names", names)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want []byte
+	for _, n := range []int64{0x20, 3, 0x60, 0xa0, 0xe0} {
+		want = append(want, word(n)...)
+	}
+	for _, name := range names {
+		want = append(want, word(int64(len(name)))...)
+		want = append(want, pad([]byte(name), 32, false)...)
+	}
+	if !bytes.Equal(packed[4:], want) {
+		t.Fatalf("packed strings mismatch:\nhave %x\nwant %x", packed[4:], want)
+	}
+	var out []string
+	if err := abi.Unpack(&out, "names", packed[4:]); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out, names) {
+		t.Errorf("unpacked strings mismatch: have %v, want %v", out, names)
+	}
+	// Offsets pointing out of the output must be rejected
+	if err := abi.Unpack(&out, "names", append(word(0x20), word(0x1000)...)); err == nil {
+		t.Errorf("expected error for out of bounds slice")
+	}
+}
+
+func TestErrorUnpack(t *testing.T) {
+	abi, err := JSON(strings.NewReader(tupleDefinition))
+	if err != nil {
+		t.Fatal(err)
+	}
+	decl := abi.Errors["Unauthorized"]
+	if sig := decl.Sig(); sig != "Unauthorized(address,string)" {
+		t.Errorf("signature mismatch: have %s, want %s", sig, "Unauthorized(address,string)")
+	}
+	data := append([]byte{}, decl.Id()...)
+	data = append(data, pad(common.Address{1}.Bytes(), 32, true)...)
+	data = append(data, word(0x40)...)
+	data = append(data, word(4)...)
+	data = append(data, pad([]byte("nope"), 32, false)...)
+
+	found, err := abi.ErrorById(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values, err := found.Unpack(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(values) != 2 || values[0] != (common.Address{1}) || values[1] != "nope" {
+		t.Errorf("unpacked error mismatch: have %v", values)
+	}
+	if _, err := abi.ErrorById(revertSelector); err == nil {
+		t.Errorf("expected error for unknown selector")
+	}
+}
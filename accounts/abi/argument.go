@@ -29,21 +29,28 @@ type Argument struct {
 	Indexed bool // indexed is only used by events
 }
 
+// ArgumentMarshaling is the JSON representation of an argument, carrying the
+// components of tuple types nested within.
+type ArgumentMarshaling struct {
+	Name       string
+	Type       string
+	Components []ArgumentMarshaling
+	Indexed    bool
+}
+
 func (a *Argument) UnmarshalJSON(data []byte) error {
-	var extarg struct {
-		Name string
-		Type string
-	}
+	var extarg ArgumentMarshaling
 	err := json.Unmarshal(data, &extarg)
 	if err != nil {
 		return fmt.Errorf("argument json err: %v", err)
 	}
 
-	a.Type, err = NewType(extarg.Type)
+	a.Type, err = newType(extarg.Type, extarg.Components)
 	if err != nil {
 		return err
 	}
 	a.Name = extarg.Name
+	a.Indexed = extarg.Indexed
 
 	return nil
 }
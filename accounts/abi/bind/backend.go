@@ -22,6 +22,7 @@ import (
 
 	"github.com/ellaism/go-ellaism/common"
 	"github.com/ellaism/go-ellaism/core/types"
+	"github.com/ellaism/go-ellaism/core/vm"
 )
 
 // ErrNoCode is returned by call and transact operations for which the requested
@@ -72,14 +73,48 @@ type ContractTransactor interface {
 	SendTransaction(tx *types.Transaction) error
 }
 
+// FilterQuery contains the criteria for selecting contract logs.
+type FilterQuery struct {
+	FromBlock *big.Int         // Beginning of the queried range, nil means genesis block
+	ToBlock   *big.Int         // End of the range, nil means latest block
+	Addresses []common.Address // Restricts matches to events created by specific contracts
+
+	// Topics restricts matches to particular event topics. Each position lists
+	// the alternatives accepted for that topic, an empty position matches any.
+	Topics [][]common.Hash
+}
+
+// Subscription represents a stream of logs being delivered to a channel.
+type Subscription interface {
+	// Err returns a channel that receives the error terminating the subscription,
+	// if any.
+	Err() <-chan error
+
+	// Unsubscribe stops the delivery of logs. It can be called more than once.
+	Unsubscribe()
+}
+
+// ContractFilterer defines the methods needed to access log events using one-off
+// queries or continuous event subscriptions.
+type ContractFilterer interface {
+	// FilterLogs executes a log filter operation, blocking during execution and
+	// returning all the results in one batch.
+	FilterLogs(query FilterQuery) ([]*vm.Log, error)
+
+	// SubscribeFilterLogs creates a background log filtering operation, returning
+	// a subscription immediately, which can be used to stream the found events.
+	SubscribeFilterLogs(query FilterQuery, ch chan<- *vm.Log) (Subscription, error)
+}
+
 // ContractBackend defines the methods needed to allow operating with contract
 // on a read-write basis.
 //
-// This interface is essentially the union of ContractCaller and ContractTransactor
-// but due to a bug in the Go compiler (https://github.com/golang/go/issues/6977),
-// we cannot simply list it as the two interfaces. The other solution is to add a
-// third interface containing the common methods, but that convolutes the user API
-// as it introduces yet another parameter to require for initialization.
+// This interface is essentially the union of ContractCaller, ContractTransactor
+// and ContractFilterer but due to a bug in the Go compiler
+// (https://github.com/golang/go/issues/6977), we cannot simply list it as the
+// three interfaces. The other solution is to add a fourth interface containing
+// the common methods, but that convolutes the user API as it introduces yet
+// another parameter to require for initialization.
 type ContractBackend interface {
 	// HasCode checks if the contract at the given address has any code associated
 	// with it or not. This is needed to differentiate between contract internal
@@ -108,4 +143,12 @@ type ContractBackend interface {
 
 	// SendTransaction injects the transaction into the pending pool for execution.
 	SendTransaction(tx *types.Transaction) error
+
+	// FilterLogs executes a log filter operation, blocking during execution and
+	// returning all the results in one batch.
+	FilterLogs(query FilterQuery) ([]*vm.Log, error)
+
+	// SubscribeFilterLogs creates a background log filtering operation, returning
+	// a subscription immediately, which can be used to stream the found events.
+	SubscribeFilterLogs(query FilterQuery, ch chan<- *vm.Log) (Subscription, error)
 }
@@ -22,14 +22,19 @@ import (
 	"math/big"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/ellaism/go-ellaism/accounts/abi/bind"
 	"github.com/ellaism/go-ellaism/common"
 	"github.com/ellaism/go-ellaism/core/types"
-	"github.com/ellaism/go-ellaism/rlp"
+	"github.com/ellaism/go-ellaism/core/vm"
 	"github.com/ellaism/go-ellaism/rpc"
 )
 
+// logPollInterval is the time between two polls of a remote log filter backing
+// a log subscription.
+const logPollInterval = time.Second
+
 // This nil assignment ensures compile time that rpcBackend implements bind.ContractBackend.
 var _ bind.ContractBackend = (*rpcBackend)(nil)
 
@@ -229,7 +234,7 @@ func (b *rpcBackend) EstimateGasLimit(sender common.Address, contract *common.Ad
 // SendTransaction implements ContractTransactor.SendTransaction, delegating the
 // raw transaction injection to the remote node.
 func (b *rpcBackend) SendTransaction(tx *types.Transaction) error {
-	data, err := rlp.EncodeToBytes(tx)
+	data, err := tx.MarshalBinary()
 	if err != nil {
 		return err
 	}
@@ -243,3 +248,111 @@ func (b *rpcBackend) SendTransaction(tx *types.Transaction) error {
 	}
 	return nil
 }
+
+// FilterLogs implements ContractFilterer.FilterLogs, delegating the log search
+// to the remote node.
+func (b *rpcBackend) FilterLogs(query bind.FilterQuery) ([]*vm.Log, error) {
+	res, err := b.request("eth_getLogs", []interface{}{toFilterArg(query)})
+	if err != nil {
+		return nil, err
+	}
+	var logs []*vm.Log
+	if err := json.Unmarshal(res, &logs); err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
+
+// SubscribeFilterLogs implements ContractFilterer.SubscribeFilterLogs, installing
+// a log filter on the remote node and polling it for changes in the background.
+func (b *rpcBackend) SubscribeFilterLogs(query bind.FilterQuery, ch chan<- *vm.Log) (bind.Subscription, error) {
+	res, err := b.request("eth_newFilter", []interface{}{toFilterArg(query)})
+	if err != nil {
+		return nil, err
+	}
+	var id string
+	if err := json.Unmarshal(res, &id); err != nil {
+		return nil, err
+	}
+	sub := &pollSubscription{
+		err:  make(chan error, 1),
+		quit: make(chan struct{}),
+	}
+	go b.pollLogs(id, sub, ch)
+	return sub, nil
+}
+
+// pollLogs periodically retrieves the changes of a remote log filter, feeding
+// them into ch until the subscription is torn down or the polling fails.
+func (b *rpcBackend) pollLogs(id string, sub *pollSubscription, ch chan<- *vm.Log) {
+	defer b.request("eth_uninstallFilter", []interface{}{id})
+
+	ticker := time.NewTicker(logPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-sub.quit:
+			return
+		}
+		res, err := b.request("eth_getFilterChanges", []interface{}{id})
+		if err == nil {
+			var logs []*vm.Log
+			if err = json.Unmarshal(res, &logs); err == nil {
+				for _, log := range logs {
+					select {
+					case ch <- log:
+					case <-sub.quit:
+						return
+					}
+				}
+				continue
+			}
+		}
+		sub.err <- err
+		return
+	}
+}
+
+// toFilterArg converts a binding filter query into the remote log filter
+// criteria. Empty topic positions are sent as null, matching any topic.
+func toFilterArg(query bind.FilterQuery) interface{} {
+	arg := map[string]interface{}{
+		"address":   query.Addresses,
+		"fromBlock": "0x0",
+		"toBlock":   "latest",
+	}
+	if query.FromBlock != nil {
+		arg["fromBlock"] = fmt.Sprintf("%#x", query.FromBlock)
+	}
+	if query.ToBlock != nil {
+		arg["toBlock"] = fmt.Sprintf("%#x", query.ToBlock)
+	}
+	topics := make([]interface{}, len(query.Topics))
+	for i, rules := range query.Topics {
+		if len(rules) > 0 {
+			topics[i] = rules
+		}
+	}
+	arg["topics"] = topics
+
+	return arg
+}
+
+// pollSubscription is a log subscription backed by a polled remote filter.
+type pollSubscription struct {
+	err  chan error
+	quit chan struct{}
+	once sync.Once
+}
+
+// Err returns a channel that receives the error that ended the polling.
+func (s *pollSubscription) Err() <-chan error {
+	return s.err
+}
+
+// Unsubscribe stops the polling and uninstalls the remote filter.
+func (s *pollSubscription) Unsubscribe() {
+	s.once.Do(func() { close(s.quit) })
+}
@@ -17,13 +17,17 @@
 package backends
 
 import (
+	"errors"
 	"math/big"
+	"time"
 
 	"github.com/ellaism/go-ellaism/accounts/abi/bind"
 	"github.com/ellaism/go-ellaism/common"
 	"github.com/ellaism/go-ellaism/core"
 	"github.com/ellaism/go-ellaism/core/state"
 	"github.com/ellaism/go-ellaism/core/types"
+	"github.com/ellaism/go-ellaism/core/vm"
+	"github.com/ellaism/go-ellaism/eth/filters"
 	"github.com/ellaism/go-ellaism/ethdb"
 	"github.com/ellaism/go-ellaism/event"
 )
@@ -31,26 +35,43 @@ import (
 // This nil assignment ensures compile time that SimulatedBackend implements bind.ContractBackend.
 var _ bind.ContractBackend = (*SimulatedBackend)(nil)
 
+// errInvalidTimeAdjustment is returned if a time adjustment would move the
+// pending block to or before its parent.
+var errInvalidTimeAdjustment = errors.New("time adjustment would not advance past the parent block")
+
 // SimulatedBackend implements bind.ContractBackend, simulating a blockchain in
 // the background. Its main purpose is to allow easily testing contract bindings.
 type SimulatedBackend struct {
-	database   ethdb.Database   // In memory database to store our testing data
-	blockchain *core.BlockChain // Ethereum blockchain to handle the consensus
+	database   ethdb.Database    // In memory database to store our testing data
+	blockchain *core.BlockChain  // Ethereum blockchain to handle the consensus
+	config     *core.ChainConfig // Chain rules the simulated blocks are generated and validated with
+	mux        *event.TypeMux    // Event mux the blockchain posts the logs of imported blocks on
 
 	pendingBlock *types.Block   // Currently pending block that will be imported on request
 	pendingState *state.StateDB // Currently pending state that will be the active on on request
+	pendingTime  int64          // Seconds the pending block is shifted from the default block time
 }
 
 // NewSimulatedBackend creates a new binding backend using a simulated blockchain
-// for testing purposes.
+// following the Ellaism mainnet consensus rules for testing purposes.
 func NewSimulatedBackend(accounts ...core.GenesisAccount) *SimulatedBackend {
+	return NewSimulatedBackendWithConfig(core.DefaultConfigMainnet.ChainConfig, accounts...)
+}
+
+// NewSimulatedBackendWithConfig creates a new binding backend using a simulated
+// blockchain following the given chain rules, allowing contracts to be tested
+// against specific forks.
+func NewSimulatedBackendWithConfig(config *core.ChainConfig, accounts ...core.GenesisAccount) *SimulatedBackend {
 	database, _ := ethdb.NewMemDatabase()
 	core.WriteGenesisBlockForTesting(database, accounts...)
-	blockchain, _ := core.NewBlockChain(database, core.DefaultConfigMorden.ChainConfig, new(core.FakePow), new(event.TypeMux))
+	mux := new(event.TypeMux)
+	blockchain, _ := core.NewBlockChain(database, config, new(core.FakePow), mux)
 
 	backend := &SimulatedBackend{
 		database:   database,
 		blockchain: blockchain,
+		config:     config,
+		mux:        mux,
 	}
 	backend.Rollback()
 
@@ -68,8 +89,35 @@ func (b *SimulatedBackend) Commit() {
 
 // Rollback aborts all pending transactions, reverting to the last committed state.
 func (b *SimulatedBackend) Rollback() {
-	blocks, _ := core.GenerateChain(core.DefaultConfigMorden.ChainConfig, b.blockchain.CurrentBlock(), b.database, 1, func(int, *core.BlockGen) {})
+	b.pendingTime = 0
+	b.rebuild(nil)
+}
 
+// AdjustTime shifts the timestamp of the pending block by the given duration,
+// keeping its transactions. Blocks are spaced ten seconds apart by default, so
+// the pending block can be moved back by at most nine seconds.
+func (b *SimulatedBackend) AdjustTime(adjustment time.Duration) error {
+	offset := b.pendingTime + int64(adjustment/time.Second)
+	if offset <= -10 {
+		return errInvalidTimeAdjustment
+	}
+	b.pendingTime = offset
+	b.rebuild(b.pendingBlock.Transactions())
+
+	return nil
+}
+
+// rebuild regenerates the pending block and state on top of the current head
+// from the given transactions, applying the pending time shift.
+func (b *SimulatedBackend) rebuild(txs types.Transactions) {
+	blocks, _ := core.GenerateChain(b.config, b.blockchain.CurrentBlock(), b.database, 1, func(number int, block *core.BlockGen) {
+		if b.pendingTime != 0 {
+			block.OffsetTime(b.pendingTime)
+		}
+		for _, tx := range txs {
+			block.AddTx(tx)
+		}
+	})
 	b.pendingBlock = blocks[0]
 	b.pendingState, _ = state.New(b.pendingBlock.Root(), b.database)
 }
@@ -117,7 +165,7 @@ func (b *SimulatedBackend) ContractCall(contract common.Address, data []byte, pe
 		data:     data,
 	}
 	// Execute the call and return
-	vmenv := core.NewEnv(statedb, core.DefaultConfigMorden.ChainConfig, b.blockchain, msg, block.Header())
+	vmenv := core.NewEnv(statedb, b.config, b.blockchain, msg, block.Header())
 	gaspool := new(core.GasPool).AddGas(common.MaxBig)
 
 	out, _, err := core.ApplyMessage(vmenv, msg, gaspool)
@@ -167,28 +215,50 @@ func (b *SimulatedBackend) EstimateGasLimit(sender common.Address, contract *com
 		data:     data,
 	}
 	// Execute the call and return
-	vmenv := core.NewEnv(statedb, core.DefaultConfigMorden.ChainConfig, b.blockchain, msg, block.Header())
+	vmenv := core.NewEnv(statedb, b.config, b.blockchain, msg, block.Header())
 	gaspool := new(core.GasPool).AddGas(common.MaxBig)
 
 	_, gas, _, err := core.NewStateTransition(vmenv, msg, gaspool).TransitionDb()
 	return gas, err
 }
 
-// SendTransaction implements ContractTransactor.SendTransaction, delegating the raw
-// transaction injection to the remote node.
+// SendTransaction implements ContractTransactor.SendTransaction, executing the
+// transaction on top of the pending block.
 func (b *SimulatedBackend) SendTransaction(tx *types.Transaction) error {
-	blocks, _ := core.GenerateChain(core.DefaultConfigMorden.ChainConfig, b.blockchain.CurrentBlock(), b.database, 1, func(number int, block *core.BlockGen) {
-		for _, tx := range b.pendingBlock.Transactions() {
-			block.AddTx(tx)
-		}
-		block.AddTx(tx)
-	})
-	b.pendingBlock = blocks[0]
-	b.pendingState, _ = state.New(b.pendingBlock.Root(), b.database)
-
+	b.rebuild(append(b.pendingBlock.Transactions(), tx))
 	return nil
 }
 
+// FilterLogs implements ContractFilterer.FilterLogs, searching the committed
+// blocks for logs matching the query.
+func (b *SimulatedBackend) FilterLogs(query bind.FilterQuery) ([]*vm.Log, error) {
+	return newLogFilter(b.database, query).Find()
+}
+
+// SubscribeFilterLogs implements ContractFilterer.SubscribeFilterLogs, streaming
+// the logs matching the query as blocks are committed.
+func (b *SimulatedBackend) SubscribeFilterLogs(query bind.FilterQuery, ch chan<- *vm.Log) (bind.Subscription, error) {
+	return filters.SubscribeLogs(b.mux, newLogFilter(b.database, query), ch), nil
+}
+
+// newLogFilter converts a binding filter query into a chain log filter.
+func newLogFilter(db ethdb.Database, query bind.FilterQuery) *filters.Filter {
+	filter := filters.New(db)
+
+	filter.SetBeginBlock(0)
+	if query.FromBlock != nil {
+		filter.SetBeginBlock(query.FromBlock.Int64())
+	}
+	filter.SetEndBlock(-1)
+	if query.ToBlock != nil {
+		filter.SetEndBlock(query.ToBlock.Int64())
+	}
+	filter.SetAddresses(query.Addresses)
+	filter.SetTopics(query.Topics)
+
+	return filter
+}
+
 // callmsg implements core.Message to allow passing it as a transaction simulator.
 type callmsg struct {
 	from     *state.StateObject
@@ -207,3 +277,4 @@ func (m callmsg) GasPrice() *big.Int                    { return m.gasPrice }
 func (m callmsg) Gas() *big.Int                         { return m.gasLimit }
 func (m callmsg) Value() *big.Int                       { return m.value }
 func (m callmsg) Data() []byte                          { return m.data }
+func (m callmsg) AccessList() types.AccessList          { return nil }
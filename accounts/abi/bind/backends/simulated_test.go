@@ -0,0 +1,83 @@
+// Copyright 2016 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package backends
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/core"
+	"github.com/ellaism/go-ellaism/core/types"
+	"github.com/ellaism/go-ellaism/crypto"
+)
+
+// Tests that transactions signed for the Ellaism chain are executed on the
+// pending block and imported on commit.
+func TestSimulatedBackendTransfer(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	to := common.HexToAddress("0x00000000000000000000000000000000000000aa")
+
+	sim := NewSimulatedBackend(core.GenesisAccount{Address: from, Balance: big.NewInt(1000000000000000000)})
+
+	signer := sim.config.GetSigner(big.NewInt(1))
+	tx, err := types.NewTransaction(0, to, big.NewInt(1000), core.TxGas, big.NewInt(1), nil).WithSigner(signer).SignECDSA(key)
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	if err := sim.SendTransaction(tx); err != nil {
+		t.Fatalf("failed to send transaction: %v", err)
+	}
+	if nonce, _ := sim.PendingAccountNonce(from); nonce != 1 {
+		t.Errorf("pending nonce mismatch: have %d, want %d", nonce, 1)
+	}
+	sim.Commit()
+
+	if number := sim.blockchain.CurrentBlock().NumberU64(); number != 1 {
+		t.Fatalf("head number mismatch: have %d, want %d", number, 1)
+	}
+	statedb, _ := sim.blockchain.State()
+	if balance := statedb.GetBalance(to); balance.Cmp(big.NewInt(1000)) != 0 {
+		t.Errorf("recipient balance mismatch: have %v, want %v", balance, 1000)
+	}
+}
+
+// Tests that the time of the pending block can be shifted, and that the
+// shifted block is accepted by the chain.
+func TestSimulatedBackendAdjustTime(t *testing.T) {
+	sim := NewSimulatedBackend()
+	parent := sim.blockchain.CurrentBlock().Time().Int64()
+
+	if err := sim.AdjustTime(-10 * time.Second); err != errInvalidTimeAdjustment {
+		t.Errorf("adjustment before parent error mismatch: have %v, want %v", err, errInvalidTimeAdjustment)
+	}
+	if err := sim.AdjustTime(time.Minute); err != nil {
+		t.Fatalf("failed to adjust time: %v", err)
+	}
+	sim.Commit()
+
+	if have, want := sim.blockchain.CurrentBlock().Time().Int64()-parent, int64(70); have != want {
+		t.Errorf("block time delta mismatch: have %d, want %d", have, want)
+	}
+	// The shift only applies to the block it was made for
+	sim.Commit()
+	if have, want := sim.blockchain.CurrentBlock().Time().Int64()-parent, int64(80); have != want {
+		t.Errorf("block time delta mismatch: have %d, want %d", have, want)
+	}
+}
@@ -20,11 +20,13 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"sync"
 	"sync/atomic"
 
 	"github.com/ellaism/go-ellaism/accounts/abi"
 	"github.com/ellaism/go-ellaism/common"
 	"github.com/ellaism/go-ellaism/core/types"
+	"github.com/ellaism/go-ellaism/core/vm"
 	"github.com/ellaism/go-ellaism/crypto"
 )
 
@@ -49,6 +51,13 @@ type TransactOpts struct {
 	GasLimit *big.Int // Gas limit to set for the transaction execution (nil = estimate + 10%)
 }
 
+// FilterOpts is the collection of options to fine tune filtering for events
+// within a bound contract.
+type FilterOpts struct {
+	Start uint64  // Start of the queried range
+	End   *uint64 // End of the range (nil = latest)
+}
+
 // BoundContract is the base wrapper object that reflects a contract on the
 // Ethereum network. It contains a collection of methods that are used by the
 // higher level contract bindings to operate.
@@ -57,6 +66,7 @@ type BoundContract struct {
 	abi        abi.ABI            // Reflect based ABI to access the correct Ethereum methods
 	caller     ContractCaller     // Read interface to interact with the blockchain
 	transactor ContractTransactor // Write interface to interact with the blockchain
+	filterer   ContractFilterer   // Event filtering to interact with the blockchain
 
 	latestHasCode  uint32 // Cached verification that the latest state contains code for this contract
 	pendingHasCode uint32 // Cached verification that the pending state contains code for this contract
@@ -64,12 +74,13 @@ type BoundContract struct {
 
 // NewBoundContract creates a low level contract interface through which calls
 // and transactions may be made through.
-func NewBoundContract(address common.Address, abi abi.ABI, caller ContractCaller, transactor ContractTransactor) *BoundContract {
+func NewBoundContract(address common.Address, abi abi.ABI, caller ContractCaller, transactor ContractTransactor, filterer ContractFilterer) *BoundContract {
 	return &BoundContract{
 		address:    address,
 		abi:        abi,
 		caller:     caller,
 		transactor: transactor,
+		filterer:   filterer,
 	}
 }
 
@@ -77,7 +88,7 @@ func NewBoundContract(address common.Address, abi abi.ABI, caller ContractCaller
 // deployment address with a Go wrapper.
 func DeployContract(opts *TransactOpts, abi abi.ABI, bytecode []byte, backend ContractBackend, params ...interface{}) (common.Address, *types.Transaction, *BoundContract, error) {
 	// Otherwise try to deploy the contract
-	c := NewBoundContract(common.Address{}, abi, backend, backend)
+	c := NewBoundContract(common.Address{}, abi, backend, backend, backend)
 
 	input, err := c.abi.Pack("", params...)
 	if err != nil {
@@ -204,3 +215,132 @@ func (c *BoundContract) transact(opts *TransactOpts, contract *common.Address, i
 	}
 	return signedTx, nil
 }
+
+// FilterLogs filters contract logs for past blocks, returning the logs of the
+// named event matching the indexed argument rules given in query.
+func (c *BoundContract) FilterLogs(opts *FilterOpts, name string, query ...[]interface{}) ([]*vm.Log, error) {
+	// Don't crash on a lazy user
+	if opts == nil {
+		opts = new(FilterOpts)
+	}
+	filter, err := c.filterQuery(name, query...)
+	if err != nil {
+		return nil, err
+	}
+	filter.FromBlock = new(big.Int).SetUint64(opts.Start)
+	if opts.End != nil {
+		filter.ToBlock = new(big.Int).SetUint64(*opts.End)
+	}
+	return c.filterer.FilterLogs(filter)
+}
+
+// WatchLogs subscribes to the logs of the named event matching the indexed
+// argument rules given in query, delivering them to sink as new blocks arrive.
+func (c *BoundContract) WatchLogs(sink chan<- *vm.Log, name string, query ...[]interface{}) (Subscription, error) {
+	filter, err := c.filterQuery(name, query...)
+	if err != nil {
+		return nil, err
+	}
+	return c.filterer.SubscribeFilterLogs(filter, sink)
+}
+
+// WatchEvents subscribes to the logs of the named event like WatchLogs, handing
+// each of them to deliver in the background. Delivery stops when the returned
+// subscription is unsubscribed, or with the first error of the log subscription
+// or of deliver, which is then reported on Err.
+func (c *BoundContract) WatchEvents(deliver func(log *vm.Log, quit <-chan struct{}) error, name string, query ...[]interface{}) (Subscription, error) {
+	logs := make(chan *vm.Log)
+	sub, err := c.WatchLogs(logs, name, query...)
+	if err != nil {
+		return nil, err
+	}
+	events := &eventSubscription{
+		err:  make(chan error, 1),
+		quit: make(chan struct{}),
+	}
+	go func() {
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case log := <-logs:
+				if err := deliver(log, events.quit); err != nil {
+					events.err <- err
+					return
+				}
+			case err := <-sub.Err():
+				if err != nil {
+					events.err <- err
+				}
+				return
+			case <-events.quit:
+				return
+			}
+		}
+	}()
+	return events, nil
+}
+
+// eventSubscription is the subscription of a typed event stream built on top
+// of a log subscription.
+type eventSubscription struct {
+	err  chan error
+	quit chan struct{}
+	once sync.Once
+}
+
+// Err returns a channel that receives the error that ended the delivery.
+func (s *eventSubscription) Err() <-chan error {
+	return s.err
+}
+
+// Unsubscribe stops the delivery of events and the underlying log subscription.
+func (s *eventSubscription) Unsubscribe() {
+	s.once.Do(func() { close(s.quit) })
+}
+
+// filterQuery assembles the filter criteria selecting the logs of the named
+// event emitted by the contract.
+func (c *BoundContract) filterQuery(name string, query ...[]interface{}) (FilterQuery, error) {
+	if c.filterer == nil {
+		return FilterQuery{}, errors.New("no filterer to retrieve the contract logs with")
+	}
+	event, ok := c.abi.Events[name]
+	if !ok {
+		return FilterQuery{}, fmt.Errorf("event '%s' not found", name)
+	}
+	topics, err := abi.MakeTopics(query...)
+	if err != nil {
+		return FilterQuery{}, err
+	}
+	// Anonymous events don't carry their signature in the first topic
+	if !event.Anonymous {
+		topics = append([][]common.Hash{{event.Id()}}, topics...)
+	}
+	return FilterQuery{Addresses: []common.Address{c.address}, Topics: topics}, nil
+}
+
+// UnpackLog unpacks a retrieved log into the provided output structure, the
+// data fields from the log payload and the indexed ones from its topics.
+func (c *BoundContract) UnpackLog(out interface{}, name string, log *vm.Log) error {
+	event, ok := c.abi.Events[name]
+	if !ok {
+		return fmt.Errorf("event '%s' not found", name)
+	}
+	if err := c.abi.Unpack(out, name, log.Data); err != nil {
+		return err
+	}
+	topics := log.Topics
+	if !event.Anonymous {
+		if len(topics) == 0 || topics[0] != event.Id() {
+			return fmt.Errorf("log is not a '%s' event", name)
+		}
+		topics = topics[1:]
+	}
+	var indexed []abi.Argument
+	for _, arg := range event.Inputs {
+		if arg.Indexed {
+			indexed = append(indexed, arg)
+		}
+	}
+	return abi.ParseTopics(out, indexed, topics)
+}
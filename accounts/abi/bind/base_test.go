@@ -0,0 +1,112 @@
+// Copyright 2016 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package bind_test
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ellaism/go-ellaism/accounts/abi"
+	"github.com/ellaism/go-ellaism/accounts/abi/bind"
+	"github.com/ellaism/go-ellaism/accounts/abi/bind/backends"
+	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/core"
+	"github.com/ellaism/go-ellaism/core/vm"
+	"github.com/ellaism/go-ellaism/crypto"
+)
+
+// pingABI describes a contract raising a Ping event with the sender and value
+// of every transaction it receives.
+const pingABI = `[{"anonymous":false,"inputs":[{"indexed":true,"name":"sender","type":"address"},{"indexed":false,"name":"value","type":"uint256"}],"name":"Ping","type":"event"}]`
+
+// pingCode assembles the deployment code of the Ping contract: the runtime code
+// stores the call value in memory and logs it with the event id and the caller
+// as topics.
+func pingCode(id common.Hash) []byte {
+	runtime := append(common.FromHex("34600052337f"), id[:]...)
+	runtime = append(runtime, common.FromHex("60206000a200")...)
+
+	return append(common.FromHex("602c600c600039602c6000f3"), runtime...)
+}
+
+// pingEvent is the Go representation of a Ping event.
+type pingEvent struct {
+	Sender common.Address
+	Value  *big.Int
+}
+
+// Tests that the logs of a bound contract can be retrieved, watched and
+// unpacked into typed events.
+func TestBoundContractLogs(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	auth := bind.NewKeyedTransactor(key)
+	sim := backends.NewSimulatedBackend(core.GenesisAccount{Address: auth.From, Balance: big.NewInt(1000000000000000000)})
+
+	parsed, err := abi.JSON(strings.NewReader(pingABI))
+	if err != nil {
+		t.Fatalf("failed to parse ABI: %v", err)
+	}
+	_, _, contract, err := bind.DeployContract(auth, parsed, pingCode(parsed.Events["Ping"].Id()), sim)
+	if err != nil {
+		t.Fatalf("failed to deploy contract: %v", err)
+	}
+	sim.Commit()
+
+	// Start watching for events before raising any
+	logs := make(chan *vm.Log, 1)
+	sub, err := contract.WatchLogs(logs, "Ping", []interface{}{auth.From})
+	if err != nil {
+		t.Fatalf("failed to watch logs: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	auth.Value = big.NewInt(7)
+	if _, err := contract.Transfer(auth); err != nil {
+		t.Fatalf("failed to ping contract: %v", err)
+	}
+	sim.Commit()
+
+	// Check the event is found by filtering, and only for the matching sender
+	found, err := contract.FilterLogs(nil, "Ping", []interface{}{auth.From})
+	if err != nil {
+		t.Fatalf("failed to filter logs: %v", err)
+	}
+	if len(found) != 1 {
+		t.Fatalf("filtered log count mismatch: have %d, want %d", len(found), 1)
+	}
+	event := new(pingEvent)
+	if err := contract.UnpackLog(event, "Ping", found[0]); err != nil {
+		t.Fatalf("failed to unpack log: %v", err)
+	}
+	if event.Sender != auth.From || event.Value.Cmp(auth.Value) != 0 {
+		t.Errorf("event mismatch: have %x/%v, want %x/%v", event.Sender, event.Value, auth.From, auth.Value)
+	}
+	if found, err := contract.FilterLogs(nil, "Ping", []interface{}{common.HexToAddress("0x01")}); err != nil || len(found) != 0 {
+		t.Errorf("unrelated sender: have %d logs (%v), want none", len(found), err)
+	}
+	// Check the event was delivered to the watcher too
+	select {
+	case log := <-logs:
+		if log.TxHash != found[0].TxHash {
+			t.Errorf("watched log mismatch: have tx %x, want %x", log.TxHash, found[0].TxHash)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("watched log not delivered")
+	}
+}
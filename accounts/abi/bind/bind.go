@@ -85,6 +85,21 @@ func Bind(types []string, abis []string, bytecodes []string, pkg string) (string
 				transacts[original.Name] = &tmplMethod{Original: original, Normalized: normalized, Structured: structured(original)}
 			}
 		}
+		// Extract the events, normalizing their arguments the same way
+		events := make(map[string]*tmplEvent)
+		for _, original := range evmABI.Events {
+			normalized := original
+			normalized.Name = capitalise(original.Name)
+
+			normalized.Inputs = make([]abi.Argument, len(original.Inputs))
+			copy(normalized.Inputs, original.Inputs)
+			for j, input := range normalized.Inputs {
+				if input.Name == "" {
+					normalized.Inputs[j].Name = fmt.Sprintf("arg%d", j)
+				}
+			}
+			events[original.Name] = &tmplEvent{Original: original, Normalized: normalized}
+		}
 		contracts[types[i]] = &tmplContract{
 			Type:        capitalise(types[i]),
 			InputABI:    strippedABI,
@@ -92,6 +107,7 @@ func Bind(types []string, abis []string, bytecodes []string, pkg string) (string
 			Constructor: evmABI.Constructor,
 			Calls:       calls,
 			Transacts:   transacts,
+			Events:      events,
 		}
 	}
 	// Generate the contract template data content and render it
@@ -102,7 +118,10 @@ func Bind(types []string, abis []string, bytecodes []string, pkg string) (string
 	buffer := new(bytes.Buffer)
 
 	funcs := map[string]interface{}{
-		"bindtype": bindType,
+		"bindtype":       bindType,
+		"bindtopictype":  bindTopicType,
+		"bindfiltertype": bindFilterType,
+		"capitalise":     capitalise,
 	}
 	tmpl := template.Must(template.New("").Funcs(funcs).Parse(tmplSource))
 	if err := tmpl.Execute(buffer, data); err != nil {
@@ -123,6 +142,16 @@ func bindType(kind abi.Type) string {
 	stringKind := kind.String()
 
 	switch {
+	case kind.T == abi.TupleTy:
+		fields := make([]string, len(kind.TupleElems))
+		for i, elem := range kind.TupleElems {
+			fields[i] = fmt.Sprintf("%s %s", kind.Type.Field(i).Name, bindType(*elem))
+		}
+		return fmt.Sprintf("struct{%s}", strings.Join(fields, "; "))
+
+	case (kind.IsSlice || kind.IsArray) && kind.Elem.T == abi.TupleTy:
+		return stringKind[strings.LastIndex(stringKind, "["):] + bindType(*kind.Elem)
+
 	case strings.HasPrefix(stringKind, "address"):
 		parts := regexp.MustCompile("address(\\[[0-9]*\\])?").FindStringSubmatch(stringKind)
 		if len(parts) != 2 {
@@ -160,6 +189,26 @@ func bindType(kind abi.Type) string {
 	}
 }
 
+// bindTopicType converts the Solidity type of an indexed event argument to a Go
+// one. Dynamic types are only stored as the hash of their content in the log
+// topics, so they are bound to hashes.
+func bindTopicType(kind abi.Type) string {
+	if kind.HashedInTopics() {
+		return "common.Hash"
+	}
+	return bindType(kind)
+}
+
+// bindFilterType converts the Solidity type of an indexed event argument to the
+// Go type its filter rules are given in. Strings and bytes are hashed when the
+// topics are assembled, other hashed types have to be given as hashes.
+func bindFilterType(kind abi.Type) string {
+	if kind.T == abi.StringTy || kind.T == abi.BytesTy {
+		return bindType(kind)
+	}
+	return bindTopicType(kind)
+}
+
 // capitalise makes the first character of a string upper case.
 func capitalise(input string) string {
 	return strings.ToUpper(input[:1]) + input[1:]
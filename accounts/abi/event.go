@@ -27,8 +27,40 @@ import (
 // Event is an event potentially triggered by the EVM's LOG mechanism. The Event
 // holds type information (inputs) about the yielded output
 type Event struct {
-	Name   string
-	Inputs []Argument
+	Name      string
+	Anonymous bool
+	Inputs    []Argument
+}
+
+// NonIndexed returns the inputs of the event that are stored in the log data
+// instead of its topics.
+func (e Event) NonIndexed() []Argument {
+	var args []Argument
+	for _, input := range e.Inputs {
+		if !input.Indexed {
+			args = append(args, input)
+		}
+	}
+	return args
+}
+
+// String returns the Solidity declaration of the event.
+func (e Event) String() string {
+	inputs := make([]string, len(e.Inputs))
+	for i, input := range e.Inputs {
+		inputs[i] = input.Type.String()
+		if input.Indexed {
+			inputs[i] += " indexed"
+		}
+		if len(input.Name) > 0 {
+			inputs[i] += " " + input.Name
+		}
+	}
+	anonymous := ""
+	if e.Anonymous {
+		anonymous = " anonymous"
+	}
+	return fmt.Sprintf("event %v(%v)%s", e.Name, strings.Join(inputs, ", "), anonymous)
 }
 
 // Id returns the canonical representation of the event's signature used by the
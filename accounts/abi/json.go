@@ -0,0 +1,214 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package abi
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+
+	"github.com/ellaism/go-ellaism/common"
+)
+
+// PackJSON packs the given JSON arguments for the method with the given name,
+// or the constructor if the name is empty, as Pack does for Go values. See
+// Type.ParseJSON for the JSON representation of the argument types.
+func (abi ABI) PackJSON(name string, args []json.RawMessage) ([]byte, error) {
+	method := abi.Constructor
+	if name != "" {
+		m, exist := abi.Methods[name]
+		if !exist {
+			return nil, fmt.Errorf("method '%s' not found", name)
+		}
+		method = m
+	}
+	if len(args) != len(method.Inputs) {
+		return nil, fmt.Errorf("argument count mismatch: %d for %d", len(args), len(method.Inputs))
+	}
+	values := make([]interface{}, len(args))
+	for i, arg := range args {
+		value, err := method.Inputs[i].Type.ParseJSON(arg)
+		if err != nil {
+			return nil, fmt.Errorf("argument %d (%s): %v", i, method.Inputs[i].Name, err)
+		}
+		values[i] = value
+	}
+	return abi.Pack(name, values...)
+}
+
+// ParseJSON parses the JSON representation of a value of the type into the Go
+// value Pack expects for it:
+//   - integers are numbers or strings holding decimal or 0x prefixed hex numbers
+//   - booleans and strings are JSON booleans and strings
+//   - addresses, bytes and fixed size bytes are 0x prefixed hex strings, the
+//     latter holding exactly as many bytes as the type
+//   - arrays and slices are JSON arrays
+//   - tuples are JSON arrays of their components or objects keyed by their names
+func (t Type) ParseJSON(data json.RawMessage) (interface{}, error) {
+	value, err := t.parseJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	return value.Interface(), nil
+}
+
+func (t Type) parseJSON(data json.RawMessage) (reflect.Value, error) {
+	switch {
+	case t.T == FixedBytesTy:
+		b, err := parseJSONBytes(data)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if len(b) != t.SliceSize {
+			return reflect.Value{}, fmt.Errorf("%d bytes for %v", len(b), t)
+		}
+		value := reflect.New(t.goType()).Elem()
+		reflect.Copy(value, reflect.ValueOf(b))
+		return value, nil
+	case t.T == BytesTy:
+		b, err := parseJSONBytes(data)
+		return reflect.ValueOf(b), err
+	case t.IsSlice || t.IsArray:
+		var elems []json.RawMessage
+		if err := json.Unmarshal(data, &elems); err != nil {
+			return reflect.Value{}, fmt.Errorf("invalid %v: %v", t, err)
+		}
+		if t.IsArray && len(elems) != t.SliceSize {
+			return reflect.Value{}, fmt.Errorf("%d elements for %v", len(elems), t)
+		}
+		value := reflect.MakeSlice(reflect.SliceOf(t.Elem.goType()), len(elems), len(elems))
+		if t.IsArray {
+			value = reflect.New(t.goType()).Elem()
+		}
+		for i, elem := range elems {
+			v, err := t.Elem.parseJSON(elem)
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("element %d: %v", i, err)
+			}
+			value.Index(i).Set(v)
+		}
+		return value, nil
+	}
+	switch t.T {
+	case IntTy, UintTy:
+		return t.parseJSONInteger(data)
+	case BoolTy:
+		var b bool
+		if err := json.Unmarshal(data, &b); err != nil {
+			return reflect.Value{}, fmt.Errorf("invalid bool: %v", err)
+		}
+		return reflect.ValueOf(b), nil
+	case StringTy:
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return reflect.Value{}, fmt.Errorf("invalid string: %v", err)
+		}
+		return reflect.ValueOf(s), nil
+	case AddressTy:
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil || !common.IsHexAddress(s) {
+			return reflect.Value{}, fmt.Errorf("invalid address %s", data)
+		}
+		return reflect.ValueOf(common.HexToAddress(s)), nil
+	case TupleTy:
+		return t.parseJSONTuple(data)
+	}
+	return reflect.Value{}, fmt.Errorf("unsupported type %v", t)
+}
+
+// parseJSONInteger parses an integer, checking it fits the type.
+func (t Type) parseJSONInteger(data json.RawMessage) (reflect.Value, error) {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		var n json.Number
+		if err := json.Unmarshal(data, &n); err != nil {
+			return reflect.Value{}, fmt.Errorf("invalid %v %s", t, data)
+		}
+		s = n.String()
+	}
+	num, ok := new(big.Int).SetString(s, 0)
+	if !ok {
+		return reflect.Value{}, fmt.Errorf("invalid %v %q", t, s)
+	}
+	if t.T == UintTy {
+		if num.Sign() < 0 || num.BitLen() > t.Size {
+			return reflect.Value{}, fmt.Errorf("%v out of %v range", num, t)
+		}
+	} else {
+		limit := new(big.Int).Lsh(common.Big1, uint(t.Size-1))
+		if num.Cmp(limit) >= 0 || num.Cmp(new(big.Int).Neg(limit)) < 0 {
+			return reflect.Value{}, fmt.Errorf("%v out of %v range", num, t)
+		}
+	}
+	if t.Kind == reflect.Ptr {
+		return reflect.ValueOf(num), nil
+	}
+	value := reflect.New(t.goType()).Elem()
+	if t.T == UintTy {
+		value.SetUint(num.Uint64())
+	} else {
+		value.SetInt(num.Int64())
+	}
+	return value, nil
+}
+
+// parseJSONTuple parses a tuple from an array of its components or an object
+// keyed by their names.
+func (t Type) parseJSONTuple(data json.RawMessage) (reflect.Value, error) {
+	components := make([]json.RawMessage, len(t.TupleElems))
+	if err := json.Unmarshal(data, &components); err != nil {
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return reflect.Value{}, fmt.Errorf("invalid %v: neither an array nor an object", t)
+		}
+		components = components[:0]
+		for _, name := range t.TupleRawNames {
+			field, ok := fields[name]
+			if !ok {
+				return reflect.Value{}, fmt.Errorf("missing component %q of %v", name, t)
+			}
+			components = append(components, field)
+		}
+	}
+	if len(components) != len(t.TupleElems) {
+		return reflect.Value{}, fmt.Errorf("%d components for %v", len(components), t)
+	}
+	value := reflect.New(t.Type).Elem()
+	for i, component := range components {
+		v, err := t.TupleElems[i].parseJSON(component)
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("component %d: %v", i, err)
+		}
+		value.Field(i).Set(v)
+	}
+	return value, nil
+}
+
+// parseJSONBytes parses a 0x prefixed hex string.
+func parseJSONBytes(data json.RawMessage) ([]byte, error) {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil || len(s) < 2 || s[:2] != "0x" {
+		return nil, fmt.Errorf("invalid hex bytes %s", data)
+	}
+	b, err := hex.DecodeString(s[2:])
+	if err != nil {
+		return nil, fmt.Errorf("invalid hex bytes %s: %v", data, err)
+	}
+	return b, nil
+}
@@ -0,0 +1,108 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package abi
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ellaism/go-ellaism/common"
+)
+
+const constructorDefinition = `[
+	{ "type" : "constructor", "inputs" : [ { "name" : "supply", "type" : "uint256" }, { "name" : "owner", "type" : "address" }, { "name" : "name", "type" : "string" }, { "name" : "salt", "type" : "bytes32" }, { "name" : "decimals", "type" : "uint8" }, { "name" : "limits", "type" : "int64[2]" }, { "name" : "paused", "type" : "bool" } ] }
+]`
+
+// jsonArgs splits a JSON array into its raw elements.
+func jsonArgs(t *testing.T, args string) []json.RawMessage {
+	var raw []json.RawMessage
+	if err := json.Unmarshal([]byte(args), &raw); err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func TestPackJSON(t *testing.T) {
+	abi, err := JSON(strings.NewReader(constructorDefinition))
+	if err != nil {
+		t.Fatal(err)
+	}
+	owner := common.HexToAddress("0x00000000000000000000000000000000000000aa")
+	want, err := abi.Pack("", big.NewInt(1000000), owner, "Token", [32]byte{0x01}, uint8(18), [2]int64{-1, 1}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	have, err := abi.PackJSON("", jsonArgs(t, `[1000000, "0x00000000000000000000000000000000000000AA", "Token", "0x0100000000000000000000000000000000000000000000000000000000000000", "0x12", ["-1", 1], true]`))
+	if err != nil {
+		t.Fatalf("failed to pack: %v", err)
+	}
+	if !bytes.Equal(have, want) {
+		t.Errorf("packed constructor mismatch:\nhave %x\nwant %x", have, want)
+	}
+	const (
+		ownerArg = `"0x00000000000000000000000000000000000000aa"`
+		saltArg  = `"0x0100000000000000000000000000000000000000000000000000000000000000"`
+	)
+	for _, test := range []struct {
+		name, args string
+	}{
+		{"short address", `[1, "0xaa", "Token", ` + saltArg + `, 18, [-1, 1], true]`},
+		{"negative uint", `[-1, ` + ownerArg + `, "Token", ` + saltArg + `, 18, [-1, 1], true]`},
+		{"short bytes32", `[1, ` + ownerArg + `, "Token", "0x01", 18, [-1, 1], true]`},
+		{"uint8 overflow", `[1, ` + ownerArg + `, "Token", ` + saltArg + `, 256, [-1, 1], true]`},
+		{"short array", `[1, ` + ownerArg + `, "Token", ` + saltArg + `, 18, [-1], true]`},
+		{"missing arguments", `[1, ` + ownerArg + `, "Token"]`},
+	} {
+		if _, err := abi.PackJSON("", jsonArgs(t, test.args)); err == nil {
+			t.Errorf("%s: invalid arguments packed", test.name)
+		}
+	}
+}
+
+func TestTuplePackJSON(t *testing.T) {
+	abi, err := JSON(strings.NewReader(tupleDefinition))
+	if err != nil {
+		t.Fatal(err)
+	}
+	type record struct {
+		Id     *big.Int
+		Label  string
+		Owners []common.Address
+	}
+	want, err := abi.Pack("set", record{Id: big.NewInt(1), Label: "a", Owners: []common.Address{{1}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range []string{
+		`[{"id": 1, "label": "a", "owners": ["0x0100000000000000000000000000000000000000"]}]`,
+		`[["0x1", "a", ["0x0100000000000000000000000000000000000000"]]]`,
+	} {
+		have, err := abi.PackJSON("set", jsonArgs(t, args))
+		if err != nil {
+			t.Fatalf("%s: failed to pack: %v", args, err)
+		}
+		if !bytes.Equal(have, want) {
+			t.Errorf("%s: packed tuple mismatch:\nhave %x\nwant %x", args, have, want)
+		}
+	}
+	if _, err := abi.PackJSON("set", jsonArgs(t, `[{"id": 1, "label": "a"}]`)); err == nil {
+		t.Errorf("tuple with missing component packed")
+	}
+}
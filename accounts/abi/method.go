@@ -44,36 +44,19 @@ func (m Method) pack(method Method, args ...interface{}) ([]byte, error) {
 	if len(args) != len(method.Inputs) {
 		return nil, fmt.Errorf("argument count mismatch: %d for %d", len(args), len(method.Inputs))
 	}
-	// variable input is the output appended at the end of packed
-	// output. This is used for strings and bytes types input.
-	var variableInput []byte
-
-	var ret []byte
+	var (
+		types  = make([]*Type, len(args))
+		values = make([]reflect.Value, len(args))
+	)
 	for i, a := range args {
-		input := method.Inputs[i]
-		// pack the input
-		packed, err := input.Type.pack(reflect.ValueOf(a))
-		if err != nil {
-			return nil, fmt.Errorf("`%s` %v", method.Name, err)
-		}
-
-		// check for a slice type (string, bytes, slice)
-		if input.Type.requiresLengthPrefix() {
-			// calculate the offset
-			offset := len(method.Inputs)*32 + len(variableInput)
-			// set the offset
-			ret = append(ret, packNum(reflect.ValueOf(offset))...)
-			// Append the packed output to the variable input. The variable input
-			// will be appended at the end of the input.
-			variableInput = append(variableInput, packed...)
-		} else {
-			// append the packed value to the input
-			ret = append(ret, packed...)
-		}
+		types[i], values[i] = &method.Inputs[i].Type, reflect.ValueOf(a)
+	}
+	// static inputs are packed in place, the dynamic ones (strings, bytes,
+	// slices) are appended after them, referenced by their offsets.
+	ret, err := packSequence(types, values)
+	if err != nil {
+		return nil, fmt.Errorf("`%s` %v", method.Name, err)
 	}
-	// append the variable input at the end of the packed input
-	ret = append(ret, variableInput...)
-
 	return ret, nil
 }
 
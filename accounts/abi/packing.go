@@ -64,3 +64,26 @@ func packElement(t Type, reflectValue reflect.Value) []byte {
 	}
 	panic("abi: fatal error")
 }
+
+// packSequence packs the values as a sequence of the given types, the static
+// ones in place and the dynamic ones after the heads, referenced by offset.
+func packSequence(types []*Type, values []reflect.Value) ([]byte, error) {
+	var size int
+	for _, t := range types {
+		size += t.headSize()
+	}
+	var head, tail []byte
+	for i, t := range types {
+		packed, err := t.pack(values[i])
+		if err != nil {
+			return nil, err
+		}
+		if t.isDynamic() {
+			head = append(head, packNum(reflect.ValueOf(size+len(tail)))...)
+			tail = append(tail, packed...)
+		} else {
+			head = append(head, packed...)
+		}
+	}
+	return append(head, tail...), nil
+}
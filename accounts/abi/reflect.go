@@ -86,6 +86,29 @@ func set(dst, src reflect.Value, output Argument) error {
 			return fmt.Errorf("abi: cannot unmarshal src (len=%d) in to dst (len=%d)", output.Type.SliceSize, dst.Len())
 		}
 		reflect.Copy(dst, src)
+	case dstType.Kind() == reflect.Struct && srcType.Kind() == reflect.Struct:
+		// tuples are unpacked into any struct with matching field names
+		for i := 0; i < srcType.NumField(); i++ {
+			name := srcType.Field(i).Name
+			field := dst.FieldByName(name)
+			if !field.IsValid() {
+				return fmt.Errorf("abi: cannot unmarshal %v in to %v: missing field %s", srcType, dstType, name)
+			}
+			if err := set(field, src.Field(i), output); err != nil {
+				return err
+			}
+		}
+	case (dstType.Kind() == reflect.Slice || dstType.Kind() == reflect.Array) && srcType.Kind() == dstType.Kind() && srcType.Elem().Kind() == reflect.Struct:
+		if dstType.Kind() == reflect.Slice {
+			dst.Set(reflect.MakeSlice(dstType, src.Len(), src.Len()))
+		} else if dst.Len() != src.Len() {
+			return fmt.Errorf("abi: cannot unmarshal %v in to %v", srcType, dstType)
+		}
+		for i := 0; i < src.Len(); i++ {
+			if err := set(dst.Index(i), src.Index(i), output); err != nil {
+				return err
+			}
+		}
 	case dstType.Kind() == reflect.Interface:
 		dst.Set(src)
 	case dstType.Kind() == reflect.Ptr:
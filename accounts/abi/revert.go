@@ -0,0 +1,131 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package abi
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ellaism/go-ellaism/crypto"
+)
+
+var (
+	// revertSelector is the selector of Error(string), which Solidity encodes
+	// the reasons of require and revert with.
+	revertSelector = crypto.Keccak256([]byte("Error(string)"))[:4]
+	// panicSelector is the selector of Panic(uint256), which Solidity encodes
+	// failed assertions and other runtime errors with.
+	panicSelector = crypto.Keccak256([]byte("Panic(uint256)"))[:4]
+
+	errInvalidRevert = errors.New("abi: output is not a revert reason")
+)
+
+// panicReasons are the descriptions of the Solidity panic codes.
+var panicReasons = map[uint64]string{
+	0x00: "generic panic",
+	0x01: "assert(false)",
+	0x11: "arithmetic underflow or overflow",
+	0x12: "division or modulo by zero",
+	0x21: "enum overflow",
+	0x22: "invalid encoded storage byte array accessed",
+	0x31: "out-of-bounds array access; popping on an empty array",
+	0x32: "out-of-bounds access of an array or bytesN",
+	0x41: "out of memory",
+	0x51: "uninitialized function",
+}
+
+// UnpackRevert decodes the output of a reverted execution into the reason
+// it was reverted with, either an Error(string) reason or the description of
+// a Panic(uint256) code.
+func UnpackRevert(data []byte) (string, error) {
+	if len(data) < 4 {
+		return "", errInvalidRevert
+	}
+	switch selector, args := data[:4], data[4:]; {
+	case bytes.Equal(selector, revertSelector):
+		if len(args) < 64 {
+			return "", errInvalidRevert
+		}
+		offset := new(big.Int).SetBytes(args[:32])
+		if !offset.IsUint64() || offset.Uint64() > uint64(len(args)-32) {
+			return "", errInvalidRevert
+		}
+		start := offset.Uint64() + 32
+		size := new(big.Int).SetBytes(args[start-32 : start])
+		if !size.IsUint64() || size.Uint64() > uint64(len(args))-start {
+			return "", errInvalidRevert
+		}
+		return string(args[start : start+size.Uint64()]), nil
+
+	case bytes.Equal(selector, panicSelector):
+		if len(args) != 32 {
+			return "", errInvalidRevert
+		}
+		code := new(big.Int).SetBytes(args)
+		if code.IsUint64() {
+			if reason, ok := panicReasons[code.Uint64()]; ok {
+				return reason, nil
+			}
+		}
+		return fmt.Sprintf("unknown panic code: %#x", code), nil
+	}
+	return "", errInvalidRevert
+}
+
+// Error is a custom error declared by a contract, which reverted executions
+// return encoded the same way as a call to a method of the same signature.
+type Error struct {
+	Name   string
+	Inputs []Argument
+}
+
+// Sig returns the error's signature, e.g. InsufficientBalance(uint256,uint256).
+func (e Error) Sig() string {
+	types := make([]string, len(e.Inputs))
+	for i, input := range e.Inputs {
+		types[i] = input.Type.String()
+	}
+	return fmt.Sprintf("%v(%v)", e.Name, strings.Join(types, ","))
+}
+
+// Id returns the selector the error is prefixed with in revert data.
+func (e Error) Id() []byte {
+	return crypto.Keccak256([]byte(e.Sig()))[:4]
+}
+
+func (e Error) String() string {
+	inputs := make([]string, len(e.Inputs))
+	for i, input := range e.Inputs {
+		inputs[i] = input.Type.String()
+		if len(input.Name) > 0 {
+			inputs[i] += " " + input.Name
+		}
+	}
+	return fmt.Sprintf("error %v(%v)", e.Name, strings.Join(inputs, ", "))
+}
+
+// Unpack decodes the arguments of the error from the revert data of a failed
+// execution, which must start with the error's selector.
+func (e Error) Unpack(data []byte) ([]interface{}, error) {
+	if len(data) < 4 || !bytes.Equal(data[:4], e.Id()) {
+		return nil, fmt.Errorf("abi: revert data is not a '%s' error", e.Name)
+	}
+	return unpackOutputs(e.Inputs, data[4:])
+}
@@ -0,0 +1,167 @@
+// Copyright 2016 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package abi
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+
+	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/crypto"
+)
+
+// MakeTopics converts a filter query argument list into a filter topic set,
+// one list of accepted topics per indexed event argument.
+func MakeTopics(query ...[]interface{}) ([][]common.Hash, error) {
+	topics := make([][]common.Hash, len(query))
+	for i, filter := range query {
+		for _, rule := range filter {
+			var topic common.Hash
+
+			// Try to generate the topic based on simple types
+			switch rule := rule.(type) {
+			case common.Hash:
+				copy(topic[:], rule[:])
+			case common.Address:
+				copy(topic[common.HashLength-common.AddressLength:], rule[:])
+			case *big.Int:
+				copy(topic[:], U256(rule))
+			case bool:
+				if rule {
+					topic[common.HashLength-1] = 1
+				}
+			case int8:
+				copy(topic[:], U256(big.NewInt(int64(rule))))
+			case int16:
+				copy(topic[:], U256(big.NewInt(int64(rule))))
+			case int32:
+				copy(topic[:], U256(big.NewInt(int64(rule))))
+			case int64:
+				copy(topic[:], U256(big.NewInt(rule)))
+			case uint8:
+				topic[common.HashLength-1] = rule
+			case uint16:
+				copy(topic[:], U256(new(big.Int).SetUint64(uint64(rule))))
+			case uint32:
+				copy(topic[:], U256(new(big.Int).SetUint64(uint64(rule))))
+			case uint64:
+				copy(topic[:], U256(new(big.Int).SetUint64(rule)))
+			case string:
+				topic = crypto.Keccak256Hash([]byte(rule))
+			case []byte:
+				topic = crypto.Keccak256Hash(rule)
+
+			default:
+				// Attempt to generate the topic from fixed size byte arrays
+				val := reflect.ValueOf(rule)
+				if val.Kind() != reflect.Array || val.Type().Elem().Kind() != reflect.Uint8 || val.Len() > common.HashLength {
+					return nil, fmt.Errorf("abi: unsupported indexed type: %T", rule)
+				}
+				reflect.Copy(reflect.ValueOf(topic[:val.Len()]), val)
+			}
+			topics[i] = append(topics[i], topic)
+		}
+	}
+	return topics, nil
+}
+
+// errTopicsMismatch is returned if a log doesn't carry a topic for every indexed
+// field of the event it is unpacked into.
+var errTopicsMismatch = errors.New("abi: topic/field count mismatch")
+
+// ParseTopics converts the indexed topic fields of a log into the fields of out,
+// matched by their capitalised names.
+func ParseTopics(out interface{}, fields []Argument, topics []common.Hash) error {
+	if len(fields) != len(topics) {
+		return errTopicsMismatch
+	}
+	value := reflect.ValueOf(out)
+	if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("abi: cannot unpack topics into %T", out)
+	}
+	value = value.Elem()
+
+	for i, arg := range fields {
+		if arg.Name == "" {
+			return fmt.Errorf("abi: cannot unpack unnamed indexed argument %d", i)
+		}
+		field := value.FieldByName(strings.ToUpper(arg.Name[:1]) + arg.Name[1:])
+		if !field.IsValid() {
+			return fmt.Errorf("abi: missing field for indexed argument %q", arg.Name)
+		}
+		topic := topics[i]
+
+		// Dynamic types are only available as the hash of their content
+		if arg.Type.HashedInTopics() {
+			if field.Type() != reflect.TypeOf(common.Hash{}) {
+				return fmt.Errorf("abi: cannot unpack hashed topic into %v", field.Type())
+			}
+			field.Set(reflect.ValueOf(topic))
+			continue
+		}
+		switch arg.Type.T {
+		case BoolTy:
+			if field.Kind() != reflect.Bool {
+				return fmt.Errorf("abi: cannot unpack bool topic into %v", field.Type())
+			}
+			field.SetBool(topic[common.HashLength-1] != 0)
+
+		case IntTy, UintTy:
+			num := new(big.Int).SetBytes(topic[:])
+			if arg.Type.T == IntTy && topic[0]&0x80 != 0 {
+				num.Sub(num, new(big.Int).Lsh(common.Big1, 256))
+			}
+			switch field.Kind() {
+			case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+				field.SetInt(num.Int64())
+			case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+				field.SetUint(num.Uint64())
+			default:
+				if field.Type() != reflect.TypeOf(num) {
+					return fmt.Errorf("abi: cannot unpack integer topic into %v", field.Type())
+				}
+				field.Set(reflect.ValueOf(num))
+			}
+
+		case AddressTy:
+			if field.Type() != reflect.TypeOf(common.Address{}) {
+				return fmt.Errorf("abi: cannot unpack address topic into %v", field.Type())
+			}
+			field.Set(reflect.ValueOf(common.BytesToAddress(topic[:])))
+
+		case FixedBytesTy:
+			if field.Kind() != reflect.Array || field.Type().Elem().Kind() != reflect.Uint8 || field.Len() > common.HashLength {
+				return fmt.Errorf("abi: cannot unpack fixed bytes topic into %v", field.Type())
+			}
+			reflect.Copy(field, reflect.ValueOf(topic[:field.Len()]))
+
+		default:
+			return fmt.Errorf("abi: unsupported indexed type: %v", arg.Type)
+		}
+	}
+	return nil
+}
+
+// HashedInTopics returns whether values of the type are stored in the topics of
+// a log as the hash of their encoding rather than in place, as is the case for
+// strings, bytes, arrays and tuples.
+func (t Type) HashedInTopics() bool {
+	return t.T == StringTy || t.T == TupleTy || ((t.IsSlice || t.IsArray) && t.T != FixedBytesTy)
+}
@@ -0,0 +1,72 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package abi
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/crypto"
+)
+
+// Tests that indexed event arguments are converted into topics and back.
+func TestTopicsRoundTrip(t *testing.T) {
+	const definition = `[{ "type" : "event", "name" : "Transfer", "inputs" : [
+		{ "name" : "from", "type" : "address", "indexed" : true },
+		{ "name" : "amount", "type" : "int256", "indexed" : true },
+		{ "name" : "memo", "type" : "string", "indexed" : true },
+		{ "name" : "pair", "type" : "tuple", "indexed" : true, "components" : [ { "name" : "a", "type" : "uint8" }, { "name" : "b", "type" : "uint8" } ] }
+	] }]`
+
+	abi, err := JSON(strings.NewReader(definition))
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := abi.Events["Transfer"]
+	if have, want := event.Id(), crypto.Keccak256Hash([]byte("Transfer(address,int256,string,(uint8,uint8))")); have != want {
+		t.Errorf("event id mismatch: have %x, want %x", have, want)
+	}
+	from := common.HexToAddress("0x01")
+	memo := crypto.Keccak256Hash([]byte("memo"))
+	pair := common.HexToHash("0x02")
+
+	topics, err := MakeTopics([]interface{}{from}, []interface{}{big.NewInt(-1)}, []interface{}{"memo"}, []interface{}{pair})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if topics[0][0] != from.Hash() || topics[2][0] != memo || topics[3][0] != pair {
+		t.Errorf("topics mismatch: have %x", topics)
+	}
+	var out struct {
+		From   common.Address
+		Amount *big.Int
+		Memo   common.Hash
+		Pair   common.Hash
+	}
+	flat := []common.Hash{topics[0][0], topics[1][0], topics[2][0], topics[3][0]}
+	if err := ParseTopics(&out, event.Inputs, flat); err != nil {
+		t.Fatal(err)
+	}
+	if out.From != from || out.Amount.Cmp(big.NewInt(-1)) != 0 || out.Memo != memo || out.Pair != pair {
+		t.Errorf("parsed topics mismatch: have %+v", out)
+	}
+	if err := ParseTopics(&out, event.Inputs, flat[:2]); err != errTopicsMismatch {
+		t.Errorf("topic count mismatch error: have %v, want %v", err, errTopicsMismatch)
+	}
+}
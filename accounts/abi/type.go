@@ -21,6 +21,10 @@ import (
 	"reflect"
 	"regexp"
 	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/ellaism/go-ellaism/common"
 )
 
 const (
@@ -34,6 +38,7 @@ const (
 	BytesTy
 	HashTy
 	RealTy
+	TupleTy
 )
 
 // Type is the reflection of the supported argument type
@@ -48,6 +53,9 @@ type Type struct {
 	Size int
 	T    byte // Our own type checking
 
+	TupleElems    []*Type  // Types of the tuple components
+	TupleRawNames []string // Names of the tuple components as declared in the ABI
+
 	stringKind string // holds the unparsed string for deriving signatures
 }
 
@@ -70,7 +78,13 @@ var (
 )
 
 // NewType creates a new reflection type of abi type given in t.
-func NewType(t string) (typ Type, err error) {
+func NewType(t string) (Type, error) {
+	return newType(t, nil)
+}
+
+// newType creates a new reflection type of abi type given in t, assembling
+// tuples out of the given components.
+func newType(t string, components []ArgumentMarshaling) (typ Type, err error) {
 	res := fullTypeRegex.FindAllStringSubmatch(t, -1)[0]
 	// check if type is slice and parse type.
 	switch {
@@ -84,7 +98,7 @@ func NewType(t string) (typ Type, err error) {
 		return Type{}, fmt.Errorf("abi: type parse error: %s", t)
 	}
 	if typ.IsArray || typ.IsSlice {
-		sliceType, err := NewType(res[1])
+		sliceType, err := newType(res[1], components)
 		if err != nil {
 			return Type{}, err
 		}
@@ -148,6 +162,30 @@ func NewType(t string) (typ Type, err error) {
 			typ.T = FixedBytesTy
 			typ.SliceSize = varSize
 		}
+	case "tuple":
+		if len(components) == 0 {
+			return Type{}, fmt.Errorf("abi: tuple without components: %s", t)
+		}
+		var (
+			fields = make([]reflect.StructField, len(components))
+			kinds  = make([]string, len(components))
+			taken  = make(map[string]bool)
+		)
+		for i, component := range components {
+			elem, err := newType(component.Type, component.Components)
+			if err != nil {
+				return Type{}, err
+			}
+			typ.TupleElems = append(typ.TupleElems, &elem)
+			typ.TupleRawNames = append(typ.TupleRawNames, component.Name)
+
+			fields[i] = reflect.StructField{Name: fieldName(component.Name, i, taken), Type: elem.goType()}
+			kinds[i] = elem.stringKind
+		}
+		typ.Kind = reflect.Struct
+		typ.Type = reflect.StructOf(fields)
+		typ.T = TupleTy
+		typ.stringKind = "(" + strings.Join(kinds, ",") + ")"
 	default:
 		return Type{}, fmt.Errorf("unsupported arg type: %s", t)
 	}
@@ -169,15 +207,28 @@ func (t Type) pack(v reflect.Value) ([]byte, error) {
 	}
 
 	if (t.IsSlice || t.IsArray) && t.T != BytesTy && t.T != FixedBytesTy {
-		var packed []byte
+		var (
+			types  = make([]*Type, v.Len())
+			values = make([]reflect.Value, v.Len())
+		)
 		for i := 0; i < v.Len(); i++ {
-			val, err := t.Elem.pack(v.Index(i))
-			if err != nil {
-				return nil, err
+			types[i], values[i] = t.Elem, v.Index(i)
+		}
+		packed, err := packSequence(types, values)
+		if err != nil {
+			return nil, err
+		}
+		return append(packNum(reflect.ValueOf(v.Len())), packed...), nil
+	}
+	if t.T == TupleTy {
+		values := make([]reflect.Value, len(t.TupleElems))
+		for i := range t.TupleElems {
+			name := t.Type.Field(i).Name
+			if values[i] = v.FieldByName(name); !values[i].IsValid() {
+				return nil, fmt.Errorf("abi: missing field %s in %v for tuple component %d", name, v.Type(), i)
 			}
-			packed = append(packed, val...)
 		}
-		return packBytesSlice(packed, v.Len()), nil
+		return packSequence(t.TupleElems, values)
 	}
 
 	return packElement(t, v), nil
@@ -188,3 +239,72 @@ func (t Type) pack(v reflect.Value) ([]byte, error) {
 func (t Type) requiresLengthPrefix() bool {
 	return t.T != FixedBytesTy && (t.T == StringTy || t.T == BytesTy || t.IsSlice || t.IsArray)
 }
+
+// isDynamic returns whether the type is encoded out of place, referenced by an
+// offset from the head of its enclosing sequence.
+func (t Type) isDynamic() bool {
+	if t.T == TupleTy {
+		for _, elem := range t.TupleElems {
+			if elem.isDynamic() {
+				return true
+			}
+		}
+		return false
+	}
+	return t.requiresLengthPrefix()
+}
+
+// headSize returns the number of bytes the type takes up in the head of its
+// enclosing sequence: static tuples are encoded in place, everything else in
+// a single word.
+func (t Type) headSize() int {
+	if t.T == TupleTy && !t.isDynamic() {
+		size := 0
+		for _, elem := range t.TupleElems {
+			size += elem.headSize()
+		}
+		return size
+	}
+	return 32
+}
+
+// goType returns the Go type values of the abi type are unpacked into when
+// nested within tuples, matching the types the binding generator emits.
+func (t Type) goType() reflect.Type {
+	switch {
+	case t.T == FixedBytesTy:
+		return reflect.ArrayOf(t.SliceSize, r_byte)
+	case t.T == BytesTy:
+		return reflect.SliceOf(r_byte)
+	case t.IsArray:
+		return reflect.ArrayOf(t.SliceSize, t.Elem.goType())
+	case t.IsSlice:
+		return reflect.SliceOf(t.Elem.goType())
+	}
+	switch t.T {
+	case IntTy, UintTy:
+		return reflect.TypeOf(readInteger(t.Kind, nil))
+	case BoolTy:
+		return reflect.TypeOf(false)
+	case StringTy:
+		return reflect.TypeOf("")
+	case AddressTy:
+		return address_t
+	case HashTy:
+		return reflect.TypeOf(common.Hash{})
+	}
+	return t.Type
+}
+
+// fieldName converts the name of a tuple component into the exported name of
+// the Go field it is unpacked into, falling back to a positional name if the
+// component is unnamed or its name is unusable.
+func fieldName(name string, index int, taken map[string]bool) string {
+	name = strings.TrimLeft(name, "_")
+	if name == "" || strings.ContainsAny(name, "$") || !unicode.IsLetter(rune(name[0])) || taken[strings.ToUpper(name[:1])+name[1:]] {
+		name = fmt.Sprintf("Field%d", index)
+	}
+	name = strings.ToUpper(name[:1]) + name[1:]
+	taken[name] = true
+	return name
+}
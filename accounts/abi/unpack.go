@@ -0,0 +1,168 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package abi
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+
+	"github.com/ellaism/go-ellaism/common"
+)
+
+// unpackOutputs unpacks every argument of a sequence into its Go value.
+func unpackOutputs(outputs []Argument, output []byte) ([]interface{}, error) {
+	var (
+		values = make([]interface{}, len(outputs))
+		index  int
+	)
+	for i, arg := range outputs {
+		value, err := toGoType(index, arg, output)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
+		index += arg.Type.headSize()
+	}
+	return values, nil
+}
+
+// readInteger converts a word into the Go integer type of the given kind,
+// *big.Int if the abi type is wider than any native one.
+func readInteger(kind reflect.Kind, word []byte) interface{} {
+	num := new(big.Int).SetBytes(word)
+	switch kind {
+	case reflect.Uint8:
+		return uint8(num.Uint64())
+	case reflect.Uint16:
+		return uint16(num.Uint64())
+	case reflect.Uint32:
+		return uint32(num.Uint64())
+	case reflect.Uint64:
+		return num.Uint64()
+	case reflect.Int8:
+		return int8(num.Int64())
+	case reflect.Int16:
+		return int16(num.Int64())
+	case reflect.Int32:
+		return int32(num.Int64())
+	case reflect.Int64:
+		return num.Int64()
+	}
+	return num
+}
+
+// readWord reads a number from the word at index, making sure it doesn't
+// exceed the length of the output it is used to index into.
+func readWord(output []byte, index int) (int, error) {
+	if index+32 > len(output) {
+		return 0, fmt.Errorf("abi: cannot marshal in to go type: length insufficient %d require %d", len(output), index+32)
+	}
+	num := new(big.Int).SetBytes(output[index : index+32])
+	if !num.IsUint64() || num.Uint64() > uint64(len(output)) {
+		return 0, fmt.Errorf("abi: cannot marshal in to go type: %v would go over slice boundary (len=%d)", num, len(output))
+	}
+	return int(num.Uint64()), nil
+}
+
+// readValue unpacks the value of type t whose head is at index within output,
+// the encoding of its enclosing sequence. Dynamic values are read from the
+// offset stored in their head, relative to the start of the sequence.
+func readValue(t Type, output []byte, index int) (reflect.Value, error) {
+	if t.isDynamic() {
+		offset, err := readWord(output, index)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		output, index = output[offset:], 0
+	}
+	switch {
+	case t.T == StringTy || t.T == BytesTy:
+		size, err := readWord(output, 0)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if 32+size > len(output) {
+			return reflect.Value{}, fmt.Errorf("abi: cannot marshal in to go type: length insufficient %d require %d", len(output), 32+size)
+		}
+		if t.T == StringTy {
+			return reflect.ValueOf(string(output[32 : 32+size])), nil
+		}
+		return reflect.ValueOf(common.CopyBytes(output[32 : 32+size])), nil
+
+	case t.T == FixedBytesTy:
+		if index+32 > len(output) {
+			return reflect.Value{}, fmt.Errorf("abi: cannot marshal in to go type: length insufficient %d require %d", len(output), index+32)
+		}
+		value := reflect.New(t.goType()).Elem()
+		reflect.Copy(value, reflect.ValueOf(output[index:index+t.SliceSize]))
+		return value, nil
+
+	case t.IsSlice || t.IsArray:
+		size, err := readWord(output, 0)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if t.IsArray && size != t.SliceSize {
+			return reflect.Value{}, fmt.Errorf("abi: cannot marshal %d elements in to %v", size, t)
+		}
+		elems := output[32:]
+		if size*t.Elem.headSize() > len(elems) {
+			return reflect.Value{}, fmt.Errorf("abi: cannot marshal in to go slice: insufficient size output %d require %d", len(output), 32+size*t.Elem.headSize())
+		}
+		value := reflect.New(t.goType()).Elem()
+		if t.IsSlice {
+			value = reflect.MakeSlice(t.goType(), size, size)
+		}
+		for i := 0; i < size; i++ {
+			elem, err := readValue(*t.Elem, elems, i*t.Elem.headSize())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			value.Index(i).Set(elem)
+		}
+		return value, nil
+
+	case t.T == TupleTy:
+		value := reflect.New(t.Type).Elem()
+		for i, elem := range t.TupleElems {
+			field, err := readValue(*elem, output, index)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			value.Field(i).Set(field)
+			index += elem.headSize()
+		}
+		return value, nil
+	}
+	if index+32 > len(output) {
+		return reflect.Value{}, fmt.Errorf("abi: cannot marshal in to go type: length insufficient %d require %d", len(output), index+32)
+	}
+	word := output[index : index+32]
+
+	switch t.T {
+	case IntTy, UintTy:
+		return reflect.ValueOf(readInteger(t.Kind, word)), nil
+	case BoolTy:
+		return reflect.ValueOf(new(big.Int).SetBytes(word).Uint64() > 0), nil
+	case AddressTy:
+		return reflect.ValueOf(common.BytesToAddress(word)), nil
+	case HashTy:
+		return reflect.ValueOf(common.BytesToHash(word)), nil
+	}
+	return reflect.Value{}, fmt.Errorf("abi: unknown type %v", t.T)
+}
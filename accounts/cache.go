@@ -163,22 +163,30 @@ func (ac *addrCache) find(a Account) (Account, error) {
 
 func (ac *addrCache) maybeReload() {
 	ac.mu.Lock()
-	defer ac.mu.Unlock()
 	if ac.watcher.running {
+		ac.mu.Unlock()
 		return // A watcher is running and will keep the cache up-to-date.
 	}
-	if ac.throttle == nil {
+	initial := ac.throttle == nil
+	if initial {
 		ac.throttle = time.NewTimer(0)
 	} else {
 		select {
 		case <-ac.throttle.C:
 		default:
+			ac.mu.Unlock()
 			return // The cache was reloaded recently.
 		}
 	}
 	ac.watcher.start()
-	ac.reload()
+	added, removed := ac.reload()
 	ac.throttle.Reset(minReloadInterval)
+	ac.mu.Unlock()
+
+	// Without a running watcher, changes are only picked up here.
+	if !initial && ac.watcher.onChange != nil && len(added)+len(removed) > 0 {
+		ac.watcher.onChange(added, removed)
+	}
 }
 
 func (ac *addrCache) close() {
@@ -190,13 +198,15 @@ func (ac *addrCache) close() {
 	ac.mu.Unlock()
 }
 
-// reload caches addresses of existing accounts.
+// reload caches addresses of existing accounts, returning the ones added and
+// removed since the previous reload.
 // Callers must hold ac.mu.
-func (ac *addrCache) reload() {
+func (ac *addrCache) reload() (added, removed []Account) {
 	accounts, err := ac.scan()
 	if err != nil && glog.V(logger.Debug) {
 		glog.Errorf("can't load keys: %v", err)
 	}
+	added, removed = diffAccounts(ac.all, accounts)
 	ac.all = accounts
 	sort.Sort(ac.all)
 	for k := range ac.byAddr {
@@ -206,6 +216,7 @@ func (ac *addrCache) reload() {
 		ac.byAddr[a.Address] = append(ac.byAddr[a.Address], a)
 	}
 	glog.V(logger.Debug).Infof("reloaded keys, cache has %d accounts", len(ac.all))
+	return added, removed
 }
 
 func (ac *addrCache) scan() ([]Account, error) {
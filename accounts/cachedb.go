@@ -17,7 +17,6 @@
 package accounts
 
 import (
-	"bufio"
 	"encoding/json"
 	"io/ioutil"
 	"os"
@@ -49,6 +48,7 @@ type cacheDB struct {
 	mu       sync.Mutex
 	throttle *time.Timer
 	db       *bolt.DB
+	closed   bool // Set once the db is closed, stopping late reloads
 }
 
 func newCacheDB(keydir string) *cacheDB {
@@ -66,6 +66,7 @@ func newCacheDB(keydir string) *cacheDB {
 		db: bdb,
 	}
 	cdb.keydir = keydir
+	cdb.watcher = newWatcher(cdb)
 
 	if e := cdb.db.Update(func(tx *bolt.Tx) error {
 		if _, e := tx.CreateBucketIfNotExists(addrBucketName); e != nil {
@@ -106,17 +107,93 @@ func (cdb *cacheDB) getThrottle() *time.Timer {
 	return cdb.throttle
 }
 
+// maybeReload starts watching the key directory, syncing the index with the
+// files added and removed since the last time. Without a running watcher, the
+// directory is rescanned at most once every minReloadInterval.
 func (cdb *cacheDB) maybeReload() {
-	// do nothing (implements caching interface)
+	cdb.mu.Lock()
+	if cdb.watcher.running || cdb.closed {
+		cdb.mu.Unlock()
+		return // A watcher is running and will keep the index up-to-date.
+	}
+	if cdb.throttle == nil {
+		cdb.throttle = time.NewTimer(0)
+	} else {
+		select {
+		case <-cdb.throttle.C:
+		default:
+			cdb.mu.Unlock()
+			return // The index was synced recently.
+		}
+	}
+	cdb.watcher.start()
+	added, removed := cdb.reload()
+	cdb.throttle.Reset(minReloadInterval)
+	cdb.mu.Unlock()
+
+	if cdb.watcher.onChange != nil && len(added)+len(removed) > 0 {
+		cdb.watcher.onChange(added, removed)
+	}
 }
 
-func (cdb *cacheDB) reload() {
-	// do nothing (implements caching interface)
+// reload syncs the index with the key files of the directory: files not yet
+// indexed are read and added, and entries whose file is gone are dropped. Only
+// the new files are decoded, so the sync stays cheap for large key stores.
+// Callers must hold cdb.mu.
+func (cdb *cacheDB) reload() (added, removed []Account) {
+	if cdb.closed {
+		return nil, nil
+	}
+	files, err := ioutil.ReadDir(cdb.keydir)
+	if err != nil {
+		glog.V(logger.Debug).Infof("can't load keys: %v", err)
+		return nil, nil
+	}
+	indexed := make(map[string]Account)
+	for _, a := range cdb.indexed() {
+		indexed[a.File] = a
+	}
+	for _, fi := range files {
+		if skipKeyFile(fi) {
+			continue
+		}
+		if _, ok := indexed[fi.Name()]; ok {
+			delete(indexed, fi.Name())
+			continue
+		}
+		a, err := readKeyFile(filepath.Join(cdb.keydir, fi.Name()))
+		if err != nil {
+			glog.V(logger.Debug).Infof("can't decode key %s: %v", fi.Name(), err)
+			continue
+		}
+		added = append(added, a)
+	}
+	for _, a := range indexed {
+		removed = append(removed, a)
+	}
+	sort.Sort(accountsByFile(removed))
+
+	for _, err := range cdb.setBatchAccounts(added) {
+		glog.V(logger.Error).Infof("failed to index key file: %v", err)
+	}
+	for _, a := range removed {
+		cdb.delete(a)
+	}
+	if len(added)+len(removed) > 0 {
+		glog.V(logger.Debug).Infof("synced key index, %d added, %d removed", len(added), len(removed))
+	}
+	return added, removed
 }
 
 // Gets all accounts _byFile_, which contains and possibly exceed byAddr content
 // because it may contain dupe address/key pairs (given dupe files)
 func (cdb *cacheDB) accounts() []Account {
+	cdb.maybeReload()
+	return cdb.indexed()
+}
+
+// indexed returns all accounts of the index, sorted by file.
+func (cdb *cacheDB) indexed() []Account {
 	var as []Account
 	if e := cdb.db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket(fileBucketName)
@@ -177,6 +254,7 @@ func (cdb *cacheDB) getCachedAccountByFile(file string) (account Account, err er
 }
 
 func (cdb *cacheDB) hasAddress(addr common.Address) bool {
+	cdb.maybeReload()
 	as, e := cdb.getCachedAccountsByAddress(addr)
 	return e == nil && len(as) > 0
 }
@@ -258,7 +336,12 @@ func (cdb *cacheDB) find(a Account) (Account, error) {
 
 func (cdb *cacheDB) close() {
 	cdb.mu.Lock()
+	cdb.watcher.close()
+	if cdb.throttle != nil {
+		cdb.throttle.Stop()
+	}
 	cdb.db.Close()
+	cdb.closed = true
 	cdb.mu.Unlock()
 }
 
@@ -470,40 +553,33 @@ func processKeyFile(wg *sync.WaitGroup, path string, fi os.FileInfo, i int, numF
 
 		glog.V(logger.Debug).Infof("(%v/%v) Adding key file to db: %v", i, numFiles, fi.Name())
 
-		keyJSON := struct {
-			Address common.Address `json:"address"`
-		}{}
-
-		buf := new(bufio.Reader)
-		fd, err := os.Open(path)
-		if err != nil {
-			errs <- err
-			return
-		}
-		buf.Reset(fd)
-		// Parse the address.
-		keyJSON.Address = common.Address{}
-		err = json.NewDecoder(buf).Decode(&keyJSON)
-		fd.Close()
-
-		web3JSON := []byte{}
-		web3JSON, err = ioutil.ReadFile(path)
+		a, err := readKeyFile(path)
 		if err != nil {
-			errs <- err
+			glog.V(logger.Debug).Infof("(%v/%v) %v", i, numFiles, err)
+			errs <- fmt.Errorf("(%v/%v) %v", i, numFiles, err)
 			return
 		}
+		aChan <- a
+	}
+}
 
-		switch {
-		case err != nil:
-			glog.V(logger.Debug).Infof("(%v/%v) can't decode key %s: %v", i, numFiles, path, err)
-			errs <- err
-		case (keyJSON.Address == common.Address{}):
-			glog.V(logger.Debug).Infof("(%v/%v) can't decode key %s: missing or zero address", i, numFiles, path)
-			errs <- fmt.Errorf("(%v/%v) can't decode key %s: missing or zero address", i, numFiles, path)
-		default:
-			aChan <- Account{Address: keyJSON.Address, File: fi.Name(), EncryptedKey: string(web3JSON)}
-		}
+// readKeyFile reads the key file at path into an index entry, keyed by the
+// base name of the file.
+func readKeyFile(path string) (Account, error) {
+	web3JSON, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Account{}, err
+	}
+	var keyJSON struct {
+		Address common.Address `json:"address"`
+	}
+	if err := json.Unmarshal(web3JSON, &keyJSON); err != nil {
+		return Account{}, fmt.Errorf("can't decode key %s: %v", path, err)
+	}
+	if (keyJSON.Address == common.Address{}) {
+		return Account{}, fmt.Errorf("can't decode key %s: missing or zero address", path)
 	}
+	return Account{Address: keyJSON.Address, File: filepath.Base(path), EncryptedKey: string(web3JSON)}, nil
 }
 
 func bytesToAccount(bs []byte) Account {
@@ -48,6 +48,7 @@ func TestCacheInitialReload_CacheDB(t *testing.T) {
 func TestCacheAddDeleteOrder_CacheDB(t *testing.T) {
 	cache := newCacheDB("testdata/no-such-dir")
 	defer cache.close()
+	cache.watcher.running = true // prevent unexpected reloads
 	defer os.RemoveAll("testdata/no-such-dir")
 
 	accounts := []Account{
@@ -133,6 +134,7 @@ func TestCacheAddDeleteOrder_CacheDB(t *testing.T) {
 func TestCacheFind_CacheFind(t *testing.T) {
 	dir := filepath.Join("testdata", "dir")
 	cache := newCacheDB(dir)
+	cache.watcher.running = true // prevent unexpected reloads
 	defer cache.close()
 	defer os.RemoveAll(dir)
 
@@ -304,3 +306,82 @@ func TestCacheDBFilePath(t *testing.T) {
 		}
 	}
 }
+
+// Tests that key files copied into or removed from the directory of a running
+// manager are picked up by the index, and reported to wallet event subscribers.
+func TestCacheDB_WatchAddRemove(t *testing.T) {
+	t.Parallel()
+
+	tmpDir, e := ioutil.TempDir("", "cachedb-watch-test")
+	if e != nil {
+		t.Fatalf("create temp dir: %v", e)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	ma, e := NewManager(tmpDir, veryLightScryptN, veryLightScryptP, true)
+	if e != nil {
+		t.Fatalf("create manager in temp dir: %v", e)
+	}
+	defer ma.ac.close()
+
+	sub := ma.Subscribe()
+	defer sub.Unsubscribe()
+	events := make(chan WalletEvent, len(cachedbtestAccounts)+1)
+	go func() {
+		for ev := range sub.Chan() {
+			events <- ev.Data.(WalletEvent)
+		}
+	}()
+
+	// Start the watcher, then copy the key files in.
+	if accs := ma.Accounts(); len(accs) != 0 {
+		t.Fatalf("initial account list not empty: %v", accs)
+	}
+	for _, acc := range cachedbtestAccounts {
+		data, err := ioutil.ReadFile(filepath.Join(cachetestDir, acc.File))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(tmpDir, acc.File), data, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitAccounts := func(want []Account) {
+		var got []Account
+		for d := 200 * time.Millisecond; d < 8*time.Second; d *= 2 {
+			if got = ma.Accounts(); reflect.DeepEqual(got, want) {
+				return
+			}
+			time.Sleep(d)
+		}
+		t.Fatalf("got %v, want: %v", spew.Sdump(got), spew.Sdump(want))
+	}
+	waitAccounts(cachedbtestAccounts)
+
+	created := make(map[common.Address]bool)
+	for range cachedbtestAccounts {
+		select {
+		case ev := <-events:
+			if ev.Type != AccountCreated {
+				t.Errorf("event type mismatch: have %v, want %v", ev.Type, AccountCreated)
+			}
+			created[ev.Account.Address] = true
+		case <-time.After(time.Second):
+			t.Fatalf("creation events missing: have %d, want %d", len(created), len(cachedbtestAccounts))
+		}
+	}
+	// Remove a key file and check it's dropped from the index.
+	if err := os.Remove(filepath.Join(tmpDir, cachedbtestAccounts[0].File)); err != nil {
+		t.Fatal(err)
+	}
+	waitAccounts(cachedbtestAccounts[1:])
+
+	select {
+	case ev := <-events:
+		if ev.Type != AccountDeleted || ev.Account.Address != cachedbtestAccounts[0].Address {
+			t.Errorf("event mismatch: have %v for %x, want %v for %x", ev.Type, ev.Account.Address, AccountDeleted, cachedbtestAccounts[0].Address)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("deletion event missing")
+	}
+}
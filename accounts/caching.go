@@ -63,7 +63,7 @@ type caching interface {
 	getThrottle() *time.Timer
 
 	maybeReload()
-	reload()
+	reload() (added, removed []Account)
 	Syncfs2db(time.Time) []error
 
 	hasAddress(address common.Address) bool
@@ -75,12 +75,34 @@ type caching interface {
 	close()
 }
 
+// diffAccounts returns the accounts of next missing from prev, and the ones of
+// prev missing from next.
+func diffAccounts(prev, next []Account) (added, removed []Account) {
+	known := make(map[Account]bool, len(prev))
+	for _, a := range prev {
+		known[a] = true
+	}
+	for _, a := range next {
+		if known[a] {
+			delete(known, a)
+			continue
+		}
+		added = append(added, a)
+	}
+	for _, a := range prev {
+		if known[a] {
+			removed = append(removed, a)
+		}
+	}
+	return added, removed
+}
+
 func skipKeyFile(fi os.FileInfo) bool {
 	// Skip editor backups and UNIX-style hidden files.
 	if strings.HasSuffix(fi.Name(), "~") || strings.HasPrefix(fi.Name(), ".") {
 		return true
 	}
-	if strings.HasSuffix(fi.Name(), "accounts.db") {
+	if strings.HasSuffix(fi.Name(), "accounts.db") || fi.Name() == "smartcards.json" {
 		return true
 	}
 	// Skip misc special files, directories (yes, symlinks too).
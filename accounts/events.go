@@ -0,0 +1,74 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package accounts
+
+import "fmt"
+
+// WalletEventType is the kind of change reported by a WalletEvent.
+type WalletEventType int
+
+const (
+	// AccountCreated is posted when a key is created or imported into the keystore.
+	AccountCreated WalletEventType = iota
+
+	// AccountDeleted is posted when a key is removed from the keystore.
+	AccountDeleted
+
+	// AccountUnlocked is posted when a key is unlocked, or its unlock renewed.
+	AccountUnlocked
+
+	// AccountLocked is posted when an unlocked key is locked again, explicitly
+	// or because its unlock expired.
+	AccountLocked
+
+	// WalletArrived is posted by hardware wallet backends when a device is
+	// plugged in.
+	WalletArrived
+
+	// WalletDropped is posted by hardware wallet backends when a device is
+	// unplugged.
+	WalletDropped
+)
+
+var walletEventNames = map[WalletEventType]string{
+	AccountCreated:  "accountCreated",
+	AccountDeleted:  "accountDeleted",
+	AccountUnlocked: "accountUnlocked",
+	AccountLocked:   "accountLocked",
+	WalletArrived:   "walletArrived",
+	WalletDropped:   "walletDropped",
+}
+
+func (t WalletEventType) String() string {
+	if name, ok := walletEventNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("WalletEventType(%d)", int(t))
+}
+
+// MarshalText encodes the event type as its name.
+func (t WalletEventType) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// WalletEvent is posted by the Manager whenever an account is added, removed,
+// unlocked or locked, or a hardware wallet comes or goes.
+type WalletEvent struct {
+	Type    WalletEventType
+	Account Account
+	Wallet  string // URL of the hardware wallet arriving or dropped
+}
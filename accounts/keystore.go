@@ -283,6 +283,16 @@ func encryptKey(key *key, secret string, scryptN, scryptP int) ([]byte, error) {
 	})
 }
 
+// EncryptPrivateKey encrypts a private key with secret as a version 3 record, the
+// format of the keystore files.
+func EncryptPrivateKey(priv *ecdsa.PrivateKey, secret string, scryptN, scryptP int) ([]byte, error) {
+	k, err := newKeyFromECDSA(priv)
+	if err != nil {
+		return nil, err
+	}
+	return encryptKey(k, secret, scryptN, scryptP)
+}
+
 // Web3PrivateKey decrypts the record with secret and returns the private key.
 func Web3PrivateKey(web3JSON []byte, secret string) (*ecdsa.PrivateKey, error) {
 	k, err := decryptKey(web3JSON, secret)
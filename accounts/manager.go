@@ -32,6 +32,7 @@ import (
 	"encoding/json"
 	"github.com/ellaism/go-ellaism/common"
 	"github.com/ellaism/go-ellaism/crypto"
+	"github.com/ellaism/go-ellaism/event"
 	"path/filepath"
 )
 
@@ -78,6 +79,8 @@ type Manager struct {
 	keyStore keyStore
 	mu       sync.RWMutex
 	unlocked map[common.Address]*unlocked
+	feed     *event.TypeMux // Delivers wallet events to subscribers
+	backends []Backend      // Hardware wallet backends signing for keys off the keystore
 }
 
 type unlocked struct {
@@ -109,12 +112,24 @@ func NewManager(keydir string, scryptN, scryptP int, wantCacheDB bool) (*Manager
 	am := &Manager{
 		keyStore: *store,
 		unlocked: make(map[common.Address]*unlocked),
+		feed:     new(event.TypeMux),
 	}
 	if wantCacheDB {
 		am.ac = newCacheDB(keydir)
 	} else {
 		am.ac = newAddrCache(keydir)
 	}
+	// Report key files added or removed behind our back. The callback only holds
+	// on to the feed, so that it doesn't keep the manager from being finalized.
+	feed := am.feed
+	am.ac.getWatcher().onChange = func(added, removed []Account) {
+		for _, a := range added {
+			postWalletEvent(feed, AccountCreated, a)
+		}
+		for _, a := range removed {
+			postWalletEvent(feed, AccountDeleted, a)
+		}
+	}
 
 	// TODO: In order for this finalizer to work, there must be no references
 	// to am. addrCache doesn't keep a reference but unlocked keys do,
@@ -133,6 +148,23 @@ func NewManager(keydir string, scryptN, scryptP int, wantCacheDB bool) (*Manager
 	return am, nil
 }
 
+// Subscribe creates a subscription delivering a WalletEvent for every account
+// created, deleted, unlocked or locked through the manager, and for every key
+// file added to or removed from the key directory by others.
+func (am *Manager) Subscribe() *event.TypeMuxSubscription {
+	return am.feed.Subscribe(WalletEvent{})
+}
+
+// notify posts a wallet event for the account, leaving out its encrypted key.
+// It must not be called with am.mu held, as delivery waits for subscribers.
+func (am *Manager) notify(typ WalletEventType, a Account) {
+	postWalletEvent(am.feed, typ, a)
+}
+
+func postWalletEvent(feed *event.TypeMux, typ WalletEventType, a Account) {
+	feed.Post(WalletEvent{Type: typ, Account: Account{Address: a.Address, File: a.File}})
+}
+
 func (am *Manager) BuildIndexDB() []error {
 	return am.ac.Syncfs2db(time.Now().Add(-60 * 24 * 7 * 30 * 120 * time.Minute)) // arbitrarily long "last updated"
 }
@@ -172,25 +204,36 @@ func (am *Manager) DeleteAccount(a Account, passphrase string) error {
 	err = os.Remove(a.File)
 	if err == nil {
 		am.ac.delete(a)
+		am.notify(AccountDeleted, a)
 	}
 	return err
 }
 
-// Sign signs hash with an unlocked private key matching the given address.
+// Sign signs hash with an unlocked private key matching the given address, or
+// on the open hardware wallet holding it.
 func (am *Manager) Sign(addr common.Address, hash []byte) (signature []byte, err error) {
 	am.mu.RLock()
-	defer am.mu.RUnlock()
+	if unlockedKey, found := am.unlocked[addr]; found {
+		defer am.mu.RUnlock()
+		return crypto.Sign(hash, unlockedKey.PrivateKey)
+	}
+	am.mu.RUnlock()
 
-	unlockedKey, found := am.unlocked[addr]
-	if !found {
-		return nil, ErrLocked
+	if wallet := am.findWallet(addr); wallet != nil {
+		return wallet.SignHash(addr, hash)
 	}
-	return crypto.Sign(hash, unlockedKey.PrivateKey)
+	return nil, ErrLocked
 }
 
 // SignWithPassphrase signs hash if the private key matching the given address can be
-// decrypted with the given passphrase.
+// decrypted with the given passphrase. Keys held by an open hardware wallet were
+// authenticated when opening it, and are signed with directly.
 func (am *Manager) SignWithPassphrase(addr common.Address, passphrase string, hash []byte) (signature []byte, err error) {
+	if !am.ac.hasAddress(addr) {
+		if wallet := am.findWallet(addr); wallet != nil {
+			return wallet.SignHash(addr, hash)
+		}
+	}
 	_, key, err := am.getDecryptedKey(Account{Address: addr}, passphrase)
 	if err != nil {
 		return nil, err
@@ -231,12 +274,12 @@ func (am *Manager) TimedUnlock(a Account, passphrase string, timeout time.Durati
 	}
 
 	am.mu.Lock()
-	defer am.mu.Unlock()
 	u, found := am.unlocked[a.Address]
 	if found {
 		if u.abort == nil {
 			// The address was unlocked indefinitely, so unlocking
 			// it with a timeout would be confusing.
+			am.mu.Unlock()
 			zeroKey(key.PrivateKey)
 			return nil
 		} else {
@@ -251,6 +294,9 @@ func (am *Manager) TimedUnlock(a Account, passphrase string, timeout time.Durati
 		u = &unlocked{key: key}
 	}
 	am.unlocked[a.Address] = u
+	am.mu.Unlock()
+
+	am.notify(AccountUnlocked, a)
 	return nil
 }
 
@@ -292,11 +338,16 @@ func (am *Manager) expire(addr common.Address, u *unlocked, timeout time.Duratio
 		// was launched with. we can check that using pointer equality
 		// because the map stores a new pointer every time the key is
 		// unlocked.
-		if am.unlocked[addr] == u {
+		dropped := am.unlocked[addr] == u
+		if dropped {
 			zeroKey(u.PrivateKey)
 			delete(am.unlocked, addr)
 		}
 		am.mu.Unlock()
+
+		if dropped {
+			am.notify(AccountLocked, Account{Address: addr})
+		}
 	}
 }
 
@@ -310,6 +361,7 @@ func (am *Manager) NewAccount(passphrase string) (Account, error) {
 	// Add the account to the cache immediately rather
 	// than waiting for file system notifications to pick it up.
 	am.ac.add(account)
+	am.notify(AccountCreated, account)
 	return account, nil
 }
 
@@ -365,6 +417,7 @@ func (am *Manager) importKey(key *key, passphrase string) (Account, error) {
 
 	a := Account{File: file, Address: key.Address}
 	am.ac.add(a)
+	am.notify(AccountCreated, a)
 	return a, nil
 }
 
@@ -385,6 +438,7 @@ func (am *Manager) ImportPreSaleKey(keyJSON []byte, passphrase string) (Account,
 		return a, err
 	}
 	am.ac.add(a)
+	am.notify(AccountCreated, a)
 	return a, nil
 }
 
@@ -267,3 +267,46 @@ func TestSignRace_Mem(t *testing.T) {
 	}
 	t.Error("Account did not lock within the timeout")
 }
+
+// Tests that account lifecycle changes are posted to wallet event subscribers.
+func TestWalletEvents_Mem(t *testing.T) {
+	dir, am := tmpManager(t)
+	defer os.RemoveAll(dir)
+
+	sub := am.Subscribe()
+	defer sub.Unsubscribe()
+
+	events := make(chan WalletEvent, 8)
+	go func() {
+		for ev := range sub.Chan() {
+			events <- ev.Data.(WalletEvent)
+		}
+	}()
+
+	a, err := am.NewAccount("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := am.Unlock(a, "foo"); err != nil {
+		t.Fatal(err)
+	}
+	if err := am.Lock(a.Address); err != nil {
+		t.Fatal(err)
+	}
+	if err := am.DeleteAccount(a, "foo"); err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []WalletEventType{AccountCreated, AccountUnlocked, AccountLocked, AccountDeleted} {
+		select {
+		case ev := <-events:
+			if ev.Type != want || ev.Account.Address != a.Address {
+				t.Errorf("event %d: have %v for %x, want %v for %x", i, ev.Type, ev.Account.Address, want, a.Address)
+			}
+			if ev.Account.EncryptedKey != "" {
+				t.Errorf("event %d: encrypted key leaked to subscribers", i)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("event %d (%v) not delivered", i, want)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package scwallet
+
+import (
+	"errors"
+	"fmt"
+)
+
+// card is the transport to a smartcard, exchanging raw APDUs with it.
+type card interface {
+	Transmit(apdu []byte) ([]byte, error)
+	Disconnect() error
+}
+
+// commandAPDU is a command sent to a smartcard.
+type commandAPDU struct {
+	Cla, Ins, P1, P2 uint8
+	Data             []byte
+	Le               uint8 // Maximum length of the response data, 0 meaning 256
+}
+
+// serialize encodes the command as a short APDU.
+func (c *commandAPDU) serialize() ([]byte, error) {
+	if len(c.Data) > 255 {
+		return nil, fmt.Errorf("apdu data too long: %d bytes", len(c.Data))
+	}
+	apdu := []byte{c.Cla, c.Ins, c.P1, c.P2}
+	if len(c.Data) > 0 {
+		apdu = append(apdu, byte(len(c.Data)))
+		apdu = append(apdu, c.Data...)
+	}
+	return append(apdu, c.Le), nil
+}
+
+// responseAPDU is the response of a smartcard to a command.
+type responseAPDU struct {
+	Data     []byte
+	Sw1, Sw2 uint8
+}
+
+// deserialize decodes a response, the data followed by the two status words.
+func (r *responseAPDU) deserialize(data []byte) error {
+	if len(data) < 2 {
+		return fmt.Errorf("apdu response too short: %d bytes", len(data))
+	}
+	r.Data = data[:len(data)-2]
+	r.Sw1, r.Sw2 = data[len(data)-2], data[len(data)-1]
+	return nil
+}
+
+// status returns both status words of the response as one number.
+func (r *responseAPDU) status() uint16 {
+	return uint16(r.Sw1)<<8 | uint16(r.Sw2)
+}
+
+// transmit sends a command to the card in the clear, failing unless the card
+// reports success.
+func transmit(c card, command *commandAPDU) (*responseAPDU, error) {
+	apdu, err := command.serialize()
+	if err != nil {
+		return nil, err
+	}
+	data, err := c.Transmit(apdu)
+	if err != nil {
+		return nil, err
+	}
+	response := new(responseAPDU)
+	if err := response.deserialize(data); err != nil {
+		return nil, err
+	}
+	if response.status() != swOK {
+		return nil, fmt.Errorf("unexpected response status %#04x to instruction %#02x", response.status(), command.Ins)
+	}
+	return response, nil
+}
+
+var errTLVTruncated = errors.New("truncated tlv data")
+
+// findTag looks up the value of a tag in BER-TLV encoded data, descending into
+// the values of the given path of constructed tags first.
+func findTag(data []byte, path ...uint8) ([]byte, error) {
+	for {
+		if len(data) < 2 {
+			return nil, fmt.Errorf("tag %#02x not found", path[0])
+		}
+		tag, size, header := data[0], int(data[1]), 2
+		switch size {
+		case 0x81:
+			if len(data) < 3 {
+				return nil, errTLVTruncated
+			}
+			size, header = int(data[2]), 3
+		case 0x82:
+			if len(data) < 4 {
+				return nil, errTLVTruncated
+			}
+			size, header = int(data[2])<<8|int(data[3]), 4
+		}
+		if len(data) < header+size {
+			return nil, errTLVTruncated
+		}
+		value := data[header : header+size]
+		if tag == path[0] {
+			if len(path) == 1 {
+				return value, nil
+			}
+			data, path = value, path[1:]
+			continue
+		}
+		data = data[header+size:]
+	}
+}
@@ -0,0 +1,234 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package scwallet implements a hardware wallet backend for Keycard smartcards,
+// talking to them through the pcsc-lite daemon.
+//
+// Cards are paired with the node once, with the pairing password set on them,
+// after which each session is opened with the PIN of the card. Pairings are
+// kept in a file so that the pairing password is only needed the first time.
+package scwallet
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ellaism/go-ellaism/accounts"
+	"github.com/ellaism/go-ellaism/event"
+	"github.com/ellaism/go-ellaism/logger"
+	"github.com/ellaism/go-ellaism/logger/glog"
+)
+
+// refreshInterval is how often the readers are checked for cards coming and
+// going.
+const refreshInterval = time.Second
+
+// pairing is the key shared with a card by pairing with it.
+type pairing struct {
+	PairingIndex uint8  `json:"index"`
+	PairingKey   []byte `json:"key"`
+}
+
+// Hub is a backend of the account manager tracking the Keycard smartcards
+// inserted in the readers of the pcsc-lite daemon.
+type Hub struct {
+	client       *pcscClient
+	pairingsFile string
+
+	lock    sync.Mutex
+	wallets map[string]*Wallet // Wallets by the reader their card is in
+	foreign map[string]bool    // Readers holding cards other than Keycards
+
+	pairingsLock sync.Mutex
+	pairings     map[string]*pairing // Pairings by the hex secure channel key of the card
+
+	feed event.TypeMux
+	quit chan struct{}
+}
+
+// NewHub connects to the pcsc-lite daemon at the given socket path, tracking the
+// Keycards inserted in its readers. Pairings are kept in pairingsFile.
+func NewHub(daemonPath, pairingsFile string) (*Hub, error) {
+	client, err := dialPCSC(daemonPath)
+	if err != nil {
+		return nil, err
+	}
+	hub := &Hub{
+		client:       client,
+		pairingsFile: pairingsFile,
+		wallets:      make(map[string]*Wallet),
+		foreign:      make(map[string]bool),
+		pairings:     make(map[string]*pairing),
+		quit:         make(chan struct{}),
+	}
+	if err := hub.readPairings(); err != nil {
+		client.Close()
+		return nil, err
+	}
+	hub.refresh()
+	go hub.loop()
+
+	return hub, nil
+}
+
+// Wallets implements accounts.Backend, returning the inserted Keycards sorted
+// by URL.
+func (h *Hub) Wallets() []accounts.Wallet {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	wallets := make([]*Wallet, 0, len(h.wallets))
+	for _, wallet := range h.wallets {
+		wallets = append(wallets, wallet)
+	}
+	sort.Slice(wallets, func(i, j int) bool { return wallets[i].URL() < wallets[j].URL() })
+
+	result := make([]accounts.Wallet, len(wallets))
+	for i, wallet := range wallets {
+		result[i] = wallet
+	}
+	return result
+}
+
+// Subscribe implements accounts.Backend, delivering an event each time a
+// Keycard is inserted or removed.
+func (h *Hub) Subscribe() *event.TypeMuxSubscription {
+	return h.feed.Subscribe(accounts.WalletEvent{})
+}
+
+// Close stops tracking the readers and disconnects from the daemon.
+func (h *Hub) Close() error {
+	close(h.quit)
+	return h.client.Close()
+}
+
+func (h *Hub) loop() {
+	ticker := time.NewTicker(refreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-h.quit:
+			return
+		case <-ticker.C:
+			h.refresh()
+		}
+	}
+}
+
+// refresh checks the readers for Keycards inserted or removed since the last
+// time, posting the matching wallet events.
+func (h *Hub) refresh() {
+	readers, err := h.client.Readers()
+	if err != nil {
+		glog.V(logger.Debug).Infof("failed to list smartcard readers: %v", err)
+		return
+	}
+	present := make(map[string]bool)
+	for _, reader := range readers {
+		if reader.Present {
+			present[reader.Name] = true
+		}
+	}
+	var events []accounts.WalletEvent
+
+	h.lock.Lock()
+	for reader, wallet := range h.wallets {
+		if !present[reader] {
+			delete(h.wallets, reader)
+			wallet.card.Disconnect()
+			events = append(events, accounts.WalletEvent{Type: accounts.WalletDropped, Wallet: wallet.URL()})
+		}
+	}
+	for reader := range h.foreign {
+		if !present[reader] {
+			delete(h.foreign, reader)
+		}
+	}
+	for reader := range present {
+		if h.wallets[reader] != nil || h.foreign[reader] {
+			continue
+		}
+		card, err := h.client.Connect(reader)
+		if err != nil {
+			glog.V(logger.Debug).Infof("failed to connect to smartcard in %q: %v", reader, err)
+			continue
+		}
+		wallet, err := newWallet(h, card)
+		if err != nil {
+			glog.V(logger.Debug).Infof("ignoring smartcard in %q: %v", reader, err)
+			card.Disconnect()
+			h.foreign[reader] = true
+			continue
+		}
+		h.wallets[reader] = wallet
+		events = append(events, accounts.WalletEvent{Type: accounts.WalletArrived, Wallet: wallet.URL()})
+	}
+	h.lock.Unlock()
+
+	for _, ev := range events {
+		glog.V(logger.Info).Infof("smartcard wallet %s: %v", ev.Wallet, ev.Type)
+		h.feed.Post(ev)
+	}
+}
+
+// pairing returns the pairing with the card of the given secure channel key.
+func (h *Hub) pairing(publicKey []byte) *pairing {
+	h.pairingsLock.Lock()
+	defer h.pairingsLock.Unlock()
+
+	return h.pairings[hex.EncodeToString(publicKey)]
+}
+
+// setPairing stores or, if nil, drops the pairing with a card, persisting the
+// pairings.
+func (h *Hub) setPairing(publicKey []byte, p *pairing) error {
+	h.pairingsLock.Lock()
+	defer h.pairingsLock.Unlock()
+
+	if p == nil {
+		delete(h.pairings, hex.EncodeToString(publicKey))
+	} else {
+		h.pairings[hex.EncodeToString(publicKey)] = p
+	}
+	return h.writePairings()
+}
+
+// readPairings loads the pairings file, if it exists.
+func (h *Hub) readPairings() error {
+	data, err := ioutil.ReadFile(h.pairingsFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &h.pairings)
+}
+
+// writePairings saves the pairings file, readable by the owner only.
+func (h *Hub) writePairings() error {
+	data, err := json.MarshalIndent(h.pairings, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(h.pairingsFile, data, 0600)
+}
@@ -0,0 +1,242 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package scwallet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+)
+
+// DefaultDaemonPath is the socket the pcsc-lite daemon listens on for clients
+// on most Linux distributions.
+const DefaultDaemonPath = "/run/pcscd/pcscd.comm"
+
+// Commands of the pcsc-lite client protocol.
+const (
+	scardEstablishContext = 0x01
+	scardReleaseContext   = 0x02
+	scardConnect          = 0x04
+	scardDisconnect       = 0x06
+	scardTransmit         = 0x09
+	cmdVersion            = 0x11
+	cmdGetReadersState    = 0x12
+)
+
+const (
+	protocolVersionMajor = 4 // Client protocol version spoken to the daemon
+	protocolVersionMinor = 3
+
+	scardScopeSystem     = 2      // Context scope of the connection
+	scardShareShared     = 2      // Share the card with other applications
+	scardProtocolAny     = 3      // Accept both the T=0 and T=1 protocols
+	scardLeaveCard       = 0      // Leave the card untouched when disconnecting
+	scardStatePresent    = 0x0004 // Reader state flag of an inserted card
+	scardIORequestLength = 8      // Size of the protocol control info of transmissions
+
+	maxReaders       = 16    // Number of reader slots reported by the daemon
+	maxReaderNameLen = 128   // Size of the reader name field
+	maxATRLen        = 33    // Size of the answer-to-reset field
+	maxResponseLen   = 65538 // Largest extended APDU response accepted
+	readerStateLen   = maxReaderNameLen + 12 + maxATRLen + 3 + 8
+)
+
+// pcscClient talks to the pcsc-lite daemon over its unix socket, implementing
+// the subset of the PC/SC interface needed to exchange APDUs with smartcards.
+// Messages are encoded in the byte order of the host, assumed little endian.
+type pcscClient struct {
+	conn    net.Conn
+	context uint32
+	lock    sync.Mutex // Serializes the request/response exchanges
+}
+
+// pcscReader is the state of a reader, as reported by the daemon.
+type pcscReader struct {
+	Name    string
+	Present bool // Whether a card is inserted in the reader
+}
+
+// dialPCSC connects to the pcsc-lite daemon at the given socket path and
+// establishes a context to access the readers with.
+func dialPCSC(path string) (*pcscClient, error) {
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	client := &pcscClient{conn: conn}
+
+	var version [12]byte
+	binary.LittleEndian.PutUint32(version[0:], protocolVersionMajor)
+	binary.LittleEndian.PutUint32(version[4:], protocolVersionMinor)
+	if err := client.exchange(cmdVersion, version[:], version[:]); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("pcsc: version negotiation failed: %v", err)
+	}
+	var context [12]byte
+	binary.LittleEndian.PutUint32(context[0:], scardScopeSystem)
+	if err := client.exchange(scardEstablishContext, context[:], context[:]); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("pcsc: failed to establish context: %v", err)
+	}
+	client.context = binary.LittleEndian.Uint32(context[4:])
+	return client, nil
+}
+
+// Close releases the context and disconnects from the daemon.
+func (c *pcscClient) Close() error {
+	var release [8]byte
+	binary.LittleEndian.PutUint32(release[0:], c.context)
+	c.exchange(scardReleaseContext, release[:], release[:])
+	return c.conn.Close()
+}
+
+// Readers returns the readers known to the daemon.
+func (c *pcscClient) Readers() ([]pcscReader, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if err := c.send(cmdGetReadersState, nil); err != nil {
+		return nil, err
+	}
+	states := make([]byte, maxReaders*readerStateLen)
+	if _, err := io.ReadFull(c.conn, states); err != nil {
+		return nil, err
+	}
+	var readers []pcscReader
+	for i := 0; i < maxReaders; i++ {
+		state := states[i*readerStateLen : (i+1)*readerStateLen]
+		if state[0] == 0 {
+			continue
+		}
+		name := state[:maxReaderNameLen]
+		if end := bytes.IndexByte(name, 0); end >= 0 {
+			name = name[:end]
+		}
+		flags := binary.LittleEndian.Uint32(state[maxReaderNameLen+4:])
+		readers = append(readers, pcscReader{Name: string(name), Present: flags&scardStatePresent != 0})
+	}
+	return readers, nil
+}
+
+// Connect opens a shared connection to the card in the given reader.
+func (c *pcscClient) Connect(reader string) (*pcscCard, error) {
+	if len(reader) >= maxReaderNameLen {
+		return nil, fmt.Errorf("pcsc: reader name too long: %q", reader)
+	}
+	connect := make([]byte, 4+maxReaderNameLen+20)
+	binary.LittleEndian.PutUint32(connect[0:], c.context)
+	copy(connect[4:], reader)
+	binary.LittleEndian.PutUint32(connect[4+maxReaderNameLen:], scardShareShared)
+	binary.LittleEndian.PutUint32(connect[8+maxReaderNameLen:], scardProtocolAny)
+
+	if err := c.exchange(scardConnect, connect, connect); err != nil {
+		return nil, fmt.Errorf("pcsc: failed to connect to %q: %v", reader, err)
+	}
+	return &pcscCard{
+		client:   c,
+		handle:   binary.LittleEndian.Uint32(connect[12+maxReaderNameLen:]),
+		protocol: binary.LittleEndian.Uint32(connect[16+maxReaderNameLen:]),
+	}, nil
+}
+
+// send writes a request with the given command and payload to the daemon.
+func (c *pcscClient) send(command uint32, payload []byte) error {
+	msg := make([]byte, 8+len(payload))
+	binary.LittleEndian.PutUint32(msg[0:], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(msg[4:], command)
+	copy(msg[8:], payload)
+
+	_, err := c.conn.Write(msg)
+	return err
+}
+
+// exchange sends a request and reads its fixed size response into reply, the
+// last field of which is the return value of the operation.
+func (c *pcscClient) exchange(command uint32, request, reply []byte) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if err := c.send(command, request); err != nil {
+		return err
+	}
+	if _, err := io.ReadFull(c.conn, reply); err != nil {
+		return err
+	}
+	return returnValue(reply)
+}
+
+// returnValue checks the return value closing a response of the daemon.
+func returnValue(reply []byte) error {
+	if rv := binary.LittleEndian.Uint32(reply[len(reply)-4:]); rv != 0 {
+		return fmt.Errorf("pcsc error %#x", rv)
+	}
+	return nil
+}
+
+// pcscCard is a connection to a card through the pcsc-lite daemon.
+type pcscCard struct {
+	client   *pcscClient
+	handle   uint32
+	protocol uint32
+}
+
+// Transmit sends an APDU to the card, returning its response.
+func (c *pcscCard) Transmit(apdu []byte) ([]byte, error) {
+	c.client.lock.Lock()
+	defer c.client.lock.Unlock()
+
+	var transmit [32]byte
+	binary.LittleEndian.PutUint32(transmit[0:], c.handle)
+	binary.LittleEndian.PutUint32(transmit[4:], c.protocol)
+	binary.LittleEndian.PutUint32(transmit[8:], scardIORequestLength)
+	binary.LittleEndian.PutUint32(transmit[12:], uint32(len(apdu)))
+	binary.LittleEndian.PutUint32(transmit[20:], scardIORequestLength)
+	binary.LittleEndian.PutUint32(transmit[24:], maxResponseLen)
+
+	if err := c.client.send(scardTransmit, transmit[:]); err != nil {
+		return nil, err
+	}
+	if _, err := c.client.conn.Write(apdu); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(c.client.conn, transmit[:]); err != nil {
+		return nil, err
+	}
+	if err := returnValue(transmit[:]); err != nil {
+		return nil, err
+	}
+	size := binary.LittleEndian.Uint32(transmit[24:])
+	if size > maxResponseLen {
+		return nil, fmt.Errorf("pcsc: response too long: %d bytes", size)
+	}
+	response := make([]byte, size)
+	if _, err := io.ReadFull(c.client.conn, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// Disconnect releases the connection to the card.
+func (c *pcscCard) Disconnect() error {
+	var disconnect [12]byte
+	binary.LittleEndian.PutUint32(disconnect[0:], c.handle)
+	binary.LittleEndian.PutUint32(disconnect[4:], scardLeaveCard)
+	return c.client.exchange(scardDisconnect, disconnect[:], disconnect[:])
+}
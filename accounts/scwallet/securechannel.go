@@ -0,0 +1,277 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package scwallet
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/crypto"
+	"github.com/ellaism/go-ellaism/crypto/secp256k1"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	pairingSalt       = "Keycard Pairing Password Salt" // Salt stretching pairing passwords
+	pairingIterations = 50000                           // PBKDF2 rounds stretching pairing passwords
+
+	scSecretLength = 32 // Length of challenges, salts and keys of the secure channel
+	scBlockSize    = aes.BlockSize
+)
+
+var (
+	errChannelClosed = errors.New("secure channel not open")
+	errInvalidMAC    = errors.New("invalid MAC in card response")
+)
+
+// pairingSecret stretches a pairing password into the secret shared with the
+// cards it was set on.
+func pairingSecret(password string) []byte {
+	return pbkdf2.Key([]byte(password), []byte(pairingSalt), pairingIterations, scSecretLength, sha256.New)
+}
+
+// secureChannel is an encrypted and authenticated session with a card, opened
+// with the key of a pairing established between the two beforehand.
+type secureChannel struct {
+	card      card
+	publicKey []byte // Secure channel public key of the card
+
+	pairingKey   []byte // Key shared with the card by pairing
+	pairingIndex uint8  // Slot of the pairing on the card
+
+	encKey []byte // Session key encrypting the APDUs
+	macKey []byte // Session key authenticating the APDUs
+	iv     []byte // Chaining value of the session, the last MAC exchanged
+}
+
+// pair establishes a new pairing with the card, proving knowledge of the pairing
+// password and checking the card knows it too.
+func (s *secureChannel) pair(password string) error {
+	secret := pairingSecret(password)
+
+	challenge := make([]byte, scSecretLength)
+	if _, err := rand.Read(challenge); err != nil {
+		return err
+	}
+	response, err := transmit(s.card, &commandAPDU{Cla: claSCWallet, Ins: insPair, P1: pairP1FirstStep, Data: challenge})
+	if err != nil {
+		return err
+	}
+	if len(response.Data) != 2*scSecretLength {
+		return fmt.Errorf("invalid pairing response length: %d", len(response.Data))
+	}
+	cardCryptogram, cardChallenge := response.Data[:scSecretLength], response.Data[scSecretLength:]
+	if want := sha256.Sum256(append(append([]byte{}, secret...), challenge...)); !bytes.Equal(cardCryptogram, want[:]) {
+		return errors.New("invalid card cryptogram, wrong pairing password?")
+	}
+	cryptogram := sha256.Sum256(append(append([]byte{}, secret...), cardChallenge...))
+	response, err = transmit(s.card, &commandAPDU{Cla: claSCWallet, Ins: insPair, P1: pairP1LastStep, Data: cryptogram[:]})
+	if err != nil {
+		return err
+	}
+	if len(response.Data) != 1+scSecretLength {
+		return fmt.Errorf("invalid pairing response length: %d", len(response.Data))
+	}
+	key := sha256.Sum256(append(append([]byte{}, secret...), response.Data[1:]...))
+
+	s.pairingKey, s.pairingIndex = key[:], response.Data[0]
+	return nil
+}
+
+// unpair releases the pairing slot on the card. The channel must be open.
+func (s *secureChannel) unpair() error {
+	if _, err := s.transmitEncrypted(claSCWallet, insUnpair, s.pairingIndex, 0, nil); err != nil {
+		return err
+	}
+	s.pairingKey, s.iv = nil, nil
+	return nil
+}
+
+// open opens the channel with the pairing key, deriving the session keys from
+// an ephemeral key exchange, and authenticates both ends to each other.
+func (s *secureChannel) open() error {
+	if s.pairingKey == nil {
+		return errors.New("card not paired")
+	}
+	cardKey := crypto.ToECDSAPub(s.publicKey)
+	if cardKey.X == nil {
+		return errors.New("invalid secure channel key of card")
+	}
+	ephemeral, err := crypto.GenerateKey()
+	if err != nil {
+		return err
+	}
+	response, err := transmit(s.card, &commandAPDU{Cla: claSCWallet, Ins: insOpenSecureChannel, P1: s.pairingIndex, Data: crypto.FromECDSAPub(&ephemeral.PublicKey)})
+	if err != nil {
+		return err
+	}
+	if len(response.Data) != scSecretLength+scBlockSize {
+		return fmt.Errorf("invalid open channel response length: %d", len(response.Data))
+	}
+	secret := ecdh(ephemeral.D.Bytes(), cardKey.X, cardKey.Y)
+	s.encKey, s.macKey = sessionKeys(secret, s.pairingKey, response.Data[:scSecretLength])
+	s.iv = response.Data[scSecretLength:]
+
+	return s.mutuallyAuthenticate()
+}
+
+// mutuallyAuthenticate checks the card derived the same session keys.
+func (s *secureChannel) mutuallyAuthenticate() error {
+	challenge := make([]byte, scSecretLength)
+	if _, err := rand.Read(challenge); err != nil {
+		return err
+	}
+	response, err := s.transmitEncrypted(claSCWallet, insMutuallyAuthenticate, 0, 0, challenge)
+	if err != nil {
+		return err
+	}
+	if len(response.Data) != scSecretLength {
+		return fmt.Errorf("invalid mutual authentication response length: %d", len(response.Data))
+	}
+	return nil
+}
+
+// transmitEncrypted sends a command over the channel, returning the decrypted
+// response once its MAC is verified.
+func (s *secureChannel) transmitEncrypted(cla, ins, p1, p2 uint8, data []byte) (*responseAPDU, error) {
+	if s.iv == nil {
+		return nil, errChannelClosed
+	}
+	encrypted, err := encryptCBC(s.encKey, s.iv, data)
+	if err != nil {
+		return nil, err
+	}
+	meta := []byte{cla, ins, p1, p2, byte(len(encrypted) + scBlockSize)}
+	if s.iv, err = cbcMAC(s.macKey, meta, encrypted); err != nil {
+		return nil, err
+	}
+	response, err := transmit(s.card, &commandAPDU{Cla: cla, Ins: ins, P1: p1, P2: p2, Data: append(append([]byte{}, s.iv...), encrypted...)})
+	if err != nil {
+		return nil, err
+	}
+	if len(response.Data) < 2*scBlockSize {
+		return nil, fmt.Errorf("encrypted response too short: %d bytes", len(response.Data))
+	}
+	mac, encrypted := response.Data[:scBlockSize], response.Data[scBlockSize:]
+
+	plain, err := decryptCBC(s.encKey, s.iv, encrypted)
+	if err != nil {
+		return nil, err
+	}
+	if s.iv, err = cbcMAC(s.macKey, []byte{byte(len(response.Data))}, encrypted); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(s.iv, mac) {
+		s.iv = nil
+		return nil, errInvalidMAC
+	}
+	decrypted := new(responseAPDU)
+	if err := decrypted.deserialize(plain); err != nil {
+		return nil, err
+	}
+	return decrypted, nil
+}
+
+// ecdh computes the X coordinate of the shared point of a key exchange.
+func ecdh(priv []byte, x, y *big.Int) []byte {
+	sx, _ := secp256k1.S256().ScalarMult(x, y, priv)
+	if sx == nil {
+		return nil
+	}
+	return common.LeftPadBytes(sx.Bytes(), 32)
+}
+
+// sessionKeys derives the encryption and MAC keys of a session.
+func sessionKeys(secret, pairingKey, salt []byte) (encKey, macKey []byte) {
+	digest := sha512.New()
+	digest.Write(secret)
+	digest.Write(pairingKey)
+	digest.Write(salt)
+	keys := digest.Sum(nil)
+
+	return keys[:scSecretLength], keys[scSecretLength:]
+}
+
+// pad applies the ISO/IEC 9797-1 method 2 padding, a marker byte followed by
+// zeroes up to the next block boundary.
+func pad(data []byte) []byte {
+	padded := make([]byte, (len(data)/scBlockSize+1)*scBlockSize)
+	copy(padded, data)
+	padded[len(data)] = 0x80
+	return padded
+}
+
+// unpad strips the padding applied by pad.
+func unpad(data []byte) ([]byte, error) {
+	for i := len(data) - 1; i >= 0; i-- {
+		switch data[i] {
+		case 0x80:
+			return data[:i], nil
+		case 0x00:
+		default:
+			return nil, errors.New("invalid padding")
+		}
+	}
+	return nil, errors.New("invalid padding")
+}
+
+// encryptCBC pads and encrypts data with AES-CBC.
+func encryptCBC(key, iv, data []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	encrypted := pad(data)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(encrypted, encrypted)
+	return encrypted, nil
+}
+
+// decryptCBC decrypts data with AES-CBC and strips its padding.
+func decryptCBC(key, iv, data []byte) ([]byte, error) {
+	if len(data)%scBlockSize != 0 {
+		return nil, errors.New("encrypted data not block aligned")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	plain := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plain, data)
+	return unpad(plain)
+}
+
+// cbcMAC computes the AES-CBC-MAC of a message: a metadata block, zero padded,
+// followed by the already block aligned encrypted data.
+func cbcMAC(key, meta, data []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	msg := make([]byte, scBlockSize+len(data))
+	copy(msg, meta)
+	copy(msg[scBlockSize:], data)
+	cipher.NewCBCEncrypter(block, make([]byte, scBlockSize)).CryptBlocks(msg, msg)
+	return msg[len(msg)-scBlockSize:], nil
+}
@@ -0,0 +1,367 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package scwallet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/crypto"
+	"github.com/ellaism/go-ellaism/crypto/secp256k1"
+)
+
+// Scheme is the URL scheme of Keycard wallets.
+const Scheme = "keycard"
+
+// keycardAID is the application identifier of the Keycard applet.
+var keycardAID = []byte{0xA0, 0x00, 0x00, 0x08, 0x04, 0x00, 0x01, 0x01, 0x01}
+
+// DefaultDerivationPath is the BIP-44 path of the key used on the card:
+// m/44'/163'/0'/0/0, 163 being the coin type registered for Ellaism.
+var DefaultDerivationPath = []uint32{0x80000000 + 44, 0x80000000 + 163, 0x80000000, 0, 0}
+
+const (
+	claISO7816  = 0x00
+	claSCWallet = 0x80
+
+	insSelect               = 0xA4
+	insOpenSecureChannel    = 0x10
+	insMutuallyAuthenticate = 0x11
+	insPair                 = 0x12
+	insUnpair               = 0x13
+	insVerifyPin            = 0x20
+	insSign                 = 0xC0
+	insExportKey            = 0xC2
+	insDeriveKey            = 0xD1
+
+	pairP1FirstStep      = 0x00
+	pairP1LastStep       = 0x01
+	selectP1ByName       = 0x04
+	deriveP1FromMaster   = 0x00
+	exportP1CurrentKey   = 0x00
+	exportP2PublicOnly   = 0x01
+	signP1CurrentKey     = 0x00
+	signP2ECDSASecp256k1 = 0x00
+
+	swOK              = 0x9000
+	swWrongPINPrefix  = 0x63C0 // Low nibble holds the attempts left
+	swWrongPINMask    = 0xFFF0
+	tagAppInfo        = 0xA4
+	tagPublicKey      = 0x80
+	tagInstanceUID    = 0x8F
+	tagKeyUID         = 0x8E
+	tagKeyPair        = 0xA1
+	tagSignature      = 0xA0
+	tagECDSASignature = 0x30
+	tagInteger        = 0x02
+)
+
+var (
+	// ErrPairingPasswordNeeded is returned when opening a card not paired yet,
+	// whose pairing password must be given to pair with it.
+	ErrPairingPasswordNeeded = errors.New("smartcard: pairing password needed")
+
+	// ErrPINNeeded is returned when opening a paired card without its PIN.
+	ErrPINNeeded = errors.New("smartcard: PIN needed")
+
+	// ErrUninitialized is returned for cards without a PIN or key set up yet,
+	// which must be initialized with the Keycard tools first.
+	ErrUninitialized = errors.New("smartcard: card not initialized")
+
+	errNotOpen = errors.New("smartcard: wallet not open")
+)
+
+// Wallet is a Keycard smartcard, signing with a key held on the card.
+type Wallet struct {
+	hub  *Hub
+	card card
+
+	publicKey   []byte // Secure channel public key of the card, keying its pairing
+	instanceUID []byte // Unique identifier of the applet instance
+	hasKey      bool   // Whether a master key is loaded on the card
+
+	lock     sync.Mutex     // Serializes the exchanges with the card
+	channel  *secureChannel // Channel with the card, nil until paired
+	verified bool           // Whether the PIN was verified in the open session
+	address  common.Address // Address of the key derived for signing
+}
+
+// newWallet selects the Keycard applet on a card, failing for other cards.
+func newWallet(hub *Hub, c card) (*Wallet, error) {
+	response, err := transmit(c, &commandAPDU{Cla: claISO7816, Ins: insSelect, P1: selectP1ByName, Data: keycardAID})
+	if err != nil {
+		return nil, err
+	}
+	w := &Wallet{hub: hub, card: c}
+
+	info, err := findTag(response.Data, tagAppInfo)
+	if err != nil {
+		// Uninitialized cards only report their secure channel key
+		if w.publicKey, err = findTag(response.Data, tagPublicKey); err != nil {
+			return nil, fmt.Errorf("smartcard: invalid applet selection response: %v", err)
+		}
+		return w, nil
+	}
+	if w.instanceUID, err = findTag(info, tagInstanceUID); err != nil {
+		return nil, err
+	}
+	if w.publicKey, err = findTag(info, tagPublicKey); err != nil {
+		return nil, err
+	}
+	keyUID, _ := findTag(info, tagKeyUID)
+	w.hasKey = len(keyUID) > 0
+
+	if pairing := hub.pairing(w.publicKey); pairing != nil {
+		w.channel = &secureChannel{card: c, publicKey: w.publicKey, pairingKey: pairing.PairingKey, pairingIndex: pairing.PairingIndex}
+	}
+	return w, nil
+}
+
+// URL implements accounts.Wallet, identifying the card by its applet instance.
+func (w *Wallet) URL() string {
+	if w.instanceUID == nil {
+		return fmt.Sprintf("%s://uninitialized-%x", Scheme, w.publicKey[len(w.publicKey)-4:])
+	}
+	return fmt.Sprintf("%s://%s", Scheme, hex.EncodeToString(w.instanceUID))
+}
+
+// Status implements accounts.Wallet, returning the step of the setup the card
+// is at.
+func (w *Wallet) Status() (string, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	switch {
+	case w.instanceUID == nil:
+		return "Uninitialized", ErrUninitialized
+	case w.channel == nil:
+		return "Unpaired, pairing password needed", nil
+	case !w.verified:
+		return "Paired, PIN needed", nil
+	case !w.hasKey:
+		return "Online, no key loaded", nil
+	default:
+		return fmt.Sprintf("Online, signing for %x", w.address), nil
+	}
+}
+
+// Open implements accounts.Wallet. Opening a card takes up to two calls: an
+// unpaired card is paired with its pairing password and reports ErrPINNeeded,
+// after which a paired card is unlocked with its PIN.
+func (w *Wallet) Open(passphrase string) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.instanceUID == nil {
+		return ErrUninitialized
+	}
+	if w.channel == nil {
+		if passphrase == "" {
+			return ErrPairingPasswordNeeded
+		}
+		channel := &secureChannel{card: w.card, publicKey: w.publicKey}
+		if err := channel.pair(passphrase); err != nil {
+			return err
+		}
+		if err := w.hub.setPairing(w.publicKey, &pairing{PairingIndex: channel.pairingIndex, PairingKey: channel.pairingKey}); err != nil {
+			return err
+		}
+		w.channel = channel
+		if err := w.channel.open(); err != nil {
+			return err
+		}
+		return ErrPINNeeded
+	}
+	if w.channel.iv == nil {
+		if err := w.channel.open(); err != nil {
+			return err
+		}
+	}
+	if w.verified {
+		return nil
+	}
+	if passphrase == "" {
+		return ErrPINNeeded
+	}
+	if err := w.verifyPIN(passphrase); err != nil {
+		return err
+	}
+	if !w.hasKey {
+		return nil
+	}
+	return w.deriveAccount(DefaultDerivationPath)
+}
+
+// verifyPIN unlocks the card with its PIN.
+func (w *Wallet) verifyPIN(pin string) error {
+	response, err := w.channel.transmitEncrypted(claSCWallet, insVerifyPin, 0, 0, []byte(pin))
+	if err != nil {
+		return err
+	}
+	switch status := response.status(); {
+	case status == swOK:
+		w.verified = true
+		return nil
+	case status&swWrongPINMask == swWrongPINPrefix:
+		return fmt.Errorf("smartcard: wrong PIN, %d attempts left", status&^swWrongPINMask)
+	default:
+		return fmt.Errorf("smartcard: PIN verification failed with status %#04x", status)
+	}
+}
+
+// deriveAccount makes the key at the given path current, reading its address.
+func (w *Wallet) deriveAccount(path []uint32) error {
+	data := make([]byte, 4*len(path))
+	for i, index := range path {
+		binary.BigEndian.PutUint32(data[4*i:], index)
+	}
+	if _, err := w.transmitChecked(insDeriveKey, deriveP1FromMaster, 0, data); err != nil {
+		return err
+	}
+	response, err := w.transmitChecked(insExportKey, exportP1CurrentKey, exportP2PublicOnly, nil)
+	if err != nil {
+		return err
+	}
+	pubkey, err := findTag(response.Data, tagKeyPair, tagPublicKey)
+	if err != nil {
+		return err
+	}
+	key := crypto.ToECDSAPub(pubkey)
+	if key.X == nil {
+		return errors.New("smartcard: invalid public key exported")
+	}
+	w.address = crypto.PubkeyToAddress(*key)
+	return nil
+}
+
+// transmitChecked sends a command over the secure channel, failing unless the
+// card reports success.
+func (w *Wallet) transmitChecked(ins, p1, p2 uint8, data []byte) (*responseAPDU, error) {
+	response, err := w.channel.transmitEncrypted(claSCWallet, ins, p1, p2, data)
+	if err != nil {
+		return nil, err
+	}
+	if response.status() != swOK {
+		return nil, fmt.Errorf("smartcard: unexpected status %#04x to instruction %#02x", response.status(), ins)
+	}
+	return response, nil
+}
+
+// Close implements accounts.Wallet, forgetting the session so that the PIN is
+// asked again on the next opening. The pairing is kept.
+func (w *Wallet) Close() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.channel != nil {
+		w.channel.iv = nil
+	}
+	w.verified, w.address = false, common.Address{}
+	return nil
+}
+
+// Unpair releases the pairing slot of this node on the card. The wallet must be
+// open.
+func (w *Wallet) Unpair() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.channel == nil || !w.verified {
+		return errNotOpen
+	}
+	if err := w.channel.unpair(); err != nil {
+		return err
+	}
+	w.channel, w.verified, w.address = nil, false, common.Address{}
+	return w.hub.setPairing(w.publicKey, nil)
+}
+
+// Accounts implements accounts.Wallet, returning the address of the key the
+// open card signs with.
+func (w *Wallet) Accounts() []common.Address {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if !w.verified || (w.address == common.Address{}) {
+		return nil
+	}
+	return []common.Address{w.address}
+}
+
+// SignHash implements accounts.Wallet, signing on the card.
+func (w *Wallet) SignHash(addr common.Address, hash []byte) ([]byte, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if !w.verified {
+		return nil, errNotOpen
+	}
+	if addr != w.address {
+		return nil, fmt.Errorf("smartcard: no key for address %x", addr)
+	}
+	if len(hash) != 32 {
+		return nil, fmt.Errorf("hash is required to be exactly 32 bytes (%d)", len(hash))
+	}
+	response, err := w.transmitChecked(insSign, signP1CurrentKey, signP2ECDSASecp256k1, hash)
+	if err != nil {
+		return nil, err
+	}
+	pubkey, err := findTag(response.Data, tagSignature, tagPublicKey)
+	if err != nil {
+		return nil, err
+	}
+	der, err := findTag(response.Data, tagSignature, tagECDSASignature)
+	if err != nil {
+		return nil, err
+	}
+	return recoverableSignature(hash, pubkey, der)
+}
+
+// recoverableSignature converts a DER encoded signature into the [R || S || V]
+// format, normalizing S to the lower half of the curve order and finding the
+// recovery id yielding the signing key.
+func recoverableSignature(hash, pubkey, der []byte) ([]byte, error) {
+	r, err := findTag(der, tagInteger)
+	if err != nil {
+		return nil, err
+	}
+	rest := der[2+len(r):]
+	s, err := findTag(rest, tagInteger)
+	if err != nil {
+		return nil, err
+	}
+	sn := new(big.Int).SetBytes(s)
+	if sn.Cmp(secp256k1.HalfN) > 0 {
+		sn.Sub(secp256k1.N, sn)
+	}
+	sig := make([]byte, 65)
+	copy(sig[:32], common.LeftPadBytes(new(big.Int).SetBytes(r).Bytes(), 32))
+	copy(sig[32:64], common.LeftPadBytes(sn.Bytes(), 32))
+	for v := byte(0); v < 2; v++ {
+		sig[64] = v
+		if recovered, err := crypto.Ecrecover(hash, sig); err == nil && bytes.Equal(recovered, pubkey) {
+			return sig, nil
+		}
+	}
+	return nil, errors.New("smartcard: signature does not match the key of the card")
+}
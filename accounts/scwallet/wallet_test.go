@@ -0,0 +1,246 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package scwallet
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/crypto"
+)
+
+// simulatedCard is an in-memory Keycard, implementing the card side of the
+// commands used by the wallet.
+type simulatedCard struct {
+	scKey   *ecdsa.PrivateKey // Secure channel key
+	signKey *ecdsa.PrivateKey // Key signing with, whatever the derivation path
+	uid     []byte
+	secret  []byte // Pairing secret
+	pin     string
+
+	cardChallenge []byte
+	pairingKeys   [][]byte
+
+	encKey, macKey, iv []byte
+	verified           bool
+}
+
+func newSimulatedCard(pairingPassword, pin string) *simulatedCard {
+	scKey, _ := crypto.GenerateKey()
+	signKey, _ := crypto.GenerateKey()
+	uid := make([]byte, 16)
+	rand.Read(uid)
+
+	return &simulatedCard{scKey: scKey, signKey: signKey, uid: uid, secret: pairingSecret(pairingPassword), pin: pin}
+}
+
+func (c *simulatedCard) Disconnect() error { return nil }
+
+func (c *simulatedCard) Transmit(apdu []byte) ([]byte, error) {
+	cla, ins, p1, p2 := apdu[0], apdu[1], apdu[2], apdu[3]
+	var data []byte
+	if len(apdu) > 5 {
+		data = apdu[5 : 5+int(apdu[4])]
+	}
+	ok := []byte{0x90, 0x00}
+
+	switch ins {
+	case insSelect:
+		info := append(tlv(tagInstanceUID, c.uid), tlv(tagPublicKey, crypto.FromECDSAPub(&c.scKey.PublicKey))...)
+		info = append(info, tlv(tagKeyUID, make([]byte, 32))...)
+		return append(tlv(tagAppInfo, info), ok...), nil
+
+	case insPair:
+		if p1 == pairP1FirstStep {
+			c.cardChallenge = make([]byte, 32)
+			rand.Read(c.cardChallenge)
+			cryptogram := sha256.Sum256(append(append([]byte{}, c.secret...), data...))
+			return append(append(cryptogram[:], c.cardChallenge...), ok...), nil
+		}
+		if want := sha256.Sum256(append(append([]byte{}, c.secret...), c.cardChallenge...)); !bytes.Equal(data, want[:]) {
+			return []byte{0x69, 0x82}, nil
+		}
+		salt := make([]byte, 32)
+		rand.Read(salt)
+		key := sha256.Sum256(append(append([]byte{}, c.secret...), salt...))
+		c.pairingKeys = append(c.pairingKeys, key[:])
+		return append(append([]byte{byte(len(c.pairingKeys) - 1)}, salt...), ok...), nil
+
+	case insOpenSecureChannel:
+		if int(p1) >= len(c.pairingKeys) {
+			return []byte{0x6A, 0x86}, nil
+		}
+		client := crypto.ToECDSAPub(data)
+		secret := ecdh(c.scKey.D.Bytes(), client.X, client.Y)
+		salt, iv := make([]byte, 32), make([]byte, 16)
+		rand.Read(salt)
+		rand.Read(iv)
+		c.encKey, c.macKey = sessionKeys(secret, c.pairingKeys[p1], salt)
+		c.iv, c.verified = iv, false
+		return append(append(salt, iv...), ok...), nil
+	}
+	// Everything else goes over the secure channel
+	if c.iv == nil || len(data) < 32 {
+		return []byte{0x69, 0x85}, nil
+	}
+	mac, encrypted := data[:16], data[16:]
+	if want, _ := cbcMAC(c.macKey, []byte{cla, ins, p1, p2, byte(len(data))}, encrypted); !bytes.Equal(mac, want) {
+		return []byte{0x69, 0x82}, nil
+	}
+	plain, err := decryptCBC(c.encKey, c.iv, encrypted)
+	if err != nil {
+		return nil, err
+	}
+	c.iv = mac
+
+	response := c.execute(ins, plain)
+	encrypted, _ = encryptCBC(c.encKey, c.iv, response)
+	c.iv, _ = cbcMAC(c.macKey, []byte{byte(len(encrypted) + 16)}, encrypted)
+	return append(append(append([]byte{}, c.iv...), encrypted...), ok...), nil
+}
+
+// execute runs a command received over the secure channel.
+func (c *simulatedCard) execute(ins uint8, data []byte) []byte {
+	ok := []byte{0x90, 0x00}
+	switch ins {
+	case insMutuallyAuthenticate:
+		challenge := make([]byte, 32)
+		rand.Read(challenge)
+		return append(challenge, ok...)
+	case insVerifyPin:
+		if string(data) != c.pin {
+			return []byte{0x63, 0xC2}
+		}
+		c.verified = true
+		return ok
+	}
+	if !c.verified {
+		return []byte{0x69, 0x82}
+	}
+	pubkey := crypto.FromECDSAPub(&c.signKey.PublicKey)
+	switch ins {
+	case insDeriveKey:
+		return ok
+	case insExportKey:
+		return append(tlv(tagKeyPair, tlv(tagPublicKey, pubkey)), ok...)
+	case insSign:
+		sig, _ := crypto.Sign(data, c.signKey)
+		der := append(tlv(tagInteger, derInteger(sig[:32])), tlv(tagInteger, derInteger(sig[32:64]))...)
+		return append(tlv(tagSignature, append(tlv(tagPublicKey, pubkey), tlv(tagECDSASignature, der)...)), ok...)
+	}
+	return []byte{0x6D, 0x00}
+}
+
+// tlv encodes a value with its tag.
+func tlv(tag uint8, value []byte) []byte {
+	if len(value) < 0x80 {
+		return append([]byte{tag, byte(len(value))}, value...)
+	}
+	return append([]byte{tag, 0x81, byte(len(value))}, value...)
+}
+
+// derInteger encodes a big endian number as the value of a DER integer.
+func derInteger(b []byte) []byte {
+	b = bytes.TrimLeft(b, "\x00")
+	if len(b) == 0 || b[0]&0x80 != 0 {
+		b = append([]byte{0}, b...)
+	}
+	return b
+}
+
+func tmpHub(t *testing.T, dir string) *Hub {
+	hub := &Hub{pairingsFile: filepath.Join(dir, "smartcards.json"), pairings: make(map[string]*pairing)}
+	if err := hub.readPairings(); err != nil {
+		t.Fatalf("failed to read pairings: %v", err)
+	}
+	return hub
+}
+
+// Tests that a card is paired, unlocked and signed with, and that the pairing
+// is remembered across sessions.
+func TestWalletPairAndSign(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scwallet-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	card := newSimulatedCard("pairing password", "123456")
+	wallet, err := newWallet(tmpHub(t, dir), card)
+	if err != nil {
+		t.Fatalf("failed to select applet: %v", err)
+	}
+	if err := wallet.Open(""); err != ErrPairingPasswordNeeded {
+		t.Fatalf("opening unpaired card: have %v, want %v", err, ErrPairingPasswordNeeded)
+	}
+	if err := wallet.Open("wrong password"); err == nil {
+		t.Fatalf("paired with wrong pairing password")
+	}
+	if err := wallet.Open("pairing password"); err != ErrPINNeeded {
+		t.Fatalf("pairing: have %v, want %v", err, ErrPINNeeded)
+	}
+	if err := wallet.Open("000000"); err == nil {
+		t.Fatalf("unlocked with wrong PIN")
+	}
+	if err := wallet.Open("123456"); err != nil {
+		t.Fatalf("failed to unlock: %v", err)
+	}
+	address := crypto.PubkeyToAddress(card.signKey.PublicKey)
+	if accounts := wallet.Accounts(); len(accounts) != 1 || accounts[0] != address {
+		t.Fatalf("accounts mismatch: have %x, want [%x]", accounts, address)
+	}
+	hash := crypto.Keccak256([]byte("transaction"))
+	sig, err := wallet.SignHash(address, hash)
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+	pubkey, err := crypto.SigToPub(hash, sig)
+	if err != nil {
+		t.Fatalf("failed to recover signer: %v", err)
+	}
+	if signer := crypto.PubkeyToAddress(*pubkey); signer != address {
+		t.Errorf("signer mismatch: have %x, want %x", signer, address)
+	}
+	if _, err := wallet.SignHash(common.HexToAddress("0x01"), hash); err == nil {
+		t.Errorf("signed for foreign address")
+	}
+	wallet.Close()
+	if accounts := wallet.Accounts(); len(accounts) != 0 {
+		t.Errorf("closed wallet still has accounts: %x", accounts)
+	}
+	// Reinsert the card, the pairing should be remembered
+	wallet, err = newWallet(tmpHub(t, dir), card)
+	if err != nil {
+		t.Fatalf("failed to select applet: %v", err)
+	}
+	if status, _ := wallet.Status(); status != "Paired, PIN needed" {
+		t.Errorf("status mismatch: have %q, want %q", status, "Paired, PIN needed")
+	}
+	if err := wallet.Open("123456"); err != nil {
+		t.Fatalf("failed to unlock paired card: %v", err)
+	}
+	if _, err := wallet.SignHash(address, hash); err != nil {
+		t.Errorf("failed to sign after reopening: %v", err)
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package accounts
+
+import (
+	"errors"
+
+	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/event"
+)
+
+// ErrUnknownWallet is returned for a wallet URL no backend knows about.
+var ErrUnknownWallet = errors.New("unknown wallet")
+
+// Wallet is a hardware wallet, holding keys that never leave the device and
+// signing on it.
+type Wallet interface {
+	// URL uniquely identifies the wallet, surviving reconnections.
+	URL() string
+
+	// Status returns a textual status of the wallet, such as whether it still
+	// needs to be paired or unlocked, along with any failure talking to it.
+	Status() (string, error)
+
+	// Open establishes a session with the wallet, authenticating with the given
+	// passphrase (such as a PIN) if the device asks for one.
+	Open(passphrase string) error
+
+	// Close releases the session with the wallet, locking it again.
+	Close() error
+
+	// Accounts returns the addresses the open wallet can sign for.
+	Accounts() []common.Address
+
+	// SignHash signs hash with the key of the given address on the device,
+	// returning the signature in the [R || S || V] format used by crypto.Sign.
+	SignHash(addr common.Address, hash []byte) ([]byte, error)
+}
+
+// Backend is a source of hardware wallets, tracking the devices as they are
+// plugged in and out.
+type Backend interface {
+	// Wallets returns the wallets currently connected.
+	Wallets() []Wallet
+
+	// Subscribe creates a subscription delivering a WalletEvent each time a
+	// wallet arrives or is dropped.
+	Subscribe() *event.TypeMuxSubscription
+}
+
+// AddBackend registers a hardware wallet backend with the manager, whose wallets
+// become available for signing once opened, and whose wallet events are
+// forwarded to the subscribers of the manager.
+func (am *Manager) AddBackend(backend Backend) {
+	am.mu.Lock()
+	am.backends = append(am.backends, backend)
+	am.mu.Unlock()
+
+	sub, feed := backend.Subscribe(), am.feed
+	go func() {
+		for ev := range sub.Chan() {
+			feed.Post(ev.Data)
+		}
+	}()
+}
+
+// Wallets returns the hardware wallets of all registered backends.
+func (am *Manager) Wallets() []Wallet {
+	am.mu.RLock()
+	defer am.mu.RUnlock()
+
+	var wallets []Wallet
+	for _, backend := range am.backends {
+		wallets = append(wallets, backend.Wallets()...)
+	}
+	return wallets
+}
+
+// Wallet returns the connected hardware wallet with the given URL.
+func (am *Manager) Wallet(url string) (Wallet, error) {
+	for _, wallet := range am.Wallets() {
+		if wallet.URL() == url {
+			return wallet, nil
+		}
+	}
+	return nil, ErrUnknownWallet
+}
+
+// findWallet returns the open hardware wallet able to sign for addr, if any.
+func (am *Manager) findWallet(addr common.Address) Wallet {
+	for _, wallet := range am.Wallets() {
+		for _, account := range wallet.Accounts() {
+			if account == addr {
+				return wallet
+			}
+		}
+	}
+	return nil
+}
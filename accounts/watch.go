@@ -33,6 +33,10 @@ type watcher struct {
 	ev       chan notify.EventInfo
 	//evs      []notify.EventInfo
 	quit chan struct{}
+
+	// onChange, if set, is called outside of the cache lock with the key files
+	// added to or removed from the directory by others, after each reload.
+	onChange func(added, removed []Account)
 }
 
 func newWatcher(ac caching) *watcher {
@@ -103,8 +107,11 @@ func (w *watcher) loop() {
 		case <-debounce.C:
 			w.ac.muLock()
 			//w.evs = w.ac.reload(w.evs)
-			w.ac.reload()
+			added, removed := w.ac.reload()
 			w.ac.muUnlock()
+			if w.onChange != nil && len(added)+len(removed) > 0 {
+				w.onChange(added, removed)
+			}
 			if hadEvent {
 				debounce.Reset(debounceDuration)
 				inCycle, hadEvent = true, false
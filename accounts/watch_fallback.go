@@ -21,8 +21,11 @@
 
 package accounts
 
-type watcher struct{ running bool }
+type watcher struct {
+	running  bool
+	onChange func(added, removed []Account)
+}
 
-func newWatcher(*addrCache) *watcher { return new(watcher) }
-func (*watcher) start()              {}
-func (*watcher) close()              {}
+func newWatcher(caching) *watcher { return new(watcher) }
+func (*watcher) start()           {}
+func (*watcher) close()           {}
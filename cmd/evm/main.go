@@ -18,7 +18,10 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
 	"math/big"
 	"os"
@@ -94,6 +97,42 @@ var (
 		Name:  "create",
 		Usage: "indicates the action should be create rather than call",
 	}
+	PrestateFlag = cli.StringFlag{
+		Name:  "prestate",
+		Usage: "JSON file with the accounts to load into the state before execution",
+	}
+	ChainFlag = cli.StringFlag{
+		Name:  "chain",
+		Usage: "JSON chain configuration file providing the gas rules (default: Ellaism mainnet)",
+	}
+	BlockFlag = cli.StringFlag{
+		Name:  "block",
+		Usage: "block number of the execution, selecting the fork rules (default: block of the last fork, 0 for the genesis rules)",
+	}
+	SenderFlag = cli.StringFlag{
+		Name:  "sender",
+		Usage: "address of the caller",
+	}
+	ReceiverFlag = cli.StringFlag{
+		Name:  "receiver",
+		Usage: "address of the called contract",
+	}
+	TxFlag = cli.StringFlag{
+		Name:  "tx",
+		Usage: "RLP encoded signed transaction to apply instead of the given code",
+	}
+	JSONFlag = cli.BoolFlag{
+		Name:  "json",
+		Usage: "output the result as JSON",
+	}
+	NoMemoryFlag = cli.BoolFlag{
+		Name:  "nomemory",
+		Usage: "disable memory output in trace logs",
+	}
+	NoStackFlag = cli.BoolFlag{
+		Name:  "nostack",
+		Usage: "disable stack output in trace logs",
+	}
 )
 
 var app *cli.App
@@ -117,6 +156,15 @@ func init() {
 		ValueFlag,
 		DumpFlag,
 		InputFlag,
+		PrestateFlag,
+		ChainFlag,
+		BlockFlag,
+		SenderFlag,
+		ReceiverFlag,
+		TxFlag,
+		JSONFlag,
+		NoMemoryFlag,
+		NoStackFlag,
 	}
 }
 
@@ -126,19 +174,57 @@ func run(ctx *cli.Context) error {
 
 	db, _ := ethdb.NewMemDatabase()
 	statedb, _ := state.New(common.Hash{}, db)
-	sender := statedb.CreateAccount(common.StringToAddress("sender"))
+	if path := ctx.GlobalString(PrestateFlag.Name); path != "" {
+		if err := loadPrestate(statedb, path); err != nil {
+			log.Fatalf("failed to load prestate: %v", err)
+		}
+	}
+
+	chainConfig := core.DefaultConfigMainnet.ChainConfig
+	if path := ctx.GlobalString(ChainFlag.Name); path != "" {
+		config, err := core.ReadExternalChainConfigFromFile(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		chainConfig = config.ChainConfig
+	}
+	blockFlag := latestForkBlock(chainConfig)
+	if ctx.GlobalIsSet(BlockFlag.Name) {
+		if blockFlag, _ = new(big.Int).SetString(ctx.GlobalString(BlockFlag.Name), 0); blockFlag == nil {
+			log.Fatalf("malformed %s flag value %q", BlockFlag.Name, ctx.GlobalString(BlockFlag.Name))
+		}
+	}
+
+	senderAddr := common.StringToAddress("sender")
+	if ctx.GlobalIsSet(SenderFlag.Name) {
+		senderAddr = common.HexToAddress(ctx.GlobalString(SenderFlag.Name))
+	}
+	receiverAddr := common.StringToAddress("receiver")
+	if ctx.GlobalIsSet(ReceiverFlag.Name) {
+		receiverAddr = common.HexToAddress(ctx.GlobalString(ReceiverFlag.Name))
+	}
 
 	valueFlag, _ := new(big.Int).SetString(ctx.GlobalString(ValueFlag.Name), 0)
 	if valueFlag == nil {
 		log.Fatalf("malformed %s flag value %q", ValueFlag.Name, ctx.GlobalString(ValueFlag.Name))
 	}
-	vmenv := NewEnv(statedb, common.StringToAddress("evmuser"), valueFlag)
+	vmenv := NewEnv(statedb, chainConfig, blockFlag, common.StringToAddress("evmuser"), valueFlag)
+
+	var logger *vm.StructLogger
+	if ctx.GlobalBool(DebugFlag.Name) {
+		logger = vm.NewStructLogger(&vm.LogConfig{
+			DisableMemory: ctx.GlobalBool(NoMemoryFlag.Name),
+			DisableStack:  ctx.GlobalBool(NoStackFlag.Name),
+		})
+		vmenv.SetTracer(logger)
+	}
 
 	tstart := time.Now()
 
 	var (
-		ret []byte
-		err error
+		ret     []byte
+		gasUsed *big.Int
+		err     error
 	)
 
 	gasFlag, _ := new(big.Int).SetString(ctx.GlobalString(GasFlag.Name), 0)
@@ -149,16 +235,43 @@ func run(ctx *cli.Context) error {
 	if priceFlag == nil {
 		log.Fatalf("malformed %s flag value %q", PriceFlag.Name, ctx.GlobalString(PriceFlag.Name))
 	}
+	gas := new(big.Int).Set(gasFlag)
+
+	if txFlag := ctx.GlobalString(TxFlag.Name); txFlag != "" {
+		tx := new(types.Transaction)
+		if err := tx.UnmarshalBinary(common.FromHex(txFlag)); err != nil {
+			log.Fatalf("malformed %s flag value: %v", TxFlag.Name, err)
+		}
+		tx.SetSigner(chainConfig.GetSigner(blockFlag))
+		var from common.Address
+		if from, err = tx.From(); err != nil {
+			log.Fatalf("invalid transaction: %v", err)
+		}
+		vmenv.transactor = &from
+		statedb.StartRecord(tx.Hash(), common.Hash{}, 0)
+
+		ret, gasUsed, err = core.ApplyMessage(vmenv, tx, new(core.GasPool).AddGas(vmenv.GasLimit()))
+		if gasUsed == nil {
+			gasUsed = new(big.Int)
+		}
+	} else if ctx.GlobalBool(CreateFlag.Name) {
+		sender := statedb.GetOrNewStateObject(senderAddr)
 
-	if ctx.GlobalBool(CreateFlag.Name) {
 		input := append(common.Hex2Bytes(ctx.GlobalString(CodeFlag.Name)), common.Hex2Bytes(ctx.GlobalString(InputFlag.Name))...)
-		ret, _, err = vmenv.Create(sender, input, gasFlag, priceFlag, valueFlag)
+		ret, _, err = vmenv.Create(sender, input, gas, priceFlag, valueFlag)
+		gasUsed = new(big.Int).Sub(gasFlag, gas)
 	} else {
-		receiver := statedb.CreateAccount(common.StringToAddress("receiver"))
-
-		code := common.Hex2Bytes(ctx.GlobalString(CodeFlag.Name))
-		receiver.SetCode(crypto.Keccak256Hash(code), code)
-		ret, err = vmenv.Call(sender, receiver.Address(), common.Hex2Bytes(ctx.GlobalString(InputFlag.Name)), gasFlag, priceFlag, valueFlag)
+		sender := statedb.GetOrNewStateObject(senderAddr)
+		receiver := statedb.GetOrNewStateObject(receiverAddr)
+
+		// Run the code given on the command line or, if none, whatever code
+		// the receiver holds in the prestate
+		if ctx.GlobalIsSet(CodeFlag.Name) {
+			code := common.Hex2Bytes(ctx.GlobalString(CodeFlag.Name))
+			receiver.SetCode(crypto.Keccak256Hash(code), code)
+		}
+		ret, err = vmenv.Call(sender, receiver.Address(), common.Hex2Bytes(ctx.GlobalString(InputFlag.Name)), gas, priceFlag, valueFlag)
+		gasUsed = new(big.Int).Sub(gasFlag, gas)
 	}
 	vmdone := time.Since(tstart)
 
@@ -180,6 +293,37 @@ num gc:     %d
 `, mem.Alloc, mem.TotalAlloc, mem.Mallocs, mem.HeapAlloc, mem.HeapObjects, mem.NumGC)
 	}
 
+	logs := statedb.Logs()
+	if ctx.GlobalBool(JSONFlag.Name) {
+		if logs == nil {
+			logs = vm.Logs{}
+		}
+		result := map[string]interface{}{
+			"output":  fmt.Sprintf("0x%x", ret),
+			"gasUsed": fmt.Sprintf("%#x", gasUsed),
+			"logs":    logs,
+		}
+		if err != nil {
+			result["error"] = err.Error()
+		}
+		if logger != nil {
+			result["structLogs"] = logger.StructLogs()
+		}
+		out, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			log.Fatalf("failed to encode result: %v", err)
+		}
+		fmt.Println(string(out))
+		return nil
+	}
+
+	if logger != nil {
+		writeTrace(os.Stdout, logger.StructLogs())
+	}
+	for _, l := range logs {
+		fmt.Printf("LOG: %x %x 0x%x\n", l.Address, l.Topics, l.Data)
+	}
+	fmt.Printf("GAS: %v\n", gasUsed)
 	fmt.Printf("OUT: 0x%x", ret)
 	if err != nil {
 		fmt.Printf(" error: %v", err)
@@ -188,6 +332,76 @@ num gc:     %d
 	return nil
 }
 
+// latestForkBlock returns the block of the last fork of the chain, so that
+// code runs under the current rules unless a block is given.
+func latestForkBlock(config *core.ChainConfig) *big.Int {
+	number := new(big.Int)
+	for _, fork := range config.Forks {
+		if fork.Block != nil && fork.Block.Cmp(number) > 0 {
+			number.Set(fork.Block)
+		}
+	}
+	return number
+}
+
+// prestateAccount is an account of the prestate file. Balances may be given in
+// decimal or hex, code and storage keys and values in hex.
+type prestateAccount struct {
+	Balance string            `json:"balance"`
+	Nonce   uint64            `json:"nonce"`
+	Code    string            `json:"code"`
+	Storage map[string]string `json:"storage"`
+}
+
+// loadPrestate reads the accounts from the given JSON file, mapping addresses
+// to their state, and sets them in the state database.
+func loadPrestate(statedb *state.StateDB, path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var accounts map[string]prestateAccount
+	if err := json.Unmarshal(data, &accounts); err != nil {
+		return err
+	}
+	for addr, account := range accounts {
+		address := common.HexToAddress(addr)
+		if account.Balance != "" {
+			balance, ok := new(big.Int).SetString(account.Balance, 0)
+			if !ok {
+				return fmt.Errorf("malformed balance %q of account %s", account.Balance, addr)
+			}
+			statedb.AddBalance(address, balance)
+		}
+		statedb.SetNonce(address, account.Nonce)
+		statedb.SetCode(address, common.FromHex(account.Code))
+		for key, value := range account.Storage {
+			statedb.SetState(address, common.HexToHash(key), common.HexToHash(value))
+		}
+	}
+	return nil
+}
+
+// writeTrace writes the captured trace in human readable form.
+func writeTrace(w io.Writer, logs []vm.StructLog) {
+	for _, l := range logs {
+		fmt.Fprintf(w, "%-16v pc=%08d gas=%v cost=%v depth=%d\n", l.Op, l.Pc, l.Gas, l.GasCost, l.Depth)
+		if l.Stack != nil {
+			fmt.Fprintln(w, "Stack:")
+			for i := len(l.Stack) - 1; i >= 0; i-- {
+				fmt.Fprintf(w, "%08d  %x\n", len(l.Stack)-i-1, common.LeftPadBytes(l.Stack[i].Bytes(), 32))
+			}
+		}
+		if len(l.Memory) > 0 {
+			fmt.Fprintln(w, "Memory:")
+			for i := 0; i+32 <= len(l.Memory); i += 32 {
+				fmt.Fprintf(w, "%08x  %x\n", i, l.Memory[i:i+32])
+			}
+		}
+		fmt.Fprintln(w)
+	}
+}
+
 func main() {
 	if err := app.Run(os.Args); err != nil {
 		fmt.Fprintln(os.Stderr, err)
@@ -196,8 +410,10 @@ func main() {
 }
 
 type VMEnv struct {
-	state *state.StateDB
-	block *types.Block
+	state       *state.StateDB
+	block       *types.Block
+	chainConfig *core.ChainConfig
+	number      *big.Int
 
 	transactor *common.Address
 	value      *big.Int
@@ -206,46 +422,32 @@ type VMEnv struct {
 	Gas   *big.Int
 	time  *big.Int
 
+	tracer vm.Tracer
+
 	evm *vm.EVM
 }
 
-func NewEnv(state *state.StateDB, transactor common.Address, value *big.Int) *VMEnv {
+func NewEnv(state *state.StateDB, chainConfig *core.ChainConfig, number *big.Int, transactor common.Address, value *big.Int) *VMEnv {
 	env := &VMEnv{
-		state:      state,
-		transactor: &transactor,
-		value:      value,
-		time:       big.NewInt(time.Now().Unix()),
+		state:       state,
+		chainConfig: chainConfig,
+		number:      number,
+		transactor:  &transactor,
+		value:       value,
+		time:        big.NewInt(time.Now().Unix()),
 	}
 
 	env.evm = vm.New(env)
 	return env
 }
 
-// ruleSet implements vm.RuleSet and will always default to the homestead rule set.
-type ruleSet struct{}
-
-func (ruleSet) IsHomestead(*big.Int) bool { return true }
-
-func (ruleSet) GasTable(*big.Int) *vm.GasTable {
-	return &vm.GasTable{
-		ExtcodeSize:     big.NewInt(700),
-		ExtcodeCopy:     big.NewInt(700),
-		Balance:         big.NewInt(400),
-		SLoad:           big.NewInt(200),
-		Calls:           big.NewInt(700),
-		Suicide:         big.NewInt(5000),
-		ExpByte:         big.NewInt(10),
-		CreateBySuicide: big.NewInt(25000),
-	}
-}
-
-func (self *VMEnv) RuleSet() vm.RuleSet       { return ruleSet{} }
+func (self *VMEnv) RuleSet() vm.RuleSet       { return self.chainConfig }
 func (self *VMEnv) Vm() vm.Vm                 { return self.evm }
 func (self *VMEnv) Db() vm.Database           { return self.state }
 func (self *VMEnv) SnapshotDatabase() int     { return self.state.Snapshot() }
 func (self *VMEnv) RevertToSnapshot(snap int) { self.state.RevertToSnapshot(snap) }
 func (self *VMEnv) Origin() common.Address    { return *self.transactor }
-func (self *VMEnv) BlockNumber() *big.Int     { return new(big.Int) }
+func (self *VMEnv) BlockNumber() *big.Int     { return self.number }
 func (self *VMEnv) Coinbase() common.Address  { return *self.transactor }
 func (self *VMEnv) Time() *big.Int            { return self.time }
 func (self *VMEnv) Difficulty() *big.Int      { return common.Big1 }
@@ -253,14 +455,25 @@ func (self *VMEnv) BlockHash() []byte         { return make([]byte, 32) }
 func (self *VMEnv) Value() *big.Int           { return self.value }
 func (self *VMEnv) GasLimit() *big.Int        { return big.NewInt(1000000000) }
 func (self *VMEnv) VmType() vm.Type           { return vm.StdVmTy }
-func (self *VMEnv) Depth() int                { return 0 }
+func (self *VMEnv) Tracer() vm.Tracer         { return self.tracer }
+func (self *VMEnv) Depth() int                { return self.depth }
 func (self *VMEnv) SetDepth(i int)            { self.depth = i }
 func (self *VMEnv) GetHash(n uint64) common.Hash {
-	if self.block.Number().Cmp(big.NewInt(int64(n))) == 0 {
+	if self.block != nil && self.block.Number().Cmp(big.NewInt(int64(n))) == 0 {
 		return self.block.Hash()
 	}
 	return common.Hash{}
 }
+
+func (self *VMEnv) OpcodeMeter() vm.OpcodeMeter {
+	return nil
+}
+
+// SetTracer sets the tracer collecting execution traces.
+func (self *VMEnv) SetTracer(tracer vm.Tracer) {
+	self.tracer = tracer
+}
+
 func (self *VMEnv) AddLog(log *vm.Log) {
 	self.state.AddLog(log)
 }
@@ -287,3 +500,7 @@ func (self *VMEnv) DelegateCall(caller vm.ContractRef, addr common.Address, data
 func (self *VMEnv) Create(caller vm.ContractRef, data []byte, gas, price, value *big.Int) ([]byte, common.Address, error) {
 	return core.Create(self, caller, data, gas, price, value)
 }
+
+func (self *VMEnv) Create2(caller vm.ContractRef, data []byte, gas, price, value, salt *big.Int) ([]byte, common.Address, error) {
+	return core.Create2(self, caller, data, gas, price, value, salt)
+}
@@ -230,8 +230,13 @@ func unlockAccount(ctx *cli.Context, accman *accounts.Manager, address string, i
 	if err != nil {
 		log.Fatal("Could not list accounts: ", err)
 	}
-	for trials := 0; trials < 3; trials++ {
-		prompt := fmt.Sprintf("Unlocking account %s | Attempt %d/%d", address, trials+1, 3)
+	// Retrying is pointless if the password comes from a list
+	attempts := 3
+	if len(passwords) > 0 {
+		attempts = 1
+	}
+	for trials := 0; trials < attempts; trials++ {
+		prompt := fmt.Sprintf("Unlocking account %s | Attempt %d/%d", address, trials+1, attempts)
 		password := getPassPhrase(prompt, false, i, passwords)
 		err = accman.Unlock(account, password)
 		if err == nil {
@@ -76,7 +76,7 @@ func execAPI(ctx *cli.Context) error {
 func getClient(ctx *cli.Context) (rpc.Client, error) {
 	chainDir := MustMakeChainDataDir(ctx)
 	var uri = "ipc:" + node.DefaultIPCEndpoint(chainDir)
-	return rpc.NewClient(uri)
+	return MakeRPCClient(ctx, uri)
 }
 
 func validateArguments(ctx *cli.Context, client rpc.Client) error {
@@ -0,0 +1,239 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"gopkg.in/urfave/cli.v1"
+
+	"github.com/ellaism/go-ellaism/core"
+	"github.com/ellaism/go-ellaism/ethdb"
+	"github.com/ellaism/go-ellaism/metrics"
+)
+
+var benchCommand = cli.Command{
+	Name:  "bench",
+	Usage: "Benchmark block processing",
+	Description: `
+	The bench commands measure the performance of the node on real workloads,
+	to detect performance regressions, for instance in CI.
+		`,
+	Subcommands: []cli.Command{
+		{
+			Action: benchImport,
+			Name:   "import",
+			Usage:  "Import a chain segment into a fresh database and report the processing performance",
+			Description: `
+geth bench import [--json] [--min-gas-rate <gas/s>] <file>
+
+	Imports the RLP encoded blocks of the file given as argument, as written by
+	'geth export', into a fresh temporary database holding only the genesis block
+	of the chain selected with --chain, so the segment must start right after the
+	genesis block. The temporary database is removed afterwards.
+
+	Reports the blocks and gas processed per second, the time spent committing the
+	state tries and receipts to the database, and the write amplification of the
+	database: the bytes written to disk by the journal and the compactions, relative
+	to the bytes of the keys and values written. The --cache flags apply as usual;
+	use --fake-pow to leave proof-of-work verification out of the measurements.
+
+	With --min-gas-rate the command fails if less gas was processed per second.
+		`,
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "json",
+					Usage: "Print the results as JSON",
+				},
+				cli.Float64Flag{
+					Name:  "min-gas-rate",
+					Usage: "Fail if less gas than this is processed per second",
+				},
+			},
+		},
+	},
+}
+
+// benchImportResult is the performance of a benchmarked chain import.
+type benchImportResult struct {
+	Blocks       uint64  `json:"blocks"`
+	Transactions int     `json:"transactions"`
+	Gas          uint64  `json:"gas"`
+	Seconds      float64 `json:"seconds"`
+	BlockRate    float64 `json:"blocksPerSecond"`
+	GasRate      float64 `json:"gasPerSecond"`
+
+	CommitSeconds      float64 `json:"commitSeconds"`      // Time spent committing state and receipts
+	BytesWritten       int64   `json:"bytesWritten"`       // Bytes of the keys and values written
+	CompactionBytes    int64   `json:"compactionBytes"`    // Bytes written by the database compactions
+	WriteAmplification float64 `json:"writeAmplification"` // Bytes written to disk per byte written
+}
+
+func benchImport(ctx *cli.Context) error {
+	if ctx.NArg() != 1 {
+		return fmt.Errorf("%v: use: $ geth bench import [--json] [--min-gas-rate <gas/s>] <file>", ErrInvalidFlag)
+	}
+	sconf := mustMakeSufficientChainConfig(ctx)
+	if sconf.Genesis == nil {
+		return errors.New("the chain configuration has no genesis block")
+	}
+
+	dir, err := ioutil.TempDir("", "geth-bench")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	cache, _ := MakeCacheAllowance(ctx)
+	ldb, err := ethdb.NewLDBDatabase(filepath.Join(dir, "chaindata"), cache, MakeDatabaseHandles())
+	if err != nil {
+		return err
+	}
+	db := &countingDatabase{LDBDatabase: ldb}
+	defer db.Close()
+
+	if _, err := core.WriteGenesisBlock(db, sconf.Genesis); err != nil {
+		return err
+	}
+	chain := makeChain(ctx, sconf, db)
+
+	written, compacted, err := db.stats()
+	if err != nil {
+		return err
+	}
+	commit := metrics.ChainCommitTimer.Sum()
+	start := time.Now()
+	if err := ImportChain(chain, ctx.Args().First()); err != nil {
+		return err
+	}
+	elapsed := time.Since(start)
+
+	result := benchImportResult{
+		Blocks:        chain.CurrentBlock().NumberU64(),
+		Seconds:       elapsed.Seconds(),
+		CommitSeconds: time.Duration(metrics.ChainCommitTimer.Sum() - commit).Seconds(),
+	}
+	if result.Blocks == 0 {
+		return errors.New("no blocks imported")
+	}
+	for n := uint64(1); n <= result.Blocks; n++ {
+		block := chain.GetBlockByNumber(n)
+		result.Transactions += len(block.Transactions())
+		result.Gas += block.GasUsed().Uint64()
+	}
+	result.BlockRate = float64(result.Blocks) / result.Seconds
+	result.GasRate = float64(result.Gas) / result.Seconds
+
+	if result.BytesWritten, result.CompactionBytes, err = db.stats(); err != nil {
+		return err
+	}
+	result.BytesWritten -= written
+	result.CompactionBytes -= compacted
+	if result.BytesWritten > 0 {
+		result.WriteAmplification = float64(result.BytesWritten+result.CompactionBytes) / float64(result.BytesWritten)
+	}
+
+	if ctx.Bool("json") {
+		out, _ := json.MarshalIndent(result, "", "  ")
+		fmt.Println(string(out))
+	} else {
+		fmt.Printf("Imported %d blocks with %d transactions and %d gas in %v\n", result.Blocks, result.Transactions, result.Gas, elapsed)
+		fmt.Printf("Blocks/s:            %.2f\n", result.BlockRate)
+		fmt.Printf("Gas/s:               %.0f\n", result.GasRate)
+		fmt.Printf("Commit time:         %.3fs (%.1f%%)\n", result.CommitSeconds, 100*result.CommitSeconds/result.Seconds)
+		fmt.Printf("Written:             %.2f MB, compactions %.2f MB\n", float64(result.BytesWritten)/1048576, float64(result.CompactionBytes)/1048576)
+		fmt.Printf("Write amplification: %.2f\n", result.WriteAmplification)
+	}
+	if min := ctx.Float64("min-gas-rate"); result.GasRate < min {
+		return fmt.Errorf("processed %.0f gas/s, less than the minimum of %.0f", result.GasRate, min)
+	}
+	return nil
+}
+
+// countingDatabase is a LevelDB database counting the bytes of the keys and
+// values written to it.
+type countingDatabase struct {
+	*ethdb.LDBDatabase
+	written int64 // Accessed atomically
+}
+
+func (db *countingDatabase) Put(key []byte, value []byte) error {
+	atomic.AddInt64(&db.written, int64(len(key)+len(value)))
+	return db.LDBDatabase.Put(key, value)
+}
+
+func (db *countingDatabase) NewBatch() ethdb.Batch {
+	return &countingBatch{Batch: db.LDBDatabase.NewBatch(), db: db}
+}
+
+// stats returns the bytes of the keys and values written to the database and
+// the bytes written by its compactions.
+func (db *countingDatabase) stats() (written, compacted int64, err error) {
+	stats, err := db.LDB().GetProperty("leveldb.stats")
+	if err != nil {
+		return 0, 0, err
+	}
+	compacted, err = parseCompactionWrites(stats)
+	return atomic.LoadInt64(&db.written), compacted, err
+}
+
+// countingBatch is a batch of a countingDatabase, counting its writes once
+// written.
+type countingBatch struct {
+	ethdb.Batch
+	db   *countingDatabase
+	size int64
+}
+
+func (b *countingBatch) Put(key []byte, value []byte) error {
+	b.size += int64(len(key) + len(value))
+	return b.Batch.Put(key, value)
+}
+
+func (b *countingBatch) Write() error {
+	atomic.AddInt64(&b.db.written, b.size)
+	return b.Batch.Write()
+}
+
+// parseCompactionWrites sums the Write(MB) column of the compaction table of
+// the LevelDB stats property, returning the bytes written by compactions.
+func parseCompactionWrites(stats string) (int64, error) {
+	var mb float64
+	for _, line := range strings.Split(stats, "\n") {
+		fields := strings.Split(line, "|")
+		if len(fields) != 6 {
+			continue
+		}
+		if _, err := strconv.Atoi(strings.TrimSpace(fields[0])); err != nil {
+			continue // header line
+		}
+		write, err := strconv.ParseFloat(strings.TrimSpace(fields[5]), 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid leveldb stats line %q: %v", line, err)
+		}
+		mb += write
+	}
+	return int64(mb * 1048576), nil
+}
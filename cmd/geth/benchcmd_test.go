@@ -0,0 +1,59 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ellaism/go-ellaism/ethdb"
+)
+
+func TestParseCompactionWrites(t *testing.T) {
+	stats := "Compactions\n" +
+		" Level |   Tables   |    Size(MB)   |    Time(sec)  |    Read(MB)   |   Write(MB)\n" +
+		"-------+------------+---------------+---------------+---------------+---------------\n" +
+		"   0   |          2 |       1.50000 |       0.10000 |       0.00000 |       1.50000\n" +
+		"   1   |          3 |       2.00000 |       0.20000 |       1.00000 |       2.50000\n"
+	written, err := parseCompactionWrites(stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if written != 4*1048576 {
+		t.Errorf("compaction writes: have %d, want %d", written, 4*1048576)
+	}
+	if _, err := parseCompactionWrites(stats + "   2   |          1 |       1.00000 |       0.10000 |       0.00000 |      invalid\n"); err == nil {
+		t.Error("invalid stats line accepted")
+	}
+}
+
+func TestCountingDatabase(t *testing.T) {
+	dir, err := ioutil.TempDir("", "geth-bench-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ldb, err := ethdb.NewLDBDatabase(filepath.Join(dir, "chaindata"), 16, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := &countingDatabase{LDBDatabase: ldb}
+	defer db.Close()
+
+	db.Put([]byte("key"), []byte("value"))
+	batch := db.NewBatch()
+	batch.Put([]byte("batched"), []byte("value"))
+	if written, _, err := db.stats(); err != nil || written != 8 {
+		t.Fatalf("bytes written before batch write: have %d (%v), want 8", written, err)
+	}
+	if err := batch.Write(); err != nil {
+		t.Fatal(err)
+	}
+	if written, _, err := db.stats(); err != nil || written != 20 {
+		t.Fatalf("bytes written: have %d (%v), want 20", written, err)
+	}
+	if value, err := db.Get([]byte("batched")); err != nil || string(value) != "value" {
+		t.Errorf("batched value: have %q (%v), want %q", value, err, "value")
+	}
+}
@@ -18,7 +18,10 @@ package main
 
 import (
 	"bufio"
+	"compress/gzip"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
@@ -31,6 +34,8 @@ import (
 	"github.com/ellaism/go-ellaism/core"
 	"github.com/ellaism/go-ellaism/core/state"
 	"github.com/ellaism/go-ellaism/core/types"
+	"github.com/ellaism/go-ellaism/ethdb"
+	"github.com/ellaism/go-ellaism/logger"
 	"github.com/ellaism/go-ellaism/logger/glog"
 	"gopkg.in/urfave/cli.v1"
 )
@@ -52,6 +57,18 @@ var (
 	if already existing.
 		`,
 	}
+	exportPreimagesCommand = cli.Command{
+		Action: exportPreimages,
+		Name:   "export-preimages",
+		Usage:  "Export the recorded keccak preimages of state trie keys",
+		Description: `
+	Export-preimages writes the keccak preimages of the state trie keys recorded in
+	the database, the addresses of accounts and the storage slots, to the file given
+	as argument, as a stream of RLP strings. The file is gzipped if its name ends
+	with .gz. Hashing each preimage gives the trie key it maps back to. Preimages
+	are recorded unless the node runs with --cache.preimages=false.
+		`,
+	}
 	upgradedbCommand = cli.Command{
 		Action:  upgradeDB,
 		Name:    "upgrade-db",
@@ -73,6 +90,87 @@ var (
 	Use "$ geth dump 0" to dump the genesis block.
 		`,
 	}
+	replayCommand = cli.Command{
+		Action: replay,
+		Name:   "replay",
+		Usage:  "Re-execute imported blocks and compare the results with the stored ones",
+		Description: `
+	Replay re-executes already imported blocks from the state of their parents and
+	compares the resulting gas usage, logs bloom, receipts and state root against the
+	values stored in the database, in order to detect database corruption or
+	nondeterministic block processing.
+	Requires a first argument of the block number to replay. An optional second
+	argument sets the last block of a range to replay.
+		`,
+	}
+	verifyChainCommand = cli.Command{
+		Action:  verifyChain,
+		Name:    "verify-chain",
+		Aliases: []string{"verifychain"},
+		Usage:   "Check the integrity of the stored blockchain data",
+		Description: `
+	Verify-chain re-checks the stored canonical blocks, for operators suspecting disk
+	corruption: the proof of work of their headers and the links between them, their
+	bodies against the transaction and uncle roots of the headers, their receipts
+	against the receipt roots and logs blooms, and the transaction lookups.
+	With --reexec the blocks are also re-executed from the state of their parents to
+	confirm their state roots, which requires the state of the parents.
+	Optional first and second arguments set the first and last block to check, by
+	default the genesis and head blocks. With --progress the last block checked is
+	saved to the given file, and a run without a first block resumes after it.
+		`,
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "reexec",
+				Usage: "Re-execute the blocks to confirm their state roots",
+			},
+			cli.StringFlag{
+				Name:  "progress",
+				Usage: "File saving the last block checked, to resume from",
+			},
+		},
+	}
+	exportSnapshotCommand = cli.Command{
+		Action: exportSnapshot,
+		Name:   "export-snapshot",
+		Usage:  "Export a state snapshot of the head block for fast bootstrapping",
+		Description: `
+	Export-snapshot writes the state of the head block, along with the most recent
+	blocks and their receipts, to the file given as argument. The snapshot is
+	gzipped if the file name ends with .gz. A fresh node loads it with
+	import-snapshot, without replaying or syncing the chain before the snapshot.
+	The hash of the snapshot head block is printed, for users to check the
+	snapshot against.
+		`,
+		Flags: []cli.Flag{
+			cli.IntFlag{
+				Name:  "blocks",
+				Usage: "Number of recent blocks to include",
+				Value: core.DefaultSnapshotBlocks,
+			},
+		},
+	}
+	importSnapshotCommand = cli.Command{
+		Action: importSnapshot,
+		Name:   "import-snapshot",
+		Usage:  "Bootstrap a fresh node from a state snapshot",
+		Description: `
+	Import-snapshot loads a snapshot written by export-snapshot from the file given
+	as argument into a node holding no blocks beyond genesis, gunzipping it if the
+	file name ends with .gz. The blocks of the snapshot are checked for their proof
+	of work, links and roots, and the state is checked to be complete for the state
+	root of the snapshot head block, which then becomes the head of the chain.
+	Since a snapshot could hold a forged chain, pass --hash with the hash of the
+	head block published by a trusted source; without it, check the printed hash
+	yourself before relying on the node.
+		`,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "hash",
+				Usage: "Trusted hash the snapshot head block must have",
+			},
+		},
+	}
 	dumpChainConfigCommand = cli.Command{
 		Action:  dumpChainConfig,
 		Name:    "dump-chain-config",
@@ -171,6 +269,22 @@ func exportChain(ctx *cli.Context) error {
 	return nil
 }
 
+func exportPreimages(ctx *cli.Context) error {
+	if ctx.NArg() != 1 {
+		return fmt.Errorf("%v: use: $ geth export-preimages <file>", ErrInvalidFlag)
+	}
+	chainDb := MakeChainDatabase(ctx)
+	defer chainDb.Close()
+
+	start := time.Now()
+	n, err := ExportPreimages(chainDb.(*ethdb.LDBDatabase), ctx.Args().First())
+	if err != nil {
+		return fmt.Errorf("preimage export failed: %v", err)
+	}
+	glog.D(logger.Warn).Infof("Exported %d preimages in %v", n, time.Since(start))
+	return nil
+}
+
 func removeDB(ctx *cli.Context) error {
 	confirm, err := console.Stdin.PromptConfirm("Remove local database?")
 	if err != nil {
@@ -317,6 +431,205 @@ func dump(ctx *cli.Context) error {
 	return nil
 }
 
+func replay(ctx *cli.Context) error {
+	if ctx.NArg() < 1 || ctx.NArg() > 2 {
+		return fmt.Errorf("%v: use: $ geth replay <first> [<last>]", ErrInvalidFlag)
+	}
+	first, err := strconv.ParseUint(ctx.Args()[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("%v: invalid block number %q", ErrInvalidFlag, ctx.Args()[0])
+	}
+	last := first
+	if ctx.NArg() == 2 {
+		if last, err = strconv.ParseUint(ctx.Args()[1], 10, 64); err != nil || last < first {
+			return fmt.Errorf("%v: invalid last block number %q", ErrInvalidFlag, ctx.Args()[1])
+		}
+	}
+
+	chain, chainDb := MakeChain(ctx)
+	defer chainDb.Close()
+
+	inconsistent := 0
+	for n := first; n <= last; n++ {
+		block := chain.GetBlockByNumber(n)
+		if block == nil {
+			return fmt.Errorf("block #%d not found", n)
+		}
+		result, err := chain.ReplayBlock(block.Hash())
+		if err != nil {
+			return err
+		}
+		if len(result.Mismatches) == 0 {
+			glog.D(logger.Warn).Infof("Block #%d [%x…] replayed consistently", n, block.Hash().Bytes()[:4])
+			continue
+		}
+		inconsistent++
+		glog.D(logger.Error).Errorf("Block #%d [%x…] replayed inconsistently:", n, block.Hash().Bytes()[:4])
+		for _, mismatch := range result.Mismatches {
+			glog.D(logger.Error).Errorf("  %s", mismatch)
+		}
+	}
+	if inconsistent > 0 {
+		return fmt.Errorf("%d of %d block(s) replayed inconsistently", inconsistent, last-first+1)
+	}
+	return nil
+}
+
+// verifyProgressInterval is how often verify-chain reports and saves its progress.
+const verifyProgressInterval = 3 * time.Second
+
+func verifyChain(ctx *cli.Context) error {
+	if ctx.NArg() > 2 {
+		return fmt.Errorf("%v: use: $ geth verify-chain [--reexec] [--progress <file>] [<first> [<last>]]", ErrInvalidFlag)
+	}
+	progress := ctx.String("progress")
+
+	var first uint64
+	if ctx.NArg() > 0 {
+		var err error
+		if first, err = strconv.ParseUint(ctx.Args()[0], 10, 64); err != nil {
+			return fmt.Errorf("%v: invalid block number %q", ErrInvalidFlag, ctx.Args()[0])
+		}
+	} else if progress != "" {
+		data, err := ioutil.ReadFile(progress)
+		switch {
+		case err == nil:
+			done, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid progress file %s: %v", progress, err)
+			}
+			first = done + 1
+			glog.D(logger.Warn).Infof("Resuming verification after block #%d", done)
+		case !os.IsNotExist(err):
+			return err
+		}
+	}
+
+	chain, chainDb := MakeChain(ctx)
+	defer chainDb.Close()
+
+	last := chain.CurrentBlock().NumberU64()
+	if ctx.NArg() == 2 {
+		var err error
+		if last, err = strconv.ParseUint(ctx.Args()[1], 10, 64); err != nil || last < first {
+			return fmt.Errorf("%v: invalid last block number %q", ErrInvalidFlag, ctx.Args()[1])
+		}
+	}
+	if first > last {
+		if ctx.NArg() > 0 {
+			return fmt.Errorf("block #%d not found", first)
+		}
+		glog.D(logger.Warn).Infof("Blocks verified through the head block #%d already", last)
+		return nil
+	}
+
+	inconsistent := 0
+	reported := time.Now()
+	for n := first; n <= last; n++ {
+		result, err := chain.VerifyBlock(n, ctx.Bool("reexec"))
+		if err != nil {
+			return err
+		}
+		if len(result.Problems) > 0 {
+			inconsistent++
+			glog.D(logger.Error).Errorf("Block #%d [%x…] is inconsistent:", n, result.Hash.Bytes()[:4])
+			for _, problem := range result.Problems {
+				glog.D(logger.Error).Errorf("  %s", problem)
+			}
+		}
+		if n == last || time.Since(reported) >= verifyProgressInterval {
+			glog.D(logger.Warn).Infof("Verified blocks #%d to #%d of #%d, %d inconsistent", first, n, last, inconsistent)
+			if progress != "" {
+				if err := ioutil.WriteFile(progress, []byte(fmt.Sprintf("%d\n", n)), 0644); err != nil {
+					return err
+				}
+			}
+			reported = time.Now()
+		}
+	}
+	if inconsistent > 0 {
+		return fmt.Errorf("%d of %d block(s) inconsistent", inconsistent, last-first+1)
+	}
+	return nil
+}
+
+func exportSnapshot(ctx *cli.Context) error {
+	if ctx.NArg() != 1 {
+		return fmt.Errorf("%v: use: $ geth export-snapshot [--blocks <n>] <file>", ErrInvalidFlag)
+	}
+	if ctx.Int("blocks") < core.MinSnapshotBlocks {
+		return fmt.Errorf("%v: --blocks: must be at least %d", ErrInvalidFlag, core.MinSnapshotBlocks)
+	}
+	chain, chainDb := MakeChain(ctx)
+	defer chainDb.Close()
+
+	fn := ctx.Args().First()
+	fh, err := os.OpenFile(fn, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	var w io.Writer = fh
+	if strings.HasSuffix(fn, ".gz") {
+		zw := gzip.NewWriter(fh)
+		defer zw.Close()
+		w = zw
+	}
+	start := time.Now()
+	glog.D(logger.Warn).Infof("Exporting snapshot to %s (this may take a while)...", fn)
+	header, err := chain.ExportSnapshot(w, uint64(ctx.Int("blocks")))
+	if err != nil {
+		os.Remove(fn)
+		return fmt.Errorf("snapshot export failed: %v", err)
+	}
+	glog.D(logger.Warn).Infof("Exported snapshot of block #%d [%x] with %d blocks in %v", header.Number, header.Hash, header.Blocks, time.Since(start))
+	return nil
+}
+
+func importSnapshot(ctx *cli.Context) error {
+	if ctx.NArg() != 1 {
+		return fmt.Errorf("%v: use: $ geth import-snapshot [--hash <block hash>] <file>", ErrInvalidFlag)
+	}
+	var trusted common.Hash
+	if hash := ctx.String("hash"); hash != "" {
+		if len(common.FromHex(hash)) != common.HashLength {
+			return fmt.Errorf("%v: --hash: invalid block hash %q", ErrInvalidFlag, hash)
+		}
+		trusted = common.HexToHash(hash)
+	}
+	chain, chainDb := MakeChain(ctx)
+	defer chainDb.Close()
+
+	fn := ctx.Args().First()
+	fh, err := os.Open(fn)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	var r io.Reader = fh
+	if strings.HasSuffix(fn, ".gz") {
+		zr, err := gzip.NewReader(fh)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		r = zr
+	}
+	start := time.Now()
+	glog.D(logger.Warn).Infof("Importing snapshot from %s (this may take a while)...", fn)
+	header, err := chain.ImportSnapshot(r, trusted)
+	if err != nil {
+		return fmt.Errorf("snapshot import failed: %v", err)
+	}
+	glog.D(logger.Warn).Infof("Imported snapshot of block #%d [%x] in %v", header.Number, header.Hash, time.Since(start))
+	if trusted == (common.Hash{}) {
+		glog.D(logger.Warn).Warnf("Check the hash of block #%d against a trusted source before relying on the node", header.Number)
+	}
+	return nil
+}
+
 // hashish returns true for strings that look like hashes.
 func hashish(x string) bool {
 	_, err := strconv.Atoi(x)
@@ -18,19 +18,23 @@ package main
 
 import (
 	"bufio"
+	"compress/gzip"
 	"errors"
 	"fmt"
 	"github.com/ellaism/go-ellaism/core"
 	"github.com/ellaism/go-ellaism/core/state"
 	"github.com/ellaism/go-ellaism/core/types"
 	"github.com/ellaism/go-ellaism/eth"
+	"github.com/ellaism/go-ellaism/ethdb"
 	"github.com/ellaism/go-ellaism/event"
 	"github.com/ellaism/go-ellaism/logger"
 	"github.com/ellaism/go-ellaism/logger/glog"
 	"github.com/ellaism/go-ellaism/node"
 	"github.com/ellaism/go-ellaism/pow"
 	"github.com/ellaism/go-ellaism/rlp"
+	"github.com/ellaism/go-ellaism/trie"
 	"github.com/ethereumproject/ethash"
+	"github.com/syndtr/goleveldb/leveldb/util"
 	"gopkg.in/urfave/cli.v1"
 	"io"
 	"io/ioutil"
@@ -227,6 +231,36 @@ func ExportAppendChain(blockchain *core.BlockChain, fn string, first uint64, las
 	return nil
 }
 
+// ExportPreimages writes the recorded keccak preimages of state trie keys to
+// the given file as a stream of RLP strings, gzipped if the file name ends with
+// .gz, and returns how many were written.
+func ExportPreimages(db *ethdb.LDBDatabase, fn string) (int, error) {
+	glog.D(logger.Warn).Infoln("Exporting preimages to", fn, "(this may take a while)...")
+	fh, err := os.OpenFile(fn, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.ModePerm)
+	if err != nil {
+		return 0, err
+	}
+	defer fh.Close()
+
+	var w io.Writer = fh
+	if strings.HasSuffix(fn, ".gz") {
+		zw := gzip.NewWriter(fh)
+		defer zw.Close()
+		w = zw
+	}
+	it := db.LDB().NewIterator(util.BytesPrefix(trie.SecureKeyPrefix), nil)
+	defer it.Release()
+
+	n := 0
+	for it.Next() {
+		if err := rlp.Encode(w, it.Value()); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, it.Error()
+}
+
 func withLineBreak(s string) string {
 	return s + "\n"
 }
@@ -329,6 +363,8 @@ func formatEthConfigPretty(ethConfig *eth.Config) (s []string) {
 	ss = append(ss, printable{0, "Blockchain version", ethConfig.BlockChainVersion})
 	// DatabaseCache
 	ss = append(ss, printable{0, "Database cache (MB)", ethConfig.DatabaseCache})
+	// TrieCache
+	ss = append(ss, printable{0, "Trie cache (MB)", ethConfig.TrieCache})
 	// DatabaseHandles
 	ss = append(ss, printable{0, "Database file handles", ethConfig.DatabaseHandles})
 	// NatSpec?
@@ -583,8 +619,8 @@ func rollback(ctx *cli.Context) error {
 func dumpChainConfig(ctx *cli.Context) error {
 
 	chainIdentity := mustMakeChainIdentity(ctx)
-	if !(chainIdentitiesMain[chainIdentity] || chainIdentitiesMorden[chainIdentity]) {
-		glog.Fatal("Dump config should only be used with default chain configurations (mainnet or morden).")
+	if core.BundledChainConfig(chainIdentity) == nil {
+		glog.Fatal("Dump config should only be used with default chain configurations (mainnet, morden or dev).")
 	}
 
 	glog.D(logger.Warn).Infof("Dumping configuration for: %v", chainIdentity)
@@ -614,15 +650,20 @@ func dumpChainConfig(ctx *cli.Context) error {
 		glog.Fatalf("'%v' must be a directory", fb)
 	}
 
-	// Implicitly favor Morden because it is a smaller, simpler configuration,
-	// so I expect it to be used more frequently than mainnet.
-	genesisDump := core.DefaultConfigMorden.Genesis
-	netId := 2
-	stateConf := &core.StateConfig{StartingNonce: state.DefaultTestnetStartingNonce}
-	if !chainIsMorden(ctx) {
-		genesisDump = core.DefaultConfigMainnet.Genesis
+	bundled := mustMakeBundledChainConfig(ctx)
+	genesisDump := bundled.Genesis
+	netId := bundled.Network
+	stateConf := bundled.State
+	consensus := bundled.Consensus
+	switch {
+	case chainIsMorden(ctx):
+		netId = 2
+		stateConf = &core.StateConfig{StartingNonce: state.DefaultTestnetStartingNonce}
+		consensus = "ethash"
+	case chainIdentitiesMain[chainIdentity]:
 		netId = eth.NetworkId
 		stateConf = nil
+		consensus = "ethash"
 	}
 
 	chainConfig := MustMakeChainConfigFromDefaults(ctx)
@@ -636,7 +677,7 @@ func dumpChainConfig(ctx *cli.Context) error {
 		Name:        mustMakeChainConfigNameDefaulty(ctx),
 		Network:     netId,
 		State:       stateConf,
-		Consensus:   "ethash",
+		Consensus:   consensus,
 		Genesis:     genesisDump,
 		ChainConfig: chainConfig.SortForks(), // get current/contextualized chain config
 		Bootstrap:   nodes,
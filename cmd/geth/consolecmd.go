@@ -23,7 +23,6 @@ import (
 
 	"github.com/ellaism/go-ellaism/console"
 	"github.com/ellaism/go-ellaism/node"
-	"github.com/ellaism/go-ellaism/rpc"
 	"gopkg.in/urfave/cli.v1"
 )
 
@@ -130,7 +129,7 @@ func remoteConsole(ctx *cli.Context) error {
 	if ctx.Args().Present() {
 		uri = ctx.Args().First()
 	}
-	client, err := rpc.NewClient(uri)
+	client, err := MakeRPCClient(ctx, uri)
 	if err != nil {
 		log.Fatal("attach to remote geth: ", err)
 	}
@@ -0,0 +1,26 @@
+#!/usr/bin/env bats
+
+: ${GETH_CMD:=$GOPATH/bin/geth}
+
+setup() {
+	DATA_DIR=`mktemp -d`
+	cp -a $BATS_TEST_DIRNAME/../../cmd/geth/testdata/testdatadir/. $DATA_DIR/
+}
+
+teardown() {
+	rm -fr $DATA_DIR
+}
+
+@test "export-preimages | exports recorded preimages" {
+	run $GETH_CMD --datadir $DATA_DIR export-preimages $DATA_DIR/preimages.gz
+	echo "$output"
+	[ "$status" -eq 0 ]
+	[[ "$output" == *"Exported 8936 preimages"* ]]
+	[ -s $DATA_DIR/preimages.gz ]
+}
+
+@test "export-preimages | requires a file" {
+	run $GETH_CMD --datadir $DATA_DIR export-preimages
+	echo "$output"
+	[ "$status" -ne 0 ]
+}
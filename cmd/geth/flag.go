@@ -30,8 +30,8 @@ import (
 
 	"errors"
 
-	"github.com/ethereumproject/ethash"
 	"github.com/ellaism/go-ellaism/accounts"
+	"github.com/ellaism/go-ellaism/accounts/scwallet"
 	"github.com/ellaism/go-ellaism/common"
 	"github.com/ellaism/go-ellaism/core"
 	"github.com/ellaism/go-ellaism/core/state"
@@ -47,7 +47,10 @@ import (
 	"github.com/ellaism/go-ellaism/p2p/discover"
 	"github.com/ellaism/go-ellaism/p2p/nat"
 	"github.com/ellaism/go-ellaism/pow"
+	"github.com/ellaism/go-ellaism/rpc"
+	"github.com/ellaism/go-ellaism/trie"
 	"github.com/ellaism/go-ellaism/whisper"
+	"github.com/ethereumproject/ethash"
 	"gopkg.in/urfave/cli.v1"
 )
 
@@ -105,6 +108,7 @@ var (
 
 	cacheChainIdentity string
 	cacheChainConfig   *core.SufficientChainConfig
+	dataDirMigrated    bool
 )
 
 // chainIsMorden allows either
@@ -151,7 +155,7 @@ func copyChainConfigFileToChainDataDir(ctx *cli.Context, identity, configFilePat
 
 // getChainIdentity parses --chain and --testnet (legacy) flags.
 // It will fatal if finds notok value.
-// It returns one of valid strings: ["mainnet", "morden", or --chain="flaggedCustom"]
+// It returns one of valid strings: ["mainnet", "testnet", "dev", or --chain="flaggedCustom"]
 func mustMakeChainIdentity(ctx *cli.Context) (identity string) {
 
 	if cacheChainIdentity != "" {
@@ -179,8 +183,8 @@ func mustMakeChainIdentity(ctx *cli.Context) (identity string) {
 	}
 	// If --chain is in use.
 	if chainFlagVal := ctx.GlobalString(aliasableName(ChainIdentityFlag.Name, ctx)); chainFlagVal != "" {
-		if chainIdentitiesMain[chainFlagVal] {
-			identity = core.DefaultConfigMainnet.Identity
+		if bundled := core.BundledChainConfig(chainFlagVal); bundled != nil {
+			identity = bundled.Identity
 			return identity
 		}
 		// Check for disallowed values.
@@ -202,7 +206,7 @@ func mustMakeChainIdentity(ctx *cli.Context) (identity string) {
 				}
 				// In edge case of using a config file for default configuration (decided by 'identity'),
 				// set global context and override config file.
-				if chainIdentitiesMorden[c.Identity] || chainIdentitiesMain[c.Identity] {
+				if core.BundledChainConfig(c.Identity) != nil {
 					if e := ctx.Set(aliasableName(ChainIdentityFlag.Name, ctx), c.Identity); e != nil {
 						glog.Fatalf("Could not set global context chain identity to morden, error: %v", e)
 					}
@@ -225,16 +229,24 @@ func mustMakeChainIdentity(ctx *cli.Context) (identity string) {
 	return identity
 }
 
-// mustMakeChainConfigNameDefaulty gets mainnet or testnet defaults if in use.
-// _If a custom net is in use, it echoes the name of the ChainConfigID._
+// mustMakeChainConfigNameDefaulty gets the name of the bundled chain configuration in use.
 // It is intended to be a human-readable name for a chain configuration.
 // - It should only be called in reference to default configuration (name will be configured
 // separately through external JSON config otherwise).
 func mustMakeChainConfigNameDefaulty(ctx *cli.Context) string {
+	return mustMakeBundledChainConfig(ctx).Name
+}
+
+// mustMakeBundledChainConfig gets the bundled chain configuration selected by --chain
+// or --testnet (legacy), falling back to mainnet if a custom chain is in use.
+func mustMakeBundledChainConfig(ctx *cli.Context) *core.SufficientChainConfig {
 	if chainIsMorden(ctx) {
-		return core.DefaultConfigMorden.Name
+		return core.DefaultConfigMorden
+	}
+	if bundled := core.BundledChainConfig(mustMakeChainIdentity(ctx)); bundled != nil {
+		return bundled
 	}
-	return core.DefaultConfigMainnet.Name
+	return core.DefaultConfigMainnet
 }
 
 // mustMakeDataDir retrieves the currently requested data directory, terminating
@@ -278,6 +290,28 @@ func MakeIPCPath(ctx *cli.Context) string {
 	return ctx.GlobalString(aliasableName(IPCPathFlag.Name, ctx))
 }
 
+// MakeRPCClient connects to the node at the given endpoint, authenticating IPC
+// connections with the token of the node data directory if --ipc-auth is set.
+func MakeRPCClient(ctx *cli.Context, uri string) (rpc.Client, error) {
+	if !strings.HasPrefix(uri, "ipc:") || !ctx.GlobalBool(IPCAuthFlag.Name) {
+		return rpc.NewClient(uri)
+	}
+	token, err := node.ReadIPCAuthToken(MustMakeChainDataDir(ctx), MakeNodeKeyPassword(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("IPC auth token: %v", err)
+	}
+	return rpc.NewIPCClient(strings.TrimPrefix(uri, "ipc:"), token)
+}
+
+// MakeIPCMode parses the file mode of the IPC socket set on the command line.
+func MakeIPCMode(ctx *cli.Context) os.FileMode {
+	mode, err := strconv.ParseUint(ctx.GlobalString(IPCModeFlag.Name), 8, 32)
+	if err != nil || os.FileMode(mode)&^os.ModePerm != 0 {
+		glog.Fatalf("%v: --%v: invalid octal file mode %q", ErrInvalidFlag, IPCModeFlag.Name, ctx.GlobalString(IPCModeFlag.Name))
+	}
+	return os.FileMode(mode)
+}
+
 // MakeNodeKey creates a node key from set command line flags, either loading it
 // from a file or as a specified hex value. If neither flags were provided, this
 // method returns nil and an emphemeral key is to be generated.
@@ -306,17 +340,31 @@ func MakeNodeKey(ctx *cli.Context) *ecdsa.PrivateKey {
 	return key
 }
 
+// MakeNodeKeyPassword reads the password encrypting the node key from the first
+// line of the file specified by --nodekey-password, if any.
+func MakeNodeKeyPassword(ctx *cli.Context) string {
+	path := ctx.GlobalString(NodeKeyPasswordFlag.Name)
+	if path == "" {
+		return ""
+	}
+	text, err := ioutil.ReadFile(path)
+	if err != nil {
+		log.Fatalf("Option %q: %v", NodeKeyPasswordFlag.Name, err)
+	}
+	password := splitPasswords(string(text))[0]
+	if password == "" {
+		log.Fatalf("Option %q: empty password", NodeKeyPasswordFlag.Name)
+	}
+	return password
+}
+
 // MakeBootstrapNodesFromContext creates a list of bootstrap nodes from the command line
 // flags, reverting to pre-configured ones if none have been specified.
 func MakeBootstrapNodesFromContext(ctx *cli.Context) []*discover.Node {
 	// Return pre-configured nodes if none were manually requested
 	if !ctx.GlobalIsSet(aliasableName(BootnodesFlag.Name, ctx)) {
 
-		// --testnet/--chain=morden flag overrides --config flag
-		if chainIsMorden(ctx) {
-			return core.DefaultConfigMorden.ParsedBootstrap
-		}
-		return core.DefaultConfigMainnet.ParsedBootstrap
+		return mustMakeBundledChainConfig(ctx).ParsedBootstrap
 	}
 	return core.ParseBootstrapNodeStrings(strings.Split(ctx.GlobalString(aliasableName(BootnodesFlag.Name, ctx)), ","))
 }
@@ -346,6 +394,16 @@ func MakeRPCModules(input string) []string {
 	return result
 }
 
+// MakeRPCLimits parses the rate and concurrency limits of the RPC methods set
+// on the command line.
+func MakeRPCLimits(ctx *cli.Context) []rpc.LimitRule {
+	limits, err := rpc.ParseLimits(ctx.GlobalString(RPCLimitsFlag.Name))
+	if err != nil {
+		glog.Fatalf("%v: --%v: %v", ErrInvalidFlag, RPCLimitsFlag.Name, err)
+	}
+	return limits
+}
+
 // MakeHTTPRpcHost creates the HTTP RPC listener interface string from the set
 // command line flags, returning empty if the HTTP endpoint is disabled.
 func MakeHTTPRpcHost(ctx *cli.Context) string {
@@ -380,6 +438,20 @@ func MakeDatabaseHandles() int {
 	return limit / 2 // Leave half for networking and other stuff
 }
 
+// MakeCacheAllowance splits the megabytes of the cache flag between the
+// database read cache and the trie node cache, as set by their flags.
+func MakeCacheAllowance(ctx *cli.Context) (databaseMB, trieMB int) {
+	var (
+		cache      = ctx.GlobalInt(aliasableName(CacheFlag.Name, ctx))
+		databasePc = ctx.GlobalInt(aliasableName(CacheDatabaseFlag.Name, ctx))
+		triePc     = ctx.GlobalInt(aliasableName(CacheTrieFlag.Name, ctx))
+	)
+	if databasePc < 0 || triePc < 0 || databasePc+triePc > 100 {
+		glog.Fatalf("%v and %v must be percentages summing to at most 100, got %d and %d", CacheDatabaseFlag.Name, CacheTrieFlag.Name, databasePc, triePc)
+	}
+	return cache * databasePc / 100, cache * triePc / 100
+}
+
 // MakeAccountManager creates an account manager from set command line flags.
 func MakeAccountManager(ctx *cli.Context) *accounts.Manager {
 	// Create the keystore crypto primitive, light if requested
@@ -390,6 +462,7 @@ func MakeAccountManager(ctx *cli.Context) *accounts.Manager {
 		scryptP = accounts.LightScryptP
 	}
 
+	mustMigrateDataDir(ctx)
 	datadir := MustMakeChainDataDir(ctx)
 
 	keydir := filepath.Join(datadir, "keystore")
@@ -406,6 +479,17 @@ func MakeAccountManager(ctx *cli.Context) *accounts.Manager {
 	if err != nil {
 		glog.Fatalf("init account manager at %q: %s", keydir, err)
 	}
+	// Track smartcard wallets if the pcsc-lite daemon runs
+	if daemon := ctx.GlobalString(aliasableName(SmartCardDaemonFlag.Name, ctx)); daemon != "" {
+		if _, err := os.Stat(daemon); err == nil {
+			hub, err := scwallet.NewHub(daemon, filepath.Join(keydir, "smartcards.json"))
+			if err != nil {
+				glog.V(logger.Warn).Warnf("Failed to start smartcard wallet tracking: %v", err)
+			} else {
+				m.AddBackend(hub)
+			}
+		}
+	}
 	return m
 }
 
@@ -445,17 +529,63 @@ func MakeEtherbase(accman *accounts.Manager, ctx *cli.Context) common.Address {
 	return account.Address
 }
 
-// MakePasswordList reads password lines from the file specified by --password.
+// MakeTxPoolLocals retrieves the accounts whose transactions are treated as
+// local by the transaction pool from the --txpool.locals flag.
+func MakeTxPoolLocals(ctx *cli.Context) []common.Address {
+	var locals []common.Address
+	for _, account := range strings.Split(ctx.GlobalString(aliasableName(TxPoolLocalsFlag.Name, ctx)), ",") {
+		if trimmed := strings.TrimSpace(account); trimmed != "" {
+			if !common.IsHexAddress(trimmed) {
+				log.Fatalf("Option %q: invalid account %q", aliasableName(TxPoolLocalsFlag.Name, ctx), trimmed)
+			}
+			locals = append(locals, common.HexToAddress(trimmed))
+		}
+	}
+	return locals
+}
+
+// MakeNameRegistry retrieves the address of the registry contract names are
+// resolved through from the --name-registry flag, the zero address if unset.
+func MakeNameRegistry(ctx *cli.Context) common.Address {
+	registry := strings.TrimSpace(ctx.GlobalString(aliasableName(NameRegistryFlag.Name, ctx)))
+	if registry == "" {
+		return common.Address{}
+	}
+	if !common.IsHexAddress(registry) {
+		log.Fatalf("Option %q: invalid address %q", aliasableName(NameRegistryFlag.Name, ctx), registry)
+	}
+	return common.HexToAddress(registry)
+}
+
+// MakePasswordList reads password lines from the file specified by --password,
+// or the environment variable specified by --password-env, which is cleared
+// once read so that it isn't passed on to child processes.
 func MakePasswordList(ctx *cli.Context) []string {
 	path := ctx.GlobalString(aliasableName(PasswordFileFlag.Name, ctx))
-	if path == "" {
-		return nil
-	}
-	text, err := ioutil.ReadFile(path)
-	if err != nil {
-		glog.Fatal("Failed to read password file: ", err)
+	env := ctx.GlobalString(aliasableName(PasswordEnvFlag.Name, ctx))
+	switch {
+	case path != "" && env != "":
+		glog.Fatalf("%v: used conflicting flags: --%v, --%v", ErrInvalidFlag, aliasableName(PasswordFileFlag.Name, ctx), aliasableName(PasswordEnvFlag.Name, ctx))
+	case env != "":
+		text, ok := os.LookupEnv(env)
+		if !ok {
+			glog.Fatalf("Password environment variable %s is not set", env)
+		}
+		os.Unsetenv(env)
+		return splitPasswords(text)
+	case path != "":
+		text, err := ioutil.ReadFile(path)
+		if err != nil {
+			glog.Fatal("Failed to read password file: ", err)
+		}
+		return splitPasswords(string(text))
 	}
-	lines := strings.Split(string(text), "\n")
+	return nil
+}
+
+// splitPasswords splits password lines.
+func splitPasswords(text string) []string {
+	lines := strings.Split(text, "\n")
 	// Sanitise DOS line endings.
 	for i := range lines {
 		lines[i] = strings.TrimRight(lines[i], "\r")
@@ -463,6 +593,31 @@ func MakePasswordList(ctx *cli.Context) []string {
 	return lines
 }
 
+// unlockAccounts unlocks the accounts specified by --unlock, the i-th with the
+// i-th password line if passwords were supplied, or prompting for them. Since
+// the unlocked accounts may then be used by any RPC client, it refuses to do so
+// while the HTTP or WS RPC interfaces are enabled unless --allow-insecure-unlock
+// is set.
+func unlockAccounts(ctx *cli.Context, accman *accounts.Manager) {
+	var unlock []string
+	for _, account := range strings.Split(ctx.GlobalString(aliasableName(UnlockedAccountFlag.Name, ctx)), ",") {
+		if trimmed := strings.TrimSpace(account); trimmed != "" {
+			unlock = append(unlock, trimmed)
+		}
+	}
+	if len(unlock) == 0 {
+		return
+	}
+	remote := MakeHTTPRpcHost(ctx) != "" || MakeWSRpcHost(ctx) != ""
+	if remote && !ctx.GlobalBool(aliasableName(AllowInsecureUnlockFlag.Name, ctx)) {
+		glog.Fatalf("%v: unlocking accounts with the HTTP or WS RPC enabled exposes them to the RPC clients, set --%s to allow it", ErrInvalidFlag, AllowInsecureUnlockFlag.Name)
+	}
+	passwords := MakePasswordList(ctx)
+	for i, account := range unlock {
+		unlockAccount(ctx, accman, account, i, passwords)
+	}
+}
+
 // makeName makes the node name, which can be (in part) customized by the NodeNameFlag
 func makeNodeName(version string, ctx *cli.Context) string {
 	name := fmt.Sprintf("Geth/%s/%s/%s", version, runtime.GOOS, runtime.Version())
@@ -485,11 +640,14 @@ func MakeSystemNode(version string, ctx *cli.Context) *node.Node {
 		}
 		miner.HeaderExtra = []byte(s)
 	}
+	rpc.SafeBlockDepth = uint64(ctx.GlobalInt(aliasableName(SafeDepthFlag.Name, ctx)))
+	rpc.ConfirmedBlockDepth = uint64(ctx.GlobalInt(aliasableName(ConfirmedDepthFlag.Name, ctx)))
 
 	// Makes sufficient configuration from JSON file or DB pending flags.
 	// Delegates flag usage.
 	config := mustMakeSufficientChainConfig(ctx)
 	logChainConfiguration(ctx, config)
+	mustMigrateDataDir(ctx)
 
 	// Configure the Ethereum service
 	ethConf := mustMakeEthConf(ctx, config)
@@ -540,24 +698,40 @@ func shouldAttemptDirMigration(ctx *cli.Context) bool {
 func mustMakeStackConf(ctx *cli.Context, name string, config *core.SufficientChainConfig) (stackConf *node.Config, shhEnable bool) {
 	// Configure the node's service container
 	stackConf = &node.Config{
-		DataDir:         MustMakeChainDataDir(ctx),
-		PrivateKey:      MakeNodeKey(ctx),
-		Name:            name,
-		NoDiscovery:     ctx.GlobalBool(aliasableName(NoDiscoverFlag.Name, ctx)),
-		BootstrapNodes:  config.ParsedBootstrap,
-		ListenAddr:      MakeListenAddress(ctx),
-		NAT:             MakeNAT(ctx),
-		MaxPeers:        ctx.GlobalInt(aliasableName(MaxPeersFlag.Name, ctx)),
-		MaxPendingPeers: ctx.GlobalInt(aliasableName(MaxPendingPeersFlag.Name, ctx)),
-		IPCPath:         MakeIPCPath(ctx),
-		HTTPHost:        MakeHTTPRpcHost(ctx),
-		HTTPPort:        ctx.GlobalInt(aliasableName(RPCPortFlag.Name, ctx)),
-		HTTPCors:        ctx.GlobalString(aliasableName(RPCCORSDomainFlag.Name, ctx)),
-		HTTPModules:     MakeRPCModules(ctx.GlobalString(aliasableName(RPCApiFlag.Name, ctx))),
-		WSHost:          MakeWSRpcHost(ctx),
-		WSPort:          ctx.GlobalInt(aliasableName(WSPortFlag.Name, ctx)),
-		WSOrigins:       ctx.GlobalString(aliasableName(WSAllowedOriginsFlag.Name, ctx)),
-		WSModules:       MakeRPCModules(ctx.GlobalString(aliasableName(WSApiFlag.Name, ctx))),
+		DataDir:            MustMakeChainDataDir(ctx),
+		PrivateKey:         MakeNodeKey(ctx),
+		NodeKeyPassword:    MakeNodeKeyPassword(ctx),
+		Name:               name,
+		NoDiscovery:        ctx.GlobalBool(aliasableName(NoDiscoverFlag.Name, ctx)),
+		BootstrapNodes:     config.ParsedBootstrap,
+		ListenAddr:         MakeListenAddress(ctx),
+		NAT:                MakeNAT(ctx),
+		MaxPeers:           ctx.GlobalInt(aliasableName(MaxPeersFlag.Name, ctx)),
+		MaxPendingPeers:    ctx.GlobalInt(aliasableName(MaxPendingPeersFlag.Name, ctx)),
+		ClockCheck:         ctx.GlobalDuration(aliasableName(ClockCheckFlag.Name, ctx)),
+		IPCPath:            MakeIPCPath(ctx),
+		IPCMode:            MakeIPCMode(ctx),
+		IPCGroup:           ctx.GlobalString(IPCGroupFlag.Name),
+		IPCAuth:            ctx.GlobalBool(IPCAuthFlag.Name),
+		HTTPHost:           MakeHTTPRpcHost(ctx),
+		HTTPPort:           ctx.GlobalInt(aliasableName(RPCPortFlag.Name, ctx)),
+		HTTPCors:           ctx.GlobalString(aliasableName(RPCCORSDomainFlag.Name, ctx)),
+		HTTPModules:        MakeRPCModules(ctx.GlobalString(aliasableName(RPCApiFlag.Name, ctx))),
+		WSHost:             MakeWSRpcHost(ctx),
+		WSPort:             ctx.GlobalInt(aliasableName(WSPortFlag.Name, ctx)),
+		WSOrigins:          ctx.GlobalString(aliasableName(WSAllowedOriginsFlag.Name, ctx)),
+		WSModules:          MakeRPCModules(ctx.GlobalString(aliasableName(WSApiFlag.Name, ctx))),
+		WSMaxConnections:   ctx.GlobalInt(WSMaxConnectionsFlag.Name),
+		WSMaxSubscriptions: ctx.GlobalInt(WSMaxSubscriptionsFlag.Name),
+		WSIdleTimeout:      ctx.GlobalDuration(WSIdleTimeoutFlag.Name),
+		RPCAccessLog:       ctx.GlobalString(RPCAccessLogFlag.Name),
+		RPCSlowQuery:       ctx.GlobalDuration(RPCSlowQueryFlag.Name),
+		RPCAuditLog:        ctx.GlobalString(RPCAuditLogFlag.Name),
+		RPCAuditKey:        ctx.GlobalString(RPCAuditKeyFlag.Name),
+		RPCLimits:          MakeRPCLimits(ctx),
+		RPCTLSCert:         ctx.GlobalString(RPCTLSCertFlag.Name),
+		RPCTLSKey:          ctx.GlobalString(RPCTLSKeyFlag.Name),
+		RPCTLSClientCA:     ctx.GlobalString(RPCTLSClientCAFlag.Name),
 	}
 
 	// Configure the Whisper service
@@ -585,24 +759,32 @@ func mustMakeStackConf(ctx *cli.Context, name string, config *core.SufficientCha
 
 func mustMakeEthConf(ctx *cli.Context, sconf *core.SufficientChainConfig) *eth.Config {
 
-	accman := MakeAccountManager(ctx)
-	passwords := MakePasswordList(ctx)
-
-	accounts := strings.Split(ctx.GlobalString(aliasableName(UnlockedAccountFlag.Name, ctx)), ",")
-	for i, account := range accounts {
-		if trimmed := strings.TrimSpace(account); trimmed != "" {
-			unlockAccount(ctx, accman, trimmed, i, passwords)
+	if ctx.GlobalBool(aliasableName(HeaderOnlyFlag.Name, ctx)) {
+		for _, flag := range []cli.BoolFlag{FastSyncFlag, MiningEnabledFlag} {
+			if ctx.GlobalBool(aliasableName(flag.Name, ctx)) {
+				glog.Fatalf("%v: used conflicting flags: --%v, --%v", ErrInvalidFlag, aliasableName(HeaderOnlyFlag.Name, ctx), aliasableName(flag.Name, ctx))
+			}
 		}
 	}
 
+	accman := MakeAccountManager(ctx)
+	unlockAccounts(ctx, accman)
+
+	databaseCache, trieCache := MakeCacheAllowance(ctx)
 	ethConf := &eth.Config{
 		ChainConfig:             sconf.ChainConfig,
 		Genesis:                 sconf.Genesis,
 		FastSync:                ctx.GlobalBool(aliasableName(FastSyncFlag.Name, ctx)),
+		HeaderOnly:              ctx.GlobalBool(aliasableName(HeaderOnlyFlag.Name, ctx)),
+		OverrideChainConfig:     ctx.GlobalBool(aliasableName(OverrideChainConfigFlag.Name, ctx)),
 		BlockChainVersion:       ctx.GlobalInt(aliasableName(BlockchainVersionFlag.Name, ctx)),
-		DatabaseCache:           ctx.GlobalInt(aliasableName(CacheFlag.Name, ctx)),
+		DatabaseCache:           databaseCache,
+		TrieCache:               trieCache,
+		NoPreimages:             !ctx.GlobalBoolT(aliasableName(CachePreimagesFlag.Name, ctx)),
 		DatabaseHandles:         MakeDatabaseHandles(),
 		NetworkId:               sconf.Network,
+		ChainName:               sconf.Name,
+		Bootnodes:               sconf.ParsedBootstrap,
 		AccountManager:          accman,
 		Etherbase:               MakeEtherbase(accman, ctx),
 		MinerThreads:            ctx.GlobalInt(aliasableName(MinerThreadsFlag.Name, ctx)),
@@ -617,6 +799,31 @@ func mustMakeEthConf(ctx *cli.Context, sconf *core.SufficientChainConfig) *eth.C
 		GpobaseCorrectionFactor: ctx.GlobalInt(aliasableName(GpobaseCorrectionFactorFlag.Name, ctx)),
 		SolcPath:                ctx.GlobalString(aliasableName(SolcPathFlag.Name, ctx)),
 		AutoDAG:                 ctx.GlobalBool(aliasableName(AutoDAGFlag.Name, ctx)) || ctx.GlobalBool(aliasableName(MiningEnabledFlag.Name, ctx)),
+		GasAudit:                ctx.GlobalBool(aliasableName(GasAuditFlag.Name, ctx)),
+		OpcodeMetrics:           ctx.GlobalBool(aliasableName(OpcodeMetricsFlag.Name, ctx)),
+		FutureDrift:             ctx.GlobalDuration(aliasableName(FutureDriftFlag.Name, ctx)),
+		ForensicsDir:            ctx.GlobalString(aliasableName(ForensicsDirFlag.Name, ctx)),
+		TraceIndex:              ctx.GlobalBool(aliasableName(TraceIndexFlag.Name, ctx)),
+		FilterTimeout:           ctx.GlobalDuration(aliasableName(FilterTimeoutFlag.Name, ctx)),
+		FilterMaxBlocks:         uint64(ctx.GlobalInt(aliasableName(FilterMaxBlocksFlag.Name, ctx))),
+		FilterMaxResults:        ctx.GlobalInt(aliasableName(FilterMaxResultsFlag.Name, ctx)),
+		NameRegistry:            MakeNameRegistry(ctx),
+		RemoteAccounts:          ctx.GlobalBool(aliasableName(ExposeAccountsFlag.Name, ctx)),
+		InsecureUnlock:          ctx.GlobalBool(aliasableName(AllowInsecureUnlockFlag.Name, ctx)),
+		TxPool: core.TxPoolConfig{
+			AccountSlots:    uint64(ctx.GlobalInt(aliasableName(TxPoolAccountSlotsFlag.Name, ctx))),
+			GlobalSlots:     uint64(ctx.GlobalInt(aliasableName(TxPoolGlobalSlotsFlag.Name, ctx))),
+			AccountQueue:    uint64(ctx.GlobalInt(aliasableName(TxPoolAccountQueueFlag.Name, ctx))),
+			GlobalQueue:     uint64(ctx.GlobalInt(aliasableName(TxPoolGlobalQueueFlag.Name, ctx))),
+			Lifetime:        ctx.GlobalDuration(aliasableName(TxPoolLifetimeFlag.Name, ctx)),
+			MaxTxSize:       uint64(ctx.GlobalInt(aliasableName(TxPoolMaxTxSizeFlag.Name, ctx))),
+			MaxTxGas:        uint64(ctx.GlobalInt(aliasableName(TxPoolMaxTxGasFlag.Name, ctx))),
+			MaxInitCodeSize: uint64(ctx.GlobalInt(aliasableName(TxPoolMaxInitCodeSizeFlag.Name, ctx))),
+			Locals:          MakeTxPoolLocals(ctx),
+			NoLocals:        ctx.GlobalBool(aliasableName(TxPoolNoLocalsFlag.Name, ctx)),
+			Journal:         ctx.GlobalString(aliasableName(TxPoolJournalFlag.Name, ctx)),
+			Rejournal:       ctx.GlobalDuration(aliasableName(TxPoolRejournalFlag.Name, ctx)),
+		},
 	}
 
 	if _, ok := ethConf.GasPrice.SetString(ctx.GlobalString(aliasableName(GasPriceFlag.Name, ctx)), 0); !ok {
@@ -632,6 +839,8 @@ func mustMakeEthConf(ctx *cli.Context, sconf *core.SufficientChainConfig) *eth.C
 	switch sconf.Consensus {
 	case "ethash-test":
 		ethConf.PowTest = true
+	case "clique":
+		ethConf.Clique = sconf.Clique
 	}
 
 	// Override any default configs in dev mode
@@ -681,19 +890,26 @@ func mustMakeSufficientChainConfig(ctx *cli.Context) *core.SufficientChainConfig
 
 	chainIdentity := mustMakeChainIdentity(ctx)
 
-	// If chain identity is either of defaults (via config file or flag), use defaults.
-	if chainIdentitiesMain[chainIdentity] || chainIdentitiesMorden[chainIdentity] {
+	// If chain identity is one of the bundled configurations (via config file or flag), use it.
+	if bundled := core.BundledChainConfig(chainIdentity); bundled != nil {
 		// Initialise chain configuration before handling migrations or setting up node.
 		config.Identity = chainIdentity
-		config.Name = mustMakeChainConfigNameDefaulty(ctx)
-		config.Network = eth.NetworkId // 1, default mainnet
-		config.Consensus = "ethash"
-		config.Genesis = core.DefaultConfigMainnet.Genesis
-		config.ChainConfig = MustMakeChainConfigFromDefaults(ctx).SortForks()
+		config.Name = bundled.Name
+		config.Network = bundled.Network
+		config.Consensus = bundled.Consensus
+		config.Genesis = bundled.Genesis
+		config.ChainConfig = bundled.ChainConfig.SortForks()
 		config.ParsedBootstrap = MakeBootstrapNodesFromContext(ctx)
-		if chainIsMorden(ctx) {
+		if bundled.State != nil && bundled.State.StartingNonce != 0 {
+			state.StartingNonce = bundled.State.StartingNonce
+		}
+		switch {
+		case chainIdentitiesMain[chainIdentity]:
+			config.Network = eth.NetworkId // 1, default mainnet
+			config.Consensus = "ethash"
+		case chainIdentitiesMorden[chainIdentity]:
 			config.Network = 2
-			config.Genesis = core.DefaultConfigMorden.Genesis
+			config.Consensus = "ethash"
 			state.StartingNonce = state.DefaultTestnetStartingNonce // (2**20)
 		}
 		return config
@@ -736,7 +952,7 @@ func mustMakeSufficientChainConfig(ctx *cli.Context) *core.SufficientChainConfig
 
 func logChainConfiguration(ctx *cli.Context, config *core.SufficientChainConfig) {
 	chainIdentity := mustMakeChainIdentity(ctx)
-	chainIsCustom := !(chainIdentitiesMain[chainIdentity] || chainIdentitiesMorden[chainIdentity])
+	chainIsCustom := core.BundledChainConfig(chainIdentity) == nil
 	if chainIsCustom {
 		glog.V(logger.Info).Infof("Using custom chain configuration: %s", chainIdentity)
 		glog.D(logger.Warn).Infof("Custom chain config: %s", logger.ColorGreen(chainIdentity))
@@ -783,19 +999,17 @@ func logChainConfiguration(ctx *cli.Context, config *core.SufficientChainConfig)
 
 // MustMakeChainConfigFromDefaults reads the chain configuration from hardcode.
 func MustMakeChainConfigFromDefaults(ctx *cli.Context) *core.ChainConfig {
-	c := core.DefaultConfigMainnet.ChainConfig
-	if chainIsMorden(ctx) {
-		c = core.DefaultConfigMorden.ChainConfig
-	}
-	return c
+	return mustMakeBundledChainConfig(ctx).ChainConfig
 }
 
 // MakeChainDatabase open an LevelDB using the flags passed to the client and will hard crash if it fails.
 func MakeChainDatabase(ctx *cli.Context) ethdb.Database {
+	mustMigrateDataDir(ctx)
+
 	var (
-		datadir = MustMakeChainDataDir(ctx)
-		cache   = ctx.GlobalInt(aliasableName(CacheFlag.Name, ctx))
-		handles = MakeDatabaseHandles()
+		datadir  = MustMakeChainDataDir(ctx)
+		cache, _ = MakeCacheAllowance(ctx)
+		handles  = MakeDatabaseHandles()
 	)
 
 	chainDb, err := ethdb.NewLDBDatabase(filepath.Join(datadir, "chaindata"), cache, handles)
@@ -807,23 +1021,53 @@ func MakeChainDatabase(ctx *cli.Context) ethdb.Database {
 
 // MakeChain creates a chain manager from set command line flags.
 func MakeChain(ctx *cli.Context) (chain *core.BlockChain, chainDb ethdb.Database) {
-	var err error
 	sconf := mustMakeSufficientChainConfig(ctx)
 	chainDb = MakeChainDatabase(ctx)
+	return makeChain(ctx, sconf, chainDb), chainDb
+}
+
+// makeChain creates a chain manager for the given chain configuration on top
+// of the given database, which must hold the genesis block.
+func makeChain(ctx *cli.Context, sconf *core.SufficientChainConfig, chainDb ethdb.Database) (chain *core.BlockChain) {
+	var err error
+	_, trieCache := MakeCacheAllowance(ctx)
+	trie.SetCacheSize(trieCache * 1024 * 1024)
+	trie.SetPreimageRecording(ctx.GlobalBoolT(aliasableName(CachePreimagesFlag.Name, ctx)))
 
 	pow := pow.PoW(core.FakePow{})
 	if !ctx.GlobalBool(aliasableName(FakePoWFlag.Name, ctx)) {
-		pow = ethash.New()
+		if sconf.Consensus == "ethash-test" {
+			if pow, err = ethash.NewForTesting(); err != nil {
+				glog.Fatal("Could not start test ethash: ", err)
+			}
+		} else {
+			pow = ethash.New()
+		}
 	} else {
 		glog.V(logger.Info).Infoln("Consensus: fake")
 		glog.D(logger.Warn).Warnln("Consensus: fake")
 	}
 
+	override := ctx.GlobalBool(aliasableName(OverrideChainConfigFlag.Name, ctx))
+	err = core.SetupChainConfig(chainDb, sconf.ChainConfig)
+	compat, _ := err.(*core.ConfigCompatError)
+	switch {
+	case compat != nil && !override:
+		glog.Fatalf("%v; start with --override.chainconfig to rewind the chain", compat)
+	case compat == nil && err != nil:
+		glog.Fatal("Could not store chain config: ", err)
+	}
+
 	chain, err = core.NewBlockChain(chainDb, sconf.ChainConfig, pow, new(event.TypeMux))
 	if err != nil {
 		glog.Fatal("Could not start chainmanager: ", err)
 	}
-	return chain, chainDb
+	if compat != nil {
+		if err := chain.ApplyChainConfig(compat); err != nil {
+			glog.Fatal("Could not apply chain config: ", err)
+		}
+	}
+	return chain
 }
 
 // MakeConsolePreloads retrieves the absolute paths for the console JavaScript
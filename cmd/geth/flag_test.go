@@ -134,6 +134,7 @@ func TestMustMakeChainDataDir(t *testing.T) {
 		{[]string{"--chain", "main"}, filepath.Join(dd, "mainnet"), nil},
 		{[]string{"--chain", "morden"}, filepath.Join(dd, "testnet"), nil},
 		{[]string{"--chain", "testnet"}, filepath.Join(dd, "testnet"), nil},
+		{[]string{"--chain", "dev"}, filepath.Join(dd, "dev"), nil},
 		{[]string{"--chain", "kitty"}, filepath.Join(dd, "kitty"), nil},
 
 		{[]string{"--chain", "kitty/cat"}, filepath.Join(dd, "kitty", "cat"), nil},
@@ -182,6 +183,7 @@ func TestGetChainIdentityValue(t *testing.T) {
 		{[]string{"--chain", "testnet"}, "testnet"},
 		{[]string{"--chain", "main"}, "mainnet"},
 		{[]string{"--chain", "mainnet"}, "mainnet"},
+		{[]string{"--chain", "dev"}, "dev"},
 
 		// Custom.
 		{[]string{"--chain", "kitty"}, "kitty"},
@@ -314,6 +316,25 @@ func TestMakeBootstrapNodesFromContext4(t *testing.T) {
 	}
 }
 
+// Bootnodes dev default
+func TestMakeBootstrapNodesFromContext5(t *testing.T) {
+
+	makeTmpDataDir(t)
+	defer rmTmpDataDir(t)
+	setupFlags(t)
+	cacheChainIdentity = ""
+
+	arg := []string{"--chain", "dev"}
+	if e := set.Parse(arg); e != nil {
+		t.Fatal(e)
+	}
+	context = cli.NewContext(app, set, nil)
+	got := MakeBootstrapNodesFromContext(context)
+	if len(got) != 0 {
+		t.Errorf("wanted: 0, got %v", len(got))
+	}
+}
+
 func TestMakeAddress(t *testing.T) {
 	accAddr := "f466859ead1932d743d622cb74fc058882e8648a" // account[0] address
 	cachetestdir := filepath.Join("accounts", "testdata", "keystore")
@@ -333,3 +354,43 @@ func TestMakeAddress(t *testing.T) {
 		t.Fatalf("want: %v, got: %v", wantAccount, gotAccount)
 	}
 }
+
+func TestMakePasswordList(t *testing.T) {
+	app := makeCLIApp()
+	set := flag.NewFlagSet("test", 0)
+	set.String(PasswordFileFlag.Name, "", "")
+	set.String(PasswordEnvFlag.Name, "", "")
+
+	dir, err := ioutil.TempDir("", "passwords")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "passwords")
+	if err := ioutil.WriteFile(file, []byte("first\r\nsecond"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := set.Parse([]string{"--password", file}); err != nil {
+		t.Fatal(err)
+	}
+	if have := MakePasswordList(cli.NewContext(app, set, nil)); !reflect.DeepEqual(have, []string{"first", "second"}) {
+		t.Errorf("file passwords mismatch: have %q", have)
+	}
+
+	// Passwords from the environment are cleared once read
+	set = flag.NewFlagSet("test", 0)
+	set.String(PasswordFileFlag.Name, "", "")
+	set.String(PasswordEnvFlag.Name, "", "")
+	os.Setenv("TEST_UNLOCK_PASSWORDS", "third\nfourth")
+	defer os.Unsetenv("TEST_UNLOCK_PASSWORDS")
+
+	if err := set.Parse([]string{"--password-env", "TEST_UNLOCK_PASSWORDS"}); err != nil {
+		t.Fatal(err)
+	}
+	if have := MakePasswordList(cli.NewContext(app, set, nil)); !reflect.DeepEqual(have, []string{"third", "fourth"}) {
+		t.Errorf("environment passwords mismatch: have %q", have)
+	}
+	if _, ok := os.LookupEnv("TEST_UNLOCK_PASSWORDS"); ok {
+		t.Errorf("password environment variable not cleared")
+	}
+}
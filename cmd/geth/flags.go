@@ -7,10 +7,13 @@ import (
 	"strings"
 
 	"path/filepath"
+	"time"
 
+	"github.com/ellaism/go-ellaism/accounts/scwallet"
 	"github.com/ellaism/go-ellaism/common"
 	"github.com/ellaism/go-ellaism/core"
 	"github.com/ellaism/go-ellaism/eth"
+	"github.com/ellaism/go-ellaism/eth/filters"
 	"github.com/ellaism/go-ellaism/logger"
 	"github.com/ellaism/go-ellaism/logger/glog"
 	"github.com/ellaism/go-ellaism/rpc"
@@ -37,9 +40,13 @@ var (
 	}
 	ChainIdentityFlag = cli.StringFlag{
 		Name:  "chain",
-		Usage: `Chain identifier (default='mainnet', test='morden') or path to JSON chain configuration file (eg './path/to/chain.json').`,
+		Usage: `Chain identifier (default='mainnet', test='morden', dev='dev') or path to JSON chain configuration file (eg './path/to/chain.json').`,
 		Value: core.DefaultConfigMainnet.Identity,
 	}
+	OverrideChainConfigFlag = cli.BoolFlag{
+		Name:  "override-chain-config,override.chainconfig",
+		Usage: "Start with a chain config changing blocks already in the chain, rewinding the chain to before the first of them",
+	}
 	NetworkIdFlag = cli.IntFlag{
 		Name:  "network-id, networkid",
 		Usage: "Network identifier (integer: 1=Homestead, 2=Morden)",
@@ -71,19 +78,56 @@ var (
 		Usage: "Megabytes of memory allocated to internal caching (min 16MB / database forced)",
 		Value: 128,
 	}
+	CacheDatabaseFlag = cli.IntFlag{
+		Name:  "cache-database",
+		Usage: "Percentage of the cache allowance used for the database read cache",
+		Value: 75,
+	}
+	CacheTrieFlag = cli.IntFlag{
+		Name:  "cache-trie",
+		Usage: "Percentage of the cache allowance used for the trie node cache",
+		Value: 25,
+	}
+	CachePreimagesFlag = cli.BoolTFlag{
+		Name:  "cache-preimages,cache.preimages",
+		Usage: "Record the keccak preimages of state trie keys, used by dump and export-preimages (--cache.preimages=false saves disk space)",
+	}
 	BlockchainVersionFlag = cli.IntFlag{
 		Name:  "blockchain-version,blockchainversion",
 		Usage: "Blockchain version (integer)",
 		Value: core.BlockChainVersion,
 	}
+	FutureDriftFlag = cli.DurationFlag{
+		Name:  "max-future-drift",
+		Usage: "Maximum time the timestamp of a block may be ahead of the local clock for the block to be queued until then instead of rejected",
+		Value: core.DefaultFutureDrift,
+	}
+	ForensicsDirFlag = DirectoryFlag{
+		Name:  "forensics-dir",
+		Usage: "Directory the forensic bundles of blocks resulting in a state root mismatch are written to, relative to the data directory",
+		Value: DirectoryString{"forensics"},
+	}
+	TraceIndexFlag = cli.BoolFlag{
+		Name:  "trace-index",
+		Usage: "Traces every imported block once in the background to index the internal transfers of its transactions (uses additional disk space)",
+	}
 	FastSyncFlag = cli.BoolFlag{
 		Name:  "fast",
 		Usage: "Enable fast syncing through state downloads",
 	}
+	HeaderOnlyFlag = cli.BoolFlag{
+		Name:  "header-only",
+		Usage: "Sync and validate only the header chain, without block bodies, receipts or state (no mining or transactions)",
+	}
 	LightKDFFlag = cli.BoolFlag{
 		Name:  "light-kdf,lightkdf",
 		Usage: "Reduce key-derivation RAM & CPU usage at some expense of KDF strength",
 	}
+	SmartCardDaemonFlag = cli.StringFlag{
+		Name:  "smartcard-daemon",
+		Usage: "Socket of the pcsc-lite daemon to find Keycard smartcard wallets through (empty = disabled)",
+		Value: scwallet.DefaultDaemonPath,
+	}
 	// Network Split settings
 	ETFChain = cli.BoolFlag{
 		Name:  "etf",
@@ -123,6 +167,64 @@ var (
 		Usage: "Minimal gas price to accept for mining a transactions",
 		Value: new(big.Int).Mul(big.NewInt(20), common.Shannon).String(),
 	}
+	TxPoolAccountSlotsFlag = cli.IntFlag{
+		Name:  "txpool.accountslots",
+		Usage: "Number of executable transactions guaranteed per account",
+		Value: int(core.DefaultTxPoolConfig.AccountSlots),
+	}
+	TxPoolGlobalSlotsFlag = cli.IntFlag{
+		Name:  "txpool.globalslots",
+		Usage: "Maximum number of executable transactions of all accounts",
+		Value: int(core.DefaultTxPoolConfig.GlobalSlots),
+	}
+	TxPoolAccountQueueFlag = cli.IntFlag{
+		Name:  "txpool.accountqueue",
+		Usage: "Maximum number of non-executable transactions per account",
+		Value: int(core.DefaultTxPoolConfig.AccountQueue),
+	}
+	TxPoolGlobalQueueFlag = cli.IntFlag{
+		Name:  "txpool.globalqueue",
+		Usage: "Maximum number of non-executable transactions of all accounts",
+		Value: int(core.DefaultTxPoolConfig.GlobalQueue),
+	}
+	TxPoolLifetimeFlag = cli.DurationFlag{
+		Name:  "txpool.lifetime",
+		Usage: "Maximum time the transactions of an inactive account are queued for",
+		Value: core.DefaultTxPoolConfig.Lifetime,
+	}
+	TxPoolMaxTxSizeFlag = cli.IntFlag{
+		Name:  "txpool.maxtxsize",
+		Usage: "Maximum size of an encoded transaction, in bytes",
+		Value: int(core.DefaultTxPoolConfig.MaxTxSize),
+	}
+	TxPoolMaxTxGasFlag = cli.IntFlag{
+		Name:  "txpool.maxtxgas",
+		Usage: "Maximum gas limit of a transaction (0 = block gas limit)",
+		Value: int(core.DefaultTxPoolConfig.MaxTxGas),
+	}
+	TxPoolMaxInitCodeSizeFlag = cli.IntFlag{
+		Name:  "txpool.maxinitcodesize",
+		Usage: "Maximum size of the init code of a contract creation, in bytes",
+		Value: int(core.DefaultTxPoolConfig.MaxInitCodeSize),
+	}
+	TxPoolLocalsFlag = cli.StringFlag{
+		Name:  "txpool.locals",
+		Usage: "Comma separated accounts whose transactions are treated as local wherever they come from",
+	}
+	TxPoolNoLocalsFlag = cli.BoolFlag{
+		Name:  "txpool.nolocals",
+		Usage: "Treat the transactions submitted through this node as remote, enforcing the gas price floor and limits",
+	}
+	TxPoolJournalFlag = cli.StringFlag{
+		Name:  "txpool.journal",
+		Usage: "File within the datadir journaling local transactions across restarts (empty = disabled)",
+		Value: core.DefaultTxPoolConfig.Journal,
+	}
+	TxPoolRejournalFlag = cli.DurationFlag{
+		Name:  "txpool.rejournal",
+		Usage: "Time interval to regenerate the local transaction journal",
+		Value: core.DefaultTxPoolConfig.Rejournal,
+	}
 	ExtraDataFlag = cli.StringFlag{
 		Name:  "extra-data,extradata",
 		Usage: "Freeform header field set by the miner",
@@ -135,9 +237,18 @@ var (
 	}
 	PasswordFileFlag = cli.StringFlag{
 		Name:  "password",
-		Usage: "Password file to use for non-inteactive password input",
+		Usage: "Password file to use for non-inteactive password input, one line per unlocked account",
+		Value: "",
+	}
+	PasswordEnvFlag = cli.StringFlag{
+		Name:  "password-env",
+		Usage: "Environment variable holding the passwords of the unlocked accounts, one line per account (instead of --password)",
 		Value: "",
 	}
+	AllowInsecureUnlockFlag = cli.BoolFlag{
+		Name:  "allow-insecure-unlock",
+		Usage: "Allow unlocking accounts while the HTTP or WS RPC interfaces are enabled, and over them",
+	}
 
 	// logging and debug settings
 	NeckbeardFlag = cli.BoolFlag{
@@ -208,6 +319,14 @@ var (
 		Name:  "fake-pow, fakepow",
 		Usage: "Disables proof-of-work verification",
 	}
+	GasAuditFlag = cli.BoolFlag{
+		Name:  "gas-audit",
+		Usage: "Cross-checks the gas used by imported transactions against opcode metering and logs any discrepancies",
+	}
+	OpcodeMetricsFlag = cli.BoolFlag{
+		Name:  "opcode-metrics",
+		Usage: "Collects per-opcode execution counts, gas and timings of imported blocks as metrics (slows down block processing)",
+	}
 
 	// RPC settings
 	RPCEnabledFlag = cli.BoolFlag{
@@ -234,6 +353,38 @@ var (
 		Usage: "API's offered over the HTTP-RPC interface",
 		Value: rpc.DefaultHTTPApis,
 	}
+	RPCAccessLogFlag = cli.StringFlag{
+		Name:  "rpc-accesslog",
+		Usage: "File to append a line to for each RPC call served (method, params size, latency, error)",
+	}
+	RPCSlowQueryFlag = cli.DurationFlag{
+		Name:  "rpc-slowquery",
+		Usage: "Latency from which RPC calls are logged as slow (0 = disabled)",
+	}
+	RPCAuditLogFlag = cli.StringFlag{
+		Name:  "rpc-auditlog",
+		Usage: "File to append a MAC chained entry to for each personal, admin, signing and setEtherbase RPC call served",
+	}
+	RPCAuditKeyFlag = cli.StringFlag{
+		Name:  "rpc-auditlog-key",
+		Usage: "File holding the key of the RPC audit log MACs, generated if missing; required by --rpc-auditlog, out of the data directory",
+	}
+	RPCLimitsFlag = cli.StringFlag{
+		Name:  "rpc-limits",
+		Usage: "Rate and concurrency limits of the HTTP and WS RPC methods per client host, as pattern=calls/sec:concurrent (e.g. eth_call=50:8,eth_getLogs=5:2,debug_*=:1)",
+	}
+	RPCTLSCertFlag = cli.StringFlag{
+		Name:  "rpc-tls-cert",
+		Usage: "PEM certificate to serve the HTTP-RPC and WS-RPC endpoints over TLS with (https/wss)",
+	}
+	RPCTLSKeyFlag = cli.StringFlag{
+		Name:  "rpc-tls-key",
+		Usage: "PEM private key of the --rpc-tls-cert certificate",
+	}
+	RPCTLSClientCAFlag = cli.StringFlag{
+		Name:  "rpc-tls-client-ca",
+		Usage: "PEM certificate authorities TLS RPC clients must present a certificate from",
+	}
 	IPCDisabledFlag = cli.BoolFlag{
 		Name:  "ipc-disable,ipcdisable",
 		Usage: "Disable the IPC-RPC server",
@@ -248,6 +399,50 @@ var (
 		Usage: "Filename for IPC socket/pipe within the datadir (explicit paths escape it)",
 		Value: DirectoryString{common.DefaultIPCSocket},
 	}
+	IPCModeFlag = cli.StringFlag{
+		Name:  "ipc-mode",
+		Usage: "Octal file mode of the IPC socket (e.g. 0660 to let --ipc-group connect)",
+		Value: "0600",
+	}
+	IPCGroupFlag = cli.StringFlag{
+		Name:  "ipc-group",
+		Usage: "Group to own the IPC socket; the socket directory must be accessible to it",
+	}
+	IPCAuthFlag = cli.BoolFlag{
+		Name:  "ipc-auth",
+		Usage: "Require IPC clients to authenticate with the token in the data directory, encrypted with --nodekey-password if set (attach: authenticate with it)",
+	}
+	FilterTimeoutFlag = cli.DurationFlag{
+		Name:  "filter-timeout",
+		Usage: "Time after which log, block and transaction filters which aren't polled are removed",
+		Value: filters.DefaultConfig.Timeout,
+	}
+	FilterMaxBlocksFlag = cli.IntFlag{
+		Name:  "filter-max-blocks",
+		Usage: "Maximum number of blocks searched by a log query (0 = unlimited)",
+	}
+	FilterMaxResultsFlag = cli.IntFlag{
+		Name:  "filter-max-results",
+		Usage: "Maximum number of logs returned by a log query (0 = unlimited)",
+	}
+	NameRegistryFlag = cli.StringFlag{
+		Name:  "name-registry",
+		Usage: "Address of the ENS-style registry contract resolving names given to the RPC API in place of addresses",
+	}
+	SafeDepthFlag = cli.IntFlag{
+		Name:  "rpc-safe-depth",
+		Usage: `Number of blocks below the chain head the "safe" block tag of the RPC API stands for`,
+		Value: int(rpc.SafeBlockDepth),
+	}
+	ConfirmedDepthFlag = cli.IntFlag{
+		Name:  "rpc-confirmed-depth",
+		Usage: `Number of blocks below the chain head the "confirmed" block tag of the RPC API stands for`,
+		Value: int(rpc.ConfirmedBlockDepth),
+	}
+	ExposeAccountsFlag = cli.BoolFlag{
+		Name:  "rpc-expose-accounts",
+		Usage: "List the keystore accounts in eth_accounts to HTTP and WebSocket callers too (always listed over IPC)",
+	}
 	WSEnabledFlag = cli.BoolFlag{
 		Name:  "ws",
 		Usage: "Enable the WS-RPC server",
@@ -267,6 +462,18 @@ var (
 		Usage: "API's offered over the WS-RPC interface",
 		Value: rpc.DefaultHTTPApis,
 	}
+	WSMaxConnectionsFlag = cli.IntFlag{
+		Name:  "ws-max-connections",
+		Usage: "Maximum number of WS-RPC clients served at the same time (0 = unlimited)",
+	}
+	WSMaxSubscriptionsFlag = cli.IntFlag{
+		Name:  "ws-max-subscriptions",
+		Usage: "Maximum number of subscriptions a WS-RPC client may hold (0 = unlimited)",
+	}
+	WSIdleTimeoutFlag = cli.DurationFlag{
+		Name:  "ws-idle-timeout",
+		Usage: "Time after which WS-RPC clients without requests or subscriptions are disconnected (0 = never)",
+	}
 	WSAllowedOriginsFlag = cli.StringFlag{
 		Name:  "ws-origins,wsorigins",
 		Usage: "Origins from which to accept websockets requests",
@@ -310,6 +517,10 @@ var (
 		Name:  "nodekey-hex,nodekeyhex",
 		Usage: "P2P node key as hex (for testing)",
 	}
+	NodeKeyPasswordFlag = cli.StringFlag{
+		Name:  "nodekey-password",
+		Usage: "File holding the password encrypting the P2P node key in the data directory",
+	}
 	NATFlag = cli.StringFlag{
 		Name:  "nat",
 		Usage: "NAT port mapping mechanism (any|none|upnp|pmp|extip:<IP>)",
@@ -319,6 +530,11 @@ var (
 		Name:  "no-discover,nodiscover",
 		Usage: "Disables the peer discovery mechanism (manual peer addition)",
 	}
+	ClockCheckFlag = cli.DurationFlag{
+		Name:  "clock-check",
+		Usage: "Interval of the NTP checks of the local clock drift, reported by admin.nodeInfo (0 disables them)",
+		Value: time.Hour,
+	}
 	WhisperEnabledFlag = cli.BoolFlag{
 		Name:  "shh",
 		Usage: "Enable Whisper",
@@ -52,6 +52,12 @@ func makeCLIApp() (app *cli.App) {
 		upgradedbCommand,
 		removedbCommand,
 		dumpCommand,
+		replayCommand,
+		verifyChainCommand,
+		exportSnapshotCommand,
+		importSnapshotCommand,
+		exportPreimagesCommand,
+		benchCommand,
 		rollbackCommand,
 		recoverCommand,
 		resetCommand,
@@ -125,15 +131,26 @@ func makeCLIApp() (app *cli.App) {
 		NodeNameFlag,
 		UnlockedAccountFlag,
 		PasswordFileFlag,
+		PasswordEnvFlag,
+		AllowInsecureUnlockFlag,
 		AccountsIndexFlag,
+		SmartCardDaemonFlag,
 		BootnodesFlag,
 		DataDirFlag,
 		DocRootFlag,
 		KeyStoreDirFlag,
 		ChainIdentityFlag,
+		OverrideChainConfigFlag,
 		BlockchainVersionFlag,
+		FutureDriftFlag,
+		ForensicsDirFlag,
+		TraceIndexFlag,
 		FastSyncFlag,
+		HeaderOnlyFlag,
 		CacheFlag,
+		CacheDatabaseFlag,
+		CacheTrieFlag,
+		CachePreimagesFlag,
 		LightKDFFlag,
 		JSpathFlag,
 		ListenPortFlag,
@@ -141,6 +158,18 @@ func makeCLIApp() (app *cli.App) {
 		MaxPendingPeersFlag,
 		EtherbaseFlag,
 		GasPriceFlag,
+		TxPoolAccountSlotsFlag,
+		TxPoolGlobalSlotsFlag,
+		TxPoolAccountQueueFlag,
+		TxPoolGlobalQueueFlag,
+		TxPoolLifetimeFlag,
+		TxPoolMaxTxSizeFlag,
+		TxPoolMaxTxGasFlag,
+		TxPoolMaxInitCodeSizeFlag,
+		TxPoolLocalsFlag,
+		TxPoolNoLocalsFlag,
+		TxPoolJournalFlag,
+		TxPoolRejournalFlag,
 		MinerThreadsFlag,
 		MiningEnabledFlag,
 		MiningGPUFlag,
@@ -149,8 +178,10 @@ func makeCLIApp() (app *cli.App) {
 		NATFlag,
 		NatspecEnabledFlag,
 		NoDiscoverFlag,
+		ClockCheckFlag,
 		NodeKeyFileFlag,
 		NodeKeyHexFlag,
+		NodeKeyPasswordFlag,
 		RPCEnabledFlag,
 		RPCListenAddrFlag,
 		RPCPortFlag,
@@ -160,9 +191,22 @@ func makeCLIApp() (app *cli.App) {
 		WSPortFlag,
 		WSApiFlag,
 		WSAllowedOriginsFlag,
+		WSMaxConnectionsFlag,
+		WSMaxSubscriptionsFlag,
+		WSIdleTimeoutFlag,
 		IPCDisabledFlag,
 		IPCApiFlag,
 		IPCPathFlag,
+		IPCModeFlag,
+		IPCGroupFlag,
+		IPCAuthFlag,
+		FilterTimeoutFlag,
+		FilterMaxBlocksFlag,
+		FilterMaxResultsFlag,
+		NameRegistryFlag,
+		SafeDepthFlag,
+		ConfirmedDepthFlag,
+		ExposeAccountsFlag,
 		ExecFlag,
 		PreloadJSFlag,
 		WhisperEnabledFlag,
@@ -170,6 +214,14 @@ func makeCLIApp() (app *cli.App) {
 		TestNetFlag,
 		NetworkIdFlag,
 		RPCCORSDomainFlag,
+		RPCAccessLogFlag,
+		RPCSlowQueryFlag,
+		RPCAuditLogFlag,
+		RPCAuditKeyFlag,
+		RPCLimitsFlag,
+		RPCTLSCertFlag,
+		RPCTLSKeyFlag,
+		RPCTLSClientCAFlag,
 		NeckbeardFlag,
 		VerbosityFlag,
 		DisplayFlag,
@@ -183,6 +235,8 @@ func makeCLIApp() (app *cli.App) {
 		BacktraceAtFlag,
 		MetricsFlag,
 		FakePoWFlag,
+		GasAuditFlag,
+		OpcodeMetricsFlag,
 		SolcPathFlag,
 		GpoMinGasPriceFlag,
 		GpoMaxGasPriceFlag,
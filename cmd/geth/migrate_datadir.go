@@ -20,9 +20,11 @@ import (
 	"fmt"
 	"github.com/ellaism/go-ellaism/common"
 	"github.com/ellaism/go-ellaism/core"
+	"github.com/ellaism/go-ellaism/eth"
 	"github.com/ellaism/go-ellaism/ethdb"
 	"github.com/ellaism/go-ellaism/logger"
 	"github.com/ellaism/go-ellaism/logger/glog"
+	"github.com/ellaism/go-ellaism/node"
 	"gopkg.in/urfave/cli.v1"
 	"math/big"
 	"os"
@@ -202,24 +204,34 @@ func migrateExistingDirToClassicNamingScheme(ctx *cli.Context) error {
 }
 
 // migrateToChainSubdirIfNecessary migrates ".../EthereumClassic/nodes|chaindata|...|nodekey" --> ".../EthereumClassic/mainnet/nodes|chaindata|...|nodekey"
+// Only the default data directory of the default chains is migrated.
 func migrateToChainSubdirIfNecessary(ctx *cli.Context) error {
+	if !shouldAttemptDirMigration(ctx) {
+		return nil
+	}
 	chainIdentity := mustMakeChainIdentity(ctx) // "mainnet", "morden", "custom"
 
 	datapath := mustMakeDataDir(ctx) // ".../EthereumClassic/ | --datadir"
 
 	subdirPath := MustMakeChainDataDir(ctx) // ie, <EthereumClassic>/mainnet
 
+	return migrateToChainSubdir(datapath, subdirPath, chainIdentity)
+}
+
+// migrateToChainSubdir moves the data geth <= 3.3 kept in the root of the data
+// directory into the given chain subdirectory, unless the subdirectory exists.
+func migrateToChainSubdir(datapath, subdirPath, chainIdentity string) error {
 	// check if default subdir "mainnet" exits
 	// NOTE: this assumes that if the migration has been run once, the "mainnet" dir will exist and will have necessary datum inside it
 	subdirPathInfo, err := os.Stat(subdirPath)
 	if err == nil {
+		if !subdirPathInfo.IsDir() {
+			return fmt.Errorf(`%v: found file named '%v' in EthereumClassic datadir,
+			which conflicts with default chain directory naming convention: %v`, ErrDirectoryStructure, chainIdentity, subdirPath)
+		}
 		// dir already exists
 		return nil
 	}
-	if subdirPathInfo != nil && !subdirPathInfo.IsDir() {
-		return fmt.Errorf(`%v: found file named '%v' in EthereumClassic datadir,
-			which conflicts with default chain directory naming convention: %v`, ErrDirectoryStructure, chainIdentity, subdirPath)
-	}
 
 	// 3.3 testnet uses subdir '/testnet'
 	if chainIdentitiesMorden[chainIdentity] {
@@ -278,3 +290,48 @@ func migrateToChainSubdirIfNecessary(ctx *cli.Context) error {
 	}
 	return nil
 }
+
+// migrateChainDatabaseLayout upgrades the keys of the chain database in the
+// chain data directory to the current layout, if there is a database.
+func migrateChainDatabaseLayout(dir string) error {
+	path := filepath.Join(dir, "chaindata")
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil
+	}
+	db, err := ethdb.NewLDBDatabase(path, 0, 0)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return eth.UpgradeChainDatabase(db)
+}
+
+// dataDirMigrations returns the upgrades of the chain data directory layout by
+// ascending version. Changes to the layout are made by appending a migration.
+func dataDirMigrations(ctx *cli.Context) []node.DataDirMigration {
+	return []node.DataDirMigration{
+		{
+			Version: 1,
+			Name:    "move keystore and data of geth <= 3.3 into the chain subdirectory",
+			Migrate: func(string) error { return migrateToChainSubdirIfNecessary(ctx) },
+		},
+		{
+			Version: 2,
+			Name:    "split combined blocks into headers and bodies and add log bloom bins",
+			Migrate: migrateChainDatabaseLayout,
+		},
+	}
+}
+
+// mustMigrateDataDir upgrades the chain data directory to the latest layout,
+// once and before any of it is opened.
+func mustMigrateDataDir(ctx *cli.Context) {
+	if dataDirMigrated {
+		return
+	}
+	dataDirMigrated = true
+
+	if err := node.MigrateDataDir(MustMakeChainDataDir(ctx), dataDirMigrations(ctx)); err != nil {
+		glog.Fatalf("%v: %v", ErrDirectoryStructure, err)
+	}
+}
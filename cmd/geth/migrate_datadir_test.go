@@ -0,0 +1,80 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// makeMigrationDataDir creates a data directory holding the given directories
+// and files, each file holding its name.
+func makeMigrationDataDir(t *testing.T, dirs, files []string) string {
+	datapath, err := ioutil.TempDir("", "geth-migrate-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range dirs {
+		if err := os.MkdirAll(filepath.Join(datapath, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, file := range files {
+		if err := ioutil.WriteFile(filepath.Join(datapath, file), []byte(file), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return datapath
+}
+
+func checkExists(t *testing.T, path string, want bool) {
+	if _, err := os.Stat(path); (err == nil) != want {
+		t.Errorf("%s: exists %v, want %v", path, err == nil, want)
+	}
+}
+
+func TestMigrateToChainSubdirMainnet(t *testing.T) {
+	datapath := makeMigrationDataDir(t, []string{"chaindata", "keystore", "nodes"}, []string{"nodekey"})
+	defer os.RemoveAll(datapath)
+
+	subdir := filepath.Join(datapath, "mainnet")
+	if err := migrateToChainSubdir(datapath, subdir, "mainnet"); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"chaindata", "keystore", "nodes", "nodekey"} {
+		checkExists(t, filepath.Join(datapath, name), false)
+		checkExists(t, filepath.Join(subdir, name), true)
+	}
+}
+
+// Tests that nothing is moved once the chain subdirectory exists.
+func TestMigrateToChainSubdirExisting(t *testing.T) {
+	datapath := makeMigrationDataDir(t, []string{"chaindata", "mainnet/keystore"}, []string{"nodekey"})
+	defer os.RemoveAll(datapath)
+
+	subdir := filepath.Join(datapath, "mainnet")
+	if err := migrateToChainSubdir(datapath, subdir, "mainnet"); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"chaindata", "nodekey"} {
+		checkExists(t, filepath.Join(datapath, name), true)
+		checkExists(t, filepath.Join(subdir, name), false)
+	}
+	checkExists(t, filepath.Join(subdir, "keystore"), true)
+}
+
+// Tests that the testnet directory of geth 3.3 is renamed to the chain
+// subdirectory of morden, leaving the mainnet data in the root alone.
+func TestMigrateToChainSubdirTestnet(t *testing.T) {
+	datapath := makeMigrationDataDir(t, []string{"chaindata", "testnet/chaindata"}, []string{"testnet/nodekey"})
+	defer os.RemoveAll(datapath)
+
+	subdir := filepath.Join(datapath, "morden")
+	if err := migrateToChainSubdir(datapath, subdir, "morden"); err != nil {
+		t.Fatal(err)
+	}
+	checkExists(t, filepath.Join(datapath, "testnet"), false)
+	checkExists(t, filepath.Join(subdir, "chaindata"), true)
+	checkExists(t, filepath.Join(subdir, "nodekey"), true)
+	checkExists(t, filepath.Join(datapath, "chaindata"), true)
+}
@@ -77,7 +77,7 @@ func monitor(ctx *cli.Context) error {
 	if ctx.GlobalString(monitorCommandAttachFlag.Name) == "" {
 		endpoint = "ipc:" + node.DefaultIPCEndpoint(MustMakeChainDataDir(ctx))
 	}
-	client, err := rpc.NewClient(endpoint)
+	client, err := MakeRPCClient(ctx, endpoint)
 	if err != nil {
 		log.Fatal("attach to remote geth: ", err)
 	}
@@ -0,0 +1,41 @@
+#!/usr/bin/env bats
+
+: ${GETH_CMD:=$GOPATH/bin/geth}
+
+setup() {
+	DATA_DIR=`mktemp -d`
+	cp -a $BATS_TEST_DIRNAME/../../cmd/geth/testdata/testdatadir/. $DATA_DIR/
+}
+
+teardown() {
+	rm -fr $DATA_DIR
+}
+
+@test "replay 42 | replays block consistently" {
+	run $GETH_CMD --datadir $DATA_DIR replay 42
+	echo "$output"
+	[ "$status" -eq 0 ]
+	[[ "$output" == *"Block #42"*"replayed consistently"* ]]
+}
+
+@test "replay 1 10 | replays range consistently" {
+	run $GETH_CMD --datadir $DATA_DIR replay 1 10
+	echo "$output"
+	[ "$status" -eq 0 ]
+	[[ "$output" == *"Block #1 "*"replayed consistently"* ]]
+	[[ "$output" == *"Block #10 "*"replayed consistently"* ]]
+}
+
+@test "replay <noarg> | fails" {
+	run $GETH_CMD --datadir $DATA_DIR replay
+	echo "$output"
+	[ "$status" -gt 0 ]
+	[[ "$output" == *'use: $ geth replay <first> [<last>]'* ]]
+}
+
+@test "replay 420 | fails (420 > 384; block not yet in database)" {
+	run $GETH_CMD --datadir $DATA_DIR replay 420
+	echo "$output"
+	[ "$status" -gt 0 ]
+	[[ "$output" == *"block #420 not found"* ]]
+}
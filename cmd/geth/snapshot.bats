@@ -0,0 +1,50 @@
+#!/usr/bin/env bats
+
+: ${GETH_CMD:=$GOPATH/bin/geth}
+
+setup() {
+	DATA_DIR=`mktemp -d`
+	cp -a $BATS_TEST_DIRNAME/../../cmd/geth/testdata/testdatadir/. $DATA_DIR/
+
+	# A fresh node of the same chain, with nothing beyond genesis
+	FRESH_DIR=`mktemp -d`
+	cp -a $BATS_TEST_DIRNAME/../../cmd/geth/testdata/testdatadir/. $FRESH_DIR/
+	$GETH_CMD --datadir $FRESH_DIR rollback 0
+}
+
+teardown() {
+	rm -fr $DATA_DIR $FRESH_DIR
+}
+
+@test "export-snapshot, import-snapshot --hash | bootstraps a fresh node" {
+	run $GETH_CMD --datadir $DATA_DIR export-snapshot --blocks 10 $DATA_DIR/snapshot.gz
+	echo "$output"
+	[ "$status" -eq 0 ]
+	[[ "$output" == *"Exported snapshot of block #384 [d3d5d5c1b501a00e76cbd467f2c670e436119b63974d19652d0d2d35bbc79cf3] with 10 blocks"* ]]
+
+	run $GETH_CMD --datadir $FRESH_DIR import-snapshot --hash 0xd3d5d5c1b501a00e76cbd467f2c670e436119b63974d19652d0d2d35bbc79cf3 $DATA_DIR/snapshot.gz
+	echo "$output"
+	[ "$status" -eq 0 ]
+	[[ "$output" == *"Imported snapshot of block #384"* ]]
+
+	run $GETH_CMD --datadir $FRESH_DIR verify-chain 376 384
+	echo "$output"
+	[ "$status" -eq 0 ]
+	[[ "$output" == *"Verified blocks #376 to #384 of #384, 0 inconsistent"* ]]
+
+	run $GETH_CMD --datadir $FRESH_DIR import-snapshot $DATA_DIR/snapshot.gz
+	echo "$output"
+	[ "$status" -ne 0 ]
+	[[ "$output" == *"chain already holds blocks beyond genesis"* ]]
+}
+
+@test "import-snapshot --hash | rejects a snapshot of another block" {
+	run $GETH_CMD --datadir $DATA_DIR export-snapshot $DATA_DIR/snapshot
+	echo "$output"
+	[ "$status" -eq 0 ]
+
+	run $GETH_CMD --datadir $FRESH_DIR import-snapshot --hash 0x30c0e6b7b1aa1a4f2b3e8c19a3a6b3bdbb3a1a1e8fd3eba6e4c39ff0e4d2a0c1 $DATA_DIR/snapshot
+	echo "$output"
+	[ "$status" -ne 0 ]
+	[[ "$output" == *"head block mismatch"* ]]
+}
@@ -57,14 +57,22 @@ var AppHelpFlagGroups = []flagGroup{
 		Flags: []cli.Flag{
 			DataDirFlag,
 			ChainIdentityFlag,
+			OverrideChainConfigFlag,
 			KeyStoreDirFlag,
 			NetworkIdFlag,
 			DevModeFlag,
 			NodeNameFlag,
 			FastSyncFlag,
+			HeaderOnlyFlag,
 			LightKDFFlag,
 			CacheFlag,
+			CacheDatabaseFlag,
+			CacheTrieFlag,
+			CachePreimagesFlag,
 			BlockchainVersionFlag,
+			FutureDriftFlag,
+			ForensicsDirFlag,
+			TraceIndexFlag,
 		},
 	},
 	{
@@ -72,7 +80,10 @@ var AppHelpFlagGroups = []flagGroup{
 		Flags: []cli.Flag{
 			UnlockedAccountFlag,
 			PasswordFileFlag,
+			PasswordEnvFlag,
+			AllowInsecureUnlockFlag,
 			AccountsIndexFlag,
+			SmartCardDaemonFlag,
 		},
 	},
 	{
@@ -87,10 +98,31 @@ var AppHelpFlagGroups = []flagGroup{
 			WSPortFlag,
 			WSApiFlag,
 			WSAllowedOriginsFlag,
+			WSMaxConnectionsFlag,
+			WSMaxSubscriptionsFlag,
+			WSIdleTimeoutFlag,
 			IPCDisabledFlag,
 			IPCApiFlag,
 			IPCPathFlag,
+			IPCModeFlag,
+			IPCGroupFlag,
+			IPCAuthFlag,
 			RPCCORSDomainFlag,
+			RPCAccessLogFlag,
+			RPCSlowQueryFlag,
+			RPCAuditLogFlag,
+			RPCAuditKeyFlag,
+			RPCLimitsFlag,
+			RPCTLSCertFlag,
+			RPCTLSKeyFlag,
+			RPCTLSClientCAFlag,
+			FilterTimeoutFlag,
+			FilterMaxBlocksFlag,
+			FilterMaxResultsFlag,
+			NameRegistryFlag,
+			SafeDepthFlag,
+			ConfirmedDepthFlag,
+			ExposeAccountsFlag,
 			JSpathFlag,
 			ExecFlag,
 			PreloadJSFlag,
@@ -105,8 +137,10 @@ var AppHelpFlagGroups = []flagGroup{
 			MaxPendingPeersFlag,
 			NATFlag,
 			NoDiscoverFlag,
+			ClockCheckFlag,
 			NodeKeyFileFlag,
 			NodeKeyHexFlag,
+			NodeKeyPasswordFlag,
 		},
 	},
 	{
@@ -122,6 +156,23 @@ var AppHelpFlagGroups = []flagGroup{
 			ExtraDataFlag,
 		},
 	},
+	{
+		Name: "TRANSACTION POOL",
+		Flags: []cli.Flag{
+			TxPoolAccountSlotsFlag,
+			TxPoolGlobalSlotsFlag,
+			TxPoolAccountQueueFlag,
+			TxPoolGlobalQueueFlag,
+			TxPoolLifetimeFlag,
+			TxPoolMaxTxSizeFlag,
+			TxPoolMaxTxGasFlag,
+			TxPoolMaxInitCodeSizeFlag,
+			TxPoolLocalsFlag,
+			TxPoolNoLocalsFlag,
+			TxPoolJournalFlag,
+			TxPoolRejournalFlag,
+		},
+	},
 	{
 		Name: "GAS PRICE ORACLE",
 		Flags: []cli.Flag{
@@ -146,6 +197,8 @@ var AppHelpFlagGroups = []flagGroup{
 			BacktraceAtFlag,
 			MetricsFlag,
 			FakePoWFlag,
+			GasAuditFlag,
+			OpcodeMetricsFlag,
 		},
 	},
 	{
@@ -0,0 +1,40 @@
+#!/usr/bin/env bats
+
+: ${GETH_CMD:=$GOPATH/bin/geth}
+
+setup() {
+	DATA_DIR=`mktemp -d`
+	cp -a $BATS_TEST_DIRNAME/../../cmd/geth/testdata/testdatadir/. $DATA_DIR/
+}
+
+teardown() {
+	rm -fr $DATA_DIR
+}
+
+@test "verify-chain 1 10 | verifies range" {
+	run $GETH_CMD --datadir $DATA_DIR verify-chain 1 10
+	echo "$output"
+	[ "$status" -eq 0 ]
+	[[ "$output" == *"Verified blocks #1 to #10 of #10, 0 inconsistent"* ]]
+}
+
+@test "verify-chain --reexec --progress | resumes after the saved block" {
+	run $GETH_CMD --datadir $DATA_DIR verify-chain --reexec --progress $DATA_DIR/progress 0 100
+	echo "$output"
+	[ "$status" -eq 0 ]
+	[ "$(cat $DATA_DIR/progress)" -eq 100 ]
+
+	run $GETH_CMD --datadir $DATA_DIR verify-chain --reexec --progress $DATA_DIR/progress
+	echo "$output"
+	[ "$status" -eq 0 ]
+	[[ "$output" == *"Resuming verification after block #100"* ]]
+	[[ "$output" == *"Verified blocks #101 to #384 of #384, 0 inconsistent"* ]]
+	[ "$(cat $DATA_DIR/progress)" -eq 384 ]
+}
+
+@test "verify-chain 420 | fails (420 > 384; block not yet in database)" {
+	run $GETH_CMD --datadir $DATA_DIR verify-chain 420
+	echo "$output"
+	[ "$status" -gt 0 ]
+	[[ "$output" == *"block #420 not found"* ]]
+}
@@ -146,6 +146,9 @@ func (m callmsg) Value() *big.Int {
 func (m callmsg) Data() []byte {
 	return m.data
 }
+func (m callmsg) AccessList() types.AccessList {
+	return nil
+}
 
 // Call forms a transaction from the given arguments and tries to execute it on
 // a private VM with a copy of the state. Any changes are therefore only temporary
@@ -287,8 +290,7 @@ func (be *registryAPIBackend) Transact(fromStr, toStr, nonceStr, valueStr, gasSt
 		return "", err
 	}
 
-	be.txPool.SetLocal(signedTx)
-	if err := be.txPool.Add(signedTx); err != nil {
+	if err := be.txPool.AddLocal(signedTx); err != nil {
 		return "", nil
 	}
 
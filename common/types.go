@@ -167,6 +167,18 @@ func (a Address) MarshalJSON() ([]byte, error) {
 	return json.Marshal(a.Hex())
 }
 
+// MarshalText serializes the address as hex, allowing it to be used as a JSON
+// object key.
+func (a Address) MarshalText() ([]byte, error) {
+	return []byte(a.Hex()), nil
+}
+
+// UnmarshalText parses a hex address, allowing it to be used as a JSON object
+// key.
+func (a *Address) UnmarshalText(text []byte) error {
+	return a.UnmarshalJSON(text)
+}
+
 // Parse address from raw json data
 func (a *Address) UnmarshalJSON(data []byte) error {
 	if len(data) > 2 && data[0] == '"' && data[len(data)-1] == '"' {
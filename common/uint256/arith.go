@@ -0,0 +1,225 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package uint256
+
+import "math/bits"
+
+// The 64-bit word primitives below follow the portable implementations of
+// Hacker's Delight.
+
+// add64 returns the sum with carry of x, y and carry, which must be 0 or 1.
+func add64(x, y, carry uint64) (sum, carryOut uint64) {
+	sum = x + y + carry
+	carryOut = ((x & y) | ((x | y) &^ sum)) >> 63
+	return
+}
+
+// sub64 returns the difference of x, y and borrow, which must be 0 or 1.
+func sub64(x, y, borrow uint64) (diff, borrowOut uint64) {
+	diff = x - y - borrow
+	borrowOut = ((^x & y) | (^(x ^ y) & diff)) >> 63
+	return
+}
+
+// mul64 returns the 128-bit product of x and y.
+func mul64(x, y uint64) (hi, lo uint64) {
+	const mask32 = 1<<32 - 1
+	x0, x1 := x&mask32, x>>32
+	y0, y1 := y&mask32, y>>32
+	w0 := x0 * y0
+	t := x1*y0 + w0>>32
+	w1, w2 := t&mask32, t>>32
+	w1 += x0 * y1
+	hi = x1*y1 + w2 + w1>>32
+	lo = x * y
+	return
+}
+
+// div64 returns the quotient and remainder of (hi, lo) divided by y. The
+// quotient must fit in 64 bits, that is hi must be less than y.
+func div64(hi, lo, y uint64) (quo, rem uint64) {
+	const (
+		two32  = 1 << 32
+		mask32 = two32 - 1
+	)
+	s := uint(bits.LeadingZeros64(y))
+	y <<= s
+
+	yn1, yn0 := y>>32, y&mask32
+	un32 := hi<<s | lo>>(64-s)
+	un10 := lo << s
+	un1, un0 := un10>>32, un10&mask32
+
+	q1 := un32 / yn1
+	rhat := un32 - q1*yn1
+	for q1 >= two32 || q1*yn0 > two32*rhat+un1 {
+		q1--
+		rhat += yn1
+		if rhat >= two32 {
+			break
+		}
+	}
+	un21 := un32*two32 + un1 - q1*y
+
+	q0 := un21 / yn1
+	rhat = un21 - q0*yn1
+	for q0 >= two32 || q0*yn0 > two32*rhat+un0 {
+		q0--
+		rhat += yn1
+		if rhat >= two32 {
+			break
+		}
+	}
+	return q1*two32 + q0, (un21*two32 + un0 - q0*y) >> s
+}
+
+// udivrem divides u by d, storing the quotient in quot, which must hold
+// len(u) words, and returning the remainder. d must not be zero. It follows
+// Knuth's algorithm D with 64-bit digits.
+func udivrem(quot, u []uint64, d *Int) (rem Int) {
+	dLen := 0
+	for i := len(d) - 1; i >= 0; i-- {
+		if d[i] != 0 {
+			dLen = i + 1
+			break
+		}
+	}
+	uLen := 0
+	for i := len(u) - 1; i >= 0; i-- {
+		if u[i] != 0 {
+			uLen = i + 1
+			break
+		}
+	}
+	if uLen < dLen {
+		copy(rem[:], u)
+		return rem
+	}
+
+	// Normalize the divisor so that its top bit is set, shifting the
+	// dividend along into an extra word.
+	shift := uint(bits.LeadingZeros64(d[dLen-1]))
+
+	var dnStorage Int
+	dn := dnStorage[:dLen]
+	for i := dLen - 1; i > 0; i-- {
+		dn[i] = d[i]<<shift | d[i-1]>>(64-shift)
+	}
+	dn[0] = d[0] << shift
+
+	var unStorage [9]uint64
+	un := unStorage[:uLen+1]
+	un[uLen] = u[uLen-1] >> (64 - shift)
+	for i := uLen - 1; i > 0; i-- {
+		un[i] = u[i]<<shift | u[i-1]>>(64-shift)
+	}
+	un[0] = u[0] << shift
+
+	if dLen == 1 {
+		r := un[uLen]
+		for j := uLen - 1; j >= 0; j-- {
+			quot[j], r = div64(r, un[j], dn[0])
+		}
+		rem[0] = r >> shift
+		return rem
+	}
+	udivremKnuth(quot, un, dn)
+
+	for i := 0; i < dLen-1; i++ {
+		rem[i] = un[i]>>shift | un[i+1]<<(64-shift)
+	}
+	rem[dLen-1] = un[dLen-1] >> shift
+	return rem
+}
+
+// udivremKnuth divides the normalized u by the normalized d, at least two
+// words long, leaving the remainder in u.
+func udivremKnuth(quot, u, d []uint64) {
+	dh, dl := d[len(d)-1], d[len(d)-2]
+
+	for j := len(u) - len(d) - 1; j >= 0; j-- {
+		u2, u1, u0 := u[j+len(d)], u[j+len(d)-1], u[j+len(d)-2]
+
+		// Estimate the quotient digit from the top words, which is at most
+		// one too large once corrected.
+		var qhat, rhat, carry uint64
+		if u2 >= dh {
+			qhat = ^uint64(0)
+			rhat, carry = add64(u1, dh, 0)
+		} else {
+			qhat, rhat = div64(u2, u1, dh)
+		}
+		for carry == 0 {
+			ph, pl := mul64(qhat, dl)
+			if ph < rhat || (ph == rhat && pl <= u0) {
+				break
+			}
+			qhat--
+			rhat, carry = add64(rhat, dh, 0)
+		}
+
+		// Multiply and subtract, adding back if too much was subtracted.
+		borrow := subMulTo(u[j:], d, qhat)
+		u[j+len(d)] = u2 - borrow
+		if u2 < borrow {
+			qhat--
+			u[j+len(d)] += addTo(u[j:], d)
+		}
+		quot[j] = qhat
+	}
+}
+
+// subMulTo computes x -= y * multiplier, returning the borrow.
+func subMulTo(x, y []uint64, multiplier uint64) uint64 {
+	var borrow uint64
+	for i := 0; i < len(y); i++ {
+		s, carry1 := sub64(x[i], borrow, 0)
+		ph, pl := mul64(y[i], multiplier)
+		t, carry2 := sub64(s, pl, 0)
+		x[i] = t
+		borrow = ph + carry1 + carry2
+	}
+	return borrow
+}
+
+// addTo computes x += y, returning the carry.
+func addTo(x, y []uint64) uint64 {
+	var carry uint64
+	for i := 0; i < len(y); i++ {
+		x[i], carry = add64(x[i], y[i], carry)
+	}
+	return carry
+}
+
+// umul returns the full 512-bit product of x and y.
+func umul(x, y *Int) (res [8]uint64) {
+	for i := 0; i < 4; i++ {
+		var carry uint64
+		for j := 0; j < 4; j++ {
+			hi, lo := mul64(x[i], y[j])
+			var c uint64
+			lo, c = add64(lo, res[i+j], 0)
+			hi += c
+			lo, c = add64(lo, carry, 0)
+			hi += c
+			res[i+j] = lo
+			carry = hi
+		}
+		res[i+4] = carry
+	}
+	return res
+}
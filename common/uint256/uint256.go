@@ -0,0 +1,450 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package uint256 implements fixed size 256-bit integers with the wrapping
+// arithmetic of the EVM. Unlike big.Int, its values don't allocate, so they
+// can be kept by value on the EVM stack.
+//
+// Integers are unsigned; the signed operations interpret them in two's
+// complement. As with big.Int, the operations set the receiver to their
+// result and return it, and the receiver may alias the operands.
+package uint256
+
+import (
+	"math/big"
+	"math/bits"
+)
+
+// Int is a 256-bit unsigned integer, stored as four 64-bit words with the
+// least significant word first.
+type Int [4]uint64
+
+// NewInt returns a new Int set to v.
+func NewInt(v uint64) *Int {
+	return &Int{v}
+}
+
+// FromBig returns a new Int set to the lowest 256 bits of b in two's
+// complement.
+func FromBig(b *big.Int) *Int {
+	return new(Int).SetFromBig(b)
+}
+
+// Set sets z to x and returns z.
+func (z *Int) Set(x *Int) *Int {
+	*z = *x
+	return z
+}
+
+// Clear sets z to 0 and returns z.
+func (z *Int) Clear() *Int {
+	*z = Int{}
+	return z
+}
+
+// SetOne sets z to 1 and returns z.
+func (z *Int) SetOne() *Int {
+	*z = Int{1}
+	return z
+}
+
+// SetUint64 sets z to v and returns z.
+func (z *Int) SetUint64(v uint64) *Int {
+	*z = Int{v}
+	return z
+}
+
+// SetBytes sets z to the big-endian unsigned integer b, keeping its lowest
+// 256 bits, and returns z.
+func (z *Int) SetBytes(b []byte) *Int {
+	if len(b) > 32 {
+		b = b[len(b)-32:]
+	}
+	z.Clear()
+	for i, j := len(b)-1, uint(0); i >= 0; i, j = i-1, j+1 {
+		z[j/8] |= uint64(b[i]) << (8 * (j % 8))
+	}
+	return z
+}
+
+// SetFromBig sets z to the lowest 256 bits of b in two's complement and
+// returns z.
+func (z *Int) SetFromBig(b *big.Int) *Int {
+	z.Clear()
+	words := b.Bits()
+	if bits.UintSize == 64 {
+		for i := 0; i < len(words) && i < 4; i++ {
+			z[i] = uint64(words[i])
+		}
+	} else {
+		for i := 0; i < len(words) && i < 8; i++ {
+			z[i/2] |= uint64(words[i]) << (32 * uint(i%2))
+		}
+	}
+	if b.Sign() < 0 {
+		z.Neg(z)
+	}
+	return z
+}
+
+// ToBig returns z as a big.Int.
+func (z *Int) ToBig() *big.Int {
+	b := z.Bytes32()
+	return new(big.Int).SetBytes(b[:])
+}
+
+// Bytes32 returns z as 32 big-endian bytes.
+func (z *Int) Bytes32() (b [32]byte) {
+	for i := 0; i < 32; i++ {
+		b[31-i] = byte(z[i/8] >> (8 * uint(i%8)))
+	}
+	return b
+}
+
+// Bytes returns z as big-endian bytes without leading zeros, like
+// big.Int.Bytes.
+func (z *Int) Bytes() []byte {
+	b := z.Bytes32()
+	return b[32-z.ByteLen():]
+}
+
+// Uint64 returns the lowest 64 bits of z.
+func (z *Int) Uint64() uint64 {
+	return z[0]
+}
+
+// IsUint64 reports whether z fits in 64 bits.
+func (z *Int) IsUint64() bool {
+	return z[1]|z[2]|z[3] == 0
+}
+
+// IsZero reports whether z is 0.
+func (z *Int) IsZero() bool {
+	return z[0]|z[1]|z[2]|z[3] == 0
+}
+
+// BitLen returns the number of bits required to represent z.
+func (z *Int) BitLen() int {
+	for i := 3; i >= 0; i-- {
+		if z[i] != 0 {
+			return i*64 + bits.Len64(z[i])
+		}
+	}
+	return 0
+}
+
+// ByteLen returns the number of bytes required to represent z.
+func (z *Int) ByteLen() int {
+	return (z.BitLen() + 7) / 8
+}
+
+// Sign returns the sign of z interpreted in two's complement: -1, 0 or 1.
+func (z *Int) Sign() int {
+	if z.IsZero() {
+		return 0
+	}
+	if z[3] < 1<<63 {
+		return 1
+	}
+	return -1
+}
+
+// Eq reports whether z equals x.
+func (z *Int) Eq(x *Int) bool {
+	return *z == *x
+}
+
+// Cmp compares z and x as unsigned integers, returning -1, 0 or 1.
+func (z *Int) Cmp(x *Int) int {
+	for i := 3; i >= 0; i-- {
+		if z[i] < x[i] {
+			return -1
+		}
+		if z[i] > x[i] {
+			return 1
+		}
+	}
+	return 0
+}
+
+// Lt reports whether z < x as unsigned integers.
+func (z *Int) Lt(x *Int) bool {
+	return z.Cmp(x) < 0
+}
+
+// Gt reports whether z > x as unsigned integers.
+func (z *Int) Gt(x *Int) bool {
+	return z.Cmp(x) > 0
+}
+
+// Slt reports whether z < x as signed integers.
+func (z *Int) Slt(x *Int) bool {
+	zs, xs := z.Sign(), x.Sign()
+	switch {
+	case zs >= 0 && xs < 0:
+		return false
+	case zs < 0 && xs >= 0:
+		return true
+	}
+	return z.Lt(x)
+}
+
+// Sgt reports whether z > x as signed integers.
+func (z *Int) Sgt(x *Int) bool {
+	return x.Slt(z)
+}
+
+// Add sets z to x + y modulo 2^256 and returns z.
+func (z *Int) Add(x, y *Int) *Int {
+	var carry uint64
+	z[0], carry = add64(x[0], y[0], 0)
+	z[1], carry = add64(x[1], y[1], carry)
+	z[2], carry = add64(x[2], y[2], carry)
+	z[3], _ = add64(x[3], y[3], carry)
+	return z
+}
+
+// Sub sets z to x - y modulo 2^256 and returns z.
+func (z *Int) Sub(x, y *Int) *Int {
+	var borrow uint64
+	z[0], borrow = sub64(x[0], y[0], 0)
+	z[1], borrow = sub64(x[1], y[1], borrow)
+	z[2], borrow = sub64(x[2], y[2], borrow)
+	z[3], _ = sub64(x[3], y[3], borrow)
+	return z
+}
+
+// Neg sets z to -x modulo 2^256 and returns z.
+func (z *Int) Neg(x *Int) *Int {
+	return z.Sub(new(Int), x)
+}
+
+// Abs sets z to the absolute value of x interpreted in two's complement and
+// returns z.
+func (z *Int) Abs(x *Int) *Int {
+	if x.Sign() >= 0 {
+		return z.Set(x)
+	}
+	return z.Neg(x)
+}
+
+// Mul sets z to x * y modulo 2^256 and returns z.
+func (z *Int) Mul(x, y *Int) *Int {
+	p := umul(x, y)
+	copy(z[:], p[:4])
+	return z
+}
+
+// Div sets z to x / y, or 0 if y is 0, and returns z.
+func (z *Int) Div(x, y *Int) *Int {
+	if y.IsZero() || y.Gt(x) {
+		return z.Clear()
+	}
+	if x.IsUint64() {
+		return z.SetUint64(x[0] / y[0])
+	}
+	var quot Int
+	udivrem(quot[:], x[:], y)
+	return z.Set(&quot)
+}
+
+// Mod sets z to x modulo y, or 0 if y is 0, and returns z.
+func (z *Int) Mod(x, y *Int) *Int {
+	if y.IsZero() {
+		return z.Clear()
+	}
+	if x.Lt(y) {
+		return z.Set(x)
+	}
+	if x.IsUint64() {
+		return z.SetUint64(x[0] % y[0])
+	}
+	var quot Int
+	rem := udivrem(quot[:], x[:], y)
+	return z.Set(&rem)
+}
+
+// SDiv sets z to x / y as signed integers truncated towards zero, or 0 if y
+// is 0, and returns z.
+func (z *Int) SDiv(x, y *Int) *Int {
+	neg := x.Sign()*y.Sign() < 0
+	var xa, ya Int
+	z.Div(xa.Abs(x), ya.Abs(y))
+	if neg {
+		z.Neg(z)
+	}
+	return z
+}
+
+// SMod sets z to x modulo y as signed integers, with the sign of x, or 0 if
+// y is 0, and returns z.
+func (z *Int) SMod(x, y *Int) *Int {
+	neg := x.Sign() < 0
+	var xa, ya Int
+	z.Mod(xa.Abs(x), ya.Abs(y))
+	if neg {
+		z.Neg(z)
+	}
+	return z
+}
+
+// AddMod sets z to (x + y) modulo m without wrapping the sum, or 0 if m is
+// 0, and returns z.
+func (z *Int) AddMod(x, y, m *Int) *Int {
+	if m.IsZero() {
+		return z.Clear()
+	}
+	var (
+		sum   [5]uint64
+		carry uint64
+	)
+	for i := 0; i < 4; i++ {
+		sum[i], carry = add64(x[i], y[i], carry)
+	}
+	sum[4] = carry
+
+	var quot [5]uint64
+	rem := udivrem(quot[:], sum[:], m)
+	return z.Set(&rem)
+}
+
+// MulMod sets z to (x * y) modulo m without wrapping the product, or 0 if m
+// is 0, and returns z.
+func (z *Int) MulMod(x, y, m *Int) *Int {
+	if m.IsZero() {
+		return z.Clear()
+	}
+	p := umul(x, y)
+
+	var quot [8]uint64
+	rem := udivrem(quot[:], p[:], m)
+	return z.Set(&rem)
+}
+
+// Exp sets z to base ** exponent modulo 2^256 and returns z.
+func (z *Int) Exp(base, exponent *Int) *Int {
+	var (
+		res = Int{1}
+		b   = *base
+		e   = *exponent
+	)
+	for n := e.BitLen(); n > 0; n-- {
+		if e[0]&1 == 1 {
+			res.Mul(&res, &b)
+		}
+		b.Mul(&b, &b)
+		e.Rsh(&e, 1)
+	}
+	return z.Set(&res)
+}
+
+// And sets z to x & y and returns z.
+func (z *Int) And(x, y *Int) *Int {
+	z[0], z[1], z[2], z[3] = x[0]&y[0], x[1]&y[1], x[2]&y[2], x[3]&y[3]
+	return z
+}
+
+// Or sets z to x | y and returns z.
+func (z *Int) Or(x, y *Int) *Int {
+	z[0], z[1], z[2], z[3] = x[0]|y[0], x[1]|y[1], x[2]|y[2], x[3]|y[3]
+	return z
+}
+
+// Xor sets z to x ^ y and returns z.
+func (z *Int) Xor(x, y *Int) *Int {
+	z[0], z[1], z[2], z[3] = x[0]^y[0], x[1]^y[1], x[2]^y[2], x[3]^y[3]
+	return z
+}
+
+// Not sets z to ^x and returns z.
+func (z *Int) Not(x *Int) *Int {
+	z[0], z[1], z[2], z[3] = ^x[0], ^x[1], ^x[2], ^x[3]
+	return z
+}
+
+// Lsh sets z to x << n modulo 2^256 and returns z.
+func (z *Int) Lsh(x *Int, n uint) *Int {
+	if n >= 256 {
+		return z.Clear()
+	}
+	words, n := int(n/64), n%64
+	var res Int
+	for i := 3; i >= words; i-- {
+		res[i] = x[i-words] << n
+		if n > 0 && i-words > 0 {
+			res[i] |= x[i-words-1] >> (64 - n)
+		}
+	}
+	return z.Set(&res)
+}
+
+// Rsh sets z to x >> n, shifting in zeros, and returns z.
+func (z *Int) Rsh(x *Int, n uint) *Int {
+	if n >= 256 {
+		return z.Clear()
+	}
+	words, n := int(n/64), n%64
+	var res Int
+	for i := 0; i < 4-words; i++ {
+		res[i] = x[i+words] >> n
+		if n > 0 && i+words < 3 {
+			res[i] |= x[i+words+1] << (64 - n)
+		}
+	}
+	return z.Set(&res)
+}
+
+// SRsh sets z to x >> n, shifting in the sign bit of x, and returns z.
+func (z *Int) SRsh(x *Int, n uint) *Int {
+	if x.Sign() >= 0 {
+		return z.Rsh(x, n)
+	}
+	if n >= 256 {
+		return z.Not(new(Int))
+	}
+	var mask Int
+	mask.Not(&mask)
+	mask.Lsh(&mask, 256-n)
+	z.Rsh(x, n)
+	return z.Or(z, &mask)
+}
+
+// Byte sets z to the n'th byte of z counted from the most significant one,
+// or 0 if n is not less than 32, and returns z.
+func (z *Int) Byte(n *Int) *Int {
+	if !n.IsUint64() || n[0] >= 32 {
+		return z.Clear()
+	}
+	i := n[0]
+	return z.SetUint64((z[3-i/8] >> (56 - 8*(i%8))) & 0xff)
+}
+
+// SignExtend sets z to x sign extended from its (back + 1)'th lowest byte,
+// or to x if back is not less than 31, and returns z.
+func (z *Int) SignExtend(back, x *Int) *Int {
+	if !back.IsUint64() || back[0] >= 31 {
+		return z.Set(x)
+	}
+	bit := uint(back[0]*8 + 7)
+
+	var mask Int
+	mask.Lsh(NewInt(1), bit)
+	mask.Sub(&mask, NewInt(1))
+	if x[bit/64]&(1<<(bit%64)) != 0 {
+		return z.Or(x, mask.Not(&mask))
+	}
+	return z.And(x, &mask)
+}
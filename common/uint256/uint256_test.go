@@ -0,0 +1,238 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package uint256
+
+import (
+	"math/big"
+	"math/rand"
+	"testing"
+)
+
+var (
+	tt256   = new(big.Int).Lsh(big.NewInt(1), 256)
+	tt256m1 = new(big.Int).Sub(tt256, big.NewInt(1))
+	tt255   = new(big.Int).Lsh(big.NewInt(1), 255)
+)
+
+// u256 wraps x to 256 bits.
+func u256(x *big.Int) *big.Int {
+	return x.And(x, tt256m1)
+}
+
+// s256 interprets the 256-bit x in two's complement.
+func s256(x *big.Int) *big.Int {
+	if x.Cmp(tt255) < 0 {
+		return new(big.Int).Set(x)
+	}
+	return new(big.Int).Sub(x, tt256)
+}
+
+// testValues returns the edge case operands along with random ones of every
+// word length.
+func testValues(rnd *rand.Rand) []*big.Int {
+	values := []*big.Int{
+		big.NewInt(0), big.NewInt(1), big.NewInt(2), big.NewInt(255),
+		new(big.Int).SetUint64(1<<64 - 1), new(big.Int).Lsh(big.NewInt(1), 64),
+		new(big.Int).Lsh(big.NewInt(1), 128), new(big.Int).Sub(tt255, big.NewInt(1)),
+		new(big.Int).Set(tt255), new(big.Int).Set(tt256m1),
+	}
+	for words := 1; words <= 4; words++ {
+		for i := 0; i < 8; i++ {
+			b := make([]byte, 8*words)
+			rnd.Read(b)
+			values = append(values, new(big.Int).SetBytes(b))
+		}
+	}
+	return values
+}
+
+func TestConversions(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, x := range testValues(rnd) {
+		z := FromBig(x)
+		if z.ToBig().Cmp(x) != 0 {
+			t.Errorf("FromBig(%x).ToBig() = %x", x, z.ToBig())
+		}
+		if have := new(Int).SetBytes(x.Bytes()); !have.Eq(z) {
+			t.Errorf("SetBytes(%x) = %x, want %x", x, have.ToBig(), x)
+		}
+		if have := new(big.Int).SetBytes(z.Bytes()); have.Cmp(x) != 0 || len(z.Bytes()) != len(x.Bytes()) {
+			t.Errorf("Bytes(%x) = %x", x, z.Bytes())
+		}
+		if z.BitLen() != x.BitLen() {
+			t.Errorf("BitLen(%x) = %d, want %d", x, z.BitLen(), x.BitLen())
+		}
+		neg := new(big.Int).Neg(x)
+		if have, want := FromBig(neg).ToBig(), u256(new(big.Int).Set(neg)); have.Cmp(want) != 0 {
+			t.Errorf("FromBig(%x) = %x, want %x", neg, have, want)
+		}
+	}
+	if have := FromBig(new(big.Int).Add(tt256, big.NewInt(7))); !have.Eq(NewInt(7)) {
+		t.Errorf("FromBig(2^256+7) = %x, want 7", have.ToBig())
+	}
+}
+
+func TestBinaryOps(t *testing.T) {
+	ops := []struct {
+		name string
+		fn   func(z, x, y *Int) *Int
+		ref  func(x, y *big.Int) *big.Int
+	}{
+		{"Add", (*Int).Add, func(x, y *big.Int) *big.Int { return u256(new(big.Int).Add(x, y)) }},
+		{"Sub", (*Int).Sub, func(x, y *big.Int) *big.Int { return u256(new(big.Int).Sub(x, y)) }},
+		{"Mul", (*Int).Mul, func(x, y *big.Int) *big.Int { return u256(new(big.Int).Mul(x, y)) }},
+		{"Div", (*Int).Div, func(x, y *big.Int) *big.Int {
+			if y.Sign() == 0 {
+				return new(big.Int)
+			}
+			return new(big.Int).Div(x, y)
+		}},
+		{"Mod", (*Int).Mod, func(x, y *big.Int) *big.Int {
+			if y.Sign() == 0 {
+				return new(big.Int)
+			}
+			return new(big.Int).Mod(x, y)
+		}},
+		{"SDiv", (*Int).SDiv, func(x, y *big.Int) *big.Int {
+			if y.Sign() == 0 {
+				return new(big.Int)
+			}
+			return u256(new(big.Int).Quo(s256(x), s256(y)))
+		}},
+		{"SMod", (*Int).SMod, func(x, y *big.Int) *big.Int {
+			if y.Sign() == 0 {
+				return new(big.Int)
+			}
+			return u256(new(big.Int).Rem(s256(x), s256(y)))
+		}},
+		{"Exp", (*Int).Exp, func(x, y *big.Int) *big.Int { return new(big.Int).Exp(x, y, tt256) }},
+		{"And", (*Int).And, func(x, y *big.Int) *big.Int { return new(big.Int).And(x, y) }},
+		{"Or", (*Int).Or, func(x, y *big.Int) *big.Int { return new(big.Int).Or(x, y) }},
+		{"Xor", (*Int).Xor, func(x, y *big.Int) *big.Int { return new(big.Int).Xor(x, y) }},
+	}
+	rnd := rand.New(rand.NewSource(2))
+	values := testValues(rnd)
+	for _, op := range ops {
+		for _, x := range values {
+			for _, y := range values {
+				want := op.ref(x, y)
+				if have := op.fn(new(Int), FromBig(x), FromBig(y)).ToBig(); have.Cmp(want) != 0 {
+					t.Errorf("%s(%x, %x) = %x, want %x", op.name, x, y, have, want)
+				}
+				// The receiver may alias the operands.
+				z := FromBig(x)
+				if have := op.fn(z, z, FromBig(y)).ToBig(); have.Cmp(want) != 0 {
+					t.Errorf("%s(%x, %x) aliased = %x, want %x", op.name, x, y, have, want)
+				}
+			}
+		}
+	}
+}
+
+func TestModularOps(t *testing.T) {
+	rnd := rand.New(rand.NewSource(3))
+	values := testValues(rnd)
+	for _, x := range values {
+		for _, y := range values {
+			for _, m := range values {
+				want := new(big.Int)
+				if m.Sign() != 0 {
+					want.Add(x, y).Mod(want, m)
+				}
+				if have := new(Int).AddMod(FromBig(x), FromBig(y), FromBig(m)).ToBig(); have.Cmp(want) != 0 {
+					t.Errorf("AddMod(%x, %x, %x) = %x, want %x", x, y, m, have, want)
+				}
+				want = new(big.Int)
+				if m.Sign() != 0 {
+					want.Mul(x, y).Mod(want, m)
+				}
+				if have := new(Int).MulMod(FromBig(x), FromBig(y), FromBig(m)).ToBig(); have.Cmp(want) != 0 {
+					t.Errorf("MulMod(%x, %x, %x) = %x, want %x", x, y, m, have, want)
+				}
+			}
+		}
+	}
+}
+
+func TestComparisons(t *testing.T) {
+	rnd := rand.New(rand.NewSource(4))
+	values := testValues(rnd)
+	for _, x := range values {
+		for _, y := range values {
+			zx, zy := FromBig(x), FromBig(y)
+			if have, want := zx.Cmp(zy), x.Cmp(y); have != want {
+				t.Errorf("Cmp(%x, %x) = %d, want %d", x, y, have, want)
+			}
+			if have, want := zx.Slt(zy), s256(x).Cmp(s256(y)) < 0; have != want {
+				t.Errorf("Slt(%x, %x) = %v, want %v", x, y, have, want)
+			}
+			if have, want := zx.Sgt(zy), s256(x).Cmp(s256(y)) > 0; have != want {
+				t.Errorf("Sgt(%x, %x) = %v, want %v", x, y, have, want)
+			}
+		}
+	}
+}
+
+func TestShifts(t *testing.T) {
+	rnd := rand.New(rand.NewSource(5))
+	for _, x := range testValues(rnd) {
+		for _, n := range []uint{0, 1, 7, 63, 64, 65, 127, 128, 191, 200, 255, 256, 300} {
+			want := u256(new(big.Int).Lsh(x, n))
+			if have := new(Int).Lsh(FromBig(x), n).ToBig(); have.Cmp(want) != 0 {
+				t.Errorf("Lsh(%x, %d) = %x, want %x", x, n, have, want)
+			}
+			want = new(big.Int).Rsh(x, n)
+			if have := new(Int).Rsh(FromBig(x), n).ToBig(); have.Cmp(want) != 0 {
+				t.Errorf("Rsh(%x, %d) = %x, want %x", x, n, have, want)
+			}
+			want = u256(new(big.Int).Rsh(s256(x), n))
+			if have := new(Int).SRsh(FromBig(x), n).ToBig(); have.Cmp(want) != 0 {
+				t.Errorf("SRsh(%x, %d) = %x, want %x", x, n, have, want)
+			}
+		}
+	}
+}
+
+func TestByteAndSignExtend(t *testing.T) {
+	rnd := rand.New(rand.NewSource(6))
+	for _, x := range testValues(rnd) {
+		b := FromBig(x).Bytes32()
+		for n := uint64(0); n < 40; n++ {
+			want := uint64(0)
+			if n < 32 {
+				want = uint64(b[n])
+			}
+			if have := FromBig(x).Byte(NewInt(n)); !have.Eq(NewInt(want)) {
+				t.Errorf("Byte(%x, %d) = %x, want %x", x, n, have.ToBig(), want)
+			}
+
+			want2 := new(big.Int).Set(x)
+			if n < 31 {
+				bit := uint(n*8 + 7)
+				mask := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), bit), big.NewInt(1))
+				if x.Bit(int(bit)) == 1 {
+					want2.Or(x, new(big.Int).Xor(tt256m1, mask))
+				} else {
+					want2.And(x, mask)
+				}
+			}
+			if have := new(Int).SignExtend(NewInt(n), FromBig(x)).ToBig(); have.Cmp(want2) != 0 {
+				t.Errorf("SignExtend(%d, %x) = %x, want %x", n, x, have, want2)
+			}
+		}
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package clique
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/core/types"
+	"github.com/ellaism/go-ellaism/crypto"
+	"github.com/ellaism/go-ellaism/ethdb"
+)
+
+// testerChainReader is a chain of headers kept in memory.
+type testerChainReader struct {
+	headers []*types.Header
+}
+
+func (r *testerChainReader) CurrentHeader() *types.Header {
+	return r.headers[len(r.headers)-1]
+}
+
+func (r *testerChainReader) GetHeader(hash common.Hash) *types.Header {
+	for _, header := range r.headers {
+		if header.Hash() == hash {
+			return header
+		}
+	}
+	return nil
+}
+
+func (r *testerChainReader) GetHeaderByNumber(number uint64) *types.Header {
+	if number >= uint64(len(r.headers)) {
+		return nil
+	}
+	return r.headers[number]
+}
+
+// Tests that blocks sealed by an authorized signer pass verification, and
+// that those sealed by others don't.
+func TestSealVerification(t *testing.T) {
+	accounts := newTesterAccountPool()
+	signer := accounts.address("A")
+
+	genesis := &types.Header{
+		Number:     new(big.Int),
+		Time:       new(big.Int),
+		Difficulty: big.NewInt(1),
+		GasLimit:   new(big.Int),
+		GasUsed:    new(big.Int),
+		UncleHash:  types.EmptyUncleHash,
+		Extra:      append(append(make([]byte, extraVanity), signer[:]...), make([]byte, extraSeal)...),
+	}
+	chain := &testerChainReader{headers: []*types.Header{genesis}}
+
+	db, _ := ethdb.NewMemDatabase()
+	engine := New(&Config{Period: 1}, db)
+
+	for _, tt := range []struct {
+		sealer string
+		err    error
+	}{
+		{sealer: "A"},
+		{sealer: "B", err: errUnauthorized},
+	} {
+		header := &types.Header{
+			ParentHash: genesis.Hash(),
+			Number:     big.NewInt(1),
+			GasLimit:   new(big.Int),
+			GasUsed:    new(big.Int),
+			UncleHash:  types.EmptyUncleHash,
+			Extra:      []byte("vanity"),
+		}
+		engine.Authorize(accounts.address(tt.sealer), func(_ common.Address, hash []byte) ([]byte, error) {
+			return crypto.Sign(hash, accounts.accounts[tt.sealer])
+		})
+		if err := engine.Prepare(chain, header); err != nil {
+			t.Fatalf("sealer %s: failed to prepare header: %v", tt.sealer, err)
+		}
+		if err := engine.VerifyHeader(chain, header, genesis); err != nil {
+			t.Errorf("sealer %s: prepared header invalid: %v", tt.sealer, err)
+		}
+		// Sign the header directly, as the engine refuses to seal unauthorized
+		accounts.sign(header, tt.sealer)
+		if err := engine.VerifySeal(chain, header); err != tt.err {
+			t.Errorf("sealer %s: seal verification error %v, want %v", tt.sealer, err, tt.err)
+		}
+		if author, err := engine.Author(header); err != nil || author != accounts.address(tt.sealer) {
+			t.Errorf("sealer %s: author %x (%v), want %x", tt.sealer, author, err, accounts.address(tt.sealer))
+		}
+	}
+	// Seal a block through the engine as the authorized signer
+	engine.Authorize(signer, func(_ common.Address, hash []byte) ([]byte, error) {
+		return crypto.Sign(hash, accounts.accounts["A"])
+	})
+	header := &types.Header{
+		ParentHash: genesis.Hash(),
+		Number:     big.NewInt(1),
+		GasLimit:   new(big.Int),
+		GasUsed:    new(big.Int),
+		UncleHash:  types.EmptyUncleHash,
+	}
+	if err := engine.Prepare(chain, header); err != nil {
+		t.Fatalf("failed to prepare header: %v", err)
+	}
+	header.Time = big.NewInt(1) // Don't wait for the period
+	block, err := engine.Seal(chain, types.NewBlockWithHeader(header), make(chan struct{}))
+	if err != nil {
+		t.Fatalf("failed to seal block: %v", err)
+	}
+	if err := engine.VerifySeal(chain, block.Header()); err != nil {
+		t.Errorf("sealed block invalid: %v", err)
+	}
+}
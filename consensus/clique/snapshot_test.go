@@ -0,0 +1,291 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package clique
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"math/big"
+	"sort"
+	"testing"
+
+	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/core/types"
+	"github.com/ellaism/go-ellaism/crypto"
+)
+
+// testerAccountPool is a pool to maintain currently active tester accounts,
+// mapped from textual names used in the tests below to actual Ethereum private
+// keys capable of signing transactions.
+type testerAccountPool struct {
+	accounts map[string]*ecdsa.PrivateKey
+}
+
+func newTesterAccountPool() *testerAccountPool {
+	return &testerAccountPool{
+		accounts: make(map[string]*ecdsa.PrivateKey),
+	}
+}
+
+// sign calculates a Clique digital signature for the given block and embeds it
+// back into the header.
+func (ap *testerAccountPool) sign(header *types.Header, signer string) {
+	// Ensure we have a persistent key for the signer
+	if ap.accounts[signer] == nil {
+		ap.accounts[signer], _ = crypto.GenerateKey()
+	}
+	// Sign the header and embed the signature in extra data
+	sig, _ := crypto.Sign(sigHash(header).Bytes(), ap.accounts[signer])
+	copy(header.Extra[len(header.Extra)-extraSeal:], sig)
+}
+
+// address retrieves the Ethereum address of a tester account by label, creating
+// a new account if no previous one exists yet.
+func (ap *testerAccountPool) address(account string) common.Address {
+	// Return the zero account for non-addresses
+	if account == "" {
+		return common.Address{}
+	}
+	// Ensure we have a persistent key for the account
+	if ap.accounts[account] == nil {
+		ap.accounts[account], _ = crypto.GenerateKey()
+	}
+	// Resolve and return the Ethereum address
+	return crypto.PubkeyToAddress(ap.accounts[account].PublicKey)
+}
+
+// testerVote represents a single block signed by a particular account, where
+// the account may or may not have cast a Clique vote.
+type testerVote struct {
+	signer string
+	voted  string
+	auth   bool
+}
+
+// Tests that voting is evaluated correctly for various simple and complex
+// scenarios.
+func TestVoting(t *testing.T) {
+	tests := []struct {
+		epoch   uint64
+		signers []string
+		votes   []testerVote
+		results []string
+		failure error
+	}{
+		{
+			// Single signer, no votes cast
+			signers: []string{"A"},
+			votes:   []testerVote{{signer: "A"}},
+			results: []string{"A"},
+		}, {
+			// Single signer, voting to add two others (only accept first, second needs 2 votes)
+			signers: []string{"A"},
+			votes: []testerVote{
+				{signer: "A", voted: "B", auth: true},
+				{signer: "B"},
+				{signer: "A", voted: "C", auth: true},
+			},
+			results: []string{"A", "B"},
+		}, {
+			// Single signer, dropping itself (weird, but one less cornercase by explicitly allowing this)
+			signers: []string{"A"},
+			votes: []testerVote{
+				{signer: "A", voted: "A", auth: false},
+			},
+			results: []string{},
+		}, {
+			// Two signers, actually needing mutual consent to drop either of them (not fulfilled)
+			signers: []string{"A", "B"},
+			votes: []testerVote{
+				{signer: "A", voted: "B", auth: false},
+			},
+			results: []string{"A", "B"},
+		}, {
+			// Two signers, actually needing mutual consent to drop either of them (fulfilled)
+			signers: []string{"A", "B"},
+			votes: []testerVote{
+				{signer: "A", voted: "B", auth: false},
+				{signer: "B", voted: "B", auth: false},
+			},
+			results: []string{"A"},
+		}, {
+			// Three signers, two of them deciding to drop the third
+			signers: []string{"A", "B", "C"},
+			votes: []testerVote{
+				{signer: "A", voted: "C", auth: false},
+				{signer: "B", voted: "C", auth: false},
+			},
+			results: []string{"A", "B"},
+		}, {
+			// Four signers, consensus of two not being enough to drop anyone
+			signers: []string{"A", "B", "C", "D"},
+			votes: []testerVote{
+				{signer: "A", voted: "C", auth: false},
+				{signer: "B", voted: "C", auth: false},
+			},
+			results: []string{"A", "B", "C", "D"},
+		}, {
+			// Four signers, consensus of three already being enough to drop someone
+			signers: []string{"A", "B", "C", "D"},
+			votes: []testerVote{
+				{signer: "A", voted: "D", auth: false},
+				{signer: "B", voted: "D", auth: false},
+				{signer: "C", voted: "D", auth: false},
+			},
+			results: []string{"A", "B", "C"},
+		}, {
+			// Deauthorizations are counted once per signer per target
+			signers: []string{"A", "B", "C", "D"},
+			votes: []testerVote{
+				{signer: "A", voted: "D", auth: false},
+				{signer: "B"},
+				{signer: "C"},
+				{signer: "A", voted: "D", auth: false},
+			},
+			results: []string{"A", "B", "C", "D"},
+		}, {
+			// Votes from deauthorized signers are discarded immediately (deauth votes)
+			signers: []string{"A", "B", "C"},
+			votes: []testerVote{
+				{signer: "C", voted: "B", auth: false},
+				{signer: "A", voted: "C", auth: false},
+				{signer: "B", voted: "C", auth: false},
+				{signer: "A", voted: "B", auth: false},
+			},
+			results: []string{"A", "B"},
+		}, {
+			// Cascading changes are not allowed, only the account being voted on may change
+			signers: []string{"A", "B", "C", "D"},
+			votes: []testerVote{
+				{signer: "A", voted: "C", auth: false},
+				{signer: "B"},
+				{signer: "C"},
+				{signer: "A", voted: "D", auth: false},
+				{signer: "B", voted: "C", auth: false},
+				{signer: "C"},
+				{signer: "A"},
+				{signer: "B", voted: "D", auth: false},
+				{signer: "C", voted: "D", auth: false},
+			},
+			results: []string{"A", "B", "C"},
+		}, {
+			// Ensure that pending votes don't survive authorization status changes
+			signers: []string{"A", "B", "C", "D", "E"},
+			votes: []testerVote{
+				{signer: "A", voted: "F", auth: true}, // Authorize F, 3 votes needed
+				{signer: "B", voted: "F", auth: true},
+				{signer: "C", voted: "F", auth: true},
+				{signer: "D", voted: "F", auth: false}, // Deauthorize F, 4 votes needed (leave A's previous vote "unchanged")
+				{signer: "E", voted: "F", auth: false},
+				{signer: "B", voted: "F", auth: false},
+				{signer: "C", voted: "F", auth: false},
+				{signer: "D", voted: "F", auth: true}, // Almost authorize F, 2/3 votes needed
+				{signer: "E", voted: "F", auth: true},
+				{signer: "B", voted: "A", auth: false}, // Deauthorize A, 3 votes needed
+				{signer: "C", voted: "A", auth: false},
+				{signer: "D", voted: "A", auth: false},
+				{signer: "B", voted: "F", auth: true}, // Finish authorizing F, 3/3 votes needed
+			},
+			results: []string{"B", "C", "D", "E", "F"},
+		}, {
+			// Epoch transitions reset all votes to allow chain checkpointing
+			epoch:   3,
+			signers: []string{"A", "B"},
+			votes: []testerVote{
+				{signer: "A", voted: "C", auth: true},
+				{signer: "B"},
+				{signer: "A"}, // Checkpoint block
+				{signer: "B", voted: "C", auth: true},
+			},
+			results: []string{"A", "B"},
+		}, {
+			// A signer can't sign again before enough others did
+			signers: []string{"A", "B"},
+			votes: []testerVote{
+				{signer: "A"},
+				{signer: "A"},
+			},
+			failure: errUnauthorized,
+		}, {
+			// Unauthorized signers can't sign at all
+			signers: []string{"A"},
+			votes: []testerVote{
+				{signer: "B"},
+			},
+			failure: errUnauthorized,
+		},
+	}
+	// Run through the scenarios and test them
+	for i, tt := range tests {
+		// Create the account pool and generate the initial set of signers
+		accounts := newTesterAccountPool()
+
+		signers := make([]common.Address, len(tt.signers))
+		for j, signer := range tt.signers {
+			signers[j] = accounts.address(signer)
+		}
+		// Assemble a chain of headers from the cast votes
+		headers := make([]*types.Header, len(tt.votes))
+		for j, vote := range tt.votes {
+			headers[j] = &types.Header{
+				Number:     big.NewInt(int64(j) + 1),
+				Time:       big.NewInt(int64(j) * 15),
+				Difficulty: new(big.Int),
+				GasLimit:   new(big.Int),
+				GasUsed:    new(big.Int),
+				Coinbase:   accounts.address(vote.voted),
+				Extra:      make([]byte, extraVanity+extraSeal),
+			}
+			if j > 0 {
+				headers[j].ParentHash = headers[j-1].Hash()
+			}
+			if vote.auth {
+				copy(headers[j].Nonce[:], nonceAuthVote)
+			}
+			accounts.sign(headers[j], vote.signer)
+		}
+		// Pass all the headers through clique and ensure tallying succeeds
+		config := &Config{Epoch: tt.epoch}
+		if config.Epoch == 0 {
+			config.Epoch = epochLength
+		}
+		snap, err := newSnapshot(config, newSignatureCache(), 0, common.Hash{}, signers).apply(headers)
+		if err != tt.failure {
+			t.Errorf("test %d: failure mismatch: have %v, want %v", i, err, tt.failure)
+			continue
+		}
+		if tt.failure != nil {
+			continue
+		}
+		// Verify the final list of signers against the expected ones
+		signers = make([]common.Address, len(tt.results))
+		for j, signer := range tt.results {
+			signers[j] = accounts.address(signer)
+		}
+		sort.Sort(signersAscending(signers))
+		result := snap.signers()
+		if len(result) != len(signers) {
+			t.Errorf("test %d: signers mismatch: have %x, want %x", i, result, signers)
+			continue
+		}
+		for j := 0; j < len(result); j++ {
+			if !bytes.Equal(result[j][:], signers[j][:]) {
+				t.Errorf("test %d, signer %d: signer mismatch: have %x, want %x", i, j, result[j], signers[j])
+			}
+		}
+	}
+}
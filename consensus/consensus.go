@@ -0,0 +1,92 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package consensus implements different Ethereum consensus engines.
+package consensus
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/core/state"
+	"github.com/ellaism/go-ellaism/core/types"
+)
+
+var (
+	// ErrInvalidSeal is returned if the seal of a header doesn't satisfy the
+	// consensus rules of the engine.
+	ErrInvalidSeal = errors.New("invalid seal")
+
+	// ErrUnknownAncestor is returned when validating a header requires an
+	// ancestor that is unknown.
+	ErrUnknownAncestor = errors.New("unknown ancestor")
+)
+
+// ChainReader defines a small collection of methods needed to access the local
+// blockchain during header verification and sealing.
+type ChainReader interface {
+	// CurrentHeader retrieves the current head header of the local chain.
+	CurrentHeader() *types.Header
+
+	// GetHeader retrieves a block header from the database by hash.
+	GetHeader(hash common.Hash) *types.Header
+
+	// GetHeaderByNumber retrieves a canonical block header from the database
+	// by number.
+	GetHeaderByNumber(number uint64) *types.Header
+}
+
+// Engine is an algorithm agnostic consensus engine, deciding who may seal
+// blocks, how they are sealed and how their sealers are rewarded.
+type Engine interface {
+	// Author retrieves the address of the account that sealed the given block,
+	// which is credited with its rewards.
+	Author(header *types.Header) (common.Address, error)
+
+	// VerifyHeader checks whether the engine specific fields of a header, such
+	// as its difficulty and extra-data, conform to the consensus rules of the
+	// engine. The seal isn't verified.
+	VerifyHeader(chain ChainReader, header, parent *types.Header) error
+
+	// Prepare initializes the engine specific fields of a header to be sealed
+	// on top of the chain, such as its difficulty.
+	Prepare(chain ChainReader, header *types.Header) error
+
+	// CalcDifficulty returns the difficulty a block created at the given time
+	// on top of parent should have.
+	CalcDifficulty(chain ChainReader, time uint64, parent *types.Header) *big.Int
+
+	// VerifySeal checks whether the seal of the given header satisfies the
+	// consensus rules of the engine.
+	VerifySeal(chain ChainReader, header *types.Header) error
+
+	// Finalize credits the rewards of the block with the given header and
+	// uncles to the state.
+	Finalize(chain ChainReader, header *types.Header, statedb *state.StateDB, uncles []*types.Header)
+
+	// Seal generates a sealed version of the given block, or returns nil if
+	// stop is closed before the block could be sealed.
+	Seal(chain ChainReader, block *types.Block, stop <-chan struct{}) (*types.Block, error)
+}
+
+// PoW is a consensus engine based on proof-of-work.
+type PoW interface {
+	Engine
+
+	// Hashrate returns the current sealing hash rate of the engine.
+	Hashrate() int64
+}
@@ -0,0 +1,117 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"sort"
+
+	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/core/types"
+	"github.com/ellaism/go-ellaism/core/vm"
+)
+
+// AccessListTracer is a vm.Tracer which records the accounts and storage
+// slots accessed during the execution of a message, in order to build its
+// EIP-2930 access list. The sender, the recipient and the precompiled
+// contracts are always accessed, so they are only listed for the storage
+// slots accessed in them.
+type AccessListTracer struct {
+	excluded map[common.Address]bool
+	slots    map[common.Address]map[common.Hash]bool
+}
+
+// NewAccessListTracer returns a tracer extending the given access list with
+// the accesses of a message from the given sender to the given recipient.
+func NewAccessListTracer(acl types.AccessList, from common.Address, to *common.Address) *AccessListTracer {
+	t := &AccessListTracer{
+		excluded: map[common.Address]bool{from: true},
+		slots:    make(map[common.Address]map[common.Hash]bool),
+	}
+	if to != nil {
+		t.excluded[*to] = true
+	}
+	for _, tuple := range acl {
+		t.addAddress(tuple.Address)
+		for _, key := range tuple.StorageKeys {
+			t.addSlot(tuple.Address, key)
+		}
+	}
+	return t
+}
+
+func (t *AccessListTracer) addAddress(addr common.Address) {
+	if t.excluded[addr] || vm.Precompiled[addr.Str()] != nil {
+		return
+	}
+	if _, ok := t.slots[addr]; !ok {
+		t.slots[addr] = make(map[common.Hash]bool)
+	}
+}
+
+func (t *AccessListTracer) addSlot(addr common.Address, key common.Hash) {
+	if _, ok := t.slots[addr]; !ok {
+		t.slots[addr] = make(map[common.Hash]bool)
+	}
+	t.slots[addr][key] = true
+}
+
+func (t *AccessListTracer) CaptureEnter(typ vm.OpCode, from, to common.Address, input []byte, gas, value *big.Int) {
+}
+
+func (t *AccessListTracer) CaptureState(env vm.Environment, pc uint64, op vm.OpCode, gas, cost *big.Int, memory *vm.Memory, stack []*big.Int, contract *vm.Contract, depth int) {
+	size := len(stack)
+	switch {
+	case (op == vm.SLOAD || op == vm.SSTORE) && size >= 1:
+		t.addSlot(contract.Address(), common.BigToHash(stack[size-1]))
+	case (op == vm.BALANCE || op == vm.EXTCODESIZE || op == vm.EXTCODECOPY || op == vm.EXTCODEHASH || op == vm.SUICIDE) && size >= 1:
+		t.addAddress(common.BigToAddress(stack[size-1]))
+	case (op == vm.CALL || op == vm.CALLCODE || op == vm.DELEGATECALL) && size >= 2:
+		t.addAddress(common.BigToAddress(stack[size-2]))
+	}
+}
+
+func (t *AccessListTracer) CaptureExit(output []byte, gasUsed *big.Int, err error) {
+}
+
+// AccessList returns the access list recorded so far, sorted by address and
+// storage slot.
+func (t *AccessListTracer) AccessList() types.AccessList {
+	acl := make(types.AccessList, 0, len(t.slots))
+	for addr, slots := range t.slots {
+		tuple := types.AccessTuple{Address: addr, StorageKeys: make([]common.Hash, 0, len(slots))}
+		for key := range slots {
+			tuple.StorageKeys = append(tuple.StorageKeys, key)
+		}
+		sort.Sort(hashesByValue(tuple.StorageKeys))
+		acl = append(acl, tuple)
+	}
+	sort.Sort(tuplesByAddress(acl))
+	return acl
+}
+
+type hashesByValue []common.Hash
+
+func (s hashesByValue) Len() int           { return len(s) }
+func (s hashesByValue) Less(i, j int) bool { return s[i].Big().Cmp(s[j].Big()) < 0 }
+func (s hashesByValue) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+type tuplesByAddress types.AccessList
+
+func (s tuplesByAddress) Len() int           { return len(s) }
+func (s tuplesByAddress) Less(i, j int) bool { return s[i].Address.Big().Cmp(s[j].Address.Big()) < 0 }
+func (s tuplesByAddress) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
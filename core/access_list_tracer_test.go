@@ -0,0 +1,136 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/core/state"
+	"github.com/ellaism/go-ellaism/core/types"
+	"github.com/ellaism/go-ellaism/core/vm"
+	"github.com/ellaism/go-ellaism/crypto"
+	"github.com/ellaism/go-ellaism/ethdb"
+)
+
+// accessListTestConfig returns a chain configuration enabling access list
+// transactions from block 2.
+func accessListTestConfig() *ChainConfig {
+	config := MakeDiehardChainConfig()
+	config.Forks = append(config.Forks, &Fork{
+		Name:     "AccessLists",
+		Block:    big.NewInt(2),
+		Features: []*ForkFeature{{ID: "eip2930"}},
+	})
+	return config
+}
+
+func signedAccessListTx(t *testing.T, nonce uint64, to common.Address, acl types.AccessList) *types.Transaction {
+	key, _ := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	tx, err := types.NewTx(&types.AccessListTx{
+		ChainID:    big.NewInt(63),
+		Nonce:      nonce,
+		GasPrice:   big.NewInt(1),
+		Gas:        big.NewInt(100000),
+		To:         &to,
+		Value:      new(big.Int),
+		AccessList: acl,
+		V:          new(big.Int),
+		R:          new(big.Int),
+		S:          new(big.Int),
+	}).SignECDSA(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tx
+}
+
+// Tests that access list transactions are only valid from the fork enabling
+// them and that their access list is charged for.
+func TestAccessListTransaction(t *testing.T) {
+	key, _ := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	addr := crypto.PubkeyToAddress(key.PublicKey)
+
+	db, _ := ethdb.NewMemDatabase()
+	genesis := WriteGenesisBlockForTesting(db, GenesisAccount{addr, big.NewInt(1e18)})
+	statedb, _ := state.New(genesis.Root(), db)
+	config := accessListTestConfig()
+
+	acl := types.AccessList{{Address: common.Address{0xaa}, StorageKeys: []common.Hash{{0x01}, {0x02}}}}
+	tx := signedAccessListTx(t, 0, common.Address{0xbb}, acl)
+	header := &types.Header{Number: big.NewInt(1), GasLimit: big.NewInt(1000000), Difficulty: big.NewInt(1), Time: big.NewInt(0)}
+
+	gp := new(GasPool).AddGas(header.GasLimit)
+	if _, _, _, err := ApplyTransaction(config, nil, gp, statedb, header, tx, new(big.Int)); err != types.ErrTxTypeNotSupported {
+		t.Fatalf("before the fork: error mismatch: have %v, want %v", err, types.ErrTxTypeNotSupported)
+	}
+
+	header.Number = big.NewInt(2)
+	receipt, _, _, err := ApplyTransaction(config, nil, gp, statedb, header, tx, new(big.Int))
+	if err != nil {
+		t.Fatalf("after the fork: failed to apply transaction: %v", err)
+	}
+	want := new(big.Int).Add(TxGas, TxAccessListAddressGas)
+	want.Add(want, new(big.Int).Mul(big.NewInt(2), TxAccessListStorageKeyGas))
+	if receipt.GasUsed.Cmp(want) != 0 {
+		t.Errorf("gas used mismatch: have %v, want %v", receipt.GasUsed, want)
+	}
+}
+
+// Tests that the access list tracer records the accounts and storage slots a
+// message accesses.
+func TestAccessListTracer(t *testing.T) {
+	key, _ := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	addr := crypto.PubkeyToAddress(key.PublicKey)
+	contract, other := common.Address{0xcc}, common.Address{0xdd}
+
+	db, _ := ethdb.NewMemDatabase()
+	genesis := WriteGenesisBlockForTesting(db, GenesisAccount{addr, big.NewInt(1e18)})
+	statedb, _ := state.New(genesis.Root(), db)
+
+	// SLOAD(5), BALANCE(other), CALL the identity precompile
+	code := []byte{byte(vm.PUSH1), 0x05, byte(vm.SLOAD), byte(vm.POP), byte(vm.PUSH20)}
+	code = append(code, other.Bytes()...)
+	code = append(code, byte(vm.BALANCE), byte(vm.POP))
+	code = append(code,
+		byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0,
+		byte(vm.PUSH1), 0x04, byte(vm.GAS), byte(vm.CALL), byte(vm.STOP))
+	statedb.SetCode(contract, code)
+
+	config := accessListTestConfig()
+	tx := signedAccessListTx(t, 0, contract, types.AccessList{{Address: common.Address{0xee}}})
+	tx.SetSigner(config.GetSigner(big.NewInt(2)))
+	header := &types.Header{Number: big.NewInt(2), GasLimit: big.NewInt(1000000), Difficulty: big.NewInt(1), Time: big.NewInt(0)}
+
+	vmenv := NewEnv(statedb, config, nil, tx, header)
+	tracer := NewAccessListTracer(tx.AccessList(), addr, tx.To())
+	vmenv.SetTracer(tracer)
+	if _, _, err := ApplyMessage(vmenv, tx, new(GasPool).AddGas(header.GasLimit)); err != nil {
+		t.Fatalf("failed to apply message: %v", err)
+	}
+
+	want := types.AccessList{
+		{Address: contract, StorageKeys: []common.Hash{common.BigToHash(big.NewInt(5))}},
+		{Address: other, StorageKeys: []common.Hash{}},
+		{Address: common.Address{0xee}, StorageKeys: []common.Hash{}},
+	}
+	if have := tracer.AccessList(); !reflect.DeepEqual(have, want) {
+		t.Errorf("access list mismatch:\nhave %+v\nwant %+v", have, want)
+	}
+}
@@ -0,0 +1,418 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"fmt"
+	"math/big"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/core/types"
+	"github.com/ellaism/go-ellaism/crypto"
+	"github.com/ellaism/go-ellaism/ethdb"
+	"github.com/ellaism/go-ellaism/event"
+	"github.com/ellaism/go-ellaism/rlp"
+)
+
+// fuzzTx mirrors the RLP layout of a transaction so that the fuzzer can
+// modify fields which are not settable through the transaction's API.
+type fuzzTx struct {
+	Nonce     uint64
+	Price     *big.Int
+	GasLimit  *big.Int
+	Recipient *common.Address `rlp:"nil"`
+	Amount    *big.Int
+	Payload   []byte
+	V, R, S   *big.Int
+}
+
+// blockMutator modifies the header, transactions or uncles of a block. It
+// reports whether the header's transaction and uncle hashes should be
+// recomputed, so that the mutated block makes it past the cheap checks.
+type blockMutator func(r *rand.Rand, header *types.Header, txs []*types.Transaction, uncles []*types.Header) ([]*types.Transaction, []*types.Header, bool)
+
+// fuzzBig returns a random value among the edge cases of the given field,
+// which are likely to trip up arithmetic in the consensus code.
+func fuzzBig(r *rand.Rand, old *big.Int) *big.Int {
+	switch r.Intn(6) {
+	case 0:
+		return new(big.Int)
+	case 1:
+		return new(big.Int).Add(old, big.NewInt(1+r.Int63n(1024)))
+	case 2:
+		// RLP has no notion of negative numbers
+		return new(big.Int).Abs(new(big.Int).Sub(old, big.NewInt(1+r.Int63n(1024))))
+	case 3:
+		return new(big.Int).Lsh(old, uint(1+r.Intn(256)))
+	case 4:
+		return new(big.Int).Sub(new(big.Int).Lsh(common.Big1, 256), common.Big1)
+	default:
+		return new(big.Int).Rand(r, new(big.Int).Lsh(common.Big1, uint(1+r.Intn(300))))
+	}
+}
+
+// fuzzHash returns a random hash.
+func fuzzHash(r *rand.Rand) (h common.Hash) {
+	r.Read(h[:])
+	return h
+}
+
+// mutateTx applies the given modification to a copy of the transaction.
+func mutateTx(tx *types.Transaction, mutate func(*fuzzTx)) *types.Transaction {
+	enc, _ := rlp.EncodeToBytes(tx)
+	var data fuzzTx
+	if err := rlp.DecodeBytes(enc, &data); err != nil {
+		panic(err)
+	}
+	mutate(&data)
+	enc, _ = rlp.EncodeToBytes(data)
+	mutated := new(types.Transaction)
+	if err := rlp.DecodeBytes(enc, mutated); err != nil {
+		panic(err)
+	}
+	return mutated
+}
+
+// mutateRandomTx applies the given modification to a random transaction.
+func mutateRandomTx(r *rand.Rand, txs []*types.Transaction, mutate func(*fuzzTx)) []*types.Transaction {
+	if len(txs) > 0 {
+		i := r.Intn(len(txs))
+		txs[i] = mutateTx(txs[i], mutate)
+	}
+	return txs
+}
+
+var blockMutators = map[string]blockMutator{
+	"gasLimit": func(r *rand.Rand, h *types.Header, txs []*types.Transaction, uncles []*types.Header) ([]*types.Transaction, []*types.Header, bool) {
+		h.GasLimit = fuzzBig(r, h.GasLimit)
+		return txs, uncles, false
+	},
+	"gasUsed": func(r *rand.Rand, h *types.Header, txs []*types.Transaction, uncles []*types.Header) ([]*types.Transaction, []*types.Header, bool) {
+		h.GasUsed = fuzzBig(r, h.GasUsed)
+		return txs, uncles, false
+	},
+	"difficulty": func(r *rand.Rand, h *types.Header, txs []*types.Transaction, uncles []*types.Header) ([]*types.Transaction, []*types.Header, bool) {
+		h.Difficulty = fuzzBig(r, h.Difficulty)
+		return txs, uncles, false
+	},
+	"number": func(r *rand.Rand, h *types.Header, txs []*types.Transaction, uncles []*types.Header) ([]*types.Transaction, []*types.Header, bool) {
+		h.Number = fuzzBig(r, h.Number)
+		return txs, uncles, false
+	},
+	"time": func(r *rand.Rand, h *types.Header, txs []*types.Transaction, uncles []*types.Header) ([]*types.Transaction, []*types.Header, bool) {
+		h.Time = fuzzBig(r, h.Time)
+		return txs, uncles, false
+	},
+	"extra": func(r *rand.Rand, h *types.Header, txs []*types.Transaction, uncles []*types.Header) ([]*types.Transaction, []*types.Header, bool) {
+		h.Extra = make([]byte, types.HeaderExtraMax+1+r.Intn(1024))
+		r.Read(h.Extra)
+		return txs, uncles, false
+	},
+	"coinbase": func(r *rand.Rand, h *types.Header, txs []*types.Transaction, uncles []*types.Header) ([]*types.Transaction, []*types.Header, bool) {
+		h.Coinbase = common.BytesToAddress(fuzzHash(r).Bytes())
+		return txs, uncles, false
+	},
+	"root": func(r *rand.Rand, h *types.Header, txs []*types.Transaction, uncles []*types.Header) ([]*types.Transaction, []*types.Header, bool) {
+		h.Root = fuzzHash(r)
+		return txs, uncles, false
+	},
+	"txHash": func(r *rand.Rand, h *types.Header, txs []*types.Transaction, uncles []*types.Header) ([]*types.Transaction, []*types.Header, bool) {
+		h.TxHash = fuzzHash(r)
+		return txs, uncles, false
+	},
+	"receiptHash": func(r *rand.Rand, h *types.Header, txs []*types.Transaction, uncles []*types.Header) ([]*types.Transaction, []*types.Header, bool) {
+		h.ReceiptHash = fuzzHash(r)
+		return txs, uncles, false
+	},
+	"uncleHash": func(r *rand.Rand, h *types.Header, txs []*types.Transaction, uncles []*types.Header) ([]*types.Transaction, []*types.Header, bool) {
+		h.UncleHash = fuzzHash(r)
+		return txs, uncles, false
+	},
+	"bloom": func(r *rand.Rand, h *types.Header, txs []*types.Transaction, uncles []*types.Header) ([]*types.Transaction, []*types.Header, bool) {
+		h.Bloom[r.Intn(len(h.Bloom))] ^= byte(1 + r.Intn(255))
+		return txs, uncles, false
+	},
+	"uncleNumber": func(r *rand.Rand, h *types.Header, txs []*types.Transaction, uncles []*types.Header) ([]*types.Transaction, []*types.Header, bool) {
+		uncles[0].Number = fuzzBig(r, uncles[0].Number)
+		return txs, uncles, true
+	},
+	"uncleTime": func(r *rand.Rand, h *types.Header, txs []*types.Transaction, uncles []*types.Header) ([]*types.Transaction, []*types.Header, bool) {
+		uncles[0].Time = fuzzBig(r, uncles[0].Time)
+		return txs, uncles, true
+	},
+	"uncleDifficulty": func(r *rand.Rand, h *types.Header, txs []*types.Transaction, uncles []*types.Header) ([]*types.Transaction, []*types.Header, bool) {
+		uncles[0].Difficulty = fuzzBig(r, uncles[0].Difficulty)
+		return txs, uncles, true
+	},
+	"uncleGasLimit": func(r *rand.Rand, h *types.Header, txs []*types.Transaction, uncles []*types.Header) ([]*types.Transaction, []*types.Header, bool) {
+		uncles[0].GasLimit = fuzzBig(r, uncles[0].GasLimit)
+		return txs, uncles, true
+	},
+	"uncleCoinbase": func(r *rand.Rand, h *types.Header, txs []*types.Transaction, uncles []*types.Header) ([]*types.Transaction, []*types.Header, bool) {
+		uncles[0].Coinbase = common.BytesToAddress(fuzzHash(r).Bytes())
+		return txs, uncles, true
+	},
+	"uncleParent": func(r *rand.Rand, h *types.Header, txs []*types.Transaction, uncles []*types.Header) ([]*types.Transaction, []*types.Header, bool) {
+		uncles[0].ParentHash = h.ParentHash
+		return txs, uncles, true
+	},
+	"uncleDuplicate": func(r *rand.Rand, h *types.Header, txs []*types.Transaction, uncles []*types.Header) ([]*types.Transaction, []*types.Header, bool) {
+		return txs, append(uncles, types.CopyHeader(uncles[0])), true
+	},
+	"uncleCount": func(r *rand.Rand, h *types.Header, txs []*types.Transaction, uncles []*types.Header) ([]*types.Transaction, []*types.Header, bool) {
+		for i := 0; i < 2+r.Intn(3); i++ {
+			uncle := types.CopyHeader(uncles[0])
+			uncle.Extra = []byte{byte(i)}
+			uncles = append(uncles, uncle)
+		}
+		return txs, uncles, true
+	},
+	"txNonce": func(r *rand.Rand, h *types.Header, txs []*types.Transaction, uncles []*types.Header) ([]*types.Transaction, []*types.Header, bool) {
+		return mutateRandomTx(r, txs, func(tx *fuzzTx) { tx.Nonce = fuzzBig(r, new(big.Int).SetUint64(tx.Nonce)).Uint64() }), uncles, true
+	},
+	"txGasLimit": func(r *rand.Rand, h *types.Header, txs []*types.Transaction, uncles []*types.Header) ([]*types.Transaction, []*types.Header, bool) {
+		return mutateRandomTx(r, txs, func(tx *fuzzTx) { tx.GasLimit = fuzzBig(r, tx.GasLimit) }), uncles, true
+	},
+	"txPrice": func(r *rand.Rand, h *types.Header, txs []*types.Transaction, uncles []*types.Header) ([]*types.Transaction, []*types.Header, bool) {
+		return mutateRandomTx(r, txs, func(tx *fuzzTx) { tx.Price = fuzzBig(r, tx.Price) }), uncles, true
+	},
+	"txValue": func(r *rand.Rand, h *types.Header, txs []*types.Transaction, uncles []*types.Header) ([]*types.Transaction, []*types.Header, bool) {
+		return mutateRandomTx(r, txs, func(tx *fuzzTx) { tx.Amount = fuzzBig(r, tx.Amount) }), uncles, true
+	},
+	"txPayload": func(r *rand.Rand, h *types.Header, txs []*types.Transaction, uncles []*types.Header) ([]*types.Transaction, []*types.Header, bool) {
+		return mutateRandomTx(r, txs, func(tx *fuzzTx) {
+			tx.Payload = make([]byte, r.Intn(256))
+			r.Read(tx.Payload)
+		}), uncles, true
+	},
+	"txRecipient": func(r *rand.Rand, h *types.Header, txs []*types.Transaction, uncles []*types.Header) ([]*types.Transaction, []*types.Header, bool) {
+		return mutateRandomTx(r, txs, func(tx *fuzzTx) {
+			if tx.Recipient == nil {
+				tx.Recipient = new(common.Address)
+			} else {
+				tx.Recipient = nil
+			}
+		}), uncles, true
+	},
+	"txSignature": func(r *rand.Rand, h *types.Header, txs []*types.Transaction, uncles []*types.Header) ([]*types.Transaction, []*types.Header, bool) {
+		return mutateRandomTx(r, txs, func(tx *fuzzTx) {
+			switch r.Intn(3) {
+			case 0:
+				tx.V = fuzzBig(r, tx.V)
+			case 1:
+				tx.R = fuzzBig(r, tx.R)
+			default:
+				tx.S = fuzzBig(r, tx.S)
+			}
+		}), uncles, true
+	},
+	"txDuplicate": func(r *rand.Rand, h *types.Header, txs []*types.Transaction, uncles []*types.Header) ([]*types.Transaction, []*types.Header, bool) {
+		if len(txs) == 0 {
+			return txs, uncles, false
+		}
+		return append(txs, txs[r.Intn(len(txs))]), uncles, true
+	},
+	"txDrop": func(r *rand.Rand, h *types.Header, txs []*types.Transaction, uncles []*types.Header) ([]*types.Transaction, []*types.Header, bool) {
+		if len(txs) == 0 {
+			return txs, uncles, false
+		}
+		i := r.Intn(len(txs))
+		return append(txs[:i:i], txs[i+1:]...), uncles, true
+	},
+}
+
+// fuzzChain is a short valid chain whose last block includes transactions and
+// an uncle, serving as the template of the fuzzed blocks.
+type fuzzChain struct {
+	config *ChainConfig
+	funds  GenesisAccount
+	blocks types.Blocks
+}
+
+func newFuzzChain(t *testing.T) *fuzzChain {
+	key, _ := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	addr := crypto.PubkeyToAddress(key.PublicKey)
+
+	db, _ := ethdb.NewMemDatabase()
+	genesis := WriteGenesisBlockForTesting(db, GenesisAccount{addr, big.NewInt(1e18)})
+	config := DefaultConfigMainnet.ChainConfig
+
+	side, _ := GenerateChain(config, genesis, db, 1, func(i int, b *BlockGen) {
+		b.SetCoinbase(common.Address{0xaa})
+	})
+	blocks, _ := GenerateChain(config, genesis, db, 2, func(i int, b *BlockGen) {
+		b.SetCoinbase(common.Address{byte(i + 1)})
+		signer := config.GetSigner(b.Number())
+		transfer, err := types.NewTransaction(b.TxNonce(addr), common.Address{0xbb}, big.NewInt(1000), TxGas, big.NewInt(1), nil).WithSigner(signer).SignECDSA(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b.AddTx(transfer)
+		if i == 1 {
+			// Store a value and emit a log, so that the receipts are not trivial
+			create, err := types.NewContractCreation(b.TxNonce(addr), big.NewInt(1), big.NewInt(100000), big.NewInt(1), common.Hex2Bytes("600160005560006000a0")).WithSigner(signer).SignECDSA(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b.AddTx(create)
+			b.AddUncle(side[0].Header())
+		}
+	})
+	return &fuzzChain{config: config, funds: GenesisAccount{addr, big.NewInt(1e18)}, blocks: blocks}
+}
+
+// fuzzPanic is the error reported for a block import which panicked.
+type fuzzPanic struct{ value interface{} }
+
+func (p fuzzPanic) Error() string { return fmt.Sprintf("panic: %v", p.value) }
+
+// importBlock inserts all but the last block of the template chain into a
+// fresh blockchain, followed by the given block. It returns the blockchain,
+// its head before the import of the block and the outcome of the import.
+func (c *fuzzChain) importBlock(block *types.Block) (bc *BlockChain, head *types.Block, err error) {
+	db, _ := ethdb.NewMemDatabase()
+	WriteGenesisBlockForTesting(db, c.funds)
+
+	if bc, err = NewBlockChain(db, c.config, FakePow{}, new(event.TypeMux)); err != nil {
+		return nil, nil, err
+	}
+	if _, err := bc.InsertChain(c.blocks[:len(c.blocks)-1]); err != nil {
+		return nil, nil, err
+	}
+	head = bc.CurrentBlock()
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fuzzPanic{r}
+		}
+	}()
+	_, err = bc.InsertChain(types.Blocks{block})
+	return bc, head, err
+}
+
+// checkChainInvariants verifies that a blockchain is consistent after a block
+// import: a rejected block must leave no trace and the head must always be
+// backed by its state and total difficulty.
+func checkChainInvariants(bc *BlockChain, prevHead, block *types.Block, err error) error {
+	head := bc.CurrentBlock()
+	if err != nil {
+		if head.Hash() != prevHead.Hash() {
+			return fmt.Errorf("head changed by rejected block: have %x, want %x", head.Hash(), prevHead.Hash())
+		}
+		if bc.HasBlock(block.Hash()) {
+			return fmt.Errorf("rejected block %x stored", block.Hash())
+		}
+	}
+	if bc.CurrentHeader().Hash() != head.Hash() {
+		return fmt.Errorf("head header %x differs from head block %x", bc.CurrentHeader().Hash(), head.Hash())
+	}
+	if !bc.HasBlockAndState(head.Hash()) {
+		return fmt.Errorf("state of head block %x missing", head.Hash())
+	}
+	if bc.GetTd(head.Hash()) == nil {
+		return fmt.Errorf("total difficulty of head block %x missing", head.Hash())
+	}
+	return nil
+}
+
+// Tests that blocks mutated in all kinds of ways are rejected by InsertChain
+// without panicking, that the outcome is the same across independent nodes,
+// and that the chain is left in a consistent state.
+func TestInsertChainFuzzing(t *testing.T) {
+	chain := newFuzzChain(t)
+	template := chain.blocks[len(chain.blocks)-1]
+
+	// Sanity check that the template chain is valid itself
+	bc, prevHead, err := chain.importBlock(template)
+	if err != nil {
+		t.Fatalf("failed to import template chain: %v", err)
+	}
+	if err := checkChainInvariants(bc, prevHead, template, err); err != nil {
+		t.Fatal(err)
+	}
+
+	names := make([]string, 0, len(blockMutators))
+	for name := range blockMutators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	iterations := 500
+	if testing.Short() {
+		iterations = 100
+	}
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < iterations; i++ {
+		// Apply one or more random mutations to the template block
+		header := template.Header()
+		txs := append([]*types.Transaction{}, template.Transactions()...)
+		uncles := make([]*types.Header, len(template.Uncles()))
+		for j, uncle := range template.Uncles() {
+			uncles[j] = types.CopyHeader(uncle)
+		}
+		var applied []string
+		for j := 0; j < 1+r.Intn(2); j++ {
+			name := names[r.Intn(len(names))]
+			var rehash bool
+			txs, uncles, rehash = blockMutators[name](r, header, txs, uncles)
+			if rehash {
+				header.TxHash = types.DeriveSha(types.Transactions(txs))
+				header.UncleHash = types.CalcUncleHash(uncles)
+			}
+			applied = append(applied, name)
+		}
+		// Deliver the block the way the network would
+		enc, err := rlp.EncodeToBytes(types.NewBlockWithHeader(header).WithBody(txs, uncles))
+		if err != nil {
+			t.Fatalf("iteration %d %v: failed to encode block: %v", i, applied, err)
+		}
+		block := new(types.Block)
+		if err := rlp.DecodeBytes(enc, block); err != nil {
+			t.Fatalf("iteration %d %v: failed to decode block: %v", i, applied, err)
+		}
+		if block.Hash() == template.Hash() {
+			continue
+		}
+
+		// Import the block into two independent chains and compare
+		bc1, head1, err1 := chain.importBlock(block)
+		bc2, _, err2 := chain.importBlock(block)
+		if bc1 == nil || bc2 == nil {
+			t.Fatalf("iteration %d %v: failed to set up chain: %v, %v", i, applied, err1, err2)
+		}
+		if _, ok := err1.(fuzzPanic); ok {
+			t.Fatalf("iteration %d %v: %v", i, applied, err1)
+		}
+		// Error messages may embed the local time, only the verdict matters
+		if (err1 == nil) != (err2 == nil) || bc1.CurrentBlock().Hash() != bc2.CurrentBlock().Hash() {
+			t.Fatalf("iteration %d %v: non-deterministic import: %v != %v", i, applied, err1, err2)
+		}
+		if err := checkChainInvariants(bc1, head1, block, err1); err != nil {
+			t.Fatalf("iteration %d %v: %v", i, applied, err)
+		}
+		if err1 == nil && bc1.CurrentBlock().Hash() == block.Hash() {
+			// Mutations like a gas limit within bounds leave a valid block
+			continue
+		}
+		if err1 == nil && !bc1.futureBlocks.Contains(block.Hash()) {
+			t.Errorf("iteration %d %v: block neither imported, queued nor rejected", i, applied)
+		}
+	}
+}
@@ -22,11 +22,9 @@ import (
 	"time"
 
 	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/consensus"
 	"github.com/ellaism/go-ellaism/core/state"
 	"github.com/ellaism/go-ellaism/core/types"
-	"github.com/ellaism/go-ellaism/logger/glog"
-	"github.com/ellaism/go-ellaism/pow"
-	"gopkg.in/fatih/set.v0"
 )
 
 var (
@@ -55,17 +53,20 @@ type DifficultyConfig struct {
 //
 // BlockValidator implements Validator.
 type BlockValidator struct {
-	config *ChainConfig // Chain configuration options
-	bc     *BlockChain  // Canonical block chain
-	Pow    pow.PoW      // Proof of work used for validating
+	config *ChainConfig     // Chain configuration options
+	bc     *BlockChain      // Canonical block chain
+	engine consensus.Engine // Consensus engine used for validating
+
+	uncles *UncleValidator // Validator of the included uncles
 }
 
 // NewBlockValidator returns a new block validator which is safe for re-use
-func NewBlockValidator(config *ChainConfig, blockchain *BlockChain, pow pow.PoW) *BlockValidator {
+func NewBlockValidator(config *ChainConfig, blockchain *BlockChain, engine consensus.Engine) *BlockValidator {
 	validator := &BlockValidator{
 		config: config,
-		Pow:    pow,
+		engine: engine,
 		bc:     blockchain,
+		uncles: NewUncleValidator(config, engine, blockchain, MaxUncleDepth),
 	}
 	return validator
 }
@@ -81,24 +82,58 @@ func NewBlockValidator(config *ChainConfig, blockchain *BlockChain, pow pow.PoW)
 // sync has done it's job proper. This prevents the block validator form accepting
 // false positives where a header is present but the state is not.
 func (v *BlockValidator) ValidateBlock(block *types.Block) error {
+	parent, err := v.validateAncestry(block)
+	if err != nil {
+		return err
+	}
+	// validate the block header
+	if err := ValidateHeader(v.config, v.engine, v.bc, block.Header(), parent.Header(), false, false); err != nil {
+		return err
+	}
+	return v.validateBody(block, parent)
+}
+
+// ValidateBody validates the given block as ValidateBlock does, except for its
+// header, which is left to be validated along with the other headers of the
+// batch being imported by ValidateHeaders.
+func (v *BlockValidator) ValidateBody(block *types.Block) error {
+	parent, err := v.validateAncestry(block)
+	if err != nil {
+		return err
+	}
+	return v.validateBody(block, parent)
+}
+
+// ValidateHeaders starts a concurrent verification of the headers, seals
+// included, of a contiguous batch of blocks being imported, returning a quit
+// channel to abort the operations and a results channel to retrieve the async
+// checks.
+func (v *BlockValidator) ValidateHeaders(headers []*types.Header) (chan<- struct{}, <-chan nonceCheckResult) {
+	return verifyHeaders(v.config, v.engine, v.bc, headers)
+}
+
+// validateAncestry checks that the given block isn't already known with its
+// state, and returns its parent if known with its state.
+func (v *BlockValidator) validateAncestry(block *types.Block) (*types.Block, error) {
 	if v.bc.HasBlock(block.Hash()) {
 		if _, err := state.New(block.Root(), v.bc.chainDb); err == nil {
-			return &KnownBlockError{block.Number(), block.Hash()}
+			return nil, &KnownBlockError{block.Number(), block.Hash()}
 		}
 	}
 	parent := v.bc.GetBlock(block.ParentHash())
 	if parent == nil {
-		return ParentError(block.ParentHash())
+		return nil, ParentError(block.ParentHash())
 	}
 	if _, err := state.New(parent.Root(), v.bc.chainDb); err != nil {
-		return ParentError(block.ParentHash())
+		return nil, ParentError(block.ParentHash())
 	}
+	return parent, nil
+}
 
+// validateBody verifies the uncles and the transaction and uncle roots of the
+// given block.
+func (v *BlockValidator) validateBody(block, parent *types.Block) error {
 	header := block.Header()
-	// validate the block header
-	if err := ValidateHeader(v.config, v.Pow, header, parent.Header(), false, false); err != nil {
-		return err
-	}
 	// verify the uncles are correctly rewarded
 	if err := v.VerifyUncles(block, parent); err != nil {
 		return err
@@ -142,8 +177,8 @@ func (v *BlockValidator) ValidateState(block, parent *types.Block, statedb *stat
 	}
 	// Validate the state root against the received state root and throw
 	// an error if they don't match.
-	if root := statedb.IntermediateRoot(); header.Root != root {
-		return fmt.Errorf("invalid merkle root: header=%x computed=%x", header.Root, root)
+	if root := statedb.IntermediateRoot(v.config.IsEIP161(header.Number)); header.Root != root {
+		return &StateRootErr{Header: header.Root, Computed: root}
 	}
 	return nil
 }
@@ -153,50 +188,7 @@ func (v *BlockValidator) ValidateState(block, parent *types.Block, statedb *stat
 // error if any of the included uncle headers were invalid. It returns an error
 // if the validation failed.
 func (v *BlockValidator) VerifyUncles(block, parent *types.Block) error {
-	// validate that there at most 2 uncles included in this block
-	if len(block.Uncles()) > 2 {
-		return validateError(fmt.Sprintf("Block can only contain maximum 2 uncles (contained %d)", len(block.Uncles())))
-	}
-
-	uncles := set.New()
-	ancestors := make(map[common.Hash]*types.Block)
-	for _, ancestor := range v.bc.GetBlocksFromHash(block.ParentHash(), 7) {
-		ancestors[ancestor.Hash()] = ancestor
-		// Include ancestors uncles in the uncle set. Uncles must be unique.
-		for _, uncle := range ancestor.Uncles() {
-			uncles.Add(uncle.Hash())
-		}
-	}
-	ancestors[block.Hash()] = block
-	uncles.Add(block.Hash())
-
-	for i, uncle := range block.Uncles() {
-		hash := uncle.Hash()
-		if uncles.Has(hash) {
-			// Error not unique
-			return UncleError("uncle[%d](%x) not unique", i, hash[:4])
-		}
-		uncles.Add(hash)
-
-		if ancestors[hash] != nil {
-			branch := fmt.Sprintf("  O - %x\n  |\n", block.Hash())
-			for h := range ancestors {
-				branch += fmt.Sprintf("  O - %x\n  |\n", h)
-			}
-			glog.Infoln(branch)
-			return UncleError("uncle[%d](%x) is ancestor", i, hash[:4])
-		}
-
-		if ancestors[uncle.ParentHash] == nil || uncle.ParentHash == parent.Hash() {
-			return UncleError("uncle[%d](%x)'s parent is not ancestor (%x)", i, hash[:4], uncle.ParentHash[0:4])
-		}
-
-		if err := ValidateHeader(v.config, v.Pow, uncle, ancestors[uncle.ParentHash].Header(), true, true); err != nil {
-			return validateError(fmt.Sprintf("uncle[%d](%x) header invalid: %v", i, hash[:4], err))
-		}
-	}
-
-	return nil
+	return v.uncles.ValidateUncles(block, v.bc.GetBlocksFromHash(parent.Hash(), v.uncles.Depth()))
 }
 
 // ValidateHeader validates the given header and, depending on the pow arg,
@@ -211,17 +203,15 @@ func (v *BlockValidator) ValidateHeader(header, parent *types.Header, checkPow b
 	if v.bc.HasHeader(header.Hash()) {
 		return nil
 	}
-	return ValidateHeader(v.config, v.Pow, header, parent, checkPow, false)
+	return ValidateHeader(v.config, v.engine, v.bc, header, parent, checkPow, false)
 }
 
-// Validates a header. Returns an error if the header is invalid.
+// Validates a header. Returns an error if the header is invalid. The
+// engine specific fields, such as the difficulty, and depending on
+// checkSeal the seal of the header are verified by the consensus engine.
 //
 // See YP section 4.3.4. "Block Header Validity"
-func ValidateHeader(config *ChainConfig, pow pow.PoW, header *types.Header, parent *types.Header, checkPow, uncle bool) error {
-	if len(header.Extra) > types.HeaderExtraMax {
-		return fmt.Errorf("extra data size %d exceeds limit of %d", len(header.Extra), types.HeaderExtraMax)
-	}
-
+func ValidateHeader(config *ChainConfig, engine consensus.Engine, chain consensus.ChainReader, header *types.Header, parent *types.Header, checkSeal, uncle bool) error {
 	if uncle {
 		if header.Time.Cmp(common.MaxBig) == 1 {
 			return BlockTSTooBigErr
@@ -235,9 +225,8 @@ func ValidateHeader(config *ChainConfig, pow pow.PoW, header *types.Header, pare
 		return BlockEqualTSErr
 	}
 
-	expd := CalcDifficulty(config, header.Time.Uint64(), parent.Time.Uint64(), parent.Number, parent.Difficulty)
-	if expd.Cmp(header.Difficulty) != 0 {
-		return fmt.Errorf("Difficulty check failed for header %v != %v at %v", header.Difficulty, expd, header.Number)
+	if err := engine.VerifyHeader(chain, header, parent); err != nil {
+		return err
 	}
 
 	a := new(big.Int).Set(parent.GasLimit)
@@ -255,10 +244,10 @@ func ValidateHeader(config *ChainConfig, pow pow.PoW, header *types.Header, pare
 		return BlockNumberErr
 	}
 
-	if checkPow {
-		// Verify the nonce of the header. Return an error if it's not valid
-		if !pow.Verify(types.NewBlockWithHeader(header)) {
-			return &BlockNonceErr{header.Number, header.Hash(), header.Nonce.Uint64()}
+	if checkSeal {
+		// Verify the seal of the header. Return an error if it's not valid
+		if err := engine.VerifySeal(chain, header); err != nil {
+			return err
 		}
 	}
 	// If all checks passed, validate the extra-data field for hard forks
@@ -267,42 +256,31 @@ func ValidateHeader(config *ChainConfig, pow pow.PoW, header *types.Header, pare
 
 // CalcDifficulty is the difficulty adjustment algorithm. It returns
 // the difficulty that a new block should have when created at time
-// given the parent block's time and difficulty.
+// given the parent block's time and difficulty, as computed by the
+// algorithm selected by the difficulty feature of the block's fork.
+// The exponential factor of the algorithm is paused or removed by the
+// bombdefuse feature.
 func CalcDifficulty(config *ChainConfig, time, parentTime uint64, parentNumber, parentDiff *big.Int) *big.Int {
 
 	num := new(big.Int).Add(parentNumber, common.Big1) // increment block number to current
 
-	f, fork, configured := config.GetFeature(num, "difficulty")
-	if !configured {
-		return calcDifficultyFrontier(time, parentTime, parentNumber, parentDiff)
+	algo := frontierDifficulty
+	if f, fork, configured := config.GetFeature(num, "difficulty"); configured {
+		var err error
+		if algo, err = f.difficultyAlgorithm(fork); err != nil {
+			panic(fmt.Sprintf("Unsupported difficulty for block %v: %v", num, err))
+		}
 	}
-	name, ok := f.GetString("type")
-	if !ok {
-		name = ""
-	} // will fall to default panic
-	switch name {
-	case "diffused":
-		return calcDifficultyDiffused(time, parentTime, parentNumber, parentDiff)
-	case "ecip1010":
-		if length, ok := f.GetBigInt("length"); ok {
-			explosionBlock := big.NewInt(0).Add(fork.Block, length)
-			if num.Cmp(explosionBlock) < 0 {
-				return calcDifficultyDiehard(time, parentTime, parentDiff,
-					fork.Block)
-			} else {
-				return calcDifficultyExplosion(time, parentTime, parentNumber, parentDiff,
-					fork.Block, explosionBlock)
-			}
-		} else {
-			panic(fmt.Sprintf("Length is not set for diehard difficulty at %v", num))
+	var periods *big.Int
+	if f, fork, configured := config.GetFeature(num, "bombdefuse"); configured {
+		var err error
+		if periods, err = f.bombDefusal(fork, algo, num); err != nil {
+			panic(fmt.Sprintf("Unsupported bomb defusal for block %v: %v", num, err))
 		}
-	case "homestead":
-		return calcDifficultyHomestead(time, parentTime, parentNumber, parentDiff)
-	case "frontier":
-		return calcDifficultyFrontier(time, parentTime, parentNumber, parentDiff)
-	default:
-		panic(fmt.Sprintf("Unsupported difficulty '%v' for block: %v", name, num))
+	} else if algo.BombPeriods != nil {
+		periods = algo.BombPeriods(num)
 	}
+	return addDifficultyBomb(algo.Adjust(time, parentTime, parentDiff), periods)
 }
 
 func calcDifficultyDiehard(time, parentTime uint64, parentDiff *big.Int, diehardBlock *big.Int) *big.Int {
@@ -311,46 +289,8 @@ func calcDifficultyDiehard(time, parentTime uint64, parentDiff *big.Int, diehard
 	// diff = (parent_diff +
 	//         (parent_diff / 2048 * max(1 - (block_timestamp - parent_timestamp) // 10, -99))
 	//        ) + 2^(fixed_diff)
-
-	bigTime := new(big.Int).SetUint64(time)
-	bigParentTime := new(big.Int).SetUint64(parentTime)
-
-	// holds intermediate values to make the algo easier to read & audit
-	x := new(big.Int)
-	y := new(big.Int)
-
-	// 1 - (block_timestamp -parent_timestamp) // 10
-	x.Sub(bigTime, bigParentTime)
-	x.Div(x, big10)
-	x.Sub(common.Big1, x)
-
-	// max(1 - (block_timestamp - parent_timestamp) // 10, -99)))
-	if x.Cmp(bigMinus99) < 0 {
-		x.Set(bigMinus99)
-	}
-
-	// (parent_diff + parent_diff // 2048 * max(1 - (block_timestamp - parent_timestamp) // 10, -99))
-	y.Div(parentDiff, DifficultyBoundDivisor)
-	x.Mul(y, x)
-	x.Add(parentDiff, x)
-
-	// minimum difficulty can ever be (before exponential factor)
-	if x.Cmp(MinimumDifficulty) < 0 {
-		x.Set(MinimumDifficulty)
-	}
-
-	// for the exponential factor
 	fixedCount := new(big.Int).Div(diehardBlock, ExpDiffPeriod)
-
-	// the exponential factor, commonly referred to as "the bomb"
-	// diff = diff + 2^(periodCount - 2)
-	if fixedCount.Cmp(common.Big1) > 0 {
-		y.Sub(fixedCount, common.Big2)
-		y.Exp(common.Big2, y, nil)
-		x.Add(x, y)
-	}
-
-	return x
+	return addDifficultyBomb(adjustDifficultyHomestead(time, parentTime, parentDiff), fixedCount)
 }
 
 func calcDifficultyExplosion(time, parentTime uint64, parentNumber, parentDiff *big.Int, delayBlock *big.Int, continueBlock *big.Int) *big.Int {
@@ -359,50 +299,12 @@ func calcDifficultyExplosion(time, parentTime uint64, parentNumber, parentDiff *
 	// diff = (parent_diff +
 	//         (parent_diff / 2048 * max(1 - (block_timestamp - parent_timestamp) // 10, -99))
 	//        ) + 2^(delayedCount - 2)
-
-	bigTime := new(big.Int).SetUint64(time)
-	bigParentTime := new(big.Int).SetUint64(parentTime)
-
-	// holds intermediate values to make the algo easier to read & audit
-	x := new(big.Int)
-	y := new(big.Int)
-
-	// 1 - (block_timestamp -parent_timestamp) // 10
-	x.Sub(bigTime, bigParentTime)
-	x.Div(x, big10)
-	x.Sub(common.Big1, x)
-
-	// max(1 - (block_timestamp - parent_timestamp) // 10, -99)))
-	if x.Cmp(bigMinus99) < 0 {
-		x.Set(bigMinus99)
-	}
-
-	// (parent_diff + parent_diff // 2048 * max(1 - (block_timestamp - parent_timestamp) // 10, -99))
-	y.Div(parentDiff, DifficultyBoundDivisor)
-	x.Mul(y, x)
-	x.Add(parentDiff, x)
-
-	// minimum difficulty can ever be (before exponential factor)
-	if x.Cmp(MinimumDifficulty) < 0 {
-		x.Set(MinimumDifficulty)
-	}
-
-	// for the exponential factor...
-
 	delayedCount := new(big.Int).Add(parentNumber, common.Big1)
 	delayedCount.Sub(delayedCount, continueBlock)
 	delayedCount.Add(delayedCount, delayBlock)
 	delayedCount.Div(delayedCount, ExpDiffPeriod)
 
-	// the exponential factor, commonly referred to as "the bomb"
-	// diff = diff + 2^(periodCount - 2)
-	if delayedCount.Cmp(common.Big1) > 0 {
-		y.Sub(delayedCount, common.Big2)
-		y.Exp(common.Big2, y, nil)
-		x.Add(x, y)
-	}
-
-	return x
+	return addDifficultyBomb(adjustDifficultyHomestead(time, parentTime, parentDiff), delayedCount)
 }
 
 func calcDifficultyHomestead(time, parentTime uint64, parentNumber, parentDiff *big.Int) *big.Int {
@@ -411,47 +313,7 @@ func calcDifficultyHomestead(time, parentTime uint64, parentNumber, parentDiff *
 	// diff = (parent_diff +
 	//         (parent_diff / 2048 * max(1 - (block_timestamp - parent_timestamp) // 10, -99))
 	//        ) + 2^(periodCount - 2)
-
-	bigTime := new(big.Int).SetUint64(time)
-	bigParentTime := new(big.Int).SetUint64(parentTime)
-
-	// holds intermediate values to make the algo easier to read & audit
-	x := new(big.Int)
-	y := new(big.Int)
-
-	// 1 - (block_timestamp -parent_timestamp) // 10
-	x.Sub(bigTime, bigParentTime)
-	x.Div(x, big10)
-	x.Sub(common.Big1, x)
-
-	// max(1 - (block_timestamp - parent_timestamp) // 10, -99)))
-	if x.Cmp(bigMinus99) < 0 {
-		x.Set(bigMinus99)
-	}
-
-	// (parent_diff + parent_diff // 2048 * max(1 - (block_timestamp - parent_timestamp) // 10, -99))
-	y.Div(parentDiff, DifficultyBoundDivisor)
-	x.Mul(y, x)
-	x.Add(parentDiff, x)
-
-	// minimum difficulty can ever be (before exponential factor)
-	if x.Cmp(MinimumDifficulty) < 0 {
-		x.Set(MinimumDifficulty)
-	}
-
-	// for the exponential factor
-	periodCount := new(big.Int).Add(parentNumber, common.Big1)
-	periodCount.Div(periodCount, ExpDiffPeriod)
-
-	// the exponential factor, commonly referred to as "the bomb"
-	// diff = diff + 2^(periodCount - 2)
-	if periodCount.Cmp(common.Big1) > 0 {
-		y.Sub(periodCount, common.Big2)
-		y.Exp(common.Big2, y, nil)
-		x.Add(x, y)
-	}
-
-	return x
+	return addDifficultyBomb(adjustDifficultyHomestead(time, parentTime, parentDiff), bombPeriods(new(big.Int).Add(parentNumber, common.Big1)))
 }
 
 func calcDifficultyDiffused(time, parentTime uint64, parentNumber, parentDiff *big.Int) *big.Int {
@@ -459,8 +321,18 @@ func calcDifficultyDiffused(time, parentTime uint64, parentNumber, parentDiff *b
 	// algorithm:
 	// diff = (parent_diff +
 	//         (parent_diff / 2048 * max(1 - (block_timestamp - parent_timestamp) // 10, -99))
-	//        ) + 2^(periodCount - 2)
+	//        )
+	return adjustDifficultyHomestead(time, parentTime, parentDiff)
+}
+
+func calcDifficultyFrontier(time, parentTime uint64, parentNumber, parentDiff *big.Int) *big.Int {
+	return addDifficultyBomb(adjustDifficultyFrontier(time, parentTime, parentDiff), bombPeriods(new(big.Int).Add(parentNumber, common.Big1)))
+}
 
+// adjustDifficultyHomestead adjusts the parent difficulty to the time the
+// block took to mine by the Homestead rules (EIP-2), without the exponential
+// factor.
+func adjustDifficultyHomestead(time, parentTime uint64, parentDiff *big.Int) *big.Int {
 	bigTime := new(big.Int).SetUint64(time)
 	bigParentTime := new(big.Int).SetUint64(parentTime)
 
@@ -487,11 +359,12 @@ func calcDifficultyDiffused(time, parentTime uint64, parentNumber, parentDiff *b
 	if x.Cmp(MinimumDifficulty) < 0 {
 		x.Set(MinimumDifficulty)
 	}
-
 	return x
 }
 
-func calcDifficultyFrontier(time, parentTime uint64, parentNumber, parentDiff *big.Int) *big.Int {
+// adjustDifficultyFrontier adjusts the parent difficulty to the time the
+// block took to mine by the Frontier rules, without the exponential factor.
+func adjustDifficultyFrontier(time, parentTime uint64, parentDiff *big.Int) *big.Int {
 	diff := new(big.Int)
 	adjust := new(big.Int).Div(parentDiff, DifficultyBoundDivisor)
 	bigTime := new(big.Int)
@@ -508,17 +381,25 @@ func calcDifficultyFrontier(time, parentTime uint64, parentNumber, parentDiff *b
 	if diff.Cmp(MinimumDifficulty) < 0 {
 		diff.Set(MinimumDifficulty)
 	}
+	return diff
+}
 
-	periodCount := new(big.Int).Add(parentNumber, common.Big1)
-	periodCount.Div(periodCount, ExpDiffPeriod)
-	if periodCount.Cmp(common.Big1) > 0 {
-		// diff = diff + 2^(periodCount - 2)
-		expDiff := periodCount.Sub(periodCount, common.Big2)
-		expDiff.Exp(common.Big2, expDiff, nil)
-		diff.Add(diff, expDiff)
-		diff = common.BigMax(diff, MinimumDifficulty)
-	}
+// bombPeriods returns the number of periods of the exponential factor elapsed
+// at the given block number.
+func bombPeriods(num *big.Int) *big.Int {
+	return new(big.Int).Div(num, ExpDiffPeriod)
+}
 
+// addDifficultyBomb adds the exponential factor, commonly referred to as "the
+// bomb", of the given number of periods to the difficulty. Nil periods add no
+// factor.
+func addDifficultyBomb(diff, periods *big.Int) *big.Int {
+	// diff = diff + 2^(periodCount - 2)
+	if periods != nil && periods.Cmp(common.Big1) > 0 {
+		y := new(big.Int).Sub(periods, common.Big2)
+		y.Exp(common.Big2, y, nil)
+		diff.Add(diff, y)
+	}
 	return diff
 }
 
@@ -113,13 +113,13 @@ func TestNumber(t *testing.T) {
 	header := makeHeader(chain.config, chain.Genesis(), statedb)
 	header.Number = big.NewInt(3)
 	cfg := testChainConfig()
-	err = ValidateHeader(cfg, nil, header, chain.Genesis().Header(), false, false)
+	err = ValidateHeader(cfg, chain.engine, chain, header, chain.Genesis().Header(), false, false)
 	if err != BlockNumberErr {
 		t.Errorf("expected block number error, got %q", err)
 	}
 
 	header = makeHeader(chain.config, chain.Genesis(), statedb)
-	err = ValidateHeader(cfg, nil, header, chain.Genesis().Header(), false, false)
+	err = ValidateHeader(cfg, chain.engine, chain, header, chain.Genesis().Header(), false, false)
 	if err == BlockNumberErr {
 		t.Errorf("didn't expect block number error")
 	}
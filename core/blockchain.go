@@ -25,6 +25,7 @@ import (
 	"math/big"
 	mrand "math/rand"
 	"runtime"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -33,14 +34,15 @@ import (
 	"strconv"
 
 	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/consensus"
 	"github.com/ellaism/go-ellaism/core/state"
 	"github.com/ellaism/go-ellaism/core/types"
 	"github.com/ellaism/go-ellaism/core/vm"
-	"github.com/ellaism/go-ellaism/crypto"
 	"github.com/ellaism/go-ellaism/ethdb"
 	"github.com/ellaism/go-ellaism/event"
 	"github.com/ellaism/go-ellaism/logger"
 	"github.com/ellaism/go-ellaism/logger/glog"
+	"github.com/ellaism/go-ellaism/metrics"
 	"github.com/ellaism/go-ellaism/pow"
 	"github.com/ellaism/go-ellaism/rlp"
 	"github.com/ellaism/go-ellaism/trie"
@@ -54,17 +56,21 @@ var (
 )
 
 const (
-	headerCacheLimit    = 512
-	bodyCacheLimit      = 256
-	tdCacheLimit        = 1024
-	blockCacheLimit     = 256
-	maxFutureBlocks     = 256
-	maxTimeFutureBlocks = 30
+	headerCacheLimit = 512
+	bodyCacheLimit   = 256
+	tdCacheLimit     = 1024
+	blockCacheLimit  = 256
+	maxFutureBlocks  = 256
 	// must be bumped when consensus algorithm is changed, this forces the upgradedb
 	// command to be run (forces the blocks to be imported again using the new algorithm)
 	BlockChainVersion = 3
 )
 
+// DefaultFutureDrift is the maximum time the timestamp of an imported block may
+// be ahead of the local clock for the block to be queued until then rather than
+// rejected, unless configured otherwise.
+var DefaultFutureDrift = 30 * time.Second
+
 // BlockChain represents the canonical chain given a database with a genesis
 // block. The Blockchain manages chain imports, reverts, chain reorganisations.
 //
@@ -87,6 +93,11 @@ type BlockChain struct {
 	eventMux     *event.TypeMux
 	genesisBlock *types.Block
 
+	chainHeadFeed event.Feed              // Delivers ChainHeadEvent for new canonical heads
+	logsFeed      event.Feed              // Delivers LogsEvent for imported canonical blocks
+	rmLogsFeed    event.Feed              // Delivers RemovedLogsEvent for reorged out blocks
+	scope         event.SubscriptionScope // Ends the feed subscriptions when the chain stops
+
 	mu      sync.RWMutex // global mutex for locking chain operations
 	chainmu sync.RWMutex // blockchain insertion lock
 	procmu  sync.RWMutex // block processor lock
@@ -100,21 +111,35 @@ type BlockChain struct {
 	blockCache   *lru.Cache     // Cache for the most recent entire blocks
 	futureBlocks *lru.Cache     // future blocks are blocks added for later processing
 
+	futureDrift time.Duration // Maximum time imported blocks may be ahead of the clock to be queued, DefaultFutureDrift if zero
+
+	forensicsDir string // Directory forensic bundles of state root mismatches are written to, none if empty
+
+	missingLock sync.Mutex
+	missing     map[missingData]time.Time // Blocks last reported missing their body or receipts
+
 	quit    chan struct{} // blockchain quit channel
 	running int32         // running must be called atomically
 	// procInterrupt must be atomically called
 	procInterrupt int32          // interrupt signaler for block processing
 	wg            sync.WaitGroup // chain processing wait group for shutting down
 
-	pow       pow.PoW
-	processor Processor // block processor interface
-	validator Validator // block and state validator interface
+	engine    consensus.Engine // consensus engine sealing and verifying the blocks
+	processor Processor        // block processor interface
+	validator Validator        // block and state validator interface
 }
 
 // NewBlockChain returns a fully initialised block chain using information
 // available in the database. It initialises the default Ethereum Validator and
-// Processor.
+// Processor, and the Ethash consensus engine with the given proof of work.
 func NewBlockChain(chainDb ethdb.Database, config *ChainConfig, pow pow.PoW, mux *event.TypeMux) (*BlockChain, error) {
+	return NewBlockChainWithEngine(chainDb, config, NewEthash(config, pow), mux)
+}
+
+// NewBlockChainWithEngine returns a fully initialised block chain using
+// information available in the database, sealed and verified by the given
+// consensus engine.
+func NewBlockChainWithEngine(chainDb ethdb.Database, config *ChainConfig, engine consensus.Engine, mux *event.TypeMux) (*BlockChain, error) {
 	bodyCache, _ := lru.New(bodyCacheLimit)
 	bodyRLPCache, _ := lru.New(bodyCacheLimit)
 	blockCache, _ := lru.New(blockCacheLimit)
@@ -129,14 +154,14 @@ func NewBlockChain(chainDb ethdb.Database, config *ChainConfig, pow pow.PoW, mux
 		bodyRLPCache: bodyRLPCache,
 		blockCache:   blockCache,
 		futureBlocks: futureBlocks,
-		pow:          pow,
+		engine:       engine,
 	}
-	bc.SetValidator(NewBlockValidator(config, bc, pow))
+	bc.SetValidator(NewBlockValidator(config, bc, engine))
 	bc.SetProcessor(NewStateProcessor(config, bc))
 
 	gv := func() HeaderValidator { return bc.Validator() }
 	var err error
-	bc.hc, err = NewHeaderChain(chainDb, config, mux, gv, bc.getProcInterrupt)
+	bc.hc, err = NewHeaderChain(chainDb, config, bc.engine, mux, gv, bc.getProcInterrupt)
 	if err != nil {
 		return nil, err
 	}
@@ -177,14 +202,14 @@ func NewBlockChainDryrun(chainDb ethdb.Database, config *ChainConfig, pow pow.Po
 		bodyRLPCache: bodyRLPCache,
 		blockCache:   blockCache,
 		futureBlocks: futureBlocks,
-		pow:          pow,
+		engine:       NewEthash(config, pow),
 	}
-	bc.SetValidator(NewBlockValidator(config, bc, pow))
+	bc.SetValidator(NewBlockValidator(config, bc, bc.engine))
 	bc.SetProcessor(NewStateProcessor(config, bc))
 
 	gv := func() HeaderValidator { return bc.Validator() }
 	var err error
-	bc.hc, err = NewHeaderChain(chainDb, config, mux, gv, bc.getProcInterrupt)
+	bc.hc, err = NewHeaderChain(chainDb, config, bc.engine, mux, gv, bc.getProcInterrupt)
 	if err != nil {
 		return nil, err
 	}
@@ -569,6 +594,21 @@ func (self *BlockChain) LoadLastState(dryrun bool) error {
 		return errors.New("nil currentBlock")
 	}
 
+	// If the state of the head block is missing or corrupt, the blocks above
+	// the newest intact state can't be processed anymore: rewind to it.
+	if !dryrun && currentBlock.NumberU64() > 0 {
+		if err := self.checkState(currentBlock.Root()); err != nil {
+			header := self.lastIntactState(currentBlock)
+			glog.V(logger.Error).Errorf("Unusable state of head block #%d [%x…]: %v, rewinding chain to block #%d [%x…]", currentBlock.Number(), currentBlock.Hash().Bytes()[:4], err, header.Number, header.Hash().Bytes()[:4])
+			glog.D(logger.Error).Errorf("Unusable state of head block #%d [%x…]: %v, rewinding chain to block #%d [%x…]", currentBlock.Number(), currentBlock.Hash().Bytes()[:4], err, header.Number, header.Hash().Bytes()[:4])
+
+			self.currentBlock, self.currentFastBlock = currentBlock, currentBlock
+			self.mu.Unlock()
+			defer self.mu.Lock()
+			return self.SetHead(header.Number.Uint64())
+		}
+	}
+
 	// If currentBlock (fullblock) is not genesis, check that it is valid
 	// and that it has a state associated with it.
 	if currentBlock.Number().Cmp(new(big.Int)) > 0 {
@@ -846,6 +886,15 @@ func (self *BlockChain) SetProcessor(processor Processor) {
 	self.processor = processor
 }
 
+// SetFutureDrift sets the maximum time the timestamp of an imported block may
+// be ahead of the local clock for the block to be queued until then, as long
+// as the queue of future blocks isn't full, rather than rejected.
+func (self *BlockChain) SetFutureDrift(drift time.Duration) {
+	self.chainmu.Lock()
+	defer self.chainmu.Unlock()
+	self.futureDrift = drift
+}
+
 // SetValidator sets the validator which is used to validate incoming blocks.
 func (self *BlockChain) SetValidator(validator Validator) {
 	self.procmu.Lock()
@@ -867,8 +916,14 @@ func (self *BlockChain) Processor() Processor {
 	return self.processor
 }
 
-// AuxValidator returns the auxiliary validator (Proof of work atm)
-func (self *BlockChain) AuxValidator() pow.PoW { return self.pow }
+// ForkChoice returns the fork choice deciding between competing chains of
+// blocks and headers.
+func (self *BlockChain) ForkChoice() *ForkChoice {
+	return self.hc.forkChoice
+}
+
+// Engine returns the consensus engine sealing and verifying the blocks.
+func (self *BlockChain) Engine() consensus.Engine { return self.engine }
 
 // State returns a new mutable state based on the current HEAD block.
 func (self *BlockChain) State() (*state.StateDB, error) {
@@ -990,6 +1045,9 @@ func (self *BlockChain) GetBody(hash common.Hash) *types.Body {
 	}
 	body := GetBody(self.chainDb, hash)
 	if body == nil {
+		if self.HasHeader(hash) {
+			self.reportMissing(hash, false)
+		}
 		return nil
 	}
 	// Cache the found body for next time and return
@@ -1006,6 +1064,9 @@ func (self *BlockChain) GetBodyRLP(hash common.Hash) rlp.RawValue {
 	}
 	body := GetBodyRLP(self.chainDb, hash)
 	if len(body) == 0 {
+		if self.HasHeader(hash) {
+			self.reportMissing(hash, false)
+		}
 		return nil
 	}
 	// Cache the found body for next time and return
@@ -1032,6 +1093,28 @@ func (bc *BlockChain) HasBlockAndState(hash common.Hash) bool {
 	return err == nil
 }
 
+// OldestState returns the oldest canonical block above the genesis whose state
+// is stored, the states of the blocks above it up to the head being stored as
+// well, as after a fast sync. The genesis state is always stored.
+func (bc *BlockChain) OldestState() *types.Block {
+	head := bc.CurrentBlock()
+	if head.NumberU64() == 0 {
+		return head
+	}
+	n := sort.Search(int(head.NumberU64()), func(i int) bool {
+		block := bc.GetBlockByNumber(uint64(i + 1))
+		if block == nil {
+			return false
+		}
+		_, err := state.New(block.Root(), bc.chainDb)
+		return err == nil
+	})
+	if block := bc.GetBlockByNumber(uint64(n + 1)); block != nil {
+		return block
+	}
+	return head
+}
+
 // GetBlock retrieves a block from the database by hash, caching it if found.
 func (self *BlockChain) GetBlock(hash common.Hash) *types.Block {
 	// Short circuit if the block's already in the cache, retrieve otherwise
@@ -1040,6 +1123,9 @@ func (self *BlockChain) GetBlock(hash common.Hash) *types.Block {
 	}
 	block := GetBlock(self.chainDb, hash)
 	if block == nil {
+		if self.HasHeader(hash) {
+			self.reportMissing(hash, false)
+		}
 		return nil
 	}
 	// Cache the found block for next time and return
@@ -1088,6 +1174,8 @@ func (bc *BlockChain) Stop() {
 	if !atomic.CompareAndSwapInt32(&bc.running, 0, 1) {
 		return
 	}
+	// Unsubscribe all subscriptions registered from blockchain
+	bc.scope.Close()
 	close(bc.quit)
 	atomic.StoreInt32(&bc.procInterrupt, 1)
 
@@ -1171,35 +1259,9 @@ func (self *BlockChain) InsertReceiptChain(blockChain types.Blocks, receiptChain
 				atomic.AddInt32(&stats.ignored, 1)
 				continue
 			}
-			signer := self.config.GetSigner(block.Number())
 			// Compute all the non-consensus fields of the receipts
-			transactions, logIndex := block.Transactions(), uint(0)
-			for j := 0; j < len(receipts); j++ {
-				// The transaction hash can be retrieved from the transaction itself
-				receipts[j].TxHash = transactions[j].Hash()
-				tx := transactions[j]
-				from, _ := types.Sender(signer, tx)
-
-				// The contract address can be derived from the transaction itself
-				if MessageCreatesContract(transactions[j]) {
-					receipts[j].ContractAddress = crypto.CreateAddress(from, tx.Nonce())
-				}
-				// The used gas can be calculated based on previous receipts
-				if j == 0 {
-					receipts[j].GasUsed = new(big.Int).Set(receipts[j].CumulativeGasUsed)
-				} else {
-					receipts[j].GasUsed = new(big.Int).Sub(receipts[j].CumulativeGasUsed, receipts[j-1].CumulativeGasUsed)
-				}
-				// The derived log fields can simply be set from the block and transaction
-				for k := 0; k < len(receipts[j].Logs); k++ {
-					receipts[j].Logs[k].BlockNumber = block.NumberU64()
-					receipts[j].Logs[k].BlockHash = block.Hash()
-					receipts[j].Logs[k].TxHash = receipts[j].TxHash
-					receipts[j].Logs[k].TxIndex = uint(j)
-					receipts[j].Logs[k].Index = logIndex
-					logIndex++
-				}
-			}
+			SetReceiptsData(self.config, block, receipts)
+
 			// Write all the data out into the database
 			if err := WriteBody(self.chainDb, block.Hash(), block.Body()); err != nil {
 				errs[index] = fmt.Errorf("failed to write block body: %v", err)
@@ -1225,6 +1287,12 @@ func (self *BlockChain) InsertReceiptChain(blockChain types.Blocks, receiptChain
 				glog.Fatal(errs[index])
 				return
 			}
+			if err := WriteUncleLookups(self.chainDb, block); err != nil {
+				errs[index] = fmt.Errorf("failed to write uncle lookups: %v", err)
+				atomic.AddInt32(&failed, 1)
+				glog.Fatal(errs[index])
+				return
+			}
 			if err := WriteReceipts(self.chainDb, receipts); err != nil {
 				errs[index] = fmt.Errorf("failed to write individual receipts: %v", err)
 				atomic.AddInt32(&failed, 1)
@@ -1278,6 +1346,12 @@ func (self *BlockChain) InsertReceiptChain(blockChain types.Blocks, receiptChain
 
 // WriteBlock writes the block to the chain.
 func (self *BlockChain) WriteBlock(block *types.Block) (status WriteStatus, err error) {
+	return self.writeBlock(block, self.chainDb.NewBatch())
+}
+
+// writeBlock writes the block and its total difficulty to the chain in batch,
+// together with whatever the batch already holds, before making it the head.
+func (self *BlockChain) writeBlock(block *types.Block, batch ethdb.Batch) (status WriteStatus, err error) {
 
 	if logger.MlogEnabled() {
 		defer func() {
@@ -1328,19 +1402,20 @@ func (self *BlockChain) WriteBlock(block *types.Block) (status WriteStatus, err
 	localTd := self.GetTd(self.currentBlock.Hash())
 	externTd := new(big.Int).Add(block.Difficulty(), ptd)
 
-	// If the total difficulty is higher than our known, add it to the canonical chain
-	// Compare local vs external difficulties
-	tdCompare := externTd.Cmp(localTd)
-
-	// Initialize reorg if incoming TD is greater than local.
-	reorg := tdCompare > 0
-
-	// If TDs are the same, randomize.
-	if tdCompare == 0 {
-		// Reduces the vulnerability to selfish mining.
-		// Please refer to http://www.cs.cornell.edu/~ie53/publications/btcProcFC.pdf
-		reorg = mrand.Float64() < 0.5
+	// Irrelevant of the canonical status, write the block itself to the database
+	if err := WriteTd(batch, block.Hash(), externTd); err != nil {
+		glog.Fatalf("failed to write block total difficulty: %v", err)
+	}
+	if err := WriteBlock(batch, block); err != nil {
+		glog.Fatalf("failed to write block contents: %v", err)
+	}
+	if err := batch.Write(); err != nil {
+		glog.Fatalf("failed to write block batch: %v", err)
 	}
+	self.hc.tdCache.Add(block.Hash(), new(big.Int).Set(externTd))
+
+	// If the fork choice prefers the block's chain, make it the canonical chain
+	reorg := self.hc.forkChoice.Reorg(ForkHead{self.currentBlock.Header(), localTd}, ForkHead{block.Header(), externTd})
 	if reorg {
 		// Reorganise the chain if the parent is not the head block
 		if block.ParentHash() != self.currentBlock.Hash() {
@@ -1353,13 +1428,6 @@ func (self *BlockChain) WriteBlock(block *types.Block) (status WriteStatus, err
 	} else {
 		status = SideStatTy
 	}
-	// Irrelevant of the canonical status, write the block itself to the database
-	if err := self.hc.WriteTd(block.Hash(), externTd); err != nil {
-		glog.Fatalf("failed to write block total difficulty: %v", err)
-	}
-	if err := WriteBlock(self.chainDb, block); err != nil {
-		glog.Fatalf("failed to write block contents: %v", err)
-	}
 
 	self.futureBlocks.Remove(block.Hash())
 
@@ -1397,12 +1465,18 @@ func (self *BlockChain) InsertChain(chain types.Blocks) (chainIndex int, err err
 		coalescedLogs vm.Logs
 		tstart        = time.Now()
 
-		nonceChecked = make([]bool, len(chain))
+		headerChecked = make([]bool, len(chain))
+		headerErrs    = make([]error, len(chain))
 	)
 
-	// Start the parallel nonce verifier.
-	nonceAbort, nonceResults := verifyNoncesFromBlocks(self.pow, chain)
-	defer close(nonceAbort)
+	// Start the parallel header verifier, checking the header fields and seals
+	// of the whole batch ahead of the state processing.
+	headers := make([]*types.Header, len(chain))
+	for i, block := range chain {
+		headers[i] = block.Header()
+	}
+	headerAbort, headerResults := self.Validator().ValidateHeaders(headers)
+	defer close(headerAbort)
 
 	txcount := 0
 	var latestBlockTime time.Time
@@ -1413,14 +1487,17 @@ func (self *BlockChain) InsertChain(chain types.Blocks) (chainIndex int, err err
 		}
 
 		bstart := time.Now()
-		// Wait for block i's nonce to be verified before processing
-		// its state transition.
-		for !nonceChecked[i] {
-			r := <-nonceResults
-			nonceChecked[r.index] = true
+		// Wait for block i's header to be verified before processing its state
+		// transition. Invalid headers abort the import right away, unless from
+		// the future or missing their parent, which are handled below.
+		for !headerChecked[i] {
+			r := <-headerResults
+			headerChecked[r.index] = true
 			if !r.valid {
-				block := chain[r.index]
-				return r.index, &BlockNonceErr{Hash: block.Hash(), Number: block.Number(), Nonce: block.Nonce()}
+				if r.err != BlockFutureErr && !IsParentErr(r.err) {
+					return r.index, r.err
+				}
+				headerErrs[r.index] = r.err
 			}
 		}
 
@@ -1429,8 +1506,11 @@ func (self *BlockChain) InsertChain(chain types.Blocks) (chainIndex int, err err
 		}
 
 		// Stage 1 validation of the block using the chain's validator
-		// interface.
-		err := self.Validator().ValidateBlock(block)
+		// interface, its header being validated above.
+		err := self.Validator().ValidateBody(block)
+		if err == nil {
+			err = headerErrs[i]
+		}
 		if err != nil {
 			if IsKnownBlockErr(err) {
 				stats.ignored++
@@ -1438,10 +1518,14 @@ func (self *BlockChain) InsertChain(chain types.Blocks) (chainIndex int, err err
 			}
 
 			if err == BlockFutureErr {
-				// Allow up to MaxFuture second in the future blocks. If this limit
-				// is exceeded the chain is discarded and processed at a later time
-				// if given.
-				max := big.NewInt(time.Now().Unix() + maxTimeFutureBlocks)
+				// Allow blocks up to the future drift ahead of the clock. If this
+				// limit is exceeded the chain is discarded and processed at a later
+				// time if given.
+				drift := self.futureDrift
+				if drift == 0 {
+					drift = DefaultFutureDrift
+				}
+				max := big.NewInt(time.Now().Add(drift).Unix())
 				if block.Time().Cmp(max) == 1 {
 					return i, fmt.Errorf("%v: BlockFutureErr, %v > %v", BlockFutureErr, block.Time(), max)
 				}
@@ -1481,27 +1565,32 @@ func (self *BlockChain) InsertChain(chain types.Blocks) (chainIndex int, err err
 		// Validate the state using the default validator
 		err = self.Validator().ValidateState(block, self.GetBlock(block.ParentHash()), self.stateCache, receipts, usedGas)
 		if err != nil {
+			if mismatch, ok := err.(*StateRootErr); ok {
+				self.reportStateRootMismatch(block, receipts, mismatch)
+			}
 			return i, err
 		}
-		// Write state changes to database
-		_, err = self.stateCache.Commit()
-		if err != nil {
+		// Write the block, its total difficulty, state changes and receipts
+		// to the database in a single batch.
+		cstart := time.Now()
+		batch := self.chainDb.NewBatch()
+		if _, err := self.stateCache.CommitTo(batch); err != nil {
+			return i, err
+		}
+		if err := WriteBlockReceipts(batch, block.Hash(), receipts); err != nil {
 			return i, err
 		}
 
 		// coalesce logs for later processing
 		coalescedLogs = append(coalescedLogs, logs...)
 
-		if err := WriteBlockReceipts(self.chainDb, block.Hash(), receipts); err != nil {
-			return i, err
-		}
-
 		txcount += len(block.Transactions())
 		// write the block to the chain and get the status
-		status, err := self.WriteBlock(block)
+		status, err := self.writeBlock(block, batch)
 		if err != nil {
 			return i, err
 		}
+		metrics.ChainCommitTimer.UpdateSince(cstart)
 		latestBlockTime = time.Unix(block.Time().Int64(), 0)
 
 		switch status {
@@ -1515,6 +1604,9 @@ func (self *BlockChain) InsertChain(chain types.Blocks) (chainIndex int, err err
 			if err := WriteTransactions(self.chainDb, block); err != nil {
 				return i, err
 			}
+			if err := WriteUncleLookups(self.chainDb, block); err != nil {
+				return i, err
+			}
 			// store the receipts
 			if err := WriteReceipts(self.chainDb, receipts); err != nil {
 				return i, err
@@ -1655,6 +1747,12 @@ func (self *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 		))
 	}
 
+	// Uncles of the old chain are rewritten below if the new chain includes them too
+	for _, block := range oldChain {
+		for _, uncle := range block.Uncles() {
+			DeleteUncleLookup(self.chainDb, uncle.Hash())
+		}
+	}
 	var addedTxs types.Transactions
 	// insert blocks. Order does not matter. Last block will be written in ImportChain itself which creates the new head properly
 	for _, block := range newChain {
@@ -1664,6 +1762,9 @@ func (self *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 		if err := WriteTransactions(self.chainDb, block); err != nil {
 			return err
 		}
+		if err := WriteUncleLookups(self.chainDb, block); err != nil {
+			return err
+		}
 		receipts := GetBlockReceipts(self.chainDb, block.Hash())
 		// write receipts
 		if err := WriteReceipts(self.chainDb, receipts); err != nil {
@@ -1690,7 +1791,10 @@ func (self *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 		go self.eventMux.Post(RemovedTransactionEvent{diff})
 	}
 	if len(deletedLogs) > 0 {
-		go self.eventMux.Post(RemovedLogsEvent{deletedLogs})
+		go func() {
+			self.eventMux.Post(RemovedLogsEvent{deletedLogs})
+			self.rmLogsFeed.Send(RemovedLogsEvent{deletedLogs})
+		}()
 	}
 
 	if len(oldChain) > 0 {
@@ -1709,12 +1813,16 @@ func (self *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 func (self *BlockChain) postChainEvents(events []interface{}, logs vm.Logs) {
 	// post event logs for further processing
 	self.eventMux.Post(logs)
+	if len(logs) > 0 {
+		self.logsFeed.Send(LogsEvent{logs})
+	}
 	for _, event := range events {
 		if event, ok := event.(ChainEvent); ok {
 			// We need some control over the mining operation. Acquiring locks and waiting for the miner to create new block takes too long
 			// and in most cases isn't even necessary.
 			if self.LastBlockHash() == event.Hash {
 				self.eventMux.Post(ChainHeadEvent{event.Block})
+				self.chainHeadFeed.Send(ChainHeadEvent{event.Block})
 			}
 		}
 		// Fire the insertion events individually too
@@ -1722,8 +1830,26 @@ func (self *BlockChain) postChainEvents(events []interface{}, logs vm.Logs) {
 	}
 }
 
+// SubscribeChainHeadEvent registers a subscription of ChainHeadEvent, sent
+// whenever a block becomes the head of the canonical chain.
+func (bc *BlockChain) SubscribeChainHeadEvent(ch chan<- ChainHeadEvent) event.Subscription {
+	return bc.scope.Track(bc.chainHeadFeed.Subscribe(ch))
+}
+
+// SubscribeLogsEvent registers a subscription of LogsEvent, sent with the logs
+// of every batch of blocks imported into the canonical chain.
+func (bc *BlockChain) SubscribeLogsEvent(ch chan<- LogsEvent) event.Subscription {
+	return bc.scope.Track(bc.logsFeed.Subscribe(ch))
+}
+
+// SubscribeRemovedLogsEvent registers a subscription of RemovedLogsEvent, sent
+// with the logs of blocks dropped from the canonical chain by a reorg.
+func (bc *BlockChain) SubscribeRemovedLogsEvent(ch chan<- RemovedLogsEvent) event.Subscription {
+	return bc.scope.Track(bc.rmLogsFeed.Subscribe(ch))
+}
+
 func (chain *BlockChain) update() {
-	ticker := time.NewTicker(5 * time.Second)
+	ticker := time.NewTicker(time.Second)
 	defer ticker.Stop()
 
 	for range ticker.C {
@@ -1733,9 +1859,11 @@ func (chain *BlockChain) update() {
 		default:
 		}
 
+		// Import the queued future blocks whose time has come
+		now := time.Now().Unix()
 		blocks := make([]*types.Block, 0, chain.futureBlocks.Len())
 		for _, hash := range chain.futureBlocks.Keys() {
-			if block, exist := chain.futureBlocks.Get(hash); exist {
+			if block, exist := chain.futureBlocks.Peek(hash); exist && block.(*types.Block).Time().Int64() <= now {
 				blocks = append(blocks, block.(*types.Block))
 			}
 		}
@@ -455,6 +455,10 @@ func (bproc) ValidateState(block, parent *types.Block, state *state.StateDB, rec
 	return nil
 }
 func (bproc) VerifyUncles(block, parent *types.Block) error { return nil }
+func (bproc) ValidateBody(*types.Block) error               { return nil }
+func (bproc) ValidateHeaders(headers []*types.Header) (chan<- struct{}, <-chan nonceCheckResult) {
+	return verifyConcurrently(len(headers), func(int) error { return nil })
+}
 func (bproc) Process(block *types.Block, statedb *state.StateDB) (types.Receipts, vm.Logs, *big.Int, error) {
 	return nil, nil, nil, nil
 }
@@ -497,12 +501,12 @@ func chm(t testing.TB, genesis *types.Block, db ethdb.Database) *BlockChain {
 		chainDb:      db,
 		genesisBlock: genesis,
 		eventMux:     &eventMux,
-		pow:          FakePow{},
+		engine:       NewEthash(config, FakePow{}),
 		config:       config,
 	}
 	valFn := func() HeaderValidator { return bc.Validator() }
 	var err error
-	bc.hc, err = NewHeaderChain(db, config, bc.eventMux, valFn, bc.getProcInterrupt)
+	bc.hc, err = NewHeaderChain(db, config, bc.engine, bc.eventMux, valFn, bc.getProcInterrupt)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -738,7 +742,8 @@ func testInsertNonceError(t *testing.T, full bool) {
 			failNum = blocks[failAt].NumberU64()
 			failHash = blocks[failAt].Hash()
 
-			blockchain.pow = failPow{failNum}
+			blockchain.engine = NewEthash(blockchain.config, failPow{failNum})
+			blockchain.validator = NewBlockValidator(testChainConfig(), blockchain, blockchain.engine)
 
 			failRes, err = blockchain.InsertChain(blocks)
 		} else {
@@ -748,8 +753,9 @@ func testInsertNonceError(t *testing.T, full bool) {
 			failNum = headers[failAt].Number.Uint64()
 			failHash = headers[failAt].Hash()
 
-			blockchain.pow = failPow{failNum}
-			blockchain.validator = NewBlockValidator(testChainConfig(), blockchain, failPow{failNum})
+			blockchain.engine = NewEthash(blockchain.config, failPow{failNum})
+			blockchain.hc.engine = blockchain.engine
+			blockchain.validator = NewBlockValidator(testChainConfig(), blockchain, blockchain.engine)
 
 			failRes, err = blockchain.InsertHeaderChain(headers, 1)
 		}
@@ -1171,6 +1177,102 @@ func TestLogReorgs(t *testing.T) {
 	}
 }
 
+// Tests that the chain's typed feeds deliver new heads and the logs of
+// imported and reorged blocks, and that stopping the chain ends the
+// subscriptions.
+func TestChainFeeds(t *testing.T) {
+	MinGasLimit = big.NewInt(125000)
+
+	key1, err := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr1 := crypto.PubkeyToAddress(key1.PublicKey)
+	// this code generates a log
+	code := common.Hex2Bytes("60606040525b7f24ec1d3ff24c2f6ff210738839dbc339cd45a5294d85c79361016243157aae7b60405180905060405180910390a15b600a8060416000396000f360606040526008565b00")
+	signer := types.NewChainIdSigner(big.NewInt(63))
+	db, err := ethdb.NewMemDatabase()
+	if err != nil {
+		t.Fatal(err)
+	}
+	genesis := WriteGenesisBlockForTesting(db,
+		GenesisAccount{addr1, big.NewInt(10000000000000)},
+	)
+	chainConfig := MakeDiehardChainConfig()
+
+	blockchain, err := NewBlockChain(db, chainConfig, FakePow{}, new(event.TypeMux))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		headCh   = make(chan ChainHeadEvent, 10)
+		logsCh   = make(chan LogsEvent, 10)
+		rmLogsCh = make(chan RemovedLogsEvent, 10)
+		headSub  = blockchain.SubscribeChainHeadEvent(headCh)
+		logsSub  = blockchain.SubscribeLogsEvent(logsCh)
+		rmSub    = blockchain.SubscribeRemovedLogsEvent(rmLogsCh)
+	)
+	chain, _ := GenerateChain(chainConfig, genesis, db, 2, func(i int, gen *BlockGen) {
+		if i == 1 {
+			tx, err := types.NewContractCreation(gen.TxNonce(addr1), new(big.Int), big.NewInt(1000000), new(big.Int), code).WithSigner(signer).SignECDSA(key1)
+			if err != nil {
+				t.Fatalf("failed to create tx: %v", err)
+			}
+			gen.AddTx(tx)
+		}
+	})
+	if _, err := blockchain.InsertChain(chain); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	select {
+	case ev := <-logsCh:
+		if len(ev.Logs) == 0 {
+			t.Error("expected logs")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for logs event")
+	}
+	select {
+	case ev := <-headCh:
+		if ev.Block.Hash() != chain[1].Hash() {
+			t.Errorf("head event block mismatch: have %x, want %x", ev.Block.Hash(), chain[1].Hash())
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for head event")
+	}
+
+	chain, _ = GenerateChain(chainConfig, genesis, db, 3, func(i int, gen *BlockGen) {})
+	if _, err := blockchain.InsertChain(chain); err != nil {
+		t.Fatalf("failed to insert forked chain: %v", err)
+	}
+	select {
+	case ev := <-rmLogsCh:
+		if len(ev.Logs) == 0 {
+			t.Error("expected removed logs")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for removed logs event")
+	}
+	select {
+	case ev := <-headCh:
+		if ev.Block.Hash() != chain[2].Hash() {
+			t.Errorf("head event block mismatch after reorg: have %x, want %x", ev.Block.Hash(), chain[2].Hash())
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for head event after reorg")
+	}
+
+	// Stopping the chain ends all subscriptions
+	blockchain.Stop()
+	for i, sub := range []event.Subscription{headSub, logsSub, rmSub} {
+		select {
+		case <-sub.Err():
+		case <-time.After(time.Second):
+			t.Errorf("subscription %d not ended by stopping the chain", i)
+		}
+	}
+}
+
 func TestReorgSideEvent(t *testing.T) {
 	key1, err := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
 	if err != nil {
@@ -1301,6 +1403,40 @@ func TestCanonicalBlockRetrieval(t *testing.T) {
 	}
 }
 
+// Tests that blocks from the future are queued within the configured drift
+// and rejected beyond it.
+func TestFutureBlockDrift(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	genesis := WriteGenesisBlockForTesting(db)
+
+	blockchain, err := NewBlockChain(db, testChainConfig(), FakePow{}, new(event.TypeMux))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer blockchain.Stop()
+
+	// Create a block a minute ahead of the clock
+	chain, _ := GenerateChain(testChainConfig(), genesis, db, 1, func(i int, gen *BlockGen) {
+		gen.OffsetTime(time.Now().Add(time.Minute).Unix() - genesis.Time().Int64() - 10)
+	})
+	if _, err := blockchain.InsertChain(chain); err == nil {
+		t.Fatalf("block beyond the default drift accepted")
+	}
+	if blockchain.futureBlocks.Contains(chain[0].Hash()) {
+		t.Fatalf("block beyond the default drift queued")
+	}
+	blockchain.SetFutureDrift(2 * time.Minute)
+	if _, err := blockchain.InsertChain(chain); err != nil {
+		t.Fatalf("block within the drift rejected: %v", err)
+	}
+	if !blockchain.futureBlocks.Contains(chain[0].Hash()) {
+		t.Fatalf("block within the drift not queued")
+	}
+	if blockchain.CurrentBlock().Hash() != genesis.Hash() {
+		t.Fatalf("future block imported")
+	}
+}
+
 func TestEIP155Transition(t *testing.T) {
 	// Configure and generate a sample block chain
 	db, err := ethdb.NewMemDatabase()
@@ -19,53 +19,113 @@ package core
 import (
 	"runtime"
 
+	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/consensus"
 	"github.com/ellaism/go-ellaism/core/types"
-	"github.com/ellaism/go-ellaism/pow"
 )
 
-// nonceCheckResult contains the result of a nonce verification.
+// nonceCheckResult contains the result of a nonce or header verification.
 type nonceCheckResult struct {
-	index int  // Index of the item verified from an input array
-	valid bool // Result of the nonce verification
+	index int   // Index of the item verified from an input array
+	valid bool  // Result of the verification
+	err   error // Error of the verification if the item isn't valid
 }
 
-// verifyNoncesFromHeaders starts a concurrent header nonce verification,
+// batchChainReader is a chain reader resolving the headers of a batch being
+// verified before they are written to the chain, so that engines verifying a
+// header can access its ancestors within the batch.
+type batchChainReader struct {
+	consensus.ChainReader
+	headers map[common.Hash]*types.Header
+}
+
+// newBatchChainReader returns a reader of the chain extended by the headers.
+func newBatchChainReader(chain consensus.ChainReader, headers []*types.Header) *batchChainReader {
+	r := &batchChainReader{ChainReader: chain, headers: make(map[common.Hash]*types.Header, len(headers))}
+	for _, header := range headers {
+		r.headers[header.Hash()] = header
+	}
+	return r
+}
+
+// GetHeader retrieves a header of the batch or the chain by hash.
+func (r *batchChainReader) GetHeader(hash common.Hash) *types.Header {
+	if header, ok := r.headers[hash]; ok {
+		return header
+	}
+	return r.ChainReader.GetHeader(hash)
+}
+
+// verifyNoncesFromHeaders starts a concurrent header seal verification,
 // returning a quit channel to abort the operations and a results channel
 // to retrieve the async verifications.
-func verifyNoncesFromHeaders(checker pow.PoW, headers []*types.Header) (chan<- struct{}, <-chan nonceCheckResult) {
-	items := make([]pow.Block, len(headers))
-	for i, header := range headers {
-		items[i] = types.NewBlockWithHeader(header)
-	}
-	return verifyNonces(checker, items)
+func verifyNoncesFromHeaders(engine consensus.Engine, chain consensus.ChainReader, headers []*types.Header) (chan<- struct{}, <-chan nonceCheckResult) {
+	return verifyNonces(engine, chain, headers)
 }
 
-// verifyNoncesFromBlocks starts a concurrent block nonce verification,
+// verifyNoncesFromBlocks starts a concurrent block seal verification,
 // returning a quit channel to abort the operations and a results channel
 // to retrieve the async verifications.
-func verifyNoncesFromBlocks(checker pow.PoW, blocks []*types.Block) (chan<- struct{}, <-chan nonceCheckResult) {
-	items := make([]pow.Block, len(blocks))
+func verifyNoncesFromBlocks(engine consensus.Engine, chain consensus.ChainReader, blocks []*types.Block) (chan<- struct{}, <-chan nonceCheckResult) {
+	headers := make([]*types.Header, len(blocks))
 	for i, block := range blocks {
-		items[i] = block
+		headers[i] = block.Header()
 	}
-	return verifyNonces(checker, items)
+	return verifyNonces(engine, chain, headers)
 }
 
-// verifyNonces starts a concurrent nonce verification, returning a quit channel
+// verifyNonces starts a concurrent seal verification, returning a quit channel
 // to abort the operations and a results channel to retrieve the async checks.
-func verifyNonces(checker pow.PoW, items []pow.Block) (chan<- struct{}, <-chan nonceCheckResult) {
+// The headers are verified with the chain extended by them, as they may not be
+// written to it yet.
+func verifyNonces(engine consensus.Engine, chain consensus.ChainReader, headers []*types.Header) (chan<- struct{}, <-chan nonceCheckResult) {
+	chain = newBatchChainReader(chain, headers)
+
+	return verifyConcurrently(len(headers), func(index int) error {
+		return engine.VerifySeal(chain, headers[index])
+	})
+}
+
+// verifyHeaders starts a concurrent verification of the fields and seals of a
+// contiguous batch of headers, returning a quit channel to abort the operations
+// and a results channel to retrieve the async checks. The first header is
+// verified against its parent in the chain, the others against the previous
+// header of the batch, so none waits for its parent to be imported.
+func verifyHeaders(config *ChainConfig, engine consensus.Engine, chain consensus.ChainReader, headers []*types.Header) (chan<- struct{}, <-chan nonceCheckResult) {
+	chain = newBatchChainReader(chain, headers)
+
+	return verifyConcurrently(len(headers), func(index int) error {
+		header := headers[index]
+		var parent *types.Header
+		if index == 0 {
+			parent = chain.GetHeader(header.ParentHash)
+		} else {
+			parent = headers[index-1]
+		}
+		if parent == nil {
+			return ParentError(header.ParentHash)
+		}
+		return ValidateHeader(config, engine, chain, header, parent, true, false)
+	})
+}
+
+// verifyConcurrently runs a verification of count items on as many workers as
+// allowed threads, returning a quit channel to abort the operations and a
+// results channel to retrieve the async checks.
+func verifyConcurrently(count int, verify func(index int) error) (chan<- struct{}, <-chan nonceCheckResult) {
 	// Spawn as many workers as allowed threads
 	workers := runtime.GOMAXPROCS(0)
-	if len(items) < workers {
-		workers = len(items)
+	if count < workers {
+		workers = count
 	}
 	// Create a task channel and spawn the verifiers
 	tasks := make(chan int, workers)
-	results := make(chan nonceCheckResult, len(items)) // Buffered to make sure all workers stop
+	results := make(chan nonceCheckResult, count) // Buffered to make sure all workers stop
 	for i := 0; i < workers; i++ {
 		go func() {
 			for index := range tasks {
-				results <- nonceCheckResult{index: index, valid: checker.Verify(items[index])}
+				err := verify(index)
+				results <- nonceCheckResult{index: index, valid: err == nil, err: err}
 			}
 		}()
 	}
@@ -74,7 +134,7 @@ func verifyNonces(checker pow.PoW, items []pow.Block) (chan<- struct{}, <-chan n
 	go func() {
 		defer close(tasks)
 
-		for i := range items {
+		for i := 0; i < count; i++ {
 			select {
 			case tasks <- i:
 				continue
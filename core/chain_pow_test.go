@@ -25,6 +25,7 @@ import (
 	"github.com/ellaism/go-ellaism/common"
 	"github.com/ellaism/go-ellaism/core/types"
 	"github.com/ellaism/go-ellaism/ethdb"
+	"github.com/ellaism/go-ellaism/event"
 	"github.com/ellaism/go-ellaism/pow"
 )
 
@@ -74,13 +75,13 @@ func TestPowVerification(t *testing.T) {
 
 				switch {
 				case full && valid:
-					_, results = verifyNoncesFromBlocks(FakePow{}, []*types.Block{blocks[i]})
+					_, results = verifyNoncesFromBlocks(NewEthash(testChainConfig(), FakePow{}), nil, []*types.Block{blocks[i]})
 				case full && !valid:
-					_, results = verifyNoncesFromBlocks(failPow{blocks[i].NumberU64()}, []*types.Block{blocks[i]})
+					_, results = verifyNoncesFromBlocks(NewEthash(testChainConfig(), failPow{blocks[i].NumberU64()}), nil, []*types.Block{blocks[i]})
 				case !full && valid:
-					_, results = verifyNoncesFromHeaders(FakePow{}, []*types.Header{headers[i]})
+					_, results = verifyNoncesFromHeaders(NewEthash(testChainConfig(), FakePow{}), nil, []*types.Header{headers[i]})
 				case !full && !valid:
-					_, results = verifyNoncesFromHeaders(failPow{headers[i].Number.Uint64()}, []*types.Header{headers[i]})
+					_, results = verifyNoncesFromHeaders(NewEthash(testChainConfig(), failPow{headers[i].Number.Uint64()}), nil, []*types.Header{headers[i]})
 				}
 				// Wait for the verification result
 				select {
@@ -133,13 +134,13 @@ func testPowConcurrentVerification(t *testing.T, threads int) {
 
 			switch {
 			case full && valid:
-				_, results = verifyNoncesFromBlocks(FakePow{}, blocks)
+				_, results = verifyNoncesFromBlocks(NewEthash(testChainConfig(), FakePow{}), nil, blocks)
 			case full && !valid:
-				_, results = verifyNoncesFromBlocks(failPow{uint64(len(blocks) - 1)}, blocks)
+				_, results = verifyNoncesFromBlocks(NewEthash(testChainConfig(), failPow{uint64(len(blocks) - 1)}), nil, blocks)
 			case !full && valid:
-				_, results = verifyNoncesFromHeaders(FakePow{}, headers)
+				_, results = verifyNoncesFromHeaders(NewEthash(testChainConfig(), FakePow{}), nil, headers)
 			case !full && !valid:
-				_, results = verifyNoncesFromHeaders(failPow{uint64(len(headers) - 1)}, headers)
+				_, results = verifyNoncesFromHeaders(NewEthash(testChainConfig(), failPow{uint64(len(headers) - 1)}), nil, headers)
 			}
 			// Wait for all the verification results
 			checks := make(map[int]bool)
@@ -203,9 +204,9 @@ func testPowConcurrentAbortion(t *testing.T, threads int) {
 
 		// Start the verifications and immediately abort
 		if full {
-			abort, results = verifyNoncesFromBlocks(delayedPow{time.Millisecond}, blocks)
+			abort, results = verifyNoncesFromBlocks(NewEthash(testChainConfig(), delayedPow{time.Millisecond}), nil, blocks)
 		} else {
-			abort, results = verifyNoncesFromHeaders(delayedPow{time.Millisecond}, headers)
+			abort, results = verifyNoncesFromHeaders(NewEthash(testChainConfig(), delayedPow{time.Millisecond}), nil, headers)
 		}
 		close(abort)
 
@@ -231,3 +232,54 @@ func testPowConcurrentAbortion(t *testing.T, threads int) {
 		}
 	}
 }
+
+// Tests that concurrent header verification checks the fields of every header
+// against its predecessor in the batch, and of the first against the chain.
+func TestHeaderConcurrentVerification(t *testing.T) {
+	var (
+		testdb, _ = ethdb.NewMemDatabase()
+		genesis   = WriteGenesisBlockForTesting(testdb)
+		blocks, _ = GenerateChain(testChainConfig(), genesis, testdb, 8, nil)
+	)
+	chain, err := NewBlockChain(testdb, testChainConfig(), FakePow{}, new(event.TypeMux))
+	if err != nil {
+		t.Fatal(err)
+	}
+	headers := make([]*types.Header, len(blocks))
+	for i, block := range blocks {
+		headers[i] = block.Header()
+	}
+	// Make a header of the batch as old as its parent
+	invalid := *headers[5]
+	invalid.Time = new(big.Int).Set(headers[4].Time)
+	headers[5] = &invalid
+
+	engine := NewEthash(testChainConfig(), FakePow{})
+	_, results := verifyHeaders(testChainConfig(), engine, chain, headers)
+
+	errs := make(map[int]error)
+	for k := 0; k < len(headers); k++ {
+		select {
+		case result := <-results:
+			if result.valid != (result.err == nil) {
+				t.Errorf("header %d: validity %v with error %v", result.index, result.valid, result.err)
+			}
+			errs[result.index] = result.err
+		case <-time.After(time.Second):
+			t.Fatalf("verification timeout")
+		}
+	}
+	for k := 0; k < 5; k++ {
+		if errs[k] != nil {
+			t.Errorf("header %d: unexpected error: %v", k, errs[k])
+		}
+	}
+	if errs[5] != BlockEqualTSErr {
+		t.Errorf("header 5: error mismatch: have %v, want %v", errs[5], BlockEqualTSErr)
+	}
+	// Headers whose parent is unknown are reported as such
+	_, results = verifyHeaders(testChainConfig(), engine, chain, headers[1:2])
+	if result := <-results; !IsParentErr(result.err) {
+		t.Errorf("orphan header error mismatch: have %v, want parent error", result.err)
+	}
+}
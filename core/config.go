@@ -31,6 +31,7 @@ import (
 	"reflect"
 
 	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/consensus/clique"
 	"github.com/ellaism/go-ellaism/core/state"
 	"github.com/ellaism/go-ellaism/core/types"
 	"github.com/ellaism/go-ellaism/core/vm"
@@ -50,14 +51,19 @@ var (
 	ErrHashKnownFork = validateError("known fork hash mismatch")
 )
 
+// DefaultMaxCodeSize is the contract code size limit of EIP-170, used when the
+// eip170 feature doesn't configure one.
+const DefaultMaxCodeSize = 24576
+
 // SufficientChainConfig holds necessary data for externalizing a given blockchain configuration.
 type SufficientChainConfig struct {
 	ID              string           `json:"id,omitempty"` // deprecated in favor of 'Identity', method decoding should id -> identity
 	Identity        string           `json:"identity"`
 	Name            string           `json:"name,omitempty"`
-	State           *StateConfig     `json:"state"`     // don't omitempty for clarity of potential custom options
-	Network         int              `json:"network"`   // eth.NetworkId (mainnet=1, morden=2)
-	Consensus       string           `json:"consensus"` // pow type (ethash OR ethash-test)
+	State           *StateConfig     `json:"state"`            // don't omitempty for clarity of potential custom options
+	Network         int              `json:"network"`          // eth.NetworkId (mainnet=1, morden=2)
+	Consensus       string           `json:"consensus"`        // consensus engine (ethash, ethash-test OR clique)
+	Clique          *clique.Config   `json:"clique,omitempty"` // proof-of-authority parameters, for clique consensus
 	Genesis         *GenesisDump     `json:"genesis"`
 	ChainConfig     *ChainConfig     `json:"chainConfig"`
 	Bootstrap       []string         `json:"bootstrap"`
@@ -140,6 +146,7 @@ type ForkFeature struct {
 	Options           ChainFeatureConfigOptions `json:"options"` // no * because they have to be iterable(?)
 	optionsLock       sync.RWMutex
 	ParsedOptions     map[string]interface{} `json:"-"` // don't include in JSON dumps, since its for holding parsed JSON in mem
+	parsedGasTable    *vm.GasTable           // gas table of a gastable feature, guarded by parsedOptionsLock
 	parsedOptionsLock sync.RWMutex
 	// TODO Derive Oracle contracts from fork struct (Version, Registrar, Release)
 }
@@ -167,9 +174,12 @@ func (c *SufficientChainConfig) IsValid() (string, bool) {
 		return "networkId", false
 	}
 
-	if c := c.Consensus; c == "" || (c != "ethash" && c != "ethash-test") {
+	if c := c.Consensus; c == "" || (c != "ethash" && c != "ethash-test" && c != "clique") {
 		return "consensus", false
 	}
+	if c.Consensus == "clique" && c.Clique == nil {
+		return "clique", false
+	}
 
 	if c.Genesis == nil {
 		return "genesis", false
@@ -199,6 +209,29 @@ func (c *SufficientChainConfig) IsValid() (string, bool) {
 		return "diehard chainid", false
 	}
 
+	for _, fork := range c.ChainConfig.Forks {
+		for _, feat := range fork.Features {
+			switch feat.ID {
+			case "gastable":
+				if _, err := feat.gasTable(); err != nil {
+					return fmt.Sprintf("forks.%s.gastable: %v", fork.Name, err), false
+				}
+			case "difficulty":
+				if _, err := feat.difficultyAlgorithm(fork); err != nil {
+					return fmt.Sprintf("forks.%s.difficulty: %v", fork.Name, err), false
+				}
+			case "bombdefuse":
+				if _, err := feat.bombDefusal(fork, diffusedDifficulty, fork.Block); err != nil {
+					return fmt.Sprintf("forks.%s.bombdefuse: %v", fork.Name, err), false
+				}
+			case "treasury":
+				if _, _, err := feat.treasury(); err != nil {
+					return fmt.Sprintf("forks.%s.treasury: %v", fork.Name, err), false
+				}
+			}
+		}
+	}
+
 	return "", true
 }
 
@@ -298,7 +331,7 @@ func (c *ChainConfig) ForkByName(name string) *Fork {
 	return &Fork{}
 }
 
-// GetFeature looks up fork features by id, where id can (currently) be [difficulty, gastable, eip155].
+// GetFeature looks up fork features by id, where id can (currently) be [difficulty, gastable, eip155, eip2930].
 // GetFeature returns the feature|nil, the latest fork configuring a given id, and if the given feature id was found at all
 // If queried feature is not found, returns ForkFeature{}, Fork{}, false.
 // If queried block number and/or feature is a zero-value, returns ForkFeature{}, Fork{}, false.
@@ -377,6 +410,104 @@ func (c *ChainConfig) GetSigner(blockNumber *big.Int) types.Signer {
 	return types.BasicSigner{}
 }
 
+// txTypeFeatures maps the types of typed transactions to the ids of the fork
+// features enabling them. Legacy transactions are always supported.
+var txTypeFeatures = map[byte]string{
+	types.AccessListTxType: "eip2930",
+}
+
+// IsEIP161 returns whether the EIP-161 state clearing rules apply at the given
+// block: touched empty accounts are deleted, contracts are created with a
+// nonce of one and calls without value no longer create accounts.
+func (c *ChainConfig) IsEIP161(num *big.Int) bool {
+	_, _, configured := c.GetFeature(num, "eip161")
+	return configured
+}
+
+// MaxCodeSize returns the maximum size of the code of a contract created at the
+// given block, as limited by the eip170 feature and its optional maxCodeSize
+// option, or zero if the size is unlimited.
+func (c *ChainConfig) MaxCodeSize(num *big.Int) int {
+	feat, _, configured := c.GetFeature(num, "eip170")
+	if !configured {
+		return 0
+	}
+	if size, ok := feat.GetBigInt("maxCodeSize"); ok {
+		return int(size.Int64())
+	}
+	return DefaultMaxCodeSize
+}
+
+// IsEIP1014 returns whether the CREATE2 opcode is available at the given block.
+func (c *ChainConfig) IsEIP1014(num *big.Int) bool {
+	_, _, configured := c.GetFeature(num, "eip1014")
+	return configured
+}
+
+// IsEIP145 returns whether the SHL, SHR and SAR opcodes are available at the
+// given block.
+func (c *ChainConfig) IsEIP145(num *big.Int) bool {
+	_, _, configured := c.GetFeature(num, "eip145")
+	return configured
+}
+
+// IsEIP1052 returns whether the EXTCODEHASH opcode is available at the given
+// block.
+func (c *ChainConfig) IsEIP1052(num *big.Int) bool {
+	_, _, configured := c.GetFeature(num, "eip1052")
+	return configured
+}
+
+// IsEIP1344 returns whether the CHAINID opcode is available at the given block.
+func (c *ChainConfig) IsEIP1344(num *big.Int) bool {
+	_, _, configured := c.GetFeature(num, "eip1344")
+	return configured
+}
+
+// IsEIP152 returns whether the BLAKE2 compression function contract is
+// available at the given block.
+func (c *ChainConfig) IsEIP152(num *big.Int) bool {
+	_, _, configured := c.GetFeature(num, "eip152")
+	return configured
+}
+
+// IsEIP1884 returns whether the SELFBALANCE opcode is available at the given
+// block. The repricing of EIP-1884 is scheduled through the eip1884 gastable.
+func (c *ChainConfig) IsEIP1884(num *big.Int) bool {
+	_, _, configured := c.GetFeature(num, "eip1884")
+	return configured
+}
+
+// IsEIP140 returns whether the REVERT opcode is available at the given block.
+func (c *ChainConfig) IsEIP140(num *big.Int) bool {
+	_, _, configured := c.GetFeature(num, "eip140")
+	return configured
+}
+
+// ChainID returns the chain id used for replay protection, see GetChainID.
+func (c *ChainConfig) ChainID() *big.Int {
+	return c.GetChainID()
+}
+
+// SupportsTxType returns whether transactions of the given type are valid at
+// the given block number. If the number is nil, it returns whether the type is
+// enabled at any block of the configuration.
+func (c *ChainConfig) SupportsTxType(num *big.Int, typ byte) bool {
+	if typ == types.LegacyTxType {
+		return true
+	}
+	id, ok := txTypeFeatures[typ]
+	if !ok {
+		return false
+	}
+	if num == nil {
+		_, _, configured := c.HasFeature(id)
+		return configured
+	}
+	_, _, configured := c.GetFeature(num, id)
+	return configured
+}
+
 // GasTable returns the gas table corresponding to the current fork
 // The returned GasTable's fields shouldn't, under any circumstances, be changed.
 func (c *ChainConfig) GasTable(num *big.Int) *vm.GasTable {
@@ -384,20 +515,71 @@ func (c *ChainConfig) GasTable(num *big.Int) *vm.GasTable {
 	if !configured {
 		return DefaultHomeSteadGasTable
 	}
-	name, ok := f.GetString("type")
+	table, err := f.gasTable()
+	if err != nil {
+		panic(fmt.Errorf("Unsupported gastable value at block: %v: %v", num, err))
+	}
+	return table
+}
+
+// gasTableFields maps the JSON keys of the gas table costs to their field
+// indexes in vm.GasTable.
+var gasTableFields = func() map[string]int {
+	fields := make(map[string]int)
+	typ := reflect.TypeOf(vm.GasTable{})
+	for i := 0; i < typ.NumField(); i++ {
+		fields[typ.Field(i).Tag.Get("json")] = i
+	}
+	return fields
+}()
+
+// gasTable returns the gas table configured by a gastable feature: the default
+// table named by its "type" option, with the costs named by any other option
+// overridden, eg. {"type": "eip160", "sload": 800}.
+func (o *ForkFeature) gasTable() (*vm.GasTable, error) {
+	o.parsedOptionsLock.RLock()
+	table := o.parsedGasTable
+	o.parsedOptionsLock.RUnlock()
+	if table != nil {
+		return table, nil
+	}
+
+	name, _ := o.GetString("type")
+	base, ok := gasTables[name]
 	if !ok {
-		name = ""
-	} // will wall to default panic
-	switch name {
-	case "homestead":
-		return DefaultHomeSteadGasTable
-	case "eip150":
-		return DefaultGasRepriceGasTable
-	case "eip160":
-		return DefaultDiehardGasTable
-	default:
-		panic(fmt.Errorf("Unsupported gastable value '%v' at block: %v", name, num))
+		return nil, fmt.Errorf("unknown type '%v'", name)
+	}
+	o.optionsLock.RLock()
+	keys := make([]string, 0, len(o.Options))
+	for key := range o.Options {
+		if key != "type" {
+			keys = append(keys, key)
+		}
+	}
+	o.optionsLock.RUnlock()
+	sort.Strings(keys)
+
+	table = base
+	if len(keys) > 0 {
+		table = new(vm.GasTable)
+		*table = *base
 	}
+	for _, key := range keys {
+		field, ok := gasTableFields[key]
+		if !ok {
+			return nil, fmt.Errorf("unknown cost '%v'", key)
+		}
+		cost, ok := o.GetBigInt(key)
+		if !ok || cost.Sign() < 0 {
+			return nil, fmt.Errorf("invalid %v cost", key)
+		}
+		reflect.ValueOf(table).Elem().Field(field).Set(reflect.ValueOf(new(big.Int).Set(cost)))
+	}
+
+	o.parsedOptionsLock.Lock()
+	o.parsedGasTable = table
+	o.parsedOptionsLock.Unlock()
+	return table, nil
 }
 
 // WriteToJSONFile writes a given config to a specified file path.
@@ -507,7 +689,9 @@ func (o *ForkFeature) GetString(name string) (string, bool) {
 	defer o.optionsLock.RUnlock()
 
 	val, ok := o.Options[name].(string)
-	o.ParsedOptions[name] = val //expect it as a string in config
+	if ok {
+		o.ParsedOptions[name] = val //expect it as a string in config
+	}
 
 	return val, ok
 }
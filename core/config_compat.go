@@ -0,0 +1,208 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/ethdb"
+	"github.com/ellaism/go-ellaism/logger"
+	"github.com/ellaism/go-ellaism/logger/glog"
+)
+
+// ConfigCompatError is returned when a chain is started with a chain config
+// differing from the one it was built with at a block the chain already holds,
+// which would have the chain diverge from the network silently.
+type ConfigCompatError struct {
+	What     string // Differences between the configs at the block
+	Block    uint64 // Earliest block the configs differ at
+	Head     uint64 // Number of the head header of the chain
+	RewindTo uint64 // Block to rewind the chain to for the new config to apply
+}
+
+func (err *ConfigCompatError) Error() string {
+	return fmt.Sprintf("incompatible chain config at block #%d: %s, but the chain is already at block #%d (rewind to #%d to apply it)", err.Block, err.What, err.Head, err.RewindTo)
+}
+
+// CheckCompatible compares the config with the config stored for a chain whose
+// head header has the given number. It returns a *ConfigCompatError if they
+// differ at or below the head, where the chain was processed with the stored
+// config. Forks are compared by name, block, required hash and features, and
+// bad hashes by block and hash.
+func (c *ChainConfig) CheckCompatible(stored *ChainConfig, head uint64) *ConfigCompatError {
+	block, what := c.firstDifference(stored)
+	if block == nil || !block.IsUint64() || block.Uint64() > head {
+		return nil
+	}
+	err := &ConfigCompatError{What: what, Block: block.Uint64(), Head: head}
+	if err.Block > 0 {
+		err.RewindTo = err.Block - 1
+	}
+	return err
+}
+
+// firstDifference returns the earliest block at which the forks or bad hashes
+// of the configs differ, along with a description of the differences, or nil
+// if the configs are the same.
+func (c *ChainConfig) firstDifference(stored *ChainConfig) (*big.Int, string) {
+	var blocks []*big.Int
+	for _, config := range []*ChainConfig{c, stored} {
+		for _, fork := range config.Forks {
+			if fork.Block != nil {
+				blocks = append(blocks, fork.Block)
+			}
+		}
+		for _, bad := range config.BadHashes {
+			if bad.Block != nil {
+				blocks = append(blocks, bad.Block)
+			}
+		}
+	}
+	sort.Sort(bigInts(blocks))
+
+	for _, block := range blocks {
+		var diffs []string
+
+		have, want := stored.forksAt(block), c.forksAt(block)
+		for _, name := range forkNames(have, want) {
+			switch {
+			case want[name] == nil:
+				if moved := c.ForkByName(name); moved != nil && moved.Block != nil {
+					diffs = append(diffs, fmt.Sprintf("fork %q moved to block #%v", name, moved.Block))
+				} else {
+					diffs = append(diffs, fmt.Sprintf("fork %q removed", name))
+				}
+			case have[name] == nil:
+				if moved := stored.ForkByName(name); moved != nil && moved.Block != nil {
+					diffs = append(diffs, fmt.Sprintf("fork %q moved from block #%v", name, moved.Block))
+				} else {
+					diffs = append(diffs, fmt.Sprintf("fork %q added", name))
+				}
+			case !bytes.Equal(have[name], want[name]):
+				diffs = append(diffs, fmt.Sprintf("fork %q changed", name))
+			}
+		}
+		haveBad, wantBad := stored.badHashesAt(block), c.badHashesAt(block)
+		for hash := range haveBad {
+			if !wantBad[hash] {
+				diffs = append(diffs, fmt.Sprintf("bad block hash %x removed", hash))
+			}
+		}
+		for hash := range wantBad {
+			if !haveBad[hash] {
+				diffs = append(diffs, fmt.Sprintf("bad block hash %x added", hash))
+			}
+		}
+		if len(diffs) > 0 {
+			sort.Strings(diffs)
+			return block, strings.Join(diffs, ", ")
+		}
+	}
+	return nil, ""
+}
+
+// forksAt returns the JSON encodings of the forks of the config at the given
+// block, by fork name.
+func (c *ChainConfig) forksAt(block *big.Int) map[string][]byte {
+	forks := make(map[string][]byte)
+	for _, fork := range c.Forks {
+		if fork.Block != nil && fork.Block.Cmp(block) == 0 {
+			forks[fork.Name], _ = json.Marshal(fork)
+		}
+	}
+	return forks
+}
+
+// badHashesAt returns the bad hashes of the config at the given block.
+func (c *ChainConfig) badHashesAt(block *big.Int) map[common.Hash]bool {
+	hashes := make(map[common.Hash]bool)
+	for _, bad := range c.BadHashes {
+		if bad.Block != nil && bad.Block.Cmp(block) == 0 {
+			hashes[bad.Hash] = true
+		}
+	}
+	return hashes
+}
+
+// forkNames returns the sorted names of the forks in either set.
+func forkNames(sets ...map[string][]byte) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, set := range sets {
+		for name := range set {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// bigInts implements sort interface, sorting by value
+type bigInts []*big.Int
+
+func (b bigInts) Len() int           { return len(b) }
+func (b bigInts) Less(i, j int) bool { return b[i].Cmp(b[j]) < 0 }
+func (b bigInts) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+
+// SetupChainConfig checks the config a chain is started with against the config
+// stored for the chain, then stores it. If the configs differ at a block the
+// chain already holds, the stored config is kept and a *ConfigCompatError is
+// returned; the chain must then be rewound before the config can be stored,
+// see BlockChain.ApplyChainConfig. Nothing is checked nor stored for chains
+// without a genesis block yet.
+func SetupChainConfig(db ethdb.Database, config *ChainConfig) error {
+	genesis := GetCanonicalHash(db, 0)
+	if genesis == (common.Hash{}) {
+		return nil
+	}
+	stored, err := GetChainConfig(db, genesis)
+	if err != nil {
+		glog.V(logger.Warn).Warnf("Replacing unreadable stored chain config: %v", err)
+	}
+	if stored != nil {
+		var head uint64
+		if header := GetHeader(db, GetHeadHeaderHash(db)); header != nil {
+			head = header.Number.Uint64()
+		}
+		if compat := config.CheckCompatible(stored, head); compat != nil {
+			return compat
+		}
+	}
+	return WriteChainConfig(db, genesis, config)
+}
+
+// ApplyChainConfig rewinds the chain to the block given by a config
+// compatibility error, so that the blocks past it are processed again with the
+// config of the chain, which is then stored as the config of the chain.
+func (self *BlockChain) ApplyChainConfig(compat *ConfigCompatError) error {
+	glog.V(logger.Warn).Warnf("Rewinding chain to block #%d to apply new chain config: %s", compat.RewindTo, compat.What)
+	glog.D(logger.Warn).Warnf("Rewinding chain to block #%d to apply new chain config: %s", compat.RewindTo, compat.What)
+
+	if err := self.SetHead(compat.RewindTo); err != nil {
+		return err
+	}
+	return WriteChainConfig(self.chainDb, self.Genesis().Hash(), self.config)
+}
@@ -0,0 +1,120 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/ethdb"
+	"github.com/ellaism/go-ellaism/event"
+)
+
+// configWithFork returns a test chain config with an extra fork at the given block.
+func configWithFork(block int64, chainID int) *ChainConfig {
+	config := MakeDiehardChainConfig()
+	config.Forks = append(config.Forks, &Fork{
+		Name:  "Later",
+		Block: big.NewInt(block),
+		Features: []*ForkFeature{
+			{ID: "eip155", Options: ChainFeatureConfigOptions{"chainID": chainID}},
+		},
+	})
+	return config
+}
+
+func TestChainConfigCheckCompatible(t *testing.T) {
+	withBadHash := MakeDiehardChainConfig()
+	withBadHash.BadHashes = []*BadHash{{Block: big.NewInt(5), Hash: common.Hash{0x01}}}
+
+	tests := []struct {
+		stored, config *ChainConfig
+		head           uint64
+		block          uint64 // Expected first incompatible block, if what is set
+		what           string // Expected differences, empty if compatible
+	}{
+		{stored: configWithFork(10, 63), config: configWithFork(10, 63), head: 20},
+		{stored: configWithFork(10, 63), config: configWithFork(10, 64), head: 20, block: 10, what: `fork "Later" changed`},
+		{stored: configWithFork(30, 63), config: configWithFork(30, 64), head: 20},
+		{stored: configWithFork(10, 63), config: configWithFork(10, 64), head: 9},
+		{stored: configWithFork(30, 63), config: configWithFork(15, 63), head: 20, block: 15, what: `fork "Later" moved from block #30`},
+		{stored: configWithFork(10, 63), config: configWithFork(25, 63), head: 20, block: 10, what: `fork "Later" moved to block #25`},
+		{stored: MakeDiehardChainConfig(), config: configWithFork(10, 63), head: 20, block: 10, what: `fork "Later" added`},
+		{stored: configWithFork(10, 63), config: MakeDiehardChainConfig(), head: 10, block: 10, what: `fork "Later" removed`},
+		{stored: MakeDiehardChainConfig(), config: withBadHash, head: 20, block: 5, what: "bad block hash 0100000000000000000000000000000000000000000000000000000000000000 added"},
+	}
+	for i, tt := range tests {
+		err := tt.config.CheckCompatible(tt.stored, tt.head)
+		switch {
+		case tt.what == "" && err != nil:
+			t.Errorf("test %d: unexpected error: %v", i, err)
+		case tt.what != "" && err == nil:
+			t.Errorf("test %d: expected incompatibility at block #%d", i, tt.block)
+		case tt.what != "" && (err.Block != tt.block || err.RewindTo != tt.block-1 || err.What != tt.what):
+			t.Errorf("test %d: error mismatch: have %q at #%d rewinding to #%d, want %q at #%d", i, err.What, err.Block, err.RewindTo, tt.what, tt.block)
+		}
+	}
+}
+
+// Tests that the chain config is stored, that a config changing blocks the chain
+// holds is refused, and that it's stored once the chain is rewound.
+func TestSetupChainConfig(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	genesis := WriteGenesisBlockForTesting(db)
+	config := MakeDiehardChainConfig()
+
+	blockchain, err := NewBlockChain(db, config, FakePow{}, new(event.TypeMux))
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain, _ := GenerateChain(config, genesis, db, 5, nil)
+	if i, err := blockchain.InsertChain(chain); err != nil {
+		t.Fatalf("failed to insert block %d: %v", i, err)
+	}
+	if err := SetupChainConfig(db, config); err != nil {
+		t.Fatalf("failed to set up chain config: %v", err)
+	}
+	if err := SetupChainConfig(db, MakeDiehardChainConfig()); err != nil {
+		t.Fatalf("stored chain config reported incompatible with itself: %v", err)
+	}
+
+	// A fork below the head is refused, keeping the stored config
+	changed := configWithFork(3, 64)
+	compat, ok := SetupChainConfig(db, changed).(*ConfigCompatError)
+	if !ok || compat.Block != 3 || compat.Head != 5 || compat.RewindTo != 2 {
+		t.Fatalf("incompatibility mismatch: have %v, want fork at #3 with head #5", compat)
+	}
+	if stored, _ := GetChainConfig(db, genesis.Hash()); stored == nil || len(stored.Forks) != 1 {
+		t.Fatalf("stored chain config replaced: %v", stored)
+	}
+
+	// Rewinding the chain applies the new config
+	blockchain, err = NewBlockChain(db, changed, FakePow{}, new(event.TypeMux))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := blockchain.ApplyChainConfig(compat); err != nil {
+		t.Fatalf("failed to apply chain config: %v", err)
+	}
+	if n := blockchain.CurrentBlock().NumberU64(); n != 2 {
+		t.Errorf("head block after rewind: have #%d, want #2", n)
+	}
+	if err := SetupChainConfig(db, configWithFork(3, 64)); err != nil {
+		t.Errorf("applied chain config reported incompatible: %v", err)
+	}
+}
@@ -2,6 +2,8 @@ package core
 
 import (
 	"math/big"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/ellaism/go-ellaism/core/types"
@@ -106,7 +108,7 @@ func TestMakeGenesisDump(t *testing.T) {
 
 func TestMakeGenesisDump2(t *testing.T) {
 	// setup so we have a genesis block in this test db
-	for i, gen := range []*GenesisDump{DefaultConfigMainnet.Genesis, DefaultConfigMorden.Genesis} {
+	for i, gen := range []*GenesisDump{DefaultConfigMainnet.Genesis, DefaultConfigMorden.Genesis, DefaultConfigDev.Genesis} {
 		db, _ := ethdb.NewMemDatabase()
 		genesisDump := gen
 		gBlock1, err := WriteGenesisBlock(db, genesisDump)
@@ -140,6 +142,44 @@ func TestMakeGenesisDump2(t *testing.T) {
 	}
 }
 
+// Tests that bundled chain configs are found by each of their names, and that
+// each of them is a network of its own.
+func TestBundledChainConfig(t *testing.T) {
+	names := map[string]*SufficientChainConfig{
+		"main":    DefaultConfigMainnet,
+		"mainnet": DefaultConfigMainnet,
+		"morden":  DefaultConfigMorden,
+		"testnet": DefaultConfigMorden,
+		"dev":     DefaultConfigDev,
+	}
+	for name, want := range names {
+		if got := BundledChainConfig(name); got != want {
+			t.Errorf("%s: have config %v, want %v", name, got.Identity, want.Identity)
+		}
+	}
+	if config := BundledChainConfig("kitty"); config != nil {
+		t.Errorf("unexpected bundled config for custom chain: %v", config.Identity)
+	}
+
+	dev, err := DefaultConfigDev.Genesis.Header()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mainnet, err := DefaultConfigMainnet.Genesis.Header()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dev.Hash() == mainnet.Hash() {
+		t.Error("dev chain shares the mainnet genesis block")
+	}
+	if DefaultConfigDev.Network == DefaultConfigMainnet.Network || DefaultConfigDev.ChainConfig.GetChainID().Cmp(DefaultConfigMainnet.ChainConfig.GetChainID()) == 0 {
+		t.Error("dev chain shares the mainnet network and chain ids")
+	}
+	if len(DefaultConfigDev.ParsedBootstrap) != 0 {
+		t.Errorf("dev chain has %d bootnodes, want none", len(DefaultConfigDev.ParsedBootstrap))
+	}
+}
+
 func getDefaultChainConfigSorted() *ChainConfig {
 	return DefaultConfigMainnet.ChainConfig.SortForks()
 }
@@ -264,6 +304,7 @@ func TestChainConfig_GetChainID(t *testing.T) {
 	cases := map[string]*big.Int{
 		"../core/config/mainnet.json": DefaultConfigMainnet.ChainConfig.GetChainID(),
 		"../core/config/morden.json":  DefaultConfigMorden.ChainConfig.GetChainID(),
+		"../core/config/dev.json":     DefaultConfigDev.ChainConfig.GetChainID(),
 	}
 	for extConfigPath, wantInt := range cases {
 		p, e := filepath.Abs(extConfigPath)
@@ -317,6 +358,71 @@ func TestChainConfig_GetSigner(t *testing.T) {
 
 }
 
+func TestChainConfig_SupportsTxType(t *testing.T) {
+	const typ = 0x7e
+	txTypeFeatures[typ] = "testtxtype"
+	defer delete(txTypeFeatures, typ)
+
+	c := &ChainConfig{Forks: []*Fork{
+		{Name: "Homestead", Block: big.NewInt(0)},
+		{Name: "Typed", Block: big.NewInt(10), Features: []*ForkFeature{{ID: "testtxtype"}}},
+	}}
+	for _, tt := range []struct {
+		num  *big.Int
+		typ  byte
+		want bool
+	}{
+		{big.NewInt(0), types.LegacyTxType, true},
+		{big.NewInt(9), typ, false},
+		{big.NewInt(10), typ, true},
+		{nil, typ, true},
+		{big.NewInt(10), typ - 1, false},
+		{nil, typ - 1, false},
+	} {
+		if have := c.SupportsTxType(tt.num, tt.typ); have != tt.want {
+			t.Errorf("block %v, type %d: have %v, want %v", tt.num, tt.typ, have, tt.want)
+		}
+	}
+}
+
+func TestChainConfig_GasTable(t *testing.T) {
+	c := &ChainConfig{Forks: []*Fork{
+		{Name: "Homestead", Block: big.NewInt(0)},
+		{Name: "Diehard", Block: big.NewInt(10), Features: []*ForkFeature{
+			{ID: "gastable", Options: ChainFeatureConfigOptions{"type": "eip160"}},
+		}},
+		{Name: "Repricing", Block: big.NewInt(20), Features: []*ForkFeature{
+			{ID: "gastable", Options: ChainFeatureConfigOptions{"type": "eip160", "sload": 800, "sstoreSet": "0x5208"}},
+		}},
+	}}
+	if have := c.GasTable(big.NewInt(0)); have != DefaultHomeSteadGasTable {
+		t.Errorf("block 0: have %+v, want the homestead gas table", have)
+	}
+	if have := c.GasTable(big.NewInt(10)); have != DefaultDiehardGasTable {
+		t.Errorf("block 10: have %+v, want the diehard gas table", have)
+	}
+
+	want := *DefaultDiehardGasTable
+	want.SLoad, want.SStoreSet = big.NewInt(800), big.NewInt(21000)
+	if have := c.GasTable(big.NewInt(20)); !reflect.DeepEqual(*have, want) {
+		t.Errorf("block 20: have %+v, want %+v", *have, want)
+	}
+	if DefaultDiehardGasTable.SLoad.Cmp(big.NewInt(200)) != 0 {
+		t.Errorf("default gas table modified: sload %v", DefaultDiehardGasTable.SLoad)
+	}
+
+	for _, options := range []ChainFeatureConfigOptions{
+		{"type": "eip999"},
+		{"type": "eip160", "sloadd": 800},
+		{"type": "eip160", "sload": -1},
+		{"type": "eip160", "sload": "many"},
+	} {
+		if _, err := (&ForkFeature{ID: "gastable", Options: options}).gasTable(); err == nil {
+			t.Errorf("options %v: expected error", options)
+		}
+	}
+}
+
 func makeOKSufficientChainConfig(dump *GenesisDump, config *ChainConfig) *SufficientChainConfig {
 	// Setup.
 	whole := &SufficientChainConfig{}
@@ -342,8 +448,8 @@ func makeOKSufficientChainConfig(dump *GenesisDump, config *ChainConfig) *Suffic
 
 // TestSufficientChainConfig_IsValid tests against defaulty dumps and chainconfigs.
 func TestSufficientChainConfig_IsValid(t *testing.T) {
-	dumps := []*GenesisDump{DefaultConfigMainnet.Genesis, DefaultConfigMorden.Genesis}
-	configs := []*ChainConfig{DefaultConfigMainnet.ChainConfig, DefaultConfigMorden.ChainConfig}
+	dumps := []*GenesisDump{DefaultConfigMainnet.Genesis, DefaultConfigMorden.Genesis, DefaultConfigDev.Genesis}
+	configs := []*ChainConfig{DefaultConfigMainnet.ChainConfig, DefaultConfigMorden.ChainConfig, DefaultConfigDev.ChainConfig}
 
 	for i, dump := range dumps {
 		for j, config := range configs {
@@ -389,6 +495,19 @@ func TestSufficientChainConfig_IsValid(t *testing.T) {
 			}
 			scc.Genesis = o
 
+			oDiff := scc.ChainConfig.Forks[0].Features
+			scc.ChainConfig.Forks[0].Features = append([]*ForkFeature{{ID: "difficulty", Options: ChainFeatureConfigOptions{"type": "unknown"}}}, oDiff...)
+			if s, ok := scc.IsValid(); ok {
+				t.Errorf("unexpected ok: %v @ %v/%v", s, i, j)
+			}
+			scc.ChainConfig.Forks[0].Features = oDiff
+
+			scc.ChainConfig.Forks[0].Features = append([]*ForkFeature{{ID: "bombdefuse", Options: ChainFeatureConfigOptions{"type": "unknown"}}}, oDiff...)
+			if s, ok := scc.IsValid(); ok {
+				t.Errorf("unexpected ok: %v @ %v/%v", s, i, j)
+			}
+			scc.ChainConfig.Forks[0].Features = oDiff
+
 			oo := scc.ChainConfig
 			scc.ChainConfig = nil
 			if s, ok := scc.IsValid(); ok {
@@ -431,6 +550,13 @@ func TestSufficientChainConfig_IsValid(t *testing.T) {
 			}
 			scc.Genesis.Difficulty = ooooooo0
 
+			gastable := &ForkFeature{ID: "gastable", Options: ChainFeatureConfigOptions{"type": "eip160", "sloadd": 800}}
+			scc.ChainConfig = &ChainConfig{Forks: append(append(Forks{}, config.Forks...), &Fork{Name: "Repricing", Block: big.NewInt(1e9), Features: []*ForkFeature{gastable}})}
+			if s, ok := scc.IsValid(); ok || !strings.Contains(s, "gastable") {
+				t.Errorf("unexpected ok or reason: %v @ %v/%v", s, i, j)
+			}
+			scc.ChainConfig = config
+
 			ooooooo00 := scc.ChainConfig.Forks
 			scc.ChainConfig.Forks = []*Fork{}
 			if s, ok := scc.IsValid(); ok {
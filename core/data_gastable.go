@@ -25,34 +25,153 @@ import (
 )
 
 var DefaultHomeSteadGasTable = &vm.GasTable{
+	QuickStep:   big.NewInt(2),
+	FastestStep: big.NewInt(3),
+	FastStep:    big.NewInt(5),
+	MidStep:     big.NewInt(8),
+	SlowStep:    big.NewInt(10),
+	ExtStep:     big.NewInt(20),
+
 	ExtcodeSize:     big.NewInt(20),
 	ExtcodeCopy:     big.NewInt(20),
+	ExtcodeHash:     big.NewInt(400),
 	Balance:         big.NewInt(20),
 	SLoad:           big.NewInt(50),
 	Calls:           big.NewInt(40),
 	Suicide:         big.NewInt(0),
 	ExpByte:         big.NewInt(10),
 	CreateBySuicide: nil,
+
+	SStoreSet:         big.NewInt(20000),
+	SStoreReset:       big.NewInt(5000),
+	SStoreRefund:      big.NewInt(15000),
+	SuicideRefund:     big.NewInt(24000),
+	Sha3:              big.NewInt(30),
+	Sha3Word:          big.NewInt(6),
+	Copy:              big.NewInt(3),
+	Log:               big.NewInt(375),
+	LogTopic:          big.NewInt(375),
+	LogData:           big.NewInt(8),
+	Memory:            big.NewInt(3),
+	QuadCoeffDiv:      big.NewInt(512),
+	JumpDest:          big.NewInt(1),
+	Create:            big.NewInt(32000),
+	CreateData:        big.NewInt(200),
+	CallValueTransfer: big.NewInt(9000),
+	CallNewAccount:    big.NewInt(25000),
+	CallStipend:       big.NewInt(2300),
+
+	TxDataNonZero: big.NewInt(68),
 }
 
 var DefaultGasRepriceGasTable = &vm.GasTable{
+	QuickStep:   big.NewInt(2),
+	FastestStep: big.NewInt(3),
+	FastStep:    big.NewInt(5),
+	MidStep:     big.NewInt(8),
+	SlowStep:    big.NewInt(10),
+	ExtStep:     big.NewInt(20),
+
 	ExtcodeSize:     big.NewInt(700),
 	ExtcodeCopy:     big.NewInt(700),
+	ExtcodeHash:     big.NewInt(400),
 	Balance:         big.NewInt(400),
 	SLoad:           big.NewInt(200),
 	Calls:           big.NewInt(700),
 	Suicide:         big.NewInt(5000),
 	ExpByte:         big.NewInt(10),
 	CreateBySuicide: big.NewInt(25000),
+
+	SStoreSet:         big.NewInt(20000),
+	SStoreReset:       big.NewInt(5000),
+	SStoreRefund:      big.NewInt(15000),
+	SuicideRefund:     big.NewInt(24000),
+	Sha3:              big.NewInt(30),
+	Sha3Word:          big.NewInt(6),
+	Copy:              big.NewInt(3),
+	Log:               big.NewInt(375),
+	LogTopic:          big.NewInt(375),
+	LogData:           big.NewInt(8),
+	Memory:            big.NewInt(3),
+	QuadCoeffDiv:      big.NewInt(512),
+	JumpDest:          big.NewInt(1),
+	Create:            big.NewInt(32000),
+	CreateData:        big.NewInt(200),
+	CallValueTransfer: big.NewInt(9000),
+	CallNewAccount:    big.NewInt(25000),
+	CallStipend:       big.NewInt(2300),
+
+	TxDataNonZero: big.NewInt(68),
 }
 
 var DefaultDiehardGasTable = &vm.GasTable{
+	QuickStep:   big.NewInt(2),
+	FastestStep: big.NewInt(3),
+	FastStep:    big.NewInt(5),
+	MidStep:     big.NewInt(8),
+	SlowStep:    big.NewInt(10),
+	ExtStep:     big.NewInt(20),
+
 	ExtcodeSize:     big.NewInt(700),
 	ExtcodeCopy:     big.NewInt(700),
+	ExtcodeHash:     big.NewInt(400),
 	Balance:         big.NewInt(400),
 	SLoad:           big.NewInt(200),
 	Calls:           big.NewInt(700),
 	Suicide:         big.NewInt(5000),
 	ExpByte:         big.NewInt(50),
 	CreateBySuicide: big.NewInt(25000),
+
+	SStoreSet:         big.NewInt(20000),
+	SStoreReset:       big.NewInt(5000),
+	SStoreRefund:      big.NewInt(15000),
+	SuicideRefund:     big.NewInt(24000),
+	Sha3:              big.NewInt(30),
+	Sha3Word:          big.NewInt(6),
+	Copy:              big.NewInt(3),
+	Log:               big.NewInt(375),
+	LogTopic:          big.NewInt(375),
+	LogData:           big.NewInt(8),
+	Memory:            big.NewInt(3),
+	QuadCoeffDiv:      big.NewInt(512),
+	JumpDest:          big.NewInt(1),
+	Create:            big.NewInt(32000),
+	CreateData:        big.NewInt(200),
+	CallValueTransfer: big.NewInt(9000),
+	CallNewAccount:    big.NewInt(25000),
+	CallStipend:       big.NewInt(2300),
+
+	TxDataNonZero: big.NewInt(68),
+}
+
+// DefaultEIP1884GasTable is the Diehard gas table with the state reading
+// operations repriced by EIP-1884.
+var DefaultEIP1884GasTable = deriveGasTable(DefaultDiehardGasTable, func(table *vm.GasTable) {
+	table.ExtcodeHash = big.NewInt(700)
+	table.Balance = big.NewInt(700)
+	table.SLoad = big.NewInt(800)
+})
+
+// DefaultEIP2028GasTable is the EIP-1884 gas table with the transaction data
+// repriced by EIP-2028.
+var DefaultEIP2028GasTable = deriveGasTable(DefaultEIP1884GasTable, func(table *vm.GasTable) {
+	table.TxDataNonZero = big.NewInt(16)
+})
+
+// deriveGasTable returns a copy of the parent gas table with the costs changed
+// by reprice.
+func deriveGasTable(parent *vm.GasTable, reprice func(*vm.GasTable)) *vm.GasTable {
+	table := *parent
+	reprice(&table)
+	return &table
+}
+
+// gasTables are the default gas tables selectable by the type option of the
+// gastable fork feature.
+var gasTables = map[string]*vm.GasTable{
+	"homestead": DefaultHomeSteadGasTable,
+	"eip150":    DefaultGasRepriceGasTable,
+	"eip160":    DefaultDiehardGasTable,
+	"eip1884":   DefaultEIP1884GasTable,
+	"eip2028":   DefaultEIP2028GasTable,
 }
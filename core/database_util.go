@@ -19,6 +19,7 @@ package core
 import (
 	"bytes"
 	"encoding/binary"
+	"encoding/json"
 	"fmt"
 	"math/big"
 
@@ -46,6 +47,10 @@ var (
 	receiptsPrefix      = []byte("receipts-")
 	blockReceiptsPrefix = []byte("receipts-block-")
 
+	uncleLookupPrefix = []byte("uncle-lookup-")
+
+	configPrefix = []byte("ethereum-config-") // config prefix for the db
+
 	mipmapPre    = []byte("mipmap-log-bloom-")
 	MIPMapLevels = []uint64{1000000, 500000, 100000, 50000, 1000}
 
@@ -220,6 +225,25 @@ func GetTransaction(db ethdb.Database, hash common.Hash) (*types.Transaction, co
 	return &tx, meta.BlockHash, meta.BlockIndex, meta.Index
 }
 
+// GetUncleLookup retrieves the position of an uncle in the canonical chain:
+// the hash and number of the block including it, and its index in the block.
+// The returned hash is empty if the uncle isn't known to be included.
+func GetUncleLookup(db ethdb.Database, hash common.Hash) (common.Hash, uint64, uint64) {
+	data, _ := db.Get(append(uncleLookupPrefix, hash[:]...))
+	if len(data) == 0 {
+		return common.Hash{}, 0, 0
+	}
+	var meta struct {
+		BlockHash  common.Hash
+		BlockIndex uint64
+		Index      uint64
+	}
+	if err := rlp.DecodeBytes(data, &meta); err != nil {
+		return common.Hash{}, 0, 0
+	}
+	return meta.BlockHash, meta.BlockIndex, meta.Index
+}
+
 // GetReceipt returns a receipt by hash
 func GetReceipt(db ethdb.Database, txHash common.Hash) *types.Receipt {
 	data, _ := db.Get(append(receiptsPrefix, txHash[:]...))
@@ -272,7 +296,7 @@ func WriteHeadFastBlockHash(db ethdb.Database, hash common.Hash) error {
 }
 
 // WriteHeader serializes a block header into the database.
-func WriteHeader(db ethdb.Database, header *types.Header) error {
+func WriteHeader(db ethdb.Putter, header *types.Header) error {
 	data, err := rlp.EncodeToBytes(header)
 	if err != nil {
 		return err
@@ -287,7 +311,7 @@ func WriteHeader(db ethdb.Database, header *types.Header) error {
 }
 
 // WriteBody serializes the body of a block into the database.
-func WriteBody(db ethdb.Database, hash common.Hash, body *types.Body) error {
+func WriteBody(db ethdb.Putter, hash common.Hash, body *types.Body) error {
 	data, err := rlp.EncodeToBytes(body)
 	if err != nil {
 		return err
@@ -302,7 +326,7 @@ func WriteBody(db ethdb.Database, hash common.Hash, body *types.Body) error {
 }
 
 // WriteTd serializes the total difficulty of a block into the database.
-func WriteTd(db ethdb.Database, hash common.Hash, td *big.Int) error {
+func WriteTd(db ethdb.Putter, hash common.Hash, td *big.Int) error {
 	data, err := rlp.EncodeToBytes(td)
 	if err != nil {
 		return err
@@ -317,7 +341,7 @@ func WriteTd(db ethdb.Database, hash common.Hash, td *big.Int) error {
 }
 
 // WriteBlock serializes a block into the database, header and body separately.
-func WriteBlock(db ethdb.Database, block *types.Block) error {
+func WriteBlock(db ethdb.Putter, block *types.Block) error {
 	// Store the body first to retain database consistency
 	if err := WriteBody(db, block.Hash(), block.Body()); err != nil {
 		return err
@@ -333,7 +357,7 @@ func WriteBlock(db ethdb.Database, block *types.Block) error {
 // WriteBlockReceipts stores all the transaction receipts belonging to a block
 // as a single receipt slice. This is used during chain reorganisations for
 // rescheduling dropped transactions.
-func WriteBlockReceipts(db ethdb.Database, hash common.Hash, receipts types.Receipts) error {
+func WriteBlockReceipts(db ethdb.Putter, hash common.Hash, receipts types.Receipts) error {
 	// Convert the receipts into their storage form and serialize them
 	storageReceipts := make([]*types.ReceiptForStorage, len(receipts))
 	for i, receipt := range receipts {
@@ -395,6 +419,36 @@ func WriteTransactions(db ethdb.Database, block *types.Block) error {
 	return nil
 }
 
+// WriteUncleLookups stores the position within the blockchain of the uncles of
+// a specific block, indexed by their hash.
+func WriteUncleLookups(db ethdb.Database, block *types.Block) error {
+	batch := db.NewBatch()
+
+	for i, uncle := range block.Uncles() {
+		meta := struct {
+			BlockHash  common.Hash
+			BlockIndex uint64
+			Index      uint64
+		}{
+			BlockHash:  block.Hash(),
+			BlockIndex: block.NumberU64(),
+			Index:      uint64(i),
+		}
+		data, err := rlp.EncodeToBytes(meta)
+		if err != nil {
+			return err
+		}
+		if err := batch.Put(append(uncleLookupPrefix, uncle.Hash().Bytes()...), data); err != nil {
+			return err
+		}
+	}
+	if err := batch.Write(); err != nil {
+		glog.Fatalf("failed to store uncle lookups into database: %v", err)
+		return err
+	}
+	return nil
+}
+
 // WriteReceipts stores a batch of transaction receipts into the database.
 func WriteReceipts(db ethdb.Database, receipts types.Receipts) error {
 	batch := db.NewBatch()
@@ -457,6 +511,11 @@ func DeleteTransaction(db ethdb.Database, hash common.Hash) {
 	db.Delete(append(hash.Bytes(), txMetaSuffix...))
 }
 
+// DeleteUncleLookup removes the position of an uncle within the blockchain.
+func DeleteUncleLookup(db ethdb.Database, hash common.Hash) {
+	db.Delete(append(uncleLookupPrefix, hash.Bytes()...))
+}
+
 // DeleteReceipt removes all receipt data associated with a transaction hash.
 func DeleteReceipt(db ethdb.Database, hash common.Hash) {
 	db.Delete(append(receiptsPrefix, hash.Bytes()...))
@@ -532,3 +591,31 @@ func WriteBlockChainVersion(db ethdb.Database, vsn int) {
 	enc, _ := rlp.EncodeToBytes(uint(vsn))
 	db.Put([]byte("BlockchainVersion"), enc)
 }
+
+// GetChainConfig retrieves the chain config stored for the chain with the given
+// genesis hash, or nil if none is stored. Numbers in fork feature options are
+// decoded as json.Number, so that the config encodes back exactly as stored.
+func GetChainConfig(db ethdb.Database, genesis common.Hash) (*ChainConfig, error) {
+	data, _ := db.Get(append(configPrefix, genesis[:]...))
+	if len(data) == 0 {
+		return nil, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	config := new(ChainConfig)
+	if err := dec.Decode(config); err != nil {
+		return nil, fmt.Errorf("invalid chain config JSON: %x: %v", genesis, err)
+	}
+	return config, nil
+}
+
+// WriteChainConfig stores the chain config of the chain with the given genesis
+// hash, as JSON.
+func WriteChainConfig(db ethdb.Database, genesis common.Hash, config *ChainConfig) error {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+	return db.Put(append(configPrefix, genesis[:]...), data)
+}
@@ -405,6 +405,34 @@ func TestTransactionStorage(t *testing.T) {
 	}
 }
 
+// Tests that the positions of uncles can be stored, retrieved and deleted.
+func TestUncleLookupStorage(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+
+	uncles := []*types.Header{{Number: big.NewInt(312), Extra: []byte("uncle 1")}, {Number: big.NewInt(313), Extra: []byte("uncle 2")}}
+	block := types.NewBlock(&types.Header{Number: big.NewInt(314)}, nil, uncles, nil)
+
+	for i, uncle := range uncles {
+		if hash, _, _ := GetUncleLookup(db, uncle.Hash()); hash != (common.Hash{}) {
+			t.Fatalf("uncle #%d [%x]: non existent lookup returned: %x", i, uncle.Hash(), hash)
+		}
+	}
+	if err := WriteUncleLookups(db, block); err != nil {
+		t.Fatalf("failed to write uncle lookups: %v", err)
+	}
+	for i, uncle := range uncles {
+		if hash, number, index := GetUncleLookup(db, uncle.Hash()); hash != block.Hash() || number != block.NumberU64() || index != uint64(i) {
+			t.Fatalf("uncle #%d [%x]: positional metadata mismatch: have %x/%d/%d, want %x/%v/%v", i, uncle.Hash(), hash, number, index, block.Hash(), block.NumberU64(), i)
+		}
+	}
+	for i, uncle := range uncles {
+		DeleteUncleLookup(db, uncle.Hash())
+		if hash, _, _ := GetUncleLookup(db, uncle.Hash()); hash != (common.Hash{}) {
+			t.Fatalf("uncle #%d [%x]: deleted lookup returned: %x", i, uncle.Hash(), hash)
+		}
+	}
+}
+
 // Tests that receipts can be stored and retrieved.
 func TestReceiptStorage(t *testing.T) {
 	db, _ := ethdb.NewMemDatabase()
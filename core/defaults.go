@@ -8,27 +8,44 @@ import (
 var (
 	DefaultConfigMainnet *SufficientChainConfig
 	DefaultConfigMorden  *SufficientChainConfig
+	DefaultConfigDev     *SufficientChainConfig
+
+	// bundledChainNames maps the names a bundled chain configuration can be
+	// selected by with --chain to the asset holding it.
+	bundledChainNames = map[string]string{
+		"main":    "/core/config/mainnet.json",
+		"mainnet": "/core/config/mainnet.json",
+		"morden":  "/core/config/morden.json",
+		"testnet": "/core/config/morden.json",
+		"dev":     "/core/config/dev.json",
+	}
+	bundledChainConfigs = make(map[string]*SufficientChainConfig)
 )
 
 func init() {
-
-	var err error
-
-	mainnetJSONData, err := assets.DEFAULTS.Open("/core/config/mainnet.json")
-	if err != nil {
-		glog.Fatal("Error opening mainnet default JSON:", err)
-	}
-	mordenJSONData, err := assets.DEFAULTS.Open("/core/config/morden.json")
-	if err != nil {
-		glog.Fatal("Error opening morden default JSON:", err)
+	for _, asset := range bundledChainNames {
+		if bundledChainConfigs[asset] != nil {
+			continue
+		}
+		data, err := assets.DEFAULTS.Open(asset)
+		if err != nil {
+			glog.Fatalf("Error opening default chain config JSON %s: %v", asset, err)
+		}
+		config, err := parseExternalChainConfig(data)
+		if err != nil {
+			glog.Fatalf("Error parsing default chain config from JSON %s: %v", asset, err)
+		}
+		bundledChainConfigs[asset] = config
 	}
 
-	DefaultConfigMainnet, err = parseExternalChainConfig(mainnetJSONData)
-	if err != nil {
-		glog.Fatal("Error parsing mainnet defaults from JSON:", err)
-	}
-	DefaultConfigMorden, err = parseExternalChainConfig(mordenJSONData)
-	if err != nil {
-		glog.Fatal("Error parsing morden defaults from JSON:", err)
-	}
+	DefaultConfigMainnet = BundledChainConfig("mainnet")
+	DefaultConfigMorden = BundledChainConfig("morden")
+	DefaultConfigDev = BundledChainConfig("dev")
+}
+
+// BundledChainConfig returns the chain configuration shipped with the client
+// under the given name, or nil if there is none. The configuration is shared and
+// must not be modified.
+func BundledChainConfig(name string) *SufficientChainConfig {
+	return bundledChainConfigs[bundledChainNames[name]]
 }
@@ -0,0 +1,142 @@
+// Copyright 2015 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+)
+
+// DifficultyAlgorithm computes the difficulty of a block as the difficulty of
+// its parent adjusted to the time the block took to mine, plus an exponential
+// factor ("the bomb") doubling every ExpDiffPeriod blocks.
+type DifficultyAlgorithm struct {
+	// Adjust returns the parent difficulty adjusted for a block created at
+	// time, without the exponential factor.
+	Adjust func(time, parentTime uint64, parentDiff *big.Int) *big.Int
+
+	// BombPeriods returns the number of periods of the exponential factor of
+	// the block with the given number. Nil if the algorithm has no factor.
+	BombPeriods func(num *big.Int) *big.Int
+}
+
+// DifficultyAlgorithmFactory builds the difficulty algorithm configured by a
+// difficulty feature of the given fork, or fails if the feature's options are
+// invalid.
+type DifficultyAlgorithmFactory func(feat *ForkFeature, fork *Fork) (*DifficultyAlgorithm, error)
+
+var (
+	frontierDifficulty  = &DifficultyAlgorithm{Adjust: adjustDifficultyFrontier, BombPeriods: bombPeriods}
+	homesteadDifficulty = &DifficultyAlgorithm{Adjust: adjustDifficultyHomestead, BombPeriods: bombPeriods}
+	diffusedDifficulty  = &DifficultyAlgorithm{Adjust: adjustDifficultyHomestead}
+
+	difficultyAlgorithms = map[string]DifficultyAlgorithmFactory{
+		"frontier":  staticDifficulty(frontierDifficulty),
+		"homestead": staticDifficulty(homesteadDifficulty),
+		"diffused":  staticDifficulty(diffusedDifficulty),
+		"defused":   staticDifficulty(diffusedDifficulty),
+		"delayed":   delayedDifficulty,
+		"ecip1010":  ecip1010Difficulty,
+	}
+	difficultyAlgorithmsLock sync.RWMutex
+)
+
+// RegisterDifficultyAlgorithm makes a difficulty algorithm available to the
+// difficulty fork feature under the given 'type' option, replacing any
+// algorithm previously registered under that name.
+func RegisterDifficultyAlgorithm(name string, factory DifficultyAlgorithmFactory) {
+	difficultyAlgorithmsLock.Lock()
+	defer difficultyAlgorithmsLock.Unlock()
+
+	difficultyAlgorithms[name] = factory
+}
+
+// difficultyAlgorithm returns the algorithm selected by the 'type' option of a
+// difficulty feature of the given fork.
+func (o *ForkFeature) difficultyAlgorithm(fork *Fork) (*DifficultyAlgorithm, error) {
+	name, ok := o.GetString("type")
+	if !ok {
+		return nil, errors.New("type is not set")
+	}
+	difficultyAlgorithmsLock.RLock()
+	factory, ok := difficultyAlgorithms[name]
+	difficultyAlgorithmsLock.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unsupported type '%v'", name)
+	}
+	return factory(o, fork)
+}
+
+// staticDifficulty returns a factory of an algorithm without options.
+func staticDifficulty(algo *DifficultyAlgorithm) DifficultyAlgorithmFactory {
+	return func(*ForkFeature, *Fork) (*DifficultyAlgorithm, error) {
+		return algo, nil
+	}
+}
+
+// delayedDifficulty builds the Homestead algorithm with the exponential factor
+// computed as if the chain was 'delay' blocks shorter.
+func delayedDifficulty(feat *ForkFeature, fork *Fork) (*DifficultyAlgorithm, error) {
+	delay, ok := feat.GetBigInt("delay")
+	if !ok || delay.Sign() < 0 {
+		return nil, errors.New("delay is not set")
+	}
+	return &DifficultyAlgorithm{
+		Adjust: adjustDifficultyHomestead,
+		BombPeriods: func(num *big.Int) *big.Int {
+			return bombPeriods(new(big.Int).Sub(num, delay))
+		},
+	}, nil
+}
+
+// ecip1010Difficulty builds the ECIP-1010 algorithm, which pauses the
+// exponential factor for 'length' blocks from the fork and then continues it.
+func ecip1010Difficulty(feat *ForkFeature, fork *Fork) (*DifficultyAlgorithm, error) {
+	length, ok := feat.GetBigInt("length")
+	if !ok {
+		return nil, errors.New("length is not set")
+	}
+	return &DifficultyAlgorithm{
+		Adjust: adjustDifficultyHomestead,
+		BombPeriods: func(num *big.Int) *big.Int {
+			if num.Cmp(new(big.Int).Add(fork.Block, length)) < 0 {
+				return bombPeriods(fork.Block)
+			}
+			return bombPeriods(new(big.Int).Sub(num, length))
+		},
+	}, nil
+}
+
+// bombDefusal returns the number of periods of the exponential factor of the
+// given block number as modified by the bombdefuse feature: 'pause' freezes
+// the factor at its value at the fork's block and 'remove' drops it.
+func (o *ForkFeature) bombDefusal(fork *Fork, algo *DifficultyAlgorithm, num *big.Int) (*big.Int, error) {
+	name, ok := o.GetString("type")
+	if !ok {
+		return nil, errors.New("type is not set")
+	}
+	switch {
+	case name == "remove" || (name == "pause" && algo.BombPeriods == nil):
+		return nil, nil
+	case name == "pause":
+		return algo.BombPeriods(fork.Block), nil
+	default:
+		return nil, fmt.Errorf("unsupported type '%v'", name)
+	}
+}
@@ -0,0 +1,186 @@
+// Copyright 2015 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"testing"
+)
+
+// difficultyTestConfig returns a chain configuration switching from the
+// Frontier difficulty to a difficulty feature with the given options at
+// block 1,000,000.
+func difficultyTestConfig(options ChainFeatureConfigOptions) *ChainConfig {
+	return &ChainConfig{Forks: []*Fork{{
+		Name:     "Difficulty",
+		Block:    big.NewInt(1000000),
+		Features: []*ForkFeature{{ID: "difficulty", Options: options}},
+	}}}
+}
+
+func TestDifficultyAlgorithms(t *testing.T) {
+	var (
+		parentTime uint64 = 1500000000
+		time              = parentTime + 20
+		parentDiff        = big.NewInt(100000000000)
+	)
+	tests := []struct {
+		name   string
+		config *ChainConfig
+		number int64 // Number of the parent
+		want   *big.Int
+	}{
+		{"before fork", difficultyTestConfig(ChainFeatureConfigOptions{"type": "defused"}), 999998,
+			calcDifficultyFrontier(time, parentTime, big.NewInt(999998), parentDiff)},
+		{"frontier", difficultyTestConfig(ChainFeatureConfigOptions{"type": "frontier"}), 3000000,
+			calcDifficultyFrontier(time, parentTime, big.NewInt(3000000), parentDiff)},
+		{"homestead", difficultyTestConfig(ChainFeatureConfigOptions{"type": "homestead"}), 3000000,
+			calcDifficultyHomestead(time, parentTime, big.NewInt(3000000), parentDiff)},
+		{"defused", difficultyTestConfig(ChainFeatureConfigOptions{"type": "defused"}), 3000000,
+			calcDifficultyDiffused(time, parentTime, big.NewInt(3000000), parentDiff)},
+		{"delayed", difficultyTestConfig(ChainFeatureConfigOptions{"type": "delayed", "delay": 2000000}), 3000000,
+			calcDifficultyHomestead(time, parentTime, big.NewInt(1000000), parentDiff)},
+		{"delayed beyond chain", difficultyTestConfig(ChainFeatureConfigOptions{"type": "delayed", "delay": 5000000}), 3000000,
+			calcDifficultyDiffused(time, parentTime, big.NewInt(3000000), parentDiff)},
+		{"ecip1010 paused", difficultyTestConfig(ChainFeatureConfigOptions{"type": "ecip1010", "length": 2000000}), 2999998,
+			calcDifficultyDiehard(time, parentTime, parentDiff, big.NewInt(1000000))},
+		{"ecip1010 continued", difficultyTestConfig(ChainFeatureConfigOptions{"type": "ecip1010", "length": 2000000}), 2999999,
+			calcDifficultyExplosion(time, parentTime, big.NewInt(2999999), parentDiff, big.NewInt(1000000), big.NewInt(3000000))},
+	}
+	for _, tt := range tests {
+		if diff := CalcDifficulty(tt.config, time, parentTime, big.NewInt(tt.number), parentDiff); diff.Cmp(tt.want) != 0 {
+			t.Errorf("%s: difficulty %v, want %v", tt.name, diff, tt.want)
+		}
+	}
+	// The delayed bomb is smaller than the Homestead one, and the defused one absent.
+	number := big.NewInt(3000000)
+	homestead := calcDifficultyHomestead(time, parentTime, number, parentDiff)
+	delayed := CalcDifficulty(difficultyTestConfig(ChainFeatureConfigOptions{"type": "delayed", "delay": 2000000}), time, parentTime, number, parentDiff)
+	defused := calcDifficultyDiffused(time, parentTime, number, parentDiff)
+	if homestead.Cmp(delayed) <= 0 || delayed.Cmp(defused) <= 0 {
+		t.Errorf("difficulties homestead %v, delayed %v, defused %v aren't decreasing", homestead, delayed, defused)
+	}
+}
+
+func TestRegisterDifficultyAlgorithm(t *testing.T) {
+	RegisterDifficultyAlgorithm("test-constant", func(feat *ForkFeature, fork *Fork) (*DifficultyAlgorithm, error) {
+		diff, _ := feat.GetBigInt("difficulty")
+		return &DifficultyAlgorithm{Adjust: func(time, parentTime uint64, parentDiff *big.Int) *big.Int {
+			return new(big.Int).Set(diff)
+		}}, nil
+	})
+	config := difficultyTestConfig(ChainFeatureConfigOptions{"type": "test-constant", "difficulty": 12345})
+	if diff := CalcDifficulty(config, 1500000020, 1500000000, big.NewInt(1000000), big.NewInt(1000000000)); diff.Cmp(big.NewInt(12345)) != 0 {
+		t.Errorf("difficulty %v, want 12345", diff)
+	}
+}
+
+func TestDifficultyAlgorithmInvalid(t *testing.T) {
+	for _, options := range []ChainFeatureConfigOptions{
+		{},
+		{"type": "unknown"},
+		{"type": "delayed"},
+		{"type": "delayed", "delay": -1},
+		{"type": "ecip1010"},
+	} {
+		config := difficultyTestConfig(options)
+		if _, err := config.Forks[0].Features[0].difficultyAlgorithm(config.Forks[0]); err == nil {
+			t.Errorf("options %v: no error", options)
+		}
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("options %v: difficulty computed", options)
+				}
+			}()
+			CalcDifficulty(config, 1500000020, 1500000000, big.NewInt(1000000), big.NewInt(1000000000))
+		}()
+	}
+}
+
+// bombDefuseTestConfig returns a chain configuration using the difficulty
+// algorithm with the given options from genesis, and defusing its exponential
+// factor by the given type at block 3,000,000.
+func bombDefuseTestConfig(difficulty ChainFeatureConfigOptions, defusal string) *ChainConfig {
+	return &ChainConfig{Forks: []*Fork{
+		{
+			Name:     "Genesis",
+			Block:    big.NewInt(0),
+			Features: []*ForkFeature{{ID: "difficulty", Options: difficulty}},
+		},
+		{
+			Name:     "Defuse",
+			Block:    big.NewInt(3000000),
+			Features: []*ForkFeature{{ID: "bombdefuse", Options: ChainFeatureConfigOptions{"type": defusal}}},
+		},
+	}}
+}
+
+// Tests the exponential factor on both sides of the activation of its defusal.
+func TestBombDefusal(t *testing.T) {
+	var (
+		parentTime uint64 = 1500000000
+		time              = parentTime + 20
+		parentDiff        = big.NewInt(100000000000)
+		homestead         = ChainFeatureConfigOptions{"type": "homestead"}
+		ecip1010          = ChainFeatureConfigOptions{"type": "ecip1010", "length": 2000000}
+	)
+	adjusted := adjustDifficultyHomestead(time, parentTime, parentDiff)
+	bomb := func(periods int64) *big.Int {
+		return addDifficultyBomb(new(big.Int).Set(adjusted), big.NewInt(periods))
+	}
+	tests := []struct {
+		name   string
+		config *ChainConfig
+		number int64 // Number of the block
+		want   *big.Int
+	}{
+		{"paused, before activation", bombDefuseTestConfig(homestead, "pause"), 2999999, bomb(29)},
+		{"paused, at activation", bombDefuseTestConfig(homestead, "pause"), 3000000, bomb(30)},
+		{"paused, after activation", bombDefuseTestConfig(homestead, "pause"), 4500000, bomb(30)},
+		{"removed, before activation", bombDefuseTestConfig(homestead, "remove"), 2999999, bomb(29)},
+		{"removed, at activation", bombDefuseTestConfig(homestead, "remove"), 3000000, adjusted},
+		{"removed, after activation", bombDefuseTestConfig(homestead, "remove"), 4500000, adjusted},
+		{"ecip1010 paused, before activation", bombDefuseTestConfig(ecip1010, "pause"), 2999999, bomb(9)},
+		{"ecip1010 paused, after activation", bombDefuseTestConfig(ecip1010, "pause"), 4500000, bomb(10)},
+		{"ecip1010 removed, after activation", bombDefuseTestConfig(ecip1010, "remove"), 4500000, adjusted},
+		{"defused difficulty paused", bombDefuseTestConfig(ChainFeatureConfigOptions{"type": "defused"}, "pause"), 4500000, adjusted},
+	}
+	for _, tt := range tests {
+		parentNumber := big.NewInt(tt.number - 1)
+		if diff := CalcDifficulty(tt.config, time, parentTime, parentNumber, parentDiff); diff.Cmp(tt.want) != 0 {
+			t.Errorf("%s: difficulty %v, want %v", tt.name, diff, tt.want)
+		}
+	}
+}
+
+func TestBombDefusalInvalid(t *testing.T) {
+	for _, defusal := range []string{"", "unknown"} {
+		config := bombDefuseTestConfig(ChainFeatureConfigOptions{"type": "homestead"}, defusal)
+		if defusal == "" {
+			config.Forks[1].Features[0].Options = nil
+		}
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("type %q: difficulty computed", defusal)
+				}
+			}()
+			CalcDifficulty(config, 1500000020, 1500000000, big.NewInt(3000000), big.NewInt(1000000000))
+		}()
+	}
+}
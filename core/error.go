@@ -50,16 +50,30 @@ func IsParentErr(err error) bool {
 	return ok
 }
 
+var (
+	ErrTooManyUncles   = errors.New("too many uncles")
+	ErrDuplicateUncle  = errors.New("duplicate uncle")
+	ErrUncleIsAncestor = errors.New("uncle is an ancestor")
+	ErrUncleIsSibling  = errors.New("uncle is a sibling of the block")
+	ErrDanglingUncle   = errors.New("uncle's parent is not a recent ancestor")
+	ErrUnclesNotPoW    = errors.New("uncles are only allowed by proof-of-work")
+)
+
+// UncleErr is returned for a block including an invalid uncle. Reason is one
+// of the uncle errors above or the error validating the uncle's header.
 type UncleErr struct {
-	Message string
+	Number *big.Int    // Number of the including block
+	Hash   common.Hash // Hash of the including block
+	Index  int         // Index of the invalid uncle, -1 if not about a single uncle
+	Uncle  common.Hash // Hash of the invalid uncle
+	Reason error
 }
 
 func (err *UncleErr) Error() string {
-	return err.Message
-}
-
-func UncleError(format string, v ...interface{}) error {
-	return &UncleErr{Message: fmt.Sprintf(format, v...)}
+	if err.Index < 0 {
+		return fmt.Sprintf("block #%v [%x…]: invalid uncles: %v", err.Number, err.Hash[:4], err.Reason)
+	}
+	return fmt.Sprintf("block #%v [%x…]: invalid uncle %d [%x…]: %v", err.Number, err.Hash[:4], err.Index, err.Uncle[:4], err.Reason)
 }
 
 func IsUncleErr(err error) bool {
@@ -74,10 +88,14 @@ func (err validateError) Error() string {
 	return string(err)
 }
 
-// IsValidateError eturns whether err is a validation error.
+// IsValidateError eturns whether err is a validation error. Invalid uncles
+// are validation errors of the including block.
 func IsValidateError(err error) bool {
-	_, ok := err.(validateError)
-	return ok
+	switch err.(type) {
+	case validateError, *UncleErr:
+		return true
+	}
+	return false
 }
 
 type NonceErr struct {
@@ -185,3 +203,18 @@ func IsGasLimitErr(err error) bool {
 func (err *GasLimitErr) Error() string {
 	return fmt.Sprintf("GasLimit reached. Have %d gas, transaction requires %d", err.Have, err.Want)
 }
+
+// StateRootErr is returned when the state root resulting from the processing
+// of a block differs from the one in its header.
+type StateRootErr struct {
+	Header, Computed common.Hash
+}
+
+func (err *StateRootErr) Error() string {
+	return fmt.Sprintf("invalid merkle root: header=%x computed=%x", err.Header, err.Computed)
+}
+
+func IsStateRootErr(err error) bool {
+	_, ok := err.(*StateRootErr)
+	return ok
+}
@@ -0,0 +1,110 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/consensus"
+	"github.com/ellaism/go-ellaism/core/state"
+	"github.com/ellaism/go-ellaism/core/types"
+	"github.com/ellaism/go-ellaism/pow"
+)
+
+// Ethash is the proof-of-work consensus engine. Blocks are sealed and verified
+// with a proof of work, their difficulty adjusted and their miners rewarded as
+// configured by the chain configuration.
+//
+// Ethash implements consensus.PoW.
+type Ethash struct {
+	config *ChainConfig
+	pow    pow.PoW
+}
+
+// NewEthash returns a proof-of-work consensus engine for the given chain
+// configuration, sealing and verifying blocks with the given proof of work.
+func NewEthash(config *ChainConfig, pow pow.PoW) *Ethash {
+	return &Ethash{config: config, pow: pow}
+}
+
+// PoW returns the proof of work sealing and verifying the blocks.
+func (e *Ethash) PoW() pow.PoW {
+	return e.pow
+}
+
+// Author returns the coinbase of the header, the miner of the block.
+func (e *Ethash) Author(header *types.Header) (common.Address, error) {
+	return header.Coinbase, nil
+}
+
+// VerifyHeader checks the size of the extra-data of the header and its
+// difficulty.
+func (e *Ethash) VerifyHeader(chain consensus.ChainReader, header, parent *types.Header) error {
+	if len(header.Extra) > types.HeaderExtraMax {
+		return fmt.Errorf("extra data size %d exceeds limit of %d", len(header.Extra), types.HeaderExtraMax)
+	}
+	expd := e.CalcDifficulty(chain, header.Time.Uint64(), parent)
+	if expd.Cmp(header.Difficulty) != 0 {
+		return fmt.Errorf("Difficulty check failed for header %v != %v at %v", header.Difficulty, expd, header.Number)
+	}
+	return nil
+}
+
+// Prepare sets the difficulty of the header.
+func (e *Ethash) Prepare(chain consensus.ChainReader, header *types.Header) error {
+	parent := chain.GetHeader(header.ParentHash)
+	if parent == nil {
+		return consensus.ErrUnknownAncestor
+	}
+	header.Difficulty = e.CalcDifficulty(chain, header.Time.Uint64(), parent)
+	return nil
+}
+
+// CalcDifficulty returns the difficulty of the block by the difficulty
+// algorithm configured for it.
+func (e *Ethash) CalcDifficulty(chain consensus.ChainReader, time uint64, parent *types.Header) *big.Int {
+	return CalcDifficulty(e.config, time, parent.Time.Uint64(), parent.Number, parent.Difficulty)
+}
+
+// VerifySeal checks the proof of work of the header.
+func (e *Ethash) VerifySeal(chain consensus.ChainReader, header *types.Header) error {
+	if !e.pow.Verify(types.NewBlockWithHeader(header)) {
+		return &BlockNonceErr{header.Number, header.Hash(), header.Nonce.Uint64()}
+	}
+	return nil
+}
+
+// Finalize credits the block and uncle rewards to the state.
+func (e *Ethash) Finalize(chain consensus.ChainReader, header *types.Header, statedb *state.StateDB, uncles []*types.Header) {
+	AccumulateRewards(e.config, statedb, header, uncles)
+}
+
+// Seal searches for a nonce satisfying the difficulty of the block.
+func (e *Ethash) Seal(chain consensus.ChainReader, block *types.Block, stop <-chan struct{}) (*types.Block, error) {
+	nonce, mixDigest := e.pow.Search(block, stop, 0)
+	if nonce == 0 {
+		return nil, nil
+	}
+	return block.WithMiningResult(nonce, common.BytesToHash(mixDigest)), nil
+}
+
+// Hashrate returns the hash rate of the nonce searches.
+func (e *Ethash) Hashrate() int64 {
+	return e.pow.GetHashrate()
+}
@@ -29,6 +29,29 @@ import (
 // TxPreEvent is posted when a transaction enters the transaction pool.
 type TxPreEvent struct{ Tx *types.Transaction }
 
+// NewTxsEvent is sent on the transaction pool's feed when transactions enter
+// the pool.
+type NewTxsEvent struct{ Txs types.Transactions }
+
+// Reasons transactions leave the pool without being included in a block by it.
+const (
+	TxDropNonceUsed   = "nonceUsed"   // The nonce was used by a new head, by the transaction itself or another one
+	TxDropInvalidated = "invalidated" // The sender can't pay for the transaction anymore as of a new head
+	TxDropEvicted     = "evicted"     // The pool limits were exceeded
+	TxDropExpired     = "expired"     // The sender didn't have a transaction queued or promoted for the pool lifetime
+	TxDropRemoved     = "removed"     // The transaction was removed on request, e.g. to be resent
+)
+
+// DroppedTx is a transaction which left the pool, with the reason it left.
+type DroppedTx struct {
+	Tx     *types.Transaction
+	Reason string
+}
+
+// DroppedTxsEvent is sent on the transaction pool's feed when transactions
+// leave the pool.
+type DroppedTxsEvent struct{ Txs []DroppedTx }
+
 // TxPostEvent is posted when a transaction has been processed.
 type TxPostEvent struct{ Tx *types.Transaction }
 
@@ -43,15 +66,24 @@ type PendingStateEvent struct{}
 // NewBlockEvent is posted when a block has been imported.
 type NewBlockEvent struct{ Block *types.Block }
 
-// NewMinedBlockEvent is posted when a block has been imported.
-type NewMinedBlockEvent struct{ Block *types.Block }
+// NewMinedBlockEvent is posted when a block mined by this node has been
+// imported, with the rewards and transaction fees credited to its miner.
+type NewMinedBlockEvent struct {
+	Block  *types.Block
+	Reward *BlockReward // Rewards credited for the block, nil if unknown
+	Fees   *big.Int     // Transaction fees earned by the block's miner, nil if unknown
+}
 
 // RemovedTransactionEvent is posted when a reorg happens
 type RemovedTransactionEvent struct{ Txs types.Transactions }
 
-// RemovedLogEvent is posted when a reorg happens
+// RemovedLogsEvent is posted when a reorg happens
 type RemovedLogsEvent struct{ Logs vm.Logs }
 
+// LogsEvent is sent on the chain's feed with the logs of newly imported
+// canonical blocks.
+type LogsEvent struct{ Logs vm.Logs }
+
 // ChainSplit is posted when a new head is detected
 type ChainSplitEvent struct {
 	Block *types.Block
@@ -99,8 +131,16 @@ type ChainUncleEvent struct {
 	Block *types.Block
 }
 
+// ChainHeadEvent is posted when a block becomes the head of the canonical chain.
 type ChainHeadEvent struct{ Block *types.Block }
 
+// MissingBlockDataEvent is posted when the body or the receipts of a canonical
+// block are found missing from the database, so that they can be refetched.
+type MissingBlockDataEvent struct {
+	Header   *types.Header
+	Receipts bool // Whether the receipts are missing, otherwise the body
+}
+
 type GasPriceChanged struct{ Price *big.Int }
 
 // Mining operation events
@@ -17,29 +17,65 @@
 package core
 
 import (
+	"errors"
 	"fmt"
 	"math/big"
 
 	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/core/state"
 	"github.com/ellaism/go-ellaism/core/vm"
 	"github.com/ellaism/go-ellaism/crypto"
 )
 
 var (
 	callCreateDepthMax = 1024 // limit call/create stack
-	errCallCreateDepth = fmt.Errorf("Max call depth exceeded (%d)", callCreateDepthMax)
+	errCallCreateDepth = callDepthError{}
+
+	errContractAddressCollision = errors.New("contract address collision")
 )
 
+// callDepthError aborts the frames which would exceed the call depth limit.
+type callDepthError struct{}
+
+func (callDepthError) Error() string {
+	return fmt.Sprintf("Max call depth exceeded (%d)", callCreateDepthMax)
+}
+
+// Limit implements vm.LimitError.
+func (callDepthError) Limit() string { return "callDepth" }
+
+// limitReporter is implemented by the environments recording the frames aborted
+// by the limits of the EVM.
+type limitReporter interface {
+	reportLimit(err vm.LimitError, contract common.Address)
+}
+
+// reportLimit notifies the environment, if it records them, that a frame of the
+// given contract was aborted with err, if err is a limit error.
+func reportLimit(env vm.Environment, err error, contract common.Address) {
+	if limitErr, ok := err.(vm.LimitError); ok {
+		if reporter, ok := env.(limitReporter); ok {
+			reporter.reportLimit(limitErr, contract)
+		}
+	}
+}
+
 // Call executes within the given contract
 func Call(env vm.Environment, caller vm.ContractRef, addr common.Address, input []byte, gas, gasPrice, value *big.Int) (ret []byte, err error) {
-	ret, _, err = exec(env, caller, &addr, &addr, env.Db().GetCodeHash(addr), input, env.Db().GetCode(addr), gas, gasPrice, value)
+	if tracer := env.Tracer(); tracer != nil {
+		defer traceCall(tracer, vm.CALL, caller.Address(), addr, input, gas, value)(&ret, &err)
+	}
+	ret, _, err = exec(env, caller, &addr, &addr, env.Db().GetCodeHash(addr), input, env.Db().GetCode(addr), gas, gasPrice, value, nil)
 	return ret, err
 }
 
 // CallCode executes the given address' code as the given contract address
 func CallCode(env vm.Environment, caller vm.ContractRef, addr common.Address, input []byte, gas, gasPrice, value *big.Int) (ret []byte, err error) {
 	callerAddr := caller.Address()
-	ret, _, err = exec(env, caller, &callerAddr, &addr, env.Db().GetCodeHash(addr), input, env.Db().GetCode(addr), gas, gasPrice, value)
+	if tracer := env.Tracer(); tracer != nil {
+		defer traceCall(tracer, vm.CALLCODE, callerAddr, addr, input, gas, value)(&ret, &err)
+	}
+	ret, _, err = exec(env, caller, &callerAddr, &addr, env.Db().GetCodeHash(addr), input, env.Db().GetCode(addr), gas, gasPrice, value, nil)
 	return ret, err
 }
 
@@ -48,28 +84,55 @@ func DelegateCall(env vm.Environment, caller vm.ContractRef, addr common.Address
 	callerAddr := caller.Address()
 	originAddr := env.Origin()
 	callerValue := caller.Value()
+	if tracer := env.Tracer(); tracer != nil {
+		defer traceCall(tracer, vm.DELEGATECALL, callerAddr, addr, input, gas, callerValue)(&ret, &err)
+	}
 	ret, _, err = execDelegateCall(env, caller, &originAddr, &callerAddr, &addr, env.Db().GetCodeHash(addr), input, env.Db().GetCode(addr), gas, gasPrice, callerValue)
 	return ret, err
 }
 
 // Create creates a new contract with the given code
 func Create(env vm.Environment, caller vm.ContractRef, code []byte, gas, gasPrice, value *big.Int) (ret []byte, address common.Address, err error) {
-	ret, address, err = exec(env, caller, nil, nil, crypto.Keccak256Hash(code), nil, code, gas, gasPrice, value)
+	if tracer := env.Tracer(); tracer != nil {
+		contractAddr := crypto.CreateAddress(caller.Address(), env.Db().GetNonce(caller.Address()))
+		defer traceCall(tracer, vm.CREATE, caller.Address(), contractAddr, code, gas, value)(&ret, &err)
+	}
+	ret, address, err = exec(env, caller, nil, nil, crypto.Keccak256Hash(code), nil, code, gas, gasPrice, value, nil)
 	// Here we get an error if we run into maximum stack depth,
 	// See: https://github.com/ethereum/yellowpaper/pull/131
-	// and YP definitions for CREATE instruction
-	if err != nil {
+	// and YP definitions for CREATE instruction. The output of reverted
+	// creations is kept as it may hold the revert reason.
+	if err != nil && err != vm.ErrExecutionReverted {
 		return nil, address, err
 	}
 	return ret, address, err
 }
 
-func exec(env vm.Environment, caller vm.ContractRef, address, codeAddr *common.Address, codeHash common.Hash, input, code []byte, gas, gasPrice, value *big.Int) (ret []byte, addr common.Address, err error) {
+// Create2 creates a new contract with the given code at an address derived
+// from the caller, the salt and the code (EIP-1014)
+func Create2(env vm.Environment, caller vm.ContractRef, code []byte, gas, gasPrice, value, salt *big.Int) (ret []byte, address common.Address, err error) {
+	codeHash := crypto.Keccak256Hash(code)
+	if tracer := env.Tracer(); tracer != nil {
+		contractAddr := crypto.CreateAddress2(caller.Address(), common.BigToHash(salt), codeHash.Bytes())
+		defer traceCall(tracer, vm.CREATE2, caller.Address(), contractAddr, code, gas, value)(&ret, &err)
+	}
+	ret, address, err = exec(env, caller, nil, nil, codeHash, nil, code, gas, gasPrice, value, salt)
+	if err != nil && err != vm.ErrExecutionReverted {
+		return nil, address, err
+	}
+	return ret, address, err
+}
+
+// exec executes the given code in the context of the given address, or
+// creates a new contract if the address is nil. Contracts are created at an
+// address derived from the caller's nonce, or from the salt if one is given.
+func exec(env vm.Environment, caller vm.ContractRef, address, codeAddr *common.Address, codeHash common.Hash, input, code []byte, gas, gasPrice, value, salt *big.Int) (ret []byte, addr common.Address, err error) {
 	evm := env.Vm()
 	// Depth check execution. Fail if we're trying to execute above the
 	// limit.
 	if env.Depth() > callCreateDepthMax {
 		caller.ReturnGas(gas, gasPrice)
+		reportLimit(env, errCallCreateDepth, caller.Address())
 
 		return nil, common.Address{}, errCallCreateDepth
 	}
@@ -85,7 +148,16 @@ func exec(env vm.Environment, caller vm.ContractRef, address, codeAddr *common.A
 		// Create a new account on the state
 		nonce := env.Db().GetNonce(caller.Address())
 		env.Db().SetNonce(caller.Address(), nonce+1)
-		addr = crypto.CreateAddress(caller.Address(), nonce)
+		if salt == nil {
+			addr = crypto.CreateAddress(caller.Address(), nonce)
+		} else {
+			addr = crypto.CreateAddress2(caller.Address(), common.BigToHash(salt), codeHash.Bytes())
+			// Salted addresses can be reused, so make sure no contract lives
+			// at the address yet. The gas is consumed, not returned.
+			if env.Db().GetNonce(addr) != state.StartingNonce || env.Db().GetCodeSize(addr) != 0 {
+				return nil, common.Address{}, errContractAddressCollision
+			}
+		}
 		address = &addr
 		createAccount = true
 	}
@@ -97,8 +169,18 @@ func exec(env vm.Environment, caller vm.ContractRef, address, codeAddr *common.A
 	)
 	if createAccount {
 		to = env.Db().CreateAccount(*address)
+		if env.RuleSet().IsEIP161(env.BlockNumber()) {
+			// EIP-161: contracts start with their nonce incremented
+			env.Db().SetNonce(*address, env.Db().GetNonce(*address)+1)
+		}
 	} else {
 		if !env.Db().Exist(*address) {
+			// EIP-161: calls without value don't bring accounts into existence
+			if vm.ActivePrecompiled(env.RuleSet(), env.BlockNumber(), *address) == nil && value.Sign() == 0 && env.RuleSet().IsEIP161(env.BlockNumber()) {
+				caller.ReturnGas(gas, gasPrice)
+
+				return nil, common.Address{}, nil
+			}
 			to = env.Db().CreateAccount(*address)
 		} else {
 			to = env.Db().GetAccount(*address)
@@ -114,14 +196,20 @@ func exec(env vm.Environment, caller vm.ContractRef, address, codeAddr *common.A
 	defer contract.Finalise()
 
 	ret, err = evm.Run(contract, input)
+	if err != nil {
+		reportLimit(env, err, *address)
+	}
 	// if the contract creation ran successfully and no errors were returned
 	// calculate the gas required to store the code. If the code could not
 	// be stored due to not enough gas set an error and let it be handled
 	// by the error checking condition below.
+	if max := env.RuleSet().MaxCodeSize(env.BlockNumber()); err == nil && createAccount && max > 0 && len(ret) > max {
+		err = vm.MaxCodeSizeError
+	}
 	if err == nil && createAccount {
 		dataGas := big.NewInt(int64(len(ret)))
 		// create data gas
-		dataGas.Mul(dataGas, big.NewInt(200))
+		dataGas.Mul(dataGas, env.RuleSet().GasTable(env.BlockNumber()).CreateData)
 		if contract.UseGas(dataGas) {
 			env.Db().SetCode(*address, ret)
 		} else {
@@ -130,11 +218,13 @@ func exec(env vm.Environment, caller vm.ContractRef, address, codeAddr *common.A
 	}
 
 	// When an error was returned by the EVM or when setting the creation code
-	// above we revert to the snapshot and consume any gas remaining. Additionally
-	// when we're in homestead this also counts for code storage gas errors.
+	// above we revert to the snapshot and consume any gas remaining, unless the
+	// execution was reverted on purpose. Additionally when we're in homestead
+	// this also counts for code storage gas errors.
 	if err != nil && (env.RuleSet().IsHomestead(env.BlockNumber()) || err != vm.CodeStoreOutOfGasError) {
-		contract.UseGas(contract.Gas)
-
+		if err != vm.ErrExecutionReverted {
+			contract.UseGas(contract.Gas)
+		}
 		env.RevertToSnapshot(snapshotPreTransfer)
 	}
 
@@ -147,6 +237,7 @@ func execDelegateCall(env vm.Environment, caller vm.ContractRef, originAddr, toA
 	// limit.
 	if env.Depth() > callCreateDepthMax {
 		caller.ReturnGas(gas, gasPrice)
+		reportLimit(env, errCallCreateDepth, caller.Address())
 		return nil, common.Address{}, errCallCreateDepth
 	}
 
@@ -166,14 +257,29 @@ func execDelegateCall(env vm.Environment, caller vm.ContractRef, originAddr, toA
 
 	ret, err = evm.Run(contract, input)
 	if err != nil {
-		contract.UseGas(contract.Gas)
-
+		reportLimit(env, err, *toAddr)
+		if err != vm.ErrExecutionReverted {
+			contract.UseGas(contract.Gas)
+		}
 		env.RevertToSnapshot(snapshot)
 	}
 
 	return ret, addr, err
 }
 
+// traceCall notifies the tracer that a new call frame is entered and returns
+// the function reporting the frame's result once it has been executed. The
+// gas passed to the EVM is reduced in place, so whatever is left of it when
+// the returned function runs is the unused part.
+func traceCall(tracer vm.Tracer, typ vm.OpCode, from, to common.Address, input []byte, gas, value *big.Int) func(*[]byte, *error) {
+	initialGas := new(big.Int).Set(gas)
+	tracer.CaptureEnter(typ, from, to, input, gas, value)
+
+	return func(ret *[]byte, err *error) {
+		tracer.CaptureExit(*ret, new(big.Int).Sub(initialGas, gas), *err)
+	}
+}
+
 // generic transfer method
 func Transfer(from, to vm.Account, amount *big.Int) {
 	from.SubBalance(amount)
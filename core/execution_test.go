@@ -0,0 +1,437 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/core/state"
+	"github.com/ellaism/go-ellaism/core/types"
+	"github.com/ellaism/go-ellaism/core/vm"
+	"github.com/ellaism/go-ellaism/crypto"
+	"github.com/ellaism/go-ellaism/ethdb"
+)
+
+// forkTestContract is the address the code run by runWithFeatures lives at.
+var forkTestContract = common.Address{0xcc}
+
+// forkTestEnv is a state holding a funded account, at a block either before or
+// after the fork enabling the features under test.
+type forkTestEnv struct {
+	t       *testing.T
+	key     *ecdsa.PrivateKey
+	config  *ChainConfig
+	statedb *state.StateDB
+	header  *types.Header
+	nonce   uint64
+}
+
+// newForkTestEnv creates a test environment at the given block of a chain
+// enabling the given features from block 2.
+func newForkTestEnv(t *testing.T, features []*ForkFeature, number int64) *forkTestEnv {
+	key, _ := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	config := MakeDiehardChainConfig()
+	config.Forks = append(config.Forks, &Fork{Name: "Test", Block: big.NewInt(2), Features: features})
+
+	db, _ := ethdb.NewMemDatabase()
+	genesis := WriteGenesisBlockForTesting(db, GenesisAccount{crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1e18)})
+	statedb, _ := state.New(genesis.Root(), db)
+
+	return &forkTestEnv{
+		t:       t,
+		key:     key,
+		config:  config,
+		statedb: statedb,
+		header:  &types.Header{Number: big.NewInt(number), GasLimit: big.NewInt(4712388), Difficulty: big.NewInt(1), Time: big.NewInt(0)},
+	}
+}
+
+// tx signs the next transaction of the funded account, creating a contract if
+// to is nil.
+func (env *forkTestEnv) tx(to *common.Address, value *big.Int, gas int64, data []byte) *types.Transaction {
+	var tx *types.Transaction
+	if to == nil {
+		tx = types.NewContractCreation(env.nonce, value, big.NewInt(gas), new(big.Int), data)
+	} else {
+		tx = types.NewTransaction(env.nonce, *to, value, big.NewInt(gas), new(big.Int), data)
+	}
+	tx, err := tx.WithSigner(env.config.GetSigner(env.header.Number)).SignECDSA(env.key)
+	if err != nil {
+		env.t.Fatal(err)
+	}
+	env.nonce++
+	return tx
+}
+
+// apply applies the next transaction of the funded account to the state.
+func (env *forkTestEnv) apply(to *common.Address, value *big.Int, gas int64, data []byte) *types.Receipt {
+	receipt, _, _, err := ApplyTransaction(env.config, nil, new(GasPool).AddGas(env.header.GasLimit), env.statedb, env.header, env.tx(to, value, gas, data), new(big.Int))
+	if err != nil {
+		env.t.Fatalf("block %v: failed to apply transaction: %v", env.header.Number, err)
+	}
+	return receipt
+}
+
+// runWithFeatures calls the given code with the given value at the given block
+// of a chain enabling the features from block 2.
+func runWithFeatures(t *testing.T, features []*ForkFeature, number int64, code []byte, value *big.Int) (*state.StateDB, *types.Receipt) {
+	env := newForkTestEnv(t, features, number)
+	env.statedb.SetCode(forkTestContract, code)
+	return env.statedb, env.apply(&forkTestContract, value, 1000000, nil)
+}
+
+// initCode returns contract creation code deploying size zero bytes.
+func initCode(size byte) []byte {
+	return []byte{byte(vm.PUSH1), size, byte(vm.PUSH1), 0, byte(vm.RETURN)}
+}
+
+// Tests the state clearing and code size rules before and after the fork
+// enabling them.
+func TestSpuriousDragonRules(t *testing.T) {
+	features := []*ForkFeature{
+		{ID: "eip161"},
+		{ID: "eip170", Options: ChainFeatureConfigOptions{"maxCodeSize": 16}},
+	}
+	for _, number := range []int64{1, 2} {
+		env := newForkTestEnv(t, features, number)
+		empty, missing := common.Address{0xaa}, common.Address{0xbb}
+		env.statedb.CreateAccount(empty)
+		env.statedb.IntermediateRoot(false)
+		eip161 := number >= 2
+
+		// Zero value transfers touch the empty account and don't create the missing one
+		env.apply(&empty, new(big.Int), 1000000, nil)
+		env.apply(&missing, new(big.Int), 1000000, nil)
+		if env.statedb.Exist(empty) == eip161 {
+			t.Errorf("block %d: touched empty account exists: %v", number, env.statedb.Exist(empty))
+		}
+		if env.statedb.Exist(missing) == eip161 {
+			t.Errorf("block %d: zero value call target exists: %v", number, env.statedb.Exist(missing))
+		}
+
+		// Contracts start with a nonce of one and their code size is limited
+		small := env.apply(nil, new(big.Int), 1000000, initCode(16))
+		if nonce := env.statedb.GetNonce(small.ContractAddress); eip161 && nonce != 1 || !eip161 && nonce != 0 {
+			t.Errorf("block %d: contract nonce mismatch: have %d", number, nonce)
+		}
+		large := env.apply(nil, new(big.Int), 1000000, initCode(17))
+		if size := env.statedb.GetCodeSize(large.ContractAddress); eip161 && size != 0 || !eip161 && size != 17 {
+			t.Errorf("block %d: oversized contract code size mismatch: have %d", number, size)
+		}
+		if eip161 && large.GasUsed.Cmp(big.NewInt(1000000)) != 0 {
+			t.Errorf("block %d: oversized contract creation gas mismatch: have %v, want all gas", number, large.GasUsed)
+		}
+	}
+}
+
+// create2Code stores the given init code in memory, then CREATE2s it twice
+// with the same salt, storing the resulting addresses in slots 0 and 1.
+func create2Code(init []byte) []byte {
+	code := append([]byte{byte(vm.PUSH5)}, init...)
+	code = append(code, byte(vm.PUSH1), 0, byte(vm.MSTORE))
+	for slot := byte(0); slot < 2; slot++ {
+		code = append(code,
+			byte(vm.PUSH1), 0x2a, byte(vm.PUSH1), byte(len(init)), byte(vm.PUSH1), byte(32-len(init)), byte(vm.PUSH1), 0,
+			byte(vm.CREATE2), byte(vm.PUSH1), slot, byte(vm.SSTORE))
+	}
+	return code
+}
+
+// extCodeHashCode stores the code hashes of the given accounts in consecutive
+// slots.
+func extCodeHashCode(accounts ...common.Address) []byte {
+	var code []byte
+	for slot, account := range accounts {
+		code = append(code, byte(vm.PUSH20))
+		code = append(code, account.Bytes()...)
+		code = append(code, byte(vm.EXTCODEHASH), byte(vm.PUSH1), byte(slot), byte(vm.SSTORE))
+	}
+	return code
+}
+
+// Tests that the opcodes introduced by fork features are invalid before the
+// fork and store the expected values after it.
+func TestForkOpcodes(t *testing.T) {
+	key, _ := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	sender, missing := crypto.PubkeyToAddress(key.PublicKey), common.Address{0xee}
+	create2, created := create2Code(initCode(1)), crypto.CreateAddress2(forkTestContract, common.BigToHash(big.NewInt(0x2a)), crypto.Keccak256(initCode(1)))
+	extCodeHash := extCodeHashCode(forkTestContract, sender, missing)
+
+	tests := []struct {
+		name     string
+		features []*ForkFeature
+		code     []byte
+		value    int64
+		storage  []common.Hash              // Expected storage after the fork
+		check    func(*state.StateDB) error // Additional check after the fork
+	}{
+		{
+			// CREATE2 deploys contracts at their salted address and fails on
+			// address collisions
+			name:     "CREATE2",
+			features: []*ForkFeature{{ID: "eip1014"}},
+			code:     create2,
+			storage:  []common.Hash{created.Hash(), {}},
+			check: func(statedb *state.StateDB) error {
+				if size := statedb.GetCodeSize(created); size != 1 {
+					return fmt.Errorf("created contract code size mismatch: have %d, want 1", size)
+				}
+				return nil
+			},
+		},
+		{
+			// EXTCODEHASH reports the code hash of existing accounts and zero
+			// for missing ones
+			name:     "EXTCODEHASH",
+			features: []*ForkFeature{{ID: "eip1052"}},
+			code:     extCodeHash,
+			storage:  []common.Hash{crypto.Keccak256Hash(extCodeHash), crypto.Keccak256Hash(nil), {}},
+		},
+		{
+			name: "CHAINID and SELFBALANCE",
+			features: []*ForkFeature{
+				{ID: "eip1344"},
+				{ID: "eip1884"},
+				{ID: "gastable", Options: ChainFeatureConfigOptions{"type": "eip1884"}},
+			},
+			code:    []byte{byte(vm.CHAINID), byte(vm.PUSH1), 0, byte(vm.SSTORE), byte(vm.SELFBALANCE), byte(vm.PUSH1), 1, byte(vm.SSTORE)},
+			value:   8,
+			storage: []common.Hash{common.BigToHash(big.NewInt(63)), common.BigToHash(big.NewInt(8))},
+		},
+	}
+	for _, test := range tests {
+		for _, number := range []int64{1, 2} {
+			statedb, receipt := runWithFeatures(t, test.features, number, test.code, big.NewInt(test.value))
+			if number < 2 {
+				if receipt.GasUsed.Cmp(big.NewInt(1000000)) != 0 {
+					t.Errorf("%s: available before the fork", test.name)
+				}
+				continue
+			}
+			for slot, want := range test.storage {
+				if have := statedb.GetState(forkTestContract, common.BigToHash(big.NewInt(int64(slot)))); have != want {
+					t.Errorf("%s: slot %d mismatch: have %x, want %x", test.name, slot, have, want)
+				}
+			}
+			if test.check != nil {
+				if err := test.check(statedb); err != nil {
+					t.Errorf("%s: %v", test.name, err)
+				}
+			}
+		}
+	}
+}
+
+// Tests that the EIP-1884 gas table is scheduled by the gastable feature.
+func TestEIP1884GasTableFeature(t *testing.T) {
+	env := newForkTestEnv(t, []*ForkFeature{{ID: "gastable", Options: ChainFeatureConfigOptions{"type": "eip1884"}}}, 2)
+	if have := env.config.GasTable(env.header.Number); have != DefaultEIP1884GasTable || have.SLoad.Cmp(big.NewInt(800)) != 0 {
+		t.Errorf("gas table mismatch: have %+v", have)
+	}
+}
+
+// Tests that the step costs of the operations are priced by the gas table.
+func TestStepGasRepricing(t *testing.T) {
+	features := []*ForkFeature{{ID: "gastable", Options: ChainFeatureConfigOptions{"type": "eip160", "fastestStep": 4}}}
+	code := []byte{byte(vm.PUSH1), 1, byte(vm.PUSH1), 1, byte(vm.ADD), byte(vm.POP)}
+
+	_, before := runWithFeatures(t, features, 1, code, new(big.Int))
+	_, after := runWithFeatures(t, features, 2, code, new(big.Int))
+	if diff := new(big.Int).Sub(after.GasUsed, before.GasUsed); diff.Cmp(big.NewInt(3)) != 0 {
+		t.Errorf("gas used difference mismatch: have %v, want 3", diff)
+	}
+}
+
+// Tests that the BLAKE2 compression function contract and the EIP-2028
+// transaction data pricing are only applied from the fork enabling them.
+func TestIstanbulPrecompiles(t *testing.T) {
+	features := []*ForkFeature{
+		{ID: "eip152"},
+		{ID: "gastable", Options: ChainFeatureConfigOptions{"type": "eip2028"}},
+	}
+
+	// The 12 rounds BLAKE2b compression of "abc", from EIP-152
+	input := make([]byte, 213)
+	input[3] = 12
+	copy(input[4:], common.FromHex("48c9bdf267e6096a3ba7ca8485ae67bb2bf894fe72f36e3cf1361d5f3af54fa5d182e6ad7f520e511f6c3e2b8c68059b6bbd41fbabd9831f79217e1319cde05b"))
+	copy(input[68:], "abc")
+	input[196], input[212] = 3, 1
+
+	blake2f := common.BytesToAddress([]byte{9})
+	gasTables := make(map[int64]*vm.GasTable)
+	for _, number := range []int64{1, 2} {
+		env := newForkTestEnv(t, features, number)
+		receipt := env.apply(&blake2f, new(big.Int), 100000, input)
+
+		gasTables[number] = env.config.GasTable(env.header.Number)
+		want := IntrinsicGas(input, nil, false, true, gasTables[number])
+		if number >= 2 {
+			want.Add(want, big.NewInt(12))
+		}
+		if receipt.GasUsed.Cmp(want) != 0 {
+			t.Errorf("block %d: gas used mismatch: have %v, want %v", number, receipt.GasUsed, want)
+		}
+	}
+	if before, after := IntrinsicGas(input, nil, false, true, gasTables[1]), IntrinsicGas(input, nil, false, true, gasTables[2]); before.Cmp(after) <= 0 {
+		t.Errorf("transaction data not repriced: have %v before and %v after the fork", before, after)
+	}
+}
+
+// Tests that the REVERT opcode reverts the state changes of the execution
+// without consuming the remaining gas, and returns its output to the caller.
+func TestRevert(t *testing.T) {
+	reverter, caller := common.Address{0xaa}, common.Address{0xbb}
+	features := []*ForkFeature{{ID: "eip140"}}
+
+	// Store a value, then revert with 32 bytes of output
+	reverterCode := []byte{
+		byte(vm.PUSH1), 1, byte(vm.PUSH1), 2, byte(vm.SSTORE),
+		byte(vm.PUSH1), 0x2a, byte(vm.PUSH1), 0, byte(vm.MSTORE),
+		byte(vm.PUSH1), 32, byte(vm.PUSH1), 0, byte(vm.REVERT),
+	}
+	// Call the reverter, storing the output in slot 0 and the result in slot 1
+	callerCode := []byte{
+		byte(vm.PUSH1), 32, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0,
+		byte(vm.PUSH20)}
+	callerCode = append(callerCode, reverter.Bytes()...)
+	callerCode = append(callerCode,
+		byte(vm.PUSH3), 0x01, 0x86, 0xa0, byte(vm.CALL),
+		byte(vm.PUSH1), 1, byte(vm.SSTORE),
+		byte(vm.PUSH1), 0, byte(vm.MLOAD), byte(vm.PUSH1), 0, byte(vm.SSTORE),
+	)
+
+	for _, number := range []int64{1, 2} {
+		env := newForkTestEnv(t, features, number)
+		env.statedb.SetCode(reverter, reverterCode)
+		env.statedb.SetCode(caller, callerCode)
+
+		tx := env.tx(&reverter, new(big.Int), 1000000, nil)
+		st := NewStateTransition(NewEnv(env.statedb, env.config, nil, tx, env.header), tx, new(GasPool).AddGas(env.header.GasLimit))
+		ret, _, gas, err := st.TransitionDb()
+		if err != nil {
+			t.Fatalf("block %d: failed to apply transaction: %v", number, err)
+		}
+		if number < 2 {
+			if gas.Cmp(big.NewInt(1000000)) != 0 || st.VMErr() == vm.ErrExecutionReverted {
+				t.Errorf("block %d: REVERT available before the fork", number)
+			}
+			continue
+		}
+		if st.VMErr() != vm.ErrExecutionReverted {
+			t.Errorf("vm error mismatch: have %v, want %v", st.VMErr(), vm.ErrExecutionReverted)
+		}
+		if want := common.LeftPadBytes([]byte{0x2a}, 32); !bytes.Equal(ret, want) {
+			t.Errorf("output mismatch: have %x, want %x", ret, want)
+		}
+		if gas.Cmp(big.NewInt(100000)) >= 0 {
+			t.Errorf("remaining gas consumed: used %v", gas)
+		}
+		if have := env.statedb.GetState(reverter, common.Hash{31: 2}); have != (common.Hash{}) {
+			t.Errorf("state change not reverted: have %x", have)
+		}
+
+		// Reverted calls fail, but their output is still copied to memory
+		env.apply(&caller, new(big.Int), 1000000, nil)
+		if have := env.statedb.GetState(caller, common.Hash{}); have != (common.Hash{31: 0x2a}) {
+			t.Errorf("call output mismatch: have %x", have)
+		}
+		if have := env.statedb.GetState(caller, common.Hash{31: 1}); have != (common.Hash{}) {
+			t.Errorf("reverted call succeeded")
+		}
+	}
+}
+
+// Tests that the frames aborted by the call depth and stack limits are counted
+// and flagged in the call traces.
+func TestLimitAborts(t *testing.T) {
+	key, _ := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	addr := crypto.PubkeyToAddress(key.PublicKey)
+	overflower, caller, recurser := common.Address{0xaa}, common.Address{0xbb}, common.Address{0xcc}
+
+	// Homestead gas rules pass all the requested gas on, so that the call depth
+	// limit is reachable
+	config := &ChainConfig{}
+
+	// Push one more item than the stack holds
+	overflowerCode := bytes.Repeat([]byte{byte(vm.PUSH1), 0}, 1025)
+	// Call the overflower
+	callerCode := []byte{
+		byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0,
+		byte(vm.PUSH20)}
+	callerCode = append(callerCode, overflower.Bytes()...)
+	callerCode = append(callerCode, byte(vm.PUSH3), 0x0f, 0x42, 0x40, byte(vm.CALL))
+	// Call itself with all but 100 of its gas
+	recurserCode := []byte{
+		byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0,
+		byte(vm.ADDRESS), byte(vm.PUSH1), 100, byte(vm.GAS), byte(vm.SUB), byte(vm.CALL),
+	}
+
+	db, _ := ethdb.NewMemDatabase()
+	genesis := WriteGenesisBlockForTesting(db, GenesisAccount{addr, big.NewInt(1e18)})
+	statedb, _ := state.New(genesis.Root(), db)
+	statedb.SetCode(overflower, overflowerCode)
+	statedb.SetCode(caller, callerCode)
+	statedb.SetCode(recurser, recurserCode)
+
+	header := &types.Header{Number: big.NewInt(1), GasLimit: big.NewInt(4712388), Difficulty: big.NewInt(1), Time: big.NewInt(0)}
+	transaction := func(nonce uint64, to common.Address) *types.Transaction {
+		tx, err := types.NewTransaction(nonce, to, new(big.Int), big.NewInt(2000000), new(big.Int), nil).WithSigner(config.GetSigner(header.Number)).SignECDSA(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tx
+	}
+	limits := new(limitAborts)
+	for i, to := range []common.Address{caller, recurser} {
+		if _, _, _, err := applyTransaction(config, nil, new(GasPool).AddGas(header.GasLimit), statedb, header, transaction(uint64(i), to), new(big.Int), nil, nil, limits); err != nil {
+			t.Fatalf("failed to apply transaction %d: %v", i, err)
+		}
+	}
+	if limits.stack != 1 || limits.callDepth != 1 {
+		t.Errorf("limit aborts mismatch: have %d stack, %d call depth, want 1 and 1", limits.stack, limits.callDepth)
+	}
+
+	// Only the frames which hit the limits are flagged
+	trace := func(tx *types.Transaction) *vm.CallFrame {
+		env := NewEnv(statedb, config, nil, tx, header)
+		tracer := vm.NewCallTracer()
+		env.SetTracer(tracer)
+		if _, _, err := ApplyMessage(env, tx, new(GasPool).AddGas(header.GasLimit)); err != nil {
+			t.Fatalf("failed to trace transaction: %v", err)
+		}
+		return tracer.Result()
+	}
+	root := trace(transaction(2, caller))
+	if root.Limit != "" || len(root.Calls) != 1 || root.Calls[0].Limit != "stack" {
+		t.Errorf("stack limit abort not flagged on the overflowing frame: %+v", root)
+	}
+	frame, depth := trace(transaction(3, recurser)), 0
+	for ; len(frame.Calls) > 0; depth++ {
+		if frame.Limit != "" {
+			t.Fatalf("frame at depth %d flagged with %s limit", depth, frame.Limit)
+		}
+		frame = frame.Calls[0]
+	}
+	if depth != 1025 || frame.Limit != "callDepth" {
+		t.Errorf("call depth limit abort mismatch: depth %d flagged with %q, want depth 1025 flagged with callDepth", depth, frame.Limit)
+	}
+}
@@ -0,0 +1,256 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+
+	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/core/state"
+	"github.com/ellaism/go-ellaism/core/types"
+	"github.com/ellaism/go-ellaism/core/vm"
+	"github.com/ellaism/go-ellaism/logger"
+	"github.com/ellaism/go-ellaism/logger/glog"
+	"github.com/ellaism/go-ellaism/rlp"
+)
+
+// forensicsSummary describes the state root mismatch a forensic bundle was
+// written for. The replayed root is the one of the re-execution of the block
+// done for the bundle; if it differs from the computed one, the processing of
+// the block isn't deterministic.
+type forensicsSummary struct {
+	Number       uint64      `json:"number"`
+	Hash         common.Hash `json:"hash"`
+	ParentHash   common.Hash `json:"parentHash"`
+	HeaderRoot   common.Hash `json:"headerRoot"`
+	ComputedRoot common.Hash `json:"computedRoot"`
+	ReplayedRoot common.Hash `json:"replayedRoot"`
+	Error        string      `json:"error"`
+}
+
+// forensicsTrace is the execution trace of a transaction of a forensic bundle.
+type forensicsTrace struct {
+	Hash       common.Hash    `json:"hash"`
+	Gas        *big.Int       `json:"gas"`
+	Error      string         `json:"error,omitempty"`
+	StructLogs []vm.StructLog `json:"structLogs"`
+}
+
+// forensicsAccount is the state of an account touched by a block, with the
+// storage slots touched by its transactions.
+type forensicsAccount struct {
+	Balance  *big.Int               `json:"balance"`
+	Nonce    uint64                 `json:"nonce"`
+	CodeHash common.Hash            `json:"codeHash"`
+	Storage  map[string]common.Hash `json:"storage,omitempty"`
+}
+
+// forensicsState is an account touched by a block, before and after the block.
+// Either is nil if the account doesn't exist.
+type forensicsState struct {
+	Pre  *forensicsAccount `json:"pre"`
+	Post *forensicsAccount `json:"post"`
+}
+
+// forensicsTracer records the execution of a transaction for a forensic bundle:
+// the state of the EVM prior to every opcode, memory aside, and the accounts and
+// storage slots it touched.
+type forensicsTracer struct {
+	*vm.StructLogger
+	touched map[common.Address]map[common.Hash]bool
+}
+
+func newForensicsTracer(touched map[common.Address]map[common.Hash]bool) *forensicsTracer {
+	return &forensicsTracer{
+		StructLogger: vm.NewStructLogger(&vm.LogConfig{DisableMemory: true}),
+		touched:      touched,
+	}
+}
+
+func (t *forensicsTracer) touch(addr common.Address) map[common.Hash]bool {
+	if _, ok := t.touched[addr]; !ok {
+		t.touched[addr] = make(map[common.Hash]bool)
+	}
+	return t.touched[addr]
+}
+
+func (t *forensicsTracer) CaptureEnter(typ vm.OpCode, from, to common.Address, input []byte, gas, value *big.Int) {
+	t.touch(from)
+	t.touch(to)
+}
+
+func (t *forensicsTracer) CaptureState(env vm.Environment, pc uint64, op vm.OpCode, gas, cost *big.Int, memory *vm.Memory, stack []*big.Int, contract *vm.Contract, depth int) {
+	t.StructLogger.CaptureState(env, pc, op, gas, cost, memory, stack, contract, depth)
+
+	size := len(stack)
+	switch {
+	case (op == vm.SLOAD || op == vm.SSTORE) && size >= 1:
+		t.touch(contract.Address())[common.BigToHash(stack[size-1])] = true
+	case (op == vm.BALANCE || op == vm.EXTCODESIZE || op == vm.EXTCODECOPY || op == vm.EXTCODEHASH) && size >= 1:
+		t.touch(common.BigToAddress(stack[size-1]))
+	}
+}
+
+// SetForensicsDir sets the directory forensic bundles are written to when the
+// processing of an imported block results in a state root mismatch. Bundles
+// aren't written if it is empty.
+func (self *BlockChain) SetForensicsDir(dir string) {
+	self.chainmu.Lock()
+	defer self.chainmu.Unlock()
+	self.forensicsDir = dir
+}
+
+// reportStateRootMismatch writes the forensic bundle of a block whose processing
+// resulted in a state root mismatch and logs its path.
+func (self *BlockChain) reportStateRootMismatch(block *types.Block, receipts types.Receipts, cause *StateRootErr) {
+	if self.forensicsDir == "" {
+		return
+	}
+	path, err := self.writeForensics(block, receipts, cause)
+	if err != nil {
+		glog.V(logger.Error).Errorf("Failed to write the forensic bundle of block #%d [%s]: %v", block.NumberU64(), block.Hash().Hex(), err)
+		return
+	}
+	glog.V(logger.Error).Errorf("State root mismatch in block #%d [%s], forensic bundle written to %s", block.NumberU64(), block.Hash().Hex(), path)
+	glog.D(logger.Error).Errorf("State root mismatch in block #%d [%s], forensic bundle written to %s", block.NumberU64(), block.Hash().Hex(), path)
+}
+
+// writeForensics writes the forensic bundle of a block whose processing
+// resulted in a state root mismatch to a directory of its own, returning its
+// path. The bundle holds the RLP of the block, the receipts its processing
+// produced, the traces of its transactions and the state of the accounts it
+// touched before and after it, for which the block is re-executed from the
+// state of its parent. A complete bundle already written for the block is left
+// as is.
+func (self *BlockChain) writeForensics(block *types.Block, receipts types.Receipts, cause *StateRootErr) (string, error) {
+	dir := filepath.Join(self.forensicsDir, fmt.Sprintf("%d-%x", block.NumberU64(), block.Hash().Bytes()[:4]))
+	if _, err := os.Stat(filepath.Join(dir, "summary.json")); err == nil {
+		return dir, nil
+	}
+	parent := self.GetBlock(block.ParentHash())
+	if parent == nil {
+		return "", ParentError(block.ParentHash())
+	}
+	pre, err := self.StateAt(parent.Root())
+	if err != nil {
+		return "", err
+	}
+	post, err := self.StateAt(parent.Root())
+	if err != nil {
+		return "", err
+	}
+	// Re-execute the block, tracing its transactions
+	var (
+		header  = block.Header()
+		gp      = new(GasPool).AddGas(block.GasLimit())
+		touched = map[common.Address]map[common.Hash]bool{header.Coinbase: {}}
+		traces  = []*forensicsTrace{}
+	)
+	for _, uncle := range block.Uncles() {
+		touched[uncle.Coinbase] = make(map[common.Hash]bool)
+	}
+	for i, tx := range block.Transactions() {
+		post.StartRecord(tx.Hash(), block.Hash(), i)
+		tx.SetSigner(self.config.GetSigner(header.Number))
+
+		tracer := newForensicsTracer(touched)
+		env := NewEnv(post, self.config, self, tx, header)
+		env.SetTracer(tracer)
+		_, gas, err := ApplyMessage(env, tx, gp)
+
+		trace := &forensicsTrace{Hash: tx.Hash(), Gas: gas, StructLogs: tracer.StructLogs()}
+		traces = append(traces, trace)
+		if err != nil {
+			trace.Error = err.Error()
+			break
+		}
+		post.IntermediateRoot(self.config.IsEIP161(header.Number))
+	}
+	self.engine.Finalize(self, header, post, block.Uncles())
+
+	summary := &forensicsSummary{
+		Number:       block.NumberU64(),
+		Hash:         block.Hash(),
+		ParentHash:   block.ParentHash(),
+		HeaderRoot:   cause.Header,
+		ComputedRoot: cause.Computed,
+		ReplayedRoot: post.IntermediateRoot(self.config.IsEIP161(header.Number)),
+		Error:        cause.Error(),
+	}
+	accounts := make(map[common.Address]*forensicsState, len(touched))
+	for addr, slots := range touched {
+		accounts[addr] = &forensicsState{
+			Pre:  forensicsAccountAt(pre, addr, slots),
+			Post: forensicsAccountAt(post, addr, slots),
+		}
+	}
+	// Write the bundle, the summary last to mark it complete
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	blob, err := rlp.EncodeToBytes(block)
+	if err != nil {
+		return "", err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "block.rlp"), blob, 0644); err != nil {
+		return "", err
+	}
+	files := []struct {
+		name string
+		data interface{}
+	}{
+		{"receipts.json", receipts},
+		{"traces.json", traces},
+		{"state.json", accounts},
+		{"summary.json", summary},
+	}
+	for _, file := range files {
+		blob, err := json.MarshalIndent(file.data, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, file.name), blob, 0644); err != nil {
+			return "", err
+		}
+	}
+	return dir, nil
+}
+
+// forensicsAccountAt returns the state of an account with the given storage
+// slots, or nil if the account doesn't exist.
+func forensicsAccountAt(statedb *state.StateDB, addr common.Address, slots map[common.Hash]bool) *forensicsAccount {
+	if !statedb.Exist(addr) {
+		return nil
+	}
+	account := &forensicsAccount{
+		Balance:  statedb.GetBalance(addr),
+		Nonce:    statedb.GetNonce(addr),
+		CodeHash: statedb.GetCodeHash(addr),
+	}
+	if len(slots) > 0 {
+		account.Storage = make(map[string]common.Hash, len(slots))
+		for key := range slots {
+			account.Storage[key.Hex()] = statedb.GetState(addr, key)
+		}
+	}
+	return account
+}
@@ -0,0 +1,115 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/core/types"
+	"github.com/ellaism/go-ellaism/crypto"
+	"github.com/ellaism/go-ellaism/ethdb"
+	"github.com/ellaism/go-ellaism/event"
+	"github.com/ellaism/go-ellaism/rlp"
+)
+
+// Tests that a forensic bundle is written for blocks resulting in a state root
+// mismatch.
+func TestStateRootMismatchForensics(t *testing.T) {
+	dir, err := ioutil.TempDir("", "forensics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	key, _ := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	addr := crypto.PubkeyToAddress(key.PublicKey)
+
+	db, _ := ethdb.NewMemDatabase()
+	genesis := WriteGenesisBlockForTesting(db, GenesisAccount{addr, big.NewInt(1e18)})
+	config := MakeDiehardChainConfig()
+	signer := types.NewChainIdSigner(big.NewInt(63))
+
+	chain, _ := GenerateChain(config, genesis, db, 2, func(i int, b *BlockGen) {
+		tx, err := types.NewTransaction(b.TxNonce(addr), common.Address{0xaa}, big.NewInt(1000), TxGas, big.NewInt(1), nil).WithSigner(signer).SignECDSA(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b.AddTx(tx)
+	})
+	blockchain, err := NewBlockChain(db, config, FakePow{}, new(event.TypeMux))
+	if err != nil {
+		t.Fatal(err)
+	}
+	blockchain.SetForensicsDir(dir)
+	if _, err := blockchain.InsertChain(chain[:1]); err != nil {
+		t.Fatal(err)
+	}
+	// Import a block with a wrong state root
+	header := chain[1].Header()
+	header.Root = common.Hash{0x01}
+	bad := types.NewBlockWithHeader(header).WithBody(chain[1].Transactions(), chain[1].Uncles())
+	if _, err := blockchain.InsertChain(types.Blocks{bad}); !IsStateRootErr(err) {
+		t.Fatalf("error mismatch: have %v, want state root error", err)
+	}
+	bundle := filepath.Join(dir, "2-"+common.Bytes2Hex(bad.Hash().Bytes()[:4]))
+
+	blob, err := ioutil.ReadFile(filepath.Join(bundle, "block.rlp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	block := new(types.Block)
+	if err := rlp.DecodeBytes(blob, block); err != nil || block.Hash() != bad.Hash() {
+		t.Errorf("block mismatch: %v", err)
+	}
+	var summary forensicsSummary
+	readJSON(t, filepath.Join(bundle, "summary.json"), &summary)
+	if summary.HeaderRoot != header.Root || summary.ComputedRoot != chain[1].Root() || summary.ReplayedRoot != chain[1].Root() {
+		t.Errorf("summary roots mismatch: header %x, computed %x, replayed %x", summary.HeaderRoot, summary.ComputedRoot, summary.ReplayedRoot)
+	}
+	var traces []*forensicsTrace
+	readJSON(t, filepath.Join(bundle, "traces.json"), &traces)
+	if len(traces) != 1 || traces[0].Hash != bad.Transactions()[0].Hash() || traces[0].Gas.Cmp(TxGas) != 0 {
+		t.Errorf("traces mismatch: %v", traces)
+	}
+	var state map[common.Address]*forensicsState
+	readJSON(t, filepath.Join(bundle, "state.json"), &state)
+	if recipient := state[common.Address{0xaa}]; recipient == nil || recipient.Pre.Balance.Cmp(big.NewInt(1000)) != 0 || recipient.Post.Balance.Cmp(big.NewInt(2000)) != 0 {
+		t.Errorf("recipient state mismatch: %v", recipient)
+	}
+	if sender := state[addr]; sender == nil || sender.Pre.Nonce != 1 || sender.Post.Nonce != 2 {
+		t.Errorf("sender state mismatch: %v", sender)
+	}
+	if state[bad.Coinbase()] == nil {
+		t.Error("coinbase state missing")
+	}
+}
+
+func readJSON(t *testing.T, path string, v interface{}) {
+	blob, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(blob, v); err != nil {
+		t.Fatalf("%s: %v", path, err)
+	}
+}
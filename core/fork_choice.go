@@ -0,0 +1,123 @@
+// Copyright 2015 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"math/big"
+	"sync"
+
+	"github.com/ellaism/go-ellaism/core/types"
+	"github.com/ellaism/go-ellaism/logger"
+	"github.com/ellaism/go-ellaism/logger/glog"
+)
+
+// ForkHead is the head of a chain competing to be the canonical chain.
+type ForkHead struct {
+	Header *types.Header
+	Td     *big.Int // Total difficulty of the chain
+}
+
+// ForkRule reports whether the extern chain should replace the current one as
+// the canonical chain.
+type ForkRule func(current, extern ForkHead) bool
+
+// TdForkRule prefers the chain with the highest total difficulty. Of chains
+// with equal total difficulty it prefers the one whose head has the lowest
+// hash, so that all nodes agree on the head regardless of the order they
+// received the blocks in.
+func TdForkRule(current, extern ForkHead) bool {
+	// A missing total difficulty is lower than any known one.
+	switch {
+	case current.Td == nil:
+		return extern.Td != nil
+	case extern.Td == nil:
+		return false
+	}
+	if cmp := extern.Td.Cmp(current.Td); cmp != 0 {
+		return cmp > 0
+	}
+	currentHash, externHash := current.Header.Hash(), extern.Header.Hash()
+	return bytes.Compare(externHash[:], currentHash[:]) < 0
+}
+
+// ForkDecision is a choice between two competing chains.
+type ForkDecision struct {
+	Current ForkHead
+	Extern  ForkHead
+	Reorg   bool // Whether the extern chain replaced the current one
+}
+
+// ForkChoice chooses the canonical chain among competing ones. Hooks are
+// notified of the decisions between chains which don't extend each other.
+type ForkChoice struct {
+	rule  ForkRule
+	hooks []func(ForkDecision)
+	mu    sync.RWMutex
+}
+
+// NewForkChoice returns a fork choice using the given rule, or TdForkRule if
+// nil.
+func NewForkChoice(rule ForkRule) *ForkChoice {
+	if rule == nil {
+		rule = TdForkRule
+	}
+	return &ForkChoice{rule: rule}
+}
+
+// SetRule replaces the rule of the fork choice.
+func (f *ForkChoice) SetRule(rule ForkRule) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rule = rule
+}
+
+// AddHook registers a function called with every decision between competing
+// chains. Hooks are called with the chain locked and may not call back into
+// it.
+func (f *ForkChoice) AddHook(hook func(ForkDecision)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.hooks = append(f.hooks, hook)
+}
+
+// Reorg reports whether the extern chain should replace the current one as
+// the canonical chain.
+func (f *ForkChoice) Reorg(current, extern ForkHead) bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	reorg := f.rule(current, extern)
+	if extern.Header.ParentHash != current.Header.Hash() {
+		decision := ForkDecision{Current: current, Extern: extern, Reorg: reorg}
+		for _, hook := range f.hooks {
+			hook(decision)
+		}
+	}
+	return reorg
+}
+
+// logForkDecision logs the decision between competing chains.
+func logForkDecision(d ForkDecision) {
+	winner, loser := d.Current, d.Extern
+	if d.Reorg {
+		winner, loser = d.Extern, d.Current
+	}
+	glog.V(logger.Debug).Infof("Fork choice: chose #%v [%x…] (td %v) over #%v [%x…] (td %v)",
+		winner.Header.Number, winner.Header.Hash().Bytes()[:4], winner.Td,
+		loser.Header.Number, loser.Header.Hash().Bytes()[:4], loser.Td)
+}
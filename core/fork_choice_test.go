@@ -0,0 +1,142 @@
+// Copyright 2015 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/core/types"
+	"github.com/ellaism/go-ellaism/ethdb"
+	"github.com/ellaism/go-ellaism/event"
+)
+
+func forkHead(extra byte, td int64) ForkHead {
+	head := ForkHead{Header: &types.Header{Number: big.NewInt(1), Extra: []byte{extra}}}
+	if td >= 0 {
+		head.Td = big.NewInt(td)
+	}
+	return head
+}
+
+func TestTdForkRule(t *testing.T) {
+	// Order two heads of equal total difficulty by hash.
+	low, high := forkHead(1, 10), forkHead(2, 10)
+	if lh, hh := low.Header.Hash(), high.Header.Hash(); bytes.Compare(lh[:], hh[:]) > 0 {
+		low, high = high, low
+	}
+	huge := new(big.Int).Lsh(common.Big1, 300)
+
+	tests := []struct {
+		name            string
+		current, extern ForkHead
+		reorg           bool
+	}{
+		{"higher td", forkHead(1, 10), forkHead(2, 11), true},
+		{"lower td", forkHead(1, 11), forkHead(2, 10), false},
+		{"huge td", forkHead(1, 10), ForkHead{forkHead(2, 0).Header, huge}, true},
+		{"equal td, lower hash", high, low, true},
+		{"equal td, higher hash", low, high, false},
+		{"same head", low, low, false},
+		{"unknown current td", forkHead(1, -1), forkHead(2, 0), true},
+		{"unknown extern td", forkHead(1, 0), forkHead(2, -1), false},
+	}
+	for _, tt := range tests {
+		if reorg := TdForkRule(tt.current, tt.extern); reorg != tt.reorg {
+			t.Errorf("%s: reorg %v, want %v", tt.name, reorg, tt.reorg)
+		}
+	}
+}
+
+func TestForkChoiceHooks(t *testing.T) {
+	var decisions []ForkDecision
+	f := NewForkChoice(nil)
+	f.AddHook(func(d ForkDecision) { decisions = append(decisions, d) })
+
+	parent := forkHead(1, 10)
+	child := ForkHead{&types.Header{ParentHash: parent.Header.Hash(), Number: big.NewInt(2)}, big.NewInt(20)}
+	if !f.Reorg(parent, child) {
+		t.Error("child of the head isn't chosen")
+	}
+	if len(decisions) != 0 {
+		t.Errorf("extending the head notified %d decisions, want none", len(decisions))
+	}
+
+	current, extern := forkHead(1, 10), forkHead(2, 20)
+	if !f.Reorg(current, extern) {
+		t.Error("heavier chain isn't chosen")
+	}
+	if len(decisions) != 1 {
+		t.Fatalf("%d decisions notified, want 1", len(decisions))
+	}
+	if d := decisions[0]; d.Current.Header != current.Header || d.Extern.Header != extern.Header || !d.Reorg {
+		t.Errorf("decision %+v doesn't record the reorg to the extern chain", d)
+	}
+
+	// Swapping the rule applies to subsequent decisions.
+	f.SetRule(func(current, extern ForkHead) bool { return false })
+	if f.Reorg(current, extern) {
+		t.Error("replaced rule isn't applied")
+	}
+	if len(decisions) != 2 || decisions[1].Reorg {
+		t.Errorf("decisions %+v, want a second one without reorg", decisions)
+	}
+}
+
+// Tests that competing chains of equal total difficulty are resolved the same
+// way regardless of the order they are imported in.
+func TestForkChoiceDeterministic(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	genesis := WriteGenesisBlockForTesting(db)
+	config := DefaultConfigMainnet.ChainConfig
+
+	a, _ := GenerateChain(config, genesis, db, 1, func(i int, b *BlockGen) { b.SetCoinbase(common.Address{0xaa}) })
+	b, _ := GenerateChain(config, genesis, db, 1, func(i int, b *BlockGen) { b.SetCoinbase(common.Address{0xbb}) })
+	if a[0].Difficulty().Cmp(b[0].Difficulty()) != 0 {
+		t.Fatal("competing blocks have different difficulties")
+	}
+	want := a[0]
+	if ah, bh := a[0].Hash(), b[0].Hash(); bytes.Compare(bh[:], ah[:]) < 0 {
+		want = b[0]
+	}
+
+	for _, order := range [][]*types.Block{{a[0], b[0]}, {b[0], a[0]}} {
+		db, _ := ethdb.NewMemDatabase()
+		WriteGenesisBlockForTesting(db)
+		bc, err := NewBlockChain(db, config, FakePow{}, new(event.TypeMux))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decisions int
+		bc.ForkChoice().AddHook(func(ForkDecision) { decisions++ })
+
+		for _, block := range order {
+			if _, err := bc.InsertChain(types.Blocks{block}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if head := bc.CurrentBlock(); head.Hash() != want.Hash() {
+			t.Errorf("importing [%x…] first: head [%x…], want [%x…]", order[0].Hash().Bytes()[:4], head.Hash().Bytes()[:4], want.Hash().Bytes()[:4])
+		}
+		if decisions != 1 {
+			t.Errorf("importing [%x…] first: %d decisions notified, want 1", order[0].Hash().Bytes()[:4], decisions)
+		}
+		bc.Stop()
+	}
+}
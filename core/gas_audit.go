@@ -0,0 +1,167 @@
+// Copyright 2016 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/core/state"
+	"github.com/ellaism/go-ellaism/core/types"
+	"github.com/ellaism/go-ellaism/core/vm"
+)
+
+// gasAuditFrame is a single call frame tracked by the gas auditor.
+type gasAuditFrame struct {
+	typ   vm.OpCode
+	to    common.Address
+	input []byte
+
+	gas  *big.Int // Gas made available to the frame
+	used *big.Int // Gas used by the frame according to opcode metering
+}
+
+// gasAuditor is a vm.Tracer which recomputes the gas used by a transaction
+// from the cost of every executed opcode and cross-checks it against the gas
+// reported by the state transition, both for every call frame and for the
+// transaction as a whole. Any mismatch hints at a gas accounting bug which
+// would split the network if the other clients disagree.
+type gasAuditor struct {
+	ruleset   vm.RuleSet
+	number    *big.Int
+	homestead bool
+	gasTable  *vm.GasTable
+	frames    []*gasAuditFrame
+	root      *gasAuditFrame
+
+	discrepancies []string
+}
+
+// newGasAuditor returns a gas auditor for a single transaction executed with
+// the rules of the given block.
+func newGasAuditor(ruleset vm.RuleSet, number *big.Int) *gasAuditor {
+	return &gasAuditor{
+		ruleset:   ruleset,
+		number:    number,
+		homestead: ruleset.IsHomestead(number),
+		gasTable:  ruleset.GasTable(number),
+	}
+}
+
+func (a *gasAuditor) CaptureEnter(typ vm.OpCode, from, to common.Address, input []byte, gas, value *big.Int) {
+	a.frames = append(a.frames, &gasAuditFrame{
+		typ:   typ,
+		to:    to,
+		input: input,
+		gas:   new(big.Int).Set(gas),
+		used:  new(big.Int),
+	})
+}
+
+func (a *gasAuditor) CaptureState(env vm.Environment, pc uint64, op vm.OpCode, gas, cost *big.Int, memory *vm.Memory, stack []*big.Int, contract *vm.Contract, depth int) {
+	if len(a.frames) == 0 {
+		return
+	}
+	frame := a.frames[len(a.frames)-1]
+	frame.used.Add(frame.used, cost)
+}
+
+func (a *gasAuditor) CaptureExit(output []byte, gasUsed *big.Int, err error) {
+	if len(a.frames) == 0 {
+		return
+	}
+	frame := a.frames[len(a.frames)-1]
+	a.frames = a.frames[:len(a.frames)-1]
+
+	// Suicides are reported as frames of their own but their cost has
+	// already been metered as part of the suiciding contract.
+	if frame.typ == vm.SUICIDE {
+		return
+	}
+
+	// Work out the gas the frame should have used given the metered opcodes
+	// and the outcome of its execution.
+	switch {
+	case err == nil:
+		if p := vm.ActivePrecompiled(a.ruleset, a.number, frame.to); p != nil && !isCreate(frame.typ) {
+			frame.used.Set(p.Gas(frame.input))
+		}
+		if isCreate(frame.typ) {
+			dataGas := new(big.Int).Mul(big.NewInt(int64(len(output))), a.gasTable.CreateData)
+			frame.used.Add(frame.used, dataGas)
+		}
+	case err == errCallCreateDepth || IsValueTransferErr(err):
+		// The frame never started executing, all gas is returned
+		frame.used.SetUint64(0)
+	case err == vm.ErrExecutionReverted:
+		// Reverted frames only pay for the metered opcodes
+	case err == vm.CodeStoreOutOfGasError && !a.homestead:
+		// Frontier keeps the created account without code and returns the
+		// gas which could not pay for storing it
+	default:
+		frame.used.Set(frame.gas)
+	}
+	if frame.used.Cmp(gasUsed) != 0 {
+		a.discrepancies = append(a.discrepancies, fmt.Sprintf("%v to %x (depth %d): metered %v gas, reported %v", frame.typ, frame.to, len(a.frames), frame.used, gasUsed))
+	}
+
+	if len(a.frames) == 0 {
+		a.root = frame
+		return
+	}
+	// Charge the parent with the gas actually used by the frame. The gas of a
+	// message call is metered upfront as part of the call's cost, while the
+	// gas forwarded to a contract creation is not metered at all.
+	parent := a.frames[len(a.frames)-1]
+	if isCreate(frame.typ) {
+		parent.used.Add(parent.used, gasUsed)
+	} else {
+		parent.used.Sub(parent.used, new(big.Int).Sub(frame.gas, gasUsed))
+	}
+}
+
+// isCreate returns whether the frame type is a contract creation.
+func isCreate(typ vm.OpCode) bool {
+	return typ == vm.CREATE || typ == vm.CREATE2
+}
+
+// verify checks the gas used by the given transaction as reported by the state
+// transition against the gas metered during its execution and returns all
+// discrepancies found. It must be called before the refund counter and the
+// state journal are reset by computing the intermediate state root.
+func (a *gasAuditor) verify(statedb *state.StateDB, tx *types.Transaction, gasUsed *big.Int) []string {
+	if a.root == nil {
+		a.discrepancies = append(a.discrepancies, "no execution captured")
+		return a.discrepancies
+	}
+	expected := IntrinsicGas(tx.Data(), tx.AccessList(), MessageCreatesContract(tx), a.homestead, a.gasTable)
+	expected.Add(expected, a.root.used)
+
+	// Recompute the refund from the state changes which survived the
+	// execution and check it against the refund counter.
+	refund := statedb.JournalRefund(a.gasTable.SStoreRefund, a.gasTable.SuicideRefund)
+	if counter := statedb.GetRefund(); counter.Cmp(refund) != 0 {
+		a.discrepancies = append(a.discrepancies, fmt.Sprintf("refund counter %v, state changes refund %v", counter, refund))
+	}
+	expected.Sub(expected, common.BigMin(new(big.Int).Div(expected, common.Big2), refund))
+
+	if expected.Cmp(gasUsed) != 0 {
+		a.discrepancies = append(a.discrepancies, fmt.Sprintf("transaction: metered %v gas, reported %v", expected, gasUsed))
+	}
+	return a.discrepancies
+}
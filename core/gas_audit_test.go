@@ -0,0 +1,123 @@
+// Copyright 2016 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/core/state"
+	"github.com/ellaism/go-ellaism/core/types"
+	"github.com/ellaism/go-ellaism/crypto"
+	"github.com/ellaism/go-ellaism/ethdb"
+)
+
+func TestGasAudit(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, db)
+
+	key, _ := crypto.GenerateKey()
+	sender := crypto.PubkeyToAddress(key.PublicKey)
+	statedb.AddBalance(sender, big.NewInt(1e18))
+
+	// The contract clears a storage slot, calls the sha256 precompile, a
+	// suiciding contract and a looping one, creates a contract and finally
+	// suicides itself.
+	contract := common.Address{0x0a}
+	statedb.SetState(contract, common.Hash{}, common.Hash{1})
+	statedb.SetCode(contract, common.Hex2Bytes(
+		"6000600055"+
+			"60206000602060006000600261fffff150"+
+			"6000600060006000600060b061fffff150"+
+			"6000600060006000600060c0600af150"+
+			"69600160005360016000f3600052600a60166000f050"+
+			"600cff"))
+	statedb.SetCode(common.BytesToAddress([]byte{0xb0}), common.Hex2Bytes("600dff"))
+	statedb.SetCode(common.BytesToAddress([]byte{0xc0}), common.Hex2Bytes("5b600056"))
+
+	config := MakeChainConfig()
+	header := &types.Header{
+		Number:     big.NewInt(1),
+		Difficulty: big.NewInt(1),
+		GasLimit:   big.NewInt(4712388),
+		Time:       big.NewInt(1),
+	}
+	tx, err := types.NewTransaction(0, contract, new(big.Int), big.NewInt(1000000), big.NewInt(1), nil).WithSigner(config.GetSigner(header.Number)).SignECDSA(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	auditor := newGasAuditor(config, header.Number)
+	env := NewEnv(statedb, config, nil, tx, header)
+	env.SetTracer(auditor)
+	_, gas, err := ApplyMessage(env, tx, new(GasPool).AddGas(header.GasLimit))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refund := statedb.GetRefund(); refund.Cmp(big.NewInt(15000+2*24000)) != 0 {
+		t.Errorf("refund mismatch: have %v, want %v", refund, 15000+2*24000)
+	}
+	if discrepancies := auditor.verify(statedb, tx, gas); len(discrepancies) != 0 {
+		t.Fatalf("unexpected discrepancies: %v", discrepancies)
+	}
+	if discrepancies := auditor.verify(statedb, tx, new(big.Int).Add(gas, common.Big1)); len(discrepancies) != 1 {
+		t.Fatalf("discrepancy count mismatch: have %d, want 1", len(discrepancies))
+	}
+}
+
+func TestGasAuditRefundMismatch(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, db)
+
+	key, _ := crypto.GenerateKey()
+	sender := crypto.PubkeyToAddress(key.PublicKey)
+	statedb.AddBalance(sender, big.NewInt(1e18))
+
+	// The contract clears a storage slot
+	contract := common.Address{0x0a}
+	statedb.SetState(contract, common.Hash{}, common.Hash{1})
+	statedb.SetCode(contract, common.Hex2Bytes("6000600055"))
+	statedb.IntermediateRoot(false)
+
+	config := MakeChainConfig()
+	header := &types.Header{
+		Number:     big.NewInt(1),
+		Difficulty: big.NewInt(1),
+		GasLimit:   big.NewInt(4712388),
+		Time:       big.NewInt(1),
+	}
+	tx, err := types.NewTransaction(0, contract, new(big.Int), big.NewInt(100000), big.NewInt(1), nil).WithSigner(config.GetSigner(header.Number)).SignECDSA(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auditor := newGasAuditor(config, header.Number)
+	env := NewEnv(statedb, config, nil, tx, header)
+	env.SetTracer(auditor)
+	_, gas, err := ApplyMessage(env, tx, new(GasPool).AddGas(header.GasLimit))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if discrepancies := auditor.verify(statedb, tx, gas); len(discrepancies) != 0 {
+		t.Fatalf("unexpected discrepancies: %v", discrepancies)
+	}
+	// A refund not backed by any state change must be reported
+	statedb.AddRefund(big.NewInt(1))
+	if discrepancies := auditor.verify(statedb, tx, gas); len(discrepancies) != 1 {
+		t.Fatalf("discrepancy count mismatch: have %d, want 1: %v", len(discrepancies), discrepancies)
+	}
+}
@@ -27,12 +27,12 @@ import (
 	"time"
 
 	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/consensus"
 	"github.com/ellaism/go-ellaism/core/types"
 	"github.com/ellaism/go-ellaism/ethdb"
 	"github.com/ellaism/go-ellaism/event"
 	"github.com/ellaism/go-ellaism/logger"
 	"github.com/ellaism/go-ellaism/logger/glog"
-	"github.com/ellaism/go-ellaism/pow"
 	"github.com/hashicorp/golang-lru"
 )
 
@@ -43,6 +43,7 @@ import (
 // the necessary mutex locking/unlocking.
 type HeaderChain struct {
 	config *ChainConfig
+	engine consensus.Engine // Consensus engine verifying the seals of inserted headers
 
 	chainDb       ethdb.Database
 	genesisHeader *types.Header
@@ -58,16 +59,18 @@ type HeaderChain struct {
 	rand         *mrand.Rand
 	getValidator getHeaderValidatorFn
 	eventMux     *event.TypeMux
+	forkChoice   *ForkChoice
 }
 
 // getHeaderValidatorFn returns a HeaderValidator interface
 type getHeaderValidatorFn func() HeaderValidator
 
 // NewHeaderChain creates a new HeaderChain structure.
+//  engine is the consensus engine of the parent
 //  getValidator should return the parent's validator
 //  procInterrupt points to the parent's interrupt semaphore
 //  wg points to the parent's shutdown wait group
-func NewHeaderChain(chainDb ethdb.Database, config *ChainConfig, mux *event.TypeMux, getValidator getHeaderValidatorFn, procInterrupt func() bool) (*HeaderChain, error) {
+func NewHeaderChain(chainDb ethdb.Database, config *ChainConfig, engine consensus.Engine, mux *event.TypeMux, getValidator getHeaderValidatorFn, procInterrupt func() bool) (*HeaderChain, error) {
 	headerCache, _ := lru.New(headerCacheLimit)
 	tdCache, _ := lru.New(tdCacheLimit)
 
@@ -79,6 +82,7 @@ func NewHeaderChain(chainDb ethdb.Database, config *ChainConfig, mux *event.Type
 
 	hc := &HeaderChain{
 		config:        config,
+		engine:        engine,
 		eventMux:      mux,
 		chainDb:       chainDb,
 		headerCache:   headerCache,
@@ -86,16 +90,22 @@ func NewHeaderChain(chainDb ethdb.Database, config *ChainConfig, mux *event.Type
 		procInterrupt: procInterrupt,
 		rand:          mrand.New(mrand.NewSource(seed.Int64())),
 		getValidator:  getValidator,
+		forkChoice:    NewForkChoice(nil),
 	}
+	hc.forkChoice.AddHook(logForkDecision)
 
 	gen := DefaultConfigMainnet.Genesis
 	genname := "mainnet"
-	// Check if ChainConfig is mainnet or testnet and write genesis accordingly.
+	// Check if ChainConfig is mainnet, testnet or dev and write genesis accordingly.
 	// If it's neither (custom), write default (this will be overwritten or avoided,
 	// but maintains consistent implementation.
-	if config == DefaultConfigMorden.ChainConfig {
+	switch config {
+	case DefaultConfigMorden.ChainConfig:
 		gen = DefaultConfigMorden.Genesis
 		genname = "morden testnet"
+	case DefaultConfigDev.ChainConfig:
+		gen = DefaultConfigDev.Genesis
+		genname = "dev"
 	}
 
 	hc.genesisHeader = hc.GetHeaderByNumber(0)
@@ -151,10 +161,8 @@ func (hc *HeaderChain) WriteHeader(header *types.Header) (status WriteStatus, er
 		glog.Fatalf("failed to write header contents: %v", err)
 	}
 
-	// If the total difficulty is higher than our known, add it to the canonical chain
-	// Second clause in the if statement reduces the vulnerability to selfish mining.
-	// Please refer to http://www.cs.cornell.edu/~ie53/publications/btcProcFC.pdf
-	if externTd.Cmp(localTd) > 0 || (externTd.Cmp(localTd) == 0 && mrand.Float64() < 0.5) {
+	// If the fork choice prefers the header's chain, make it the canonical chain
+	if hc.forkChoice.Reorg(ForkHead{hc.currentHeader, localTd}, ForkHead{header, externTd}) {
 		// Delete any canonical number assignments above the new head
 		for i := number + 1; GetCanonicalHash(hc.chainDb, i) != (common.Hash{}); i++ {
 			DeleteCanonicalHash(hc.chainDb, i)
@@ -255,14 +263,13 @@ func (hc *HeaderChain) InsertHeaderChain(chain []*types.Header, checkFreq int, w
 			if hc.HasHeader(hash) {
 				continue
 			}
-			// Verify that the header honors the chain parameters
-			checkPow := verify[index]
-
+			// Verify that the header honors the chain parameters, its seal is
+			// verified below
 			var err error
 			if index == 0 {
-				err = hc.getValidator().ValidateHeader(header, hc.GetHeader(header.ParentHash), checkPow)
+				err = hc.getValidator().ValidateHeader(header, hc.GetHeader(header.ParentHash), false)
 			} else {
-				err = hc.getValidator().ValidateHeader(header, chain[index-1], checkPow)
+				err = hc.getValidator().ValidateHeader(header, chain[index-1], false)
 			}
 			if err != nil {
 				errs[index] = err
@@ -291,6 +298,32 @@ func (hc *HeaderChain) InsertHeaderChain(chain []*types.Header, checkFreq int, w
 			}
 		}
 	}
+	// Verify the seals of the selected headers. Engines may need ancestors of
+	// the headers within the batch, so the batch extends the chain.
+	var (
+		seals   []*types.Header
+		indices []int
+	)
+	for i, header := range chain {
+		if verify[i] {
+			seals, indices = append(seals, header), append(indices, i)
+		}
+	}
+	abort, results := verifyNoncesFromHeaders(hc.engine, newBatchChainReader(hc, chain), seals)
+	defer close(abort)
+
+	var (
+		sealFailed = len(chain)
+		sealErr    error
+	)
+	for range seals {
+		if r := <-results; !r.valid && indices[r.index] < sealFailed {
+			sealFailed, sealErr = indices[r.index], r.err
+		}
+	}
+	if sealErr != nil {
+		return sealFailed, sealErr
+	}
 	// All headers passed verification, import them into the database
 	for i, header := range chain {
 		// Short circuit insertion if shutting down
@@ -519,8 +552,8 @@ func (hc *HeaderChain) postChainEvents(events []interface{}) {
 // headerValidator implements HeaderValidator.
 type headerValidator struct {
 	config *ChainConfig
-	hc     *HeaderChain // Canonical header chain
-	Pow    pow.PoW      // Proof of work used for validating
+	hc     *HeaderChain     // Canonical header chain
+	engine consensus.Engine // Consensus engine used for validating
 }
 
 // ValidateHeader validates the given header and, depending on the pow arg,
@@ -535,5 +568,5 @@ func (v *headerValidator) ValidateHeader(header, parent *types.Header, checkPow
 	if v.hc.HasHeader(header.Hash()) {
 		return nil
 	}
-	return ValidateHeader(v.config, v.Pow, header, parent, checkPow, false)
+	return ValidateHeader(v.config, v.engine, v.hc, header, parent, checkPow, false)
 }
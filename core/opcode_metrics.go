@@ -0,0 +1,81 @@
+// Copyright 2016 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/ellaism/go-ellaism/core/vm"
+	"github.com/ellaism/go-ellaism/metrics"
+)
+
+// opcodeUsage is the gas used and the time taken by a number of executions.
+type opcodeUsage struct {
+	count   uint64
+	gas     uint64
+	elapsed time.Duration
+}
+
+// opcodeMetrics is a vm.OpcodeMeter aggregating the executions of every opcode
+// over the transactions of a block.
+//
+// The usage of an opcode calling into another contract is reported by the EVM
+// including the opcodes run by the callee. Those are subtracted, so that every
+// opcode is only accounted for the work it did itself.
+type opcodeMetrics struct {
+	ops   [256]opcodeUsage
+	inner []opcodeUsage // Usage of the opcodes run at each depth since the last opcode at the depth above
+}
+
+// newOpcodeMetrics returns an opcode meter for a single block.
+func newOpcodeMetrics() *opcodeMetrics {
+	return &opcodeMetrics{}
+}
+
+// MeterOpcode implements vm.OpcodeMeter, accounting a single execution of an
+// opcode at the given call depth.
+func (m *opcodeMetrics) MeterOpcode(op vm.OpCode, depth int, gas *big.Int, elapsed time.Duration) {
+	for len(m.inner) <= depth+1 {
+		m.inner = append(m.inner, opcodeUsage{})
+	}
+	// Opcodes of the callee, if any, all completed before the calling opcode
+	inner := m.inner[depth+1]
+	m.inner[depth+1] = opcodeUsage{}
+
+	m.inner[depth].gas += gas.Uint64()
+	m.inner[depth].elapsed += elapsed
+
+	usage := &m.ops[op]
+	usage.count++
+	if used := gas.Uint64(); used > inner.gas {
+		usage.gas += used - inner.gas
+	}
+	if elapsed > inner.elapsed {
+		usage.elapsed += elapsed - inner.elapsed
+	}
+}
+
+// report adds the usage of the opcodes executed in the block to the metrics
+// registry.
+func (m *opcodeMetrics) report() {
+	for op, usage := range m.ops {
+		if usage.count > 0 {
+			metrics.MarkOpcode(vm.OpCode(op).String(), usage.count, usage.gas, usage.elapsed)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2016 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/core/state"
+	"github.com/ellaism/go-ellaism/core/types"
+	"github.com/ellaism/go-ellaism/core/vm"
+	"github.com/ellaism/go-ellaism/crypto"
+	"github.com/ellaism/go-ellaism/ethdb"
+)
+
+// Tests that every executed opcode is metered once, and that the opcodes run
+// by a callee aren't accounted to the calling opcode as well.
+func TestOpcodeMetrics(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, db)
+
+	key, _ := crypto.GenerateKey()
+	sender := crypto.PubkeyToAddress(key.PublicKey)
+	statedb.AddBalance(sender, big.NewInt(1e18))
+
+	// The contract calls another one which sets a storage slot
+	contract := common.Address{0x0a}
+	statedb.SetCode(contract, common.Hex2Bytes("6000600060006000600060b061fffff15000"))
+	statedb.SetCode(common.BytesToAddress([]byte{0xb0}), common.Hex2Bytes("600160005500"))
+
+	config := MakeChainConfig()
+	header := &types.Header{
+		Number:     big.NewInt(1),
+		Difficulty: big.NewInt(1),
+		GasLimit:   big.NewInt(4712388),
+		Time:       big.NewInt(1),
+	}
+	tx, err := types.NewTransaction(0, contract, new(big.Int), big.NewInt(1000000), big.NewInt(1), nil).WithSigner(config.GetSigner(header.Number)).SignECDSA(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	meter := newOpcodeMetrics()
+	env := NewEnv(statedb, config, nil, tx, header)
+	env.SetOpcodeMeter(meter)
+	_, gas, err := ApplyMessage(env, tx, new(GasPool).AddGas(header.GasLimit))
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := map[vm.OpCode]uint64{vm.PUSH1: 8, vm.PUSH2: 1, vm.CALL: 1, vm.POP: 1, vm.SSTORE: 1, vm.STOP: 2}
+	var total uint64
+	for op, usage := range meter.ops {
+		if usage.count != counts[vm.OpCode(op)] {
+			t.Errorf("%v executions: have %d, want %d", vm.OpCode(op), usage.count, counts[vm.OpCode(op)])
+		}
+		total += usage.gas
+	}
+	if sstore := meter.ops[vm.SSTORE].gas; sstore != 20000 {
+		t.Errorf("SSTORE gas: have %d, want 20000", sstore)
+	}
+	if want := gas.Uint64() - 21000; total != want {
+		t.Errorf("metered gas mismatch: have %d, want %d", total, want)
+	}
+	meter.report()
+}
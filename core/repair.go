@@ -0,0 +1,227 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/core/types"
+	"github.com/ellaism/go-ellaism/crypto"
+	"github.com/ellaism/go-ellaism/logger"
+	"github.com/ellaism/go-ellaism/logger/glog"
+)
+
+const (
+	missingReportInterval = time.Minute // Time before missing data is reported again for the same block
+	maxMissingReports     = 1024        // Maximum number of blocks tracked as reported missing data
+)
+
+// missingData identifies the body or the receipts of a block.
+type missingData struct {
+	hash     common.Hash
+	receipts bool
+}
+
+// reportMissing notes that the body or the receipts of the known block with
+// the given hash were looked up but not found in the database. If the block is
+// canonical and the data should have been stored, a MissingBlockDataEvent is
+// posted, unless it was already posted recently. Missing bodies that can only
+// be empty are restored straight away.
+func (self *BlockChain) reportMissing(hash common.Hash, receipts bool) {
+	key, now := missingData{hash, receipts}, time.Now()
+
+	self.missingLock.Lock()
+	if self.missing == nil {
+		self.missing = make(map[missingData]time.Time)
+	}
+	if reported, ok := self.missing[key]; ok && now.Sub(reported) < missingReportInterval {
+		self.missingLock.Unlock()
+		return
+	}
+	if len(self.missing) >= maxMissingReports {
+		for key, reported := range self.missing {
+			if now.Sub(reported) >= missingReportInterval {
+				delete(self.missing, key)
+			}
+		}
+		if len(self.missing) >= maxMissingReports {
+			self.missingLock.Unlock()
+			return
+		}
+	}
+	self.missing[key] = now
+	self.missingLock.Unlock()
+
+	// Checking the chain takes its lock, which the caller may be holding
+	go self.checkMissing(hash, receipts)
+}
+
+// checkMissing posts a MissingBlockDataEvent for the body or the receipts of
+// the block with the given hash if they are still missing, the block is
+// canonical and the chain is synced past it.
+func (self *BlockChain) checkMissing(hash common.Hash, receipts bool) {
+	header := self.GetHeader(hash)
+	if header == nil {
+		return
+	}
+	number := header.Number.Uint64()
+	if number > self.CurrentFastBlock().NumberU64() || GetCanonicalHash(self.chainDb, number) != hash {
+		return
+	}
+	if !receipts {
+		if GetBody(self.chainDb, hash) != nil {
+			return
+		}
+		if header.TxHash == types.EmptyRootHash && header.UncleHash == types.EmptyUncleHash {
+			if err := self.RepairBody(hash, &types.Body{}); err != nil {
+				glog.V(logger.Error).Errorf("failed to restore empty body of block #%d [%x…]: %v", number, hash.Bytes()[:4], err)
+			}
+			return
+		}
+		glog.V(logger.Warn).Warnf("Body of canonical block #%d [%x…] missing from the database", number, hash.Bytes()[:4])
+	} else {
+		if GetBlockReceipts(self.chainDb, hash) != nil || header.ReceiptHash == types.EmptyRootHash {
+			return
+		}
+		glog.V(logger.Warn).Warnf("Receipts of canonical block #%d [%x…] missing from the database", number, hash.Bytes()[:4])
+	}
+	self.eventMux.Post(MissingBlockDataEvent{Header: header, Receipts: receipts})
+}
+
+// GetReceiptsByHash retrieves the receipts of the block with the given hash
+// from the database. Receipts found missing are reported for repair.
+func (self *BlockChain) GetReceiptsByHash(hash common.Hash) types.Receipts {
+	receipts := GetBlockReceipts(self.chainDb, hash)
+	if receipts == nil && self.HasHeader(hash) {
+		self.reportMissing(hash, true)
+	}
+	return receipts
+}
+
+// RepairBody restores the missing body of a known block, checking it against
+// the transaction and uncle roots of the block header. The transaction and
+// uncle lookups are rewritten too if the block is canonical.
+func (self *BlockChain) RepairBody(hash common.Hash, body *types.Body) error {
+	header := self.GetHeader(hash)
+	if header == nil {
+		return fmt.Errorf("unknown block %x", hash)
+	}
+	if txSha := types.DeriveSha(types.Transactions(body.Transactions)); txSha != header.TxHash {
+		return fmt.Errorf("transaction root mismatch: header %x, body %x", header.TxHash, txSha)
+	}
+	if uncleSha := types.CalcUncleHash(body.Uncles); uncleSha != header.UncleHash {
+		return fmt.Errorf("uncles hash mismatch: header %x, body %x", header.UncleHash, uncleSha)
+	}
+	if err := WriteBody(self.chainDb, hash, body); err != nil {
+		return err
+	}
+	if GetCanonicalHash(self.chainDb, header.Number.Uint64()) == hash {
+		block := types.NewBlockWithHeader(header).WithBody(body.Transactions, body.Uncles)
+		if err := WriteTransactions(self.chainDb, block); err != nil {
+			return err
+		}
+		if err := WriteUncleLookups(self.chainDb, block); err != nil {
+			return err
+		}
+	}
+	self.forgetMissing(hash, false)
+
+	glog.V(logger.Info).Infof("Repaired body of block #%d [%x…]", header.Number, hash.Bytes()[:4])
+	return nil
+}
+
+// RepairReceipts restores the missing receipts of a known block, checking them
+// against the receipt root of the block header. Only the consensus fields of
+// the receipts are needed, the others are derived from the block. The receipt
+// lookups and log blooms are rewritten too if the block is canonical.
+func (self *BlockChain) RepairReceipts(hash common.Hash, receipts types.Receipts) error {
+	block := self.GetBlock(hash)
+	if block == nil {
+		return fmt.Errorf("body of block %x unavailable", hash)
+	}
+	if len(receipts) != len(block.Transactions()) {
+		return fmt.Errorf("receipt count mismatch: %d for %d transactions", len(receipts), len(block.Transactions()))
+	}
+	if receiptSha := types.DeriveSha(receipts); receiptSha != block.ReceiptHash() {
+		return fmt.Errorf("receipt root mismatch: header %x, receipts %x", block.ReceiptHash(), receiptSha)
+	}
+	SetReceiptsData(self.config, block, receipts)
+
+	if err := WriteBlockReceipts(self.chainDb, hash, receipts); err != nil {
+		return err
+	}
+	if GetCanonicalHash(self.chainDb, block.NumberU64()) == hash {
+		if err := WriteReceipts(self.chainDb, receipts); err != nil {
+			return err
+		}
+		if err := WriteMipmapBloom(self.chainDb, block.NumberU64(), receipts); err != nil {
+			return err
+		}
+	}
+	self.forgetMissing(hash, true)
+
+	glog.V(logger.Info).Infof("Repaired receipts of block #%d [%x…]", block.NumberU64(), hash.Bytes()[:4])
+	return nil
+}
+
+// forgetMissing drops the report of missing data once repaired, so that any
+// further loss is reported straight away.
+func (self *BlockChain) forgetMissing(hash common.Hash, receipts bool) {
+	self.missingLock.Lock()
+	defer self.missingLock.Unlock()
+
+	delete(self.missing, missingData{hash, receipts})
+}
+
+// SetReceiptsData computes the fields of the receipts of the given block which
+// aren't part of the consensus encoding of receipts: the transaction hashes,
+// the contract addresses, the gas used by each transaction and the positional
+// fields of the logs.
+func SetReceiptsData(config *ChainConfig, block *types.Block, receipts types.Receipts) {
+	signer := config.GetSigner(block.Number())
+
+	transactions, logIndex := block.Transactions(), uint(0)
+	for j := 0; j < len(receipts); j++ {
+		// The transaction hash can be retrieved from the transaction itself
+		receipts[j].TxHash = transactions[j].Hash()
+		tx := transactions[j]
+		from, _ := types.Sender(signer, tx)
+
+		// The contract address can be derived from the transaction itself
+		if MessageCreatesContract(transactions[j]) {
+			receipts[j].ContractAddress = crypto.CreateAddress(from, tx.Nonce())
+		}
+		// The used gas can be calculated based on previous receipts
+		if j == 0 {
+			receipts[j].GasUsed = new(big.Int).Set(receipts[j].CumulativeGasUsed)
+		} else {
+			receipts[j].GasUsed = new(big.Int).Sub(receipts[j].CumulativeGasUsed, receipts[j-1].CumulativeGasUsed)
+		}
+		// The derived log fields can simply be set from the block and transaction
+		for k := 0; k < len(receipts[j].Logs); k++ {
+			receipts[j].Logs[k].BlockNumber = block.NumberU64()
+			receipts[j].Logs[k].BlockHash = block.Hash()
+			receipts[j].Logs[k].TxHash = receipts[j].TxHash
+			receipts[j].Logs[k].TxIndex = uint(j)
+			receipts[j].Logs[k].Index = logIndex
+			logIndex++
+		}
+	}
+}
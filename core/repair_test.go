@@ -0,0 +1,142 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/core/types"
+	"github.com/ellaism/go-ellaism/crypto"
+	"github.com/ellaism/go-ellaism/ethdb"
+	"github.com/ellaism/go-ellaism/event"
+	"github.com/ellaism/go-ellaism/rlp"
+)
+
+// Tests that bodies and receipts found missing are reported, and that they are
+// restored once refetched, unless they don't match the block.
+func TestRepairMissingData(t *testing.T) {
+	key, _ := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	addr := crypto.PubkeyToAddress(key.PublicKey)
+
+	db, _ := ethdb.NewMemDatabase()
+	genesis := WriteGenesisBlockForTesting(db, GenesisAccount{addr, big.NewInt(1e18)})
+	config := MakeDiehardChainConfig()
+	signer := types.NewChainIdSigner(big.NewInt(63))
+
+	chain, _ := GenerateChain(config, genesis, db, 5, func(i int, b *BlockGen) {
+		if i == 1 {
+			return // Leave a block empty
+		}
+		tx, err := types.NewTransaction(b.TxNonce(addr), common.Address{0xaa}, big.NewInt(1000), TxGas, big.NewInt(1), nil).WithSigner(signer).SignECDSA(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b.AddTx(tx)
+	})
+	blockchain, err := NewBlockChain(db, config, FakePow{}, new(event.TypeMux))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i, err := blockchain.InsertChain(chain); err != nil {
+		t.Fatalf("failed to insert block %d: %v", i, err)
+	}
+	full, empty := chain[0], chain[1]
+	receipts := GetBlockReceipts(db, full.Hash())
+
+	// Lose the bodies of both blocks and the receipts of one, then reopen the chain,
+	// whose head is still healthy
+	DeleteBody(db, full.Hash())
+	DeleteBody(db, empty.Hash())
+	DeleteBlockReceipts(db, full.Hash())
+	DeleteTransaction(db, full.Transactions()[0].Hash())
+	DeleteReceipt(db, full.Transactions()[0].Hash())
+
+	mux := new(event.TypeMux)
+	sub := mux.Subscribe(MissingBlockDataEvent{})
+	defer sub.Unsubscribe()
+	if blockchain, err = NewBlockChain(db, config, FakePow{}, mux); err != nil {
+		t.Fatal(err)
+	}
+	expectMissing := func(block *types.Block, receipts bool) {
+		select {
+		case ev := <-sub.Chan():
+			missing := ev.Data.(MissingBlockDataEvent)
+			if missing.Header.Hash() != block.Hash() || missing.Receipts != receipts {
+				t.Fatalf("missing data mismatch: have block %x receipts %v, want block %x receipts %v", missing.Header.Hash(), missing.Receipts, block.Hash(), receipts)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("missing data of block #%d not reported", block.NumberU64())
+		}
+	}
+
+	// The missing body is reported, the empty one restored straight away
+	if blockchain.GetBlock(full.Hash()) != nil {
+		t.Fatal("lost block returned")
+	}
+	expectMissing(full, false)
+
+	blockchain.GetBlock(empty.Hash())
+	for i := 0; GetBody(db, empty.Hash()) == nil; i++ {
+		if i == 100 {
+			t.Fatal("empty body not restored")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Only the genuine body is accepted back
+	if err := blockchain.RepairBody(full.Hash(), chain[2].Body()); err == nil {
+		t.Error("repaired body with the body of another block")
+	}
+	if err := blockchain.RepairBody(full.Hash(), full.Body()); err != nil {
+		t.Fatalf("failed to repair body: %v", err)
+	}
+	if blockchain.GetBlock(full.Hash()) == nil {
+		t.Error("repaired block not returned")
+	}
+	if tx, hash, _, _ := GetTransaction(db, full.Transactions()[0].Hash()); tx == nil || hash != full.Hash() {
+		t.Error("transaction lookup not restored")
+	}
+
+	// The missing receipts are reported and restored from their consensus fields
+	if blockchain.GetReceiptsByHash(full.Hash()) != nil {
+		t.Fatal("lost receipts returned")
+	}
+	expectMissing(full, true)
+
+	enc, _ := rlp.EncodeToBytes(receipts[0])
+	fetched := new(types.Receipt)
+	if err := rlp.DecodeBytes(enc, fetched); err != nil {
+		t.Fatal(err)
+	}
+	wrong := *fetched
+	wrong.CumulativeGasUsed = new(big.Int).Add(fetched.CumulativeGasUsed, common.Big1)
+	if err := blockchain.RepairReceipts(full.Hash(), types.Receipts{&wrong}); err == nil {
+		t.Error("repaired receipts with altered receipts")
+	}
+	if err := blockchain.RepairReceipts(full.Hash(), types.Receipts{fetched}); err != nil {
+		t.Fatalf("failed to repair receipts: %v", err)
+	}
+	if restored := blockchain.GetReceiptsByHash(full.Hash()); len(restored) != 1 || restored[0].TxHash != receipts[0].TxHash || restored[0].GasUsed.Cmp(receipts[0].GasUsed) != 0 {
+		t.Errorf("restored receipts mismatch: have %v, want %v", restored, receipts)
+	}
+	if receipt := GetReceipt(db, full.Transactions()[0].Hash()); receipt == nil {
+		t.Error("receipt lookup not restored")
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+
+	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/core/types"
+	"github.com/ellaism/go-ellaism/rlp"
+)
+
+// ReplayResult is the outcome of re-executing an imported block on top of the
+// state of its parent. Mismatches lists every difference between the results
+// of the execution and the values stored in the header and the database; it
+// is empty if the block replayed consistently.
+type ReplayResult struct {
+	Number     uint64      `json:"number"`
+	Hash       common.Hash `json:"hash"`
+	GasUsed    *big.Int    `json:"gasUsed"`
+	Root       common.Hash `json:"root"`
+	Mismatches []string    `json:"mismatches"`
+}
+
+// ReplayBlock re-executes the already imported block with the given hash from
+// the state of its parent and compares the resulting gas usage, logs bloom,
+// receipts and state root against the values in the block header and the
+// receipts stored for the block. Differences hint at a corrupted database or
+// nondeterministic block processing. The chain itself is left untouched.
+func (self *BlockChain) ReplayBlock(hash common.Hash) (*ReplayResult, error) {
+	block := self.GetBlock(hash)
+	if block == nil {
+		return nil, fmt.Errorf("block %x not found", hash)
+	}
+	parent := self.GetBlock(block.ParentHash())
+	if parent == nil {
+		return nil, ParentError(block.ParentHash())
+	}
+	statedb, err := self.StateAt(parent.Root())
+	if err != nil {
+		return nil, fmt.Errorf("state of parent block #%d [%x…] unavailable: %v", parent.NumberU64(), parent.Hash().Bytes()[:4], err)
+	}
+	receipts, _, usedGas, err := self.Processor().Process(block, statedb)
+	if err != nil {
+		return nil, fmt.Errorf("block #%d [%x…] failed to process: %v", block.NumberU64(), hash.Bytes()[:4], err)
+	}
+	result := &ReplayResult{
+		Number:     block.NumberU64(),
+		Hash:       hash,
+		GasUsed:    usedGas,
+		Root:       statedb.IntermediateRoot(self.config.IsEIP161(block.Number())),
+		Mismatches: []string{},
+	}
+	mismatch := func(format string, args ...interface{}) {
+		result.Mismatches = append(result.Mismatches, fmt.Sprintf(format, args...))
+	}
+
+	// Check the results against the header
+	header := block.Header()
+	if usedGas.Cmp(header.GasUsed) != 0 {
+		mismatch("gas used: header %v, replayed %v", header.GasUsed, usedGas)
+	}
+	if bloom := types.CreateBloom(receipts); bloom != header.Bloom {
+		mismatch("logs bloom: header %x, replayed %x", header.Bloom, bloom)
+	}
+	if receiptSha := types.DeriveSha(receipts); receiptSha != header.ReceiptHash {
+		mismatch("receipt root: header %x, replayed %x", header.ReceiptHash, receiptSha)
+	}
+	if result.Root != header.Root {
+		mismatch("state root: header %x, replayed %x", header.Root, result.Root)
+	}
+
+	// Check the results against the receipts stored during the import
+	stored := GetBlockReceipts(self.chainDb, hash)
+	if len(stored) != len(receipts) {
+		mismatch("receipt count: stored %d, replayed %d", len(stored), len(receipts))
+		return result, nil
+	}
+	for i, receipt := range receipts {
+		have, _ := rlp.EncodeToBytes(stored[i])
+		want, _ := rlp.EncodeToBytes(receipt)
+		if !bytes.Equal(have, want) {
+			mismatch("receipt %d: stored %v, replayed %v", i, stored[i], receipt)
+			continue
+		}
+		if stored[i].TxHash != receipt.TxHash || stored[i].ContractAddress != receipt.ContractAddress || stored[i].GasUsed.Cmp(receipt.GasUsed) != 0 {
+			mismatch("receipt %d: stored tx %x, contract %x, gas %v; replayed tx %x, contract %x, gas %v", i,
+				stored[i].TxHash, stored[i].ContractAddress, stored[i].GasUsed, receipt.TxHash, receipt.ContractAddress, receipt.GasUsed)
+		}
+	}
+	return result, nil
+}
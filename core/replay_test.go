@@ -0,0 +1,87 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/core/types"
+	"github.com/ellaism/go-ellaism/crypto"
+	"github.com/ellaism/go-ellaism/ethdb"
+	"github.com/ellaism/go-ellaism/event"
+)
+
+// Tests that imported blocks replay consistently and that corrupted receipts
+// are reported.
+func TestReplayBlock(t *testing.T) {
+	key, _ := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	addr := crypto.PubkeyToAddress(key.PublicKey)
+
+	db, _ := ethdb.NewMemDatabase()
+	genesis := WriteGenesisBlockForTesting(db, GenesisAccount{addr, big.NewInt(1e18)})
+	config := MakeDiehardChainConfig()
+	signer := types.NewChainIdSigner(big.NewInt(63))
+
+	chain, _ := GenerateChain(config, genesis, db, 3, func(i int, b *BlockGen) {
+		tx, err := types.NewTransaction(b.TxNonce(addr), common.Address{0xaa}, big.NewInt(1000), TxGas, big.NewInt(1), nil).WithSigner(signer).SignECDSA(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b.AddTx(tx)
+	})
+	blockchain, err := NewBlockChain(db, config, FakePow{}, new(event.TypeMux))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i, err := blockchain.InsertChain(chain); err != nil {
+		t.Fatalf("failed to insert block %d: %v", i, err)
+	}
+
+	for _, block := range chain {
+		result, err := blockchain.ReplayBlock(block.Hash())
+		if err != nil {
+			t.Fatalf("block #%d: replay failed: %v", block.NumberU64(), err)
+		}
+		if len(result.Mismatches) != 0 {
+			t.Errorf("block #%d: unexpected mismatches: %v", block.NumberU64(), result.Mismatches)
+		}
+		if result.Root != block.Root() {
+			t.Errorf("block #%d: root mismatch: have %x, want %x", block.NumberU64(), result.Root, block.Root())
+		}
+	}
+
+	// Corrupt the stored receipts of a block and check the replay notices
+	block := chain[1]
+	receipts := GetBlockReceipts(db, block.Hash())
+	receipts[0].CumulativeGasUsed = new(big.Int).Add(receipts[0].CumulativeGasUsed, common.Big1)
+	if err := WriteBlockReceipts(db, block.Hash(), receipts); err != nil {
+		t.Fatal(err)
+	}
+	result, err := blockchain.ReplayBlock(block.Hash())
+	if err != nil {
+		t.Fatalf("replay failed: %v", err)
+	}
+	if len(result.Mismatches) != 1 {
+		t.Errorf("mismatch count: have %d, want 1: %v", len(result.Mismatches), result.Mismatches)
+	}
+
+	if _, err := blockchain.ReplayBlock(common.Hash{0x01}); err == nil {
+		t.Error("expected error replaying unknown block")
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"fmt"
+
+	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/core/state"
+	"github.com/ellaism/go-ellaism/core/types"
+	"github.com/ellaism/go-ellaism/crypto"
+)
+
+// stateCheckEntries is the number of state entries walked to check that the
+// state of the head block is intact, bounding the time the check takes at
+// startup.
+const stateCheckEntries = 10000
+
+// checkState checks that the state with the given root is stored and intact,
+// as far as a bounded walk from its root can tell: the root node must hash to
+// the root and the first entries of the state must all be stored.
+func (self *BlockChain) checkState(root common.Hash) error {
+	if root == (common.Hash{}) || root == types.EmptyRootHash {
+		return nil
+	}
+	blob, err := self.chainDb.Get(root.Bytes())
+	if err != nil {
+		return fmt.Errorf("state root %x missing", root)
+	}
+	if crypto.Keccak256Hash(blob) != root {
+		return fmt.Errorf("state root %x corrupt", root)
+	}
+	statedb, err := state.New(root, self.chainDb)
+	if err != nil {
+		return err
+	}
+	it := state.NewNodeIterator(statedb)
+	for i := 0; i < stateCheckEntries && it.Next(); i++ {
+	}
+	return it.Error
+}
+
+// lastIntactState returns the header of the newest ancestor of the given block
+// whose state is intact, or the genesis header if there is none.
+func (self *BlockChain) lastIntactState(block *types.Block) *types.Header {
+	header := block.Header()
+	for header.Number.Sign() > 0 {
+		parent := self.GetHeader(header.ParentHash)
+		if parent == nil {
+			break
+		}
+		if header = parent; self.checkState(header.Root) == nil {
+			return header
+		}
+	}
+	return self.genesisBlock.Header()
+}
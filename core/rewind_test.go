@@ -0,0 +1,80 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/core/types"
+	"github.com/ellaism/go-ellaism/crypto"
+	"github.com/ellaism/go-ellaism/ethdb"
+	"github.com/ellaism/go-ellaism/event"
+)
+
+// Tests that a chain whose head state is missing or corrupt is rewound on
+// startup to the newest block with an intact state.
+func TestRewindToIntactState(t *testing.T) {
+	key, _ := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	addr := crypto.PubkeyToAddress(key.PublicKey)
+
+	db, _ := ethdb.NewMemDatabase()
+	genesis := WriteGenesisBlockForTesting(db, GenesisAccount{addr, big.NewInt(1e18)})
+	config := MakeDiehardChainConfig()
+	signer := types.NewChainIdSigner(big.NewInt(63))
+
+	chain, _ := GenerateChain(config, genesis, db, 10, func(i int, b *BlockGen) {
+		tx, err := types.NewTransaction(b.TxNonce(addr), common.Address{0xaa}, big.NewInt(1000), TxGas, big.NewInt(1), nil).WithSigner(signer).SignECDSA(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b.AddTx(tx)
+	})
+	blockchain, err := NewBlockChain(db, config, FakePow{}, new(event.TypeMux))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := blockchain.InsertChain(chain); err != nil {
+		t.Fatal(err)
+	}
+	blockchain.Stop()
+
+	// Corrupt the state root of the head block and delete the one of its parent
+	db.Put(chain[9].Root().Bytes(), []byte{0x01})
+	db.Delete(chain[8].Root().Bytes())
+
+	blockchain, err = NewBlockChain(db, config, FakePow{}, new(event.TypeMux))
+	if err != nil {
+		t.Fatalf("failed to recover: %v", err)
+	}
+	defer blockchain.Stop()
+
+	if head := blockchain.CurrentBlock(); head.Hash() != chain[7].Hash() {
+		t.Errorf("head block mismatch: have #%d, want #%d", head.NumberU64(), chain[7].NumberU64())
+	}
+	if head := blockchain.CurrentHeader(); head.Hash() != chain[7].Hash() {
+		t.Errorf("head header mismatch: have #%d, want #%d", head.Number, chain[7].NumberU64())
+	}
+	// The rewound blocks can be imported again
+	if _, err := blockchain.InsertChain(chain[8:]); err != nil {
+		t.Fatalf("failed to reimport rewound blocks: %v", err)
+	}
+	if head := blockchain.CurrentBlock(); head.Hash() != chain[9].Hash() {
+		t.Errorf("reimported head mismatch: have #%d, want #%d", head.NumberU64(), chain[9].NumberU64())
+	}
+}
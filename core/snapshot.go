@@ -0,0 +1,330 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/core/state"
+	"github.com/ellaism/go-ellaism/core/types"
+	"github.com/ellaism/go-ellaism/crypto"
+	"github.com/ellaism/go-ellaism/logger"
+	"github.com/ellaism/go-ellaism/logger/glog"
+	"github.com/ellaism/go-ellaism/rlp"
+)
+
+// SnapshotVersion is the version of the snapshot format written by ExportSnapshot.
+const SnapshotVersion = 1
+
+// MinSnapshotBlocks is the least number of blocks a snapshot holds, so that the
+// head block can be checked against its parent when the chain is loaded.
+const MinSnapshotBlocks = 2
+
+// DefaultSnapshotBlocks is the default number of recent blocks in a snapshot,
+// enough for the BLOCKHASH opcode to resolve every block it may refer to.
+const DefaultSnapshotBlocks = 256
+
+var (
+	errSnapshotVersion  = errors.New("unsupported snapshot version")
+	errSnapshotNotFresh = errors.New("chain already holds blocks beyond genesis")
+)
+
+// SnapshotHeader opens a snapshot, identifying the head block whose state the
+// snapshot holds.
+type SnapshotHeader struct {
+	Version uint64
+	Genesis common.Hash // Hash of the genesis block of the chain
+	Number  uint64      // Number of the head block
+	Hash    common.Hash // Hash of the head block
+	Root    common.Hash // State root of the head block
+	Blocks  uint64      // Number of blocks in the snapshot, up to the head block
+}
+
+// snapshotBlock is a block of a snapshot, with its receipts and total difficulty.
+type snapshotBlock struct {
+	Header       *types.Header
+	Transactions []*types.Transaction
+	Uncles       []*types.Header
+	Receipts     []*types.ReceiptForStorage
+	Td           *big.Int
+}
+
+// ExportSnapshot writes a snapshot of the current head block to the given
+// writer: a SnapshotHeader, the given number of most recent blocks up to the
+// head with their receipts, then every node of the state trie of the head
+// block and the code of every contract, until the end of the stream.
+func (self *BlockChain) ExportSnapshot(w io.Writer, blocks uint64) (*SnapshotHeader, error) {
+	head := self.CurrentBlock()
+	if head.NumberU64() == 0 {
+		return nil, errors.New("no blocks beyond genesis")
+	}
+	if blocks < MinSnapshotBlocks {
+		return nil, fmt.Errorf("snapshot needs at least %d blocks", MinSnapshotBlocks)
+	}
+	if blocks > head.NumberU64() {
+		blocks = head.NumberU64() // The genesis block is never exported, the importing chain has it
+	}
+	header := &SnapshotHeader{
+		Version: SnapshotVersion,
+		Genesis: self.Genesis().Hash(),
+		Number:  head.NumberU64(),
+		Hash:    head.Hash(),
+		Root:    head.Root(),
+		Blocks:  blocks,
+	}
+	if err := rlp.Encode(w, header); err != nil {
+		return nil, err
+	}
+
+	// Write the recent blocks, oldest first
+	for n := head.NumberU64() - blocks + 1; n <= head.NumberU64(); n++ {
+		hash := GetCanonicalHash(self.chainDb, n)
+		block := GetBlock(self.chainDb, hash)
+		if block == nil {
+			return nil, fmt.Errorf("block #%d not found", n)
+		}
+		receipts := GetBlockReceipts(self.chainDb, hash)
+		if len(receipts) != len(block.Transactions()) {
+			return nil, fmt.Errorf("receipts of block #%d not found", n)
+		}
+		td := GetTd(self.chainDb, hash)
+		if td == nil {
+			return nil, fmt.Errorf("total difficulty of block #%d not found", n)
+		}
+		stored := make([]*types.ReceiptForStorage, len(receipts))
+		for i, receipt := range receipts {
+			stored[i] = (*types.ReceiptForStorage)(receipt)
+		}
+		if err := rlp.Encode(w, &snapshotBlock{block.Header(), block.Transactions(), block.Uncles(), stored, td}); err != nil {
+			return nil, err
+		}
+	}
+
+	// Write the state entries, the standalone trie nodes and contract code
+	statedb, err := state.New(head.Root(), self.chainDb)
+	if err != nil {
+		return nil, err
+	}
+	entries := 0
+	it := state.NewNodeIterator(statedb)
+	for it.Next() {
+		if it.Hash == (common.Hash{}) {
+			continue // Embedded in its parent node
+		}
+		data, err := self.chainDb.Get(it.Hash.Bytes())
+		if err != nil {
+			return nil, fmt.Errorf("state entry %x: %v", it.Hash, err)
+		}
+		if err := rlp.Encode(w, data); err != nil {
+			return nil, err
+		}
+		if entries++; entries%100000 == 0 {
+			glog.V(logger.Info).Infof("exported %d state entries", entries)
+		}
+	}
+	if it.Error != nil {
+		return nil, it.Error
+	}
+	glog.V(logger.Info).Infof("exported snapshot of block #%d [%x…]: %d blocks, %d state entries", header.Number, header.Hash.Bytes()[:4], blocks, entries)
+	return header, nil
+}
+
+// ImportSnapshot loads a snapshot written by ExportSnapshot into a chain holding
+// nothing beyond its genesis block, and makes the snapshot head block the head
+// of the chain. If trusted isn't empty, the head block must have that hash.
+//
+// Every block is checked for its proof of work, its link to the previous one,
+// its transaction, uncle and receipt roots and its total difficulty. Every state
+// entry is stored under its own hash, and the state is then walked from the
+// state root of the head block to make sure it is complete. The head of the
+// chain is only moved once all checks passed.
+func (self *BlockChain) ImportSnapshot(r io.Reader, trusted common.Hash) (*SnapshotHeader, error) {
+	if self.CurrentHeader().Number.Sign() > 0 || self.CurrentFastBlock().NumberU64() > 0 {
+		return nil, errSnapshotNotFresh
+	}
+	stream := rlp.NewStream(r, 0)
+
+	header := new(SnapshotHeader)
+	if err := stream.Decode(header); err != nil {
+		return nil, fmt.Errorf("snapshot header: %v", err)
+	}
+	switch {
+	case header.Version != SnapshotVersion:
+		return nil, fmt.Errorf("%v: %d", errSnapshotVersion, header.Version)
+	case header.Genesis != self.Genesis().Hash():
+		return nil, fmt.Errorf("genesis mismatch: chain %x, snapshot %x", self.Genesis().Hash(), header.Genesis)
+	case trusted != (common.Hash{}) && header.Hash != trusted:
+		return nil, fmt.Errorf("head block mismatch: trusted %x, snapshot %x", trusted, header.Hash)
+	case header.Blocks == 0 || header.Blocks > header.Number || header.Blocks < MinSnapshotBlocks && header.Blocks < header.Number:
+		return nil, fmt.Errorf("invalid block count %d for head block #%d", header.Blocks, header.Number)
+	}
+
+	// Check and store the blocks, linking them to the genesis block if they start there
+	first := header.Number - header.Blocks + 1
+
+	var last *types.Block
+	if first == 1 {
+		last = self.Genesis()
+	}
+	for n := first; n <= header.Number; n++ {
+		stored := new(snapshotBlock)
+		if err := stream.Decode(stored); err != nil {
+			return nil, fmt.Errorf("block #%d: %v", n, err)
+		}
+		block := types.NewBlockWithHeader(stored.Header).WithBody(stored.Transactions, stored.Uncles)
+		receipts := make(types.Receipts, len(stored.Receipts))
+		for i, receipt := range stored.Receipts {
+			receipts[i] = (*types.Receipt)(receipt)
+		}
+		if err := self.checkSnapshotBlock(n, block, receipts, stored.Td, last); err != nil {
+			return nil, fmt.Errorf("block #%d: %v", n, err)
+		}
+		if err := self.writeSnapshotBlock(block, receipts, stored.Td); err != nil {
+			return nil, err
+		}
+		last = block
+	}
+	if last.Hash() != header.Hash || last.Root() != header.Root {
+		return nil, fmt.Errorf("head block mismatch: header %x with root %x, blocks end at %x with root %x", header.Hash, header.Root, last.Hash(), last.Root())
+	}
+
+	// Store the state entries under their hashes until the end of the stream
+	entries := 0
+	batch := self.chainDb.NewBatch()
+	for {
+		data, err := stream.Bytes()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("state entry %d: %v", entries, err)
+		}
+		if err := batch.Put(crypto.Keccak256(data), data); err != nil {
+			return nil, err
+		}
+		if entries++; entries%100000 == 0 {
+			if err := batch.Write(); err != nil {
+				return nil, err
+			}
+			batch = self.chainDb.NewBatch()
+			glog.V(logger.Info).Infof("imported %d state entries", entries)
+		}
+	}
+	if err := batch.Write(); err != nil {
+		return nil, err
+	}
+
+	// Make sure the state of the head block is complete
+	statedb, err := state.New(header.Root, self.chainDb)
+	if err != nil {
+		return nil, fmt.Errorf("state root %x: %v", header.Root, err)
+	}
+	it := state.NewNodeIterator(statedb)
+	for it.Next() {
+	}
+	if it.Error != nil {
+		return nil, fmt.Errorf("incomplete state: %v", it.Error)
+	}
+
+	// All checks passed, move the chain onto the snapshot head block
+	if err := WriteHeadHeaderHash(self.chainDb, header.Hash); err != nil {
+		return nil, err
+	}
+	if err := WriteHeadFastBlockHash(self.chainDb, header.Hash); err != nil {
+		return nil, err
+	}
+	if err := WriteHeadBlockHash(self.chainDb, header.Hash); err != nil {
+		return nil, err
+	}
+	if err := self.LoadLastState(false); err != nil {
+		return nil, err
+	}
+	if self.CurrentBlock().Hash() != header.Hash {
+		return nil, fmt.Errorf("chain failed to load snapshot head block #%d [%x…]", header.Number, header.Hash.Bytes()[:4])
+	}
+	glog.V(logger.Info).Infof("imported snapshot of block #%d [%x…]: %d blocks, %d state entries", header.Number, header.Hash.Bytes()[:4], header.Blocks, entries)
+	return header, nil
+}
+
+// checkSnapshotBlock checks a block of a snapshot with the given number against
+// its receipts and total difficulty, and against the previous block of the
+// snapshot, if any.
+func (self *BlockChain) checkSnapshotBlock(number uint64, block *types.Block, receipts types.Receipts, td *big.Int, prev *types.Block) error {
+	if block.NumberU64() != number {
+		return fmt.Errorf("number mismatch: have %v", block.Number())
+	}
+	if prev != nil && block.ParentHash() != prev.Hash() {
+		return fmt.Errorf("parent hash mismatch: have %x, want %x", block.ParentHash(), prev.Hash())
+	}
+	if err := self.engine.VerifySeal(self, block.Header()); err != nil {
+		return err
+	}
+	if txSha := types.DeriveSha(block.Transactions()); txSha != block.TxHash() {
+		return fmt.Errorf("transaction root mismatch: header %x, body %x", block.TxHash(), txSha)
+	}
+	if uncleSha := types.CalcUncleHash(block.Uncles()); uncleSha != block.UncleHash() {
+		return fmt.Errorf("uncles hash mismatch: header %x, body %x", block.UncleHash(), uncleSha)
+	}
+	if len(receipts) != len(block.Transactions()) {
+		return fmt.Errorf("receipt count mismatch: %d for %d transactions", len(receipts), len(block.Transactions()))
+	}
+	if receiptSha := types.DeriveSha(receipts); receiptSha != block.ReceiptHash() {
+		return fmt.Errorf("receipt root mismatch: header %x, receipts %x", block.ReceiptHash(), receiptSha)
+	}
+	if td == nil || td.Cmp(block.Difficulty()) < 0 {
+		return fmt.Errorf("invalid total difficulty %v", td)
+	}
+	if prev != nil {
+		prevTd := GetTd(self.chainDb, prev.Hash())
+		if want := new(big.Int).Add(prevTd, block.Difficulty()); td.Cmp(want) != 0 {
+			return fmt.Errorf("total difficulty mismatch: have %v, want %v", td, want)
+		}
+	}
+	return nil
+}
+
+// writeSnapshotBlock stores a checked block of a snapshot as a canonical block,
+// along with its receipts, total difficulty and lookups.
+func (self *BlockChain) writeSnapshotBlock(block *types.Block, receipts types.Receipts, td *big.Int) error {
+	SetReceiptsData(self.config, block, receipts)
+
+	if err := WriteTd(self.chainDb, block.Hash(), td); err != nil {
+		return err
+	}
+	if err := WriteBlock(self.chainDb, block); err != nil {
+		return err
+	}
+	if err := WriteBlockReceipts(self.chainDb, block.Hash(), receipts); err != nil {
+		return err
+	}
+	if err := WriteCanonicalHash(self.chainDb, block.Hash(), block.NumberU64()); err != nil {
+		return err
+	}
+	if err := WriteTransactions(self.chainDb, block); err != nil {
+		return err
+	}
+	if err := WriteUncleLookups(self.chainDb, block); err != nil {
+		return err
+	}
+	if err := WriteReceipts(self.chainDb, receipts); err != nil {
+		return err
+	}
+	return WriteMipmapBloom(self.chainDb, block.NumberU64(), receipts)
+}
@@ -0,0 +1,143 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"io"
+	"math/big"
+	"testing"
+
+	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/core/types"
+	"github.com/ellaism/go-ellaism/crypto"
+	"github.com/ellaism/go-ellaism/ethdb"
+	"github.com/ellaism/go-ellaism/event"
+	"github.com/ellaism/go-ellaism/rlp"
+)
+
+// Tests that a snapshot exported from a chain loads into a fresh chain, which
+// can then carry on importing blocks, and that broken snapshots are rejected.
+func TestSnapshotExportImport(t *testing.T) {
+	key, _ := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	addr := crypto.PubkeyToAddress(key.PublicKey)
+	config := MakeDiehardChainConfig()
+	signer := types.NewChainIdSigner(big.NewInt(63))
+
+	// Build a chain creating a contract with some storage
+	generator := func(i int, b *BlockGen) {
+		var tx *types.Transaction
+		if i == 0 {
+			code := common.Hex2Bytes("600160005560016000f3") // Store 1 at slot 0 and deploy a single byte
+			tx = types.NewContractCreation(b.TxNonce(addr), new(big.Int), big.NewInt(100000), big.NewInt(1), code)
+		} else {
+			tx = types.NewTransaction(b.TxNonce(addr), common.Address{0xaa}, big.NewInt(1000), TxGas, big.NewInt(1), nil)
+		}
+		tx, err := tx.WithSigner(signer).SignECDSA(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b.AddTx(tx)
+	}
+	db, _ := ethdb.NewMemDatabase()
+	genesis := WriteGenesisBlockForTesting(db, GenesisAccount{addr, big.NewInt(1e18)})
+	chain, _ := GenerateChain(config, genesis, db, 6, generator)
+	blockchain, err := NewBlockChain(db, config, FakePow{}, new(event.TypeMux))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i, err := blockchain.InsertChain(chain[:5]); err != nil {
+		t.Fatalf("failed to insert block %d: %v", i, err)
+	}
+	head := blockchain.CurrentBlock()
+	contract := crypto.CreateAddress(addr, 0)
+
+	snapshot := new(bytes.Buffer)
+	header, err := blockchain.ExportSnapshot(snapshot, 3)
+	if err != nil {
+		t.Fatalf("failed to export snapshot: %v", err)
+	}
+	if header.Hash != head.Hash() || header.Blocks != 3 {
+		t.Fatalf("snapshot header mismatch: have %x with %d blocks, want %x with 3", header.Hash, header.Blocks, head.Hash())
+	}
+
+	newChain := func() *BlockChain {
+		db, _ := ethdb.NewMemDatabase()
+		WriteGenesisBlockForTesting(db, GenesisAccount{addr, big.NewInt(1e18)})
+		blockchain, err := NewBlockChain(db, config, FakePow{}, new(event.TypeMux))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return blockchain
+	}
+
+	// A snapshot of another head block than the trusted one is rejected
+	if _, err := newChain().ImportSnapshot(bytes.NewReader(snapshot.Bytes()), common.Hash{0x01}); err == nil {
+		t.Error("imported snapshot of untrusted head block")
+	}
+	// A snapshot missing part of the state is rejected, leaving the chain untouched
+	incomplete := newChain()
+	if _, err := incomplete.ImportSnapshot(bytes.NewReader(dropLastSnapshotEntry(t, snapshot.Bytes())), common.Hash{}); err == nil {
+		t.Error("imported snapshot with incomplete state")
+	}
+	if n := incomplete.CurrentBlock().NumberU64(); n != 0 {
+		t.Errorf("head block after failed import: have #%d, want #0", n)
+	}
+
+	// A full snapshot loads, and the chain carries on from its head block
+	imported := newChain()
+	if _, err := imported.ImportSnapshot(bytes.NewReader(snapshot.Bytes()), head.Hash()); err != nil {
+		t.Fatalf("failed to import snapshot: %v", err)
+	}
+	if imported.CurrentBlock().Hash() != head.Hash() {
+		t.Fatalf("head block mismatch: have %x, want %x", imported.CurrentBlock().Hash(), head.Hash())
+	}
+	statedb, err := imported.State()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statedb.GetCode(contract)) != 1 || statedb.GetState(contract, common.Hash{}) != common.BytesToHash([]byte{1}) {
+		t.Error("contract state not imported")
+	}
+	if receipts := imported.GetReceiptsByHash(head.Hash()); len(receipts) != 1 {
+		t.Errorf("head block receipts: have %d, want 1", len(receipts))
+	}
+	if i, err := imported.InsertChain(chain[5:]); err != nil {
+		t.Fatalf("failed to insert block %d after snapshot: %v", i, err)
+	}
+
+	// Snapshots only load into fresh chains
+	if _, err := imported.ImportSnapshot(bytes.NewReader(snapshot.Bytes()), common.Hash{}); err != errSnapshotNotFresh {
+		t.Errorf("import into chain with blocks: have error %v, want %v", err, errSnapshotNotFresh)
+	}
+}
+
+// dropLastSnapshotEntry returns the given snapshot without its last state entry.
+func dropLastSnapshotEntry(t *testing.T, snapshot []byte) []byte {
+	var items [][]byte
+	stream := rlp.NewStream(bytes.NewReader(snapshot), 0)
+	for {
+		item, err := stream.Raw()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		items = append(items, item)
+	}
+	return bytes.Join(items[:len(items)-1], nil)
+}
@@ -0,0 +1,81 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"sync"
+
+	"github.com/ellaism/go-ellaism/common"
+	"github.com/hashicorp/golang-lru/simplelru"
+)
+
+// codeCache is a least recently used cache of contract code by code hash. It is
+// bounded by the total size of the code it holds rather than by the number of
+// contracts, as contracts range from a few bytes to tens of kilobytes.
+type codeCache struct {
+	lru     *simplelru.LRU
+	size    int // Total size of the cached code
+	maxSize int // Total size of code kept at most
+	lock    sync.Mutex
+}
+
+// newCodeCache creates a code cache holding up to maxSize bytes of code.
+func newCodeCache(maxSize int) *codeCache {
+	c := &codeCache{maxSize: maxSize}
+	c.lru, _ = simplelru.NewLRU(maxSize, func(key, value interface{}) {
+		c.size -= len(value.(Code))
+	})
+	return c
+}
+
+// Get returns the code with the given hash, if cached.
+func (c *codeCache) Get(hash common.Hash) (Code, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if code, ok := c.lru.Get(hash); ok {
+		return code.(Code), true
+	}
+	return nil, false
+}
+
+// Add caches the code with the given hash, evicting the least recently used
+// code to make room for it. Code larger than the whole cache isn't cached.
+func (c *codeCache) Add(hash common.Hash, code Code) {
+	if len(code) > c.maxSize {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if cached, ok := c.lru.Peek(hash); ok {
+		c.size -= len(cached.(Code))
+	}
+	c.lru.Add(hash, code)
+	c.size += len(code)
+	for c.size > c.maxSize {
+		c.lru.RemoveOldest()
+	}
+}
+
+// Size returns the total size of the cached code.
+func (c *codeCache) Size() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.size
+}
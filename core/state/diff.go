@@ -0,0 +1,106 @@
+// Copyright 2016 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"reflect"
+
+	"github.com/ellaism/go-ellaism/common"
+)
+
+// DiffAccount is the state of a single account as reported in a state diff.
+// Only the storage slots touched by the diffed changes are included.
+type DiffAccount struct {
+	Balance string            `json:"balance"`
+	Nonce   uint64            `json:"nonce"`
+	Code    string            `json:"code"`
+	Storage map[string]string `json:"storage"`
+}
+
+// AccountDiff holds the state of an account before and after a set of changes.
+// Pre is nil if the account did not exist before and Post is nil if the account
+// was removed by the changes.
+type AccountDiff struct {
+	Pre  *DiffAccount `json:"pre"`
+	Post *DiffAccount `json:"post"`
+}
+
+// StateDiff maps the accounts modified by a set of changes to their diffs.
+type StateDiff map[common.Address]*AccountDiff
+
+// Diff returns the state diff of all changes journalled since the last
+// intermediate root or commit, i.e. usually those of a single transaction.
+// The pre state must be a copy of self taken right before the changes were
+// applied. Accounts which were touched but ended up unmodified are omitted.
+func (self *StateDB) Diff(pre *StateDB) StateDiff {
+	// Collect the touched accounts and storage slots from the journal
+	touched := make(map[common.Address]map[common.Hash]struct{})
+	touch := func(addr common.Address) map[common.Hash]struct{} {
+		if _, ok := touched[addr]; !ok {
+			touched[addr] = make(map[common.Hash]struct{})
+		}
+		return touched[addr]
+	}
+	for _, entry := range self.journal {
+		switch ch := entry.(type) {
+		case createObjectChange:
+			touch(*ch.account)
+		case resetObjectChange:
+			touch(ch.prev.address)
+		case suicideChange:
+			touch(*ch.account)
+		case balanceChange:
+			touch(*ch.account)
+		case nonceChange:
+			touch(*ch.account)
+		case codeChange:
+			touch(*ch.account)
+		case storageChange:
+			touch(*ch.account)[ch.key] = struct{}{}
+		}
+	}
+	diff := make(StateDiff)
+	for addr, keys := range touched {
+		account := &AccountDiff{
+			Pre:  pre.diffAccount(addr, keys),
+			Post: self.diffAccount(addr, keys),
+		}
+		if !reflect.DeepEqual(account.Pre, account.Post) {
+			diff[addr] = account
+		}
+	}
+	return diff
+}
+
+// diffAccount returns the current state of the given account including the
+// given storage slots, or nil if it doesn't exist or has suicided.
+func (self *StateDB) diffAccount(addr common.Address, keys map[common.Hash]struct{}) *DiffAccount {
+	stateObject := self.GetStateObject(addr)
+	if stateObject == nil || stateObject.suicided {
+		return nil
+	}
+	account := &DiffAccount{
+		Balance: stateObject.Balance().String(),
+		Nonce:   stateObject.Nonce(),
+		Code:    common.ToHex(stateObject.Code(self.db)),
+		Storage: make(map[string]string, len(keys)),
+	}
+	for key := range keys {
+		account.Storage[key.Hex()] = stateObject.GetState(self.db, key).Hex()
+	}
+	return account
+}
@@ -41,6 +41,9 @@ type (
 		prev        bool // whether account had already suicided
 		prevbalance *big.Int
 	}
+	touchChange struct {
+		account *common.Address
+	}
 
 	// Changes to individual accounts.
 	balanceChange struct {
@@ -87,6 +90,10 @@ func (ch suicideChange) undo(s *StateDB) {
 	}
 }
 
+func (ch touchChange) undo(s *StateDB) {
+	s.GetStateObject(*ch.account).touched = false
+}
+
 func (ch balanceChange) undo(s *StateDB) {
 	s.GetStateObject(*ch.account).setBalance(ch.prev)
 }
@@ -32,6 +32,9 @@ import (
 
 var emptyCodeHash = crypto.Keccak256(nil)
 
+// emptyRoot is the known root hash of an empty trie.
+var emptyRoot = common.HexToHash("56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421")
+
 type Code []byte
 
 func (self Code) String() string {
@@ -87,6 +90,7 @@ type StateObject struct {
 	// during the "update" phase of the state transition.
 	dirtyCode bool // true if the code was updated
 	suicided  bool
+	touched   bool // true if the balance was modified, even by a zero amount (EIP-161)
 	deleted   bool
 	onDirty   func(addr common.Address) // Callback method to mark a state object newly dirty
 }
@@ -166,6 +170,21 @@ func (self *StateObject) GetState(db trie.Database, key common.Hash) common.Hash
 	return value
 }
 
+// GetCommittedState returns a value in the account storage trie, ignoring the
+// changes not written to it yet, which are those of the current transaction
+// when the trie is updated after every transaction.
+func (self *StateObject) GetCommittedState(db trie.Database, key common.Hash) common.Hash {
+	var value common.Hash
+	if enc := self.getTrie(db).Get(key[:]); len(enc) > 0 {
+		_, content, _, err := rlp.Split(enc)
+		if err != nil {
+			self.setError(err)
+		}
+		value.SetBytes(content)
+	}
+	return value
+}
+
 // SetState updates a value in account storage.
 func (self *StateObject) SetState(db trie.Database, key, value common.Hash) {
 	self.db.journal = append(self.db.journal, storageChange{
@@ -221,7 +240,28 @@ func (self *StateObject) CommitTrie(db trie.Database, dbw trie.DatabaseWriter) e
 	return err
 }
 
+// empty returns whether the account is considered empty as defined by
+// EIP-161: it has no code, a zero balance and the starting nonce.
+func (c *StateObject) empty() bool {
+	return c.data.Nonce == StartingNonce && c.data.Balance.Sign() == 0 && bytes.Equal(c.data.CodeHash, emptyCodeHash)
+}
+
+// touch marks the account as touched, making it a candidate for deletion
+// if it is empty at the end of the transaction.
+func (c *StateObject) touch() {
+	if c.touched {
+		return
+	}
+	c.db.journal = append(c.db.journal, touchChange{account: &c.address})
+	c.touched = true
+	if c.onDirty != nil {
+		c.onDirty(c.Address())
+		c.onDirty = nil
+	}
+}
+
 func (c *StateObject) AddBalance(amount *big.Int) {
+	c.touch()
 	if amount.Sign() == 0 {
 		return
 	}
@@ -240,6 +280,7 @@ func (c *StateObject) AddBalance(amount *big.Int) {
 }
 
 func (c *StateObject) SubBalance(amount *big.Int) {
+	c.touch()
 	if amount.Sign() == 0 {
 		return
 	}
@@ -284,6 +325,7 @@ func (self *StateObject) deepCopy(db *StateDB, onDirty func(addr common.Address)
 	stateObject.dirtyStorage = self.dirtyStorage.Copy()
 	stateObject.cachedStorage = self.dirtyStorage.Copy()
 	stateObject.suicided = self.suicided
+	stateObject.touched = self.touched
 	stateObject.dirtyCode = self.dirtyCode
 	stateObject.deleted = self.deleted
 	return stateObject
@@ -306,9 +348,20 @@ func (self *StateObject) Code(db trie.Database) []byte {
 	if bytes.Equal(self.CodeHash(), emptyCodeHash) {
 		return nil
 	}
+	// Hot contracts are shared by many accounts and blocks, look them up in
+	// the code cache of the state before hitting the database.
+	hash := common.BytesToHash(self.CodeHash())
+	if self.db != nil {
+		if cached, ok := self.db.codeCache.Get(hash); ok {
+			self.code = cached
+			return self.code
+		}
+	}
 	code, err := db.Get(self.CodeHash())
 	if err != nil {
 		self.setError(fmt.Errorf("can't load code hash %x: %v", self.CodeHash(), err))
+	} else if self.db != nil {
+		self.db.codeCache.Add(hash, Code(code))
 	}
 	self.code = code
 	return code
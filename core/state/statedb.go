@@ -49,6 +49,9 @@ const (
 	// Number of codehash->size associations to keep.
 	codeSizeCacheSize = 100000
 
+	// Total bytes of contract code to keep cached by code hash.
+	codeCacheSize = 16 * 1024 * 1024
+
 	// Default StartingNonce for Morden Testnet
 	DefaultTestnetStartingNonce = uint64(1048576)
 )
@@ -68,6 +71,7 @@ type StateDB struct {
 	trie          *trie.SecureTrie
 	pastTries     []*trie.SecureTrie
 	codeSizeCache *lru.Cache
+	codeCache     *codeCache // Contract code by code hash, shared by the copies of the state
 
 	// This map holds 'live' objects, which will get modified while processing a state transition.
 	stateObjects      map[common.Address]*StateObject
@@ -97,10 +101,12 @@ func New(root common.Hash, db ethdb.Database) (*StateDB, error) {
 		return nil, err
 	}
 	csc, _ := lru.New(codeSizeCacheSize)
+	cc := newCodeCache(codeCacheSize)
 	return &StateDB{
 		db:                db,
 		trie:              tr,
 		codeSizeCache:     csc,
+		codeCache:         cc,
 		stateObjects:      make(map[common.Address]*StateObject),
 		stateObjectsDirty: make(map[common.Address]struct{}),
 		refund:            new(big.Int),
@@ -122,6 +128,7 @@ func (self *StateDB) New(root common.Hash) (*StateDB, error) {
 		db:                self.db,
 		trie:              tr,
 		codeSizeCache:     self.codeSizeCache,
+		codeCache:         self.codeCache,
 		stateObjects:      make(map[common.Address]*StateObject),
 		stateObjectsDirty: make(map[common.Address]struct{}),
 		refund:            new(big.Int),
@@ -216,6 +223,13 @@ func (self *StateDB) Exist(addr common.Address) bool {
 	return self.GetStateObject(addr) != nil
 }
 
+// Empty reports whether the given account does not exist or is empty
+// according to EIP-161 (no code, zero balance and the starting nonce).
+func (self *StateDB) Empty(addr common.Address) bool {
+	stateObject := self.GetStateObject(addr)
+	return stateObject == nil || stateObject.empty()
+}
+
 func (self *StateDB) GetAccount(addr common.Address) vm.Account {
 	return self.GetStateObject(addr)
 }
@@ -273,6 +287,33 @@ func (self *StateDB) GetCodeHash(addr common.Address) common.Hash {
 	return common.BytesToHash(stateObject.CodeHash())
 }
 
+// GetProof returns the Merkle proof of the account with the given address in
+// the account trie. It proves the absence of the account if it doesn't exist.
+func (self *StateDB) GetProof(a common.Address) []rlp.RawValue {
+	return self.trie.Prove(a[:])
+}
+
+// GetStorageProof returns the Merkle proof of the given storage slot of the
+// account in its storage trie, or nil if the account doesn't exist.
+func (self *StateDB) GetStorageProof(a common.Address, key common.Hash) []rlp.RawValue {
+	stateObject := self.GetStateObject(a)
+	if stateObject == nil {
+		return nil
+	}
+	return stateObject.getTrie(self.db).Prove(key[:])
+}
+
+// GetStorageRoot returns the root hash of the storage trie of the account,
+// which storage proofs are made against, or the empty root if the account
+// doesn't exist.
+func (self *StateDB) GetStorageRoot(a common.Address) common.Hash {
+	stateObject := self.GetStateObject(a)
+	if stateObject == nil {
+		return emptyRoot
+	}
+	return stateObject.getTrie(self.db).Hash()
+}
+
 func (self *StateDB) GetState(a common.Address, b common.Hash) common.Hash {
 	stateObject := self.GetStateObject(a)
 	if stateObject != nil {
@@ -281,6 +322,16 @@ func (self *StateDB) GetState(a common.Address, b common.Hash) common.Hash {
 	return common.Hash{}
 }
 
+// GetCommittedState returns a value in the storage of an account as of the last
+// IntermediateRoot or commit, before the changes of the current transaction.
+func (self *StateDB) GetCommittedState(a common.Address, b common.Hash) common.Hash {
+	stateObject := self.GetStateObject(a)
+	if stateObject != nil {
+		return stateObject.GetCommittedState(self.db, b)
+	}
+	return common.Hash{}
+}
+
 func (self *StateDB) HasSuicided(addr common.Address) bool {
 	stateObject := self.GetStateObject(addr)
 	if stateObject != nil {
@@ -480,6 +531,7 @@ func (self *StateDB) Copy() *StateDB {
 		trie:              self.trie,
 		pastTries:         self.pastTries,
 		codeSizeCache:     self.codeSizeCache,
+		codeCache:         self.codeCache,
 		stateObjects:      make(map[common.Address]*StateObject, len(self.stateObjectsDirty)),
 		stateObjectsDirty: make(map[common.Address]struct{}, len(self.stateObjectsDirty)),
 		refund:            new(big.Int).Set(self.refund),
@@ -534,13 +586,69 @@ func (self *StateDB) GetRefund() *big.Int {
 	return self.refund
 }
 
+// JournalRefund recomputes the gas refund owed for the changes journalled
+// since the last intermediate root using the original SSTORE rules: every
+// storage slot cleared from a non-zero value is refunded sstoreRefund and
+// every account suiciding for the first time suicideRefund. Changes undone by
+// reverting to a snapshot are no longer journalled and so aren't refunded.
+func (self *StateDB) JournalRefund(sstoreRefund, suicideRefund *big.Int) *big.Int {
+	var (
+		refund  = new(big.Int)
+		objects = make(map[common.Address]*StateObject)
+		stored  = make(map[common.Address]map[common.Hash]common.Hash)
+	)
+	// Walk the journal backwards, so the value written by a storage change is
+	// the previous value of the next change to the same slot, or the current
+	// value of the slot if there is no later change.
+	for i := len(self.journal) - 1; i >= 0; i-- {
+		switch ch := self.journal[i].(type) {
+		case storageChange:
+			addr := *ch.account
+			if stored[addr] == nil {
+				stored[addr] = make(map[common.Hash]common.Hash)
+			}
+			value, ok := stored[addr][ch.key]
+			if !ok {
+				obj, seen := objects[addr]
+				if !seen {
+					obj = self.GetStateObject(addr)
+					objects[addr] = obj
+				}
+				if obj != nil {
+					value = obj.GetState(self.db, ch.key)
+				}
+			}
+			if (ch.prevalue != common.Hash{}) && (value == common.Hash{}) {
+				refund.Add(refund, sstoreRefund)
+			}
+			stored[addr][ch.key] = ch.prevalue
+
+		case suicideChange:
+			if !ch.prev {
+				refund.Add(refund, suicideRefund)
+			}
+
+		case resetObjectChange:
+			// Earlier changes were made to the replaced account
+			objects[ch.prev.address] = ch.prev
+			delete(stored, ch.prev.address)
+		}
+	}
+	return refund
+}
+
 // IntermediateRoot computes the current root hash of the state trie.
 // It is called in between transactions to get the root hash that
 // goes into transaction receipts.
-func (s *StateDB) IntermediateRoot() common.Hash {
+//
+// If deleteEmptyObjects is set, the empty accounts touched since the last call
+// are removed from the state as required by EIP-161. Empty accounts which are
+// not touched are left alone, so the ones predating the fork are only cleared
+// once a transaction touches them.
+func (s *StateDB) IntermediateRoot(deleteEmptyObjects bool) common.Hash {
 	for addr := range s.stateObjectsDirty {
 		stateObject := s.stateObjects[addr]
-		if stateObject.suicided {
+		if stateObject.suicided || (deleteEmptyObjects && stateObject.touched && stateObject.empty()) {
 			s.deleteStateObject(stateObject)
 		} else {
 			stateObject.updateRoot(s.db)
@@ -588,6 +696,12 @@ func (s *StateDB) CommitBatch() (root common.Hash, batch ethdb.Batch) {
 	return root, batch
 }
 
+// CommitTo commits all state changes to dbw, usually a batch shared with the
+// other writes of a block.
+func (s *StateDB) CommitTo(dbw trie.DatabaseWriter) (root common.Hash, err error) {
+	return s.commit(dbw)
+}
+
 func (s *StateDB) clearJournalAndRefund() {
 	s.journal = nil
 	s.validRevisions = s.validRevisions[:0]
@@ -599,7 +713,7 @@ func (s *StateDB) commit(dbw trie.DatabaseWriter) (root common.Hash, err error)
 
 	// Commit objects to the trie.
 	for addr, stateObject := range s.stateObjects {
-		if stateObject.suicided {
+		if stateObject.suicided || stateObject.deleted {
 			// If the object has been removed, don't bother syncing it
 			// and just mark it for deletion in the trie.
 			s.deleteStateObject(stateObject)
@@ -609,6 +723,7 @@ func (s *StateDB) commit(dbw trie.DatabaseWriter) (root common.Hash, err error)
 				if err := dbw.Put(stateObject.CodeHash(), stateObject.code); err != nil {
 					return common.Hash{}, err
 				}
+				s.codeCache.Add(common.BytesToHash(stateObject.CodeHash()), stateObject.code)
 				stateObject.dirtyCode = false
 			}
 			// Write any storage changes in the state object to its storage trie.
@@ -30,7 +30,10 @@ import (
 
 	"github.com/ellaism/go-ellaism/common"
 	"github.com/ellaism/go-ellaism/core/vm"
+	"github.com/ellaism/go-ellaism/crypto"
 	"github.com/ellaism/go-ellaism/ethdb"
+	"github.com/ellaism/go-ellaism/rlp"
+	"github.com/ellaism/go-ellaism/trie"
 )
 
 // Tests that updating a state trie does not leak any database writes prior to
@@ -51,7 +54,7 @@ func TestUpdateLeaks(t *testing.T) {
 		if i%3 == 0 {
 			state.SetCode(addr, []byte{i, i, i, i, i})
 		}
-		state.IntermediateRoot()
+		state.IntermediateRoot(false)
 	}
 	// Ensure that no data was leaked into the database
 	for _, key := range db.Keys() {
@@ -86,7 +89,7 @@ func TestIntermediateLeaks(t *testing.T) {
 		modify(transState, common.Address{byte(i)}, i, 0)
 	}
 	// Write modifications to trie.
-	transState.IntermediateRoot()
+	transState.IntermediateRoot(false)
 
 	// Overwrite all the data with new values in the transient database.
 	for i := byte(0); i < 255; i++ {
@@ -115,6 +118,256 @@ func TestIntermediateLeaks(t *testing.T) {
 	}
 }
 
+// Tests that state diffs report the pre and post state of all accounts modified
+// since the last intermediate root, omitting the ones left unchanged.
+func TestStateDiff(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	state, _ := New(common.Hash{}, db)
+
+	var (
+		modified  = common.BytesToAddress([]byte{0x01})
+		untouched = common.BytesToAddress([]byte{0x02})
+		created   = common.BytesToAddress([]byte{0x03})
+		key       = common.BytesToHash([]byte{0x0a})
+	)
+	state.AddBalance(modified, big.NewInt(100))
+	state.SetState(modified, key, common.BytesToHash([]byte{0x01}))
+	state.SetNonce(untouched, 1)
+	state.IntermediateRoot(false)
+
+	pre := state.Copy()
+	state.SetBalance(modified, big.NewInt(60))
+	state.SetState(modified, key, common.BytesToHash([]byte{0x02}))
+	state.SetNonce(untouched, 1)
+	state.AddBalance(created, big.NewInt(40))
+
+	diff := state.Diff(pre)
+	if len(diff) != 2 {
+		t.Fatalf("diff length mismatch: have %d, want 2", len(diff))
+	}
+	if _, ok := diff[untouched]; ok {
+		t.Errorf("unchanged account %x reported in diff", untouched)
+	}
+	if d := diff[modified]; d == nil || d.Pre == nil || d.Post == nil {
+		t.Errorf("modified account %x missing pre or post state", modified)
+	} else {
+		if d.Pre.Balance != "100" || d.Post.Balance != "60" {
+			t.Errorf("balance mismatch: have %s -> %s, want 100 -> 60", d.Pre.Balance, d.Post.Balance)
+		}
+		if d.Pre.Storage[key.Hex()] != common.BytesToHash([]byte{0x01}).Hex() || d.Post.Storage[key.Hex()] != common.BytesToHash([]byte{0x02}).Hex() {
+			t.Errorf("storage mismatch: have %v -> %v", d.Pre.Storage, d.Post.Storage)
+		}
+	}
+	if d := diff[created]; d == nil || d.Pre != nil || d.Post == nil || d.Post.Balance != "40" {
+		t.Errorf("created account %x mismatch: have %+v", created, d)
+	}
+}
+
+// Tests that only the empty accounts touched by a transaction are deleted when
+// the EIP-161 rules apply, and that reverted touches don't count.
+func TestDeleteTouchedEmptyAccounts(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	state, _ := New(common.Hash{}, db)
+
+	touched, untouched, reverted := common.Address{0x01}, common.Address{0x02}, common.Address{0x03}
+	for _, addr := range []common.Address{touched, untouched, reverted} {
+		state.CreateAccount(addr)
+	}
+	state.IntermediateRoot(true)
+
+	state.AddBalance(touched, new(big.Int))
+	snapshot := state.Snapshot()
+	state.AddBalance(reverted, new(big.Int))
+	state.RevertToSnapshot(snapshot)
+	state.IntermediateRoot(true)
+
+	if state.Exist(touched) {
+		t.Error("touched empty account not deleted")
+	}
+	if !state.Exist(untouched) {
+		t.Error("untouched empty account deleted")
+	}
+	if !state.Exist(reverted) {
+		t.Error("empty account deleted after its touch was reverted")
+	}
+
+	// Deleted accounts must not be written by a commit
+	root, _ := state.Commit()
+	state, _ = New(root, db)
+	if state.Exist(touched) || !state.Exist(untouched) {
+		t.Errorf("committed state mismatch: touched exists %v, untouched exists %v", state.Exist(touched), state.Exist(untouched))
+	}
+}
+
+// Tests that contract code is served from the code cache shared by the
+// states derived from each other, without reading it from the database.
+func TestCodeCache(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	state, _ := New(common.Hash{}, db)
+
+	addr, code := common.Address{0x01}, []byte{0x60, 0x00, 0x60, 0x00, 0xf3}
+	state.SetCode(addr, code)
+	root, _ := state.Commit()
+
+	// Drop the code from the database, derived states must still find it
+	if err := db.Delete(crypto.Keccak256(code)); err != nil {
+		t.Fatal(err)
+	}
+	derived, _ := state.New(root)
+	if have := derived.GetCode(addr); !bytes.Equal(have, code) {
+		t.Errorf("code mismatch: have %x, want %x", have, code)
+	}
+	if have := derived.GetCodeSize(addr); have != len(code) {
+		t.Errorf("code size mismatch: have %d, want %d", have, len(code))
+	}
+
+	// A state with its own caches has to read the code from the database
+	fresh, _ := New(root, db)
+	if have := fresh.GetCode(addr); have != nil {
+		t.Errorf("code loaded without a cache: have %x, want nil", have)
+	}
+}
+
+// Tests that the code cache is bounded by the total size of the code it holds,
+// evicting the least recently used code first.
+func TestCodeCacheSize(t *testing.T) {
+	cache := newCodeCache(100)
+
+	a, b, c := common.Hash{0x0a}, common.Hash{0x0b}, common.Hash{0x0c}
+	cache.Add(a, make(Code, 40))
+	cache.Add(b, make(Code, 40))
+	cache.Get(a)
+	cache.Add(c, make(Code, 40))
+
+	if _, ok := cache.Get(b); ok {
+		t.Errorf("least recently used code not evicted")
+	}
+	if _, ok := cache.Get(a); !ok {
+		t.Errorf("recently used code evicted")
+	}
+	if size := cache.Size(); size != 80 {
+		t.Errorf("cache size mismatch: have %d, want 80", size)
+	}
+	// Re-adding code doesn't count it twice, oversized code isn't cached
+	cache.Add(c, make(Code, 40))
+	cache.Add(b, make(Code, 101))
+	if _, ok := cache.Get(b); ok {
+		t.Errorf("oversized code cached")
+	}
+	if size := cache.Size(); size != 80 {
+		t.Errorf("cache size mismatch: have %d, want 80", size)
+	}
+	// A single large code evicts as much as needed
+	cache.Add(b, make(Code, 100))
+	if size := cache.Size(); size != 100 {
+		t.Errorf("cache size mismatch: have %d, want 100", size)
+	}
+	if _, ok := cache.Get(a); ok {
+		t.Errorf("code kept over the size limit")
+	}
+}
+
+// Tests that committed storage values ignore the changes made since the last
+// intermediate root.
+func TestCommittedState(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	state, _ := New(common.Hash{}, db)
+
+	addr, key := common.Address{0x01}, common.Hash{0x02}
+	state.SetState(addr, key, common.Hash{0x03})
+	if have := state.GetCommittedState(addr, key); have != (common.Hash{}) {
+		t.Errorf("uncommitted value returned: have %x", have)
+	}
+	state.IntermediateRoot(false)
+
+	state.SetState(addr, key, common.Hash{0x04})
+	if have := state.GetCommittedState(addr, key); have != (common.Hash{0x03}) {
+		t.Errorf("committed value mismatch: have %x, want %x", have, common.Hash{0x03})
+	}
+	if have := state.GetState(addr, key); have != (common.Hash{0x04}) {
+		t.Errorf("current value mismatch: have %x, want %x", have, common.Hash{0x04})
+	}
+}
+
+func TestJournalRefund(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	state, _ := New(common.Hash{}, db)
+
+	addr := common.Address{0x01}
+	for i := byte(1); i <= 3; i++ {
+		state.SetState(addr, common.Hash{i}, common.Hash{0xff})
+	}
+	state.AddBalance(addr, big.NewInt(1))
+	state.IntermediateRoot(false)
+
+	// Clearing a slot is refunded even if it's set again later, clearing a
+	// slot in a reverted snapshot is not, and neither is a repeated suicide.
+	state.SetState(addr, common.Hash{1}, common.Hash{})
+	state.SetState(addr, common.Hash{2}, common.Hash{})
+	state.SetState(addr, common.Hash{2}, common.Hash{0x01})
+	snapshot := state.Snapshot()
+	state.SetState(addr, common.Hash{3}, common.Hash{})
+	state.RevertToSnapshot(snapshot)
+	state.Suicide(addr)
+	state.Suicide(addr)
+
+	if have, want := state.JournalRefund(big.NewInt(10), big.NewInt(100)), big.NewInt(2*10+100); have.Cmp(want) != 0 {
+		t.Errorf("refund mismatch: have %v, want %v", have, want)
+	}
+}
+
+func TestGetProof(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	state, _ := New(common.Hash{}, db)
+	for i := byte(1); i <= 16; i++ {
+		addr := common.Address{i}
+		state.AddBalance(addr, big.NewInt(int64(i)))
+		state.SetState(addr, common.Hash{i}, common.Hash{31: i})
+	}
+	root, err := state.Commit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, _ = New(root, db)
+
+	for i := byte(1); i <= 16; i++ {
+		addr := common.Address{i}
+		enc, err := trie.VerifyProof(root, crypto.Keccak256(addr[:]), state.GetProof(addr))
+		if err != nil {
+			t.Fatalf("account %x: invalid proof: %v", addr, err)
+		}
+		var account Account
+		if err := rlp.DecodeBytes(enc, &account); err != nil {
+			t.Fatalf("account %x: invalid proven value: %v", addr, err)
+		}
+		if account.Balance.Int64() != int64(i) || account.Root != state.GetStorageRoot(addr) {
+			t.Errorf("account %x: proven account mismatch: %+v", addr, account)
+		}
+
+		key := common.Hash{i}
+		enc, err = trie.VerifyProof(account.Root, crypto.Keccak256(key[:]), state.GetStorageProof(addr, key))
+		if err != nil {
+			t.Fatalf("account %x: invalid storage proof: %v", addr, err)
+		}
+		if !bytes.Equal(enc, []byte{i}) {
+			t.Errorf("account %x: proven storage mismatch: have %x, want %x", addr, enc, i)
+		}
+	}
+
+	// Absent accounts and slots are proven absent
+	missing := common.Address{0xff}
+	if enc, err := trie.VerifyProof(root, crypto.Keccak256(missing[:]), state.GetProof(missing)); err != nil || enc != nil {
+		t.Errorf("missing account: have %x, %v, want absence proof", enc, err)
+	}
+	if root := state.GetStorageRoot(missing); root != emptyRoot {
+		t.Errorf("missing account: storage root mismatch: have %x, want %x", root, emptyRoot)
+	}
+	addr, key := common.Address{1}, common.Hash{0xff}
+	if enc, err := trie.VerifyProof(state.GetStorageRoot(addr), crypto.Keccak256(key[:]), state.GetStorageProof(addr, key)); err != nil || enc != nil {
+		t.Errorf("missing slot: have %x, %v, want absence proof", enc, err)
+	}
+}
+
 func TestSnapshotRandom(t *testing.T) {
 	config := &quick.Config{MaxCount: 1000}
 	err := quick.Check((*snapshotTest).run, config)
@@ -18,7 +18,6 @@ package state
 
 import (
 	"bytes"
-	"math/big"
 
 	"github.com/ellaism/go-ellaism/common"
 	"github.com/ellaism/go-ellaism/ethdb"
@@ -31,27 +30,50 @@ import (
 // and reconstructs the state database step by step until all is done.
 type StateSync trie.TrieSync
 
-// NewStateSync create a new state trie download scheduler.
-func NewStateSync(root common.Hash, database ethdb.Database) *StateSync {
+// NewStateSync create a new state trie download scheduler. The bloom, if any,
+// spares the database lookups of the nodes it knows to be missing.
+func NewStateSync(root common.Hash, database ethdb.Database, bloom *trie.SyncBloom) *StateSync {
 	var syncer *trie.TrieSync
+	syncer = trie.NewTrieSync(root, database, accountCallback(&syncer), bloom)
+	return (*StateSync)(syncer)
+}
 
-	callback := func(leaf []byte, parent common.Hash) error {
-		var obj struct {
-			Nonce    uint64
-			Balance  *big.Int
-			Root     common.Hash
-			CodeHash []byte
-		}
+// accountCallback returns the leaf callback of the state trie sync, scheduling
+// the storage trie and the code of every account retrieved.
+func accountCallback(syncer **trie.TrieSync) trie.TrieSyncLeafCallback {
+	return func(leaf []byte, parent common.Hash) error {
+		var obj Account
 		if err := rlp.Decode(bytes.NewReader(leaf), &obj); err != nil {
 			return err
 		}
-		syncer.AddSubTrie(obj.Root, 64, parent, nil)
-		syncer.AddRawEntry(common.BytesToHash(obj.CodeHash), 64, parent)
+		(*syncer).AddSubTrie(obj.Root, 64, parent, nil)
+		(*syncer).AddRawEntry(common.BytesToHash(obj.CodeHash), 64, parent)
 
 		return nil
 	}
-	syncer = trie.NewTrieSync(root, database, callback)
-	return (*StateSync)(syncer)
+}
+
+// Heal walks the state of the given root as stored in the database, along with
+// the storage tries and the code of its accounts, and schedules the retrieval
+// of the entries missing from it. It returns the number of entries scheduled.
+func (s *StateSync) Heal(root common.Hash) (int, error) {
+	syncer := (*trie.TrieSync)(s)
+	healed := make(map[common.Hash]bool) // Storage tries already walked
+	leaf := func(value []byte) (int, error) {
+		var obj Account
+		if err := rlp.Decode(bytes.NewReader(value), &obj); err != nil {
+			return 0, err
+		}
+		count := syncer.HealRawEntry(common.BytesToHash(obj.CodeHash), 64)
+		if healed[obj.Root] {
+			return count, nil
+		}
+		healed[obj.Root] = true
+
+		storage, err := syncer.Heal(obj.Root, 64, nil, nil)
+		return count + storage, err
+	}
+	return syncer.Heal(root, 0, accountCallback(&syncer), leaf)
 }
 
 // Missing retrieves the known missing nodes from the state trie for retrieval.
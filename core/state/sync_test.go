@@ -110,7 +110,7 @@ func checkStateConsistency(db ethdb.Database, root common.Hash) error {
 func TestEmptyStateSync(t *testing.T) {
 	empty := common.HexToHash("56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421")
 	db, _ := ethdb.NewMemDatabase()
-	if req := NewStateSync(empty, db).Missing(1); len(req) != 0 {
+	if req := NewStateSync(empty, db, nil).Missing(1); len(req) != 0 {
 		t.Errorf("content requested for empty state: %v", req)
 	}
 }
@@ -126,7 +126,7 @@ func testIterativeStateSync(t *testing.T, batch int) {
 
 	// Create a destination state and sync with the scheduler
 	dstDb, _ := ethdb.NewMemDatabase()
-	sched := NewStateSync(srcRoot, dstDb)
+	sched := NewStateSync(srcRoot, dstDb, nil)
 
 	queue := append([]common.Hash{}, sched.Missing(batch)...)
 	for len(queue) > 0 {
@@ -155,7 +155,7 @@ func TestIterativeDelayedStateSync(t *testing.T) {
 
 	// Create a destination state and sync with the scheduler
 	dstDb, _ := ethdb.NewMemDatabase()
-	sched := NewStateSync(srcRoot, dstDb)
+	sched := NewStateSync(srcRoot, dstDb, nil)
 
 	queue := append([]common.Hash{}, sched.Missing(0)...)
 	for len(queue) > 0 {
@@ -189,7 +189,7 @@ func testIterativeRandomStateSync(t *testing.T, batch int) {
 
 	// Create a destination state and sync with the scheduler
 	dstDb, _ := ethdb.NewMemDatabase()
-	sched := NewStateSync(srcRoot, dstDb)
+	sched := NewStateSync(srcRoot, dstDb, nil)
 
 	queue := make(map[common.Hash]struct{})
 	for _, hash := range sched.Missing(batch) {
@@ -226,7 +226,7 @@ func TestIterativeRandomDelayedStateSync(t *testing.T) {
 
 	// Create a destination state and sync with the scheduler
 	dstDb, _ := ethdb.NewMemDatabase()
-	sched := NewStateSync(srcRoot, dstDb)
+	sched := NewStateSync(srcRoot, dstDb, nil)
 
 	queue := make(map[common.Hash]struct{})
 	for _, hash := range sched.Missing(0) {
@@ -268,7 +268,7 @@ func TestIncompleteStateSync(t *testing.T) {
 
 	// Create a destination state and sync with the scheduler
 	dstDb, _ := ethdb.NewMemDatabase()
-	sched := NewStateSync(srcRoot, dstDb)
+	sched := NewStateSync(srcRoot, dstDb, nil)
 
 	added := []common.Hash{}
 	queue := append([]common.Hash{}, sched.Missing(1)...)
@@ -321,3 +321,51 @@ func TestIncompleteStateSync(t *testing.T) {
 		dstDb.Put(key, value)
 	}
 }
+
+// Tests that healing a state schedules the trie nodes and the code missing
+// from it, and finds nothing to heal once they're retrieved.
+func TestStateSyncHeal(t *testing.T) {
+	srcDb, srcRoot, srcAccounts := makeTestState()
+
+	dstDb, _ := ethdb.NewMemDatabase()
+	sync := func(sched *StateSync) {
+		for queue := sched.Missing(100); len(queue) > 0; queue = sched.Missing(100) {
+			results := make([]trie.SyncResult, len(queue))
+			for i, hash := range queue {
+				data, err := srcDb.Get(hash.Bytes())
+				if err != nil {
+					t.Fatalf("failed to retrieve node data for %x: %v", hash, err)
+				}
+				results[i] = trie.SyncResult{Hash: hash, Data: data}
+			}
+			if index, err := sched.Process(results); err != nil {
+				t.Fatalf("failed to process result #%d: %v", index, err)
+			}
+		}
+	}
+	sync(NewStateSync(srcRoot, dstDb, nil))
+
+	// Drop the code of an account and an inner state trie node
+	dstDb.Delete(crypto.Keccak256([]byte{3, 3, 3, 3, 3}))
+	for _, key := range dstDb.Keys() {
+		if blob, _ := dstDb.Get(key); len(key) == common.HashLength && !bytes.Equal(key, srcRoot[:]) && len(blob) > 100 {
+			dstDb.Delete(key)
+			break
+		}
+	}
+	sched := NewStateSync(srcRoot, dstDb, nil)
+	healed, err := sched.Heal(srcRoot)
+	if err != nil {
+		t.Fatalf("failed to heal state: %v", err)
+	}
+	// The code is only found missing if its account isn't in the missing subtrie
+	if healed < 1 || healed > 2 {
+		t.Errorf("healed entries: have %d, want 1 or 2", healed)
+	}
+	sync(sched)
+	checkStateAccounts(t, dstDb, srcRoot, srcAccounts)
+
+	if healed, err := sched.Heal(srcRoot); healed != 0 || err != nil {
+		t.Errorf("complete state healed: %d entries (%v)", healed, err)
+	}
+}
@@ -22,6 +22,7 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/ellaism/go-ellaism/common"
 	"github.com/ellaism/go-ellaism/core/state"
 	"github.com/ellaism/go-ellaism/core/types"
 	"github.com/ellaism/go-ellaism/core/vm"
@@ -34,6 +35,7 @@ var (
 	MaximumBlockReward       = big.NewInt(5e+18) // that's shiny 5 ether
 	big8                     = big.NewInt(8)
 	big32                    = big.NewInt(32)
+	big100                   = big.NewInt(100)
 	DisinflationRateQuotient = big.NewInt(4)
 	DisinflationRateDivisor  = big.NewInt(5)
 
@@ -45,8 +47,10 @@ var (
 //
 // StateProcessor implements Processor.
 type StateProcessor struct {
-	config *ChainConfig
-	bc     *BlockChain
+	config    *ChainConfig
+	bc        *BlockChain
+	gasAudit  bool // Cross-check the gas used by transactions against opcode metering
+	opMetrics bool // Export per-opcode execution metrics of the processed blocks
 }
 
 // NewStateProcessor initialises a new StateProcessor.
@@ -57,6 +61,21 @@ func NewStateProcessor(config *ChainConfig, bc *BlockChain) *StateProcessor {
 	}
 }
 
+// SetGasAudit enables or disables the gas accounting audit. When enabled, the
+// gas used by every processed transaction is recomputed from the cost of the
+// executed opcodes and any discrepancy with the gas reported in the receipt
+// is logged.
+func (p *StateProcessor) SetGasAudit(enabled bool) {
+	p.gasAudit = enabled
+}
+
+// SetOpcodeMetrics enables or disables the opcode metrics. When enabled, the
+// number of executions, the gas used and the time taken by every opcode are
+// aggregated over each processed block and added to the metrics registry.
+func (p *StateProcessor) SetOpcodeMetrics(enabled bool) {
+	p.opMetrics = enabled
+}
+
 // Process processes the state changes according to the Ethereum rules by running
 // the transaction messages using the statedb and applying any rewards to both
 // the processor (coinbase) and any included uncles.
@@ -72,7 +91,14 @@ func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB) (ty
 		header       = block.Header()
 		allLogs      vm.Logs
 		gp           = new(GasPool).AddGas(block.GasLimit())
+
+		discrepancies int
+		meter         *opcodeMetrics
+		limits        = new(limitAborts)
 	)
+	if p.opMetrics {
+		meter = newOpcodeMetrics()
+	}
 	// Iterate over and process the individual transactions
 	for i, tx := range block.Transactions() {
 		if tx.Protected() {
@@ -85,14 +111,30 @@ func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB) (ty
 			}
 		}
 		statedb.StartRecord(tx.Hash(), block.Hash(), i)
-		receipt, logs, _, err := ApplyTransaction(p.config, p.bc, gp, statedb, header, tx, totalUsedGas)
+		var auditor *gasAuditor
+		if p.gasAudit {
+			auditor = newGasAuditor(p.config, header.Number)
+		}
+		receipt, logs, _, err := applyTransaction(p.config, p.bc, gp, statedb, header, tx, totalUsedGas, auditor, meter, limits)
 		if err != nil {
 			return nil, nil, totalUsedGas, err
 		}
+		if auditor != nil {
+			discrepancies += len(auditor.discrepancies)
+		}
 		receipts = append(receipts, receipt)
 		allLogs = append(allLogs, logs...)
 	}
-	AccumulateRewards(p.config, statedb, header, block.Uncles())
+	p.bc.engine.Finalize(p.bc, header, statedb, block.Uncles())
+
+	if discrepancies > 0 {
+		glog.V(logger.Warn).Infof("Gas audit found %d discrepancies in block #%v [%s]", discrepancies, block.Number(), block.Hash().Hex())
+		glog.D(logger.Warn).Warnf("Gas audit found %d discrepancies in block #%v [%s]", discrepancies, block.Number(), block.Hash().Hex())
+	}
+	if meter != nil {
+		meter.report()
+	}
+	limits.report(block)
 
 	return receipts, allLogs, totalUsedGas, err
 }
@@ -103,16 +145,41 @@ func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB) (ty
 // ApplyTransactions returns the generated receipts and vm logs during the
 // execution of the state transition phase.
 func ApplyTransaction(config *ChainConfig, bc *BlockChain, gp *GasPool, statedb *state.StateDB, header *types.Header, tx *types.Transaction, usedGas *big.Int) (*types.Receipt, vm.Logs, *big.Int, error) {
+	return applyTransaction(config, bc, gp, statedb, header, tx, usedGas, nil, nil, nil)
+}
+
+// applyTransaction applies a transaction like ApplyTransaction, additionally
+// auditing its gas accounting if an auditor is given, metering its opcodes if a
+// meter is given and counting the frames aborted by the EVM limits if limits is
+// given.
+func applyTransaction(config *ChainConfig, bc *BlockChain, gp *GasPool, statedb *state.StateDB, header *types.Header, tx *types.Transaction, usedGas *big.Int, auditor *gasAuditor, meter *opcodeMetrics, limits *limitAborts) (*types.Receipt, vm.Logs, *big.Int, error) {
+	if !config.SupportsTxType(header.Number, tx.Type()) {
+		return nil, nil, nil, types.ErrTxTypeNotSupported
+	}
 	tx.SetSigner(config.GetSigner(header.Number))
 
-	_, gas, err := ApplyMessage(NewEnv(statedb, config, bc, tx, header), tx, gp)
+	env := NewEnv(statedb, config, bc, tx, header)
+	if auditor != nil {
+		env.SetTracer(auditor)
+	}
+	if meter != nil {
+		env.SetOpcodeMeter(meter)
+	}
+	env.limits = limits
+	_, gas, err := ApplyMessage(env, tx, gp)
 	if err != nil {
 		return nil, nil, nil, err
 	}
+	// The refund counter is cleared along with the intermediate root below
+	if auditor != nil {
+		for _, discrepancy := range auditor.verify(statedb, tx, gas) {
+			glog.V(logger.Warn).Infof("Gas audit of tx %s in block #%v: %s", tx.Hash().Hex(), header.Number, discrepancy)
+		}
+	}
 
 	// Update the state with pending changes
 	usedGas.Add(usedGas, gas)
-	receipt := types.NewReceipt(statedb.IntermediateRoot().Bytes(), usedGas)
+	receipt := types.NewReceipt(statedb.IntermediateRoot(config.IsEIP161(header.Number)).Bytes(), usedGas)
 	receipt.TxHash = tx.Hash()
 	receipt.GasUsed = new(big.Int).Set(gas)
 	if MessageCreatesContract(tx) {
@@ -129,11 +196,78 @@ func ApplyTransaction(config *ChainConfig, bc *BlockChain, gp *GasPool, statedb
 	return receipt, logs, gas, err
 }
 
-// AccumulateRewards credits the coinbase of the given block with the
-// mining reward. The total reward consists of the static block reward
-// and rewards for included uncles. The coinbase of each uncle block is
-// also rewarded.
-func AccumulateRewards(config *ChainConfig, statedb *state.StateDB, header *types.Header, uncles []*types.Header) {
+// BlockReward is the breakdown of the rewards credited for a block, excluding
+// transaction fees.
+type BlockReward struct {
+	Base   *big.Int   // Static reward of the block's miner
+	Uncles *big.Int   // Reward of the block's miner for including uncles
+	Uncle  []*big.Int // Reward of each uncle's miner, in the order of the block's uncles
+
+	Treasury        *big.Int        // Share of the base and uncle inclusion rewards diverted from the miner
+	TreasuryAddress *common.Address // Recipient of the diverted share, nil if it is burnt
+}
+
+// Miner returns the total reward of the block's miner.
+func (r *BlockReward) Miner() *big.Int {
+	reward := new(big.Int).Add(r.Base, r.Uncles)
+	if r.Treasury != nil {
+		reward.Sub(reward, r.Treasury)
+	}
+	return reward
+}
+
+// CalcBlockReward computes the rewards credited for the given block with the
+// given uncles, as credited by AccumulateRewards.
+func CalcBlockReward(config *ChainConfig, header *types.Header, uncles []*types.Header) *BlockReward {
+	reward := calcMiningReward(config, header, uncles)
+	reward.Treasury, reward.TreasuryAddress = calcTreasuryShare(config, header.Number, reward)
+	return reward
+}
+
+// treasury returns the options of a treasury feature: the 'percent' of the base
+// and uncle inclusion rewards diverted from the miner, and the 'address' the
+// share is credited to, nil if it is burnt.
+func (o *ForkFeature) treasury() (*big.Int, *common.Address, error) {
+	percent, ok := o.GetBigInt("percent")
+	if !ok {
+		return nil, nil, errors.New("percent is not set")
+	}
+	if percent.Sign() < 0 || percent.Cmp(big100) > 0 {
+		return nil, nil, fmt.Errorf("percent %v out of range [0, 100]", percent)
+	}
+	hex, ok := o.GetString("address")
+	if !ok {
+		return percent, nil, nil
+	}
+	if !common.IsHexAddress(hex) {
+		return nil, nil, fmt.Errorf("invalid address '%v'", hex)
+	}
+	address := common.HexToAddress(hex)
+	return percent, &address, nil
+}
+
+// calcTreasuryShare computes the share of the miner's reward diverted by the
+// treasury feature at the given block, and the address it is credited to. The
+// feature is validated along the chain configuration, so its options are known
+// to be valid here.
+func calcTreasuryShare(config *ChainConfig, num *big.Int, reward *BlockReward) (*big.Int, *common.Address) {
+	feat, _, configured := config.GetFeature(num, "treasury")
+	if !configured {
+		return new(big.Int), nil
+	}
+	percent, recipient, err := feat.treasury()
+	if err != nil {
+		panic(fmt.Sprintf("Unsupported treasury for block %v: %v", num, err))
+	}
+	share := new(big.Int).Add(reward.Base, reward.Uncles)
+	share.Mul(share, percent)
+	share.Div(share, big100)
+	return share, recipient
+}
+
+// calcMiningReward computes the rewards of the given block's miner and the
+// miners of its uncles, before any treasury share is diverted.
+func calcMiningReward(config *ChainConfig, header *types.Header, uncles []*types.Header) *BlockReward {
 
 	// An uncle is a block that would be considered an orphan because its not on the longest chain (it's an alternative block at the same height as your parent).
 	// https://www.reddit.com/r/ethereum/comments/3c9jbf/wtf_are_uncles_and_why_do_they_matter/
@@ -144,51 +278,75 @@ func AccumulateRewards(config *ChainConfig, statedb *state.StateDB, header *type
 
 	// Since ECIP1017 impacts "Era 1" idempotently and with constant 0-block based eras,
 	// we don't care about where the block/fork implementing it is.
+	reward := &BlockReward{Uncles: new(big.Int), Uncle: make([]*big.Int, len(uncles))}
+
 	feat, _, configured := config.HasFeature("reward")
 	if !configured {
-		reward := new(big.Int).Set(MaximumBlockReward)
-		r := new(big.Int)
+		reward.Base = new(big.Int).Set(MaximumBlockReward)
 
-		for _, uncle := range uncles {
+		for i, uncle := range uncles {
+			r := new(big.Int)
 			r.Add(uncle.Number, big8)    // 2,534,998 + 8              = 2,535,006
 			r.Sub(r, header.Number)      // 2,535,006 - 2,534,999        = 7
 			r.Mul(r, MaximumBlockReward) // 7 * 5e+18               = 35e+18
 			r.Div(r, big8)               // 35e+18 / 8                            = 7/8 * 5e+18
+			reward.Uncle[i] = r
 
-			statedb.AddBalance(uncle.Coinbase, r) // $$
-
-			r.Div(MaximumBlockReward, big32) // 5e+18 / 32
-			reward.Add(reward, r)            // 5e+18 + (1/32*5e+18)
-		}
-		statedb.AddBalance(header.Coinbase, reward) //  $$ => 5e+18 + (1/32*5e+18)
-	} else {
-		// Check that configuration specifies ECIP1017.
-		val, ok := feat.GetString("type")
-		if !ok || val != "ecip1017" {
-			panic(ErrConfiguration)
-		}
-
-		// Ensure value 'era' is configured.
-		eraLen, ok := feat.GetBigInt("era")
-		if !ok || eraLen.Cmp(big.NewInt(0)) <= 0 {
-			panic(ErrConfiguration)
+			reward.Uncles.Add(reward.Uncles, new(big.Int).Div(MaximumBlockReward, big32)) // 1/32*5e+18 per uncle
 		}
+		return reward
+	}
+	// Check that configuration specifies ECIP1017.
+	val, ok := feat.GetString("type")
+	if !ok || val != "ecip1017" {
+		panic(ErrConfiguration)
+	}
 
-		era := GetBlockEra(header.Number, eraLen)
+	// Ensure value 'era' is configured.
+	eraLen, ok := feat.GetBigInt("era")
+	if !ok || eraLen.Cmp(big.NewInt(0)) <= 0 {
+		panic(ErrConfiguration)
+	}
 
-		wr := GetBlockWinnerRewardByEra(era) // wr "winner reward". 5, 4, 3.2, 2.56, ...
+	era := GetBlockEra(header.Number, eraLen)
 
-		wurs := GetBlockWinnerRewardForUnclesByEra(era, uncles) // wurs "winner uncle rewards"
-		wr.Add(wr, wurs)
+	reward.Base = GetBlockWinnerRewardByEra(era)                    // 5, 4, 3.2, 2.56, ...
+	reward.Uncles = GetBlockWinnerRewardForUnclesByEra(era, uncles) // winner uncle rewards
 
-		statedb.AddBalance(header.Coinbase, wr) // $$
+	// Reward uncle miners.
+	for i, uncle := range uncles {
+		reward.Uncle[i] = GetBlockUncleRewardByEra(era, header, uncle)
+	}
+	return reward
+}
 
-		// Reward uncle miners.
-		for _, uncle := range uncles {
-			ur := GetBlockUncleRewardByEra(era, header, uncle)
-			statedb.AddBalance(uncle.Coinbase, ur) // $$
+// BlockFees returns the transaction fees earned by the miner of the given block
+// with the given receipts.
+func BlockFees(block *types.Block, receipts types.Receipts) *big.Int {
+	fees := new(big.Int)
+	for i, tx := range block.Transactions() {
+		if i < len(receipts) && receipts[i].GasUsed != nil {
+			fees.Add(fees, new(big.Int).Mul(receipts[i].GasUsed, tx.GasPrice()))
 		}
 	}
+	return fees
+}
+
+// AccumulateRewards credits the coinbase of the given block with the
+// mining reward. The total reward consists of the static block reward
+// and rewards for included uncles, less the share diverted by the treasury
+// feature, which is credited to the treasury address or burnt. The coinbase
+// of each uncle block is also rewarded.
+func AccumulateRewards(config *ChainConfig, statedb *state.StateDB, header *types.Header, uncles []*types.Header) {
+	reward := CalcBlockReward(config, header, uncles)
+
+	statedb.AddBalance(header.Coinbase, reward.Miner()) // $$
+	for i, uncle := range uncles {
+		statedb.AddBalance(uncle.Coinbase, reward.Uncle[i]) // $$
+	}
+	if reward.TreasuryAddress != nil {
+		statedb.AddBalance(*reward.TreasuryAddress, reward.Treasury)
+	}
 }
 
 // As of "Era 2" (zero-index era 1), uncle miners and winners are rewarded equally for each included block.
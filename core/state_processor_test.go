@@ -3,6 +3,7 @@ package core
 import (
 	"math/big"
 	"math/rand"
+	"strings"
 	"testing"
 	"time"
 
@@ -513,6 +514,22 @@ var (
 	Era4UncleReward       = new(big.Int).Div(new(big.Int).Mul(new(big.Int).Div(Era3WinnerReward, big.NewInt(5)), big.NewInt(4)), big32)
 )
 
+func TestBlockFees(t *testing.T) {
+	txs := types.Transactions{
+		types.NewTransaction(0, common.Address{}, new(big.Int), big.NewInt(50000), big.NewInt(2), nil),
+		types.NewTransaction(1, common.Address{}, new(big.Int), big.NewInt(50000), big.NewInt(3), nil),
+	}
+	block := types.NewBlock(&types.Header{Number: common.Big1}, txs, nil, nil)
+	receipts := types.Receipts{{GasUsed: big.NewInt(21000)}, {GasUsed: big.NewInt(30000)}}
+
+	if fees, want := BlockFees(block, receipts), big.NewInt(2*21000+3*30000); fees.Cmp(want) != 0 {
+		t.Errorf("fees %v, want %v", fees, want)
+	}
+	if fees := BlockFees(block, receipts[:1]); fees.Cmp(big.NewInt(2*21000)) != 0 {
+		t.Errorf("fees with a missing receipt %v, want %v", fees, 2*21000)
+	}
+}
+
 // Non-accruing over block cases simulates instance,
 // ie. a miner wins once at different blocks.
 //
@@ -613,6 +630,37 @@ func TestAccumulateRewards2_2Uncles(t *testing.T) {
 	}
 }
 
+// Tests that the reward breakdown adds up to the balances credited by
+// AccumulateRewards.
+func TestCalcBlockReward(t *testing.T) {
+	for i, config := range []*ChainConfig{DefaultConfigMainnet.ChainConfig, DefaultConfigMorden.ChainConfig} {
+		for _, c := range makeExpectedRewardCasesForConfig(config, 2, t) {
+			winner := &types.Header{Number: c.block, Coinbase: WinnerCoinbase}
+			uncles := []*types.Header{
+				{Number: new(big.Int).Sub(c.block, common.Big1), Coinbase: Uncle1Coinbase},
+				{Number: new(big.Int).Sub(c.block, common.Big2), Coinbase: Uncle2Coinbase},
+			}
+			db, _ := ethdb.NewMemDatabase()
+			stateDB, _ := state.New(common.Hash{}, db)
+			AccumulateRewards(config, stateDB, winner, uncles)
+
+			reward := CalcBlockReward(config, winner, uncles)
+			if got, want := reward.Miner(), stateDB.GetBalance(WinnerCoinbase); got.Cmp(want) != 0 {
+				t.Errorf("config %d block %v: miner reward %v, credited %v", i, c.block, got, want)
+			}
+			if got, want := new(big.Int).Add(reward.Base, reward.Uncles), reward.Miner(); got.Cmp(want) != 0 {
+				t.Errorf("config %d block %v: base %v and uncle inclusion %v don't add up to %v", i, c.block, reward.Base, reward.Uncles, want)
+			}
+			for j, uncle := range uncles {
+				if got, want := reward.Uncle[j], stateDB.GetBalance(uncle.Coinbase); got.Cmp(want) != 0 {
+					t.Errorf("config %d block %v: uncle %d reward %v, credited %v", i, c.block, j, got, want)
+				}
+			}
+			db.Close()
+		}
+	}
+}
+
 // Non-accruing over block cases simulates instance,
 // ie. a miner wins once at different blocks.
 //
@@ -765,3 +813,101 @@ func TestAccumulateRewards4_0Uncles(t *testing.T) {
 		}
 	}
 }
+
+// treasuryTestConfig returns a chain configuration diverting the given
+// percentage of the miner's reward from block 10, to the given address or
+// burnt if empty.
+func treasuryTestConfig(percent int, address string) *ChainConfig {
+	options := ChainFeatureConfigOptions{"percent": percent}
+	if address != "" {
+		options["address"] = address
+	}
+	config := MakeDiehardChainConfig()
+	config.Forks = append(config.Forks, &Fork{
+		Name:     "Treasury",
+		Block:    big.NewInt(10),
+		Features: []*ForkFeature{{ID: "treasury", Options: options}},
+	})
+	return config
+}
+
+func TestAccumulateRewardsTreasury(t *testing.T) {
+	treasury := common.HexToAddress("0x00000000000000000000000000000000000000aa")
+	untreasured := MakeDiehardChainConfig()
+
+	tests := []struct {
+		name     string
+		config   *ChainConfig
+		number   int64
+		treasury bool // Whether the share is credited to the treasury
+		percent  int64
+	}{
+		{"before fork", treasuryTestConfig(20, treasury.Hex()), 9, false, 0},
+		{"at fork", treasuryTestConfig(20, treasury.Hex()), 10, true, 20},
+		{"after fork", treasuryTestConfig(20, treasury.Hex()), 11, true, 20},
+		{"burnt", treasuryTestConfig(20, ""), 10, false, 20},
+		{"whole reward", treasuryTestConfig(100, treasury.Hex()), 10, true, 100},
+		{"no share", treasuryTestConfig(0, treasury.Hex()), 10, true, 0},
+	}
+	for _, tt := range tests {
+		winner := &types.Header{Number: big.NewInt(tt.number), Coinbase: WinnerCoinbase}
+		uncles := []*types.Header{{Number: big.NewInt(tt.number - 1), Coinbase: Uncle1Coinbase}}
+		base := CalcBlockReward(untreasured, winner, uncles)
+
+		db, _ := ethdb.NewMemDatabase()
+		statedb, _ := state.New(common.Hash{}, db)
+		AccumulateRewards(tt.config, statedb, winner, uncles)
+
+		share := new(big.Int).Add(base.Base, base.Uncles)
+		share.Mul(share, big.NewInt(tt.percent))
+		share.Div(share, big.NewInt(100))
+
+		if got, want := statedb.GetBalance(WinnerCoinbase), new(big.Int).Sub(base.Miner(), share); got.Cmp(want) != 0 {
+			t.Errorf("%s: miner credited %v, want %v", tt.name, got, want)
+		}
+		if got, want := statedb.GetBalance(Uncle1Coinbase), base.Uncle[0]; got.Cmp(want) != 0 {
+			t.Errorf("%s: uncle miner credited %v, want %v", tt.name, got, want)
+		}
+		want := new(big.Int)
+		if tt.treasury {
+			want = share
+		}
+		if got := statedb.GetBalance(treasury); got.Cmp(want) != 0 {
+			t.Errorf("%s: treasury credited %v, want %v", tt.name, got, want)
+		}
+		if reward := CalcBlockReward(tt.config, winner, uncles); reward.Treasury.Cmp(share) != 0 {
+			t.Errorf("%s: treasury share %v, want %v", tt.name, reward.Treasury, share)
+		} else if tt.treasury && (reward.TreasuryAddress == nil || *reward.TreasuryAddress != treasury) {
+			t.Errorf("%s: treasury address %v, want %x", tt.name, reward.TreasuryAddress, treasury)
+		}
+		db.Close()
+	}
+}
+
+// Tests that chain configurations with invalid treasury options are rejected
+// rather than failing once the fork is reached.
+func TestAccumulateRewardsTreasuryInvalid(t *testing.T) {
+	for _, options := range []ChainFeatureConfigOptions{
+		{},
+		{"percent": -1},
+		{"percent": 101},
+		{"percent": 20, "address": "0xinvalid"},
+	} {
+		config := MakeDiehardChainConfig()
+		config.Forks = append(config.Forks, &Fork{
+			Name:     "Treasury",
+			Block:    big.NewInt(10),
+			Features: []*ForkFeature{{ID: "treasury", Options: options}},
+		})
+		scc := &SufficientChainConfig{
+			Identity:    "test",
+			Network:     1,
+			Consensus:   "ethash",
+			Genesis:     DefaultConfigMainnet.Genesis,
+			ChainConfig: config,
+		}
+		if s, ok := scc.IsValid(); ok || !strings.Contains(s, "treasury") {
+			t.Errorf("options %v: unexpected ok or reason: %v", options, s)
+		}
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"math/big"
 
 	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/core/types"
 	"github.com/ellaism/go-ellaism/core/vm"
 	"github.com/ellaism/go-ellaism/logger"
 	"github.com/ellaism/go-ellaism/logger/glog"
@@ -31,6 +32,9 @@ var (
 	TxGasContractCreation = big.NewInt(53000) // Per transaction that creates a contract. NOTE: Not payable on data of calls between transactions.
 	TxDataZeroGas         = big.NewInt(4)     // Per byte of data attached to a transaction that equals zero. NOTE: Not payable on data of calls between transactions.
 	TxDataNonZeroGas      = big.NewInt(68)    // Per byte of data attached to a transaction that is not equal to zero. NOTE: Not payable on data of calls between transactions.
+
+	TxAccessListAddressGas    = big.NewInt(2400) // Per address in the access list of a transaction.
+	TxAccessListStorageKeyGas = big.NewInt(1900) // Per storage slot in the access list of a transaction.
 )
 
 /*
@@ -58,6 +62,7 @@ type StateTransition struct {
 	value         *big.Int
 	data          []byte
 	state         vm.Database
+	vmErr         error
 
 	env vm.Environment
 }
@@ -73,6 +78,7 @@ type Message interface {
 
 	Nonce() uint64
 	Data() []byte
+	AccessList() types.AccessList
 }
 
 func MessageCreatesContract(msg Message) bool {
@@ -80,8 +86,8 @@ func MessageCreatesContract(msg Message) bool {
 }
 
 // IntrinsicGas computes the 'intrinsic gas' for a message
-// with the given data.
-func IntrinsicGas(data []byte, contractCreation, homestead bool) *big.Int {
+// with the given data and access list.
+func IntrinsicGas(data []byte, accessList types.AccessList, contractCreation, homestead bool, gasTable *vm.GasTable) *big.Int {
 	igas := new(big.Int)
 	if contractCreation && homestead {
 		igas.Set(TxGasContractCreation)
@@ -96,12 +102,18 @@ func IntrinsicGas(data []byte, contractCreation, homestead bool) *big.Int {
 			}
 		}
 		m := big.NewInt(nz)
-		m.Mul(m, TxDataNonZeroGas)
+		m.Mul(m, gasTable.TxDataNonZero)
 		igas.Add(igas, m)
 		m.SetInt64(int64(len(data)) - nz)
 		m.Mul(m, TxDataZeroGas)
 		igas.Add(igas, m)
 	}
+	if len(accessList) > 0 {
+		m := big.NewInt(int64(len(accessList)))
+		igas.Add(igas, m.Mul(m, TxAccessListAddressGas))
+		m.SetInt64(int64(accessList.StorageKeys()))
+		igas.Add(igas, m.Mul(m, TxAccessListStorageKeyGas))
+	}
 	return igas
 }
 
@@ -231,7 +243,7 @@ func (self *StateTransition) TransitionDb() (ret []byte, requiredGas, usedGas *b
 	homestead := self.env.RuleSet().IsHomestead(self.env.BlockNumber())
 	contractCreation := MessageCreatesContract(msg)
 	// Pay intrinsic gas
-	if err = self.useGas(IntrinsicGas(self.data, contractCreation, homestead)); err != nil {
+	if err = self.useGas(IntrinsicGas(self.data, msg.AccessList(), contractCreation, homestead, self.env.RuleSet().GasTable(self.env.BlockNumber()))); err != nil {
 		return nil, nil, nil, InvalidTxError(err)
 	}
 
@@ -244,7 +256,10 @@ func (self *StateTransition) TransitionDb() (ret []byte, requiredGas, usedGas *b
 		}
 
 		if err != nil {
-			ret = nil
+			// Keep the output of reverted creations, it may hold the reason
+			if err != vm.ErrExecutionReverted {
+				ret = nil
+			}
 			glog.V(logger.Core).Infoln("VM create err:", err)
 		}
 	} else {
@@ -262,6 +277,7 @@ func (self *StateTransition) TransitionDb() (ret []byte, requiredGas, usedGas *b
 
 	// We aren't interested in errors here. Errors returned by the VM are non-consensus errors and therefor shouldn't bubble up
 	if err != nil {
+		self.vmErr = err
 		err = nil
 	}
 
@@ -273,6 +289,13 @@ func (self *StateTransition) TransitionDb() (ret []byte, requiredGas, usedGas *b
 	return ret, requiredGas, self.gasUsed(), err
 }
 
+// VMErr returns the error the EVM execution of the message ended with, such
+// as vm.ErrExecutionReverted, once TransitionDb has run. These errors don't
+// invalidate the message, so TransitionDb doesn't return them.
+func (self *StateTransition) VMErr() error {
+	return self.vmErr
+}
+
 func (self *StateTransition) refundGas() {
 	// Return eth for remaining gas to the sender account,
 	// exchanged at the original rate.
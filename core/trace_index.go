@@ -0,0 +1,120 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+
+	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/core/vm"
+	"github.com/ellaism/go-ellaism/ethdb"
+	"github.com/ellaism/go-ellaism/logger"
+	"github.com/ellaism/go-ellaism/logger/glog"
+	"github.com/ellaism/go-ellaism/rlp"
+)
+
+var (
+	traceIndexHeadKey = []byte("LastTraceIndexed")
+	blockTracesPrefix = []byte("traces-block-")
+)
+
+// TraceRecord is the compact record of a call frame of a transaction, which the
+// trace index stores in place of the full trace of the transaction: the frame's
+// transfer of value, without its input, output and gas.
+type TraceRecord struct {
+	TxIndex  uint           // Index of the transaction in its block
+	Address  []uint         // Index of the frame among the calls of each of its callers, empty for the transaction itself
+	Type     vm.OpCode      // CALL, CALLCODE, DELEGATECALL, CREATE, CREATE2 or SUICIDE
+	From     common.Address // Caller
+	To       common.Address // Callee, created contract or suicide beneficiary
+	Value    *big.Int       // Value transferred
+	Reverted bool           // Whether the frame or any of its callers failed, reverting the transfer
+}
+
+// NewTraceRecords flattens the call frames of a transaction, in execution order.
+func NewTraceRecords(txIndex int, frame *vm.CallFrame) []*TraceRecord {
+	var records []*TraceRecord
+	appendTraceRecords(&records, uint(txIndex), nil, frame, false)
+	return records
+}
+
+func appendTraceRecords(records *[]*TraceRecord, txIndex uint, address []uint, frame *vm.CallFrame, reverted bool) {
+	reverted = reverted || frame.Error != nil
+	value := frame.Value
+	if value == nil {
+		value = new(big.Int)
+	}
+	*records = append(*records, &TraceRecord{
+		TxIndex:  txIndex,
+		Address:  address,
+		Type:     frame.Type,
+		From:     frame.From,
+		To:       frame.To,
+		Value:    value,
+		Reverted: reverted,
+	})
+	for i, call := range frame.Calls {
+		child := make([]uint, len(address)+1)
+		copy(child, address)
+		child[len(address)] = uint(i)
+		appendTraceRecords(records, txIndex, child, call, reverted)
+	}
+}
+
+// GetBlockTraces retrieves the trace records of the transactions of the block
+// with the given hash, and whether the block was indexed at all.
+func GetBlockTraces(db ethdb.Database, hash common.Hash) ([]*TraceRecord, bool) {
+	data, _ := db.Get(append(blockTracesPrefix, hash[:]...))
+	if len(data) == 0 {
+		return nil, false
+	}
+	var records []*TraceRecord
+	if err := rlp.DecodeBytes(data, &records); err != nil {
+		glog.V(logger.Error).Infof("invalid trace record array RLP for hash %x: %v", hash, err)
+		return nil, false
+	}
+	return records, true
+}
+
+// WriteBlockTraces stores the trace records of the transactions of the block
+// with the given hash.
+func WriteBlockTraces(db ethdb.Putter, hash common.Hash, records []*TraceRecord) error {
+	if records == nil {
+		records = []*TraceRecord{}
+	}
+	data, err := rlp.EncodeToBytes(records)
+	if err != nil {
+		return err
+	}
+	return db.Put(append(blockTracesPrefix, hash.Bytes()...), data)
+}
+
+// GetTraceIndexHead retrieves the hash of the last block indexed by the trace
+// index.
+func GetTraceIndexHead(db ethdb.Database) common.Hash {
+	data, _ := db.Get(traceIndexHeadKey)
+	if len(data) == 0 {
+		return common.Hash{}
+	}
+	return common.BytesToHash(data)
+}
+
+// WriteTraceIndexHead stores the hash of the last block indexed by the trace
+// index.
+func WriteTraceIndexHead(db ethdb.Putter, hash common.Hash) error {
+	return db.Put(traceIndexHeadKey, hash.Bytes())
+}
@@ -17,8 +17,8 @@
 package core
 
 import (
+	"bytes"
 	"errors"
-	"fmt"
 	"math/big"
 	"sort"
 	"sync"
@@ -27,9 +27,11 @@ import (
 	"github.com/ellaism/go-ellaism/common"
 	"github.com/ellaism/go-ellaism/core/state"
 	"github.com/ellaism/go-ellaism/core/types"
+	"github.com/ellaism/go-ellaism/core/vm"
 	"github.com/ellaism/go-ellaism/event"
 	"github.com/ellaism/go-ellaism/logger"
 	"github.com/ellaism/go-ellaism/logger/glog"
+	"github.com/hashicorp/golang-lru"
 )
 
 var (
@@ -43,12 +45,108 @@ var (
 	ErrIntrinsicGas       = errors.New("Intrinsic gas too low")
 	ErrGasLimit           = errors.New("Exceeds block gas limit")
 	ErrNegativeValue      = errors.New("Negative value")
+	ErrKnownTransaction   = errors.New("Known transaction")
+	ErrOversizedData      = errors.New("Exceeds maximum transaction size")
+	ErrTxGasLimit         = errors.New("Exceeds maximum gas per transaction")
+	ErrInitCodeSize       = errors.New("Exceeds maximum init code size")
 )
 
+// txRejectionReasons are the machine readable reasons of the errors the pool
+// rejects transactions with.
+var txRejectionReasons = map[error]string{
+	types.ErrTxTypeNotSupported: "txTypeNotSupported",
+	ErrInvalidSender:            "invalidSender",
+	ErrNonce:                    "nonceTooLow",
+	ErrCheap:                    "underpriced",
+	ErrNonExistentAccount:       "unknownAccount",
+	ErrInsufficientFunds:        "insufficientFunds",
+	ErrIntrinsicGas:             "intrinsicGasTooLow",
+	ErrGasLimit:                 "exceedsBlockGasLimit",
+	ErrNegativeValue:            "negativeValue",
+	ErrKnownTransaction:         "alreadyKnown",
+	ErrOversizedData:            "oversized",
+	ErrTxGasLimit:               "exceedsTxGasLimit",
+	ErrInitCodeSize:             "initCodeTooLarge",
+}
+
+// TxRejectionReason returns the machine readable reason of an error the pool
+// rejected a transaction with, or an empty string if the transaction wasn't
+// rejected for itself, e.g. when the state is unavailable.
+func TxRejectionReason(err error) string {
+	return txRejectionReasons[err]
+}
+
 const (
-	maxQueued = 64 // max limit of queued txs per address
+	evictionInterval     = time.Minute // Time interval to check for queued transactions exceeding their lifetime
+	underpricedCacheSize = 4096        // Number of recently rejected underpriced transaction hashes to remember
 )
 
+// TxPoolConfig are the configuration parameters of the transaction pool.
+type TxPoolConfig struct {
+	AccountSlots uint64 // Number of executable transactions guaranteed per account
+	GlobalSlots  uint64 // Maximum number of executable transactions of all accounts
+	AccountQueue uint64 // Maximum number of non-executable transactions per account
+	GlobalQueue  uint64 // Maximum number of non-executable transactions of all accounts
+
+	Lifetime time.Duration // Maximum time the transactions of an inactive account are queued for
+
+	MaxTxSize       uint64 // Maximum size of an encoded transaction, in bytes
+	MaxTxGas        uint64 // Maximum gas limit of a transaction below the block gas limit, unlimited if zero
+	MaxInitCodeSize uint64 // Maximum size of the init code of a contract creation, in bytes
+
+	Locals    []common.Address // Senders whose transactions are treated as local wherever they come from
+	NoLocals  bool             // Whether to treat the transactions submitted through this node as remote
+	Journal   string           // Journal of local transactions surviving node restarts, disabled if empty
+	Rejournal time.Duration    // Time interval to regenerate the local transaction journal
+}
+
+// DefaultTxPoolConfig contains the default configuration of the transaction
+// pool.
+var DefaultTxPoolConfig = TxPoolConfig{
+	AccountSlots: 16,
+	GlobalSlots:  4096,
+	AccountQueue: 64,
+	GlobalQueue:  1024,
+
+	Lifetime: 3 * time.Hour,
+
+	MaxTxSize:       128 * 1024,
+	MaxInitCodeSize: 2 * DefaultMaxCodeSize,
+
+	Journal:   "transactions.rlp",
+	Rejournal: time.Hour,
+}
+
+// sanitize returns the configuration with the unset limits replaced by the
+// defaults.
+func (config TxPoolConfig) sanitize() TxPoolConfig {
+	if config.AccountSlots == 0 {
+		config.AccountSlots = DefaultTxPoolConfig.AccountSlots
+	}
+	if config.GlobalSlots == 0 {
+		config.GlobalSlots = DefaultTxPoolConfig.GlobalSlots
+	}
+	if config.AccountQueue == 0 {
+		config.AccountQueue = DefaultTxPoolConfig.AccountQueue
+	}
+	if config.GlobalQueue == 0 {
+		config.GlobalQueue = DefaultTxPoolConfig.GlobalQueue
+	}
+	if config.Lifetime == 0 {
+		config.Lifetime = DefaultTxPoolConfig.Lifetime
+	}
+	if config.MaxTxSize == 0 {
+		config.MaxTxSize = DefaultTxPoolConfig.MaxTxSize
+	}
+	if config.MaxInitCodeSize == 0 {
+		config.MaxInitCodeSize = DefaultTxPoolConfig.MaxInitCodeSize
+	}
+	if config.Rejournal < time.Second {
+		config.Rejournal = DefaultTxPoolConfig.Rejournal
+	}
+	return config
+}
+
 type stateFn func() (*state.StateDB, error)
 
 // TxPool contains all currently known transactions. Transactions
@@ -58,42 +156,96 @@ type stateFn func() (*state.StateDB, error)
 // The pool separates processable transactions (which can be applied to the
 // current state) and future transactions. Transactions move between those
 // two states over time as they are received and processed.
+//
+// The number of transactions is limited per account and globally. When the
+// executable transactions exceed the global slots, the accounts holding the
+// most transactions over their guaranteed slots lose their highest nonces
+// first; when the queued ones exceed the global queue, the cheapest are
+// dropped. Accounts which don't see a transaction queued or promoted for the
+// configured lifetime have their queued transactions dropped.
+//
+// Transactions of local senders, those which submitted transactions through
+// this node or were configured as such, are exempt from the minimum gas price
+// and the limits, and are journaled to disk.
 type TxPool struct {
 	config       *ChainConfig
+	txConfig     TxPoolConfig
 	signer       types.Signer
 	currentState stateFn // The state function which will allow us to do some pre checks
 	pendingState *state.ManagedState
 	gasLimit     func() *big.Int // The current gas limit function callback
 	minGasPrice  *big.Int
 	eventMux     *event.TypeMux
-	events       event.Subscription
-	localTx      *txSet
+	events       *event.TypeMuxSubscription
+	txFeed       event.Feed                  // Delivers NewTxsEvent for transactions entering the pool
+	dropFeed     event.Feed                  // Delivers DroppedTxsEvent for transactions leaving the pool
+	drops        []DroppedTx                 // Transactions which left the pool since the last DroppedTxsEvent
+	scope        event.SubscriptionScope     // Ends the feed subscriptions when the pool stops
+	locals       map[common.Address]struct{} // Senders whose transactions are local
+	journal      *txJournal                  // Journal of local transactions, nil if disabled
+	underpriced  *lru.Cache                  // Hashes of recently rejected underpriced transactions, skipped without validation
 	mu           sync.RWMutex
-	pending      map[common.Hash]*types.Transaction // processable transactions
+	pending      map[common.Hash]*types.Transaction                    // processable transactions
+	accounts     map[common.Address]map[common.Hash]*types.Transaction // processable transactions by sender
 	queue        map[common.Address]map[common.Hash]*types.Transaction
+	beats        map[common.Address]time.Time // Last time each account had a transaction queued or promoted
 
-	wg sync.WaitGroup // for shutdown sync
+	priced        *txPricedList // Eviction order of the non-local queued transactions
+	pendingPriced *txPricedList // Eviction order of the non-local processable transactions
+
+	wg   sync.WaitGroup // for shutdown sync
+	quit chan struct{}
 
 	homestead bool
+	gasTable  *vm.GasTable // Gas table of the pending block, pricing the transaction data
 }
 
-func NewTxPool(config *ChainConfig, eventMux *event.TypeMux, currentStateFn stateFn, gasLimitFn func() *big.Int) *TxPool {
+// NewTxPool creates a transaction pool on top of the block with the given number,
+// validating transactions by the rules of the block following it.
+func NewTxPool(config *ChainConfig, txConfig TxPoolConfig, eventMux *event.TypeMux, currentStateFn stateFn, gasLimitFn func() *big.Int, head *big.Int) *TxPool {
+	next := new(big.Int).Add(head, common.Big1)
+	underpriced, _ := lru.New(underpricedCacheSize)
 	pool := &TxPool{
-		config:       config,
-		signer:       types.NewChainIdSigner(config.GetChainID()),
-		pending:      make(map[common.Hash]*types.Transaction),
-		queue:        make(map[common.Address]map[common.Hash]*types.Transaction),
-		eventMux:     eventMux,
-		currentState: currentStateFn,
-		gasLimit:     gasLimitFn,
-		minGasPrice:  new(big.Int),
-		pendingState: nil,
-		localTx:      newTxSet(),
-		events:       eventMux.Subscribe(ChainHeadEvent{}, GasPriceChanged{}, RemovedTransactionEvent{}),
-	}
-
-	pool.wg.Add(1)
+		config:        config,
+		txConfig:      txConfig.sanitize(),
+		signer:        types.NewChainIdSigner(config.GetChainID()),
+		pending:       make(map[common.Hash]*types.Transaction),
+		accounts:      make(map[common.Address]map[common.Hash]*types.Transaction),
+		queue:         make(map[common.Address]map[common.Hash]*types.Transaction),
+		beats:         make(map[common.Address]time.Time),
+		priced:        new(txPricedList),
+		pendingPriced: new(txPricedList),
+		quit:          make(chan struct{}),
+		eventMux:      eventMux,
+		currentState:  currentStateFn,
+		gasLimit:      gasLimitFn,
+		minGasPrice:   new(big.Int),
+		homestead:     config.IsHomestead(head),
+		gasTable:      config.GasTable(next),
+		pendingState:  nil,
+		locals:        make(map[common.Address]struct{}),
+		underpriced:   underpriced,
+		events:        eventMux.Subscribe(ChainHeadEvent{}, GasPriceChanged{}, RemovedTransactionEvent{}),
+	}
+
+	for _, addr := range pool.txConfig.Locals {
+		pool.locals[addr] = struct{}{}
+	}
+	// Load the local transactions left from the previous run and start a new
+	// journal with the ones still valid
+	if pool.txConfig.Journal != "" {
+		pool.journal = newTxJournal(pool.txConfig.Journal)
+
+		if err := pool.journal.load(pool.AddLocal); err != nil {
+			glog.V(logger.Warn).Infof("Failed to load transaction journal: %v", err)
+		}
+		if err := pool.journal.rotate(pool.localTransactions()); err != nil {
+			glog.V(logger.Warn).Infof("Failed to rotate transaction journal: %v", err)
+		}
+	}
+	pool.wg.Add(2)
 	go pool.eventLoop()
+	go pool.maintenanceLoop()
 
 	return pool
 }
@@ -111,11 +263,18 @@ func (pool *TxPool) eventLoop() {
 			if ev.Block != nil && pool.config.IsHomestead(ev.Block.Number()) {
 				pool.homestead = true
 			}
+			if ev.Block != nil {
+				pool.gasTable = pool.config.GasTable(new(big.Int).Add(ev.Block.Number(), common.Big1))
+			}
 
 			pool.resetState()
 			pool.mu.Unlock()
 		case GasPriceChanged:
 			pool.mu.Lock()
+			// A lower price may accept previously rejected transactions
+			if ev.Price.Cmp(pool.minGasPrice) < 0 {
+				pool.underpriced.Purge()
+			}
 			pool.minGasPrice = ev.Price
 			pool.mu.Unlock()
 		case RemovedTransactionEvent:
@@ -124,6 +283,39 @@ func (pool *TxPool) eventLoop() {
 	}
 }
 
+// maintenanceLoop periodically drops the queued transactions of inactive
+// accounts and regenerates the local transaction journal.
+func (pool *TxPool) maintenanceLoop() {
+	defer pool.wg.Done()
+
+	evict := time.NewTicker(evictionInterval)
+	defer evict.Stop()
+
+	journal := time.NewTicker(pool.txConfig.Rejournal)
+	defer journal.Stop()
+
+	for {
+		select {
+		case <-evict.C:
+			pool.mu.Lock()
+			pool.expireQueue()
+			pool.mu.Unlock()
+
+		case <-journal.C:
+			if pool.journal != nil {
+				pool.mu.Lock()
+				if err := pool.journal.rotate(pool.localTransactions()); err != nil {
+					glog.V(logger.Warn).Infof("Failed to rotate transaction journal: %v", err)
+				}
+				pool.mu.Unlock()
+			}
+
+		case <-pool.quit:
+			return
+		}
+	}
+}
+
 func (pool *TxPool) resetState() {
 	currentState, err := pool.currentState()
 	if err != nil {
@@ -161,10 +353,47 @@ func (pool *TxPool) resetState() {
 
 func (pool *TxPool) Stop() {
 	pool.events.Unsubscribe()
+	pool.scope.Close()
+	close(pool.quit)
 	pool.wg.Wait()
+
+	if pool.journal != nil {
+		pool.journal.close()
+	}
 	glog.V(logger.Info).Infoln("Transaction pool stopped")
 }
 
+// SubscribeNewTxsEvent registers a subscription of NewTxsEvent, sent whenever
+// transactions enter the pool.
+func (pool *TxPool) SubscribeNewTxsEvent(ch chan<- NewTxsEvent) event.Subscription {
+	return pool.scope.Track(pool.txFeed.Subscribe(ch))
+}
+
+// SubscribeDroppedTxsEvent registers a subscription of DroppedTxsEvent, sent
+// whenever transactions leave the pool for any reason but being included in a
+// block by it, including those dropped for their nonce being used by a new head.
+func (pool *TxPool) SubscribeDroppedTxsEvent(ch chan<- DroppedTxsEvent) event.Subscription {
+	return pool.scope.Track(pool.dropFeed.Subscribe(ch))
+}
+
+// drop records a transaction leaving the pool for the given reason, to be
+// announced by the next call to announceDrops.
+func (pool *TxPool) drop(tx *types.Transaction, reason string) {
+	pool.drops = append(pool.drops, DroppedTx{Tx: tx, Reason: reason})
+}
+
+// announceDrops sends the transactions which left the pool since the last call
+// to the subscribers. Like the arrivals, they're sent in a goroutine as the
+// subscribers may call back into the pool.
+func (pool *TxPool) announceDrops() {
+	if len(pool.drops) == 0 {
+		return
+	}
+	drops := pool.drops
+	pool.drops = nil
+	go pool.dropFeed.Send(DroppedTxsEvent{drops})
+}
+
 func (pool *TxPool) State() *state.ManagedState {
 	pool.mu.RLock()
 	defer pool.mu.RUnlock()
@@ -172,6 +401,55 @@ func (pool *TxPool) State() *state.ManagedState {
 	return pool.pendingState
 }
 
+// PendingNonce returns the nonce following the highest one of the account's
+// transactions in the pool, whether executable or queued behind a nonce gap,
+// and at least the nonce of its pending state. Sending the next transaction
+// with it never replaces one already in the pool.
+func (pool *TxPool) PendingNonce(addr common.Address) uint64 {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	nonce := pool.executableNonce(addr)
+	for _, tx := range pool.queue[addr] {
+		if tx.Nonce() >= nonce {
+			nonce = tx.Nonce() + 1
+		}
+	}
+	return nonce
+}
+
+// NextNonce returns the lowest nonce of the account, at or above the nonce of
+// its pending state, that no transaction in the pool uses. With queued
+// transactions waiting behind a nonce gap this is the nonce filling the gap,
+// otherwise it is the nonce following the executable transactions.
+func (pool *TxPool) NextNonce(addr common.Address) uint64 {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	nonce := pool.executableNonce(addr)
+	queued := make(map[uint64]bool, len(pool.queue[addr]))
+	for _, tx := range pool.queue[addr] {
+		queued[tx.Nonce()] = true
+	}
+	for queued[nonce] {
+		nonce++
+	}
+	return nonce
+}
+
+// executableNonce returns the nonce following the account's executable
+// transactions. The caller must hold the pool lock.
+func (pool *TxPool) executableNonce(addr common.Address) uint64 {
+	if pool.pendingState != nil {
+		return pool.pendingState.GetNonce(addr)
+	}
+	currentState, err := pool.currentState()
+	if err != nil {
+		return 0
+	}
+	return currentState.GetNonce(addr)
+}
+
 func (pool *TxPool) Stats() (pending int, queued int) {
 	pool.mu.RLock()
 	defer pool.mu.RUnlock()
@@ -213,42 +491,80 @@ func (pool *TxPool) Content() (map[common.Address]map[uint64][]*types.Transactio
 	return pending, queued
 }
 
-// SetLocal marks a transaction as local, skipping gas price
-//  check against local miner minimum in the future
-func (pool *TxPool) SetLocal(tx *types.Transaction) {
-	pool.mu.Lock()
-	defer pool.mu.Unlock()
-	pool.localTx.add(tx.Hash())
+// isLocal reports whether the transactions of the sender are local.
+func (pool *TxPool) isLocal(addr common.Address) bool {
+	_, ok := pool.locals[addr]
+	return ok
+}
+
+// localTransactions returns the pending and queued transactions of the local
+// senders, sorted by nonce.
+func (pool *TxPool) localTransactions() types.Transactions {
+	var txs types.Transactions
+	for _, tx := range pool.pending {
+		if from, _ := tx.From(); pool.isLocal(from) {
+			txs = append(txs, tx)
+		}
+	}
+	for addr, queued := range pool.queue {
+		if pool.isLocal(addr) {
+			for _, tx := range queued {
+				txs = append(txs, tx)
+			}
+		}
+	}
+	sort.Sort(types.TxByNonce(txs))
+	return txs
 }
 
 // validateTx checks whether a transaction is valid according
 // to the consensus rules.
 func (pool *TxPool) validateTx(tx *types.Transaction) (e error) {
-	local := pool.localTx.contains(tx.Hash())
 	defer func() {
 		mlogTxPool.Send(mlogTxPoolValidateTx.SetDetailValues(
 			tx.Hash().Hex(),
 			e,
 		))
 	}()
-	// Drop transactions under our own minimal accepted gas price
-	if !local && pool.minGasPrice.Cmp(tx.GasPrice()) > 0 {
-		e = ErrCheap
+	// Drop transaction types the chain doesn't enable. The pool doesn't know
+	// the block the transaction will be included in, so any fork enabling the
+	// type will do; the miner skips it until the fork is reached.
+	if !pool.config.SupportsTxType(nil, tx.Type()) {
+		e = types.ErrTxTypeNotSupported
 		return
 	}
-
-	currentState, err := pool.currentState()
-	if err != nil {
-		e = err
+	// Drop transactions over the size limits, which cost the network to relay
+	// and the miners to process more than their gas pays for
+	if uint64(tx.Size()) > pool.txConfig.MaxTxSize {
+		e = ErrOversizedData
+		return
+	}
+	if MessageCreatesContract(tx) && uint64(len(tx.Data())) > pool.txConfig.MaxInitCodeSize {
+		e = ErrInitCodeSize
+		return
+	}
+	if pool.txConfig.MaxTxGas > 0 && tx.Gas().Cmp(new(big.Int).SetUint64(pool.txConfig.MaxTxGas)) > 0 {
+		e = ErrTxGasLimit
 		return
 	}
-
 	from, err := types.Sender(pool.signer, tx)
 	if err != nil {
 		e = ErrInvalidSender
 		return
 	}
 
+	// Drop remote transactions under our own minimal accepted gas price
+	if !pool.isLocal(from) && pool.minGasPrice.Cmp(tx.GasPrice()) > 0 {
+		e = ErrCheap
+		return
+	}
+
+	currentState, err := pool.currentState()
+	if err != nil {
+		e = err
+		return
+	}
+
 	// Make sure the account exist. Non existent accounts
 	// haven't got funds and well therefor never pass.
 	if !currentState.Exist(from) {
@@ -284,7 +600,7 @@ func (pool *TxPool) validateTx(tx *types.Transaction) (e error) {
 		return
 	}
 
-	intrGas := IntrinsicGas(tx.Data(), MessageCreatesContract(tx), pool.homestead)
+	intrGas := IntrinsicGas(tx.Data(), tx.AccessList(), MessageCreatesContract(tx), pool.homestead, pool.gasTable)
 	if tx.Gas().Cmp(intrGas) < 0 {
 		e = ErrIntrinsicGas
 		return
@@ -292,19 +608,37 @@ func (pool *TxPool) validateTx(tx *types.Transaction) (e error) {
 	return // e=nil
 }
 
-// validate and queue transactions.
-func (self *TxPool) add(tx *types.Transaction) error {
+// validate and queue transactions. Local transactions skip the cache of
+// underpriced ones.
+func (self *TxPool) add(tx *types.Transaction, local bool) error {
 	hash := tx.Hash()
 
 	if self.pending[hash] != nil {
-		return fmt.Errorf("Known transaction (%x)", hash[:4])
+		return ErrKnownTransaction
+	}
+	// Reject transactions recently found underpriced without validating them
+	// again, as peers keep gossiping them.
+	if !local && self.underpriced.Contains(hash) {
+		return ErrCheap
 	}
 	err := self.validateTx(tx)
 	if err != nil {
+		if err == ErrCheap {
+			self.underpriced.Add(hash, nil)
+		}
 		return err
 	}
 	self.queueTx(hash, tx)
 
+	// we can ignore the error here because From is
+	// verified in ValidateTransaction.
+	f, _ := types.Sender(self.signer, tx)
+	if self.journal != nil && self.isLocal(f) {
+		if err := self.journal.insert(tx); err != nil && err != errNoActiveJournal {
+			glog.V(logger.Warn).Infof("Failed to journal local transaction: %v", err)
+		}
+	}
+
 	var toName, toLogName string
 	if to := tx.To(); to != nil {
 		toName = common.Bytes2Hex(to[:4])
@@ -313,9 +647,6 @@ func (self *TxPool) add(tx *types.Transaction) error {
 		toName = "[NEW_CONTRACT]"
 		toLogName = "[NEW_CONTRACT]"
 	}
-	// we can ignore the error here because From is
-	// verified in ValidateTransaction.
-	f, _ := types.Sender(self.signer, tx)
 	from := common.Bytes2Hex(f[:4])
 
 	if logger.MlogEnabled() {
@@ -340,6 +671,10 @@ func (self *TxPool) queueTx(hash common.Hash, tx *types.Transaction) {
 		self.queue[from] = make(map[common.Hash]*types.Transaction)
 	}
 	self.queue[from][hash] = tx
+	self.beats[from] = time.Now()
+	if !self.isLocal(from) {
+		self.priced.Put(hash, from, tx, self.beats[from])
+	}
 }
 
 // addTx will add a transaction to the pending (processable queue) list of transactions
@@ -350,7 +685,7 @@ func (pool *TxPool) addTx(hash common.Hash, addr common.Address, tx *types.Trans
 	}
 
 	if _, ok := pool.pending[hash]; !ok {
-		pool.pending[hash] = tx
+		pool.setPending(hash, addr, tx)
 
 		// Increment the nonce on the pending state. This can only happen if
 		// the nonce is +1 to the previous one.
@@ -358,7 +693,34 @@ func (pool *TxPool) addTx(hash common.Hash, addr common.Address, tx *types.Trans
 		// Notify the subscribers. This event is posted in a goroutine
 		// because it's possible that somewhere during the post "Remove transaction"
 		// gets called which will then wait for the global tx pool lock and deadlock.
-		go pool.eventMux.Post(TxPreEvent{tx})
+		go func() {
+			pool.eventMux.Post(TxPreEvent{tx})
+			pool.txFeed.Send(NewTxsEvent{types.Transactions{tx}})
+		}()
+	}
+}
+
+// setPending adds a transaction to the processable ones and their indexes.
+func (pool *TxPool) setPending(hash common.Hash, addr common.Address, tx *types.Transaction) {
+	pool.pending[hash] = tx
+	if pool.accounts[addr] == nil {
+		pool.accounts[addr] = make(map[common.Hash]*types.Transaction)
+	}
+	pool.accounts[addr][hash] = tx
+	if !pool.isLocal(addr) {
+		pool.pendingPriced.Put(hash, addr, tx, time.Time{})
+	}
+}
+
+// deletePending removes a transaction from the processable ones. Its eviction
+// entry is left to be skipped once it reaches the top of the heap.
+func (pool *TxPool) deletePending(hash common.Hash, addr common.Address) {
+	delete(pool.pending, hash)
+	if txs := pool.accounts[addr]; txs != nil {
+		delete(txs, hash)
+		if len(txs) == 0 {
+			delete(pool.accounts, addr)
+		}
 	}
 }
 
@@ -367,7 +729,27 @@ func (self *TxPool) Add(tx *types.Transaction) error {
 	self.mu.Lock()
 	defer self.mu.Unlock()
 
-	if err := self.add(tx); err != nil {
+	if err := self.add(tx, false); err != nil {
+		return err
+	}
+	self.checkQueue()
+	return nil
+}
+
+// AddLocal queues a single transaction submitted through this node in the pool
+// if it is valid, marking its sender as local unless locals are disabled.
+func (self *TxPool) AddLocal(tx *types.Transaction) error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	if !self.txConfig.NoLocals {
+		from, err := types.Sender(self.signer, tx)
+		if err != nil {
+			return ErrInvalidSender
+		}
+		self.locals[from] = struct{}{}
+	}
+	if err := self.add(tx, !self.txConfig.NoLocals); err != nil {
 		return err
 	}
 	self.checkQueue()
@@ -380,7 +762,7 @@ func (self *TxPool) AddTransactions(txs []*types.Transaction) {
 	defer self.mu.Unlock()
 
 	for _, tx := range txs {
-		if err := self.add(tx); err != nil {
+		if err := self.add(tx, false); err != nil {
 			glog.V(logger.Debug).Infoln("tx error:", err)
 		} else {
 			h := tx.Hash()
@@ -453,6 +835,7 @@ func (self *TxPool) RemoveTransactions(txs types.Transactions) {
 	for _, tx := range txs {
 		self.removeTx(tx.Hash())
 	}
+	self.announceDrops()
 }
 
 // RemoveTx removes the transaction with the given hash from the pool.
@@ -460,17 +843,24 @@ func (pool *TxPool) RemoveTx(hash common.Hash) {
 	pool.mu.Lock()
 	defer pool.mu.Unlock()
 	pool.removeTx(hash)
+	pool.announceDrops()
 }
 
 func (pool *TxPool) removeTx(hash common.Hash) {
 	// delete from pending pool
-	delete(pool.pending, hash)
+	if tx, ok := pool.pending[hash]; ok {
+		from, _ := tx.From() // already validated
+		pool.drop(tx, TxDropRemoved)
+		pool.deletePending(hash, from)
+	}
 	// delete from queue
 	for address, txs := range pool.queue {
-		if _, ok := txs[hash]; ok {
+		if tx, ok := txs[hash]; ok {
+			pool.drop(tx, TxDropRemoved)
 			if len(txs) == 1 {
 				// if only one tx, remove entire address entry.
 				delete(pool.queue, address)
+				delete(pool.beats, address)
 			} else {
 				delete(txs, hash)
 			}
@@ -502,10 +892,15 @@ func (pool *TxPool) checkQueue() {
 		promote = promote[:0]
 		for hash, tx := range txs {
 			// Drop processed or out of fund transactions
-			if tx.Nonce() < trueNonce || balance.Cmp(tx.Cost()) < 0 {
+			if past := tx.Nonce() < trueNonce; past || balance.Cmp(tx.Cost()) < 0 {
 				if glog.V(logger.Core) {
 					glog.Infof("removed tx (%v) from pool queue: low tx nonce or out of funds\n", tx)
 				}
+				if past {
+					pool.drop(tx, TxDropNonceUsed)
+				} else {
+					pool.drop(tx, TxDropInvalidated)
+				}
 				delete(txs, hash)
 				continue
 			}
@@ -518,11 +913,12 @@ func (pool *TxPool) checkQueue() {
 		for i, entry := range promote {
 			// If we reached a gap in the nonces, enforce transaction limit and stop
 			if entry.Nonce() > guessedNonce {
-				if len(promote)-i > maxQueued {
+				if len(promote)-i > int(pool.txConfig.AccountQueue) {
 					if glog.V(logger.Debug) {
 						glog.Infof("Queued tx limit exceeded for %s. Tx %s removed\n", common.PP(address[:]), common.PP(entry.hash[:]))
 					}
-					for _, drop := range promote[i+maxQueued:] {
+					for _, drop := range promote[i+int(pool.txConfig.AccountQueue):] {
+						pool.drop(drop.Transaction, TxDropEvicted)
 						delete(txs, drop.hash)
 					}
 				}
@@ -531,6 +927,7 @@ func (pool *TxPool) checkQueue() {
 			// Otherwise promote the transaction and move the guess nonce if needed
 			pool.addTx(entry.hash, address, entry.Transaction)
 			delete(txs, entry.hash)
+			pool.beats[address] = time.Now()
 
 			if entry.Nonce() == guessedNonce {
 				guessedNonce++
@@ -539,8 +936,155 @@ func (pool *TxPool) checkQueue() {
 		// Delete the entire queue entry if it became empty.
 		if len(txs) == 0 {
 			delete(pool.queue, address)
+			delete(pool.beats, address)
 		}
 	}
+	// Enforce the global limits on the resulting pool
+	pool.truncatePending()
+	pool.truncateQueue()
+	pool.announceDrops()
+}
+
+// truncatePending drops executable transactions while there are more than the
+// global slots. The accounts holding the most transactions over their guaranteed
+// slots lose their highest nonces first, then the cheapest transactions of all
+// non-local accounts go, postponing the later nonces of their senders.
+func (pool *TxPool) truncatePending() {
+	// Rebuild the eviction heap once it's mostly made of stale entries
+	if pool.pendingPriced.Len() > 2*len(pool.pending) {
+		entries := make([]*pricedEntry, 0, len(pool.pending))
+		for address, txs := range pool.accounts {
+			if pool.isLocal(address) {
+				continue
+			}
+			for hash, tx := range txs {
+				entries = append(entries, &pricedEntry{hash: hash, addr: address, tx: tx})
+			}
+		}
+		pool.pendingPriced.Reheap(entries)
+	}
+	for uint64(len(pool.pending)) > pool.txConfig.GlobalSlots {
+		var (
+			spammer common.Address
+			most    int
+		)
+		for address, txs := range pool.accounts {
+			if uint64(len(txs)) <= pool.txConfig.AccountSlots || pool.isLocal(address) {
+				continue
+			}
+			if len(txs) > most || (len(txs) == most && bytes.Compare(address[:], spammer[:]) < 0) {
+				spammer, most = address, len(txs)
+			}
+		}
+		if most == 0 {
+			break
+		}
+		var drop *types.Transaction
+		for _, tx := range pool.accounts[spammer] {
+			if drop == nil || tx.Nonce() > drop.Nonce() {
+				drop = tx
+			}
+		}
+		if glog.V(logger.Debug) {
+			glog.Infof("Pending tx limit exceeded. Tx %s of %s removed\n", common.PP(drop.Hash().Bytes()), common.PP(spammer[:]))
+		}
+		pool.drop(drop, TxDropEvicted)
+		pool.deletePending(drop.Hash(), spammer)
+		pool.pendingState.SetNonce(spammer, drop.Nonce())
+	}
+	current := func(entry *pricedEntry) (bool, time.Time) {
+		if _, ok := pool.pending[entry.hash]; !ok || pool.isLocal(entry.addr) {
+			return false, time.Time{}
+		}
+		return true, time.Time{}
+	}
+	for uint64(len(pool.pending)) > pool.txConfig.GlobalSlots {
+		drop := pool.pendingPriced.Pop(current)
+		if drop == nil {
+			break
+		}
+		if glog.V(logger.Debug) {
+			glog.Infof("Pending tx limit exceeded. Tx %s of %s removed\n", common.PP(drop.hash[:]), common.PP(drop.addr[:]))
+		}
+		pool.drop(drop.tx, TxDropEvicted)
+		pool.deletePending(drop.hash, drop.addr)
+
+		// Move the later transactions of the sender back to the future queue
+		for hash, tx := range pool.accounts[drop.addr] {
+			if tx.Nonce() > drop.tx.Nonce() {
+				pool.queueTx(hash, tx)
+				pool.deletePending(hash, drop.addr)
+			}
+		}
+		pool.pendingState.SetNonce(drop.addr, drop.tx.Nonce())
+	}
+}
+
+// truncateQueue drops non-executable transactions while there are more than the
+// global queue allows, cheapest first. Of equally priced transactions, those of
+// the longest inactive accounts and the highest nonces go first.
+func (pool *TxPool) truncateQueue() {
+	var queued int
+	for address, txs := range pool.queue {
+		if !pool.isLocal(address) {
+			queued += len(txs)
+		}
+	}
+	// Rebuild the eviction heap once it's mostly made of stale entries
+	if pool.priced.Len() > 2*queued {
+		entries := make([]*pricedEntry, 0, queued)
+		for address, txs := range pool.queue {
+			if pool.isLocal(address) {
+				continue
+			}
+			for hash, tx := range txs {
+				entries = append(entries, &pricedEntry{hash: hash, addr: address, tx: tx, beat: pool.beats[address]})
+			}
+		}
+		pool.priced.Reheap(entries)
+	}
+	if uint64(queued) <= pool.txConfig.GlobalQueue {
+		return
+	}
+	current := func(entry *pricedEntry) (bool, time.Time) {
+		if _, ok := pool.queue[entry.addr][entry.hash]; !ok || pool.isLocal(entry.addr) {
+			return false, time.Time{}
+		}
+		return true, pool.beats[entry.addr]
+	}
+	for ; uint64(queued) > pool.txConfig.GlobalQueue; queued-- {
+		drop := pool.priced.Pop(current)
+		if drop == nil {
+			break
+		}
+		if glog.V(logger.Debug) {
+			glog.Infof("Queued tx limit exceeded. Tx %s of %s removed\n", common.PP(drop.hash[:]), common.PP(drop.addr[:]))
+		}
+		pool.drop(drop.tx, TxDropEvicted)
+		delete(pool.queue[drop.addr], drop.hash)
+		if len(pool.queue[drop.addr]) == 0 {
+			delete(pool.queue, drop.addr)
+			delete(pool.beats, drop.addr)
+		}
+	}
+}
+
+// expireQueue drops the queued transactions of the accounts which didn't have
+// a transaction queued or promoted for the configured lifetime.
+func (pool *TxPool) expireQueue() {
+	for address, txs := range pool.queue {
+		if !pool.isLocal(address) && time.Since(pool.beats[address]) > pool.txConfig.Lifetime {
+			if glog.V(logger.Debug) {
+				glog.Infof("Queued txs of %s expired\n", common.PP(address[:]))
+			}
+			for _, tx := range txs {
+				pool.drop(tx, TxDropExpired)
+			}
+			delete(pool.queue, address)
+			delete(pool.beats, address)
+		}
+	}
+	pool.announceDrops()
 }
 
 // validatePool removes invalid and processed transactions from the main pool.
@@ -573,7 +1117,12 @@ func (pool *TxPool) validatePool() {
 			if glog.V(logger.Core) {
 				glog.Infof("removed tx (%v) from pool: low tx nonce or out of funds\n", tx)
 			}
-			delete(pool.pending, hash)
+			if past {
+				pool.drop(tx, TxDropNonceUsed)
+			} else {
+				pool.drop(tx, TxDropInvalidated)
+			}
+			pool.deletePending(hash, sender)
 
 			// Track the smallest invalid nonce to postpone subsequent transactions
 			if !past {
@@ -592,10 +1141,11 @@ func (pool *TxPool) validatePool() {
 					glog.Infof("postponed tx (%v) due to introduced gap\n", tx)
 				}
 				pool.queueTx(hash, tx)
-				delete(pool.pending, hash)
+				pool.deletePending(hash, sender)
 			}
 		}
 	}
+	pool.announceDrops()
 }
 
 type txQueue []txQueueEntry
@@ -609,49 +1159,3 @@ type txQueueEntry struct {
 func (q txQueue) Len() int           { return len(q) }
 func (q txQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
 func (q txQueue) Less(i, j int) bool { return q[i].Nonce() < q[j].Nonce() }
-
-// txSet represents a set of transaction hashes in which entries
-//  are automatically dropped after txSetDuration time
-type txSet struct {
-	txMap          map[common.Hash]struct{}
-	txOrd          map[uint64]txOrdType
-	addPtr, delPtr uint64
-}
-
-const txSetDuration = time.Hour * 2
-
-// txOrdType represents an entry in the time-ordered list of transaction hashes
-type txOrdType struct {
-	hash common.Hash
-	time time.Time
-}
-
-// newTxSet creates a new transaction set
-func newTxSet() *txSet {
-	return &txSet{
-		txMap: make(map[common.Hash]struct{}),
-		txOrd: make(map[uint64]txOrdType),
-	}
-}
-
-// contains returns true if the set contains the given transaction hash
-// (not thread safe, should be called from a locked environment)
-func (self *txSet) contains(hash common.Hash) bool {
-	_, ok := self.txMap[hash]
-	return ok
-}
-
-// add adds a transaction hash to the set, then removes entries older than txSetDuration
-// (not thread safe, should be called from a locked environment)
-func (self *txSet) add(hash common.Hash) {
-	self.txMap[hash] = struct{}{}
-	now := time.Now()
-	self.txOrd[self.addPtr] = txOrdType{hash: hash, time: now}
-	self.addPtr++
-	delBefore := now.Add(-txSetDuration)
-	for self.delPtr < self.addPtr && self.txOrd[self.delPtr].time.Before(delBefore) {
-		delete(self.txMap, self.txOrd[self.delPtr].hash)
-		delete(self.txOrd, self.delPtr)
-		self.delPtr++
-	}
-}
@@ -18,8 +18,11 @@ package core
 
 import (
 	"crypto/ecdsa"
+	"io/ioutil"
 	"math/big"
+	"os"
 	"testing"
+	"time"
 
 	"github.com/ellaism/go-ellaism/common"
 	"github.com/ellaism/go-ellaism/core/state"
@@ -30,7 +33,11 @@ import (
 )
 
 func transaction(nonce uint64, gaslimit *big.Int, key *ecdsa.PrivateKey) *types.Transaction {
-	tx, _ := types.NewTransaction(nonce, common.Address{}, big.NewInt(100), gaslimit, big.NewInt(1), nil).SignECDSA(key)
+	return pricedTransaction(nonce, gaslimit, big.NewInt(1), key)
+}
+
+func pricedTransaction(nonce uint64, gaslimit, gasprice *big.Int, key *ecdsa.PrivateKey) *types.Transaction {
+	tx, _ := types.NewTransaction(nonce, common.Address{}, big.NewInt(100), gaslimit, gasprice, nil).SignECDSA(key)
 	return tx
 }
 
@@ -38,17 +45,58 @@ func setupTxPool() (*TxPool, *ecdsa.PrivateKey) {
 	db, _ := ethdb.NewMemDatabase()
 	statedb, _ := state.New(common.Hash{}, db)
 
-	var m event.TypeMux
+	config := DefaultTxPoolConfig
+	config.Journal = ""
+
 	key, _ := crypto.GenerateKey()
-	newPool := NewTxPool(testChainConfig(), &m, func() (*state.StateDB, error) { return statedb, nil }, func() *big.Int { return big.NewInt(1000000) })
-	newPool.resetState()
-	return newPool, key
+	return newTestTxPool(config, statedb), key
+}
+
+func newTestTxPool(config TxPoolConfig, statedb *state.StateDB) *TxPool {
+	var m event.TypeMux
+	pool := NewTxPool(testChainConfig(), config, &m, func() (*state.StateDB, error) { return statedb, nil }, func() *big.Int { return big.NewInt(1000000) }, new(big.Int))
+	pool.resetState()
+	return pool
 }
 
 func deriveSender(tx *types.Transaction) (common.Address, error) {
 	return types.Sender(types.BasicSigner{}, tx)
 }
 
+// Tests that a new pool prices the transaction data by the gas table of the block
+// following the head it's created on, before any chain head event.
+func TestTransactionPoolInitialGasTable(t *testing.T) {
+	config := testChainConfig()
+	config.Forks = append(config.Forks, &Fork{
+		Name:  "EIP2028",
+		Block: big.NewInt(10),
+		Features: []*ForkFeature{
+			{ID: "gastable", Options: ChainFeatureConfigOptions{"type": "eip2028"}},
+		},
+	})
+	db, _ := ethdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, db)
+	txConfig := DefaultTxPoolConfig
+	txConfig.Journal = ""
+
+	for _, tt := range []struct {
+		head int64
+		want *big.Int
+	}{
+		{8, DefaultHomeSteadGasTable.TxDataNonZero},
+		{9, DefaultEIP2028GasTable.TxDataNonZero},
+	} {
+		pool := NewTxPool(config, txConfig, new(event.TypeMux), func() (*state.StateDB, error) { return statedb, nil }, func() *big.Int { return big.NewInt(1000000) }, big.NewInt(tt.head))
+		if have := pool.gasTable.TxDataNonZero; have.Cmp(tt.want) != 0 {
+			t.Errorf("head %d: non-zero data cost mismatch: have %v, want %v", tt.head, have, tt.want)
+		}
+		if !pool.homestead {
+			t.Errorf("head %d: homestead rules not in force", tt.head)
+		}
+		pool.Stop()
+	}
+}
+
 func TestInvalidTransactions(t *testing.T) {
 	pool, key := setupTxPool()
 
@@ -83,8 +131,7 @@ func TestInvalidTransactions(t *testing.T) {
 		t.Error("expected", ErrCheap, "got", err)
 	}
 
-	pool.SetLocal(tx)
-	if err := pool.Add(tx); err != nil {
+	if err := pool.AddLocal(tx); err != nil {
 		t.Error("expected", nil, "got", err)
 	}
 }
@@ -189,14 +236,14 @@ func TestTransactionChainFork(t *testing.T) {
 	resetState()
 
 	tx := transaction(0, big.NewInt(100000), key)
-	if err := pool.add(tx); err != nil {
+	if err := pool.add(tx, false); err != nil {
 		t.Error("didn't expect error", err)
 	}
 	pool.RemoveTransactions([]*types.Transaction{tx})
 
 	// reset the pool's internal state
 	resetState()
-	if err := pool.add(tx); err != nil {
+	if err := pool.add(tx, false); err != nil {
 		t.Error("didn't expect error", err)
 	}
 }
@@ -216,10 +263,10 @@ func TestTransactionDoubleNonce(t *testing.T) {
 
 	tx := transaction(0, big.NewInt(100000), key)
 	tx2 := transaction(0, big.NewInt(1000000), key)
-	if err := pool.add(tx); err != nil {
+	if err := pool.add(tx, false); err != nil {
 		t.Error("didn't expect error", err)
 	}
-	if err := pool.add(tx2); err != nil {
+	if err := pool.add(tx2, false); err != nil {
 		t.Error("didn't expect error", err)
 	}
 
@@ -235,7 +282,7 @@ func TestMissingNonce(t *testing.T) {
 	currentState, _ := pool.currentState()
 	currentState.AddBalance(addr, big.NewInt(100000000000000))
 	tx := transaction(1, big.NewInt(100000), key)
-	if err := pool.add(tx); err != nil {
+	if err := pool.add(tx, false); err != nil {
 		t.Error("didn't expect error", err)
 	}
 	if len(pool.pending) != 0 {
@@ -246,6 +293,46 @@ func TestMissingNonce(t *testing.T) {
 	}
 }
 
+// Tests that the pending nonce of an account counts its queued transactions,
+// and that the next nonce fills the first gap before them.
+func TestPendingAndNextNonce(t *testing.T) {
+	pool, key := setupTxPool()
+	addr := crypto.PubkeyToAddress(key.PublicKey)
+	currentState, _ := pool.currentState()
+	currentState.AddBalance(addr, big.NewInt(100000000000000))
+
+	if nonce := pool.PendingNonce(addr); nonce != 0 {
+		t.Fatalf("pending nonce of empty account: have %d, want 0", nonce)
+	}
+	if nonce := pool.NextNonce(addr); nonce != 0 {
+		t.Fatalf("next nonce of empty account: have %d, want 0", nonce)
+	}
+	// Executable transactions 0 and 1, queued 3, 4 and 6
+	for _, nonce := range []uint64{0, 1, 3, 4, 6} {
+		if err := pool.add(transaction(nonce, big.NewInt(100000), key), false); err != nil {
+			t.Fatalf("failed to add transaction %d: %v", nonce, err)
+		}
+	}
+	pool.checkQueue()
+	if nonce := pool.PendingNonce(addr); nonce != 7 {
+		t.Errorf("pending nonce: have %d, want 7", nonce)
+	}
+	if nonce := pool.NextNonce(addr); nonce != 2 {
+		t.Errorf("next nonce: have %d, want 2", nonce)
+	}
+	// Filling the first gap promotes the transactions queued behind it
+	if err := pool.add(transaction(2, big.NewInt(100000), key), false); err != nil {
+		t.Fatalf("failed to add transaction 2: %v", err)
+	}
+	pool.checkQueue()
+	if nonce := pool.PendingNonce(addr); nonce != 7 {
+		t.Errorf("pending nonce after filling gap: have %d, want 7", nonce)
+	}
+	if nonce := pool.NextNonce(addr); nonce != 5 {
+		t.Errorf("next nonce after filling gap: have %d, want 5", nonce)
+	}
+}
+
 func TestNonceRecovery(t *testing.T) {
 	const n = 10
 	pool, key := setupTxPool()
@@ -333,6 +420,116 @@ func TestTransactionDropping(t *testing.T) {
 	}
 }
 
+// Tests that transactions over the configured size and gas limits are rejected
+// with their own errors and rejection reasons.
+func TestTransactionSizeLimits(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, db)
+
+	config := DefaultTxPoolConfig
+	config.Journal = ""
+	config.MaxTxSize = 1024
+	config.MaxTxGas = 50000
+	config.MaxInitCodeSize = 256
+	pool := newTestTxPool(config, statedb)
+	defer pool.Stop()
+
+	key, _ := crypto.GenerateKey()
+	account, _ := deriveSender(transaction(0, big.NewInt(0), key))
+	statedb.AddBalance(account, big.NewInt(1000000000))
+
+	create := func(gas int64, size int) *types.Transaction {
+		tx, _ := types.NewContractCreation(0, big.NewInt(0), big.NewInt(gas), big.NewInt(1), make([]byte, size)).SignECDSA(key)
+		return tx
+	}
+	call := func(gas int64, size int) *types.Transaction {
+		tx, _ := types.NewTransaction(0, common.Address{}, big.NewInt(0), big.NewInt(gas), big.NewInt(1), make([]byte, size)).SignECDSA(key)
+		return tx
+	}
+	tests := []struct {
+		tx     *types.Transaction
+		err    error
+		reason string
+	}{
+		{call(50000, 2048), ErrOversizedData, "oversized"},
+		{create(50000, 512), ErrInitCodeSize, "initCodeTooLarge"},
+		{call(50001, 0), ErrTxGasLimit, "exceedsTxGasLimit"},
+		{call(20000, 0), ErrIntrinsicGas, "intrinsicGasTooLow"},
+		{call(50000, 512), nil, ""},
+	}
+	for i, test := range tests {
+		err := pool.Add(test.tx)
+		if err != test.err {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, test.err)
+		}
+		if reason := TxRejectionReason(err); reason != test.reason {
+			t.Errorf("test %d: rejection reason mismatch: have %q, want %q", i, reason, test.reason)
+		}
+	}
+	if err := pool.Add(tests[len(tests)-1].tx); err != ErrKnownTransaction {
+		t.Errorf("known transaction error mismatch: have %v, want %v", err, ErrKnownTransaction)
+	}
+}
+
+// Tests that the transactions leaving the pool are announced with the reason
+// they left.
+func TestTransactionDropEvents(t *testing.T) {
+	pool, key := setupTxPool()
+	account, _ := deriveSender(transaction(0, big.NewInt(0), key))
+
+	state, _ := pool.currentState()
+	state.AddBalance(account, big.NewInt(1000))
+
+	var (
+		tx0  = transaction(0, big.NewInt(100), key)
+		tx1  = transaction(1, big.NewInt(200), key)
+		tx10 = transaction(10, big.NewInt(100), key)
+		tx11 = transaction(11, big.NewInt(200), key)
+	)
+	pool.addTx(tx0.Hash(), account, tx0)
+	pool.addTx(tx1.Hash(), account, tx1)
+	pool.queueTx(tx10.Hash(), tx10)
+	pool.queueTx(tx11.Hash(), tx11)
+
+	events := make(chan DroppedTxsEvent, 16)
+	sub := pool.SubscribeDroppedTxsEvent(events)
+	defer sub.Unsubscribe()
+
+	check := func(want map[common.Hash]string) {
+		have := make(map[common.Hash]string)
+		for len(have) < len(want) {
+			select {
+			case ev := <-events:
+				for _, drop := range ev.Txs {
+					have[drop.Tx.Hash()] = drop.Reason
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("dropped transactions mismatch: have %v, want %v", have, want)
+			}
+		}
+		for hash, reason := range want {
+			if have[hash] != reason {
+				t.Errorf("tx %x: drop reason mismatch: have %q, want %q", hash[:4], have[hash], reason)
+			}
+		}
+		if len(have) != len(want) {
+			t.Errorf("dropped transactions mismatch: have %v, want %v", have, want)
+		}
+	}
+	// Reduce the balance of the account, invalidating the expensive transactions
+	state.AddBalance(account, big.NewInt(-750))
+	pool.resetState()
+	check(map[common.Hash]string{tx1.Hash(): TxDropInvalidated, tx11.Hash(): TxDropInvalidated})
+
+	// Use the nonce of the pending transaction, then remove the queued one
+	state.SetNonce(account, 1)
+	pool.resetState()
+	check(map[common.Hash]string{tx0.Hash(): TxDropNonceUsed})
+
+	pool.RemoveTx(tx10.Hash())
+	check(map[common.Hash]string{tx10.Hash(): TxDropRemoved})
+}
+
 // Tests that if a transaction is dropped from the current pending pool (e.g. out
 // of fund), all consecutive (still valid, but not executable) transactions are
 // postponed back into the future queue to prevent broadcasting them.
@@ -410,20 +607,20 @@ func TestTransactionQueueLimiting(t *testing.T) {
 	state.AddBalance(account, big.NewInt(1000000))
 
 	// Keep queuing up transactions and make sure all above a limit are dropped
-	for i := uint64(1); i <= maxQueued+5; i++ {
+	for i := uint64(1); i <= DefaultTxPoolConfig.AccountQueue+5; i++ {
 		if err := pool.Add(transaction(i, big.NewInt(100000), key)); err != nil {
 			t.Fatalf("tx %d: failed to add transaction: %v", i, err)
 		}
 		if len(pool.pending) != 0 {
 			t.Errorf("tx %d: pending pool size mismatch: have %d, want %d", i, len(pool.pending), 0)
 		}
-		if i <= maxQueued {
+		if i <= DefaultTxPoolConfig.AccountQueue {
 			if len(pool.queue[account]) != int(i) {
 				t.Errorf("tx %d: queue size mismatch: have %d, want %d", i, len(pool.queue[account]), i)
 			}
 		} else {
-			if len(pool.queue[account]) != maxQueued {
-				t.Errorf("tx %d: queue limit mismatch: have %d, want %d", i, len(pool.queue[account]), maxQueued)
+			if uint64(len(pool.queue[account])) != DefaultTxPoolConfig.AccountQueue {
+				t.Errorf("tx %d: queue limit mismatch: have %d, want %d", i, len(pool.queue[account]), DefaultTxPoolConfig.AccountQueue)
 			}
 		}
 	}
@@ -441,7 +638,7 @@ func TestTransactionPendingLimiting(t *testing.T) {
 	state.AddBalance(account, big.NewInt(1000000))
 
 	// Keep queuing up transactions and make sure all above a limit are dropped
-	for i := uint64(0); i < maxQueued+5; i++ {
+	for i := uint64(0); i < DefaultTxPoolConfig.AccountQueue+5; i++ {
 		if err := pool.Add(transaction(i, big.NewInt(100000), key)); err != nil {
 			t.Fatalf("tx %d: failed to add transaction: %v", i, err)
 		}
@@ -454,6 +651,379 @@ func TestTransactionPendingLimiting(t *testing.T) {
 	}
 }
 
+// Tests that if the executable transactions of all accounts exceed the global
+// slots, the accounts holding the most transactions over their guaranteed
+// slots lose their highest nonces.
+func TestTransactionPendingGlobalLimiting(t *testing.T) {
+	pool, spammer := setupTxPool()
+	pool.txConfig.GlobalSlots = 8
+	pool.txConfig.AccountSlots = 2
+
+	user, _ := crypto.GenerateKey()
+	state, _ := pool.currentState()
+	for _, key := range []*ecdsa.PrivateKey{spammer, user} {
+		account, _ := deriveSender(transaction(0, big.NewInt(0), key))
+		state.AddBalance(account, big.NewInt(1000000))
+	}
+	var txs []*types.Transaction
+	for i := uint64(0); i < 10; i++ {
+		txs = append(txs, transaction(i, big.NewInt(100000), spammer))
+	}
+	for i := uint64(0); i < 2; i++ {
+		txs = append(txs, transaction(i, big.NewInt(100000), user))
+	}
+	pool.AddTransactions(txs)
+
+	if len(pool.pending) != 8 {
+		t.Fatalf("pending transactions mismatch: have %d, want %d", len(pool.pending), 8)
+	}
+	for i, tx := range txs {
+		_, pending := pool.pending[tx.Hash()]
+		if want := i < 6 || i >= 10; pending != want {
+			t.Errorf("tx %d: pending %v, want %v", i, pending, want)
+		}
+	}
+	account, _ := deriveSender(txs[0])
+	if nonce := pool.pendingState.GetNonce(account); nonce != 6 {
+		t.Errorf("pending nonce mismatch: have %d, want %d", nonce, 6)
+	}
+}
+
+// Tests that if the executable transactions of all accounts exceed the global
+// slots with none over its guaranteed slots, the cheapest ones are dropped and
+// the later nonces of their senders postponed.
+func TestTransactionPendingGlobalLimitingPriced(t *testing.T) {
+	pool, cheap := setupTxPool()
+	pool.txConfig.GlobalSlots = 4
+	pool.txConfig.AccountSlots = 2
+
+	mid, _ := crypto.GenerateKey()
+	pricey, _ := crypto.GenerateKey()
+	state, _ := pool.currentState()
+	for _, key := range []*ecdsa.PrivateKey{cheap, mid, pricey} {
+		account, _ := deriveSender(transaction(0, big.NewInt(0), key))
+		state.AddBalance(account, big.NewInt(1000000))
+	}
+	txs := []*types.Transaction{
+		pricedTransaction(0, big.NewInt(100000), big.NewInt(1), cheap),
+		pricedTransaction(1, big.NewInt(100000), big.NewInt(3), cheap),
+		pricedTransaction(0, big.NewInt(100000), big.NewInt(2), mid),
+		pricedTransaction(1, big.NewInt(100000), big.NewInt(2), mid),
+		pricedTransaction(0, big.NewInt(100000), big.NewInt(3), pricey),
+		pricedTransaction(1, big.NewInt(100000), big.NewInt(3), pricey),
+	}
+	pool.AddTransactions(txs)
+
+	if len(pool.pending) != 4 {
+		t.Fatalf("pending transactions mismatch: have %d, want %d", len(pool.pending), 4)
+	}
+	account, _ := deriveSender(txs[0])
+	if _, ok := pool.pending[txs[0].Hash()]; ok {
+		t.Errorf("cheapest transaction not dropped")
+	}
+	if _, ok := pool.queue[account][txs[1].Hash()]; !ok {
+		t.Errorf("later transaction of the cheapest sender not postponed")
+	}
+	for i, tx := range txs[2:] {
+		if _, ok := pool.pending[tx.Hash()]; !ok {
+			t.Errorf("tx %d: not pending", i+2)
+		}
+	}
+	if nonce := pool.pendingState.GetNonce(account); nonce != 0 {
+		t.Errorf("pending nonce mismatch: have %d, want %d", nonce, 0)
+	}
+	if len(pool.accounts[account]) != 0 || len(pool.accounts) != 2 {
+		t.Errorf("sender index out of sync: %d senders, %d transactions of the cheapest", len(pool.accounts), len(pool.accounts[account]))
+	}
+}
+
+// Tests that if the non-executable transactions of all accounts exceed the
+// global queue, the cheapest ones are dropped.
+func TestTransactionQueueGlobalLimiting(t *testing.T) {
+	pool, cheap := setupTxPool()
+	pool.txConfig.GlobalQueue = 8
+
+	pricey, _ := crypto.GenerateKey()
+	state, _ := pool.currentState()
+	for _, key := range []*ecdsa.PrivateKey{cheap, pricey} {
+		account, _ := deriveSender(transaction(0, big.NewInt(0), key))
+		state.AddBalance(account, big.NewInt(1000000))
+	}
+	var txs []*types.Transaction
+	for i := uint64(1); i <= 5; i++ {
+		txs = append(txs, pricedTransaction(i, big.NewInt(100000), big.NewInt(1), cheap))
+	}
+	for i := uint64(1); i <= 5; i++ {
+		txs = append(txs, pricedTransaction(i, big.NewInt(100000), big.NewInt(2), pricey))
+	}
+	pool.AddTransactions(txs)
+
+	queued := 0
+	for i, tx := range txs {
+		from, _ := deriveSender(tx)
+		_, ok := pool.queue[from][tx.Hash()]
+		if want := i < 3 || i >= 5; ok != want {
+			t.Errorf("tx %d: queued %v, want %v", i, ok, want)
+		}
+		if ok {
+			queued++
+		}
+	}
+	if queued != 8 {
+		t.Errorf("queued transactions mismatch: have %d, want %d", queued, 8)
+	}
+}
+
+// Tests that transactions dropped for exceeding the global queue aren't taken for
+// underpriced ones, and are accepted again once there is room for them.
+func TestTransactionQueueEvictedResubmit(t *testing.T) {
+	pool, cheap := setupTxPool()
+	pool.txConfig.GlobalQueue = 1
+
+	pricey, _ := crypto.GenerateKey()
+	state, _ := pool.currentState()
+	for _, key := range []*ecdsa.PrivateKey{cheap, pricey} {
+		account, _ := deriveSender(transaction(0, big.NewInt(0), key))
+		state.AddBalance(account, big.NewInt(1000000))
+	}
+	evicted := pricedTransaction(1, big.NewInt(100000), big.NewInt(1), cheap)
+	if err := pool.Add(evicted); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	if err := pool.Add(pricedTransaction(1, big.NewInt(100000), big.NewInt(2), pricey)); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	from, _ := deriveSender(evicted)
+	if _, ok := pool.queue[from][evicted.Hash()]; ok {
+		t.Fatalf("cheapest transaction not evicted")
+	}
+	if pool.underpriced.Contains(evicted.Hash()) {
+		t.Errorf("evicted transaction cached as underpriced")
+	}
+	// Make room and resubmit the evicted transaction
+	pool.txConfig.GlobalQueue = 2
+	if err := pool.Add(evicted); err != nil {
+		t.Fatalf("failed to resubmit evicted transaction: %v", err)
+	}
+	if _, ok := pool.queue[from][evicted.Hash()]; !ok {
+		t.Errorf("resubmitted transaction not queued")
+	}
+}
+
+// Tests that the eviction of queued transactions skips the ones which left the
+// queue since they were queued, goes by the latest activity of the accounts and
+// rebuilds its index once mostly stale.
+func TestTransactionQueueEvictionStale(t *testing.T) {
+	pool, stale := setupTxPool()
+	pool.txConfig.GlobalQueue = 2
+
+	active, _ := crypto.GenerateKey()
+	idle, _ := crypto.GenerateKey()
+	staleAddr, _ := deriveSender(transaction(0, big.NewInt(0), stale))
+	activeAddr, _ := deriveSender(transaction(0, big.NewInt(0), active))
+	idleAddr, _ := deriveSender(transaction(0, big.NewInt(0), idle))
+
+	// Queue transactions of an account which then leave the queue
+	queueStale := func(from, to uint64) {
+		for i := from; i <= to; i++ {
+			tx := pricedTransaction(i, big.NewInt(100000), big.NewInt(1), stale)
+			pool.queueTx(tx.Hash(), tx)
+		}
+		delete(pool.queue, staleAddr)
+	}
+	queueStale(1, 3)
+
+	// Queue an account before another one, and have it active since
+	activeTx := pricedTransaction(1, big.NewInt(100000), big.NewInt(1), active)
+	pool.queueTx(activeTx.Hash(), activeTx)
+	idleTx := pricedTransaction(1, big.NewInt(100000), big.NewInt(1), idle)
+	pool.queueTx(idleTx.Hash(), idleTx)
+	pricey := pricedTransaction(2, big.NewInt(100000), big.NewInt(2), active)
+	pool.queueTx(pricey.Hash(), pricey)
+	pool.beats[activeAddr] = pool.beats[idleAddr].Add(time.Minute)
+
+	pool.truncateQueue()
+	if _, ok := pool.queue[activeAddr][activeTx.Hash()]; !ok {
+		t.Errorf("transaction of the recently active account dropped")
+	}
+	if _, ok := pool.queue[idleAddr]; ok {
+		t.Errorf("transaction of the idle account not dropped")
+	}
+	if have := pool.priced.Len(); have != 2 {
+		t.Errorf("eviction index size mismatch: have %d, want 2", have)
+	}
+	// Once mostly stale, the index gets rebuilt from the queue
+	queueStale(4, 10)
+	idleTx = pricedTransaction(2, big.NewInt(100000), big.NewInt(1), idle)
+	pool.queueTx(idleTx.Hash(), idleTx)
+
+	pool.truncateQueue()
+	if _, ok := pool.queue[idleAddr]; ok {
+		t.Errorf("transaction of the idle account not dropped")
+	}
+	if have := pool.priced.Len(); have != 2 {
+		t.Errorf("rebuilt eviction index size mismatch: have %d, want 2", have)
+	}
+}
+
+// Tests that the queued transactions of an account are dropped once it has been
+// inactive for the configured lifetime, leaving its pending ones alone.
+func TestTransactionQueueTimeLimiting(t *testing.T) {
+	pool, key := setupTxPool()
+	pool.txConfig.Lifetime = time.Second
+	account, _ := deriveSender(transaction(0, big.NewInt(0), key))
+
+	state, _ := pool.currentState()
+	state.AddBalance(account, big.NewInt(1000000))
+
+	pending, queued := transaction(0, big.NewInt(100000), key), transaction(2, big.NewInt(100000), key)
+	pool.AddTransactions([]*types.Transaction{pending, queued})
+
+	pool.expireQueue()
+	if _, ok := pool.queue[account][queued.Hash()]; !ok {
+		t.Fatalf("queued transaction dropped before its lifetime")
+	}
+	pool.beats[account] = time.Now().Add(-2 * time.Second)
+	pool.expireQueue()
+	if _, ok := pool.queue[account]; ok {
+		t.Errorf("queued transactions present after their lifetime")
+	}
+	if _, ok := pool.pending[pending.Hash()]; !ok {
+		t.Errorf("pending transaction dropped with the expired queue")
+	}
+}
+
+// Tests that transactions rejected as underpriced are rejected again without
+// validation until the minimum gas price is lowered.
+func TestUnderpricedTransactionCache(t *testing.T) {
+	pool, key := setupTxPool()
+	account, _ := deriveSender(transaction(0, big.NewInt(0), key))
+
+	state, _ := pool.currentState()
+	state.AddBalance(account, big.NewInt(1000000))
+
+	pool.minGasPrice = big.NewInt(2)
+	tx := transaction(0, big.NewInt(100000), key)
+	if err := pool.Add(tx); err != ErrCheap {
+		t.Fatalf("error mismatch: have %v, want %v", err, ErrCheap)
+	}
+	if !pool.underpriced.Contains(tx.Hash()) {
+		t.Fatalf("underpriced transaction not cached")
+	}
+	// The cached rejection stands even though the transaction would now be valid.
+	pool.minGasPrice = big.NewInt(1)
+	if err := pool.Add(tx); err != ErrCheap {
+		t.Errorf("cached rejection mismatch: have %v, want %v", err, ErrCheap)
+	}
+	// Lowering the minimum gas price through the event clears the cache.
+	pool.minGasPrice = big.NewInt(2)
+	pool.eventMux.Post(GasPriceChanged{big.NewInt(1)})
+	for i := 0; i < 100 && pool.underpriced.Len() > 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := pool.Add(tx); err != nil {
+		t.Errorf("failed to add transaction after lowering the price: %v", err)
+	}
+}
+
+// Tests that the transactions of local senders are exempt from the minimum gas
+// price and the pool limits.
+func TestTransactionLocals(t *testing.T) {
+	pool, local := setupTxPool()
+	pool.minGasPrice = big.NewInt(2)
+	pool.txConfig.GlobalSlots = 2
+	pool.txConfig.AccountSlots = 1
+	pool.txConfig.GlobalQueue = 1
+
+	remote, _ := crypto.GenerateKey()
+	state, _ := pool.currentState()
+	for _, key := range []*ecdsa.PrivateKey{local, remote} {
+		account, _ := deriveSender(transaction(0, big.NewInt(0), key))
+		state.AddBalance(account, big.NewInt(1000000))
+	}
+	// Cheap remote transactions are rejected, cheap local ones accepted
+	if err := pool.Add(transaction(0, big.NewInt(100000), remote)); err != ErrCheap {
+		t.Errorf("cheap remote transaction: error mismatch: have %v, want %v", err, ErrCheap)
+	}
+	if err := pool.AddLocal(transaction(0, big.NewInt(100000), local)); err != nil {
+		t.Fatalf("cheap local transaction rejected: %v", err)
+	}
+	// Further transactions of the local sender are local wherever they come from
+	var txs []*types.Transaction
+	for i := uint64(1); i < 4; i++ {
+		txs = append(txs, transaction(i, big.NewInt(100000), local))
+	}
+	for i := uint64(5); i < 8; i++ {
+		txs = append(txs, transaction(i, big.NewInt(100000), local))
+	}
+	pool.AddTransactions(txs)
+
+	if pending, queued := pool.Stats(); pending != 4 || queued != 3 {
+		t.Errorf("pool size mismatch: have %d pending and %d queued, want 4 and 3", pending, queued)
+	}
+	account, _ := deriveSender(txs[0])
+	pool.beats[account] = time.Now().Add(-2 * pool.txConfig.Lifetime)
+	pool.expireQueue()
+	if queued := len(pool.queue[account]); queued != 3 {
+		t.Errorf("local queue expired: have %d queued, want 3", queued)
+	}
+}
+
+// Tests that local transactions are journaled and reloaded by the next pool,
+// dropping those which became invalid meanwhile.
+func TestTransactionJournaling(t *testing.T) {
+	file, err := ioutil.TempFile("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary journal: %v", err)
+	}
+	journal := file.Name()
+	defer os.Remove(journal)
+	file.Close()
+	os.Remove(journal)
+
+	db, _ := ethdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, db)
+
+	config := DefaultTxPoolConfig
+	config.Journal = journal
+	pool := newTestTxPool(config, statedb)
+
+	local, _ := crypto.GenerateKey()
+	remote, _ := crypto.GenerateKey()
+	for _, key := range []*ecdsa.PrivateKey{local, remote} {
+		account, _ := deriveSender(transaction(0, big.NewInt(0), key))
+		statedb.AddBalance(account, big.NewInt(1000000))
+	}
+	for _, nonce := range []uint64{0, 2} {
+		if err := pool.AddLocal(transaction(nonce, big.NewInt(100000), local)); err != nil {
+			t.Fatalf("failed to add local transaction %d: %v", nonce, err)
+		}
+	}
+	if err := pool.Add(transaction(0, big.NewInt(100000), remote)); err != nil {
+		t.Fatalf("failed to add remote transaction: %v", err)
+	}
+	pool.Stop()
+
+	// The local transactions are reloaded, the remote one is lost
+	pool = newTestTxPool(config, statedb)
+	if pending, queued := pool.Stats(); pending != 1 || queued != 1 {
+		t.Errorf("pool size mismatch: have %d pending and %d queued, want 1 and 1", pending, queued)
+	}
+	account, _ := deriveSender(transaction(0, big.NewInt(0), local))
+	if !pool.isLocal(account) {
+		t.Errorf("journaled sender isn't local")
+	}
+	pool.Stop()
+
+	// Once the first transaction is included, it's dropped on reload
+	statedb.SetNonce(account, 1)
+	pool = newTestTxPool(config, statedb)
+	defer pool.Stop()
+	if pending, queued := pool.Stats(); pending != 0 || queued != 1 {
+		t.Errorf("pool size mismatch: have %d pending and %d queued, want 0 and 1", pending, queued)
+	}
+}
+
 // Tests that the transaction limits are enforced the same way irrelevant whether
 // the transactions are added one by one or in batches.
 func TestTransactionQueueLimitingEquivalency(t *testing.T)   { testTransactionLimitingEquivalency(t, 1) }
@@ -466,7 +1036,7 @@ func testTransactionLimitingEquivalency(t *testing.T, origin uint64) {
 	state1, _ := pool1.currentState()
 	state1.AddBalance(account1, big.NewInt(1000000))
 
-	for i := uint64(0); i < maxQueued+5; i++ {
+	for i := uint64(0); i < DefaultTxPoolConfig.AccountQueue+5; i++ {
 		if err := pool1.Add(transaction(origin+i, big.NewInt(100000), key1)); err != nil {
 			t.Fatalf("tx %d: failed to add transaction: %v", i, err)
 		}
@@ -478,7 +1048,7 @@ func testTransactionLimitingEquivalency(t *testing.T, origin uint64) {
 	state2.AddBalance(account2, big.NewInt(1000000))
 
 	txns := []*types.Transaction{}
-	for i := uint64(0); i < maxQueued+5; i++ {
+	for i := uint64(0); i < DefaultTxPoolConfig.AccountQueue+5; i++ {
 		txns = append(txns, transaction(origin+i, big.NewInt(100000), key2))
 	}
 	pool2.AddTransactions(txns)
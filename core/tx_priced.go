@@ -0,0 +1,116 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"container/heap"
+	"time"
+
+	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/core/types"
+)
+
+// pricedEntry is a queued transaction of the eviction heap, along with the last
+// activity of its sender as of when it was pushed.
+type pricedEntry struct {
+	hash common.Hash
+	addr common.Address
+	tx   *types.Transaction
+	beat time.Time
+}
+
+// priceHeap orders queued transactions by the order they are dropped in when
+// the global queue is exceeded: cheapest first and, of equally priced ones,
+// those of the longest inactive accounts and the highest nonces.
+type priceHeap []*pricedEntry
+
+func (h priceHeap) Len() int      { return len(h) }
+func (h priceHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h priceHeap) Less(i, j int) bool {
+	a, b := h[i], h[j]
+	if cmp := a.tx.GasPrice().Cmp(b.tx.GasPrice()); cmp != 0 {
+		return cmp < 0
+	}
+	if a.addr != b.addr {
+		if !a.beat.Equal(b.beat) {
+			return a.beat.Before(b.beat)
+		}
+		return bytes.Compare(a.addr[:], b.addr[:]) < 0
+	}
+	return a.tx.Nonce() > b.tx.Nonce()
+}
+
+func (h *priceHeap) Push(x interface{}) { *h = append(*h, x.(*pricedEntry)) }
+func (h *priceHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return x
+}
+
+// txPricedList is the eviction order index of the queued transactions, kept up
+// to date incrementally instead of sorting the whole queue on every check. The
+// pool keeps a second one over the processable transactions, whose entries all
+// share the zero activity.
+//
+// Entries aren't removed when their transaction leaves the queue, nor updated
+// when their sender is active again: the former are discarded once they reach
+// the top of the heap, the latter pushed back with the new activity, which can
+// only make them go later. The heap is rebuilt from the queue once the stale
+// entries outnumber the live ones.
+type txPricedList struct {
+	items priceHeap
+}
+
+// Put adds a queued transaction to the index.
+func (l *txPricedList) Put(hash common.Hash, addr common.Address, tx *types.Transaction, beat time.Time) {
+	heap.Push(&l.items, &pricedEntry{hash: hash, addr: addr, tx: tx, beat: beat})
+}
+
+// Len returns the number of entries of the index, stale ones included.
+func (l *txPricedList) Len() int {
+	return len(l.items)
+}
+
+// Pop removes and returns the next queued transaction to evict, or nil if none
+// is left. The current function reports whether an entry is still queued and
+// the current activity of its sender.
+func (l *txPricedList) Pop(current func(entry *pricedEntry) (bool, time.Time)) *pricedEntry {
+	for len(l.items) > 0 {
+		entry := heap.Pop(&l.items).(*pricedEntry)
+		queued, beat := current(entry)
+		if !queued {
+			continue
+		}
+		if !beat.Equal(entry.beat) {
+			entry.beat = beat
+			heap.Push(&l.items, entry)
+			continue
+		}
+		return entry
+	}
+	return nil
+}
+
+// Reheap replaces the index with the given live entries.
+func (l *txPricedList) Reheap(entries []*pricedEntry) {
+	l.items = priceHeap(entries)
+	heap.Init(&l.items)
+}
@@ -38,6 +38,10 @@ import (
 // ValidateBlock validates the given block and should return an error if it
 // failed to do so and should be used for "full" validation.
 //
+// ValidateBody validates the given block but for its header, and ValidateHeaders
+// concurrently validates the headers of a batch of blocks being imported, so
+// that they are validated ahead of the sequential processing of their state.
+//
 // ValidateHeader validates the given header and parent and returns an error
 // if it failed to do so.
 //
@@ -46,6 +50,8 @@ import (
 type Validator interface {
 	HeaderValidator
 	ValidateBlock(block *types.Block) error
+	ValidateBody(block *types.Block) error
+	ValidateHeaders(headers []*types.Header) (chan<- struct{}, <-chan nonceCheckResult)
 	ValidateState(block, parent *types.Block, state *state.StateDB, receipts types.Receipts, usedGas *big.Int) error
 	VerifyUncles(block, parent *types.Block) error
 }
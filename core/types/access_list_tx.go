@@ -0,0 +1,106 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"math/big"
+
+	"github.com/ellaism/go-ellaism/common"
+)
+
+// AccessListTxType is the type of the EIP-2930 access list transactions.
+const AccessListTxType = 0x01
+
+func init() {
+	typedTxs[AccessListTxType] = func() TxData { return new(AccessListTx) }
+}
+
+// AccessList is an EIP-2930 access list: the accounts and storage slots a
+// transaction declares it is going to access.
+type AccessList []AccessTuple
+
+// AccessTuple is an account and the storage slots of it which are accessed.
+type AccessTuple struct {
+	Address     common.Address `json:"address"`
+	StorageKeys []common.Hash  `json:"storageKeys"`
+}
+
+// StorageKeys returns the total number of storage slots in the access list.
+func (al AccessList) StorageKeys() int {
+	sum := 0
+	for _, tuple := range al {
+		sum += len(tuple.StorageKeys)
+	}
+	return sum
+}
+
+// copy returns a deep copy of the access list.
+func (al AccessList) copy() AccessList {
+	if al == nil {
+		return nil
+	}
+	cpy := make(AccessList, len(al))
+	for i, tuple := range al {
+		cpy[i] = AccessTuple{
+			Address:     tuple.Address,
+			StorageKeys: append([]common.Hash(nil), tuple.StorageKeys...),
+		}
+	}
+	return cpy
+}
+
+// AccessListTx is the payload of an EIP-2930 access list transaction. Its
+// signature covers the chain id, so V is the bare recovery id.
+type AccessListTx struct {
+	ChainID    *big.Int
+	Nonce      uint64
+	GasPrice   *big.Int
+	Gas        *big.Int
+	To         *common.Address `rlp:"nil"` // nil means contract creation
+	Value      *big.Int
+	Data       []byte
+	AccessList AccessList
+	V, R, S    *big.Int // signature
+}
+
+func (tx *AccessListTx) txType() byte { return AccessListTxType }
+
+func (tx *AccessListTx) copy() TxData {
+	cpy := &AccessListTx{
+		Nonce:      tx.Nonce,
+		To:         copyAddressPtr(tx.To),
+		Data:       common.CopyBytes(tx.Data),
+		AccessList: tx.AccessList.copy(),
+	}
+	cpy.ChainID, cpy.GasPrice, cpy.Gas, cpy.Value = copyBig(tx.ChainID), copyBig(tx.GasPrice), copyBig(tx.Gas), copyBig(tx.Value)
+	cpy.V, cpy.R, cpy.S = copyBig(tx.V), copyBig(tx.R), copyBig(tx.S)
+	return cpy
+}
+
+func (tx *AccessListTx) chainID() *big.Int      { return tx.ChainID }
+func (tx *AccessListTx) nonce() uint64          { return tx.Nonce }
+func (tx *AccessListTx) gasPrice() *big.Int     { return tx.GasPrice }
+func (tx *AccessListTx) gas() *big.Int          { return tx.Gas }
+func (tx *AccessListTx) value() *big.Int        { return tx.Value }
+func (tx *AccessListTx) to() *common.Address    { return tx.To }
+func (tx *AccessListTx) data() []byte           { return tx.Data }
+func (tx *AccessListTx) accessList() AccessList { return tx.AccessList }
+func (tx *AccessListTx) sigFields() []interface{} {
+	return []interface{}{tx.ChainID, tx.Nonce, tx.GasPrice, tx.Gas, tx.To, tx.Value, tx.Data, tx.AccessList}
+}
+func (tx *AccessListTx) rawSignatureValues() (v, r, s *big.Int) { return tx.V, tx.R, tx.S }
+func (tx *AccessListTx) setSignatureValues(v, r, s *big.Int)    { tx.V, tx.R, tx.S = v, r, s }
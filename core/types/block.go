@@ -126,6 +126,16 @@ func rlpHash(x interface{}) (h common.Hash) {
 	return h
 }
 
+// prefixedRlpHash hashes the RLP encoding of x prefixed by the given byte, as
+// used for the envelopes of typed transactions.
+func prefixedRlpHash(prefix byte, x interface{}) (h common.Hash) {
+	hw := sha3.NewKeccak256()
+	hw.Write([]byte{prefix})
+	rlp.Encode(hw, x)
+	hw.Sum(h[:0])
+	return h
+}
+
 // Body is a simple (mutable, non-safe) data container for storing and moving
 // a block's data contents (transactions and uncles) together.
 type Body struct {
@@ -133,6 +143,39 @@ type Body struct {
 	Uncles       []*Header
 }
 
+// BodyTransactionHashes returns the hashes of the transactions of the RLP
+// encoded block body, computed from their encoding without decoding them.
+func BodyTransactionHashes(body rlp.RawValue) ([]common.Hash, error) {
+	content, _, err := rlp.SplitList(body)
+	if err != nil {
+		return nil, err
+	}
+	txs, _, err := rlp.SplitList(content)
+	if err != nil {
+		return nil, err
+	}
+	var hashes []common.Hash
+	for len(txs) > 0 {
+		kind, tx, rest, err := rlp.Split(txs)
+		if err != nil {
+			return nil, err
+		}
+		hw := sha3.NewKeccak256()
+		if kind == rlp.List {
+			// Legacy transactions are hashed with their list header
+			hw.Write(txs[:len(txs)-len(rest)])
+		} else {
+			// Typed transactions are hashed as their envelope
+			hw.Write(tx)
+		}
+		var h common.Hash
+		hw.Sum(h[:0])
+		hashes = append(hashes, h)
+		txs = rest
+	}
+	return hashes, nil
+}
+
 type Block struct {
 	header       *Header
 	uncles       []*Header
@@ -378,6 +421,16 @@ func (b *Block) WithMiningResult(nonce uint64, mixDigest common.Hash) *Block {
 	}
 }
 
+// WithSeal returns a new block with the data from b but the header replaced
+// with the sealed one.
+func (b *Block) WithSeal(header *Header) *Block {
+	return &Block{
+		header:       CopyHeader(header),
+		transactions: b.transactions,
+		uncles:       b.uncles,
+	}
+}
+
 // WithBody returns a new block with the given transaction and uncle contents.
 func (b *Block) WithBody(transactions []*Transaction, uncles []*Header) *Block {
 	block := &Block{
@@ -52,7 +52,7 @@ func TestBlockEncoding(t *testing.T) {
 
 	tx1 := NewTransaction(0, common.HexToAddress("095e7baea6a6c7c4c2dfeb977efac326af552d87"), big.NewInt(10), big.NewInt(50000), big.NewInt(10), nil)
 	tx1, _ = tx1.WithSignature(common.Hex2Bytes("9bea4c4daac7c7c52e093e6a4c35dbbcf8856f1af7b059ba20253e70848d094f8a8fae537ce25ed8cb5af9adac3f141af69bd515bd2ba031522df09b97dd72b100"))
-	t.Logf("transaction data 0x%x, hash 0x%x", tx1.data, tx1.Hash())
+	t.Logf("transaction data 0x%x, hash 0x%x", tx1.inner, tx1.Hash())
 
 	check("len(Transactions)", len(block.Transactions()), 1)
 	check("Transactions[0].Hash", block.Transactions()[0].Hash(), tx1.Hash())
@@ -65,3 +65,27 @@ func TestBlockEncoding(t *testing.T) {
 		t.Errorf("encoded block mismatch:\ngot:  %x\nwant: %x", ourBlockEnc, blockEnc)
 	}
 }
+
+// Tests that the transaction hashes of an encoded body match those of its
+// decoded transactions, legacy and typed alike.
+func TestBodyTransactionHashes(t *testing.T) {
+	typed, addr := signedTypedTx(t, big.NewInt(1))
+	key, _ := defaultTestKey()
+	legacy, _ := NewTransaction(0, addr, big.NewInt(1), big.NewInt(21000), big.NewInt(1), nil).SignECDSA(key)
+
+	body, err := rlp.EncodeToBytes(&Body{Transactions: []*Transaction{legacy, typed, legacy}})
+	if err != nil {
+		t.Fatalf("failed to encode body: %v", err)
+	}
+	hashes, err := BodyTransactionHashes(body)
+	if err != nil {
+		t.Fatalf("failed to hash transactions: %v", err)
+	}
+	want := []common.Hash{legacy.Hash(), typed.Hash(), legacy.Hash()}
+	if !reflect.DeepEqual(hashes, want) {
+		t.Errorf("hash mismatch: have %x, want %x", hashes, want)
+	}
+	if _, err := BodyTransactionHashes(body[:len(body)-1]); err == nil {
+		t.Error("hashed truncated body")
+	}
+}
@@ -17,6 +17,8 @@
 package types
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"math/big"
@@ -81,43 +83,151 @@ func (r *Receipt) String() string {
 	return fmt.Sprintf("receipt{med=%x cgas=%v bloom=%x logs=%v}", r.PostState, r.CumulativeGasUsed, r.Bloom, r.Logs)
 }
 
+// MarshalJSON implements json.Marshaler, encoding both the consensus and the
+// implementation fields of a receipt.
+func (r *Receipt) MarshalJSON() ([]byte, error) {
+	logs := r.Logs
+	if logs == nil {
+		logs = vm.Logs{}
+	}
+	return json.Marshal(map[string]interface{}{
+		"root":              fmt.Sprintf("0x%x", r.PostState),
+		"cumulativeGasUsed": fmt.Sprintf("%#x", r.CumulativeGasUsed),
+		"logsBloom":         r.Bloom,
+		"logs":              logs,
+		"transactionHash":   r.TxHash,
+		"contractAddress":   r.ContractAddress,
+		"gasUsed":           fmt.Sprintf("%#x", r.GasUsed),
+	})
+}
+
+// UnmarshalJSON implements json.Unmarshaler, loading a receipt encoded by
+// MarshalJSON.
+func (r *Receipt) UnmarshalJSON(input []byte) error {
+	var dec struct {
+		PostState         string         `json:"root"`
+		CumulativeGasUsed string         `json:"cumulativeGasUsed"`
+		Bloom             string         `json:"logsBloom"`
+		Logs              vm.Logs        `json:"logs"`
+		TxHash            common.Hash    `json:"transactionHash"`
+		ContractAddress   common.Address `json:"contractAddress"`
+		GasUsed           string         `json:"gasUsed"`
+	}
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return err
+	}
+	cumulativeGasUsed, ok := new(big.Int).SetString(dec.CumulativeGasUsed, 0)
+	if !ok {
+		return fmt.Errorf("malformed cumulativeGasUsed %q", dec.CumulativeGasUsed)
+	}
+	gasUsed, ok := new(big.Int).SetString(dec.GasUsed, 0)
+	if !ok {
+		return fmt.Errorf("malformed gasUsed %q", dec.GasUsed)
+	}
+	bloom := common.FromHex(dec.Bloom)
+	if len(bloom) != bloomLength {
+		return fmt.Errorf("malformed logsBloom %q", dec.Bloom)
+	}
+	r.PostState, r.CumulativeGasUsed, r.Logs = common.FromHex(dec.PostState), cumulativeGasUsed, dec.Logs
+	r.Bloom.SetBytes(bloom)
+	r.TxHash, r.ContractAddress, r.GasUsed = dec.TxHash, dec.ContractAddress, gasUsed
+	return nil
+}
+
+// ReceiptStorageVersion is the version of the storage encoding written by
+// ReceiptForStorage.
+//
+// Receipts were originally stored as a flat RLP list of their fields. Later
+// versions wrap the RLP list of their fields into an envelope: an RLP string
+// made of the version byte followed by the encoded fields, so that the two
+// forms can always be told apart. New fields may be appended to the list of
+// an existing version, as decoders ignore any fields they don't know about,
+// while incompatible changes require a new version. Receipts stored in any
+// earlier form remain readable, so format changes never require a resync.
+const ReceiptStorageVersion = 1
+
+// ErrUnknownReceiptVersion is returned when decoding a stored receipt which
+// was written in a newer storage version than supported.
+var ErrUnknownReceiptVersion = errors.New("unknown receipt storage version")
+
 // ReceiptForStorage is a wrapper around a Receipt that flattens and parses the
 // entire content of a receipt, as opposed to only the consensus fields originally.
 type ReceiptForStorage Receipt
 
+// storedReceiptV1 is the storage encoding of a receipt in version 1, which
+// has the same fields as the original unversioned encoding.
+type storedReceiptV1 struct {
+	PostState         []byte
+	CumulativeGasUsed *big.Int
+	Bloom             Bloom
+	TxHash            common.Hash
+	ContractAddress   common.Address
+	Logs              []*vm.LogForStorage
+	GasUsed           *big.Int
+	Rest              []rlp.RawValue `rlp:"tail"` // Fields appended after this version
+}
+
 // EncodeRLP implements rlp.Encoder, and flattens all content fields of a receipt
-// into an RLP stream.
+// into a versioned RLP envelope.
 func (r *ReceiptForStorage) EncodeRLP(w io.Writer) error {
-	logs := make([]*vm.LogForStorage, len(r.Logs))
+	stored := storedReceiptV1{
+		PostState:         r.PostState,
+		CumulativeGasUsed: r.CumulativeGasUsed,
+		Bloom:             r.Bloom,
+		TxHash:            r.TxHash,
+		ContractAddress:   r.ContractAddress,
+		Logs:              make([]*vm.LogForStorage, len(r.Logs)),
+		GasUsed:           r.GasUsed,
+	}
 	for i, log := range r.Logs {
-		logs[i] = (*vm.LogForStorage)(log)
+		stored.Logs[i] = (*vm.LogForStorage)(log)
+	}
+	payload, err := rlp.EncodeToBytes(&stored)
+	if err != nil {
+		return err
 	}
-	return rlp.Encode(w, []interface{}{r.PostState, r.CumulativeGasUsed, r.Bloom, r.TxHash, r.ContractAddress, logs, r.GasUsed})
+	return rlp.Encode(w, append([]byte{ReceiptStorageVersion}, payload...))
 }
 
 // DecodeRLP implements rlp.Decoder, and loads both consensus and implementation
-// fields of a receipt from an RLP stream.
+// fields of a receipt from an RLP stream, either from a versioned envelope or
+// from the original unversioned encoding.
 func (r *ReceiptForStorage) DecodeRLP(s *rlp.Stream) error {
-	var receipt struct {
-		PostState         []byte
-		CumulativeGasUsed *big.Int
-		Bloom             Bloom
-		TxHash            common.Hash
-		ContractAddress   common.Address
-		Logs              []*vm.LogForStorage
-		GasUsed           *big.Int
-	}
-	if err := s.Decode(&receipt); err != nil {
+	kind, _, err := s.Kind()
+	if err != nil {
 		return err
 	}
+	var stored storedReceiptV1
+	if kind == rlp.List {
+		// Unversioned receipt, the fields are the same as in version 1
+		if err := s.Decode(&stored); err != nil {
+			return err
+		}
+	} else {
+		envelope, err := s.Bytes()
+		if err != nil {
+			return err
+		}
+		if len(envelope) == 0 {
+			return fmt.Errorf("empty receipt envelope")
+		}
+		switch envelope[0] {
+		case 1:
+			if err := rlp.DecodeBytes(envelope[1:], &stored); err != nil {
+				return err
+			}
+		default:
+			return ErrUnknownReceiptVersion
+		}
+	}
 	// Assign the consensus fields
-	r.PostState, r.CumulativeGasUsed, r.Bloom = receipt.PostState, receipt.CumulativeGasUsed, receipt.Bloom
-	r.Logs = make(vm.Logs, len(receipt.Logs))
-	for i, log := range receipt.Logs {
+	r.PostState, r.CumulativeGasUsed, r.Bloom = stored.PostState, stored.CumulativeGasUsed, stored.Bloom
+	r.Logs = make(vm.Logs, len(stored.Logs))
+	for i, log := range stored.Logs {
 		r.Logs[i] = (*vm.Log)(log)
 	}
 	// Assign the implementation fields
-	r.TxHash, r.ContractAddress, r.GasUsed = receipt.TxHash, receipt.ContractAddress, receipt.GasUsed
+	r.TxHash, r.ContractAddress, r.GasUsed = stored.TxHash, stored.ContractAddress, stored.GasUsed
 
 	return nil
 }
@@ -0,0 +1,131 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"encoding/json"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/core/vm"
+	"github.com/ellaism/go-ellaism/rlp"
+)
+
+func testReceipt() *Receipt {
+	receipt := &Receipt{
+		PostState:         common.Hash{1}.Bytes(),
+		CumulativeGasUsed: big.NewInt(42000),
+		Logs: vm.Logs{
+			{
+				Address:     common.Address{0x11},
+				Topics:      []common.Hash{{0x22}, {0x33}},
+				Data:        []byte{0x01, 0x02},
+				BlockNumber: 7,
+				TxHash:      common.Hash{0x44},
+				TxIndex:     1,
+				BlockHash:   common.Hash{0x55},
+				Index:       2,
+			},
+			{
+				Address:     common.Address{0x66},
+				Topics:      []common.Hash{},
+				Data:        []byte{},
+				BlockNumber: 7,
+				TxHash:      common.Hash{0x44},
+				TxIndex:     1,
+				BlockHash:   common.Hash{0x55},
+				Index:       3,
+			},
+		},
+		TxHash:          common.Hash{0x44},
+		ContractAddress: common.Address{0x77},
+		GasUsed:         big.NewInt(21000),
+	}
+	receipt.Bloom = CreateBloom(Receipts{receipt})
+	return receipt
+}
+
+// Tests that receipts survive a round trip through the storage encoding.
+func TestReceiptStorageRoundTrip(t *testing.T) {
+	want := testReceipt()
+
+	enc, err := rlp.EncodeToBytes((*ReceiptForStorage)(want))
+	if err != nil {
+		t.Fatalf("failed to encode receipt: %v", err)
+	}
+	if kind, content, _, _ := rlp.Split(enc); kind != rlp.String || len(content) == 0 || content[0] != ReceiptStorageVersion {
+		t.Fatalf("receipt not stored in a versioned envelope: %x", enc)
+	}
+	var have ReceiptForStorage
+	if err := rlp.DecodeBytes(enc, &have); err != nil {
+		t.Fatalf("failed to decode receipt: %v", err)
+	}
+	if !reflect.DeepEqual((*Receipt)(&have), want) {
+		t.Errorf("receipt mismatch:\nhave %+v\nwant %+v", have, want)
+	}
+}
+
+// Tests that receipts stored before the storage encoding was versioned, as
+// well as ones with fields appended by newer releases, can still be read.
+func TestReceiptStorageCompatibility(t *testing.T) {
+	want := testReceipt()
+	logs := make([]*vm.LogForStorage, len(want.Logs))
+	for i, log := range want.Logs {
+		logs[i] = (*vm.LogForStorage)(log)
+	}
+	fields := []interface{}{want.PostState, want.CumulativeGasUsed, want.Bloom, want.TxHash, want.ContractAddress, logs, want.GasUsed}
+
+	legacy, _ := rlp.EncodeToBytes(fields)
+	payload, _ := rlp.EncodeToBytes(append(fields, uint64(1), []byte("future")))
+	extended, _ := rlp.EncodeToBytes(append([]byte{ReceiptStorageVersion}, payload...))
+
+	for name, enc := range map[string][]byte{"legacy": legacy, "extended": extended} {
+		var have ReceiptForStorage
+		if err := rlp.DecodeBytes(enc, &have); err != nil {
+			t.Errorf("%s: failed to decode receipt: %v", name, err)
+			continue
+		}
+		if !reflect.DeepEqual((*Receipt)(&have), want) {
+			t.Errorf("%s: receipt mismatch:\nhave %+v\nwant %+v", name, have, want)
+		}
+	}
+
+	// Receipts written in an unknown version must be rejected
+	unknown, _ := rlp.EncodeToBytes(append([]byte{ReceiptStorageVersion + 1}, payload...))
+	if err := rlp.DecodeBytes(unknown, new(ReceiptForStorage)); err != ErrUnknownReceiptVersion {
+		t.Errorf("unknown version: error mismatch: have %v, want %v", err, ErrUnknownReceiptVersion)
+	}
+}
+
+// Tests that receipts and their logs survive a round trip through JSON.
+func TestReceiptJSONRoundTrip(t *testing.T) {
+	want := testReceipt()
+
+	enc, err := json.Marshal(want)
+	if err != nil {
+		t.Fatalf("failed to encode receipt: %v", err)
+	}
+	have := new(Receipt)
+	if err := json.Unmarshal(enc, have); err != nil {
+		t.Fatalf("failed to decode receipt: %v", err)
+	}
+	if !reflect.DeepEqual(have, want) {
+		t.Errorf("receipt mismatch:\nhave %+v\nwant %+v", have, want)
+	}
+}
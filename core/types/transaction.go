@@ -30,17 +30,54 @@ import (
 	"github.com/ellaism/go-ellaism/rlp"
 )
 
-var ErrInvalidSig = errors.New("invalid v, r, s values")
+var (
+	ErrInvalidSig = errors.New("invalid v, r, s values")
+
+	// ErrTxTypeNotSupported is returned for transactions of a type which is
+	// unknown or not enabled by the chain configuration.
+	ErrTxTypeNotSupported = errors.New("transaction type not supported")
+)
+
+// LegacyTxType is the type of the transactions predating typed transactions,
+// which are encoded as a plain RLP list of their fields.
+const LegacyTxType = 0x00
+
+// typedTxs creates empty payloads of the typed transactions, by type. Typed
+// transactions are encoded as the type byte followed by the RLP encoding of
+// their payload; inside RLP structures, such as block bodies, this envelope
+// is wrapped in an RLP string.
+var typedTxs = map[byte]func() TxData{}
 
 type Transaction struct {
 	signer Signer
-	data   txdata
+	inner  TxData
 	// caches
 	hash atomic.Value
 	size atomic.Value
 	from atomic.Value
 }
 
+// TxData is the payload of a transaction. Every transaction type has its own
+// payload, the legacy transactions being of type LegacyTxType.
+type TxData interface {
+	txType() byte
+	copy() TxData // creates a deep copy of the payload
+
+	chainID() *big.Int
+	nonce() uint64
+	gasPrice() *big.Int
+	gas() *big.Int
+	value() *big.Int
+	to() *common.Address
+	data() []byte
+	accessList() AccessList
+
+	// sigFields returns the fields covered by the signature of the sender.
+	sigFields() []interface{}
+	rawSignatureValues() (v, r, s *big.Int)
+	setSignatureValues(v, r, s *big.Int)
+}
+
 type txdata struct {
 	AccountNonce    uint64
 	Price, GasLimit *big.Int
@@ -50,13 +87,57 @@ type txdata struct {
 	V, R, S         *big.Int // signature
 }
 
+func (d *txdata) txType() byte { return LegacyTxType }
+
+func (d *txdata) copy() TxData {
+	cpy := &txdata{
+		AccountNonce: d.AccountNonce,
+		Recipient:    copyAddressPtr(d.Recipient),
+		Payload:      common.CopyBytes(d.Payload),
+	}
+	cpy.Price, cpy.GasLimit, cpy.Amount = copyBig(d.Price), copyBig(d.GasLimit), copyBig(d.Amount)
+	cpy.V, cpy.R, cpy.S = copyBig(d.V), copyBig(d.R), copyBig(d.S)
+	return cpy
+}
+
+func (d *txdata) chainID() *big.Int      { return deriveChainId(d.V) }
+func (d *txdata) nonce() uint64          { return d.AccountNonce }
+func (d *txdata) gasPrice() *big.Int     { return d.Price }
+func (d *txdata) gas() *big.Int          { return d.GasLimit }
+func (d *txdata) value() *big.Int        { return d.Amount }
+func (d *txdata) to() *common.Address    { return d.Recipient }
+func (d *txdata) data() []byte           { return d.Payload }
+func (d *txdata) accessList() AccessList { return nil }
+func (d *txdata) sigFields() []interface{} {
+	return []interface{}{d.AccountNonce, d.Price, d.GasLimit, d.Recipient, d.Amount, d.Payload}
+}
+func (d *txdata) rawSignatureValues() (v, r, s *big.Int) { return d.V, d.R, d.S }
+func (d *txdata) setSignatureValues(v, r, s *big.Int)    { d.V, d.R, d.S = v, r, s }
+
+// copyBig returns a copy of the given big integer, or nil if it is nil.
+func copyBig(v *big.Int) *big.Int {
+	if v == nil {
+		return nil
+	}
+	return new(big.Int).Set(v)
+}
+
+// copyAddressPtr returns a copy of the given address, or nil if it is nil.
+func copyAddressPtr(a *common.Address) *common.Address {
+	if a == nil {
+		return nil
+	}
+	cpy := *a
+	return &cpy
+}
+
 func NewContractCreation(nonce uint64, amount, gasLimit, gasPrice *big.Int, data []byte) *Transaction {
 	if len(data) > 0 {
 		data = common.CopyBytes(data)
 	}
 	return &Transaction{
 		signer: BasicSigner{},
-		data: txdata{
+		inner: &txdata{
 			AccountNonce: nonce,
 			Recipient:    nil,
 			Amount:       new(big.Int).Set(amount),
@@ -75,7 +156,7 @@ func NewTransaction(nonce uint64, to common.Address, amount, gasLimit, gasPrice
 	if len(data) > 0 {
 		data = common.CopyBytes(data)
 	}
-	d := txdata{
+	d := &txdata{
 		AccountNonce: nonce,
 		Recipient:    &to,
 		Payload:      data,
@@ -95,25 +176,70 @@ func NewTransaction(nonce uint64, to common.Address, amount, gasLimit, gasPrice
 	if gasPrice != nil {
 		d.Price.Set(gasPrice)
 	}
-	return &Transaction{signer: BasicSigner{}, data: d}
+	return &Transaction{signer: BasicSigner{}, inner: d}
+}
+
+// NewTx creates a new transaction of the type of the given payload, which is
+// copied. Typed transactions carry their chain id and are signed with the
+// matching EIP155 signer.
+func NewTx(inner TxData) *Transaction {
+	tx := &Transaction{inner: inner.copy()}
+	if inner.txType() == LegacyTxType {
+		tx.signer = BasicSigner{}
+	} else {
+		tx.signer = NewChainIdSigner(inner.chainID())
+	}
+	return tx
 }
 
 func (tx *Transaction) SetSigner(s Signer) {
 	tx.signer = s
 }
 
+// Type returns the type of the transaction.
+func (tx *Transaction) Type() byte {
+	return tx.inner.txType()
+}
+
 // ChainId returns which chain id this transaction was signed for (if at all)
 func (tx *Transaction) ChainId() *big.Int {
-	return deriveChainId(tx.data.V)
+	return tx.inner.chainID()
 }
 
 // Protected returns whether the transaction is protected from replay protection
 func (tx *Transaction) Protected() bool {
-	return isProtectedV(tx.data.V)
+	if tx.Type() != LegacyTxType {
+		return true
+	}
+	v, _, _ := tx.inner.rawSignatureValues()
+	return isProtectedV(v)
 }
 
+// EncodeRLP implements rlp.Encoder. Legacy transactions are encoded as an RLP
+// list, typed transactions as an RLP string holding their envelope.
 func (tx *Transaction) EncodeRLP(w io.Writer) error {
-	return rlp.Encode(w, &tx.data)
+	if tx.Type() == LegacyTxType {
+		return rlp.Encode(w, tx.inner)
+	}
+	enc, err := tx.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return rlp.Encode(w, enc)
+}
+
+// MarshalBinary returns the canonical encoding of the transaction, as used for
+// raw transactions: the RLP list of the fields of legacy transactions and the
+// envelope of typed ones.
+func (tx *Transaction) MarshalBinary() ([]byte, error) {
+	if tx.Type() == LegacyTxType {
+		return rlp.EncodeToBytes(tx.inner)
+	}
+	payload, err := rlp.EncodeToBytes(tx.inner)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{tx.Type()}, payload...), nil
 }
 
 // DeriveSigner makes a *best* guess about which signer to use.
@@ -126,41 +252,108 @@ func deriveSigner(V *big.Int) Signer {
 }
 
 func (tx *Transaction) DecodeRLP(s *rlp.Stream) error {
-	_, size, _ := s.Kind()
-	err := s.Decode(&tx.data)
-	if err == nil {
-		tx.size.Store(common.StorageSize(rlp.ListSize(size)))
+	kind, size, err := s.Kind()
+	if err != nil {
+		return err
+	}
+	if kind == rlp.List {
+		var inner txdata
+		if err := s.Decode(&inner); err != nil {
+			return err
+		}
+		tx.setDecoded(&inner, common.StorageSize(rlp.ListSize(size)))
+		return nil
+	}
+	envelope, err := s.Bytes()
+	if err != nil {
+		return err
+	}
+	inner, err := decodeTyped(envelope)
+	if err != nil {
+		return err
+	}
+	tx.setDecoded(inner, common.StorageSize(rlp.ListSize(size)))
+	return nil
+}
+
+// UnmarshalBinary decodes the canonical encoding of a transaction, as
+// produced by MarshalBinary.
+func (tx *Transaction) UnmarshalBinary(b []byte) error {
+	if len(b) > 0 && b[0] > 0x7f {
+		// An RLP list, so a legacy transaction
+		var inner txdata
+		if err := rlp.DecodeBytes(b, &inner); err != nil {
+			return err
+		}
+		tx.setDecoded(&inner, common.StorageSize(len(b)))
+		return nil
 	}
-	if tx.data.V != nil {
-		tx.signer = deriveSigner(tx.data.V)
+	inner, err := decodeTyped(b)
+	if err != nil {
+		return err
+	}
+	tx.setDecoded(inner, common.StorageSize(len(b)))
+	return nil
+}
+
+// decodeTyped decodes the envelope of a typed transaction.
+func decodeTyped(b []byte) (TxData, error) {
+	if len(b) == 0 {
+		return nil, errors.New("empty typed transaction")
+	}
+	newTx, ok := typedTxs[b[0]]
+	if !ok {
+		return nil, ErrTxTypeNotSupported
+	}
+	inner := newTx()
+	if err := rlp.DecodeBytes(b[1:], inner); err != nil {
+		return nil, err
+	}
+	return inner, nil
+}
+
+// setDecoded sets the payload of a freshly decoded transaction, along with
+// the best guess of its signer.
+func (tx *Transaction) setDecoded(inner TxData, size common.StorageSize) {
+	tx.inner = inner
+	tx.size.Store(size)
+	if v, _, _ := inner.rawSignatureValues(); inner.txType() != LegacyTxType {
+		tx.signer = NewChainIdSigner(inner.chainID())
+	} else if v != nil {
+		tx.signer = deriveSigner(v)
 	} else {
 		tx.signer = BasicSigner{}
 	}
-	return err
 }
 
-func (tx *Transaction) Data() []byte       { return common.CopyBytes(tx.data.Payload) }
-func (tx *Transaction) Gas() *big.Int      { return new(big.Int).Set(tx.data.GasLimit) }
-func (tx *Transaction) GasPrice() *big.Int { return new(big.Int).Set(tx.data.Price) }
-func (tx *Transaction) Value() *big.Int    { return new(big.Int).Set(tx.data.Amount) }
-func (tx *Transaction) Nonce() uint64      { return tx.data.AccountNonce }
+func (tx *Transaction) Data() []byte       { return common.CopyBytes(tx.inner.data()) }
+func (tx *Transaction) Gas() *big.Int      { return new(big.Int).Set(tx.inner.gas()) }
+func (tx *Transaction) GasPrice() *big.Int { return new(big.Int).Set(tx.inner.gasPrice()) }
+func (tx *Transaction) Value() *big.Int    { return new(big.Int).Set(tx.inner.value()) }
+func (tx *Transaction) Nonce() uint64      { return tx.inner.nonce() }
+
+// AccessList returns the access list of the transaction, which is nil for
+// transactions without one.
+func (tx *Transaction) AccessList() AccessList {
+	return tx.inner.accessList().copy()
+}
 
 func (tx *Transaction) To() *common.Address {
-	if tx.data.Recipient == nil {
-		return nil
-	} else {
-		to := *tx.data.Recipient
-		return &to
-	}
+	return copyAddressPtr(tx.inner.to())
 }
 
-// Hash hashes the RLP encoding of tx.
+// Hash hashes the RLP encoding of tx, or the envelope of typed transactions.
 // It uniquely identifies the transaction.
 func (tx *Transaction) Hash() common.Hash {
 	if hash := tx.hash.Load(); hash != nil {
 		return hash.(common.Hash)
 	}
-	v := rlpHash(tx)
+	var v common.Hash
+	if tx.Type() == LegacyTxType {
+		v = rlpHash(tx)
+	} else {
+		v = prefixedRlpHash(tx.Type(), tx.inner)
+	}
 	tx.hash.Store(v)
 	return v
 }
@@ -176,7 +369,7 @@ func (tx *Transaction) Size() common.StorageSize {
 		return size.(common.StorageSize)
 	}
 	c := writeCounter(0)
-	rlp.Encode(&c, &tx.data)
+	rlp.Encode(&c, tx)
 	tx.size.Store(common.StorageSize(c))
 	return common.StorageSize(c)
 }
@@ -187,8 +380,8 @@ func (tx *Transaction) From() (common.Address, error) {
 
 // Cost returns amount + gasprice * gaslimit.
 func (tx *Transaction) Cost() *big.Int {
-	total := new(big.Int).Mul(tx.data.Price, tx.data.GasLimit)
-	total.Add(total, tx.data.Amount)
+	total := new(big.Int).Mul(tx.inner.gasPrice(), tx.inner.gas())
+	total.Add(total, tx.inner.value())
 	return total
 }
 
@@ -197,7 +390,7 @@ func (tx *Transaction) SignatureValues() (v byte, r *big.Int, s *big.Int) {
 }
 
 func (tx *Transaction) RawSignatureValues() (v *big.Int, r *big.Int, s *big.Int) {
-	return tx.data.V, tx.data.R, tx.data.S
+	return tx.inner.rawSignatureValues()
 }
 
 func (tx *Transaction) WithSigner(signer Signer) *Transaction {
@@ -221,14 +414,17 @@ func (tx *Transaction) String() string {
 	} else {
 		from = fmt.Sprintf("%x", f[:])
 	}
-	if tx.data.Recipient == nil {
+	recipient := tx.inner.to()
+	if recipient == nil {
 		to = "[contract creation]"
 	} else {
-		to = fmt.Sprintf("%x", tx.data.Recipient[:])
+		to = fmt.Sprintf("%x", recipient[:])
 	}
-	enc, _ := rlp.EncodeToBytes(&tx.data)
+	v, r, s := tx.inner.rawSignatureValues()
+	enc, _ := tx.MarshalBinary()
 	return fmt.Sprintf(`
 	TX(%x)
+	Type:     %d
 	Contract: %v
 	From:     %s
 	To:       %s
@@ -243,17 +439,18 @@ func (tx *Transaction) String() string {
 	Hex:      %x
 `,
 		tx.Hash(),
-		len(tx.data.Recipient.Bytes()) == 0,
+		tx.Type(),
+		recipient == nil,
 		from,
 		to,
-		tx.data.AccountNonce,
-		tx.data.Price,
-		tx.data.GasLimit,
-		tx.data.Amount,
-		tx.data.Payload,
-		tx.data.V,
-		tx.data.R,
-		tx.data.S,
+		tx.inner.nonce(),
+		tx.inner.gasPrice(),
+		tx.inner.gas(),
+		tx.inner.value(),
+		tx.inner.data(),
+		v,
+		r,
+		s,
 		enc,
 	)
 }
@@ -297,7 +494,7 @@ func TxDifference(a, b Transactions) (keep Transactions) {
 type TxByNonce Transactions
 
 func (s TxByNonce) Len() int           { return len(s) }
-func (s TxByNonce) Less(i, j int) bool { return s[i].data.AccountNonce < s[j].data.AccountNonce }
+func (s TxByNonce) Less(i, j int) bool { return s[i].inner.nonce() < s[j].inner.nonce() }
 func (s TxByNonce) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
 
 // TxByPrice implements both the sort and the heap interface, making it useful
@@ -305,7 +502,7 @@ func (s TxByNonce) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
 type TxByPrice Transactions
 
 func (s TxByPrice) Len() int           { return len(s) }
-func (s TxByPrice) Less(i, j int) bool { return s[i].data.Price.Cmp(s[j].data.Price) > 0 }
+func (s TxByPrice) Less(i, j int) bool { return s[i].inner.gasPrice().Cmp(s[j].inner.gasPrice()) > 0 }
 func (s TxByPrice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
 
 func (s *TxByPrice) Push(x interface{}) {
@@ -49,6 +49,16 @@ func normaliseV(s Signer, v *big.Int) byte {
 	return byte(v.Uint64())
 }
 
+// signatureV returns the Ethereum version of the V parameter of the given
+// transaction. Typed transactions carry the bare recovery id instead.
+func signatureV(s Signer, tx *Transaction) byte {
+	v, _, _ := tx.inner.rawSignatureValues()
+	if tx.Type() != LegacyTxType {
+		return byte(v.Uint64()) + 27
+	}
+	return normaliseV(s, v)
+}
+
 // deriveChainId derives the chain id from the given v parameter
 func deriveChainId(v *big.Int) *big.Int {
 	if v.BitLen() <= 64 {
@@ -101,7 +111,8 @@ func Sender(signer Signer, tx *Transaction) (common.Address, error) {
 
 // SignatureValues returns the ECDSA signature values contained in the transaction.
 func SignatureValues(signer Signer, tx *Transaction) (v byte, r *big.Int, s *big.Int) {
-	return normaliseV(signer, tx.data.V), new(big.Int).Set(tx.data.R), new(big.Int).Set(tx.data.S)
+	_, R, S := tx.inner.rawSignatureValues()
+	return signatureV(signer, tx), new(big.Int).Set(R), new(big.Int).Set(S)
 }
 
 type Signer interface {
@@ -163,13 +174,17 @@ func (s ChainIdSigner) PublicKey(tx *Transaction) ([]byte, error) {
 		return nil, ErrInvalidChainId
 	}
 
-	V := normaliseV(s, tx.data.V)
-	if !crypto.ValidateSignatureValues(V, tx.data.R, tx.data.S, true) {
+	v, r, s2 := tx.inner.rawSignatureValues()
+	if tx.Type() != LegacyTxType && v.BitLen() > 1 {
+		return nil, ErrInvalidSig
+	}
+	V := signatureV(s, tx)
+	if !crypto.ValidateSignatureValues(V, r, s2, true) {
 		return nil, ErrInvalidSig
 	}
 
 	// encode the signature in uncompressed format
-	R, S := tx.data.R.Bytes(), tx.data.S.Bytes()
+	R, S := r.Bytes(), s2.Bytes()
 	sig := make([]byte, 65)
 	copy(sig[32-len(R):32], R)
 	copy(sig[64-len(S):64], S)
@@ -194,29 +209,29 @@ func (s ChainIdSigner) WithSignature(tx *Transaction, sig []byte) (*Transaction,
 		panic(fmt.Sprintf("wrong size for snature: got %d, want 65", len(sig)))
 	}
 
-	cpy := &Transaction{signer: tx.signer, data: tx.data}
-	cpy.data.R = new(big.Int).SetBytes(sig[:32])
-	cpy.data.S = new(big.Int).SetBytes(sig[32:64])
-	cpy.data.V = new(big.Int).SetBytes([]byte{sig[64]})
-	if s.chainId.BitLen() > 0 {
-		cpy.data.V = big.NewInt(int64(sig[64] + 35))
-		cpy.data.V.Add(cpy.data.V, s.chainIdMul)
+	if tx.Type() != LegacyTxType && (tx.ChainId() == nil || s.chainId == nil || tx.ChainId().Cmp(s.chainId) != 0) {
+		return nil, ErrInvalidChainId
 	}
+	cpy := &Transaction{signer: tx.signer, inner: tx.inner.copy()}
+	R := new(big.Int).SetBytes(sig[:32])
+	S := new(big.Int).SetBytes(sig[32:64])
+	V := new(big.Int).SetBytes([]byte{sig[64]})
+	if tx.Type() == LegacyTxType && s.chainId.BitLen() > 0 {
+		V = big.NewInt(int64(sig[64] + 35))
+		V.Add(V, s.chainIdMul)
+	}
+	cpy.inner.setSignatureValues(V, R, S)
 	return cpy, nil
 }
 
 // Hash returns the hash to be signed by the sender.
-// It does not uniquely identify the transaction.
+// It does not uniquely identify the transaction. Typed transactions already
+// commit to their chain id, so their fields are hashed with the type prefix.
 func (s ChainIdSigner) Hash(tx *Transaction) common.Hash {
-	return rlpHash([]interface{}{
-		tx.data.AccountNonce,
-		tx.data.Price,
-		tx.data.GasLimit,
-		tx.data.Recipient,
-		tx.data.Amount,
-		tx.data.Payload,
-		s.chainId, uint(0), uint(0),
-	})
+	if tx.Type() != LegacyTxType {
+		return prefixedRlpHash(tx.Type(), tx.inner.sigFields())
+	}
+	return rlpHash(append(tx.inner.sigFields(), s.chainId, uint(0), uint(0)))
 }
 
 func (s ChainIdSigner) SigECDSA(tx *Transaction, prv *ecdsa.PrivateKey) (*Transaction, error) {
@@ -241,10 +256,15 @@ func (fs BasicSigner) WithSignature(tx *Transaction, sig []byte) (*Transaction,
 	if len(sig) != 65 {
 		panic(fmt.Sprintf("wrong size for snature: got %d, want 65", len(sig)))
 	}
-	cpy := &Transaction{signer: tx.signer, data: tx.data}
-	cpy.data.R = new(big.Int).SetBytes(sig[:32])
-	cpy.data.S = new(big.Int).SetBytes(sig[32:64])
-	cpy.data.V = new(big.Int).SetBytes([]byte{sig[64] + 27})
+	if tx.Type() != LegacyTxType {
+		return nil, ErrTxTypeNotSupported
+	}
+	cpy := &Transaction{signer: tx.signer, inner: tx.inner.copy()}
+	cpy.inner.setSignatureValues(
+		new(big.Int).SetBytes([]byte{sig[64] + 27}),
+		new(big.Int).SetBytes(sig[:32]),
+		new(big.Int).SetBytes(sig[32:64]),
+	)
 	return cpy, nil
 }
 
@@ -260,27 +280,24 @@ func (fs BasicSigner) SignECDSA(tx *Transaction, prv *ecdsa.PrivateKey) (*Transa
 // Hash returns the hash to be sned by the sender.
 // It does not uniquely identify the transaction.
 func (fs BasicSigner) Hash(tx *Transaction) common.Hash {
-	return rlpHash([]interface{}{
-		tx.data.AccountNonce,
-		tx.data.Price,
-		tx.data.GasLimit,
-		tx.data.Recipient,
-		tx.data.Amount,
-		tx.data.Payload,
-	})
+	return rlpHash(tx.inner.sigFields())
 }
 
 func (fs BasicSigner) PublicKey(tx *Transaction) ([]byte, error) {
-	if tx.data.V.BitLen() > 8 {
+	if tx.Type() != LegacyTxType {
+		return nil, ErrTxTypeNotSupported
+	}
+	v, R, S := tx.inner.rawSignatureValues()
+	if v.BitLen() > 8 {
 		return nil, ErrInvalidSig
 	}
 
-	V := byte(tx.data.V.Uint64())
-	if !crypto.ValidateSignatureValues(V, tx.data.R, tx.data.S, false) {
+	V := byte(v.Uint64())
+	if !crypto.ValidateSignatureValues(V, R, S, false) {
 		return nil, ErrInvalidSig
 	}
 	// encode the snature in uncompressed format
-	r, s := tx.data.R.Bytes(), tx.data.S.Bytes()
+	r, s := R.Bytes(), S.Bytes()
 	sig := make([]byte, 65)
 	copy(sig[32-len(r):32], r)
 	copy(sig[64-len(s):64], s)
@@ -45,8 +45,8 @@ func TestClassicChainId(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	if txs.data.V.Cmp(big.NewInt(157)) != 0 && txs.data.V.Cmp(big.NewInt(158)) != 0 {
-		t.Errorf("V %v != 157 || 158", txs.data.V)
+	if V, _, _ := txs.RawSignatureValues(); V.Cmp(big.NewInt(157)) != 0 && V.Cmp(big.NewInt(158)) != 0 {
+		t.Errorf("V %v != 157 || 158", V)
 	}
 
 	v := normaliseV(NewChainIdSigner(big.NewInt(61)), big.NewInt(157))
@@ -76,8 +76,8 @@ func TestMordenChainId(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	if txs.data.V.Cmp(big.NewInt(160)) != 0 && txs.data.V.Cmp(big.NewInt(159)) != 0 {
-		t.Errorf("V %v != 159 || 160", txs.data.V)
+	if V, _, _ := txs.RawSignatureValues(); V.Cmp(big.NewInt(160)) != 0 && V.Cmp(big.NewInt(159)) != 0 {
+		t.Errorf("V %v != 159 || 160", V)
 	}
 
 	v := normaliseV(NewChainIdSigner(big.NewInt(62)), big.NewInt(160))
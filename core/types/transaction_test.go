@@ -174,3 +174,193 @@ func TestTransactionPriceNonceSort(t *testing.T) {
 		}
 	}
 }
+
+// testTypedTxType is the type of testTypedTx, a typed transaction only known
+// to the tests.
+const testTypedTxType = 0x7e
+
+type testTypedTx struct {
+	ChainID   *big.Int
+	Nonce     uint64
+	Price     *big.Int
+	GasLimit  *big.Int
+	Recipient *common.Address `rlp:"nil"`
+	Amount    *big.Int
+	Payload   []byte
+	V, R, S   *big.Int
+}
+
+func init() {
+	typedTxs[testTypedTxType] = func() TxData { return new(testTypedTx) }
+}
+
+func (d *testTypedTx) txType() byte { return testTypedTxType }
+
+func (d *testTypedTx) copy() TxData {
+	cpy := *d
+	cpy.Payload = common.CopyBytes(d.Payload)
+	return &cpy
+}
+
+func (d *testTypedTx) chainID() *big.Int      { return d.ChainID }
+func (d *testTypedTx) nonce() uint64          { return d.Nonce }
+func (d *testTypedTx) gasPrice() *big.Int     { return d.Price }
+func (d *testTypedTx) gas() *big.Int          { return d.GasLimit }
+func (d *testTypedTx) value() *big.Int        { return d.Amount }
+func (d *testTypedTx) to() *common.Address    { return d.Recipient }
+func (d *testTypedTx) data() []byte           { return d.Payload }
+func (d *testTypedTx) accessList() AccessList { return nil }
+func (d *testTypedTx) sigFields() []interface{} {
+	return []interface{}{d.ChainID, d.Nonce, d.Price, d.GasLimit, d.Recipient, d.Amount, d.Payload}
+}
+func (d *testTypedTx) rawSignatureValues() (v, r, s *big.Int) { return d.V, d.R, d.S }
+func (d *testTypedTx) setSignatureValues(v, r, s *big.Int)    { d.V, d.R, d.S = v, r, s }
+
+func signedTypedTx(t *testing.T, chainId *big.Int) (*Transaction, common.Address) {
+	key, addr := defaultTestKey()
+	to := common.HexToAddress("095e7baea6a6c7c4c2dfeb977efac326af552d87")
+	tx, err := NewTx(&testTypedTx{
+		ChainID:   chainId,
+		Nonce:     3,
+		Price:     big.NewInt(1),
+		GasLimit:  big.NewInt(21000),
+		Recipient: &to,
+		Amount:    big.NewInt(10),
+		Payload:   []byte{0xaa},
+		V:         new(big.Int),
+		R:         new(big.Int),
+		S:         new(big.Int),
+	}).SignECDSA(key)
+	if err != nil {
+		t.Fatalf("failed to sign typed transaction: %v", err)
+	}
+	return tx, addr
+}
+
+// Tests that typed transactions survive round trips through their envelope
+// and through RLP structures, and that their sender can be recovered.
+func TestTypedTransactionEncoding(t *testing.T) {
+	tx, addr := signedTypedTx(t, big.NewInt(1))
+
+	enc, err := tx.MarshalBinary()
+	if err != nil {
+		t.Fatalf("failed to encode transaction: %v", err)
+	}
+	if enc[0] != testTypedTxType {
+		t.Fatalf("envelope type mismatch: have %#x, want %#x", enc[0], testTypedTxType)
+	}
+	if hash := crypto.Keccak256Hash(enc); tx.Hash() != hash {
+		t.Errorf("hash mismatch: have %x, want %x", tx.Hash(), hash)
+	}
+	if v, _, _ := tx.RawSignatureValues(); v.BitLen() > 1 {
+		t.Errorf("typed transaction V is not a recovery id: %v", v)
+	}
+
+	decoded := new(Transaction)
+	if err := decoded.UnmarshalBinary(enc); err != nil {
+		t.Fatalf("failed to decode envelope: %v", err)
+	}
+	if decoded.Type() != testTypedTxType || decoded.Hash() != tx.Hash() {
+		t.Errorf("envelope round trip mismatch: have type %d hash %x, want type %d hash %x", decoded.Type(), decoded.Hash(), testTypedTxType, tx.Hash())
+	}
+	if from, err := decoded.From(); err != nil || from != addr {
+		t.Errorf("sender mismatch: have %x (%v), want %x", from, err, addr)
+	}
+	if !decoded.Protected() || decoded.ChainId().Cmp(big.NewInt(1)) != 0 {
+		t.Errorf("chain id mismatch: have %v (protected %v), want 1", decoded.ChainId(), decoded.Protected())
+	}
+
+	// Typed transactions mix with legacy ones in RLP lists
+	key, _ := defaultTestKey()
+	legacy, _ := NewTransaction(0, addr, big.NewInt(1), big.NewInt(21000), big.NewInt(1), nil).WithSigner(NewChainIdSigner(big.NewInt(1))).SignECDSA(key)
+	list, err := rlp.EncodeToBytes(Transactions{legacy, tx})
+	if err != nil {
+		t.Fatalf("failed to encode transaction list: %v", err)
+	}
+	var txs Transactions
+	if err := rlp.DecodeBytes(list, &txs); err != nil {
+		t.Fatalf("failed to decode transaction list: %v", err)
+	}
+	if len(txs) != 2 || txs[0].Hash() != legacy.Hash() || txs[1].Hash() != tx.Hash() {
+		t.Fatalf("transaction list round trip mismatch")
+	}
+	if txs[0].Type() != LegacyTxType || txs[1].Type() != testTypedTxType {
+		t.Errorf("type mismatch: have %d, %d", txs[0].Type(), txs[1].Type())
+	}
+	if size := txs[1].Size(); size != tx.Size() {
+		t.Errorf("size mismatch: have %v, want %v", size, tx.Size())
+	}
+	if DeriveSha(txs) != DeriveSha(Transactions{legacy, tx}) {
+		t.Error("transaction root mismatch")
+	}
+}
+
+// Tests that typed transactions are only accepted by the signer of their
+// chain and that unknown types are rejected.
+func TestTypedTransactionRejections(t *testing.T) {
+	tx, _ := signedTypedTx(t, big.NewInt(1))
+
+	if _, err := Sender(NewChainIdSigner(big.NewInt(2)), tx); err != ErrInvalidChainId {
+		t.Errorf("wrong chain id: error mismatch: have %v, want %v", err, ErrInvalidChainId)
+	}
+	if _, err := Sender(BasicSigner{}, tx); err != ErrTxTypeNotSupported {
+		t.Errorf("basic signer: error mismatch: have %v, want %v", err, ErrTxTypeNotSupported)
+	}
+	key, _ := defaultTestKey()
+	if _, err := tx.WithSigner(NewChainIdSigner(big.NewInt(2))).SignECDSA(key); err != ErrInvalidChainId {
+		t.Errorf("signing for another chain: error mismatch: have %v, want %v", err, ErrInvalidChainId)
+	}
+
+	enc, _ := tx.MarshalBinary()
+	enc[0] = testTypedTxType - 1
+	if err := new(Transaction).UnmarshalBinary(enc); err != ErrTxTypeNotSupported {
+		t.Errorf("unknown type: error mismatch: have %v, want %v", err, ErrTxTypeNotSupported)
+	}
+}
+
+// Tests that access list transactions survive a round trip through their
+// envelope and that their access list can't be modified through accessors.
+func TestAccessListTxEncoding(t *testing.T) {
+	key, addr := defaultTestKey()
+	to := common.HexToAddress("095e7baea6a6c7c4c2dfeb977efac326af552d87")
+	acl := AccessList{{Address: to, StorageKeys: []common.Hash{{0x01}}}}
+	tx, err := NewTx(&AccessListTx{
+		ChainID:    big.NewInt(1),
+		Nonce:      1,
+		GasPrice:   big.NewInt(1),
+		Gas:        big.NewInt(30000),
+		To:         &to,
+		Value:      big.NewInt(10),
+		AccessList: acl,
+		V:          new(big.Int),
+		R:          new(big.Int),
+		S:          new(big.Int),
+	}).SignECDSA(key)
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	enc, err := tx.MarshalBinary()
+	if err != nil {
+		t.Fatalf("failed to encode transaction: %v", err)
+	}
+	if enc[0] != AccessListTxType {
+		t.Fatalf("envelope type mismatch: have %#x, want %#x", enc[0], AccessListTxType)
+	}
+	decoded := new(Transaction)
+	if err := decoded.UnmarshalBinary(enc); err != nil {
+		t.Fatalf("failed to decode transaction: %v", err)
+	}
+	if decoded.Hash() != tx.Hash() {
+		t.Errorf("hash mismatch: have %x, want %x", decoded.Hash(), tx.Hash())
+	}
+	if from, err := decoded.From(); err != nil || from != addr {
+		t.Errorf("sender mismatch: have %x (%v), want %x", from, err, addr)
+	}
+	if have := decoded.AccessList(); len(have) != 1 || have[0].Address != to || len(have[0].StorageKeys) != 1 || have[0].StorageKeys[0] != (common.Hash{0x01}) {
+		t.Errorf("access list mismatch: have %+v, want %+v", have, acl)
+	}
+	decoded.AccessList()[0].StorageKeys[0] = common.Hash{0x02}
+	if decoded.AccessList()[0].StorageKeys[0] != (common.Hash{0x01}) {
+		t.Error("access list modified through accessor")
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright 2015 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/consensus"
+	"github.com/ellaism/go-ellaism/core/types"
+)
+
+const (
+	MaxUncles     = 2 // Maximum number of uncles a block may include
+	MaxUncleDepth = 7 // Number of ancestors whose children may be included as uncles
+)
+
+// UncleFamily is the recent family of a block, used to check the uncles it
+// includes: its ancestors up to the uncle depth, the uncles those included
+// and the uncles added to the block so far. An uncle must be the child of an
+// ancestor other than the block's parent and may not be an ancestor or an
+// uncle already included.
+type UncleFamily struct {
+	parent    common.Hash
+	ancestors map[common.Hash]*types.Header
+	uncles    map[common.Hash]struct{}
+}
+
+// NewUncleFamily returns the family of a block given its ancestors, parent
+// first, as returned by BlockChain.GetBlocksFromHash.
+func NewUncleFamily(ancestors []*types.Block) *UncleFamily {
+	f := &UncleFamily{
+		ancestors: make(map[common.Hash]*types.Header),
+		uncles:    make(map[common.Hash]struct{}),
+	}
+	if len(ancestors) > 0 {
+		f.parent = ancestors[0].Hash()
+	}
+	for _, ancestor := range ancestors {
+		f.ancestors[ancestor.Hash()] = ancestor.Header()
+		for _, uncle := range ancestor.Uncles() {
+			f.uncles[uncle.Hash()] = struct{}{}
+		}
+	}
+	return f
+}
+
+// Check returns an error if the uncle may not be included by the block. The
+// uncle's header isn't validated.
+func (f *UncleFamily) Check(uncle *types.Header) error {
+	hash := uncle.Hash()
+	if _, ok := f.ancestors[hash]; ok {
+		return ErrUncleIsAncestor
+	}
+	if _, ok := f.uncles[hash]; ok {
+		return ErrDuplicateUncle
+	}
+	if uncle.ParentHash == f.parent {
+		return ErrUncleIsSibling
+	}
+	if _, ok := f.ancestors[uncle.ParentHash]; !ok {
+		return ErrDanglingUncle
+	}
+	return nil
+}
+
+// Add checks the uncle and adds it to the uncles of the block.
+func (f *UncleFamily) Add(uncle *types.Header) error {
+	if err := f.Check(uncle); err != nil {
+		return err
+	}
+	f.uncles[uncle.Hash()] = struct{}{}
+	return nil
+}
+
+// Parent returns the header of the uncle's parent, or nil if it isn't an
+// ancestor of the block.
+func (f *UncleFamily) Parent(uncle *types.Header) *types.Header {
+	return f.ancestors[uncle.ParentHash]
+}
+
+// UncleValidator validates the uncles included by blocks.
+type UncleValidator struct {
+	config *ChainConfig
+	engine consensus.Engine
+	chain  consensus.ChainReader
+	depth  int
+}
+
+// NewUncleValidator returns an uncle validator accepting the children of the
+// given number of ancestors as uncles.
+func NewUncleValidator(config *ChainConfig, engine consensus.Engine, chain consensus.ChainReader, depth int) *UncleValidator {
+	return &UncleValidator{
+		config: config,
+		engine: engine,
+		chain:  chain,
+		depth:  depth,
+	}
+}
+
+// Depth returns the number of ancestors whose children may be included as
+// uncles.
+func (v *UncleValidator) Depth() int {
+	return v.depth
+}
+
+// ValidateUncles validates the uncles of the block given its ancestors, parent
+// first, up to the uncle depth. Engines other than proof-of-work don't allow
+// uncles. It returns an *UncleErr describing the first
+// invalid uncle.
+func (v *UncleValidator) ValidateUncles(block *types.Block, ancestors []*types.Block) error {
+	uncles := block.Uncles()
+	if _, ok := v.engine.(consensus.PoW); !ok && len(uncles) > 0 {
+		return &UncleErr{Number: block.Number(), Hash: block.Hash(), Index: -1, Reason: ErrUnclesNotPoW}
+	}
+	if len(uncles) > MaxUncles {
+		return &UncleErr{Number: block.Number(), Hash: block.Hash(), Index: -1, Reason: ErrTooManyUncles}
+	}
+	if len(ancestors) > v.depth {
+		ancestors = ancestors[:v.depth]
+	}
+	family := NewUncleFamily(ancestors)
+	for i, uncle := range uncles {
+		err := family.Add(uncle)
+		if err == nil {
+			err = ValidateHeader(v.config, v.engine, v.chain, uncle, family.Parent(uncle), true, true)
+		}
+		if err != nil {
+			return &UncleErr{Number: block.Number(), Hash: block.Hash(), Index: i, Uncle: uncle.Hash(), Reason: err}
+		}
+	}
+	return nil
+}
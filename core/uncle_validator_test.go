@@ -0,0 +1,189 @@
+// Copyright 2015 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/core/types"
+	"github.com/ellaism/go-ellaism/ethdb"
+	"github.com/ellaism/go-ellaism/event"
+	"github.com/ellaism/go-ellaism/pow"
+)
+
+// uncleTestChain is a chain of ten blocks, the sixth of which includes an
+// uncle, and a generator of uncle candidates forking off it.
+type uncleTestChain struct {
+	config *ChainConfig
+	db     ethdb.Database
+	bc     *BlockChain
+	blocks []*types.Block
+	uncle  *types.Header // Uncle included by blocks[5]
+}
+
+func newUncleTestChain(t *testing.T) *uncleTestChain {
+	db, _ := ethdb.NewMemDatabase()
+	genesis := WriteGenesisBlockForTesting(db)
+	c := &uncleTestChain{config: DefaultConfigMainnet.ChainConfig, db: db}
+
+	c.blocks, _ = GenerateChain(c.config, genesis, db, 10, func(i int, b *BlockGen) {
+		if i == 5 {
+			c.uncle = c.side(b.PrevBlock(3), 0xaa)
+			b.AddUncle(c.uncle)
+		}
+	})
+	var err error
+	if c.bc, err = NewBlockChain(db, c.config, FakePow{}, new(event.TypeMux)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.bc.InsertChain(c.blocks); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// side returns the header of a valid child of parent which isn't part of the
+// chain.
+func (c *uncleTestChain) side(parent *types.Block, coinbase byte) *types.Header {
+	blocks, _ := GenerateChain(c.config, parent, c.db, 1, func(i int, b *BlockGen) {
+		b.SetCoinbase(common.Address{coinbase})
+	})
+	return blocks[0].Header()
+}
+
+// child returns a child of the chain's head including the given uncles.
+func (c *uncleTestChain) child(uncles ...*types.Header) *types.Block {
+	head := c.blocks[len(c.blocks)-1]
+	header := &types.Header{ParentHash: head.Hash(), Number: new(big.Int).Add(head.Number(), common.Big1)}
+	return types.NewBlockWithHeader(header).WithBody(nil, uncles)
+}
+
+func TestValidateUncles(t *testing.T) {
+	c := newUncleTestChain(t)
+
+	badDifficulty := c.side(c.blocks[7], 0xaa)
+	badDifficulty.Difficulty = new(big.Int).Add(badDifficulty.Difficulty, common.Big1)
+	unknownParent := c.side(c.blocks[7], 0xaa)
+	unknownParent.ParentHash = common.Hash{0x01}
+
+	tests := []struct {
+		name   string
+		uncles []*types.Header
+		depth  int
+		pow    pow.PoW
+		index  int   // Index of the invalid uncle
+		reason error // Reason the uncles are invalid, nil if valid
+		header bool  // Whether the first uncle's header is invalid
+	}{
+		{name: "none"},
+		{name: "valid", uncles: []*types.Header{c.side(c.blocks[8], 0xaa), c.side(c.blocks[3], 0xbb)}},
+		{name: "cousins", uncles: []*types.Header{c.side(c.blocks[8], 0xaa), c.side(c.blocks[8], 0xbb)}},
+		{
+			name:   "too many",
+			uncles: []*types.Header{c.side(c.blocks[8], 0xaa), c.side(c.blocks[7], 0xaa), c.side(c.blocks[6], 0xaa)},
+			index:  -1,
+			reason: ErrTooManyUncles,
+		},
+		{
+			name:   "duplicate",
+			uncles: []*types.Header{c.side(c.blocks[8], 0xaa), c.side(c.blocks[8], 0xaa)},
+			index:  1,
+			reason: ErrDuplicateUncle,
+		},
+		{name: "included by ancestor", uncles: []*types.Header{c.uncle}, reason: ErrDuplicateUncle},
+		{name: "ancestor", uncles: []*types.Header{c.blocks[7].Header()}, reason: ErrUncleIsAncestor},
+		{name: "sibling", uncles: []*types.Header{c.side(c.blocks[9], 0xaa)}, reason: ErrUncleIsSibling},
+		{name: "too deep", uncles: []*types.Header{c.side(c.blocks[2], 0xaa)}, reason: ErrDanglingUncle},
+		{name: "deeper depth", uncles: []*types.Header{c.side(c.blocks[2], 0xaa)}, depth: MaxUncleDepth + 1},
+		{name: "shallower depth", uncles: []*types.Header{c.side(c.blocks[3], 0xbb)}, depth: MaxUncleDepth - 1, reason: ErrDanglingUncle},
+		{name: "unknown parent", uncles: []*types.Header{unknownParent}, reason: ErrDanglingUncle},
+		{name: "invalid header", uncles: []*types.Header{badDifficulty}, header: true},
+		{name: "invalid pow", uncles: []*types.Header{c.side(c.blocks[7], 0xaa)}, pow: failPow{9}, header: true},
+	}
+	for _, tt := range tests {
+		if tt.depth == 0 {
+			tt.depth = MaxUncleDepth
+		}
+		if tt.pow == nil {
+			tt.pow = FakePow{}
+		}
+		block := c.child(tt.uncles...)
+		v := NewUncleValidator(c.config, NewEthash(c.config, tt.pow), c.bc, tt.depth)
+		err := v.ValidateUncles(block, c.bc.GetBlocksFromHash(block.ParentHash(), tt.depth))
+
+		switch {
+		case tt.header:
+			// The reason is the error validating the uncle's header.
+			uerr, ok := err.(*UncleErr)
+			if !ok || uerr.Index != 0 || uerr.Uncle != tt.uncles[0].Hash() {
+				t.Errorf("%s: error %v, want header validation failure of uncle 0", tt.name, err)
+				continue
+			}
+			if _, ok := tt.pow.(failPow); ok {
+				if _, ok := uerr.Reason.(*BlockNonceErr); !ok {
+					t.Errorf("%s: reason %v, want *BlockNonceErr", tt.name, uerr.Reason)
+				}
+			}
+		case tt.reason == nil:
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", tt.name, err)
+			}
+		default:
+			uerr, ok := err.(*UncleErr)
+			if !ok {
+				t.Errorf("%s: error %v, want *UncleErr", tt.name, err)
+				continue
+			}
+			if uerr.Reason != tt.reason || uerr.Index != tt.index {
+				t.Errorf("%s: uncle %d invalid with %v, want uncle %d with %v", tt.name, uerr.Index, uerr.Reason, tt.index, tt.reason)
+			}
+			if uerr.Number.Cmp(block.Number()) != 0 || uerr.Hash != block.Hash() {
+				t.Errorf("%s: error is about block #%v [%x], want #%v [%x]", tt.name, uerr.Number, uerr.Hash, block.Number(), block.Hash())
+			}
+			if tt.index >= 0 && uerr.Uncle != tt.uncles[tt.index].Hash() {
+				t.Errorf("%s: error is about uncle %x, want %x", tt.name, uerr.Uncle, tt.uncles[tt.index].Hash())
+			}
+			if !IsUncleErr(err) || !IsValidateError(err) {
+				t.Errorf("%s: error %v isn't recognized as an uncle validation error", tt.name, err)
+			}
+		}
+	}
+}
+
+// Tests that blocks with invalid uncles are rejected by InsertChain with an
+// error identifying the uncle.
+func TestInsertChainInvalidUncle(t *testing.T) {
+	c := newUncleTestChain(t)
+
+	sibling := c.side(c.blocks[9], 0xaa)
+	blocks, _ := GenerateChain(c.config, c.blocks[9], c.db, 1, func(i int, b *BlockGen) {
+		b.AddUncle(sibling)
+	})
+	_, err := c.bc.InsertChain(blocks)
+	uerr, ok := err.(*UncleErr)
+	if !ok {
+		t.Fatalf("error %v, want *UncleErr", err)
+	}
+	if uerr.Reason != ErrUncleIsSibling || uerr.Index != 0 || uerr.Uncle != sibling.Hash() || uerr.Hash != blocks[0].Hash() {
+		t.Errorf("error %v, want uncle 0 [%x] of block [%x] to be a sibling", err, sibling.Hash(), blocks[0].Hash())
+	}
+	if head := c.bc.CurrentBlock(); head.Hash() != c.blocks[9].Hash() {
+		t.Errorf("head #%d [%x], want #%d [%x]", head.Number(), head.Hash(), c.blocks[9].Number(), c.blocks[9].Hash())
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"fmt"
+
+	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/core/types"
+)
+
+// VerifyResult is the outcome of checking the stored data of a canonical
+// block. Problems lists every inconsistency found; it is empty if the data of
+// the block is sound.
+type VerifyResult struct {
+	Number   uint64      `json:"number"`
+	Hash     common.Hash `json:"hash"`
+	Problems []string    `json:"problems"`
+}
+
+// VerifyBlock checks the stored data of the canonical block with the given
+// number: that its header hashes to the canonical hash, links to the canonical
+// parent and carries a valid proof of work, that its body matches the
+// transaction and uncle roots of the header, that its stored receipts match the
+// receipt root and logs bloom of the header, and that the transaction lookups
+// point at the block. With reexec the block is also replayed on top of the
+// state of its parent, as by ReplayBlock, to confirm its state root and
+// receipts. The data is read from the database, bypassing the caches, and the
+// chain itself is left untouched.
+func (self *BlockChain) VerifyBlock(number uint64, reexec bool) (*VerifyResult, error) {
+	hash := GetCanonicalHash(self.chainDb, number)
+	if hash == (common.Hash{}) {
+		return nil, fmt.Errorf("block #%d not found", number)
+	}
+	result := &VerifyResult{Number: number, Hash: hash, Problems: []string{}}
+	problem := func(format string, args ...interface{}) {
+		result.Problems = append(result.Problems, fmt.Sprintf(format, args...))
+	}
+
+	// Check the header and its links to the chain
+	header := GetHeader(self.chainDb, hash)
+	if header == nil {
+		problem("header missing or corrupt")
+		return result, nil
+	}
+	if have := header.Hash(); have != hash {
+		problem("header hash: canonical %x, stored header %x", hash, have)
+	}
+	if header.Number.Uint64() != number {
+		problem("header number: canonical %d, stored header %v", number, header.Number)
+	}
+	if number > 0 {
+		if parent := GetCanonicalHash(self.chainDb, number-1); header.ParentHash != parent {
+			problem("parent hash: canonical %x, header %x", parent, header.ParentHash)
+		}
+		if err := self.engine.VerifySeal(self, header); err != nil {
+			problem("proof of work: %v", err)
+		}
+	}
+
+	// Check the body against the header
+	body := GetBody(self.chainDb, hash)
+	if body == nil {
+		problem("body missing or corrupt")
+		return result, nil
+	}
+	if txSha := types.DeriveSha(types.Transactions(body.Transactions)); txSha != header.TxHash {
+		problem("transaction root: header %x, body %x", header.TxHash, txSha)
+	}
+	if uncleSha := types.CalcUncleHash(body.Uncles); uncleSha != header.UncleHash {
+		problem("uncles hash: header %x, body %x", header.UncleHash, uncleSha)
+	}
+
+	// Check the receipts against the header
+	receipts := GetBlockReceipts(self.chainDb, hash)
+	if len(receipts) != len(body.Transactions) {
+		problem("receipts: %d stored for %d transactions", len(receipts), len(body.Transactions))
+	} else {
+		if receiptSha := types.DeriveSha(receipts); receiptSha != header.ReceiptHash {
+			problem("receipt root: header %x, stored %x", header.ReceiptHash, receiptSha)
+		}
+		if bloom := types.CreateBloom(receipts); bloom != header.Bloom {
+			problem("logs bloom: header %x, stored %x", header.Bloom, bloom)
+		}
+	}
+
+	// Check the transaction lookups point at the block
+	for i, tx := range body.Transactions {
+		stored, blockHash, blockNumber, index := GetTransaction(self.chainDb, tx.Hash())
+		switch {
+		case stored == nil:
+			problem("transaction %d [%x…]: lookup missing", i, tx.Hash().Bytes()[:4])
+		case blockHash != hash || blockNumber != number || index != uint64(i):
+			problem("transaction %d [%x…]: lookup points at #%d [%x…] index %d", i, tx.Hash().Bytes()[:4], blockNumber, blockHash.Bytes()[:4], index)
+		}
+	}
+
+	// Re-execute the block if requested, the genesis block has nothing to execute
+	if reexec && number > 0 && len(result.Problems) == 0 {
+		replay, err := self.ReplayBlock(hash)
+		if err != nil {
+			problem("replay: %v", err)
+		} else {
+			for _, mismatch := range replay.Mismatches {
+				problem("replay: %s", mismatch)
+			}
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,98 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/core/types"
+	"github.com/ellaism/go-ellaism/crypto"
+	"github.com/ellaism/go-ellaism/ethdb"
+	"github.com/ellaism/go-ellaism/event"
+)
+
+// Tests that imported blocks verify cleanly and that corrupted bodies,
+// receipts and transaction lookups are reported.
+func TestVerifyBlock(t *testing.T) {
+	key, _ := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	addr := crypto.PubkeyToAddress(key.PublicKey)
+
+	db, _ := ethdb.NewMemDatabase()
+	genesis := WriteGenesisBlockForTesting(db, GenesisAccount{addr, big.NewInt(1e18)})
+	config := MakeDiehardChainConfig()
+	signer := types.NewChainIdSigner(big.NewInt(63))
+
+	chain, _ := GenerateChain(config, genesis, db, 3, func(i int, b *BlockGen) {
+		tx, err := types.NewTransaction(b.TxNonce(addr), common.Address{0xaa}, big.NewInt(1000), TxGas, big.NewInt(1), nil).WithSigner(signer).SignECDSA(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b.AddTx(tx)
+	})
+	blockchain, err := NewBlockChain(db, config, FakePow{}, new(event.TypeMux))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i, err := blockchain.InsertChain(chain); err != nil {
+		t.Fatalf("failed to insert block %d: %v", i, err)
+	}
+
+	for n := uint64(0); n <= 3; n++ {
+		result, err := blockchain.VerifyBlock(n, true)
+		if err != nil {
+			t.Fatalf("block #%d: verification failed: %v", n, err)
+		}
+		if len(result.Problems) != 0 {
+			t.Errorf("block #%d: unexpected problems: %v", n, result.Problems)
+		}
+	}
+
+	// Drop the lookup of a transaction and corrupt the receipts of a block
+	block := chain[0]
+	DeleteTransaction(db, block.Transactions()[0].Hash())
+	receipts := GetBlockReceipts(db, block.Hash())
+	receipts[0].CumulativeGasUsed = new(big.Int).Add(receipts[0].CumulativeGasUsed, common.Big1)
+	if err := WriteBlockReceipts(db, block.Hash(), receipts); err != nil {
+		t.Fatal(err)
+	}
+	result, err := blockchain.VerifyBlock(block.NumberU64(), false)
+	if err != nil {
+		t.Fatalf("verification failed: %v", err)
+	}
+	if len(result.Problems) != 2 {
+		t.Errorf("problem count: have %d, want 2: %v", len(result.Problems), result.Problems)
+	}
+
+	// Swap the body of a block for the body of another one
+	block = chain[1]
+	if err := WriteBody(db, block.Hash(), &types.Body{Transactions: chain[2].Transactions()}); err != nil {
+		t.Fatal(err)
+	}
+	result, err = blockchain.VerifyBlock(block.NumberU64(), true)
+	if err != nil {
+		t.Fatalf("verification failed: %v", err)
+	}
+	if len(result.Problems) == 0 {
+		t.Error("swapped body went unnoticed")
+	}
+
+	if _, err := blockchain.VerifyBlock(4, false); err == nil {
+		t.Error("expected error verifying unknown block")
+	}
+}
@@ -17,9 +17,8 @@
 package vm
 
 import (
-	"math/big"
-
 	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/common/uint256"
 )
 
 // destinations stores one map per contract (keyed by hash of code).
@@ -28,11 +27,11 @@ import (
 type destinations map[common.Hash][]byte
 
 // has checks whether code has a JUMPDEST at dest.
-func (d destinations) has(codehash common.Hash, code []byte, dest *big.Int) bool {
+func (d destinations) has(codehash common.Hash, code []byte, dest *uint256.Int) bool {
 	// PC cannot go beyond len(code) and certainly can't be bigger than 63bits.
 	// Don't bother checking for JUMPDEST in that case.
 	udest := dest.Uint64()
-	if dest.BitLen() >= 63 || udest >= uint64(len(code)) {
+	if !dest.IsUint64() || udest >= uint64(len(code)) {
 		return false
 	}
 
@@ -20,6 +20,7 @@ import (
 	"math/big"
 
 	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/common/uint256"
 )
 
 // Type is the VM type accepted by **NewVm**
@@ -41,16 +42,16 @@ var (
 )
 
 // calculates the memory size required for a step
-func calcMemSize(off, l *big.Int) *big.Int {
-	if l.Sign() == 0 {
+func calcMemSize(off, l *uint256.Int) *big.Int {
+	if l.IsZero() {
 		return new(big.Int)
 	}
 
-	return new(big.Int).Add(off, l)
+	return new(big.Int).Add(off.ToBig(), l.ToBig())
 }
 
 // calculates the quadratic gas
-func quadMemGas(mem *Memory, newMemSize, gas *big.Int) {
+func quadMemGas(gasTable *GasTable, mem *Memory, newMemSize, gas *big.Int) {
 	if newMemSize.Sign() > 0 {
 		newMemSizeWords := toWordSize(newMemSize)
 		newMemSize.Mul(newMemSizeWords, u256(32))
@@ -60,13 +61,13 @@ func quadMemGas(mem *Memory, newMemSize, gas *big.Int) {
 			// The order has been optimised to reduce allocation
 			oldSize := toWordSize(big.NewInt(int64(mem.Len())))
 			pow := new(big.Int).Exp(oldSize, common.Big2, new(big.Int))
-			linCoef := oldSize.Mul(oldSize, big.NewInt(3))
-			quadCoef := new(big.Int).Div(pow, big.NewInt(512))
+			linCoef := oldSize.Mul(oldSize, gasTable.Memory)
+			quadCoef := new(big.Int).Div(pow, gasTable.QuadCoeffDiv)
 			oldTotalFee := new(big.Int).Add(linCoef, quadCoef)
 
 			pow.Exp(newMemSizeWords, common.Big2, new(big.Int))
-			linCoef = linCoef.Mul(newMemSizeWords, big.NewInt(3))
-			quadCoef = quadCoef.Div(pow, big.NewInt(512))
+			linCoef = linCoef.Mul(newMemSizeWords, gasTable.Memory)
+			quadCoef = quadCoef.Div(pow, gasTable.QuadCoeffDiv)
 			newTotalFee := linCoef.Add(linCoef, quadCoef)
 
 			fee := newTotalFee.Sub(newTotalFee, oldTotalFee)
@@ -82,12 +83,37 @@ func u256(n int64) *big.Int {
 
 // getData returns a slice from the data based on the start and size and pads
 // up to size with zero's. This function is overflow safe.
-func getData(data []byte, start, size *big.Int) []byte {
-	dlen := big.NewInt(int64(len(data)))
+func getData(data []byte, start, size uint64) []byte {
+	dlen := uint64(len(data))
 
-	s := common.BigMin(start, dlen)
-	e := common.BigMin(new(big.Int).Add(s, size), dlen)
-	return common.RightPadBytes(data[s.Uint64():e.Uint64()], int(size.Uint64()))
+	s, e := start, start+size
+	if s > dlen {
+		s = dlen
+	}
+	if e > dlen || e < start {
+		e = dlen
+	}
+	return common.RightPadBytes(data[s:e], int(size))
+}
+
+// clampUint64 returns x as an uint64, or the largest uint64 if it doesn't
+// fit in one.
+func clampUint64(x *uint256.Int) uint64 {
+	if !x.IsUint64() {
+		return ^uint64(0)
+	}
+	return x.Uint64()
+}
+
+// intToAddress returns the address held in the lowest 20 bytes of x.
+func intToAddress(x *uint256.Int) common.Address {
+	b := x.Bytes32()
+	return common.BytesToAddress(b[12:])
+}
+
+// intToHash returns x as a 32 byte hash.
+func intToHash(x *uint256.Int) common.Hash {
+	return common.Hash(x.Bytes32())
 }
 
 // useGas attempts to subtract the amount of gas and returns whether it was
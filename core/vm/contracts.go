@@ -17,68 +17,94 @@
 package vm
 
 import (
+	"encoding/binary"
+	"errors"
 	"math/big"
 
 	"github.com/ellaism/go-ellaism/common"
 	"github.com/ellaism/go-ellaism/crypto"
+	"github.com/ellaism/go-ellaism/crypto/blake2b"
 	"github.com/ellaism/go-ellaism/logger"
 	"github.com/ellaism/go-ellaism/logger/glog"
 )
 
+var (
+	errBlake2FInputLength = errors.New("blake2f: invalid input length")
+	errBlake2FFinalFlag   = errors.New("blake2f: invalid final block indicator flag")
+)
+
 // PrecompiledAccount represents a native ethereum contract
 type PrecompiledAccount struct {
-	Gas func(l int) *big.Int
-	fn  func(in []byte) []byte
+	Gas func(in []byte) *big.Int
+	fn  func(in []byte) ([]byte, error)
 }
 
 // Call calls the native function
-func (self PrecompiledAccount) Call(in []byte) []byte {
+func (self PrecompiledAccount) Call(in []byte) ([]byte, error) {
 	return self.fn(in)
 }
 
 // Precompiled contains the default set of ethereum contracts
 var Precompiled = PrecompiledContracts()
 
+// PrecompiledEIP152 contains the BLAKE2 compression function contract of
+// EIP-152, available once the eip152 fork feature is enabled.
+var PrecompiledEIP152 = map[string]*PrecompiledAccount{
+	string(common.LeftPadBytes([]byte{9}, 20)): {blake2FGas, blake2FFunc},
+}
+
+// ActivePrecompiled returns the precompiled contract at the given address
+// enabled at the given block, or nil if there is none.
+func ActivePrecompiled(ruleset RuleSet, num *big.Int, addr common.Address) *PrecompiledAccount {
+	if p := Precompiled[addr.Str()]; p != nil {
+		return p
+	}
+	if ruleset.IsEIP152(num) {
+		return PrecompiledEIP152[addr.Str()]
+	}
+	return nil
+}
+
 // PrecompiledContracts returns the default set of precompiled ethereum
 // contracts defined by the ethereum yellow paper.
 func PrecompiledContracts() map[string]*PrecompiledAccount {
 	return map[string]*PrecompiledAccount{
 		// ECRECOVER
-		string(common.LeftPadBytes([]byte{1}, 20)): {func(l int) *big.Int {
+		string(common.LeftPadBytes([]byte{1}, 20)): {func(in []byte) *big.Int {
 			return big.NewInt(3000)
 		}, ecrecoverFunc},
 
 		// SHA256
-		string(common.LeftPadBytes([]byte{2}, 20)): {func(l int) *big.Int {
-			n := big.NewInt(int64(l+31) / 32)
+		string(common.LeftPadBytes([]byte{2}, 20)): {func(in []byte) *big.Int {
+			n := big.NewInt(int64(len(in)+31) / 32)
 			n.Mul(n, big.NewInt(12))
 			return n.Add(n, big.NewInt(60))
 		}, sha256Func},
 
 		// RIPEMD160
-		string(common.LeftPadBytes([]byte{3}, 20)): {func(l int) *big.Int {
-			n := big.NewInt(int64(l+31) / 32)
+		string(common.LeftPadBytes([]byte{3}, 20)): {func(in []byte) *big.Int {
+			n := big.NewInt(int64(len(in)+31) / 32)
 			n.Mul(n, big.NewInt(120))
 			return n.Add(n, big.NewInt(600))
 		}, ripemd160Func},
 
-		string(common.LeftPadBytes([]byte{4}, 20)): {func(l int) *big.Int {
-			n := big.NewInt(int64(l+31) / 32)
+		string(common.LeftPadBytes([]byte{4}, 20)): {func(in []byte) *big.Int {
+			n := big.NewInt(int64(len(in)+31) / 32)
 			n.Mul(n, big.NewInt(3))
 			return n.Add(n, big.NewInt(15))
 		}, memCpy},
 	}
 }
 
-func sha256Func(in []byte) []byte {
-	return crypto.Sha256(in)
+func sha256Func(in []byte) ([]byte, error) {
+	return crypto.Sha256(in), nil
 }
 
-func ripemd160Func(in []byte) []byte {
-	return common.LeftPadBytes(crypto.Ripemd160(in), 32)
+func ripemd160Func(in []byte) ([]byte, error) {
+	return common.LeftPadBytes(crypto.Ripemd160(in), 32), nil
 }
 
-func ecrecoverFunc(in []byte) []byte {
+func ecrecoverFunc(in []byte) ([]byte, error) {
 	in = common.RightPadBytes(in, 128)
 	// "in" is (hash, v, r, s), each 32 bytes
 	// but for ecrecover we want (r, s, v)
@@ -92,7 +118,7 @@ func ecrecoverFunc(in []byte) []byte {
 	// tighter sig s values in homestead only apply to tx sigs
 	if !crypto.ValidateSignatureValues(v, r, s, false) {
 		glog.V(logger.Detail).Infof("ECRECOVER error: v, r or s value invalid")
-		return nil
+		return nil, nil
 	}
 
 	// v needs to be at the end and normalized for libsecp256k1
@@ -103,13 +129,58 @@ func ecrecoverFunc(in []byte) []byte {
 	// make sure the public key is a valid one
 	if err != nil {
 		glog.V(logger.Detail).Infoln("ECRECOVER error: ", err)
-		return nil
+		return nil, nil
 	}
 
 	// the first byte of pubkey is bitcoin heritage
-	return common.LeftPadBytes(crypto.Keccak256(pubKey[1:])[12:], 32)
+	return common.LeftPadBytes(crypto.Keccak256(pubKey[1:])[12:], 32), nil
 }
 
-func memCpy(in []byte) []byte {
-	return in
+func memCpy(in []byte) ([]byte, error) {
+	return in, nil
+}
+
+// blake2FInputLength is the length of the input of the BLAKE2 compression
+// function contract: the rounds, state, message, offset counters and final
+// block flag.
+const blake2FInputLength = 213
+
+// blake2FGas charges a gas per round of the compression function.
+func blake2FGas(in []byte) *big.Int {
+	if len(in) != blake2FInputLength {
+		return new(big.Int)
+	}
+	return new(big.Int).SetUint64(uint64(binary.BigEndian.Uint32(in[0:4])))
+}
+
+func blake2FFunc(in []byte) ([]byte, error) {
+	if len(in) != blake2FInputLength {
+		return nil, errBlake2FInputLength
+	}
+	if in[212] != 0 && in[212] != 1 {
+		return nil, errBlake2FFinalFlag
+	}
+	var (
+		rounds = binary.BigEndian.Uint32(in[0:4])
+		final  = in[212] == 1
+		h      [8]uint64
+		m      [16]uint64
+		t      [2]uint64
+	)
+	for i := range h {
+		h[i] = binary.LittleEndian.Uint64(in[4+i*8:])
+	}
+	for i := range m {
+		m[i] = binary.LittleEndian.Uint64(in[68+i*8:])
+	}
+	t[0] = binary.LittleEndian.Uint64(in[196:])
+	t[1] = binary.LittleEndian.Uint64(in[204:])
+
+	blake2b.F(&h, m, t, final, rounds)
+
+	out := make([]byte, 64)
+	for i := range h {
+		binary.LittleEndian.PutUint64(out[i*8:], h[i])
+	}
+	return out, nil
 }
@@ -29,6 +29,35 @@ type RuleSet interface {
 	// GasTable returns the gas prices for this phase, which is based on
 	// block number passed in.
 	GasTable(*big.Int) *GasTable
+	// IsEIP161 returns whether empty accounts are cleared from the state
+	// (EIP-161) at the given block.
+	IsEIP161(*big.Int) bool
+	// MaxCodeSize returns the maximum size of the code of a contract
+	// created at the given block (EIP-170), zero meaning unlimited.
+	MaxCodeSize(*big.Int) int
+	// IsEIP1014 returns whether the CREATE2 opcode is available at the
+	// given block.
+	IsEIP1014(*big.Int) bool
+	// IsEIP145 returns whether the SHL, SHR and SAR opcodes are available
+	// at the given block.
+	IsEIP145(*big.Int) bool
+	// IsEIP1052 returns whether the EXTCODEHASH opcode is available at the
+	// given block.
+	IsEIP1052(*big.Int) bool
+	// IsEIP1344 returns whether the CHAINID opcode is available at the
+	// given block.
+	IsEIP1344(*big.Int) bool
+	// IsEIP1884 returns whether the SELFBALANCE opcode is available at the
+	// given block.
+	IsEIP1884(*big.Int) bool
+	// IsEIP140 returns whether the REVERT opcode is available at the given
+	// block.
+	IsEIP140(*big.Int) bool
+	// IsEIP152 returns whether the BLAKE2 compression function contract is
+	// available at the given block.
+	IsEIP152(*big.Int) bool
+	// ChainID returns the chain id used for replay protection.
+	ChainID() *big.Int
 }
 
 // Environment is an EVM requirement and helper which allows access to outside
@@ -76,6 +105,12 @@ type Environment interface {
 	DelegateCall(me ContractRef, addr common.Address, data []byte, gas, price *big.Int) ([]byte, error)
 	// Create a new contract
 	Create(me ContractRef, data []byte, gas, price, value *big.Int) ([]byte, common.Address, error)
+	// Create a new contract at an address derived from the salt and code (EIP-1014)
+	Create2(me ContractRef, data []byte, gas, price, value, salt *big.Int) ([]byte, common.Address, error)
+	// Tracer collecting execution traces, nil if tracing is disabled
+	Tracer() Tracer
+	// OpcodeMeter measuring the executed opcodes, nil if metering is disabled
+	OpcodeMeter() OpcodeMeter
 }
 
 // Vm is the basic interface for an implementation of the EVM.
@@ -107,6 +142,9 @@ type Database interface {
 
 	GetState(common.Address, common.Hash) common.Hash
 	SetState(common.Address, common.Hash, common.Hash)
+	// GetCommittedState returns the value of a storage slot as of the last
+	// state update, ignoring the changes of the current transaction.
+	GetCommittedState(common.Address, common.Hash) common.Hash
 
 	Suicide(common.Address) bool
 	HasSuicided(common.Address) bool
@@ -114,6 +152,9 @@ type Database interface {
 	// Exist reports whether the given account exists in state.
 	// Notably this should also return true for suicided accounts.
 	Exist(common.Address) bool
+	// Empty reports whether the given account does not exist or is empty
+	// according to EIP-161.
+	Empty(common.Address) bool
 }
 
 // Account represents a contract or basic ethereum account.
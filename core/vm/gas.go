@@ -17,7 +17,6 @@
 package vm
 
 import (
-	"fmt"
 	"math/big"
 	"reflect"
 )
@@ -37,24 +36,60 @@ var (
 
 	GasContractByte = big.NewInt(200)
 
-	n64 = big.NewInt(64)
+	big0 = big.NewInt(0)
+	n64  = big.NewInt(64)
 )
 
+// GasTable holds the gas costs of the operations, any of which may be repriced
+// by a hard fork. The JSON keys name the costs in the options of the gastable
+// fork feature.
 type GasTable struct {
-	ExtcodeSize *big.Int
-	ExtcodeCopy *big.Int
-	Balance     *big.Int
-	SLoad       *big.Int
-	Calls       *big.Int
-	Suicide     *big.Int
-	ExpByte     *big.Int
+	// Step costs of the operations charged a fixed cost, by tier
+	QuickStep   *big.Int `json:"quickStep"`
+	FastestStep *big.Int `json:"fastestStep"`
+	FastStep    *big.Int `json:"fastStep"`
+	MidStep     *big.Int `json:"midStep"`
+	SlowStep    *big.Int `json:"slowStep"`
+	ExtStep     *big.Int `json:"extStep"`
+
+	ExtcodeSize *big.Int `json:"extcodeSize"`
+	ExtcodeCopy *big.Int `json:"extcodeCopy"`
+	ExtcodeHash *big.Int `json:"extcodeHash"`
+	Balance     *big.Int `json:"balance"`
+	SLoad       *big.Int `json:"sload"`
+	Calls       *big.Int `json:"calls"`
+	Suicide     *big.Int `json:"suicide"`
+	ExpByte     *big.Int `json:"expByte"`
 
 	// CreateBySuicide occurs when the
 	// refunded account is one that does
 	// not exist. This logic is similar
 	// to call. May be left nil. Nil means
 	// not charged.
-	CreateBySuicide *big.Int
+	CreateBySuicide *big.Int `json:"createBySuicide"`
+
+	SStoreSet     *big.Int `json:"sstoreSet"`     // SSTORE of a non-zero value into an empty slot
+	SStoreReset   *big.Int `json:"sstoreReset"`   // Any other SSTORE
+	SStoreRefund  *big.Int `json:"sstoreRefund"`  // Refund for clearing a slot
+	SuicideRefund *big.Int `json:"suicideRefund"` // Refund for the first suicide of a contract
+
+	Sha3         *big.Int `json:"sha3"`
+	Sha3Word     *big.Int `json:"sha3Word"` // Per word hashed
+	Copy         *big.Int `json:"copy"`     // Per word copied by CALLDATACOPY, CODECOPY and EXTCODECOPY
+	Log          *big.Int `json:"log"`
+	LogTopic     *big.Int `json:"logTopic"`     // Per topic
+	LogData      *big.Int `json:"logData"`      // Per byte logged
+	Memory       *big.Int `json:"memory"`       // Per word of memory
+	QuadCoeffDiv *big.Int `json:"quadCoeffDiv"` // Divisor of the quadratic memory cost
+	JumpDest     *big.Int `json:"jumpDest"`
+
+	Create            *big.Int `json:"create"`
+	CreateData        *big.Int `json:"createData"`        // Per byte of code deployed
+	CallValueTransfer *big.Int `json:"callValueTransfer"` // Paid for a CALL or CALLCODE transferring value
+	CallNewAccount    *big.Int `json:"callNewAccount"`    // Paid for a CALL creating an account
+	CallStipend       *big.Int `json:"callStipend"`       // Free gas given to a call transferring value
+
+	TxDataNonZero *big.Int `json:"txDataNonZero"` // Per non-zero byte of transaction data
 }
 
 // calcGas returns the actual gas cost of the call.
@@ -80,33 +115,6 @@ func (g *GasTable) IsEmpty() bool {
 	return reflect.DeepEqual(g, GasTable{})
 }
 
-// baseCheck checks for any stack error underflows
-func baseCheck(op OpCode, stack *stack, gas *big.Int) error {
-	// PUSH and DUP are a bit special. They all cost the same but we do want to have checking on stack push limit
-	// PUSH is also allowed to calculate the same price for all PUSHes
-	// DUP requirements are handled elsewhere (except for the stack limit check)
-	if op >= PUSH1 && op <= PUSH32 {
-		op = PUSH1
-	}
-	if op >= DUP1 && op <= DUP16 {
-		op = DUP1
-	}
-
-	if r, ok := _baseCheck[op]; ok {
-		err := stack.require(r.stackPop)
-		if err != nil {
-			return err
-		}
-
-		if r.stackPush > 0 && stack.len()-r.stackPop+r.stackPush > stackLimit {
-			return fmt.Errorf("stack length %d exceed limit %d", stack.len(), stackLimit)
-		}
-
-		gas.Add(gas, r.gas)
-	}
-	return nil
-}
-
 // casts a arbitrary number to the amount of words (sets of 32 bytes)
 func toWordSize(size *big.Int) *big.Int {
 	tmp := new(big.Int)
@@ -114,76 +122,3 @@ func toWordSize(size *big.Int) *big.Int {
 	tmp.Div(tmp, u256(32))
 	return tmp
 }
-
-type req struct {
-	stackPop  int
-	gas       *big.Int
-	stackPush int
-}
-
-var _baseCheck = map[OpCode]req{
-	// opcode  |  stack pop | gas price | stack push
-	ADD:          {2, GasFastestStep, 1},
-	LT:           {2, GasFastestStep, 1},
-	GT:           {2, GasFastestStep, 1},
-	SLT:          {2, GasFastestStep, 1},
-	SGT:          {2, GasFastestStep, 1},
-	EQ:           {2, GasFastestStep, 1},
-	ISZERO:       {1, GasFastestStep, 1},
-	SUB:          {2, GasFastestStep, 1},
-	AND:          {2, GasFastestStep, 1},
-	OR:           {2, GasFastestStep, 1},
-	XOR:          {2, GasFastestStep, 1},
-	NOT:          {1, GasFastestStep, 1},
-	BYTE:         {2, GasFastestStep, 1},
-	CALLDATALOAD: {1, GasFastestStep, 1},
-	CALLDATACOPY: {3, GasFastestStep, 1},
-	MLOAD:        {1, GasFastestStep, 1},
-	MSTORE:       {2, GasFastestStep, 0},
-	MSTORE8:      {2, GasFastestStep, 0},
-	CODECOPY:     {3, GasFastestStep, 0},
-	MUL:          {2, GasFastStep, 1},
-	DIV:          {2, GasFastStep, 1},
-	SDIV:         {2, GasFastStep, 1},
-	MOD:          {2, GasFastStep, 1},
-	SMOD:         {2, GasFastStep, 1},
-	SIGNEXTEND:   {2, GasFastStep, 1},
-	ADDMOD:       {3, GasMidStep, 1},
-	MULMOD:       {3, GasMidStep, 1},
-	JUMP:         {1, GasMidStep, 0},
-	JUMPI:        {2, GasSlowStep, 0},
-	EXP:          {2, GasSlowStep, 1},
-	ADDRESS:      {0, GasQuickStep, 1},
-	ORIGIN:       {0, GasQuickStep, 1},
-	CALLER:       {0, GasQuickStep, 1},
-	CALLVALUE:    {0, GasQuickStep, 1},
-	CODESIZE:     {0, GasQuickStep, 1},
-	GASPRICE:     {0, GasQuickStep, 1},
-	COINBASE:     {0, GasQuickStep, 1},
-	TIMESTAMP:    {0, GasQuickStep, 1},
-	NUMBER:       {0, GasQuickStep, 1},
-	CALLDATASIZE: {0, GasQuickStep, 1},
-	DIFFICULTY:   {0, GasQuickStep, 1},
-	GASLIMIT:     {0, GasQuickStep, 1},
-	POP:          {1, GasQuickStep, 0},
-	PC:           {0, GasQuickStep, 1},
-	MSIZE:        {0, GasQuickStep, 1},
-	GAS:          {0, GasQuickStep, 1},
-	BLOCKHASH:    {1, GasExtStep, 1},
-	BALANCE:      {1, new(big.Int), 1},
-	EXTCODESIZE:  {1, new(big.Int), 1},
-	EXTCODECOPY:  {4, new(big.Int), 0},
-	SLOAD:        {1, big.NewInt(50), 1},
-	SSTORE:       {2, new(big.Int), 0},
-	SHA3:         {2, big.NewInt(30), 1},
-	CREATE:       {3, big.NewInt(32000), 1},
-	// Zero is calculated in the gasSwitch
-	CALL:         {7, new(big.Int), 1},
-	CALLCODE:     {7, new(big.Int), 1},
-	DELEGATECALL: {6, new(big.Int), 1},
-	SUICIDE:      {1, new(big.Int), 0},
-	JUMPDEST:     {0, big.NewInt(1), 0},
-	RETURN:       {2, new(big.Int), 0},
-	PUSH1:        {0, GasFastestStep, 1},
-	DUP1:         {0, new(big.Int), 1},
-}
@@ -0,0 +1,202 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"math/big"
+)
+
+// stepGasFunc returns the constant gas of an operation from the gas table.
+type stepGasFunc func(gt *GasTable) *big.Int
+
+var (
+	zeroStep    stepGasFunc = func(gt *GasTable) *big.Int { return big0 }
+	quickStep   stepGasFunc = func(gt *GasTable) *big.Int { return gt.QuickStep }
+	fastestStep stepGasFunc = func(gt *GasTable) *big.Int { return gt.FastestStep }
+	fastStep    stepGasFunc = func(gt *GasTable) *big.Int { return gt.FastStep }
+	midStep     stepGasFunc = func(gt *GasTable) *big.Int { return gt.MidStep }
+	slowStep    stepGasFunc = func(gt *GasTable) *big.Int { return gt.SlowStep }
+	extStep     stepGasFunc = func(gt *GasTable) *big.Int { return gt.ExtStep }
+)
+
+// gasFunc returns the gas an operation costs on top of its constant gas,
+// given the memory size it requires. Any memory expansion is charged here.
+type gasFunc func(gt *GasTable, env Environment, contract *Contract, stack *stack, mem *Memory, memorySize *big.Int) (*big.Int, error)
+
+// constGasFunc returns a gasFunc charging a cost of the gas table.
+func constGasFunc(cost func(gt *GasTable) *big.Int) gasFunc {
+	return func(gt *GasTable, env Environment, contract *Contract, stack *stack, mem *Memory, memorySize *big.Int) (*big.Int, error) {
+		return cost(gt), nil
+	}
+}
+
+var (
+	gasExtCodeSize = constGasFunc(func(gt *GasTable) *big.Int { return gt.ExtcodeSize })
+	gasExtCodeHash = constGasFunc(func(gt *GasTable) *big.Int { return gt.ExtcodeHash })
+	gasBalance     = constGasFunc(func(gt *GasTable) *big.Int { return gt.Balance })
+	gasSLoad       = constGasFunc(func(gt *GasTable) *big.Int { return gt.SLoad })
+	gasJumpDest    = constGasFunc(func(gt *GasTable) *big.Int { return gt.JumpDest })
+)
+
+// gasMemory charges the memory expansion of MLOAD, MSTORE, MSTORE8 and RETURN.
+func gasMemory(gt *GasTable, env Environment, contract *Contract, stack *stack, mem *Memory, memorySize *big.Int) (*big.Int, error) {
+	gas := new(big.Int)
+	quadMemGas(gt, mem, memorySize, gas)
+	return gas, nil
+}
+
+func gasExp(gt *GasTable, env Environment, contract *Contract, stack *stack, mem *Memory, memorySize *big.Int) (*big.Int, error) {
+	expByteLen := int64(stack.back(1).ByteLen())
+	return new(big.Int).Mul(big.NewInt(expByteLen), gt.ExpByte), nil
+}
+
+func gasSha3(gt *GasTable, env Environment, contract *Contract, stack *stack, mem *Memory, memorySize *big.Int) (*big.Int, error) {
+	gas := new(big.Int).Set(gt.Sha3)
+	words := toWordSize(stack.back(1).ToBig())
+	gas.Add(gas, words.Mul(words, gt.Sha3Word))
+
+	quadMemGas(gt, mem, memorySize, gas)
+	return gas, nil
+}
+
+// gasCopy charges CALLDATACOPY and CODECOPY.
+func gasCopy(gt *GasTable, env Environment, contract *Contract, stack *stack, mem *Memory, memorySize *big.Int) (*big.Int, error) {
+	words := toWordSize(stack.back(2).ToBig())
+	gas := words.Mul(words, gt.Copy)
+
+	quadMemGas(gt, mem, memorySize, gas)
+	return gas, nil
+}
+
+func gasExtCodeCopy(gt *GasTable, env Environment, contract *Contract, stack *stack, mem *Memory, memorySize *big.Int) (*big.Int, error) {
+	gas := new(big.Int).Set(gt.ExtcodeCopy)
+	words := toWordSize(stack.back(3).ToBig())
+	gas.Add(gas, words.Mul(words, gt.Copy))
+
+	quadMemGas(gt, mem, memorySize, gas)
+	return gas, nil
+}
+
+func makeGasLog(n int64) gasFunc {
+	return func(gt *GasTable, env Environment, contract *Contract, stack *stack, mem *Memory, memorySize *big.Int) (*big.Int, error) {
+		mSize := stack.back(1)
+
+		// log gas
+		gas := new(big.Int).Set(gt.Log)
+		// log topic gass
+		gas.Add(gas, new(big.Int).Mul(big.NewInt(n), gt.LogTopic))
+		// log data gass
+		gas.Add(gas, new(big.Int).Mul(mSize.ToBig(), gt.LogData))
+
+		quadMemGas(gt, mem, memorySize, gas)
+		return gas, nil
+	}
+}
+
+func gasCreate(gt *GasTable, env Environment, contract *Contract, stack *stack, mem *Memory, memorySize *big.Int) (*big.Int, error) {
+	gas := new(big.Int).Set(gt.Create)
+
+	quadMemGas(gt, mem, memorySize, gas)
+	return gas, nil
+}
+
+func gasCreate2(gt *GasTable, env Environment, contract *Contract, stack *stack, mem *Memory, memorySize *big.Int) (*big.Int, error) {
+	gas := new(big.Int).Set(gt.Create)
+
+	// the creation code is hashed to derive the address
+	words := toWordSize(stack.back(2).ToBig())
+	gas.Add(gas, words.Mul(words, gt.Sha3Word))
+
+	quadMemGas(gt, mem, memorySize, gas)
+	return gas, nil
+}
+
+// makeGasCall returns the gas function of CALL and CALLCODE. Before EIP-161
+// a CALL is charged for creating its target whenever it doesn't exist,
+// afterwards only when value is sent to an empty account.
+func makeGasCall(op OpCode, eip161 bool) gasFunc {
+	return func(gt *GasTable, env Environment, contract *Contract, stack *stack, mem *Memory, memorySize *big.Int) (*big.Int, error) {
+		gas := new(big.Int).Set(gt.Calls)
+
+		transfersValue := !stack.back(2).IsZero()
+		if op == CALL {
+			address := intToAddress(stack.back(1))
+			if eip161 {
+				if transfersValue && env.Db().Empty(address) {
+					gas.Add(gas, gt.CallNewAccount)
+				}
+			} else if !env.Db().Exist(address) {
+				gas.Add(gas, gt.CallNewAccount)
+			}
+		}
+		if transfersValue {
+			gas.Add(gas, gt.CallValueTransfer)
+		}
+		quadMemGas(gt, mem, memorySize, gas)
+
+		cg := callGas(gt, contract.Gas, gas, stack.back(0).ToBig())
+		// Replace the stack item with the new gas calculation. This means that
+		// either the original item is left on the stack or the item is replaced by:
+		// (availableGas - gas) * 63 / 64
+		// We replace the stack item so that it's available when the opCall instruction is
+		// called. This information is otherwise lost due to the dependency on *current*
+		// available gas.
+		stack.back(0).SetFromBig(cg)
+		return gas.Add(gas, cg), nil
+	}
+}
+
+func gasDelegateCall(gt *GasTable, env Environment, contract *Contract, stack *stack, mem *Memory, memorySize *big.Int) (*big.Int, error) {
+	gas := new(big.Int).Set(gt.Calls)
+
+	quadMemGas(gt, mem, memorySize, gas)
+
+	cg := callGas(gt, contract.Gas, gas, stack.back(0).ToBig())
+	// Replace the stack item with the new gas calculation. This means that
+	// either the original item is left on the stack or the item is replaced by:
+	// (availableGas - gas) * 63 / 64
+	// We replace the stack item so that it's available when the opCall instruction is
+	// called.
+	stack.back(0).SetFromBig(cg)
+	return gas.Add(gas, cg), nil
+}
+
+// makeGasSuicide returns the gas function of SUICIDE. Before EIP-161 the
+// creation of the beneficiary is charged whenever it doesn't exist,
+// afterwards only when funds are sent to an empty account.
+func makeGasSuicide(eip161 bool) gasFunc {
+	return func(gt *GasTable, env Environment, contract *Contract, stack *stack, mem *Memory, memorySize *big.Int) (*big.Int, error) {
+		gas := new(big.Int)
+		// if suicide is not nil: homestead gas fork
+		if gt.CreateBySuicide != nil {
+			gas.Set(gt.Suicide)
+			address := intToAddress(stack.peek())
+			if eip161 {
+				if env.Db().Empty(address) && env.Db().GetBalance(contract.Address()).Sign() != 0 {
+					gas.Add(gas, gt.CreateBySuicide)
+				}
+			} else if !env.Db().Exist(address) {
+				gas.Add(gas, gt.CreateBySuicide)
+			}
+		}
+
+		if !env.Db().HasSuicided(contract.Address()) {
+			env.Db().AddRefund(gt.SuicideRefund)
+		}
+		return gas, nil
+	}
+}
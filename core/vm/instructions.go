@@ -17,402 +17,463 @@
 package vm
 
 import (
+	"fmt"
 	"math/big"
 
 	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/common/uint256"
 	"github.com/ellaism/go-ellaism/crypto"
 )
 
-var callStipend = big.NewInt(2300) // Free gas given at beginning of call.
-
-type instrFn func(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack)
-
-type instruction struct {
-	op   OpCode
-	pc   uint64
-	fn   instrFn
-	data *big.Int
-
-	gas   *big.Int
-	spop  int
-	spush int
-
-	returns bool
+func opAdd(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
+	x, y := stack.pop(), stack.peek()
+	y.Add(&x, y)
+	return nil, nil
 }
 
-func (instr instruction) halts() bool {
-	return instr.returns
+func opSub(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
+	x, y := stack.pop(), stack.peek()
+	y.Sub(&x, y)
+	return nil, nil
 }
 
-func (instr instruction) Op() OpCode {
-	return instr.op
+func opMul(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
+	x, y := stack.pop(), stack.peek()
+	y.Mul(&x, y)
+	return nil, nil
 }
 
-func opAdd(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	x, y := stack.pop(), stack.pop()
-	stack.push(U256(x.Add(x, y)))
+func opDiv(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
+	x, y := stack.pop(), stack.peek()
+	y.Div(&x, y)
+	return nil, nil
 }
 
-func opSub(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	x, y := stack.pop(), stack.pop()
-	stack.push(U256(x.Sub(x, y)))
+func opSdiv(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
+	x, y := stack.pop(), stack.peek()
+	y.SDiv(&x, y)
+	return nil, nil
 }
 
-func opMul(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	x, y := stack.pop(), stack.pop()
-	stack.push(U256(x.Mul(x, y)))
+func opMod(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
+	x, y := stack.pop(), stack.peek()
+	y.Mod(&x, y)
+	return nil, nil
 }
 
-func opDiv(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	x, y := stack.pop(), stack.pop()
-	if y.Sign() != 0 {
-		stack.push(U256(x.Div(x, y)))
-	} else {
-		stack.push(new(big.Int))
-	}
+func opSmod(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
+	x, y := stack.pop(), stack.peek()
+	y.SMod(&x, y)
+	return nil, nil
 }
 
-func opSdiv(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	x, y := S256(stack.pop()), S256(stack.pop())
-	if y.Sign() == 0 {
-		stack.push(new(big.Int))
-		return
-	} else {
-		n := new(big.Int)
-		if new(big.Int).Mul(x, y).Sign() < 0 {
-			n.SetInt64(-1)
-		} else {
-			n.SetInt64(1)
-		}
-
-		res := x.Div(x.Abs(x), y.Abs(y))
-		res.Mul(res, n)
-
-		stack.push(U256(res))
-	}
+func opExp(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
+	base, exponent := stack.pop(), stack.peek()
+	exponent.Exp(&base, exponent)
+	return nil, nil
 }
 
-func opMod(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	x, y := stack.pop(), stack.pop()
-	if y.Sign() == 0 {
-		stack.push(new(big.Int))
-	} else {
-		stack.push(U256(x.Mod(x, y)))
-	}
+func opSignExtend(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
+	back, num := stack.pop(), stack.peek()
+	num.SignExtend(&back, num)
+	return nil, nil
 }
 
-func opSmod(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	x, y := S256(stack.pop()), S256(stack.pop())
+func opNot(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
+	x := stack.peek()
+	x.Not(x)
+	return nil, nil
+}
 
-	if y.Sign() == 0 {
-		stack.push(new(big.Int))
+func opLt(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
+	x, y := stack.pop(), stack.peek()
+	if x.Lt(y) {
+		y.SetOne()
 	} else {
-		n := new(big.Int)
-		if x.Sign() < 0 {
-			n.SetInt64(-1)
-		} else {
-			n.SetInt64(1)
-		}
-
-		res := x.Mod(x.Abs(x), y.Abs(y))
-		res.Mul(res, n)
-
-		stack.push(U256(res))
+		y.Clear()
 	}
+	return nil, nil
 }
 
-func opExp(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	x, y := stack.pop(), stack.pop()
-	stack.push(U256(x.Exp(x, y, Pow256)))
-}
-
-func opSignExtend(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	back := stack.pop()
-	if back.Cmp(big.NewInt(31)) < 0 {
-		bit := uint(back.Uint64()*8 + 7)
-		num := stack.pop()
-		mask := back.Lsh(common.Big1, bit)
-		mask.Sub(mask, common.Big1)
-		if common.BitTest(num, int(bit)) {
-			num.Or(num, mask.Not(mask))
-		} else {
-			num.And(num, mask)
-		}
-
-		stack.push(U256(num))
+func opGt(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
+	x, y := stack.pop(), stack.peek()
+	if x.Gt(y) {
+		y.SetOne()
+	} else {
+		y.Clear()
 	}
+	return nil, nil
 }
 
-func opNot(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	x := stack.pop()
-	stack.push(U256(x.Not(x)))
-}
-
-func opLt(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	x, y := stack.pop(), stack.pop()
-	if x.Cmp(y) < 0 {
-		stack.push(big.NewInt(1))
+func opSlt(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
+	x, y := stack.pop(), stack.peek()
+	if x.Slt(y) {
+		y.SetOne()
 	} else {
-		stack.push(new(big.Int))
+		y.Clear()
 	}
+	return nil, nil
 }
 
-func opGt(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	x, y := stack.pop(), stack.pop()
-	if x.Cmp(y) > 0 {
-		stack.push(big.NewInt(1))
+func opSgt(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
+	x, y := stack.pop(), stack.peek()
+	if x.Sgt(y) {
+		y.SetOne()
 	} else {
-		stack.push(new(big.Int))
+		y.Clear()
 	}
+	return nil, nil
 }
 
-func opSlt(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	x, y := S256(stack.pop()), S256(stack.pop())
-	if x.Cmp(S256(y)) < 0 {
-		stack.push(big.NewInt(1))
+func opEq(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
+	x, y := stack.pop(), stack.peek()
+	if x.Eq(y) {
+		y.SetOne()
 	} else {
-		stack.push(new(big.Int))
+		y.Clear()
 	}
+	return nil, nil
 }
 
-func opSgt(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	x, y := S256(stack.pop()), S256(stack.pop())
-	if x.Cmp(y) > 0 {
-		stack.push(big.NewInt(1))
+func opIszero(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
+	x := stack.peek()
+	if x.IsZero() {
+		x.SetOne()
 	} else {
-		stack.push(new(big.Int))
+		x.Clear()
 	}
+	return nil, nil
 }
 
-func opEq(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	x, y := stack.pop(), stack.pop()
-	if x.Cmp(y) == 0 {
-		stack.push(big.NewInt(1))
-	} else {
-		stack.push(new(big.Int))
-	}
+func opAnd(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
+	x, y := stack.pop(), stack.peek()
+	y.And(&x, y)
+	return nil, nil
+}
+func opOr(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
+	x, y := stack.pop(), stack.peek()
+	y.Or(&x, y)
+	return nil, nil
+}
+func opXor(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
+	x, y := stack.pop(), stack.peek()
+	y.Xor(&x, y)
+	return nil, nil
+}
+func opByte(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
+	th, val := stack.pop(), stack.peek()
+	val.Byte(&th)
+	return nil, nil
 }
 
-func opIszero(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	x := stack.pop()
-	if x.Sign() != 0 {
-		stack.push(new(big.Int))
-	} else {
-		stack.push(big.NewInt(1))
+// shiftAmount returns the shift given on the stack, capped to 256 as any
+// larger shift shifts out every bit.
+func shiftAmount(shift *uint256.Int) uint {
+	if !shift.IsUint64() || shift.Uint64() > 256 {
+		return 256
 	}
+	return uint(shift.Uint64())
 }
 
-func opAnd(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	x, y := stack.pop(), stack.pop()
-	stack.push(x.And(x, y))
-}
-func opOr(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	x, y := stack.pop(), stack.pop()
-	stack.push(x.Or(x, y))
+// opSHL shifts the second stack item left by the number of bits given by the
+// first one (EIP-145).
+func opSHL(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
+	shift, value := stack.pop(), stack.peek()
+	value.Lsh(value, shiftAmount(&shift))
+	return nil, nil
 }
-func opXor(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	x, y := stack.pop(), stack.pop()
-	stack.push(x.Xor(x, y))
+
+// opSHR logically shifts the second stack item right by the number of bits
+// given by the first one (EIP-145).
+func opSHR(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
+	shift, value := stack.pop(), stack.peek()
+	value.Rsh(value, shiftAmount(&shift))
+	return nil, nil
 }
-func opByte(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	th, val := stack.pop(), stack.pop()
-	if th.Cmp(big.NewInt(32)) < 0 {
-		byte := big.NewInt(int64(common.LeftPadBytes(val.Bytes(), 32)[th.Int64()]))
-		stack.push(byte)
-	} else {
-		stack.push(new(big.Int))
-	}
+
+// opSAR arithmetically shifts the second stack item right by the number of
+// bits given by the first one, preserving its sign (EIP-145).
+func opSAR(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
+	shift, value := stack.pop(), stack.peek()
+	value.SRsh(value, shiftAmount(&shift))
+	return nil, nil
 }
-func opAddmod(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	x, y, z := stack.pop(), stack.pop(), stack.pop()
-	if z.Sign() > 0 {
-		add := x.Add(x, y)
-		add.Mod(add, z)
-		stack.push(U256(add))
-	} else {
-		stack.push(new(big.Int))
-	}
+
+func opAddmod(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
+	x, y, z := stack.pop(), stack.pop(), stack.peek()
+	z.AddMod(&x, &y, z)
+	return nil, nil
 }
-func opMulmod(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	x, y, z := stack.pop(), stack.pop(), stack.pop()
-	if z.Sign() > 0 {
-		mul := x.Mul(x, y)
-		mul.Mod(mul, z)
-		stack.push(U256(mul))
-	} else {
-		stack.push(new(big.Int))
-	}
+func opMulmod(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
+	x, y, z := stack.pop(), stack.pop(), stack.peek()
+	z.MulMod(&x, &y, z)
+	return nil, nil
 }
 
-func opSha3(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	offset, size := stack.pop(), stack.pop()
-	hash := crypto.Keccak256(memory.Get(offset.Int64(), size.Int64()))
+func opSha3(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
+	offset, size := stack.pop(), stack.peek()
+	hash := crypto.Keccak256(memory.GetPtr(int64(offset.Uint64()), int64(size.Uint64())))
 
-	stack.push(new(big.Int).SetBytes(hash))
+	size.SetBytes(hash)
+	return nil, nil
 }
 
-func opAddress(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	stack.push(new(big.Int).SetBytes(contract.Address().Bytes()))
+func opAddress(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
+	var addr uint256.Int
+	stack.push(addr.SetBytes(contract.Address().Bytes()))
+	return nil, nil
 }
 
-func opBalance(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	addr := common.BigToAddress(stack.pop())
-	balance := env.Db().GetBalance(addr)
+func opBalance(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
+	slot := stack.peek()
+	balance := env.Db().GetBalance(intToAddress(slot))
 
-	stack.push(new(big.Int).Set(balance))
+	slot.SetFromBig(balance)
+	return nil, nil
 }
 
-func opOrigin(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	stack.push(env.Origin().Big())
+func opOrigin(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
+	var origin uint256.Int
+	stack.push(origin.SetBytes(env.Origin().Bytes()))
+	return nil, nil
 }
 
-func opCaller(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	stack.push(contract.Caller().Big())
+func opCaller(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
+	var caller uint256.Int
+	stack.push(caller.SetBytes(contract.Caller().Bytes()))
+	return nil, nil
 }
 
-func opCallValue(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	stack.push(new(big.Int).Set(contract.value))
+func opCallValue(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
+	var value uint256.Int
+	stack.push(value.SetFromBig(contract.value))
+	return nil, nil
 }
 
-func opCalldataLoad(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	stack.push(new(big.Int).SetBytes(getData(contract.Input, stack.pop(), common.Big32)))
+func opCalldataLoad(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
+	offset := stack.peek()
+	offset.SetBytes(getData(contract.Input, clampUint64(offset), 32))
+	return nil, nil
 }
 
-func opCalldataSize(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	stack.push(big.NewInt(int64(len(contract.Input))))
+func opCalldataSize(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
+	var size uint256.Int
+	stack.push(size.SetUint64(uint64(len(contract.Input))))
+	return nil, nil
 }
 
-func opCalldataCopy(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
+func opCalldataCopy(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
 	var (
 		mOff = stack.pop()
 		cOff = stack.pop()
 		l    = stack.pop()
 	)
-	memory.Set(mOff.Uint64(), l.Uint64(), getData(contract.Input, cOff, l))
+	memory.Set(mOff.Uint64(), l.Uint64(), getData(contract.Input, clampUint64(&cOff), l.Uint64()))
+	return nil, nil
 }
 
-func opExtCodeSize(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	addr := common.BigToAddress(stack.pop())
-	l := big.NewInt(int64(env.Db().GetCodeSize(addr)))
-	stack.push(l)
+func opExtCodeSize(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
+	slot := stack.peek()
+	slot.SetUint64(uint64(env.Db().GetCodeSize(intToAddress(slot))))
+	return nil, nil
 }
 
-func opCodeSize(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	l := big.NewInt(int64(len(contract.Code)))
-	stack.push(l)
+// opExtCodeHash pushes the hash of the code of an account, or zero if the
+// account doesn't exist or is empty (EIP-1052).
+func opExtCodeHash(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
+	slot := stack.peek()
+	addr := intToAddress(slot)
+	if env.Db().Empty(addr) {
+		slot.Clear()
+	} else {
+		slot.SetBytes(env.Db().GetCodeHash(addr).Bytes())
+	}
+	return nil, nil
 }
 
-func opCodeCopy(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
+func opCodeSize(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
+	var l uint256.Int
+	stack.push(l.SetUint64(uint64(len(contract.Code))))
+	return nil, nil
+}
+
+func opCodeCopy(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
 	var (
 		mOff = stack.pop()
 		cOff = stack.pop()
 		l    = stack.pop()
 	)
-	codeCopy := getData(contract.Code, cOff, l)
+	codeCopy := getData(contract.Code, clampUint64(&cOff), l.Uint64())
 
 	memory.Set(mOff.Uint64(), l.Uint64(), codeCopy)
+	return nil, nil
 }
 
-func opExtCodeCopy(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
+func opExtCodeCopy(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
 	var (
-		addr = common.BigToAddress(stack.pop())
+		addr = stack.pop()
 		mOff = stack.pop()
 		cOff = stack.pop()
 		l    = stack.pop()
 	)
-	codeCopy := getData(env.Db().GetCode(addr), cOff, l)
+	codeCopy := getData(env.Db().GetCode(intToAddress(&addr)), clampUint64(&cOff), l.Uint64())
 
 	memory.Set(mOff.Uint64(), l.Uint64(), codeCopy)
+	return nil, nil
 }
 
-func opGasprice(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	stack.push(new(big.Int).Set(contract.Price))
+func opGasprice(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
+	var price uint256.Int
+	stack.push(price.SetFromBig(contract.Price))
+	return nil, nil
 }
 
-func opBlockhash(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	num := stack.pop()
+func opBlockhash(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
+	num := stack.peek()
 
-	n := new(big.Int).Sub(env.BlockNumber(), common.Big257)
-	if num.Cmp(n) > 0 && num.Cmp(env.BlockNumber()) < 0 {
-		stack.push(env.GetHash(num.Uint64()).Big())
+	// only the hashes of the 256 most recent blocks are available
+	n, current := num.Uint64(), env.BlockNumber().Uint64()
+	if num.IsUint64() && n < current && (current < 257 || n > current-257) {
+		num.SetBytes(env.GetHash(n).Bytes())
 	} else {
-		stack.push(new(big.Int))
+		num.Clear()
 	}
+	return nil, nil
 }
 
-func opCoinbase(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	stack.push(env.Coinbase().Big())
+func opCoinbase(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
+	var coinbase uint256.Int
+	stack.push(coinbase.SetBytes(env.Coinbase().Bytes()))
+	return nil, nil
 }
 
-func opTimestamp(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	stack.push(U256(new(big.Int).Set(env.Time())))
+func opTimestamp(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
+	var time uint256.Int
+	stack.push(time.SetFromBig(env.Time()))
+	return nil, nil
 }
 
-func opNumber(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	stack.push(U256(new(big.Int).Set(env.BlockNumber())))
+func opNumber(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
+	var number uint256.Int
+	stack.push(number.SetFromBig(env.BlockNumber()))
+	return nil, nil
 }
 
-func opDifficulty(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	stack.push(U256(new(big.Int).Set(env.Difficulty())))
+func opDifficulty(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
+	var difficulty uint256.Int
+	stack.push(difficulty.SetFromBig(env.Difficulty()))
+	return nil, nil
 }
 
-func opGasLimit(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	stack.push(U256(new(big.Int).Set(env.GasLimit())))
+func opGasLimit(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
+	var gasLimit uint256.Int
+	stack.push(gasLimit.SetFromBig(env.GasLimit()))
+	return nil, nil
 }
 
-func opPop(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
+// opChainID pushes the chain id used for replay protection (EIP-1344).
+func opChainID(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
+	var chainID uint256.Int
+	stack.push(chainID.SetFromBig(env.RuleSet().ChainID()))
+	return nil, nil
+}
+
+// opSelfBalance pushes the balance of the executing contract (EIP-1884).
+func opSelfBalance(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
+	var balance uint256.Int
+	stack.push(balance.SetFromBig(env.Db().GetBalance(contract.Address())))
+	return nil, nil
+}
+
+func opPop(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
 	stack.pop()
+	return nil, nil
 }
 
-func opMload(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	offset := stack.pop()
-	val := new(big.Int).SetBytes(memory.Get(offset.Int64(), 32))
-	stack.push(val)
+func opMload(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
+	offset := stack.peek()
+	offset.SetBytes(memory.GetPtr(int64(offset.Uint64()), 32))
+	return nil, nil
 }
 
-func opMstore(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
+func opMstore(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
 	// pop value of the stack
 	mStart, val := stack.pop(), stack.pop()
-	memory.Set(mStart.Uint64(), 32, common.BigToBytes(val, 256))
+	word := val.Bytes32()
+	memory.Set(mStart.Uint64(), 32, word[:])
+	return nil, nil
+}
+
+func opMstore8(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
+	off, val := stack.pop(), stack.pop()
+	memory.store[off.Uint64()] = byte(val.Uint64() & 0xff)
+	return nil, nil
+}
+
+func opSload(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
+	loc := stack.peek()
+	val := env.Db().GetState(contract.Address(), intToHash(loc))
+	loc.SetBytes(val.Bytes())
+	return nil, nil
 }
 
-func opMstore8(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	off, val := stack.pop().Int64(), stack.pop().Int64()
-	memory.store[off] = byte(val & 0xff)
+func opSstore(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
+	loc, val := stack.pop(), stack.pop()
+	env.Db().SetState(contract.Address(), intToHash(&loc), intToHash(&val))
+	return nil, nil
 }
 
-func opSload(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	loc := common.BigToHash(stack.pop())
-	val := env.Db().GetState(contract.Address(), loc).Big()
-	stack.push(val)
+func opJump(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
+	pos := stack.pop()
+	if !contract.jumpdests.has(contract.CodeHash, contract.Code, &pos) {
+		nop := contract.GetOp(pos.Uint64())
+		return nil, fmt.Errorf("invalid jump destination (%v) %v", nop, pos.ToBig())
+	}
+	*pc = pos.Uint64()
+	return nil, nil
 }
 
-func opSstore(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	loc := common.BigToHash(stack.pop())
-	val := stack.pop()
-	env.Db().SetState(contract.Address(), loc, common.BigToHash(val))
+func opJumpi(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
+	pos, cond := stack.pop(), stack.pop()
+	if cond.IsZero() {
+		*pc++
+		return nil, nil
+	}
+	if !contract.jumpdests.has(contract.CodeHash, contract.Code, &pos) {
+		nop := contract.GetOp(pos.Uint64())
+		return nil, fmt.Errorf("invalid jump destination (%v) %v", nop, pos.ToBig())
+	}
+	*pc = pos.Uint64()
+	return nil, nil
 }
 
-func opJumpdest(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
+func opJumpdest(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
+	return nil, nil
 }
 
-func opPc(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	stack.push(new(big.Int).Set(instr.data))
+func opPc(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
+	var counter uint256.Int
+	stack.push(counter.SetUint64(*pc))
+	return nil, nil
 }
 
-func opMsize(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	stack.push(big.NewInt(int64(memory.Len())))
+func opMsize(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
+	var size uint256.Int
+	stack.push(size.SetUint64(uint64(memory.Len())))
+	return nil, nil
 }
 
-func opGas(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	stack.push(new(big.Int).Set(contract.Gas))
+func opGas(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
+	var gas uint256.Int
+	stack.push(gas.SetFromBig(contract.Gas))
+	return nil, nil
 }
 
-func opCreate(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
+func opCreate(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
 	var (
 		value        = stack.pop()
-		offset, size = stack.pop(), stack.pop()
-		input        = memory.Get(offset.Int64(), size.Int64())
+		offset, size = stack.pop(), stack.peek()
+		input        = memory.Get(int64(offset.Uint64()), int64(size.Uint64()))
 		gas          = new(big.Int).Set(contract.Gas)
 	)
 	if env.RuleSet().GasTable(env.BlockNumber()).CreateBySuicide != nil {
@@ -421,141 +482,198 @@ func opCreate(instr instruction, pc *uint64, env Environment, contract *Contract
 	}
 
 	contract.UseGas(gas)
-	_, addr, suberr := env.Create(contract, input, gas, contract.Price, value)
+	_, addr, suberr := env.Create(contract, input, gas, contract.Price, value.ToBig())
 	// Push item on the stack based on the returned error. If the ruleset is
 	// homestead we must check for CodeStoreOutOfGasError (homestead only
 	// rule) and treat as an error, if the ruleset is frontier we must
 	// ignore this error and pretend the operation was successful.
 	if env.RuleSet().IsHomestead(env.BlockNumber()) && suberr == CodeStoreOutOfGasError {
-		stack.push(new(big.Int))
+		size.Clear()
 	} else if suberr != nil && suberr != CodeStoreOutOfGasError {
-		stack.push(new(big.Int))
+		size.Clear()
 	} else {
-		stack.push(addr.Big())
+		size.SetBytes(addr.Bytes())
 	}
+	return nil, nil
 }
 
-func opCall(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	gas := stack.pop()
-	// pop gas and value of the stack.
-	addr, value := stack.pop(), stack.pop()
-	value = U256(value)
+func opCreate2(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
+	var (
+		value        = stack.pop()
+		offset, size = stack.pop(), stack.pop()
+		salt         = stack.peek()
+		input        = memory.Get(int64(offset.Uint64()), int64(size.Uint64()))
+		gas          = new(big.Int).Set(contract.Gas)
+	)
+	// all but one 64th of the remaining gas, as for CREATE after EIP-150
+	gas.Div(gas, n64)
+	gas = gas.Sub(contract.Gas, gas)
+
+	contract.UseGas(gas)
+	_, addr, suberr := env.Create2(contract, input, gas, contract.Price, value.ToBig(), salt.ToBig())
+	if suberr != nil {
+		salt.Clear()
+	} else {
+		salt.SetBytes(addr.Bytes())
+	}
+	return nil, nil
+}
+
+func opCall(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
+	// pop gas, address and value of the stack. The gas was replaced by the
+	// gas available to the call when its cost was calculated.
+	gas, addr, value := stack.pop(), stack.pop(), stack.pop()
 	// pop input size and offset
 	inOffset, inSize := stack.pop(), stack.pop()
 	// pop return size and offset
-	retOffset, retSize := stack.pop(), stack.pop()
+	retOffset, retSize := stack.pop(), stack.peek()
 
-	address := common.BigToAddress(addr)
+	address := intToAddress(&addr)
 
 	// Get the arguments from the memory
-	args := memory.Get(inOffset.Int64(), inSize.Int64())
+	args := memory.Get(int64(inOffset.Uint64()), int64(inSize.Uint64()))
 
-	if len(value.Bytes()) > 0 {
-		gas.Add(gas, callStipend)
+	callGas := gas.ToBig()
+	if !value.IsZero() {
+		callGas.Add(callGas, env.RuleSet().GasTable(env.BlockNumber()).CallStipend)
 	}
 
-	ret, err := env.Call(contract, address, args, gas, contract.Price, value)
+	ret, err := env.Call(contract, address, args, callGas, contract.Price, value.ToBig())
 
+	if err == nil || err == ErrExecutionReverted {
+		memory.Set(retOffset.Uint64(), retSize.Uint64(), ret)
+	}
 	if err != nil {
-		stack.push(new(big.Int))
-
+		retSize.Clear()
 	} else {
-		stack.push(big.NewInt(1))
-
-		memory.Set(retOffset.Uint64(), retSize.Uint64(), ret)
+		retSize.SetOne()
 	}
+	return nil, nil
 }
 
-func opCallCode(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	gas := stack.pop()
-	// pop gas and value of the stack.
-	addr, value := stack.pop(), stack.pop()
-	value = U256(value)
+func opCallCode(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
+	// pop gas, address and value of the stack. The gas was replaced by the
+	// gas available to the call when its cost was calculated.
+	gas, addr, value := stack.pop(), stack.pop(), stack.pop()
 	// pop input size and offset
 	inOffset, inSize := stack.pop(), stack.pop()
 	// pop return size and offset
-	retOffset, retSize := stack.pop(), stack.pop()
+	retOffset, retSize := stack.pop(), stack.peek()
 
-	address := common.BigToAddress(addr)
+	address := intToAddress(&addr)
 
 	// Get the arguments from the memory
-	args := memory.Get(inOffset.Int64(), inSize.Int64())
+	args := memory.Get(int64(inOffset.Uint64()), int64(inSize.Uint64()))
 
-	if len(value.Bytes()) > 0 {
-		gas.Add(gas, callStipend)
+	callGas := gas.ToBig()
+	if !value.IsZero() {
+		callGas.Add(callGas, env.RuleSet().GasTable(env.BlockNumber()).CallStipend)
 	}
 
-	ret, err := env.CallCode(contract, address, args, gas, contract.Price, value)
+	ret, err := env.CallCode(contract, address, args, callGas, contract.Price, value.ToBig())
 
+	if err == nil || err == ErrExecutionReverted {
+		memory.Set(retOffset.Uint64(), retSize.Uint64(), ret)
+	}
 	if err != nil {
-		stack.push(new(big.Int))
-
+		retSize.Clear()
 	} else {
-		stack.push(big.NewInt(1))
-
-		memory.Set(retOffset.Uint64(), retSize.Uint64(), ret)
+		retSize.SetOne()
 	}
+	return nil, nil
 }
 
-func opDelegateCall(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	gas, to, inOffset, inSize, outOffset, outSize := stack.pop(), stack.pop(), stack.pop(), stack.pop(), stack.pop(), stack.pop()
+func opDelegateCall(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
+	gas, to, inOffset, inSize, outOffset := stack.pop(), stack.pop(), stack.pop(), stack.pop(), stack.pop()
+	outSize := stack.peek()
 
-	toAddr := common.BigToAddress(to)
-	args := memory.Get(inOffset.Int64(), inSize.Int64())
-	ret, err := env.DelegateCall(contract, toAddr, args, gas, contract.Price)
+	toAddr := intToAddress(&to)
+	args := memory.Get(int64(inOffset.Uint64()), int64(inSize.Uint64()))
+	ret, err := env.DelegateCall(contract, toAddr, args, gas.ToBig(), contract.Price)
+	if err == nil || err == ErrExecutionReverted {
+		memory.Set(outOffset.Uint64(), outSize.Uint64(), ret)
+	}
 	if err != nil {
-		stack.push(new(big.Int))
+		outSize.Clear()
 	} else {
-		stack.push(big.NewInt(1))
-		memory.Set(outOffset.Uint64(), outSize.Uint64(), ret)
+		outSize.SetOne()
 	}
+	return nil, nil
+}
+
+func opReturn(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
+	offset, size := stack.pop(), stack.pop()
+	// the memory is reused once the execution ends, so return a copy
+	return memory.Get(int64(offset.Uint64()), int64(size.Uint64())), nil
 }
 
-func opSuicide(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
+func opRevert(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
+	offset, size := stack.pop(), stack.pop()
+	return memory.Get(int64(offset.Uint64()), int64(size.Uint64())), nil
+}
+
+func opStop(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
+	return nil, nil
+}
+
+func opSuicide(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
 	balance := env.Db().GetBalance(contract.Address())
-	env.Db().AddBalance(common.BigToAddress(stack.pop()), balance)
+	beneficiary := stack.pop()
+	beneficiaryAddr := intToAddress(&beneficiary)
+	env.Db().AddBalance(beneficiaryAddr, balance)
+
+	if tracer := env.Tracer(); tracer != nil {
+		tracer.CaptureEnter(SUICIDE, contract.Address(), beneficiaryAddr, nil, new(big.Int), balance)
+		tracer.CaptureExit(nil, new(big.Int), nil)
+	}
 
 	env.Db().Suicide(contract.Address())
+	return nil, nil
 }
 
 // following functions are used by the instruction jump  table
 
 // make log instruction function
-func makeLog(size int) instrFn {
-	return func(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
+func makeLog(size int) executionFunc {
+	return func(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
 		topics := make([]common.Hash, size)
 		mStart, mSize := stack.pop(), stack.pop()
 		for i := 0; i < size; i++ {
-			topics[i] = common.BigToHash(stack.pop())
+			topic := stack.pop()
+			topics[i] = intToHash(&topic)
 		}
 
-		d := memory.Get(mStart.Int64(), mSize.Int64())
+		d := memory.Get(int64(mStart.Uint64()), int64(mSize.Uint64()))
 		log := NewLog(contract.Address(), topics, d, env.BlockNumber().Uint64())
 		env.AddLog(log)
+		return nil, nil
 	}
 }
 
 // make push instruction function
-func makePush(size uint64, bsize *big.Int) instrFn {
-	return func(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-		bytes := getData(contract.Code, new(big.Int).SetUint64(*pc+1), bsize)
-		stack.push(new(big.Int).SetBytes(bytes))
+func makePush(size uint64) executionFunc {
+	return func(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
+		var value uint256.Int
+		stack.push(value.SetBytes(getData(contract.Code, *pc+1, size)))
 		*pc += size
+		return nil, nil
 	}
 }
 
 // make push instruction function
-func makeDup(size int64) instrFn {
-	return func(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
+func makeDup(size int64) executionFunc {
+	return func(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
 		stack.dup(int(size))
+		return nil, nil
 	}
 }
 
 // make swap instruction function
-func makeSwap(size int64) instrFn {
+func makeSwap(size int64) executionFunc {
 	// switch n + 1 otherwise n would be swapped with n
 	size += 1
-	return func(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
+	return func(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error) {
 		stack.swap(int(size))
+		return nil, nil
 	}
 }
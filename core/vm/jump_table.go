@@ -16,154 +16,587 @@
 
 package vm
 
-import "math/big"
+import (
+	"math/big"
+	"sync"
+)
 
-type jumpPtr struct {
-	fn    instrFn
-	valid bool
+// executionFunc executes an operation. It returns the output of the
+// operations halting the execution.
+type executionFunc func(pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) ([]byte, error)
+
+type operation struct {
+	// execute is the operation function
+	execute executionFunc
+	// constantGas returns the gas charged for every execution of the operation
+	constantGas stepGasFunc
+	// dynamicGas, if set, returns the gas depending on the arguments of the
+	// operation and the state
+	dynamicGas gasFunc
+	// validateStack validates the stack for the operation
+	validateStack stackValidationFunc
+	// memorySize, if set, returns the memory size required by the operation
+	memorySize memorySizeFunc
+
+	halts   bool // indicates whether the operation halts further execution
+	reverts bool // indicates whether the operation reverts the state changes
+	jumps   bool // indicates whether the operation sets the program counter
+	valid   bool // indicates whether the operation is valid and known
 }
 
-type vmJumpTable [256]jumpPtr
+type vmJumpTable [256]operation
 
-func newJumpTable(ruleset RuleSet, blockNumber *big.Int) vmJumpTable {
-	var jumpTable vmJumpTable
+// jumpTableRules are the rules of a chain a jump table depends on.
+type jumpTableRules struct {
+	homestead, eip161, eip1014, eip145, eip1052, eip1344, eip1884, eip140 bool
+
+	// sstore prices SSTORE. No chain enables net gas metering yet, activating
+	// it only takes a rule set method selecting eip1283SStore or eip2200SStore.
+	sstore sstoreRule
+}
+
+var (
+	jumpTables     = make(map[jumpTableRules]*vmJumpTable)
+	jumpTablesLock sync.Mutex
+)
 
-	// when initialising a new VM execution we must first check the homestead
-	// changes.
-	if ruleset.IsHomestead(blockNumber) {
-		jumpTable[DELEGATECALL] = jumpPtr{opDelegateCall, true}
-	}
-
-	jumpTable[ADD] = jumpPtr{opAdd, true}
-	jumpTable[SUB] = jumpPtr{opSub, true}
-	jumpTable[MUL] = jumpPtr{opMul, true}
-	jumpTable[DIV] = jumpPtr{opDiv, true}
-	jumpTable[SDIV] = jumpPtr{opSdiv, true}
-	jumpTable[MOD] = jumpPtr{opMod, true}
-	jumpTable[SMOD] = jumpPtr{opSmod, true}
-	jumpTable[EXP] = jumpPtr{opExp, true}
-	jumpTable[SIGNEXTEND] = jumpPtr{opSignExtend, true}
-	jumpTable[NOT] = jumpPtr{opNot, true}
-	jumpTable[LT] = jumpPtr{opLt, true}
-	jumpTable[GT] = jumpPtr{opGt, true}
-	jumpTable[SLT] = jumpPtr{opSlt, true}
-	jumpTable[SGT] = jumpPtr{opSgt, true}
-	jumpTable[EQ] = jumpPtr{opEq, true}
-	jumpTable[ISZERO] = jumpPtr{opIszero, true}
-	jumpTable[AND] = jumpPtr{opAnd, true}
-	jumpTable[OR] = jumpPtr{opOr, true}
-	jumpTable[XOR] = jumpPtr{opXor, true}
-	jumpTable[BYTE] = jumpPtr{opByte, true}
-	jumpTable[ADDMOD] = jumpPtr{opAddmod, true}
-	jumpTable[MULMOD] = jumpPtr{opMulmod, true}
-	jumpTable[SHA3] = jumpPtr{opSha3, true}
-	jumpTable[ADDRESS] = jumpPtr{opAddress, true}
-	jumpTable[BALANCE] = jumpPtr{opBalance, true}
-	jumpTable[ORIGIN] = jumpPtr{opOrigin, true}
-	jumpTable[CALLER] = jumpPtr{opCaller, true}
-	jumpTable[CALLVALUE] = jumpPtr{opCallValue, true}
-	jumpTable[CALLDATALOAD] = jumpPtr{opCalldataLoad, true}
-	jumpTable[CALLDATASIZE] = jumpPtr{opCalldataSize, true}
-	jumpTable[CALLDATACOPY] = jumpPtr{opCalldataCopy, true}
-	jumpTable[CODESIZE] = jumpPtr{opCodeSize, true}
-	jumpTable[EXTCODESIZE] = jumpPtr{opExtCodeSize, true}
-	jumpTable[CODECOPY] = jumpPtr{opCodeCopy, true}
-	jumpTable[EXTCODECOPY] = jumpPtr{opExtCodeCopy, true}
-	jumpTable[GASPRICE] = jumpPtr{opGasprice, true}
-	jumpTable[BLOCKHASH] = jumpPtr{opBlockhash, true}
-	jumpTable[COINBASE] = jumpPtr{opCoinbase, true}
-	jumpTable[TIMESTAMP] = jumpPtr{opTimestamp, true}
-	jumpTable[NUMBER] = jumpPtr{opNumber, true}
-	jumpTable[DIFFICULTY] = jumpPtr{opDifficulty, true}
-	jumpTable[GASLIMIT] = jumpPtr{opGasLimit, true}
-	jumpTable[POP] = jumpPtr{opPop, true}
-	jumpTable[MLOAD] = jumpPtr{opMload, true}
-	jumpTable[MSTORE] = jumpPtr{opMstore, true}
-	jumpTable[MSTORE8] = jumpPtr{opMstore8, true}
-	jumpTable[SLOAD] = jumpPtr{opSload, true}
-	jumpTable[SSTORE] = jumpPtr{opSstore, true}
-	jumpTable[JUMPDEST] = jumpPtr{opJumpdest, true}
-	jumpTable[PC] = jumpPtr{nil, true}
-	jumpTable[MSIZE] = jumpPtr{opMsize, true}
-	jumpTable[GAS] = jumpPtr{opGas, true}
-	jumpTable[CREATE] = jumpPtr{opCreate, true}
-	jumpTable[CALL] = jumpPtr{opCall, true}
-	jumpTable[CALLCODE] = jumpPtr{opCallCode, true}
-	jumpTable[LOG0] = jumpPtr{makeLog(0), true}
-	jumpTable[LOG1] = jumpPtr{makeLog(1), true}
-	jumpTable[LOG2] = jumpPtr{makeLog(2), true}
-	jumpTable[LOG3] = jumpPtr{makeLog(3), true}
-	jumpTable[LOG4] = jumpPtr{makeLog(4), true}
-	jumpTable[SWAP1] = jumpPtr{makeSwap(1), true}
-	jumpTable[SWAP2] = jumpPtr{makeSwap(2), true}
-	jumpTable[SWAP3] = jumpPtr{makeSwap(3), true}
-	jumpTable[SWAP4] = jumpPtr{makeSwap(4), true}
-	jumpTable[SWAP5] = jumpPtr{makeSwap(5), true}
-	jumpTable[SWAP6] = jumpPtr{makeSwap(6), true}
-	jumpTable[SWAP7] = jumpPtr{makeSwap(7), true}
-	jumpTable[SWAP8] = jumpPtr{makeSwap(8), true}
-	jumpTable[SWAP9] = jumpPtr{makeSwap(9), true}
-	jumpTable[SWAP10] = jumpPtr{makeSwap(10), true}
-	jumpTable[SWAP11] = jumpPtr{makeSwap(11), true}
-	jumpTable[SWAP12] = jumpPtr{makeSwap(12), true}
-	jumpTable[SWAP13] = jumpPtr{makeSwap(13), true}
-	jumpTable[SWAP14] = jumpPtr{makeSwap(14), true}
-	jumpTable[SWAP15] = jumpPtr{makeSwap(15), true}
-	jumpTable[SWAP16] = jumpPtr{makeSwap(16), true}
-	jumpTable[PUSH1] = jumpPtr{makePush(1, big.NewInt(1)), true}
-	jumpTable[PUSH2] = jumpPtr{makePush(2, big.NewInt(2)), true}
-	jumpTable[PUSH3] = jumpPtr{makePush(3, big.NewInt(3)), true}
-	jumpTable[PUSH4] = jumpPtr{makePush(4, big.NewInt(4)), true}
-	jumpTable[PUSH5] = jumpPtr{makePush(5, big.NewInt(5)), true}
-	jumpTable[PUSH6] = jumpPtr{makePush(6, big.NewInt(6)), true}
-	jumpTable[PUSH7] = jumpPtr{makePush(7, big.NewInt(7)), true}
-	jumpTable[PUSH8] = jumpPtr{makePush(8, big.NewInt(8)), true}
-	jumpTable[PUSH9] = jumpPtr{makePush(9, big.NewInt(9)), true}
-	jumpTable[PUSH10] = jumpPtr{makePush(10, big.NewInt(10)), true}
-	jumpTable[PUSH11] = jumpPtr{makePush(11, big.NewInt(11)), true}
-	jumpTable[PUSH12] = jumpPtr{makePush(12, big.NewInt(12)), true}
-	jumpTable[PUSH13] = jumpPtr{makePush(13, big.NewInt(13)), true}
-	jumpTable[PUSH14] = jumpPtr{makePush(14, big.NewInt(14)), true}
-	jumpTable[PUSH15] = jumpPtr{makePush(15, big.NewInt(15)), true}
-	jumpTable[PUSH16] = jumpPtr{makePush(16, big.NewInt(16)), true}
-	jumpTable[PUSH17] = jumpPtr{makePush(17, big.NewInt(17)), true}
-	jumpTable[PUSH18] = jumpPtr{makePush(18, big.NewInt(18)), true}
-	jumpTable[PUSH19] = jumpPtr{makePush(19, big.NewInt(19)), true}
-	jumpTable[PUSH20] = jumpPtr{makePush(20, big.NewInt(20)), true}
-	jumpTable[PUSH21] = jumpPtr{makePush(21, big.NewInt(21)), true}
-	jumpTable[PUSH22] = jumpPtr{makePush(22, big.NewInt(22)), true}
-	jumpTable[PUSH23] = jumpPtr{makePush(23, big.NewInt(23)), true}
-	jumpTable[PUSH24] = jumpPtr{makePush(24, big.NewInt(24)), true}
-	jumpTable[PUSH25] = jumpPtr{makePush(25, big.NewInt(25)), true}
-	jumpTable[PUSH26] = jumpPtr{makePush(26, big.NewInt(26)), true}
-	jumpTable[PUSH27] = jumpPtr{makePush(27, big.NewInt(27)), true}
-	jumpTable[PUSH28] = jumpPtr{makePush(28, big.NewInt(28)), true}
-	jumpTable[PUSH29] = jumpPtr{makePush(29, big.NewInt(29)), true}
-	jumpTable[PUSH30] = jumpPtr{makePush(30, big.NewInt(30)), true}
-	jumpTable[PUSH31] = jumpPtr{makePush(31, big.NewInt(31)), true}
-	jumpTable[PUSH32] = jumpPtr{makePush(32, big.NewInt(32)), true}
-	jumpTable[DUP1] = jumpPtr{makeDup(1), true}
-	jumpTable[DUP2] = jumpPtr{makeDup(2), true}
-	jumpTable[DUP3] = jumpPtr{makeDup(3), true}
-	jumpTable[DUP4] = jumpPtr{makeDup(4), true}
-	jumpTable[DUP5] = jumpPtr{makeDup(5), true}
-	jumpTable[DUP6] = jumpPtr{makeDup(6), true}
-	jumpTable[DUP7] = jumpPtr{makeDup(7), true}
-	jumpTable[DUP8] = jumpPtr{makeDup(8), true}
-	jumpTable[DUP9] = jumpPtr{makeDup(9), true}
-	jumpTable[DUP10] = jumpPtr{makeDup(10), true}
-	jumpTable[DUP11] = jumpPtr{makeDup(11), true}
-	jumpTable[DUP12] = jumpPtr{makeDup(12), true}
-	jumpTable[DUP13] = jumpPtr{makeDup(13), true}
-	jumpTable[DUP14] = jumpPtr{makeDup(14), true}
-	jumpTable[DUP15] = jumpPtr{makeDup(15), true}
-	jumpTable[DUP16] = jumpPtr{makeDup(16), true}
-
-	jumpTable[RETURN] = jumpPtr{nil, true}
-	jumpTable[SUICIDE] = jumpPtr{nil, true}
-	jumpTable[JUMP] = jumpPtr{nil, true}
-	jumpTable[JUMPI] = jumpPtr{nil, true}
-	jumpTable[STOP] = jumpPtr{nil, true}
+// newJumpTable returns the jump table of the rules enabled at the given
+// block. Jump tables are built once for every set of rules and shared by all
+// the EVMs, so they must not be modified.
+func newJumpTable(ruleset RuleSet, blockNumber *big.Int) *vmJumpTable {
+	rules := jumpTableRules{
+		homestead: ruleset.IsHomestead(blockNumber),
+		eip161:    ruleset.IsEIP161(blockNumber),
+		eip1014:   ruleset.IsEIP1014(blockNumber),
+		eip145:    ruleset.IsEIP145(blockNumber),
+		eip1052:   ruleset.IsEIP1052(blockNumber),
+		eip1344:   ruleset.IsEIP1344(blockNumber),
+		eip1884:   ruleset.IsEIP1884(blockNumber),
+		eip140:    ruleset.IsEIP140(blockNumber),
+		sstore:    originalSStore,
+	}
+
+	jumpTablesLock.Lock()
+	defer jumpTablesLock.Unlock()
 
+	jumpTable, ok := jumpTables[rules]
+	if !ok {
+		jumpTable = rules.jumpTable()
+		jumpTables[rules] = jumpTable
+	}
 	return jumpTable
 }
+
+// jumpTable builds the jump table of the rules, adding the operations
+// introduced by every enabled fork to the frontier instruction set.
+func (rules jumpTableRules) jumpTable() *vmJumpTable {
+	jumpTable := newFrontierInstructionSet()
+
+	jumpTable[SSTORE].dynamicGas = makeGasSStore(rules.sstore)
+	if rules.homestead {
+		jumpTable[DELEGATECALL] = operation{
+			execute:       opDelegateCall,
+			constantGas:   zeroStep,
+			dynamicGas:    gasDelegateCall,
+			validateStack: makeStackFunc(6, 1),
+			memorySize:    memoryDelegateCall,
+			valid:         true,
+		}
+	}
+	if rules.eip161 {
+		jumpTable[CALL].dynamicGas = makeGasCall(CALL, true)
+		jumpTable[SUICIDE].dynamicGas = makeGasSuicide(true)
+	}
+	if rules.eip1014 {
+		jumpTable[CREATE2] = operation{
+			execute:       opCreate2,
+			constantGas:   zeroStep,
+			dynamicGas:    gasCreate2,
+			validateStack: makeStackFunc(4, 1),
+			memorySize:    memoryCreate,
+			valid:         true,
+		}
+	}
+	if rules.eip145 {
+		for op, fn := range map[OpCode]executionFunc{SHL: opSHL, SHR: opSHR, SAR: opSAR} {
+			jumpTable[op] = operation{
+				execute:       fn,
+				constantGas:   fastestStep,
+				validateStack: makeStackFunc(2, 1),
+				valid:         true,
+			}
+		}
+	}
+	if rules.eip1052 {
+		jumpTable[EXTCODEHASH] = operation{
+			execute:       opExtCodeHash,
+			constantGas:   zeroStep,
+			dynamicGas:    gasExtCodeHash,
+			validateStack: makeStackFunc(1, 1),
+			valid:         true,
+		}
+	}
+	if rules.eip1344 {
+		jumpTable[CHAINID] = operation{
+			execute:       opChainID,
+			constantGas:   quickStep,
+			validateStack: makeStackFunc(0, 1),
+			valid:         true,
+		}
+	}
+	if rules.eip1884 {
+		jumpTable[SELFBALANCE] = operation{
+			execute:       opSelfBalance,
+			constantGas:   fastStep,
+			validateStack: makeStackFunc(0, 1),
+			valid:         true,
+		}
+	}
+	if rules.eip140 {
+		jumpTable[REVERT] = operation{
+			execute:       opRevert,
+			constantGas:   zeroStep,
+			dynamicGas:    gasMemory,
+			validateStack: makeStackFunc(2, 0),
+			memorySize:    memoryReturn,
+			reverts:       true,
+			valid:         true,
+		}
+	}
+	return jumpTable
+}
+
+// newFrontierInstructionSet returns the instruction set of the frontier
+// rules.
+func newFrontierInstructionSet() *vmJumpTable {
+	var jumpTable vmJumpTable
+
+	jumpTable[STOP] = operation{
+		execute:       opStop,
+		constantGas:   zeroStep,
+		validateStack: makeStackFunc(0, 0),
+		halts:         true,
+		valid:         true,
+	}
+	jumpTable[ADD] = operation{
+		execute:       opAdd,
+		constantGas:   fastestStep,
+		validateStack: makeStackFunc(2, 1),
+		valid:         true,
+	}
+	jumpTable[MUL] = operation{
+		execute:       opMul,
+		constantGas:   fastStep,
+		validateStack: makeStackFunc(2, 1),
+		valid:         true,
+	}
+	jumpTable[SUB] = operation{
+		execute:       opSub,
+		constantGas:   fastestStep,
+		validateStack: makeStackFunc(2, 1),
+		valid:         true,
+	}
+	jumpTable[DIV] = operation{
+		execute:       opDiv,
+		constantGas:   fastStep,
+		validateStack: makeStackFunc(2, 1),
+		valid:         true,
+	}
+	jumpTable[SDIV] = operation{
+		execute:       opSdiv,
+		constantGas:   fastStep,
+		validateStack: makeStackFunc(2, 1),
+		valid:         true,
+	}
+	jumpTable[MOD] = operation{
+		execute:       opMod,
+		constantGas:   fastStep,
+		validateStack: makeStackFunc(2, 1),
+		valid:         true,
+	}
+	jumpTable[SMOD] = operation{
+		execute:       opSmod,
+		constantGas:   fastStep,
+		validateStack: makeStackFunc(2, 1),
+		valid:         true,
+	}
+	jumpTable[ADDMOD] = operation{
+		execute:       opAddmod,
+		constantGas:   midStep,
+		validateStack: makeStackFunc(3, 1),
+		valid:         true,
+	}
+	jumpTable[MULMOD] = operation{
+		execute:       opMulmod,
+		constantGas:   midStep,
+		validateStack: makeStackFunc(3, 1),
+		valid:         true,
+	}
+	jumpTable[EXP] = operation{
+		execute:       opExp,
+		constantGas:   slowStep,
+		dynamicGas:    gasExp,
+		validateStack: makeStackFunc(2, 1),
+		valid:         true,
+	}
+	jumpTable[SIGNEXTEND] = operation{
+		execute:       opSignExtend,
+		constantGas:   fastStep,
+		validateStack: makeStackFunc(2, 1),
+		valid:         true,
+	}
+	jumpTable[LT] = operation{
+		execute:       opLt,
+		constantGas:   fastestStep,
+		validateStack: makeStackFunc(2, 1),
+		valid:         true,
+	}
+	jumpTable[GT] = operation{
+		execute:       opGt,
+		constantGas:   fastestStep,
+		validateStack: makeStackFunc(2, 1),
+		valid:         true,
+	}
+	jumpTable[SLT] = operation{
+		execute:       opSlt,
+		constantGas:   fastestStep,
+		validateStack: makeStackFunc(2, 1),
+		valid:         true,
+	}
+	jumpTable[SGT] = operation{
+		execute:       opSgt,
+		constantGas:   fastestStep,
+		validateStack: makeStackFunc(2, 1),
+		valid:         true,
+	}
+	jumpTable[EQ] = operation{
+		execute:       opEq,
+		constantGas:   fastestStep,
+		validateStack: makeStackFunc(2, 1),
+		valid:         true,
+	}
+	jumpTable[ISZERO] = operation{
+		execute:       opIszero,
+		constantGas:   fastestStep,
+		validateStack: makeStackFunc(1, 1),
+		valid:         true,
+	}
+	jumpTable[AND] = operation{
+		execute:       opAnd,
+		constantGas:   fastestStep,
+		validateStack: makeStackFunc(2, 1),
+		valid:         true,
+	}
+	jumpTable[OR] = operation{
+		execute:       opOr,
+		constantGas:   fastestStep,
+		validateStack: makeStackFunc(2, 1),
+		valid:         true,
+	}
+	jumpTable[XOR] = operation{
+		execute:       opXor,
+		constantGas:   fastestStep,
+		validateStack: makeStackFunc(2, 1),
+		valid:         true,
+	}
+	jumpTable[NOT] = operation{
+		execute:       opNot,
+		constantGas:   fastestStep,
+		validateStack: makeStackFunc(1, 1),
+		valid:         true,
+	}
+	jumpTable[BYTE] = operation{
+		execute:       opByte,
+		constantGas:   fastestStep,
+		validateStack: makeStackFunc(2, 1),
+		valid:         true,
+	}
+	jumpTable[SHA3] = operation{
+		execute:       opSha3,
+		constantGas:   zeroStep,
+		dynamicGas:    gasSha3,
+		validateStack: makeStackFunc(2, 1),
+		memorySize:    memoryReturn,
+		valid:         true,
+	}
+	jumpTable[ADDRESS] = operation{
+		execute:       opAddress,
+		constantGas:   quickStep,
+		validateStack: makeStackFunc(0, 1),
+		valid:         true,
+	}
+	jumpTable[BALANCE] = operation{
+		execute:       opBalance,
+		constantGas:   zeroStep,
+		dynamicGas:    gasBalance,
+		validateStack: makeStackFunc(1, 1),
+		valid:         true,
+	}
+	jumpTable[ORIGIN] = operation{
+		execute:       opOrigin,
+		constantGas:   quickStep,
+		validateStack: makeStackFunc(0, 1),
+		valid:         true,
+	}
+	jumpTable[CALLER] = operation{
+		execute:       opCaller,
+		constantGas:   quickStep,
+		validateStack: makeStackFunc(0, 1),
+		valid:         true,
+	}
+	jumpTable[CALLVALUE] = operation{
+		execute:       opCallValue,
+		constantGas:   quickStep,
+		validateStack: makeStackFunc(0, 1),
+		valid:         true,
+	}
+	jumpTable[CALLDATALOAD] = operation{
+		execute:       opCalldataLoad,
+		constantGas:   fastestStep,
+		validateStack: makeStackFunc(1, 1),
+		valid:         true,
+	}
+	jumpTable[CALLDATASIZE] = operation{
+		execute:       opCalldataSize,
+		constantGas:   quickStep,
+		validateStack: makeStackFunc(0, 1),
+		valid:         true,
+	}
+	jumpTable[CALLDATACOPY] = operation{
+		execute:       opCalldataCopy,
+		constantGas:   fastestStep,
+		dynamicGas:    gasCopy,
+		validateStack: makeStackFunc(3, 0),
+		memorySize:    memoryCopy,
+		valid:         true,
+	}
+	jumpTable[CODESIZE] = operation{
+		execute:       opCodeSize,
+		constantGas:   quickStep,
+		validateStack: makeStackFunc(0, 1),
+		valid:         true,
+	}
+	jumpTable[CODECOPY] = operation{
+		execute:       opCodeCopy,
+		constantGas:   fastestStep,
+		dynamicGas:    gasCopy,
+		validateStack: makeStackFunc(3, 0),
+		memorySize:    memoryCopy,
+		valid:         true,
+	}
+	jumpTable[GASPRICE] = operation{
+		execute:       opGasprice,
+		constantGas:   quickStep,
+		validateStack: makeStackFunc(0, 1),
+		valid:         true,
+	}
+	jumpTable[EXTCODESIZE] = operation{
+		execute:       opExtCodeSize,
+		constantGas:   zeroStep,
+		dynamicGas:    gasExtCodeSize,
+		validateStack: makeStackFunc(1, 1),
+		valid:         true,
+	}
+	jumpTable[EXTCODECOPY] = operation{
+		execute:       opExtCodeCopy,
+		constantGas:   zeroStep,
+		dynamicGas:    gasExtCodeCopy,
+		validateStack: makeStackFunc(4, 0),
+		memorySize:    memoryExtCodeCopy,
+		valid:         true,
+	}
+	jumpTable[BLOCKHASH] = operation{
+		execute:       opBlockhash,
+		constantGas:   extStep,
+		validateStack: makeStackFunc(1, 1),
+		valid:         true,
+	}
+	jumpTable[COINBASE] = operation{
+		execute:       opCoinbase,
+		constantGas:   quickStep,
+		validateStack: makeStackFunc(0, 1),
+		valid:         true,
+	}
+	jumpTable[TIMESTAMP] = operation{
+		execute:       opTimestamp,
+		constantGas:   quickStep,
+		validateStack: makeStackFunc(0, 1),
+		valid:         true,
+	}
+	jumpTable[NUMBER] = operation{
+		execute:       opNumber,
+		constantGas:   quickStep,
+		validateStack: makeStackFunc(0, 1),
+		valid:         true,
+	}
+	jumpTable[DIFFICULTY] = operation{
+		execute:       opDifficulty,
+		constantGas:   quickStep,
+		validateStack: makeStackFunc(0, 1),
+		valid:         true,
+	}
+	jumpTable[GASLIMIT] = operation{
+		execute:       opGasLimit,
+		constantGas:   quickStep,
+		validateStack: makeStackFunc(0, 1),
+		valid:         true,
+	}
+	jumpTable[POP] = operation{
+		execute:       opPop,
+		constantGas:   quickStep,
+		validateStack: makeStackFunc(1, 0),
+		valid:         true,
+	}
+	jumpTable[MLOAD] = operation{
+		execute:       opMload,
+		constantGas:   fastestStep,
+		dynamicGas:    gasMemory,
+		validateStack: makeStackFunc(1, 1),
+		memorySize:    memoryMLoad,
+		valid:         true,
+	}
+	jumpTable[MSTORE] = operation{
+		execute:       opMstore,
+		constantGas:   fastestStep,
+		dynamicGas:    gasMemory,
+		validateStack: makeStackFunc(2, 0),
+		memorySize:    memoryMLoad,
+		valid:         true,
+	}
+	jumpTable[MSTORE8] = operation{
+		execute:       opMstore8,
+		constantGas:   fastestStep,
+		dynamicGas:    gasMemory,
+		validateStack: makeStackFunc(2, 0),
+		memorySize:    memoryMStore8,
+		valid:         true,
+	}
+	jumpTable[SLOAD] = operation{
+		execute:       opSload,
+		constantGas:   zeroStep,
+		dynamicGas:    gasSLoad,
+		validateStack: makeStackFunc(1, 1),
+		valid:         true,
+	}
+	jumpTable[SSTORE] = operation{
+		execute:       opSstore,
+		constantGas:   zeroStep,
+		dynamicGas:    makeGasSStore(originalSStore),
+		validateStack: makeStackFunc(2, 0),
+		valid:         true,
+	}
+	jumpTable[JUMP] = operation{
+		execute:       opJump,
+		constantGas:   midStep,
+		validateStack: makeStackFunc(1, 0),
+		jumps:         true,
+		valid:         true,
+	}
+	jumpTable[JUMPI] = operation{
+		execute:       opJumpi,
+		constantGas:   slowStep,
+		validateStack: makeStackFunc(2, 0),
+		jumps:         true,
+		valid:         true,
+	}
+	jumpTable[PC] = operation{
+		execute:       opPc,
+		constantGas:   quickStep,
+		validateStack: makeStackFunc(0, 1),
+		valid:         true,
+	}
+	jumpTable[MSIZE] = operation{
+		execute:       opMsize,
+		constantGas:   quickStep,
+		validateStack: makeStackFunc(0, 1),
+		valid:         true,
+	}
+	jumpTable[GAS] = operation{
+		execute:       opGas,
+		constantGas:   quickStep,
+		validateStack: makeStackFunc(0, 1),
+		valid:         true,
+	}
+	jumpTable[JUMPDEST] = operation{
+		execute:       opJumpdest,
+		constantGas:   zeroStep,
+		dynamicGas:    gasJumpDest,
+		validateStack: makeStackFunc(0, 0),
+		valid:         true,
+	}
+	for i := 0; i < 32; i++ {
+		jumpTable[PUSH1+OpCode(i)] = operation{
+			execute:       makePush(uint64(i + 1)),
+			constantGas:   fastestStep,
+			validateStack: makeStackFunc(0, 1),
+			valid:         true,
+		}
+	}
+	for i := 0; i < 16; i++ {
+		jumpTable[DUP1+OpCode(i)] = operation{
+			execute:       makeDup(int64(i + 1)),
+			constantGas:   fastestStep,
+			validateStack: makeDupStackFunc(i + 1),
+			valid:         true,
+		}
+		jumpTable[SWAP1+OpCode(i)] = operation{
+			execute:       makeSwap(int64(i + 1)),
+			constantGas:   fastestStep,
+			validateStack: makeSwapStackFunc(i + 2),
+			valid:         true,
+		}
+	}
+	for i := 0; i <= 4; i++ {
+		jumpTable[LOG0+OpCode(i)] = operation{
+			execute:       makeLog(i),
+			constantGas:   zeroStep,
+			dynamicGas:    makeGasLog(int64(i)),
+			validateStack: makeStackFunc(i+2, 0),
+			memorySize:    memoryReturn,
+			valid:         true,
+		}
+	}
+	jumpTable[CREATE] = operation{
+		execute:       opCreate,
+		constantGas:   zeroStep,
+		dynamicGas:    gasCreate,
+		validateStack: makeStackFunc(3, 1),
+		memorySize:    memoryCreate,
+		valid:         true,
+	}
+	jumpTable[CALL] = operation{
+		execute:       opCall,
+		constantGas:   zeroStep,
+		dynamicGas:    makeGasCall(CALL, false),
+		validateStack: makeStackFunc(7, 1),
+		memorySize:    memoryCall,
+		valid:         true,
+	}
+	jumpTable[CALLCODE] = operation{
+		execute:       opCallCode,
+		constantGas:   zeroStep,
+		dynamicGas:    makeGasCall(CALLCODE, false),
+		validateStack: makeStackFunc(7, 1),
+		memorySize:    memoryCall,
+		valid:         true,
+	}
+	jumpTable[RETURN] = operation{
+		execute:       opReturn,
+		constantGas:   zeroStep,
+		dynamicGas:    gasMemory,
+		validateStack: makeStackFunc(2, 0),
+		memorySize:    memoryReturn,
+		halts:         true,
+		valid:         true,
+	}
+	jumpTable[SUICIDE] = operation{
+		execute:       opSuicide,
+		constantGas:   zeroStep,
+		dynamicGas:    makeGasSuicide(false),
+		validateStack: makeStackFunc(1, 0),
+		halts:         true,
+		valid:         true,
+	}
+
+	return &jumpTable
+}
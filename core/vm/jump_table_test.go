@@ -26,6 +26,16 @@ type ruleSet struct {
 }
 
 func (r ruleSet) IsHomestead(n *big.Int) bool { return n.Cmp(r.hs) >= 0 }
+func (r ruleSet) IsEIP161(*big.Int) bool      { return false }
+func (r ruleSet) MaxCodeSize(*big.Int) int    { return 0 }
+func (r ruleSet) IsEIP1014(*big.Int) bool     { return false }
+func (r ruleSet) IsEIP145(*big.Int) bool      { return false }
+func (r ruleSet) IsEIP1052(*big.Int) bool     { return false }
+func (r ruleSet) IsEIP1344(*big.Int) bool     { return false }
+func (r ruleSet) IsEIP1884(*big.Int) bool     { return false }
+func (r ruleSet) IsEIP152(*big.Int) bool      { return false }
+func (r ruleSet) IsEIP140(*big.Int) bool      { return false }
+func (r ruleSet) ChainID() *big.Int           { return new(big.Int) }
 
 func (r ruleSet) GasTable(*big.Int) *GasTable {
 	return &GasTable{
@@ -52,3 +62,14 @@ func TestInit(t *testing.T) {
 		}
 	}
 }
+
+// Tests that jump tables are built once for every set of rules.
+func TestJumpTableSharing(t *testing.T) {
+	rules := ruleSet{big.NewInt(1)}
+	if newJumpTable(rules, big.NewInt(1)) != newJumpTable(rules, big.NewInt(100)) {
+		t.Error("Expected the jump tables of the same rules to be shared")
+	}
+	if newJumpTable(rules, big.NewInt(0)) == newJumpTable(rules, big.NewInt(1)) {
+		t.Error("Expected the jump tables of different rules to differ")
+	}
+}
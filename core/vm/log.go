@@ -20,6 +20,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"strconv"
 
 	"github.com/ellaism/go-ellaism/common"
 	"github.com/ellaism/go-ellaism/rlp"
@@ -79,6 +80,42 @@ func (r *Log) MarshalJSON() ([]byte, error) {
 	return json.Marshal(fields)
 }
 
+func (r *Log) UnmarshalJSON(input []byte) error {
+	var dec struct {
+		Address          common.Address `json:"address"`
+		Data             string         `json:"data"`
+		BlockNumber      string         `json:"blockNumber"`
+		LogIndex         string         `json:"logIndex"`
+		BlockHash        common.Hash    `json:"blockHash"`
+		TransactionHash  common.Hash    `json:"transactionHash"`
+		TransactionIndex string         `json:"transactionIndex"`
+		Topics           []common.Hash  `json:"topics"`
+	}
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return err
+	}
+	number, err := strconv.ParseUint(dec.BlockNumber, 0, 64)
+	if err != nil {
+		return fmt.Errorf("malformed blockNumber %q", dec.BlockNumber)
+	}
+	index, err := strconv.ParseUint(dec.LogIndex, 0, 0)
+	if err != nil {
+		return fmt.Errorf("malformed logIndex %q", dec.LogIndex)
+	}
+	txIndex, err := strconv.ParseUint(dec.TransactionIndex, 0, 0)
+	if err != nil {
+		return fmt.Errorf("malformed transactionIndex %q", dec.TransactionIndex)
+	}
+	// Empty data is encoded as "" rather than "0x"
+	data := common.FromHex(dec.Data)
+	if data == nil {
+		data = []byte{}
+	}
+	r.Address, r.Topics, r.Data = dec.Address, dec.Topics, data
+	r.BlockNumber, r.TxHash, r.TxIndex, r.BlockHash, r.Index = number, dec.TransactionHash, uint(txIndex), dec.BlockHash, uint(index)
+	return nil
+}
+
 type Logs []*Log
 
 // LogForStorage is a wrapper around a Log that flattens and parses the entire
@@ -0,0 +1,114 @@
+// Copyright 2016 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/ellaism/go-ellaism/common"
+)
+
+// LogConfig are the configuration options for structured logger the EVM
+type LogConfig struct {
+	DisableMemory bool // disable memory capture
+	DisableStack  bool // disable stack capture
+}
+
+// StructLog is emitted to the EVM each cycle and lists information about the
+// current internal state prior to the execution of the statement.
+type StructLog struct {
+	Pc      uint64
+	Op      OpCode
+	Gas     *big.Int
+	GasCost *big.Int
+	Memory  []byte
+	Stack   []*big.Int
+	Depth   int
+}
+
+func (l *StructLog) MarshalJSON() ([]byte, error) {
+	fields := map[string]interface{}{
+		"pc":      l.Pc,
+		"op":      l.Op.String(),
+		"gas":     fmt.Sprintf("%#x", l.Gas),
+		"gasCost": fmt.Sprintf("%#x", l.GasCost),
+		"depth":   l.Depth,
+	}
+	if l.Memory != nil {
+		fields["memory"] = fmt.Sprintf("0x%x", l.Memory)
+	}
+	if l.Stack != nil {
+		stack := make([]string, len(l.Stack))
+		for i, item := range l.Stack {
+			stack[i] = fmt.Sprintf("%#x", item)
+		}
+		fields["stack"] = stack
+	}
+	return json.Marshal(fields)
+}
+
+// StructLogger is a Tracer which records the state of the EVM prior to the
+// execution of every opcode.
+type StructLogger struct {
+	cfg  LogConfig
+	logs []StructLog
+}
+
+// NewStructLogger returns a new logger
+func NewStructLogger(cfg *LogConfig) *StructLogger {
+	logger := &StructLogger{}
+	if cfg != nil {
+		logger.cfg = *cfg
+	}
+	return logger
+}
+
+func (l *StructLogger) CaptureEnter(typ OpCode, from, to common.Address, input []byte, gas, value *big.Int) {
+}
+
+func (l *StructLogger) CaptureState(env Environment, pc uint64, op OpCode, gas, cost *big.Int, memory *Memory, stack []*big.Int, contract *Contract, depth int) {
+	log := StructLog{
+		Pc:      pc,
+		Op:      op,
+		Gas:     new(big.Int).Add(gas, cost),
+		GasCost: new(big.Int).Set(cost),
+		Depth:   depth,
+	}
+	if !l.cfg.DisableMemory {
+		log.Memory = common.CopyBytes(memory.Data())
+		if log.Memory == nil {
+			log.Memory = []byte{}
+		}
+	}
+	if !l.cfg.DisableStack {
+		log.Stack = make([]*big.Int, len(stack))
+		for i, item := range stack {
+			log.Stack[i] = new(big.Int).Set(item)
+		}
+	}
+	l.logs = append(l.logs, log)
+}
+
+func (l *StructLogger) CaptureExit(output []byte, gasUsed *big.Int, err error) {
+}
+
+// StructLogs returns the logs captured so far.
+func (l *StructLogger) StructLogs() []StructLog {
+	return l.logs
+}
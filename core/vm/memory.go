@@ -16,7 +16,19 @@
 
 package vm
 
-import "fmt"
+import (
+	"fmt"
+	"sync"
+)
+
+// maxPooledMemory is the size above which memories aren't reused, so that a
+// single memory hungry execution doesn't keep its memory allocated.
+const maxPooledMemory = 1024 * 1024
+
+// memoryPool holds the memories released by finished executions.
+var memoryPool = sync.Pool{
+	New: func() interface{} { return new(Memory) },
+}
 
 // Memory implements a simple memory model for the ethereum virtual machine.
 type Memory struct {
@@ -27,6 +39,22 @@ func NewMemory() *Memory {
 	return &Memory{nil}
 }
 
+// newPooledMemory returns an empty memory, reusing a released one if
+// possible. It must be released with releaseMemory once no longer used.
+func newPooledMemory() *Memory {
+	return memoryPool.Get().(*Memory)
+}
+
+// releaseMemory returns the memory to the pool. Neither the memory nor any
+// slice of it may be used afterwards.
+func releaseMemory(m *Memory) {
+	if cap(m.store) > maxPooledMemory {
+		return
+	}
+	m.store = m.store[:0]
+	memoryPool.Put(m)
+}
+
 // Set sets offset + size to value
 func (m *Memory) Set(offset, size uint64, value []byte) {
 	// length of store may never be less than offset + size.
@@ -42,11 +70,22 @@ func (m *Memory) Set(offset, size uint64, value []byte) {
 	}
 }
 
-// Resize resizes the memory to size
+// Resize resizes the memory to size, growing in place if the store has the
+// capacity to.
 func (m *Memory) Resize(size uint64) {
-	if uint64(m.Len()) < size {
-		m.store = append(m.store, make([]byte, size-uint64(m.Len()))...)
+	if uint64(m.Len()) >= size {
+		return
+	}
+	if uint64(cap(m.store)) >= size {
+		// the spare capacity may hold data of a previous execution
+		n := len(m.store)
+		m.store = m.store[:size]
+		for i := n; i < len(m.store); i++ {
+			m.store[i] = 0
+		}
+		return
 	}
+	m.store = append(m.store, make([]byte, size-uint64(m.Len()))...)
 }
 
 // Get returns offset + size as a new slice
@@ -0,0 +1,72 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"math/big"
+
+	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/common/uint256"
+)
+
+// memorySizeFunc returns the memory size an operation requires given its
+// arguments on the stack.
+type memorySizeFunc func(*stack) *big.Int
+
+// memoryMLoad is the memory required by MLOAD and MSTORE.
+func memoryMLoad(stack *stack) *big.Int {
+	return calcMemSize(stack.peek(), uint256.NewInt(32))
+}
+
+func memoryMStore8(stack *stack) *big.Int {
+	return calcMemSize(stack.peek(), uint256.NewInt(1))
+}
+
+// memoryReturn is the memory required by the operations taking an offset
+// and a size as their first arguments: RETURN, SHA3 and the LOGs.
+func memoryReturn(stack *stack) *big.Int {
+	return calcMemSize(stack.peek(), stack.back(1))
+}
+
+// memoryCopy is the memory required by CALLDATACOPY and CODECOPY.
+func memoryCopy(stack *stack) *big.Int {
+	return calcMemSize(stack.peek(), stack.back(2))
+}
+
+func memoryExtCodeCopy(stack *stack) *big.Int {
+	return calcMemSize(stack.back(1), stack.back(3))
+}
+
+// memoryCreate is the memory required by CREATE and CREATE2.
+func memoryCreate(stack *stack) *big.Int {
+	return calcMemSize(stack.back(1), stack.back(2))
+}
+
+// memoryCall is the memory required by CALL and CALLCODE.
+func memoryCall(stack *stack) *big.Int {
+	x := calcMemSize(stack.back(5), stack.back(6))
+	y := calcMemSize(stack.back(3), stack.back(4))
+
+	return common.BigMax(x, y)
+}
+
+func memoryDelegateCall(stack *stack) *big.Int {
+	x := calcMemSize(stack.back(4), stack.back(5))
+	y := calcMemSize(stack.back(2), stack.back(3))
+
+	return common.BigMax(x, y)
+}
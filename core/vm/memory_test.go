@@ -0,0 +1,48 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"bytes"
+	"testing"
+)
+
+// Tests that memory growing into the capacity left by a previous execution
+// is cleared.
+func TestMemoryResizeReuse(t *testing.T) {
+	m := newPooledMemory()
+	m.Resize(64)
+	m.Set(0, 64, bytes.Repeat([]byte{0xff}, 64))
+	releaseMemory(m)
+
+	// The pool may or may not hand the released memory out again, so check a
+	// freshly acquired one as well as one known to hold dirty capacity.
+	dirty := bytes.Repeat([]byte{0xff}, 128)
+	for _, m := range []*Memory{newPooledMemory(), {store: dirty[:0]}} {
+		if m.Len() != 0 {
+			t.Fatalf("acquired memory size mismatch: have %d, want 0", m.Len())
+		}
+		m.Resize(32)
+		if m.Len() != 32 {
+			t.Fatalf("memory size mismatch: have %d, want 32", m.Len())
+		}
+		m.Resize(96)
+		if !bytes.Equal(m.Data(), make([]byte, 96)) {
+			t.Errorf("reused memory not cleared: %x", m.Data())
+		}
+	}
+}
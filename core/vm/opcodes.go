@@ -63,6 +63,9 @@ const (
 	XOR
 	NOT
 	BYTE
+	SHL
+	SHR
+	SAR
 
 	SHA3 = 0x20
 )
@@ -82,6 +85,8 @@ const (
 	GASPRICE
 	EXTCODESIZE
 	EXTCODECOPY
+
+	EXTCODEHASH = 0x3f
 )
 
 const (
@@ -93,6 +98,8 @@ const (
 	NUMBER
 	DIFFICULTY
 	GASLIMIT
+	CHAINID
+	SELFBALANCE
 )
 
 const (
@@ -201,7 +208,9 @@ const (
 	CALLCODE
 	RETURN
 	DELEGATECALL
+	CREATE2
 
+	REVERT  = 0xfd
 	SUICIDE = 0xff
 )
 
@@ -231,6 +240,9 @@ var opCodeToString = map[OpCode]string{
 	OR:     "OR",
 	XOR:    "XOR",
 	BYTE:   "BYTE",
+	SHL:    "SHL",
+	SHR:    "SHR",
+	SAR:    "SAR",
 	ADDMOD: "ADDMOD",
 	MULMOD: "MULMOD",
 
@@ -257,8 +269,11 @@ var opCodeToString = map[OpCode]string{
 	NUMBER:      "NUMBER",
 	DIFFICULTY:  "DIFFICULTY",
 	GASLIMIT:    "GASLIMIT",
+	CHAINID:     "CHAINID",
+	SELFBALANCE: "SELFBALANCE",
 	EXTCODESIZE: "EXTCODESIZE",
 	EXTCODECOPY: "EXTCODECOPY",
+	EXTCODEHASH: "EXTCODEHASH",
 
 	// 0x50 range - 'storage' and execution
 	POP: "POP",
@@ -355,6 +370,8 @@ var opCodeToString = map[OpCode]string{
 	RETURN:       "RETURN",
 	CALLCODE:     "CALLCODE",
 	DELEGATECALL: "DELEGATECALL",
+	CREATE2:      "CREATE2",
+	REVERT:       "REVERT",
 	SUICIDE:      "SUICIDE",
 
 	PUSH: "PUSH",
@@ -393,6 +410,9 @@ var stringToOp = map[string]OpCode{
 	"OR":           OR,
 	"XOR":          XOR,
 	"BYTE":         BYTE,
+	"SHL":          SHL,
+	"SHR":          SHR,
+	"SAR":          SAR,
 	"ADDMOD":       ADDMOD,
 	"MULMOD":       MULMOD,
 	"SHA3":         SHA3,
@@ -414,8 +434,11 @@ var stringToOp = map[string]OpCode{
 	"NUMBER":       NUMBER,
 	"DIFFICULTY":   DIFFICULTY,
 	"GASLIMIT":     GASLIMIT,
+	"CHAINID":      CHAINID,
+	"SELFBALANCE":  SELFBALANCE,
 	"EXTCODESIZE":  EXTCODESIZE,
 	"EXTCODECOPY":  EXTCODECOPY,
+	"EXTCODEHASH":  EXTCODEHASH,
 	"POP":          POP,
 	"MLOAD":        MLOAD,
 	"MSTORE":       MSTORE,
@@ -501,6 +524,8 @@ var stringToOp = map[string]OpCode{
 	"CALL":         CALL,
 	"RETURN":       RETURN,
 	"CALLCODE":     CALLCODE,
+	"CREATE2":      CREATE2,
+	"REVERT":       REVERT,
 	"SUICIDE":      SUICIDE,
 }
 
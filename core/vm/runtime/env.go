@@ -40,6 +40,7 @@ type Env struct {
 	gasLimit   *big.Int
 
 	getHashFn func(uint64) common.Hash
+	tracer    vm.Tracer
 
 	evm *vm.EVM
 }
@@ -55,6 +56,7 @@ func NewEnv(cfg *Config, state *state.StateDB) vm.Environment {
 		time:       cfg.Time,
 		difficulty: cfg.Difficulty,
 		gasLimit:   cfg.GasLimit,
+		tracer:     cfg.Tracer,
 	}
 	env.evm = vm.New(env)
 
@@ -71,9 +73,13 @@ func (self *Env) Difficulty() *big.Int     { return self.difficulty }
 func (self *Env) Db() vm.Database          { return self.state }
 func (self *Env) GasLimit() *big.Int       { return self.gasLimit }
 func (self *Env) VmType() vm.Type          { return vm.StdVmTy }
+func (self *Env) Tracer() vm.Tracer        { return self.tracer }
 func (self *Env) GetHash(n uint64) common.Hash {
 	return self.getHashFn(n)
 }
+func (self *Env) OpcodeMeter() vm.OpcodeMeter {
+	return nil
+}
 func (self *Env) AddLog(log *vm.Log) {
 	self.state.AddLog(log)
 }
@@ -107,3 +113,7 @@ func (self *Env) DelegateCall(me vm.ContractRef, addr common.Address, data []byt
 func (self *Env) Create(caller vm.ContractRef, data []byte, gas, price, value *big.Int) ([]byte, common.Address, error) {
 	return core.Create(self, caller, data, gas, price, value)
 }
+
+func (self *Env) Create2(caller vm.ContractRef, data []byte, gas, price, value, salt *big.Int) ([]byte, common.Address, error) {
+	return core.Create2(self, caller, data, gas, price, value, salt)
+}
@@ -22,6 +22,7 @@ import (
 	"time"
 
 	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/core"
 	"github.com/ellaism/go-ellaism/core/state"
 	"github.com/ellaism/go-ellaism/core/vm"
 	"github.com/ellaism/go-ellaism/crypto"
@@ -32,17 +33,18 @@ import (
 type ruleSet struct{}
 
 func (ruleSet) IsHomestead(*big.Int) bool { return true }
+func (ruleSet) IsEIP161(*big.Int) bool    { return false }
+func (ruleSet) MaxCodeSize(*big.Int) int  { return 0 }
+func (ruleSet) IsEIP1014(*big.Int) bool   { return false }
+func (ruleSet) IsEIP145(*big.Int) bool    { return false }
+func (ruleSet) IsEIP1052(*big.Int) bool   { return false }
+func (ruleSet) IsEIP1344(*big.Int) bool   { return false }
+func (ruleSet) IsEIP1884(*big.Int) bool   { return false }
+func (ruleSet) IsEIP152(*big.Int) bool    { return false }
+func (ruleSet) IsEIP140(*big.Int) bool    { return false }
+func (ruleSet) ChainID() *big.Int         { return new(big.Int) }
 func (ruleSet) GasTable(*big.Int) *vm.GasTable {
-	return &vm.GasTable{
-		ExtcodeSize:     big.NewInt(700),
-		ExtcodeCopy:     big.NewInt(700),
-		Balance:         big.NewInt(400),
-		SLoad:           big.NewInt(200),
-		Calls:           big.NewInt(700),
-		Suicide:         big.NewInt(5000),
-		ExpByte:         big.NewInt(10),
-		CreateBySuicide: big.NewInt(25000),
-	}
+	return core.DefaultGasRepriceGasTable
 }
 
 // Config is a basic type specifying certain configuration flags for running
@@ -59,6 +61,7 @@ type Config struct {
 	Value       *big.Int
 	DisableJit  bool // "disable" so it's enabled by default
 	Debug       bool
+	Tracer      vm.Tracer
 
 	State     *state.StateDB
 	GetHashFn func(n uint64) common.Hash
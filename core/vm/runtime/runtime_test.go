@@ -117,6 +117,57 @@ func TestCall(t *testing.T) {
 	}
 }
 
+func TestCallTracer(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	state, _ := state.New(common.Hash{}, db)
+	callee := common.HexToAddress("0x0b")
+	state.SetCode(callee, []byte{
+		byte(vm.PUSH1), 42,
+		byte(vm.PUSH1), 0,
+		byte(vm.MSTORE),
+		byte(vm.PUSH1), 32,
+		byte(vm.PUSH1), 0,
+		byte(vm.RETURN),
+	})
+
+	tracer := vm.NewCallTracer()
+	_, _, err := Execute([]byte{
+		byte(vm.PUSH1), 32, // out size
+		byte(vm.PUSH1), 0, // out offset
+		byte(vm.PUSH1), 0, // in size
+		byte(vm.PUSH1), 0, // in offset
+		byte(vm.PUSH1), 0, // value
+		byte(vm.PUSH1), 0x0b, // address
+		byte(vm.GAS),
+		byte(vm.CALL),
+		byte(vm.STOP),
+	}, nil, &Config{State: state, GasLimit: big.NewInt(100000), Tracer: tracer})
+	if err != nil {
+		t.Fatal("didn't expect error", err)
+	}
+
+	root := tracer.Result()
+	if root == nil {
+		t.Fatal("expected a call frame to be captured")
+	}
+	if root.Type != vm.CALL || root.To != common.StringToAddress("contract") {
+		t.Errorf("root frame mismatch: have %v to %x", root.Type, root.To)
+	}
+	if root.GasUsed.Sign() == 0 || root.GasUsed.Cmp(root.Gas) > 0 {
+		t.Errorf("root gas used out of range: %v of %v", root.GasUsed, root.Gas)
+	}
+	if len(root.Calls) != 1 {
+		t.Fatalf("expected 1 internal call, got %d", len(root.Calls))
+	}
+	inner := root.Calls[0]
+	if inner.From != root.To || inner.To != callee {
+		t.Errorf("internal call mismatch: have %x -> %x", inner.From, inner.To)
+	}
+	if num := new(big.Int).SetBytes(inner.Output); num.Cmp(big.NewInt(42)) != 0 {
+		t.Errorf("expected internal call output 42, got %v", num)
+	}
+}
+
 func BenchmarkCall(b *testing.B) {
 	var definition = `[{"constant":true,"inputs":[],"name":"seller","outputs":[{"name":"","type":"address"}],"type":"function"},{"constant":false,"inputs":[],"name":"abort","outputs":[],"type":"function"},{"constant":true,"inputs":[],"name":"value","outputs":[{"name":"","type":"uint256"}],"type":"function"},{"constant":false,"inputs":[],"name":"refund","outputs":[],"type":"function"},{"constant":true,"inputs":[],"name":"buyer","outputs":[{"name":"","type":"address"}],"type":"function"},{"constant":false,"inputs":[],"name":"confirmReceived","outputs":[],"type":"function"},{"constant":true,"inputs":[],"name":"state","outputs":[{"name":"","type":"uint8"}],"type":"function"},{"constant":false,"inputs":[],"name":"confirmPurchase","outputs":[],"type":"function"},{"inputs":[],"type":"constructor"},{"anonymous":false,"inputs":[],"name":"Aborted","type":"event"},{"anonymous":false,"inputs":[],"name":"PurchaseConfirmed","type":"event"},{"anonymous":false,"inputs":[],"name":"ItemReceived","type":"event"},{"anonymous":false,"inputs":[],"name":"Refunded","type":"event"}]`
 
@@ -149,3 +200,34 @@ func BenchmarkCall(b *testing.B) {
 		}
 	}
 }
+
+func TestStructLogger(t *testing.T) {
+	logger := vm.NewStructLogger(&vm.LogConfig{DisableMemory: true})
+	_, _, err := Execute([]byte{
+		byte(vm.PUSH1), 1,
+		byte(vm.PUSH1), 2,
+		byte(vm.ADD),
+		byte(vm.STOP),
+	}, nil, &Config{GasLimit: big.NewInt(100000), Tracer: logger})
+	if err != nil {
+		t.Fatal("didn't expect error", err)
+	}
+
+	logs := logger.StructLogs()
+	if len(logs) != 4 {
+		t.Fatalf("expected 4 logs, got %d", len(logs))
+	}
+	add := logs[2]
+	if add.Op != vm.ADD || add.Pc != 4 {
+		t.Errorf("log mismatch: have %v at pc %d, want ADD at pc 4", add.Op, add.Pc)
+	}
+	if len(add.Stack) != 2 || add.Stack[0].Cmp(big.NewInt(1)) != 0 || add.Stack[1].Cmp(big.NewInt(2)) != 0 {
+		t.Errorf("stack mismatch: have %v, want [1 2]", add.Stack)
+	}
+	if add.Memory != nil {
+		t.Errorf("expected memory to be disabled, got %x", add.Memory)
+	}
+	if have, want := new(big.Int).Sub(logs[2].Gas, logs[3].Gas), add.GasCost; have.Cmp(want) != 0 {
+		t.Errorf("gas mismatch: have %v used, want %v", have, want)
+	}
+}
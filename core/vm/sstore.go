@@ -0,0 +1,131 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/ellaism/go-ellaism/common"
+)
+
+// ErrSStoreSentry is returned by the SSTOREs of frames left with no more than
+// the call stipend under EIP-2200, so that stipend funded calls can't modify
+// the state.
+var ErrSStoreSentry = errors.New("not enough gas for reentrancy sentry")
+
+// eip1283NoopGas is the cost of the SSTOREs not changing a slot, or changing a
+// slot already changed by the transaction, under EIP-1283.
+var eip1283NoopGas = big.NewInt(200)
+
+// sstoreRule prices SSTOREs from the value the slot held at the start of the
+// transaction, the value it holds and the value stored.
+//
+// The original rule only looks at the current and new values: storing a
+// non-zero value into an empty slot costs SStoreSet, any other store
+// SStoreReset, and clearing a slot refunds SStoreRefund.
+//
+// Net gas metering (EIP-1283, and EIP-2200 which supersedes it) only charges
+// the full price for the first change of a slot in a transaction, and adjusts
+// the refunds so that a slot set back to its original value costs no more
+// than a no-op overall. The original value of the slot is its committed
+// value, which is why the state is updated after every transaction.
+type sstoreRule struct {
+	net     bool // Net gas metering instead of the original rule
+	eip2200 bool // Price no-ops and dirty slots as SLoad and enforce the sentry, instead of the fixed EIP-1283 cost
+}
+
+var (
+	originalSStore = sstoreRule{}
+	eip1283SStore  = sstoreRule{net: true}
+	eip2200SStore  = sstoreRule{net: true, eip2200: true}
+)
+
+// gas returns the gas of storing value into a slot holding current, which held
+// original at the start of the transaction, and the change of the refund
+// counter, which is negative if an earlier refund of the transaction no longer
+// applies. The gas left is that of the frame before the SSTORE.
+func (r sstoreRule) gas(gt *GasTable, original, current, value common.Hash, gasLeft *big.Int) (*big.Int, *big.Int, error) {
+	if !r.net {
+		switch {
+		case common.EmptyHash(current) && !common.EmptyHash(value):
+			return gt.SStoreSet, new(big.Int), nil
+		case !common.EmptyHash(current) && common.EmptyHash(value):
+			return gt.SStoreReset, new(big.Int).Set(gt.SStoreRefund), nil
+		}
+		return gt.SStoreReset, new(big.Int), nil
+	}
+	noop := eip1283NoopGas
+	if r.eip2200 {
+		if gasLeft.Cmp(gt.CallStipend) <= 0 {
+			return nil, nil, ErrSStoreSentry
+		}
+		noop = gt.SLoad
+	}
+	refund := new(big.Int)
+	if current == value {
+		return noop, refund, nil
+	}
+	// First change of the slot in the transaction
+	if original == current {
+		if common.EmptyHash(original) {
+			return gt.SStoreSet, refund, nil
+		}
+		if common.EmptyHash(value) {
+			refund.Set(gt.SStoreRefund)
+		}
+		return gt.SStoreReset, refund, nil
+	}
+	// The slot was already changed and paid for, only the refunds change
+	if !common.EmptyHash(original) {
+		if common.EmptyHash(current) {
+			refund.Sub(refund, gt.SStoreRefund) // Recreating the cleared slot
+		} else if common.EmptyHash(value) {
+			refund.Add(refund, gt.SStoreRefund) // Clearing the slot
+		}
+	}
+	if original == value {
+		// Restoring the original value, refund what the first change paid over a no-op
+		if common.EmptyHash(original) {
+			refund.Add(refund, new(big.Int).Sub(gt.SStoreSet, noop))
+		} else {
+			refund.Add(refund, new(big.Int).Sub(gt.SStoreReset, noop))
+		}
+	}
+	return noop, refund, nil
+}
+
+// makeGasSStore returns the gas function of SSTORE under the given rule, which
+// also updates the refund counter.
+func makeGasSStore(rule sstoreRule) gasFunc {
+	return func(gt *GasTable, env Environment, contract *Contract, stack *stack, mem *Memory, memorySize *big.Int) (*big.Int, error) {
+		key, value := intToHash(stack.back(0)), intToHash(stack.back(1))
+		current := env.Db().GetState(contract.Address(), key)
+		original := current
+		if rule.net {
+			original = env.Db().GetCommittedState(contract.Address(), key)
+		}
+		gas, refund, err := rule.gas(gt, original, current, value, contract.Gas)
+		if err != nil {
+			return nil, err
+		}
+		if refund.Sign() != 0 {
+			env.Db().AddRefund(refund)
+		}
+		return gas, nil
+	}
+}
@@ -0,0 +1,231 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/ellaism/go-ellaism/common"
+)
+
+const (
+	sstoreSet   = 20000
+	sstoreReset = 5000
+	sstoreClear = 15000
+	sloadGas    = 800
+	callStipend = 2300
+)
+
+// sstoreGasTable holds the SSTORE related costs of the Istanbul gas table.
+var sstoreGasTable = &GasTable{
+	SLoad:        big.NewInt(sloadGas),
+	SStoreSet:    big.NewInt(sstoreSet),
+	SStoreReset:  big.NewInt(sstoreReset),
+	SStoreRefund: big.NewInt(sstoreClear),
+	CallStipend:  big.NewInt(callStipend),
+}
+
+// sstoreTransition is a single SSTORE and the gas and refund it causes.
+type sstoreTransition struct {
+	original, current, value byte
+	gas, refund              int64
+}
+
+// checkSStoreTransitions checks the gas and refund of every given transition
+// under the rule.
+func checkSStoreTransitions(t *testing.T, name string, rule sstoreRule, transitions []sstoreTransition) {
+	for _, tt := range transitions {
+		gas, refund, err := rule.gas(sstoreGasTable, common.Hash{31: tt.original}, common.Hash{31: tt.current}, common.Hash{31: tt.value}, big.NewInt(1000000))
+		if err != nil {
+			t.Errorf("%s %d→%d→%d: %v", name, tt.original, tt.current, tt.value, err)
+			continue
+		}
+		if gas.Int64() != tt.gas || refund.Int64() != tt.refund {
+			t.Errorf("%s %d→%d→%d: have gas %v refund %v, want gas %d refund %d", name, tt.original, tt.current, tt.value, gas, refund, tt.gas, tt.refund)
+		}
+	}
+}
+
+// Tests the original SSTORE pricing on every transition between a zero and two
+// non-zero values. The original value of the slot doesn't matter.
+func TestOriginalSStoreTransitions(t *testing.T) {
+	var transitions []sstoreTransition
+	for original := byte(0); original < 3; original++ {
+		transitions = append(transitions, []sstoreTransition{
+			{original, 0, 0, sstoreReset, 0},
+			{original, 0, 1, sstoreSet, 0},
+			{original, 0, 2, sstoreSet, 0},
+			{original, 1, 0, sstoreReset, sstoreClear},
+			{original, 1, 1, sstoreReset, 0},
+			{original, 1, 2, sstoreReset, 0},
+			{original, 2, 0, sstoreReset, sstoreClear},
+			{original, 2, 1, sstoreReset, 0},
+			{original, 2, 2, sstoreReset, 0},
+		}...)
+	}
+	checkSStoreTransitions(t, "original", originalSStore, transitions)
+}
+
+// netSStoreTransitions returns every transition between a zero and two non-zero
+// original, current and new values under net gas metering, with no-ops and
+// changes of dirty slots costing noop.
+func netSStoreTransitions(noop int64) []sstoreTransition {
+	return []sstoreTransition{
+		{0, 0, 0, noop, 0},
+		{0, 0, 1, sstoreSet, 0},
+		{0, 0, 2, sstoreSet, 0},
+		{0, 1, 0, noop, sstoreSet - noop},
+		{0, 1, 1, noop, 0},
+		{0, 1, 2, noop, 0},
+		{0, 2, 0, noop, sstoreSet - noop},
+		{0, 2, 1, noop, 0},
+		{0, 2, 2, noop, 0},
+
+		{1, 0, 0, noop, 0},
+		{1, 0, 1, noop, sstoreReset - noop - sstoreClear},
+		{1, 0, 2, noop, -sstoreClear},
+		{1, 1, 0, sstoreReset, sstoreClear},
+		{1, 1, 1, noop, 0},
+		{1, 1, 2, sstoreReset, 0},
+		{1, 2, 0, noop, sstoreClear},
+		{1, 2, 1, noop, sstoreReset - noop},
+		{1, 2, 2, noop, 0},
+
+		{2, 0, 0, noop, 0},
+		{2, 0, 1, noop, -sstoreClear},
+		{2, 0, 2, noop, sstoreReset - noop - sstoreClear},
+		{2, 1, 0, noop, sstoreClear},
+		{2, 1, 1, noop, 0},
+		{2, 1, 2, noop, sstoreReset - noop},
+		{2, 2, 0, sstoreReset, sstoreClear},
+		{2, 2, 1, sstoreReset, 0},
+		{2, 2, 2, noop, 0},
+	}
+}
+
+func TestEIP1283SStoreTransitions(t *testing.T) {
+	checkSStoreTransitions(t, "EIP-1283", eip1283SStore, netSStoreTransitions(200))
+}
+
+func TestEIP2200SStoreTransitions(t *testing.T) {
+	checkSStoreTransitions(t, "EIP-2200", eip2200SStore, netSStoreTransitions(sloadGas))
+}
+
+// sstoreSequence is a sequence of SSTOREs to a single slot within a transaction,
+// with the gas and refund of the code storing them, from the test cases of the
+// EIPs. The code pushes the value and key of every SSTORE, 6 gas each.
+type sstoreSequence struct {
+	original byte
+	values   []byte
+	used     int64
+	refund   int64
+}
+
+// checkSStoreSequences runs every sequence under the rule and checks the gas
+// and refund they add up to.
+func checkSStoreSequences(t *testing.T, name string, rule sstoreRule, sequences []sstoreSequence) {
+	for _, tt := range sequences {
+		var (
+			original = common.Hash{31: tt.original}
+			current  = original
+			used     = int64(6 * len(tt.values))
+			refund   int64
+		)
+		for _, v := range tt.values {
+			value := common.Hash{31: v}
+			gas, r, err := rule.gas(sstoreGasTable, original, current, value, big.NewInt(1000000))
+			if err != nil {
+				t.Fatalf("%s %d%v: %v", name, tt.original, tt.values, err)
+			}
+			used, refund, current = used+gas.Int64(), refund+r.Int64(), value
+		}
+		if used != tt.used || refund != tt.refund {
+			t.Errorf("%s %d%v: have used %d refund %d, want used %d refund %d", name, tt.original, tt.values, used, refund, tt.used, tt.refund)
+		}
+	}
+}
+
+func TestEIP1283SStoreSequences(t *testing.T) {
+	checkSStoreSequences(t, "EIP-1283", eip1283SStore, []sstoreSequence{
+		{0, []byte{0, 0}, 412, 0},
+		{0, []byte{0, 1}, 20212, 0},
+		{0, []byte{1, 0}, 20212, 19800},
+		{0, []byte{1, 2}, 20212, 0},
+		{0, []byte{1, 1}, 20212, 0},
+		{1, []byte{0, 0}, 5212, 15000},
+		{1, []byte{0, 1}, 5212, 4800},
+		{1, []byte{0, 2}, 5212, 0},
+		{1, []byte{2, 0}, 5212, 15000},
+		{1, []byte{2, 3}, 5212, 0},
+		{1, []byte{2, 1}, 5212, 4800},
+		{1, []byte{2, 2}, 5212, 0},
+		{1, []byte{1, 0}, 5212, 15000},
+		{1, []byte{1, 2}, 5212, 0},
+		{1, []byte{1, 1}, 412, 0},
+		{0, []byte{1, 0, 1}, 40218, 19800},
+		{1, []byte{0, 1, 0}, 10218, 19800},
+	})
+}
+
+func TestEIP2200SStoreSequences(t *testing.T) {
+	checkSStoreSequences(t, "EIP-2200", eip2200SStore, []sstoreSequence{
+		{0, []byte{0, 0}, 1612, 0},
+		{0, []byte{0, 1}, 20812, 0},
+		{0, []byte{1, 0}, 20812, 19200},
+		{0, []byte{1, 2}, 20812, 0},
+		{0, []byte{1, 1}, 20812, 0},
+		{1, []byte{0, 0}, 5812, 15000},
+		{1, []byte{0, 1}, 5812, 4200},
+		{1, []byte{0, 2}, 5812, 0},
+		{1, []byte{2, 0}, 5812, 15000},
+		{1, []byte{2, 3}, 5812, 0},
+		{1, []byte{2, 1}, 5812, 4200},
+		{1, []byte{2, 2}, 5812, 0},
+		{1, []byte{1, 0}, 5812, 15000},
+		{1, []byte{1, 2}, 5812, 0},
+		{1, []byte{1, 1}, 1612, 0},
+		{0, []byte{1, 0, 1}, 40818, 19200},
+		{1, []byte{0, 1, 0}, 10818, 19200},
+	})
+}
+
+// Tests that EIP-2200 refuses SSTOREs to frames left with no more than the call
+// stipend, while the other rules don't look at the gas left.
+func TestSStoreSentry(t *testing.T) {
+	zero, one := common.Hash{}, common.Hash{31: 1}
+	for _, tt := range []struct {
+		rule    sstoreRule
+		gasLeft int64
+		fails   bool
+	}{
+		{originalSStore, callStipend, false},
+		{eip1283SStore, callStipend, false},
+		{eip2200SStore, callStipend, true},
+		{eip2200SStore, callStipend + 1, false},
+	} {
+		name := fmt.Sprintf("%+v with %d gas left", tt.rule, tt.gasLeft)
+		_, _, err := tt.rule.gas(sstoreGasTable, zero, zero, one, big.NewInt(tt.gasLeft))
+		if tt.fails && err != ErrSStoreSentry {
+			t.Errorf("%s: error mismatch: have %v, want %v", name, err, ErrSStoreSentry)
+		}
+		if !tt.fails && err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+		}
+	}
+}
@@ -19,31 +19,54 @@ package vm
 import (
 	"fmt"
 	"math/big"
+	"sync"
+
+	"github.com/ellaism/go-ellaism/common/uint256"
 )
 
-// stack is an object for basic stack operations. Items popped to the stack are
-// expected to be changed and modified. stack does not take care of adding newly
-// initialised objects.
+// stackPool holds the stacks released by finished executions.
+var stackPool = sync.Pool{
+	New: func() interface{} { return &stack{data: make([]uint256.Int, 0, 16)} },
+}
+
+// stack is an object for basic stack operations. Items are held by value, so
+// pushing copies them and the pointers returned by peek and back are only
+// valid until the stack is next modified.
 type stack struct {
-	data []*big.Int
+	data []uint256.Int
 }
 
+// newstack returns an empty stack, reusing a released one if possible.
 func newstack() *stack {
-	return &stack{}
+	return stackPool.Get().(*stack)
+}
+
+// releaseStack returns the stack to the pool. It may not be used afterwards.
+func releaseStack(st *stack) {
+	st.data = st.data[:0]
+	stackPool.Put(st)
 }
 
-func (st *stack) Data() []*big.Int {
+func (st *stack) Data() []uint256.Int {
 	return st.data
 }
 
-func (st *stack) push(d *big.Int) {
-	// NOTE push limit (1024) is checked in baseCheck
-	//stackItem := new(big.Int).Set(d)
-	//st.data = append(st.data, stackItem)
-	st.data = append(st.data, d)
+// toBig returns a copy of the stack items as big integers, as handed to the
+// tracers.
+func (st *stack) toBig() []*big.Int {
+	items := make([]*big.Int, len(st.data))
+	for i := range st.data {
+		items[i] = st.data[i].ToBig()
+	}
+	return items
+}
+
+func (st *stack) push(d *uint256.Int) {
+	// NOTE push limit (1024) is checked by the stack validation of the operations
+	st.data = append(st.data, *d)
 }
 
-func (st *stack) pop() (ret *big.Int) {
+func (st *stack) pop() (ret uint256.Int) {
 	ret = st.data[len(st.data)-1]
 	st.data = st.data[:len(st.data)-1]
 	return
@@ -58,11 +81,16 @@ func (st *stack) swap(n int) {
 }
 
 func (st *stack) dup(n int) {
-	st.push(new(big.Int).Set(st.data[st.len()-n]))
+	st.push(&st.data[st.len()-n])
+}
+
+func (st *stack) peek() *uint256.Int {
+	return &st.data[st.len()-1]
 }
 
-func (st *stack) peek() *big.Int {
-	return st.data[st.len()-1]
+// back returns the n'th item from the top of the stack, back(0) being the top.
+func (st *stack) back(n int) *uint256.Int {
+	return &st.data[st.len()-n-1]
 }
 
 func (st *stack) require(n int) error {
@@ -76,7 +104,7 @@ func (st *stack) Print() {
 	fmt.Println("### stack ###")
 	if len(st.data) > 0 {
 		for i, val := range st.data {
-			fmt.Printf("%-3d  %v\n", i, val)
+			fmt.Printf("%-3d  %v\n", i, val.ToBig())
 		}
 	} else {
 		fmt.Println("-- empty --")
@@ -0,0 +1,58 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import "fmt"
+
+// stackValidationFunc checks the stack holds the items an operation pops
+// and has room for the ones it pushes.
+type stackValidationFunc func(*stack) error
+
+// StackLimitError aborts a frame whose next operation would push the stack over
+// its limit. The EVM fills in the operation and its position in the code.
+type StackLimitError struct {
+	Len int    // Length of the stack before the operation
+	Op  OpCode // Operation that would have overflown the stack
+	Pc  uint64 // Position of the operation in the code
+}
+
+func (e *StackLimitError) Error() string {
+	return fmt.Sprintf("stack length %d exceed limit %d", e.Len, stackLimit)
+}
+
+// Limit implements LimitError.
+func (e *StackLimitError) Limit() string { return "stack" }
+
+func makeStackFunc(pop, push int) stackValidationFunc {
+	return func(stack *stack) error {
+		if err := stack.require(pop); err != nil {
+			return err
+		}
+		if push > 0 && stack.len()-pop+push > stackLimit {
+			return &StackLimitError{Len: stack.len()}
+		}
+		return nil
+	}
+}
+
+func makeDupStackFunc(n int) stackValidationFunc {
+	return makeStackFunc(n, n+1)
+}
+
+func makeSwapStackFunc(n int) stackValidationFunc {
+	return makeStackFunc(n, n)
+}
@@ -0,0 +1,149 @@
+// Copyright 2016 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ellaism/go-ellaism/common"
+)
+
+// Tracer is used to collect execution traces from an EVM transaction
+// execution. CaptureEnter is called when a new message call, contract
+// creation or suicide is started and CaptureExit once it has finished.
+// CaptureState is called for every opcode executed, once its cost has
+// been deducted from the available gas. The memory and stack must not be
+// modified nor retained by the tracer.
+type Tracer interface {
+	CaptureEnter(typ OpCode, from, to common.Address, input []byte, gas, value *big.Int)
+	CaptureState(env Environment, pc uint64, op OpCode, gas, cost *big.Int, memory *Memory, stack []*big.Int, contract *Contract, depth int)
+	CaptureExit(output []byte, gasUsed *big.Int, err error)
+}
+
+// OpcodeMeter is notified of the gas used and the time taken by every opcode
+// the EVM executes successfully. Unlike a Tracer it gets no access to the
+// execution state, which keeps the overhead low enough to meter every block.
+//
+// The gas and time of an opcode calling into another contract include those
+// of the opcodes run by the callee, which are reported at depth+1 first.
+type OpcodeMeter interface {
+	MeterOpcode(op OpCode, depth int, gas *big.Int, elapsed time.Duration)
+}
+
+// LimitError is implemented by the errors aborting a frame because it hit one of
+// the limits of the EVM rather than running out of gas or failing otherwise.
+type LimitError interface {
+	error
+	Limit() string // Name of the limit hit: "callDepth" or "stack"
+}
+
+// CallFrame is a single message call, contract creation or suicide
+// captured by the CallTracer, including any calls it made itself.
+type CallFrame struct {
+	Type    OpCode
+	From    common.Address
+	To      common.Address
+	Value   *big.Int
+	Gas     *big.Int
+	GasUsed *big.Int
+	Input   []byte
+	Output  []byte
+	Error   error
+	Limit   string // Limit of the EVM which aborted the frame, if any
+	Calls   []*CallFrame
+}
+
+func (f *CallFrame) MarshalJSON() ([]byte, error) {
+	fields := map[string]interface{}{
+		"type":    f.Type.String(),
+		"from":    f.From,
+		"to":      f.To,
+		"value":   fmt.Sprintf("%#x", f.Value),
+		"gas":     fmt.Sprintf("%#x", f.Gas),
+		"gasUsed": fmt.Sprintf("%#x", f.GasUsed),
+		"input":   fmt.Sprintf("0x%x", f.Input),
+		"output":  fmt.Sprintf("0x%x", f.Output),
+	}
+	if f.Error != nil {
+		fields["error"] = f.Error.Error()
+	}
+	if f.Limit != "" {
+		fields["limit"] = f.Limit
+	}
+	if len(f.Calls) > 0 {
+		fields["calls"] = f.Calls
+	}
+	return json.Marshal(fields)
+}
+
+// CallTracer is a Tracer which records the tree of calls made during the
+// execution of a transaction, typically used to list internal transactions.
+type CallTracer struct {
+	root  *CallFrame
+	stack []*CallFrame
+}
+
+// NewCallTracer returns a new, empty call tracer.
+func NewCallTracer() *CallTracer {
+	return &CallTracer{}
+}
+
+func (t *CallTracer) CaptureEnter(typ OpCode, from, to common.Address, input []byte, gas, value *big.Int) {
+	frame := &CallFrame{
+		Type:    typ,
+		From:    from,
+		To:      to,
+		Value:   new(big.Int).Set(value),
+		Gas:     new(big.Int).Set(gas),
+		GasUsed: new(big.Int),
+		Input:   common.CopyBytes(input),
+	}
+	if len(t.stack) == 0 {
+		t.root = frame
+	} else {
+		parent := t.stack[len(t.stack)-1]
+		parent.Calls = append(parent.Calls, frame)
+	}
+	t.stack = append(t.stack, frame)
+}
+
+func (t *CallTracer) CaptureState(env Environment, pc uint64, op OpCode, gas, cost *big.Int, memory *Memory, stack []*big.Int, contract *Contract, depth int) {
+}
+
+func (t *CallTracer) CaptureExit(output []byte, gasUsed *big.Int, err error) {
+	if len(t.stack) == 0 {
+		return
+	}
+	frame := t.stack[len(t.stack)-1]
+	t.stack = t.stack[:len(t.stack)-1]
+
+	frame.Output = common.CopyBytes(output)
+	frame.GasUsed.Set(gasUsed)
+	frame.Error = err
+	if limitErr, ok := err.(LimitError); ok {
+		frame.Limit = limitErr.Limit()
+	}
+}
+
+// Result returns the outermost call captured by the tracer, or nil if
+// nothing has been executed yet.
+func (t *CallTracer) Result() *CallFrame {
+	return t.root
+}
@@ -17,19 +17,26 @@
 package core
 
 import (
+	"fmt"
 	"math/big"
 
 	"github.com/ellaism/go-ellaism/common"
 	"github.com/ellaism/go-ellaism/core/state"
 	"github.com/ellaism/go-ellaism/core/types"
 	"github.com/ellaism/go-ellaism/core/vm"
+	"github.com/ellaism/go-ellaism/logger"
+	"github.com/ellaism/go-ellaism/logger/glog"
+	"github.com/ellaism/go-ellaism/metrics"
 )
 
 // GetHashFn returns a function for which the VM env can query block hashes through
 // up to the limit defined by the Yellow Paper and uses the given block chain
-// to query for information.
+// to query for information. Without a chain all block hashes are unknown.
 func GetHashFn(ref common.Hash, chain *BlockChain) func(n uint64) common.Hash {
 	return func(n uint64) common.Hash {
+		if chain == nil {
+			return common.Hash{}
+		}
 		for block := chain.GetBlock(ref); block != nil; block = chain.GetBlock(block.ParentHash()) {
 			if block.NumberU64() == n {
 				return block.Hash()
@@ -46,6 +53,9 @@ type VMEnv struct {
 	evm         *vm.EVM        // The Ethereum Virtual Machine
 	depth       int            // Current execution depth
 	msg         Message        // Message appliod
+	tracer      vm.Tracer      // Optional execution tracer
+	meter       vm.OpcodeMeter // Optional opcode meter
+	limits      *limitAborts   // Optional counter of the frames aborted by the EVM limits
 
 	header    *types.Header            // Header information
 	chain     *BlockChain              // Blockchain handle
@@ -78,9 +88,69 @@ func (self *VMEnv) Value() *big.Int          { return self.msg.Value() }
 func (self *VMEnv) Db() vm.Database          { return self.state }
 func (self *VMEnv) Depth() int               { return self.depth }
 func (self *VMEnv) SetDepth(i int)           { self.depth = i }
+func (self *VMEnv) Tracer() vm.Tracer        { return self.tracer }
 func (self *VMEnv) GetHash(n uint64) common.Hash {
 	return self.getHashFn(n)
 }
+func (self *VMEnv) OpcodeMeter() vm.OpcodeMeter {
+	return self.meter
+}
+
+// SetTracer sets the tracer collecting execution traces of the messages
+// applied in this environment.
+func (self *VMEnv) SetTracer(tracer vm.Tracer) {
+	self.tracer = tracer
+}
+
+// SetOpcodeMeter sets the meter measuring the opcodes executed by the messages
+// applied in this environment.
+func (self *VMEnv) SetOpcodeMeter(meter vm.OpcodeMeter) {
+	self.meter = meter
+}
+
+// reportLimit implements limitReporter, logging a frame aborted by a limit of
+// the EVM and counting it if the aborts of the block are counted.
+func (self *VMEnv) reportLimit(err vm.LimitError, contract common.Address) {
+	if glog.V(logger.Debug) {
+		var location, origin string
+		if stackErr, ok := err.(*vm.StackLimitError); ok {
+			location = fmt.Sprintf(" at pc %d (%v)", stackErr.Pc, stackErr.Op)
+		}
+		if tx, ok := self.msg.(*types.Transaction); ok {
+			origin = fmt.Sprintf(" of tx %s", tx.Hash().Hex())
+		}
+		glog.Infof("EVM %s limit hit by %s%s at depth %d%s in block #%v", err.Limit(), contract.Hex(), location, self.depth, origin, self.header.Number)
+	}
+	if self.limits != nil {
+		self.limits.count(err)
+	}
+}
+
+// limitAborts counts the frames of a block aborted by the limits of the EVM.
+type limitAborts struct {
+	callDepth int
+	stack     int
+}
+
+// count accounts for a frame aborted with the given limit error.
+func (l *limitAborts) count(err vm.LimitError) {
+	switch err.Limit() {
+	case "callDepth":
+		l.callDepth++
+	case "stack":
+		l.stack++
+	}
+}
+
+// report adds the aborts of the block to the metrics registry and logs them.
+func (l *limitAborts) report(block *types.Block) {
+	if l.callDepth == 0 && l.stack == 0 {
+		return
+	}
+	metrics.EVMCallDepthLimit.Mark(int64(l.callDepth))
+	metrics.EVMStackLimit.Mark(int64(l.stack))
+	glog.V(logger.Debug).Infof("Block #%v [%s]: %d frames aborted by the call depth limit, %d by the stack limit", block.Number(), block.Hash().Hex(), l.callDepth, l.stack)
+}
 
 func (self *VMEnv) AddLog(log *vm.Log) {
 	self.state.AddLog(log)
@@ -115,3 +185,7 @@ func (self *VMEnv) DelegateCall(me vm.ContractRef, addr common.Address, data []b
 func (self *VMEnv) Create(me vm.ContractRef, data []byte, gas, price, value *big.Int) ([]byte, common.Address, error) {
 	return Create(self, me, data, gas, price, value)
 }
+
+func (self *VMEnv) Create2(me vm.ContractRef, data []byte, gas, price, value, salt *big.Int) ([]byte, common.Address, error) {
+	return Create2(self, me, data, gas, price, value, salt)
+}
@@ -65,6 +65,12 @@ func CreateAddress(b common.Address, nonce uint64) common.Address {
 	return common.BytesToAddress(Keccak256(data)[12:])
 }
 
+// Creates an ethereum address given the bytes, a salt and the hash of the
+// contract creation code, as done by the CREATE2 opcode (EIP-1014)
+func CreateAddress2(b common.Address, salt [32]byte, inithash []byte) common.Address {
+	return common.BytesToAddress(Keccak256([]byte{0xff}, b.Bytes(), salt[:], inithash)[12:])
+}
+
 func Sha256(data []byte) []byte {
 	hash := sha256.Sum256(data)
 
@@ -138,7 +144,12 @@ func LoadECDSA(file string) (*ecdsa.PrivateKey, error) {
 		return nil, err
 	}
 	defer fd.Close()
-	if _, err := io.ReadFull(fd, buf); err != nil {
+	n, err := io.ReadFull(fd, buf)
+	if err == io.ErrUnexpectedEOF && n > 0 {
+		// Keys saved unpadded lack their leading zero bytes
+		buf, err = buf[:n], nil
+	}
+	if err != nil {
 		return nil, err
 	}
 
@@ -153,7 +164,7 @@ func LoadECDSA(file string) (*ecdsa.PrivateKey, error) {
 // SaveECDSA saves a secp256k1 private key to the given file with
 // restrictive permissions. The key data is saved hex-encoded.
 func SaveECDSA(file string, key *ecdsa.PrivateKey) error {
-	k := hex.EncodeToString(FromECDSA(key))
+	k := hex.EncodeToString(common.LeftPadBytes(FromECDSA(key), 32))
 	return ioutil.WriteFile(file, []byte(k), 0600)
 }
 
@@ -137,6 +137,22 @@ func TestNewContractAddress(t *testing.T) {
 	checkAddr(t, common.HexToAddress("c9ddedf451bc62ce88bf9292afb13df35b670699"), caddr2)
 }
 
+// Tests CreateAddress2 against the examples of EIP-1014.
+func TestCreateAddress2(t *testing.T) {
+	for _, tt := range []struct {
+		addr, salt, code, want string
+	}{
+		{"0x0000000000000000000000000000000000000000", "0x00", "0x00", "0x4D1A2e2bB4F88F0250f26Ffff098B0b30B26BF38"},
+		{"0xdeadbeef00000000000000000000000000000000", "0x00", "0x00", "0xB928f69Bb1D91Cd65274e3c79d8986362984fDA3"},
+		{"0xdeadbeef00000000000000000000000000000000", "0x000000000000000000000000feed000000000000000000000000000000000000", "0x00", "0xD04116cDd17beBE565EB2422F2497E06cC1C9833"},
+		{"0x0000000000000000000000000000000000000000", "0x00", "0x", "0xE33C0C7F7df4809055C3ebA6c09CFe4BaF1BD9e0"},
+		{"0x00000000000000000000000000000000deadbeef", "0x00000000000000000000000000000000000000000000000000000000cafebabe", "0xdeadbeef", "0x60f3f640a8508fC6a86d45DF051962668E1e8AC7"},
+	} {
+		have := CreateAddress2(common.HexToAddress(tt.addr), common.HexToHash(tt.salt), Keccak256(common.FromHex(tt.code)))
+		checkAddr(t, common.HexToAddress(tt.want), have)
+	}
+}
+
 func TestLoadECDSAFile(t *testing.T) {
 	keyBytes := common.FromHex(testPrivHex)
 	fileName0 := "test_key0"
@@ -172,6 +188,31 @@ func TestLoadECDSAFile(t *testing.T) {
 	checkKey(key1)
 }
 
+// Tests that keys with leading zero bytes are saved padded, and that those saved
+// unpadded load as well.
+func TestSaveECDSAPadding(t *testing.T) {
+	key := ToECDSA(common.FromHex("00" + testPrivHex[2:]))
+	fileName := "test_key_padded"
+	defer os.Remove(fileName)
+
+	if err := SaveECDSA(fileName, key); err != nil {
+		t.Fatal(err)
+	}
+	if blob, _ := ioutil.ReadFile(fileName); len(blob) != 64 {
+		t.Errorf("saved key length mismatch: have %d, want 64", len(blob))
+	}
+	for _, blob := range []string{"00" + testPrivHex[2:], testPrivHex[2:]} {
+		ioutil.WriteFile(fileName, []byte(blob), 0600)
+		loaded, err := LoadECDSA(fileName)
+		if err != nil {
+			t.Fatalf("failed to load key %s: %v", blob, err)
+		}
+		if loaded.D.Cmp(key.D) != 0 {
+			t.Errorf("loaded key %s mismatch: have %x, want %x", blob, loaded.D, key.D)
+		}
+	}
+}
+
 func TestValidateSignatureValues(t *testing.T) {
 	check := func(expected bool, v byte, r, s *big.Int) {
 		if ValidateSignatureValues(v, r, s, false) != expected {
@@ -24,13 +24,16 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"math/big"
 	"os"
+	"reflect"
 	"runtime"
 	"sync"
 	"time"
 
 	"github.com/ellaism/go-ellaism/accounts"
+	"github.com/ellaism/go-ellaism/accounts/abi"
 	"github.com/ellaism/go-ellaism/common"
 	"github.com/ellaism/go-ellaism/common/compiler"
 	"github.com/ellaism/go-ellaism/core"
@@ -38,6 +41,7 @@ import (
 	"github.com/ellaism/go-ellaism/core/types"
 	"github.com/ellaism/go-ellaism/core/vm"
 	"github.com/ellaism/go-ellaism/crypto"
+	"github.com/ellaism/go-ellaism/eth/names"
 	"github.com/ellaism/go-ellaism/ethdb"
 	"github.com/ellaism/go-ellaism/event"
 	"github.com/ellaism/go-ellaism/logger"
@@ -47,6 +51,7 @@ import (
 	"github.com/ellaism/go-ellaism/p2p"
 	"github.com/ellaism/go-ellaism/rlp"
 	"github.com/ellaism/go-ellaism/rpc"
+	"github.com/ellaism/go-ellaism/trie"
 	"github.com/ethereumproject/ethash"
 )
 
@@ -54,9 +59,11 @@ const defaultGas = uint64(90000)
 
 // blockByNumber is a commonly used helper function which retrieves and returns
 // the block for the given block number, capable of handling two special blocks:
-// rpc.LatestBlockNumber and rpc.PendingBlockNumber. It returns nil when no block
-// could be found.
+// rpc.LatestBlockNumber and rpc.PendingBlockNumber, as well as the confirmation
+// tags. It returns nil when no block could be found.
 func blockByNumber(m *miner.Miner, bc *core.BlockChain, blockNr rpc.BlockNumber) *types.Block {
+	blockNr = resolveBlockNumber(bc, blockNr)
+
 	// Pending block is only known by the miner
 	if blockNr == rpc.PendingBlockNumber {
 		block, _ := m.Pending()
@@ -69,10 +76,35 @@ func blockByNumber(m *miner.Miner, bc *core.BlockChain, blockNr rpc.BlockNumber)
 	return bc.GetBlockByNumber(uint64(blockNr))
 }
 
+// headerByNumber is a commonly used helper function which retrieves and returns
+// the header for the given block number, capable of handling two special blocks:
+// rpc.LatestBlockNumber, resolved to the head header like the reported block
+// number, and rpc.PendingBlockNumber, as well as the confirmation tags. It
+// returns nil when no header could be found.
+func headerByNumber(m *miner.Miner, bc *core.BlockChain, blockNr rpc.BlockNumber) *types.Header {
+	switch blockNr = resolveBlockNumber(bc, blockNr); blockNr {
+	case rpc.PendingBlockNumber:
+		if block, _ := m.Pending(); block != nil {
+			return block.Header()
+		}
+		return nil
+	case rpc.LatestBlockNumber:
+		return bc.CurrentHeader()
+	}
+	return bc.GetHeaderByNumber(uint64(blockNr))
+}
+
+// resolveBlockNumber resolves the "safe" and "confirmed" tags of a block number
+// against the head of the fully imported chain.
+func resolveBlockNumber(bc *core.BlockChain, blockNr rpc.BlockNumber) rpc.BlockNumber {
+	return blockNr.Resolve(bc.CurrentBlock().NumberU64())
+}
+
 // stateAndBlockByNumber is a commonly used helper function which retrieves and
 // returns the state and containing block for the given block number, capable of
 // handling two special states: rpc.LatestBlockNumber and rpc.PendingBlockNumber.
-// It returns nil when no block or state could be found.
+// It returns nil when no block could be found, and a statePrunedError if the
+// state of the block isn't retained.
 func stateAndBlockByNumber(m *miner.Miner, bc *core.BlockChain, blockNr rpc.BlockNumber, chainDb ethdb.Database) (*state.StateDB, *types.Block, error) {
 	// Pending state is only known by the miner
 	if blockNr == rpc.PendingBlockNumber {
@@ -84,10 +116,57 @@ func stateAndBlockByNumber(m *miner.Miner, bc *core.BlockChain, blockNr rpc.Bloc
 	if block == nil {
 		return nil, nil, nil
 	}
-	stateDb, err := state.New(block.Root(), chainDb)
+	stateDb, err := stateAtBlock(bc, block)
 	return stateDb, block, err
 }
 
+// stateAtBlock returns the state of the given block, or a statePrunedError if
+// the node doesn't retain it.
+func stateAtBlock(bc *core.BlockChain, block *types.Block) (*state.StateDB, error) {
+	stateDb, err := bc.StateAt(block.Root())
+	if _, missing := err.(*trie.MissingNodeError); missing {
+		return nil, &statePrunedError{number: block.NumberU64(), available: availableStates(bc)}
+	}
+	return stateDb, err
+}
+
+// AvailableStates is the range of canonical blocks whose state the node
+// retains, besides the genesis block whose state is always retained.
+type AvailableStates struct {
+	Oldest     *rpc.HexNumber `json:"oldest"`
+	OldestHash common.Hash    `json:"oldestHash"`
+	Latest     *rpc.HexNumber `json:"latest"`
+	LatestHash common.Hash    `json:"latestHash"`
+}
+
+func availableStates(bc *core.BlockChain) *AvailableStates {
+	oldest, latest := bc.OldestState(), bc.CurrentBlock()
+	return &AvailableStates{
+		Oldest:     rpc.NewHexNumber(oldest.Number()),
+		OldestHash: oldest.Hash(),
+		Latest:     rpc.NewHexNumber(latest.Number()),
+		LatestHash: latest.Hash(),
+	}
+}
+
+// statePrunedError is returned by the queries of the state of a block the node
+// doesn't retain the state of. Its data is the range of the retained states,
+// telling it apart from a state holding no data.
+type statePrunedError struct {
+	number    uint64
+	available *AvailableStates
+}
+
+func (e *statePrunedError) Error() string {
+	return fmt.Sprintf("state pruned at block #%d, the oldest available state is at block #%d", e.number, e.available.Oldest.Uint64())
+}
+
+// Code returns the JSON-RPC error code of queries of states not retained.
+func (e *statePrunedError) Code() int { return -32001 }
+
+// ErrorData returns the range of the retained states.
+func (e *statePrunedError) ErrorData() interface{} { return e.available }
+
 // PublicEthereumAPI provides an API to access Ethereum related information.
 // It offers only methods that operate on public data that is freely available to anyone.
 type PublicEthereumAPI struct {
@@ -132,14 +211,19 @@ func (s *PublicEthereumAPI) CompileSolidity(source string) (map[string]*compiler
 	return solc.Compile(source)
 }
 
-// Etherbase is the address that mining rewards will be send to
-func (s *PublicEthereumAPI) Etherbase() (common.Address, error) {
+// Etherbase is the address that mining rewards will be send to. If none is set,
+// the first account is reported, except to callers over HTTP and WebSocket
+// unless the accounts are exposed to them, as eth_accounts hides it.
+func (s *PublicEthereumAPI) Etherbase(ctx context.Context) (common.Address, error) {
+	if !s.e.remoteAccounts && rpc.IsRemoteTransport(rpc.TransportFromContext(ctx)) && s.e.etherbase == (common.Address{}) {
+		return common.Address{}, errNoEtherbase
+	}
 	return s.e.Etherbase()
 }
 
 // Coinbase is the address that mining rewards will be send to (alias for Etherbase)
-func (s *PublicEthereumAPI) Coinbase() (common.Address, error) {
-	return s.Etherbase()
+func (s *PublicEthereumAPI) Coinbase(ctx context.Context) (common.Address, error) {
+	return s.Etherbase(ctx)
 }
 
 // ProtocolVersion returns the current Ethereum protocol version this node supports
@@ -188,8 +272,10 @@ func (s *PublicEthereumAPI) ChainId() *big.Int {
 // PublicMinerAPI provides an API to control the miner.
 // It offers only methods that operate on data that pose no security risk when it is publicly accessible.
 type PublicMinerAPI struct {
-	e     *Ethereum
-	agent *miner.RemoteAgent
+	e                *Ethereum
+	agent            *miner.RemoteAgent
+	muMinedBlockSubs sync.Mutex
+	minedBlockSubs   map[string]rpc.Subscription
 }
 
 // NewPublicMinerAPI create a new PublicMinerAPI instance.
@@ -197,7 +283,30 @@ func NewPublicMinerAPI(e *Ethereum) *PublicMinerAPI {
 	agent := miner.NewRemoteAgent()
 	e.Miner().Register(agent)
 
-	return &PublicMinerAPI{e, agent}
+	api := &PublicMinerAPI{
+		e:              e,
+		agent:          agent,
+		minedBlockSubs: make(map[string]rpc.Subscription),
+	}
+	go api.subscriptionLoop()
+
+	return api
+}
+
+// subscriptionLoop listens for mined blocks on the global event mux and creates notifications for subscriptions.
+func (s *PublicMinerAPI) subscriptionLoop() {
+	sub := s.e.EventMux().Subscribe(core.NewMinedBlockEvent{})
+	for event := range sub.Chan() {
+		e := event.Data.(core.NewMinedBlockEvent)
+		mined := newBlockRewardResult(e.Block, e.Reward, e.Fees)
+		s.muMinedBlockSubs.Lock()
+		for id, sub := range s.minedBlockSubs {
+			if sub.Notify(mined) == rpc.ErrNotificationNotFound {
+				delete(s.minedBlockSubs, id)
+			}
+		}
+		s.muMinedBlockSubs.Unlock()
+	}
 }
 
 // Mining returns an indication if this node is currently mining.
@@ -236,6 +345,413 @@ func (s *PublicMinerAPI) SubmitHashrate(hashrate rpc.HexNumber, id common.Hash)
 	return true
 }
 
+// UncleRewardResult describes the reward credited to the miner of an uncle.
+type UncleRewardResult struct {
+	Hash   common.Hash    `json:"hash"`
+	Miner  common.Address `json:"miner"`
+	Reward *rpc.HexNumber `json:"reward"`
+}
+
+// BlockRewardResult describes the income a block earned its miner and the miners of its uncles.
+type BlockRewardResult struct {
+	Number               *rpc.HexNumber      `json:"number"`
+	Hash                 common.Hash         `json:"hash"`
+	Miner                common.Address      `json:"miner"`
+	Reward               *rpc.HexNumber      `json:"reward"`               // Base and uncle inclusion rewards less the treasury share
+	BaseReward           *rpc.HexNumber      `json:"baseReward"`           // Reward for mining the block
+	UncleInclusionReward *rpc.HexNumber      `json:"uncleInclusionReward"` // Reward for including the block's uncles
+	TreasuryReward       *rpc.HexNumber      `json:"treasuryReward"`       // Share diverted from the miner's reward
+	Treasury             *common.Address     `json:"treasury"`             // Recipient of the treasury share, nil if burnt
+	Uncles               []UncleRewardResult `json:"uncles"`
+	Fees                 *rpc.HexNumber      `json:"fees"` // Gas fees of the block's transactions, nil if unknown
+}
+
+func newBlockRewardResult(block *types.Block, reward *core.BlockReward, fees *big.Int) *BlockRewardResult {
+	result := &BlockRewardResult{
+		Number: rpc.NewHexNumber(block.Number()),
+		Hash:   block.Hash(),
+		Miner:  block.Coinbase(),
+		Uncles: make([]UncleRewardResult, 0, len(block.Uncles())),
+		Fees:   rpc.NewHexNumber(fees),
+	}
+	if reward != nil {
+		result.Reward = rpc.NewHexNumber(reward.Miner())
+		result.BaseReward = rpc.NewHexNumber(reward.Base)
+		result.UncleInclusionReward = rpc.NewHexNumber(reward.Uncles)
+		result.TreasuryReward = rpc.NewHexNumber(reward.Treasury)
+		result.Treasury = reward.TreasuryAddress
+		for i, uncle := range block.Uncles() {
+			result.Uncles = append(result.Uncles, UncleRewardResult{uncle.Hash(), uncle.Coinbase, rpc.NewHexNumber(reward.Uncle[i])})
+		}
+	}
+	return result
+}
+
+// MinedBlocks creates a subscription that is triggered each time this node mines a block. Notifications carry the
+// reward breakdown of the block and the transaction fees it earned, so that miners can reconcile their income.
+func (s *PublicMinerAPI) MinedBlocks(ctx context.Context) (rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return nil, rpc.ErrNotificationsUnsupported
+	}
+
+	subscription, err := notifier.NewSubscription(func(id string) {
+		s.muMinedBlockSubs.Lock()
+		delete(s.minedBlockSubs, id)
+		s.muMinedBlockSubs.Unlock()
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	s.muMinedBlockSubs.Lock()
+	s.minedBlockSubs[subscription.ID()] = subscription
+	s.muMinedBlockSubs.Unlock()
+
+	return subscription, nil
+}
+
+// PublicEllaAPI provides Ellaism specific RPC methods.
+type PublicEllaAPI struct {
+	config  *core.ChainConfig
+	bc      *core.BlockChain
+	miner   *miner.Miner
+	chainDb ethdb.Database
+	names   names.Resolver
+	debug   *PublicDebugAPI // Replays transactions to trace their internal transfers
+}
+
+// NewPublicEllaAPI creates a new RPC service with Ellaism specific methods.
+func NewPublicEllaAPI(e *Ethereum) *PublicEllaAPI {
+	return &PublicEllaAPI{config: e.chainConfig, bc: e.blockchain, miner: e.miner, chainDb: e.chainDb, names: e.names, debug: NewPublicDebugAPI(e)}
+}
+
+// ResolveName returns the address the given name resolves to through the name
+// registry configured with --name-registry.
+func (s *PublicEllaAPI) ResolveName(name string) (common.Address, error) {
+	if !names.IsName(name) {
+		return common.Address{}, fmt.Errorf("invalid name: %q", name)
+	}
+	account := names.Account{Name: name}
+	return account.Resolve(s.names)
+}
+
+// GetBlockReward returns the rewards credited for the block with the given number to its miner and the miners of its
+// uncles, as computed by the state transition, and the transaction fees earned by its miner.
+func (s *PublicEllaAPI) GetBlockReward(blockNr rpc.BlockNumber) *BlockRewardResult {
+	return s.blockReward(blockByNumber(s.miner, s.bc, blockNr))
+}
+
+// GetBlockRewardByHash returns the rewards credited for the block with the given hash to its miner and the miners of
+// its uncles, as computed by the state transition, and the transaction fees earned by its miner.
+func (s *PublicEllaAPI) GetBlockRewardByHash(blockHash common.Hash) *BlockRewardResult {
+	return s.blockReward(s.bc.GetBlock(blockHash))
+}
+
+// blockReward computes the rewards of the given block. The fees are left unknown if the receipts of the block's
+// transactions aren't available.
+func (s *PublicEllaAPI) blockReward(block *types.Block) *BlockRewardResult {
+	if block == nil {
+		return nil
+	}
+	var fees *big.Int
+	if receipts := s.bc.GetReceiptsByHash(block.Hash()); len(receipts) == len(block.Transactions()) {
+		fees = core.BlockFees(block, receipts)
+	}
+	// The genesis block isn't rewarded
+	reward := &core.BlockReward{Base: new(big.Int), Uncles: new(big.Int), Treasury: new(big.Int)}
+	if block.NumberU64() > 0 {
+		reward = core.CalcBlockReward(s.config, block.Header(), block.Uncles())
+	}
+	return newBlockRewardResult(block, reward, fees)
+}
+
+// maxTransferBlocks is the number of blocks ella_getTransfers replays at most
+// in a single request.
+const maxTransferBlocks = 1000
+
+// Transfer is a movement of value reported by ella_getTransfers: the value of a
+// transaction or of a call it made, the gas fee of a transaction, or a reward
+// credited for a block.
+type Transfer struct {
+	Type            string          `json:"type"` // "call", "create", "suicide", "fee", "reward", "uncleReward" or "treasury"
+	BlockNumber     *rpc.HexNumber  `json:"blockNumber"`
+	BlockHash       common.Hash     `json:"blockHash"`
+	TransactionHash *common.Hash    `json:"transactionHash"` // Transaction the transfer is part of, nil for rewards
+	Depth           int             `json:"depth"`           // Call depth, 0 for the transaction itself
+	From            *common.Address `json:"from"`            // Debited account, nil for rewards
+	To              common.Address  `json:"to"`
+	Value           *rpc.HexNumber  `json:"value"`
+}
+
+// GetTransfers returns the transfers of value to or from any of the given accounts in the canonical blocks of the
+// given range, in the order they took place: the value of transactions and of the internal calls, contract creations
+// and suicides they made, transaction fees, and block, uncle and treasury rewards. Calls reverted along with any of
+// their callers are left out. The transactions of the blocks not indexed by the trace index are replayed, so the
+// state of their parents must be available.
+func (s *PublicEllaAPI) GetTransfers(fromBlock, toBlock rpc.BlockNumber, accounts []names.Account) ([]*Transfer, error) {
+	if fromBlock == rpc.PendingBlockNumber || toBlock == rpc.PendingBlockNumber {
+		return nil, errors.New("transfers of the pending block are not supported")
+	}
+	from, to := blockByNumber(s.miner, s.bc, fromBlock), blockByNumber(s.miner, s.bc, toBlock)
+	if from == nil || to == nil {
+		return nil, errors.New("block range not found")
+	}
+	first, last := from.NumberU64(), to.NumberU64()
+	if first > last {
+		return nil, fmt.Errorf("invalid block range #%d-#%d", first, last)
+	}
+	if last-first >= maxTransferBlocks {
+		return nil, fmt.Errorf("block range #%d-#%d exceeds %d blocks", first, last, maxTransferBlocks)
+	}
+	if len(accounts) == 0 {
+		return nil, errors.New("no accounts given")
+	}
+	watched := make(map[common.Address]bool, len(accounts))
+	for _, account := range accounts {
+		address, err := account.Resolve(s.names)
+		if err != nil {
+			return nil, err
+		}
+		watched[address] = true
+	}
+
+	transfers := []*Transfer{}
+	for number := first; number <= last; number++ {
+		block := s.bc.GetBlockByNumber(number)
+		if block == nil {
+			return nil, fmt.Errorf("block #%d not found", number)
+		}
+		blockTransfers, err := s.blockTransfers(block, watched)
+		if err != nil {
+			return nil, fmt.Errorf("block #%d: %v", number, err)
+		}
+		transfers = append(transfers, blockTransfers...)
+	}
+	return transfers, nil
+}
+
+// blockTransfers returns the transfers of the given block involving the watched
+// accounts, from the trace index if the block was indexed, otherwise replaying
+// its transactions.
+func (s *PublicEllaAPI) blockTransfers(block *types.Block, watched map[common.Address]bool) ([]*Transfer, error) {
+	var transfers []*Transfer
+	add := func(typ string, txHash *common.Hash, depth int, from *common.Address, to common.Address, value *big.Int) {
+		if value == nil || value.Sign() == 0 || !(watched[to] || (from != nil && watched[*from])) {
+			return
+		}
+		transfers = append(transfers, &Transfer{
+			Type:            typ,
+			BlockNumber:     rpc.NewHexNumber(block.Number()),
+			BlockHash:       block.Hash(),
+			TransactionHash: txHash,
+			Depth:           depth,
+			From:            from,
+			To:              to,
+			Value:           rpc.NewHexNumber(value),
+		})
+	}
+	if txs := block.Transactions(); len(txs) > 0 {
+		receipts := s.bc.GetReceiptsByHash(block.Hash())
+		if len(receipts) != len(txs) {
+			return nil, errors.New("transaction receipts not found")
+		}
+		records, indexed := core.GetBlockTraces(s.chainDb, block.Hash())
+		if !indexed {
+			traces, err := s.debug.traceBlock(block, &TraceConfig{Tracer: "callTracer"})
+			if err != nil {
+				return nil, err
+			}
+			for i, trace := range traces {
+				if frame, ok := trace.(*vm.CallFrame); ok && frame != nil {
+					records = append(records, core.NewTraceRecords(i, frame)...)
+				}
+			}
+		}
+		for i, tx := range txs {
+			hash := tx.Hash()
+			for len(records) > 0 && records[0].TxIndex == uint(i) {
+				record := records[0]
+				records = records[1:]
+				if typ := transferType(record.Type); typ != "" && !record.Reverted {
+					from := record.From
+					add(typ, &hash, len(record.Address), &from, record.To, record.Value)
+				}
+			}
+			sender, err := tx.From()
+			if err != nil {
+				return nil, err
+			}
+			add("fee", &hash, 0, &sender, block.Coinbase(), new(big.Int).Mul(receipts[i].GasUsed, tx.GasPrice()))
+		}
+	}
+	// The genesis block isn't rewarded
+	if block.NumberU64() > 0 {
+		reward := core.CalcBlockReward(s.config, block.Header(), block.Uncles())
+		add("reward", nil, 0, nil, block.Coinbase(), reward.Miner())
+		for i, uncle := range block.Uncles() {
+			add("uncleReward", nil, 0, nil, uncle.Coinbase, reward.Uncle[i])
+		}
+		if reward.TreasuryAddress != nil {
+			add("treasury", nil, 0, nil, *reward.TreasuryAddress, reward.Treasury)
+		}
+	}
+	return transfers, nil
+}
+
+// transferType returns the transfer type of the call frames of the given type,
+// or an empty string for delegate calls and call codes, which keep the value
+// with the caller.
+func transferType(typ vm.OpCode) string {
+	switch typ {
+	case vm.CALL:
+		return "call"
+	case vm.CREATE, vm.CREATE2:
+		return "create"
+	case vm.SUICIDE:
+		return "suicide"
+	}
+	return ""
+}
+
+// UncleInclusionResult describes an uncle included in the canonical chain and the reward credited to its miner.
+type UncleInclusionResult struct {
+	Hash        common.Hash    `json:"hash"`
+	Number      *rpc.HexNumber `json:"number"`
+	Miner       common.Address `json:"miner"`
+	BlockHash   common.Hash    `json:"blockHash"`   // Canonical block including the uncle
+	BlockNumber *rpc.HexNumber `json:"blockNumber"` // Number of the block including the uncle
+	Index       *rpc.HexNumber `json:"index"`       // Index of the uncle in the block including it
+	Reward      *rpc.HexNumber `json:"reward"`      // Reward credited to the miner of the uncle
+}
+
+// GetUncleByHash returns the canonical block including the uncle with the given hash, its position in that block and
+// the reward credited to its miner, or nil if the uncle isn't included in the canonical chain.
+func (s *PublicEllaAPI) GetUncleByHash(uncleHash common.Hash) *UncleInclusionResult {
+	blockHash, number, index := core.GetUncleLookup(s.chainDb, uncleHash)
+	if blockHash == (common.Hash{}) || core.GetCanonicalHash(s.chainDb, number) != blockHash {
+		return nil
+	}
+	block := s.bc.GetBlock(blockHash)
+	if block == nil || index >= uint64(len(block.Uncles())) || block.Uncles()[index].Hash() != uncleHash {
+		return nil
+	}
+	uncle := block.Uncles()[index]
+	reward := core.CalcBlockReward(s.config, block.Header(), block.Uncles())
+	return &UncleInclusionResult{
+		Hash:        uncleHash,
+		Number:      rpc.NewHexNumber(uncle.Number),
+		Miner:       uncle.Coinbase,
+		BlockHash:   blockHash,
+		BlockNumber: rpc.NewHexNumber(number),
+		Index:       rpc.NewHexNumber(index),
+		Reward:      rpc.NewHexNumber(reward.Uncle[index]),
+	}
+}
+
+// maxBlockTransactionsPage is the most transactions returned by a single call to GetBlockTransactions.
+const maxBlockTransactionsPage = 1000
+
+// BlockTransactionsResult is a page of the transactions of a block.
+type BlockTransactionsResult struct {
+	Number       *rpc.HexNumber `json:"number"`
+	Hash         common.Hash    `json:"hash"`
+	Total        *rpc.HexNumber `json:"total"`        // Number of transactions in the block
+	Offset       *rpc.HexNumber `json:"offset"`       // Index in the block of the first transaction returned
+	Transactions []interface{}  `json:"transactions"` // Transaction hashes, or full transactions if requested
+}
+
+// GetBlockTransactions returns up to count transactions of the block with the given number or hash, starting with
+// the one at the given index, along with the number of transactions in the block so that explorers can page through
+// large blocks. A zero count, like any count above 1000, returns at most 1000 transactions. When fullTx is false only
+// the transaction hashes are returned, computed from the stored block body without decoding the transactions.
+func (s *PublicEllaAPI) GetBlockTransactions(blockNrOrHash rpc.BlockNumberOrHash, offset, count rpc.HexNumber, fullTx bool) (*BlockTransactionsResult, error) {
+	var (
+		header  *types.Header
+		pending *types.Block
+	)
+	if blockNrOrHash.BlockHash != nil {
+		header = s.bc.GetHeader(*blockNrOrHash.BlockHash)
+	} else if blockNrOrHash.BlockNumber != nil {
+		if *blockNrOrHash.BlockNumber == rpc.PendingBlockNumber {
+			if pending, _ = s.miner.Pending(); pending != nil {
+				header = pending.Header()
+			}
+		} else {
+			header = headerByNumber(s.miner, s.bc, *blockNrOrHash.BlockNumber)
+		}
+	}
+	if header == nil {
+		return nil, nil
+	}
+	hash := header.Hash()
+
+	// Only decode the block if its transactions are wanted in full
+	var (
+		block  = pending
+		hashes []common.Hash
+		total  int
+	)
+	switch {
+	case block == nil && fullTx:
+		if block = s.bc.GetBlock(hash); block == nil {
+			return nil, fmt.Errorf("body of block #%d [%x…] is not available", header.Number, hash.Bytes()[:4])
+		}
+		total = len(block.Transactions())
+	case block == nil:
+		body := s.bc.GetBodyRLP(hash)
+		if len(body) == 0 {
+			return nil, fmt.Errorf("body of block #%d [%x…] is not available", header.Number, hash.Bytes()[:4])
+		}
+		var err error
+		if hashes, err = types.BodyTransactionHashes(body); err != nil {
+			return nil, err
+		}
+		total = len(hashes)
+	default:
+		total = len(block.Transactions())
+	}
+
+	start, size := offset.Int(), count.Int()
+	if start < 0 {
+		return nil, fmt.Errorf("invalid offset %d", start)
+	}
+	if size <= 0 || size > maxBlockTransactionsPage {
+		size = maxBlockTransactionsPage
+	}
+	if start > total {
+		start = total
+	}
+	end := start + size
+	if end > total {
+		end = total
+	}
+
+	transactions := make([]interface{}, 0, end-start)
+	for i := start; i < end; i++ {
+		switch {
+		case fullTx:
+			tx, err := newRPCTransactionFromBlockIndex(block, i)
+			if err != nil {
+				return nil, err
+			}
+			transactions = append(transactions, tx)
+		case hashes != nil:
+			transactions = append(transactions, hashes[i])
+		default:
+			transactions = append(transactions, block.Transactions()[i].Hash())
+		}
+	}
+	return &BlockTransactionsResult{
+		Number:       rpc.NewHexNumber(header.Number),
+		Hash:         hash,
+		Total:        rpc.NewHexNumber(total),
+		Offset:       rpc.NewHexNumber(start),
+		Transactions: transactions,
+	}, nil
+}
+
 // PrivateMinerAPI provides private RPC methods to control the miner.
 // These methods can be abused by external users and must be considered insecure for use by untrusted users.
 type PrivateMinerAPI struct {
@@ -354,6 +870,17 @@ func (s *PublicTxPoolAPI) Status() map[string]*rpc.HexNumber {
 	}
 }
 
+// NextNonce returns the lowest nonce of the account not used by its executable
+// or queued transactions in the pool. A transaction sent with it neither
+// replaces a pooled one nor waits behind a nonce gap.
+func (s *PublicTxPoolAPI) NextNonce(account names.Account) (*rpc.HexNumber, error) {
+	address, err := account.Resolve(s.e.names)
+	if err != nil {
+		return nil, err
+	}
+	return rpc.NewHexNumber(s.e.TxPool().NextNonce(address)), nil
+}
+
 // Inspect retrieves the content of the transaction pool and flattens it into an
 // easily inspectable list.
 func (s *PublicTxPoolAPI) Inspect() map[string]map[string]map[string][]string {
@@ -398,16 +925,24 @@ func (s *PublicTxPoolAPI) Inspect() map[string]map[string]map[string][]string {
 // PublicAccountAPI provides an API to access accounts managed by this node.
 // It offers only methods that can retrieve accounts.
 type PublicAccountAPI struct {
-	am *accounts.Manager
+	am     *accounts.Manager
+	remote bool // Whether the accounts are listed to callers over HTTP and WebSocket
 }
 
-// NewPublicAccountAPI creates a new PublicAccountAPI.
-func NewPublicAccountAPI(am *accounts.Manager) *PublicAccountAPI {
-	return &PublicAccountAPI{am: am}
+// NewPublicAccountAPI creates a new PublicAccountAPI, listing the accounts to
+// callers over network facing transports only if remote is set.
+func NewPublicAccountAPI(am *accounts.Manager, remote bool) *PublicAccountAPI {
+	return &PublicAccountAPI{am: am, remote: remote}
 }
 
-// Accounts returns the collection of accounts this node manages
-func (s *PublicAccountAPI) Accounts() []accounts.Account {
+// Accounts returns the collection of accounts this node manages. Unless enabled
+// with --rpc-expose-accounts, the list is empty for callers over HTTP and
+// WebSocket, so that public endpoints don't reveal the addresses the node
+// controls.
+func (s *PublicAccountAPI) Accounts(ctx context.Context) []accounts.Account {
+	if !s.remote && rpc.IsRemoteTransport(rpc.TransportFromContext(ctx)) {
+		return []accounts.Account{}
+	}
 	return s.am.Accounts()
 }
 
@@ -420,19 +955,81 @@ type PrivateAccountAPI struct {
 	txPool *core.TxPool
 	txMu   *sync.Mutex
 	gpo    *GasPriceOracle
+	names  names.Resolver
+
+	insecureUnlock bool // Whether accounts may be unlocked by callers over HTTP and WebSocket
+
+	muWalletSubs sync.Mutex
+	walletSubs   map[string]rpc.Subscription
 }
 
 // NewPrivateAccountAPI create a new PrivateAccountAPI.
 func NewPrivateAccountAPI(e *Ethereum) *PrivateAccountAPI {
-	return &PrivateAccountAPI{
-		bc:     e.blockchain,
-		am:     e.accountManager,
-		txPool: e.txPool,
-		txMu:   &e.txMu,
-		gpo:    e.gpo,
+	api := &PrivateAccountAPI{
+		bc:         e.blockchain,
+		am:         e.accountManager,
+		txPool:     e.txPool,
+		txMu:       &e.txMu,
+		gpo:        e.gpo,
+		names:      e.names,
+		walletSubs: make(map[string]rpc.Subscription),
+
+		insecureUnlock: e.insecureUnlock,
+	}
+	go api.subscriptionLoop()
+
+	return api
+}
+
+// WalletEventResult is the notification sent to wallet event subscribers.
+type WalletEventResult struct {
+	Type    accounts.WalletEventType `json:"type"`
+	Address common.Address           `json:"address"`
+	File    string                   `json:"file,omitempty"`
+	Wallet  string                   `json:"wallet,omitempty"`
+}
+
+// subscriptionLoop listens for wallet events of the account manager and creates notifications for subscriptions.
+func (s *PrivateAccountAPI) subscriptionLoop() {
+	sub := s.am.Subscribe()
+	for event := range sub.Chan() {
+		e := event.Data.(accounts.WalletEvent)
+		result := &WalletEventResult{Type: e.Type, Address: e.Account.Address, File: e.Account.File, Wallet: e.Wallet}
+		s.muWalletSubs.Lock()
+		for id, sub := range s.walletSubs {
+			if sub.Notify(result) == rpc.ErrNotificationNotFound {
+				delete(s.walletSubs, id)
+			}
+		}
+		s.muWalletSubs.Unlock()
 	}
 }
 
+// WalletEvents creates a subscription that is triggered each time an account is created, deleted, unlocked or
+// locked, or a hardware wallet is plugged in or unplugged, so that user interfaces don't need to poll the keystore.
+func (s *PrivateAccountAPI) WalletEvents(ctx context.Context) (rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return nil, rpc.ErrNotificationsUnsupported
+	}
+
+	subscription, err := notifier.NewSubscription(func(id string) {
+		s.muWalletSubs.Lock()
+		delete(s.walletSubs, id)
+		s.muWalletSubs.Unlock()
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	s.muWalletSubs.Lock()
+	s.walletSubs[subscription.ID()] = subscription
+	s.muWalletSubs.Unlock()
+
+	return subscription, nil
+}
+
 // ListAccounts will return a list of addresses for accounts this node manages.
 func (s *PrivateAccountAPI) ListAccounts() []common.Address {
 	accounts := s.am.Accounts()
@@ -443,6 +1040,56 @@ func (s *PrivateAccountAPI) ListAccounts() []common.Address {
 	return addresses
 }
 
+// WalletResult describes a hardware wallet connected to this node.
+type WalletResult struct {
+	URL      string           `json:"url"`
+	Status   string           `json:"status"`
+	Failure  string           `json:"failure,omitempty"`
+	Accounts []common.Address `json:"accounts"`
+}
+
+// ListWallets returns the hardware wallets connected to this node, along with
+// the accounts of the open ones.
+func (s *PrivateAccountAPI) ListWallets() []WalletResult {
+	wallets := s.am.Wallets()
+	results := make([]WalletResult, len(wallets))
+	for i, wallet := range wallets {
+		status, err := wallet.Status()
+		results[i] = WalletResult{URL: wallet.URL(), Status: status, Accounts: wallet.Accounts()}
+		if err != nil {
+			results[i].Failure = err.Error()
+		}
+		if results[i].Accounts == nil {
+			results[i].Accounts = []common.Address{}
+		}
+	}
+	return results
+}
+
+// OpenWallet opens the hardware wallet with the given URL, authenticating with
+// the passphrase if the wallet asks for one. Smartcards are paired with their
+// pairing password first, then unlocked with their PIN on a second call.
+func (s *PrivateAccountAPI) OpenWallet(url string, passphrase *string) error {
+	wallet, err := s.am.Wallet(url)
+	if err != nil {
+		return err
+	}
+	pass := ""
+	if passphrase != nil {
+		pass = *passphrase
+	}
+	return wallet.Open(pass)
+}
+
+// CloseWallet closes the hardware wallet with the given URL, locking it again.
+func (s *PrivateAccountAPI) CloseWallet(url string) error {
+	wallet, err := s.am.Wallet(url)
+	if err != nil {
+		return err
+	}
+	return wallet.Close()
+}
+
 // NewAccount will create a new account and returns the address for the new account.
 func (s *PrivateAccountAPI) NewAccount(password string) (common.Address, error) {
 	acc, err := s.am.NewAccount(password)
@@ -464,10 +1111,19 @@ func (s *PrivateAccountAPI) ImportRawKey(privkey string, password string) (commo
 	return acc.Address, err
 }
 
+// errInsecureUnlock is returned to the callers over HTTP and WebSocket unlocking
+// accounts, which any other caller could then use.
+var errInsecureUnlock = errors.New("account unlock over HTTP and WS is forbidden, set --allow-insecure-unlock to allow it")
+
 // UnlockAccount will unlock the account associated with the given address with
 // the given password for duration seconds. If duration is nil it will use a
 // default of 300 seconds. It returns an indication if the account was unlocked.
-func (s *PrivateAccountAPI) UnlockAccount(addr common.Address, password string, duration *rpc.HexNumber) (bool, error) {
+// Callers over HTTP and WebSocket are refused unless insecure unlocking is
+// allowed.
+func (s *PrivateAccountAPI) UnlockAccount(ctx context.Context, addr common.Address, password string, duration *rpc.HexNumber) (bool, error) {
+	if !s.insecureUnlock && rpc.IsRemoteTransport(rpc.TransportFromContext(ctx)) {
+		return false, errInsecureUnlock
+	}
 	if duration == nil {
 		duration = rpc.NewHexNumber(300)
 	}
@@ -489,6 +1145,9 @@ func (s *PrivateAccountAPI) LockAccount(addr common.Address) bool {
 // able to decrypt the key it fails.
 func (s *PrivateAccountAPI) SignAndSendTransaction(args SendTxArgs, passwd string) (common.Hash, error) {
 	args = prepareSendTxArgs(args, s.gpo)
+	if err := resolveRecipient(args.To, s.names); err != nil {
+		return common.Hash{}, err
+	}
 
 	s.txMu.Lock()
 	defer s.txMu.Unlock()
@@ -497,12 +1156,7 @@ func (s *PrivateAccountAPI) SignAndSendTransaction(args SendTxArgs, passwd strin
 		args.Nonce = rpc.NewHexNumber(s.txPool.State().GetNonce(args.From))
 	}
 
-	var tx *types.Transaction
-	if args.To == nil {
-		tx = types.NewContractCreation(args.Nonce.Uint64(), args.Value.BigInt(), args.Gas.BigInt(), args.GasPrice.BigInt(), common.FromHex(args.Data))
-	} else {
-		tx = types.NewTransaction(args.Nonce.Uint64(), *args.To, args.Value.BigInt(), args.Gas.BigInt(), args.GasPrice.BigInt(), common.FromHex(args.Data))
-	}
+	tx := newTransaction(s.bc.Config().GetChainID(), args.Nonce.Uint64(), args.To.Recipient(), args.Value.BigInt(), args.Gas.BigInt(), args.GasPrice.BigInt(), common.FromHex(args.Data), args.AccessList)
 
 	tx.SetSigner(s.bc.Config().GetSigner(s.bc.CurrentBlock().Number()))
 
@@ -514,6 +1168,30 @@ func (s *PrivateAccountAPI) SignAndSendTransaction(args SendTxArgs, passwd strin
 	return submitTransaction(s.bc, s.txPool, tx, signature)
 }
 
+// rpcOutputHeader converts the given header into its RPC representation,
+// including the total difficulty of the chain up to it.
+func (s *PublicBlockChainAPI) rpcOutputHeader(h *types.Header) map[string]interface{} {
+	hash := h.Hash() // Hashing encodes the header, do it once
+	return map[string]interface{}{
+		"number":           rpc.NewHexNumber(h.Number),
+		"hash":             hash,
+		"parentHash":       h.ParentHash,
+		"nonce":            h.Nonce,
+		"sha3Uncles":       h.UncleHash,
+		"logsBloom":        h.Bloom,
+		"stateRoot":        h.Root,
+		"miner":            h.Coinbase,
+		"difficulty":       rpc.NewHexNumber(h.Difficulty),
+		"totalDifficulty":  rpc.NewHexNumber(s.bc.GetTd(hash)),
+		"extraData":        fmt.Sprintf("0x%x", h.Extra),
+		"gasLimit":         rpc.NewHexNumber(h.GasLimit),
+		"gasUsed":          rpc.NewHexNumber(h.GasUsed),
+		"timestamp":        rpc.NewHexNumber(h.Time),
+		"transactionsRoot": h.TxHash,
+		"receiptsRoot":     h.ReceiptHash,
+	}
+}
+
 // PublicBlockChainAPI provides an API to access the Ethereum blockchain.
 // It offers only methods that operate on public data that is freely available to anyone.
 type PublicBlockChainAPI struct {
@@ -526,10 +1204,11 @@ type PublicBlockChainAPI struct {
 	am                      *accounts.Manager
 	miner                   *miner.Miner
 	gpo                     *GasPriceOracle
+	names                   names.Resolver // Resolver of names given in place of addresses
 }
 
 // NewPublicBlockChainAPI creates a new Etheruem blockchain API.
-func NewPublicBlockChainAPI(config *core.ChainConfig, bc *core.BlockChain, m *miner.Miner, chainDb ethdb.Database, gpo *GasPriceOracle, eventMux *event.TypeMux, am *accounts.Manager) *PublicBlockChainAPI {
+func NewPublicBlockChainAPI(config *core.ChainConfig, bc *core.BlockChain, m *miner.Miner, chainDb ethdb.Database, gpo *GasPriceOracle, eventMux *event.TypeMux, am *accounts.Manager, resolver names.Resolver) *PublicBlockChainAPI {
 	api := &PublicBlockChainAPI{
 		config:   config,
 		bc:       bc,
@@ -538,7 +1217,8 @@ func NewPublicBlockChainAPI(config *core.ChainConfig, bc *core.BlockChain, m *mi
 		eventMux: eventMux,
 		am:       am,
 		newBlockSubscriptions: make(map[string]func(core.ChainEvent) error),
-		gpo: gpo,
+		gpo:   gpo,
+		names: resolver,
 	}
 
 	go api.subscriptionLoop()
@@ -567,10 +1247,14 @@ func (s *PublicBlockChainAPI) BlockNumber() *big.Int {
 	return s.bc.CurrentHeader().Number
 }
 
-// GetBalance returns the amount of wei for the given address in the state of the
-// given block number. The rpc.LatestBlockNumber and rpc.PendingBlockNumber meta
-// block numbers are also allowed.
-func (s *PublicBlockChainAPI) GetBalance(address common.Address, blockNr rpc.BlockNumber) (*big.Int, error) {
+// GetBalance returns the amount of wei for the given address or name in the state
+// of the given block number. The rpc.LatestBlockNumber and rpc.PendingBlockNumber
+// meta block numbers are also allowed.
+func (s *PublicBlockChainAPI) GetBalance(account names.Account, blockNr rpc.BlockNumber) (*big.Int, error) {
+	address, err := account.Resolve(s.names)
+	if err != nil {
+		return nil, err
+	}
 	state, _, err := stateAndBlockByNumber(s.miner, s.bc, blockNr, s.chainDb)
 	if state == nil || err != nil {
 		return nil, err
@@ -603,8 +1287,49 @@ func (s *PublicBlockChainAPI) GetBlockByHash(blockHash common.Hash, fullTx bool)
 	return nil, nil
 }
 
-// GetUncleByBlockNumberAndIndex returns the uncle block for the given block hash and index. When fullTx is true
-// all transactions in the block are returned in full detail, otherwise only the transaction hash is returned.
+// GetHeaderByNumber returns the requested header, along with the total
+// difficulty of the chain up to it. When blockNr is -1 the head header is
+// returned, which is also available in header-only mode.
+func (s *PublicBlockChainAPI) GetHeaderByNumber(blockNr rpc.BlockNumber) (map[string]interface{}, error) {
+	if header := headerByNumber(s.miner, s.bc, blockNr); header != nil {
+		response := s.rpcOutputHeader(header)
+		if blockNr == rpc.PendingBlockNumber {
+			// Pending headers need to nil out a few fields
+			for _, field := range []string{"hash", "nonce", "miner", "totalDifficulty"} {
+				response[field] = nil
+			}
+		}
+		return response, nil
+	}
+	return nil, nil
+}
+
+// GetHeaderByHash returns the requested header, along with the total difficulty
+// of the chain up to it.
+func (s *PublicBlockChainAPI) GetHeaderByHash(blockHash common.Hash) map[string]interface{} {
+	if header := s.bc.GetHeader(blockHash); header != nil {
+		return s.rpcOutputHeader(header)
+	}
+	return nil
+}
+
+// GetTotalDifficulty returns the total difficulty of the chain up to the block
+// with the given number or hash.
+func (s *PublicBlockChainAPI) GetTotalDifficulty(blockNrOrHash rpc.BlockNumberOrHash) *rpc.HexNumber {
+	var header *types.Header
+	if blockNrOrHash.BlockHash != nil {
+		header = s.bc.GetHeader(*blockNrOrHash.BlockHash)
+	} else if blockNrOrHash.BlockNumber != nil && *blockNrOrHash.BlockNumber != rpc.PendingBlockNumber {
+		header = headerByNumber(s.miner, s.bc, *blockNrOrHash.BlockNumber)
+	}
+	if header == nil {
+		return nil
+	}
+	return rpc.NewHexNumber(s.bc.GetTd(header.Hash()))
+}
+
+// GetUncleByBlockNumberAndIndex returns the uncle block for the given block number and index. Uncles carry no
+// transactions, so only their header fields are returned.
 func (s *PublicBlockChainAPI) GetUncleByBlockNumberAndIndex(blockNr rpc.BlockNumber, index rpc.HexNumber) (map[string]interface{}, error) {
 	if block := blockByNumber(s.miner, s.bc, blockNr); block != nil {
 		uncles := block.Uncles()
@@ -618,8 +1343,8 @@ func (s *PublicBlockChainAPI) GetUncleByBlockNumberAndIndex(blockNr rpc.BlockNum
 	return nil, nil
 }
 
-// GetUncleByBlockHashAndIndex returns the uncle block for the given block hash and index. When fullTx is true
-// all transactions in the block are returned in full detail, otherwise only the transaction hash is returned.
+// GetUncleByBlockHashAndIndex returns the uncle block for the given block hash and index. Uncles carry no
+// transactions, so only their header fields are returned.
 func (s *PublicBlockChainAPI) GetUncleByBlockHashAndIndex(blockHash common.Hash, index rpc.HexNumber) (map[string]interface{}, error) {
 	if block := s.bc.GetBlock(blockHash); block != nil {
 		uncles := block.Uncles()
@@ -688,8 +1413,106 @@ func (s *PublicBlockChainAPI) NewBlocks(ctx context.Context, args NewBlocksArgs)
 	return subscription, nil
 }
 
-// GetCode returns the code stored at the given address in the state for the given block number.
-func (s *PublicBlockChainAPI) GetCode(address common.Address, blockNr rpc.BlockNumber) (string, error) {
+// AccountChange is the notification of an AccountChanges subscription, carrying
+// the balance and nonce of a watched account in the post-state of a new head.
+type AccountChange struct {
+	Address     common.Address `json:"address"`
+	BlockNumber *rpc.HexNumber `json:"blockNumber"`
+	BlockHash   common.Hash    `json:"blockHash"`
+	Balance     *rpc.HexNumber `json:"balance"`
+	Nonce       *rpc.HexNumber `json:"nonce"`
+}
+
+// accountSnapshot is the balance and nonce of an account last reported to an
+// AccountChanges subscriber.
+type accountSnapshot struct {
+	balance *big.Int
+	nonce   uint64
+}
+
+// accountChanges compares the watched accounts with their balances and nonces
+// in the post-state of a block, returning the changed ones and updating the
+// snapshots to the block.
+func accountChanges(statedb *state.StateDB, block *types.Block, watched map[common.Address]*accountSnapshot) []*AccountChange {
+	var changes []*AccountChange
+	for address, last := range watched {
+		balance, nonce := statedb.GetBalance(address), statedb.GetNonce(address)
+		if balance.Cmp(last.balance) == 0 && nonce == last.nonce {
+			continue
+		}
+		watched[address] = &accountSnapshot{balance: balance, nonce: nonce}
+		changes = append(changes, &AccountChange{
+			Address:     address,
+			BlockNumber: rpc.NewHexNumber(block.Number()),
+			BlockHash:   block.Hash(),
+			Balance:     rpc.NewHexNumber(balance),
+			Nonce:       rpc.NewHexNumber(nonce),
+		})
+	}
+	return changes
+}
+
+// AccountChanges creates a subscription notifying of the given accounts whose balance or nonce changes. At each new
+// head of the chain the accounts in its post-state are compared with the values last reported, so a change is noticed
+// once even if several blocks are imported at once, and again if a reorg reverts it.
+func (s *PublicBlockChainAPI) AccountChanges(ctx context.Context, accounts []names.Account) (rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return nil, rpc.ErrNotificationsUnsupported
+	}
+	// Resolve the accounts and snapshot them at the current head
+	statedb, err := s.bc.State()
+	if err != nil {
+		return nil, err
+	}
+	watched := make(map[common.Address]*accountSnapshot, len(accounts))
+	for _, account := range accounts {
+		address, err := account.Resolve(s.names)
+		if err != nil {
+			return nil, err
+		}
+		watched[address] = &accountSnapshot{balance: statedb.GetBalance(address), nonce: statedb.GetNonce(address)}
+	}
+
+	heads := make(chan core.ChainHeadEvent, 16)
+	headSub := s.bc.SubscribeChainHeadEvent(heads)
+
+	subscription, err := notifier.NewSubscription(func(string) {
+		headSub.Unsubscribe()
+	})
+	if err != nil {
+		headSub.Unsubscribe()
+		return nil, err
+	}
+	go func() {
+		for {
+			select {
+			case ev := <-heads:
+				statedb, err := state.New(ev.Block.Root(), s.chainDb)
+				if err != nil {
+					glog.V(logger.Warn).Infof("unable to open state of block #%d [%x] for account changes: %v", ev.Block.NumberU64(), ev.Block.Hash().Bytes()[:4], err)
+					continue
+				}
+				for _, change := range accountChanges(statedb, ev.Block, watched) {
+					if err := subscription.Notify(change); err == rpc.ErrNotificationNotFound {
+						headSub.Unsubscribe()
+						return
+					}
+				}
+			case <-headSub.Err():
+				return
+			}
+		}
+	}()
+	return subscription, nil
+}
+
+// GetCode returns the code stored at the given address or name in the state for the given block number.
+func (s *PublicBlockChainAPI) GetCode(account names.Account, blockNr rpc.BlockNumber) (string, error) {
+	address, err := account.Resolve(s.names)
+	if err != nil {
+		return "", err
+	}
 	state, _, err := stateAndBlockByNumber(s.miner, s.bc, blockNr, s.chainDb)
 	if state == nil || err != nil {
 		return "", err
@@ -701,10 +1524,14 @@ func (s *PublicBlockChainAPI) GetCode(address common.Address, blockNr rpc.BlockN
 	return common.ToHex(res), nil
 }
 
-// GetStorageAt returns the storage from the state at the given address, key and
-// block number. The rpc.LatestBlockNumber and rpc.PendingBlockNumber meta block
-// numbers are also allowed.
-func (s *PublicBlockChainAPI) GetStorageAt(address common.Address, key string, blockNr rpc.BlockNumber) (string, error) {
+// GetStorageAt returns the storage from the state at the given address or name,
+// key and block number. The rpc.LatestBlockNumber and rpc.PendingBlockNumber meta
+// block numbers are also allowed.
+func (s *PublicBlockChainAPI) GetStorageAt(account names.Account, key string, blockNr rpc.BlockNumber) (string, error) {
+	address, err := account.Resolve(s.names)
+	if err != nil {
+		return "0x", err
+	}
 	state, _, err := stateAndBlockByNumber(s.miner, s.bc, blockNr, s.chainDb)
 	if state == nil || err != nil {
 		return "0x", err
@@ -712,6 +1539,78 @@ func (s *PublicBlockChainAPI) GetStorageAt(address common.Address, key string, b
 	return state.GetState(address, common.HexToHash(key)).Hex(), nil
 }
 
+// AccountResult is the Merkle proof of an account and of some of its storage
+// slots, as returned by GetProof (EIP-1186).
+type AccountResult struct {
+	Address      common.Address  `json:"address"`
+	AccountProof []string        `json:"accountProof"`
+	Balance      *rpc.HexNumber  `json:"balance"`
+	CodeHash     common.Hash     `json:"codeHash"`
+	Nonce        *rpc.HexNumber  `json:"nonce"`
+	StorageHash  common.Hash     `json:"storageHash"`
+	StorageProof []StorageResult `json:"storageProof"`
+}
+
+// StorageResult is the Merkle proof of a storage slot in the storage trie of
+// an account.
+type StorageResult struct {
+	Key   string         `json:"key"`
+	Value *rpc.HexNumber `json:"value"`
+	Proof []string       `json:"proof"`
+}
+
+// GetProof returns the Merkle proofs of the account with the given address in
+// the state trie and of the given storage slots in its storage trie, at the
+// given block number. The proofs of an account or a slot which doesn't exist
+// prove its absence.
+func (s *PublicBlockChainAPI) GetProof(account names.Account, storageKeys []string, blockNr rpc.BlockNumber) (*AccountResult, error) {
+	address, err := account.Resolve(s.names)
+	if err != nil {
+		return nil, err
+	}
+	statedb, block, err := stateAndBlockByNumber(s.miner, s.bc, blockNr, s.chainDb)
+	if statedb == nil || err != nil {
+		return nil, err
+	}
+	if blockNr == rpc.PendingBlockNumber {
+		// The pending state isn't necessarily hashed into its tries yet
+		statedb = statedb.Copy()
+		statedb.IntermediateRoot(s.config.IsEIP161(block.Number()))
+	}
+
+	codeHash := statedb.GetCodeHash(address)
+	if !statedb.Exist(address) {
+		codeHash = crypto.Keccak256Hash(nil)
+	}
+	storageProof := make([]StorageResult, len(storageKeys))
+	for i, key := range storageKeys {
+		hash := common.HexToHash(key)
+		storageProof[i] = StorageResult{
+			Key:   key,
+			Value: rpc.NewHexNumber(statedb.GetState(address, hash).Big()),
+			Proof: toHexSlice(statedb.GetStorageProof(address, hash)),
+		}
+	}
+	return &AccountResult{
+		Address:      address,
+		AccountProof: toHexSlice(statedb.GetProof(address)),
+		Balance:      rpc.NewHexNumber(statedb.GetBalance(address)),
+		CodeHash:     codeHash,
+		Nonce:        rpc.NewHexNumber(statedb.GetNonce(address)),
+		StorageHash:  statedb.GetStorageRoot(address),
+		StorageProof: storageProof,
+	}, nil
+}
+
+// toHexSlice encodes the given RLP values, such as proof nodes, as hex strings.
+func toHexSlice(values []rlp.RawValue) []string {
+	encoded := make([]string, len(values))
+	for i, value := range values {
+		encoded[i] = common.ToHex(value)
+	}
+	return encoded
+}
+
 // callmsg is the message type used for call transactions.
 type callmsg struct {
 	from          *state.StateObject
@@ -719,6 +1618,7 @@ type callmsg struct {
 	gas, gasPrice *big.Int
 	value         *big.Int
 	data          []byte
+	accessList    types.AccessList
 }
 
 // accessor boilerplate to implement core.Message
@@ -730,18 +1630,23 @@ func (m callmsg) GasPrice() *big.Int                    { return m.gasPrice }
 func (m callmsg) Gas() *big.Int                         { return m.gas }
 func (m callmsg) Value() *big.Int                       { return m.value }
 func (m callmsg) Data() []byte                          { return m.data }
+func (m callmsg) AccessList() types.AccessList          { return m.accessList }
 
 // CallArgs represents the arguments for a call.
 type CallArgs struct {
-	From     common.Address  `json:"from"`
-	To       *common.Address `json:"to"`
-	Gas      *rpc.HexNumber  `json:"gas"`
-	GasPrice *rpc.HexNumber  `json:"gasPrice"`
-	Value    rpc.HexNumber   `json:"value"`
-	Data     string          `json:"data"`
+	From       common.Address    `json:"from"`
+	To         *names.Account    `json:"to"`
+	Gas        *rpc.HexNumber    `json:"gas"`
+	GasPrice   *rpc.HexNumber    `json:"gasPrice"`
+	Value      rpc.HexNumber     `json:"value"`
+	Data       string            `json:"data"`
+	AccessList *types.AccessList `json:"accessList"`
 }
 
 func (s *PublicBlockChainAPI) doCall(args CallArgs, blockNr rpc.BlockNumber) (string, *big.Int, error) {
+	if err := resolveRecipient(args.To, s.names); err != nil {
+		return "0x", nil, err
+	}
 	// Fetch the state associated with the block number
 	stateDb, block, err := stateAndBlockByNumber(s.miner, s.bc, blockNr, s.chainDb)
 	if stateDb == nil || err != nil {
@@ -749,32 +1654,8 @@ func (s *PublicBlockChainAPI) doCall(args CallArgs, blockNr rpc.BlockNumber) (st
 	}
 	stateDb = stateDb.Copy()
 
-	// Retrieve the account state object to interact with
-	var from *state.StateObject
-	if args.From == (common.Address{}) {
-		accounts := s.am.Accounts()
-		if len(accounts) == 0 {
-			from = stateDb.GetOrNewStateObject(common.Address{})
-		} else {
-			from = stateDb.GetOrNewStateObject(accounts[0].Address)
-		}
-	} else {
-		from = stateDb.GetOrNewStateObject(args.From)
-	}
-	from.SetBalance(common.MaxBig)
-
-	// Assemble the CALL invocation
-	msg := callmsg{
-		from:     from,
-		to:       args.To,
-		gas:      args.Gas.BigInt(),
-		gasPrice: args.GasPrice.BigInt(),
-		value:    args.Value.BigInt(),
-		data:     common.FromHex(args.Data),
-	}
-	if msg.gas == nil {
-		msg.gas = big.NewInt(50000000)
-	}
+	msg := newCallMsg(stateDb, s.am, args)
+	msg.from.SetBalance(common.MaxBig)
 	if msg.gasPrice == nil {
 		msg.gasPrice = s.gpo.SuggestPrice()
 	}
@@ -783,13 +1664,41 @@ func (s *PublicBlockChainAPI) doCall(args CallArgs, blockNr rpc.BlockNumber) (st
 	vmenv := core.NewEnv(stateDb, s.config, s.bc, msg, block.Header())
 	gp := new(core.GasPool).AddGas(common.MaxBig)
 
-	res, requiredGas, _, err := core.NewStateTransition(vmenv, msg, gp).TransitionDb()
+	st := core.NewStateTransition(vmenv, msg, gp)
+	res, requiredGas, _, err := st.TransitionDb()
+	if err == nil && st.VMErr() == vm.ErrExecutionReverted {
+		return "0x", requiredGas, newRevertError(res)
+	}
 	if len(res) == 0 { // backwards compatibility
 		return "0x", requiredGas, err
 	}
 	return common.ToHex(res), requiredGas, err
 }
 
+// revertError is the error of calls ended by the REVERT opcode. Its message
+// holds the reason of the revert if it could be decoded, and its data the
+// output of the call.
+type revertError struct {
+	message string
+	output  string
+}
+
+func newRevertError(output []byte) *revertError {
+	err := &revertError{message: vm.ErrExecutionReverted.Error(), output: common.ToHex(output)}
+	if reason, e := abi.UnpackRevert(output); e == nil {
+		err.message += ": " + reason
+	}
+	return err
+}
+
+func (e *revertError) Error() string { return e.message }
+
+// Code returns the JSON-RPC error code of reverted calls.
+func (e *revertError) Code() int { return 3 }
+
+// ErrorData returns the output of the reverted call.
+func (e *revertError) ErrorData() interface{} { return e.output }
+
 // Call executes the given transaction on the state for the given block number.
 // It doesn't make and changes in the state/blockchain and is useful to execute and retrieve values.
 func (s *PublicBlockChainAPI) Call(args CallArgs, blockNr rpc.BlockNumber) (string, error) {
@@ -803,29 +1712,123 @@ func (s *PublicBlockChainAPI) EstimateGas(args CallArgs) (*rpc.HexNumber, error)
 	return rpc.NewHexNumber(gas), err
 }
 
+// SimulationResult is the outcome of a transaction executed on top of the
+// pending state by eth_simulateTransaction and eth_simulateRawTransaction.
+type SimulationResult struct {
+	Success     bool            `json:"success"`
+	Error       string          `json:"error,omitempty"`
+	GasUsed     *rpc.HexNumber  `json:"gasUsed,omitempty"`
+	ReturnValue string          `json:"returnValue,omitempty"`
+	Logs        vm.Logs         `json:"logs,omitempty"`
+	StateDiff   state.StateDiff `json:"stateDiff,omitempty"`
+}
+
+// simulatedMsg is a call message with an explicit nonce, which is checked
+// against the state like the nonce of a transaction.
+type simulatedMsg struct {
+	callmsg
+	nonce uint64
+}
+
+func (m simulatedMsg) Nonce() uint64 { return m.nonce }
+
+// SimulateTransaction executes the given transaction on top of the pending
+// state without signing or broadcasting it, and returns whether it succeeds
+// along with the gas it uses, its logs and the state changes it makes. Unlike
+// eth_call, the balance and nonce of the sender are checked. Missing fields
+// default as in eth_sendTransaction.
+func (s *PublicBlockChainAPI) SimulateTransaction(args SendTxArgs) (*SimulationResult, error) {
+	args = prepareSendTxArgs(args, s.gpo)
+	if err := resolveRecipient(args.To, s.names); err != nil {
+		return nil, err
+	}
+
+	statedb, block, err := stateAndBlockByNumber(s.miner, s.bc, rpc.PendingBlockNumber, s.chainDb)
+	if statedb == nil || err != nil {
+		return nil, err
+	}
+	statedb = statedb.Copy()
+
+	from := statedb.GetOrNewStateObject(args.From)
+	msg := simulatedMsg{
+		callmsg: callmsg{
+			from:     from,
+			to:       args.To.Recipient(),
+			gas:      args.Gas.BigInt(),
+			gasPrice: args.GasPrice.BigInt(),
+			value:    args.Value.BigInt(),
+			data:     common.FromHex(args.Data),
+		},
+		nonce: from.Nonce(),
+	}
+	if args.Nonce != nil {
+		msg.nonce = args.Nonce.Uint64()
+	}
+	if args.AccessList != nil {
+		if !s.config.SupportsTxType(block.Number(), types.AccessListTxType) {
+			return nil, types.ErrTxTypeNotSupported
+		}
+		msg.accessList = *args.AccessList
+	}
+	return s.simulate(statedb, block, msg, common.Hash{}), nil
+}
+
+// SimulateRawTransaction executes the given signed transaction on top of the
+// pending state without broadcasting it, see eth_simulateTransaction.
+func (s *PublicBlockChainAPI) SimulateRawTransaction(encodedTx string) (*SimulationResult, error) {
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(common.FromHex(encodedTx)); err != nil {
+		return nil, err
+	}
+	statedb, block, err := stateAndBlockByNumber(s.miner, s.bc, rpc.PendingBlockNumber, s.chainDb)
+	if statedb == nil || err != nil {
+		return nil, err
+	}
+	if !s.config.SupportsTxType(block.Number(), tx.Type()) {
+		return nil, types.ErrTxTypeNotSupported
+	}
+	tx.SetSigner(s.config.GetSigner(block.Number()))
+	if _, err := tx.From(); err != nil {
+		return nil, err
+	}
+	return s.simulate(statedb.Copy(), block, tx, tx.Hash()), nil
+}
+
+// simulate applies msg on top of the given state and block. Messages which
+// could not be included in the block, eg. because of a wrong nonce or
+// insufficient funds, are reported as failed without any changes.
+func (s *PublicBlockChainAPI) simulate(statedb *state.StateDB, block *types.Block, msg core.Message, txHash common.Hash) *SimulationResult {
+	pre := statedb.Copy()
+	statedb.StartRecord(txHash, common.Hash{}, 0)
+
+	vmenv := core.NewEnv(statedb, s.config, s.bc, msg, block.Header())
+	st := core.NewStateTransition(vmenv, msg, new(core.GasPool).AddGas(block.GasLimit()))
+	ret, _, gas, err := st.TransitionDb()
+	if err != nil {
+		return &SimulationResult{Error: err.Error()}
+	}
+	result := &SimulationResult{
+		Success:     st.VMErr() == nil,
+		GasUsed:     rpc.NewHexNumber(gas),
+		ReturnValue: common.ToHex(ret),
+		Logs:        statedb.GetLogs(txHash),
+		StateDiff:   statedb.Diff(pre),
+	}
+	switch err := st.VMErr(); {
+	case err == vm.ErrExecutionReverted:
+		result.Error = newRevertError(ret).Error()
+	case err != nil:
+		result.Error = err.Error()
+	}
+	return result
+}
+
 // rpcOutputBlock converts the given block to the RPC output which depends on fullTx. If inclTx is true transactions are
 // returned. When fullTx is true the returned block contains full transaction details, otherwise it will only contain
 // transaction hashes.
 func (s *PublicBlockChainAPI) rpcOutputBlock(b *types.Block, inclTx bool, fullTx bool) (map[string]interface{}, error) {
-	fields := map[string]interface{}{
-		"number":           rpc.NewHexNumber(b.Number()),
-		"hash":             b.Hash(),
-		"parentHash":       b.ParentHash(),
-		"nonce":            b.Header().Nonce,
-		"sha3Uncles":       b.UncleHash(),
-		"logsBloom":        b.Bloom(),
-		"stateRoot":        b.Root(),
-		"miner":            b.Coinbase(),
-		"difficulty":       rpc.NewHexNumber(b.Difficulty()),
-		"totalDifficulty":  rpc.NewHexNumber(s.bc.GetTd(b.Hash())),
-		"extraData":        fmt.Sprintf("0x%x", b.Extra()),
-		"size":             rpc.NewHexNumber(b.Size().Int64()),
-		"gasLimit":         rpc.NewHexNumber(b.GasLimit()),
-		"gasUsed":          rpc.NewHexNumber(b.GasUsed()),
-		"timestamp":        rpc.NewHexNumber(b.Time()),
-		"transactionsRoot": b.TxHash(),
-		"receiptsRoot":     b.ReceiptHash(),
-	}
+	fields := s.rpcOutputHeader(b.Header())
+	fields["size"] = rpc.NewHexNumber(b.Size().Int64())
 
 	if inclTx {
 		formatTx := func(tx *types.Transaction) (interface{}, error) {
@@ -864,19 +1867,31 @@ func (s *PublicBlockChainAPI) rpcOutputBlock(b *types.Block, inclTx bool, fullTx
 
 // RPCTransaction represents a transaction that will serialize to the RPC representation of a transaction
 type RPCTransaction struct {
-	BlockHash        common.Hash     `json:"blockHash"`
-	BlockNumber      *rpc.HexNumber  `json:"blockNumber"`
-	From             common.Address  `json:"from"`
-	Gas              *rpc.HexNumber  `json:"gas"`
-	GasPrice         *rpc.HexNumber  `json:"gasPrice"`
-	Hash             common.Hash     `json:"hash"`
-	Input            string          `json:"input"`
-	Nonce            *rpc.HexNumber  `json:"nonce"`
-	To               *common.Address `json:"to"`
-	TransactionIndex *rpc.HexNumber  `json:"transactionIndex"`
-	Value            *rpc.HexNumber  `json:"value"`
-	ReplayProtected  bool            `json:"replayProtected"`
-	ChainId          *big.Int        `json:"chainId,omitempty"`
+	Type             *rpc.HexNumber    `json:"type"`
+	BlockHash        common.Hash       `json:"blockHash"`
+	BlockNumber      *rpc.HexNumber    `json:"blockNumber"`
+	From             common.Address    `json:"from"`
+	Gas              *rpc.HexNumber    `json:"gas"`
+	GasPrice         *rpc.HexNumber    `json:"gasPrice"`
+	Hash             common.Hash       `json:"hash"`
+	Input            string            `json:"input"`
+	Nonce            *rpc.HexNumber    `json:"nonce"`
+	To               *common.Address   `json:"to"`
+	TransactionIndex *rpc.HexNumber    `json:"transactionIndex"`
+	Value            *rpc.HexNumber    `json:"value"`
+	ReplayProtected  bool              `json:"replayProtected"`
+	ChainId          *big.Int          `json:"chainId,omitempty"`
+	AccessList       *types.AccessList `json:"accessList,omitempty"`
+}
+
+// rpcAccessList returns the access list of the given transaction for its RPC
+// representation, nil if the transaction type has no access list.
+func rpcAccessList(tx *types.Transaction) *types.AccessList {
+	if tx.Type() != types.AccessListTxType {
+		return nil
+	}
+	acl := tx.AccessList()
+	return &acl
 }
 
 // newRPCPendingTransaction returns a pending transaction that will serialize to the RPC representation
@@ -891,6 +1906,7 @@ func newRPCPendingTransaction(tx *types.Transaction) *RPCTransaction {
 	}
 
 	return &RPCTransaction{
+		Type:            rpc.NewHexNumber(tx.Type()),
 		From:            from,
 		Gas:             rpc.NewHexNumber(tx.Gas()),
 		GasPrice:        rpc.NewHexNumber(tx.GasPrice()),
@@ -901,6 +1917,7 @@ func newRPCPendingTransaction(tx *types.Transaction) *RPCTransaction {
 		Value:           rpc.NewHexNumber(tx.Value()),
 		ReplayProtected: protected,
 		ChainId:         chainId,
+		AccessList:      rpcAccessList(tx),
 	}
 }
 
@@ -919,6 +1936,7 @@ func newRPCTransactionFromBlockIndex(b *types.Block, txIndex int) (*RPCTransacti
 		from, _ := types.Sender(signer, tx)
 
 		return &RPCTransaction{
+			Type:             rpc.NewHexNumber(tx.Type()),
 			BlockHash:        b.Hash(),
 			BlockNumber:      rpc.NewHexNumber(b.Number()),
 			From:             from,
@@ -932,6 +1950,7 @@ func newRPCTransactionFromBlockIndex(b *types.Block, txIndex int) (*RPCTransacti
 			Value:            rpc.NewHexNumber(tx.Value()),
 			ReplayProtected:  protected,
 			ChainId:          chainId,
+			AccessList:       rpcAccessList(tx),
 		}, nil
 	}
 
@@ -961,6 +1980,8 @@ type PublicTransactionPoolAPI struct {
 	txMu            *sync.Mutex
 	muPendingTxSubs sync.Mutex
 	pendingTxSubs   map[string]rpc.Subscription
+	names           names.Resolver
+	droppedTxs      *droppedTxLog
 }
 
 // NewPublicTransactionPoolAPI creates a new RPC service with methods specific for the transaction pool.
@@ -975,6 +1996,8 @@ func NewPublicTransactionPoolAPI(e *Ethereum) *PublicTransactionPoolAPI {
 		txMu:          &e.txMu,
 		miner:         e.miner,
 		pendingTxSubs: make(map[string]rpc.Subscription),
+		names:         e.names,
+		droppedTxs:    e.droppedTxs,
 	}
 	go api.subscriptionLoop()
 
@@ -1050,13 +2073,27 @@ func (s *PublicTransactionPoolAPI) GetTransactionByBlockHashAndIndex(blockHash c
 	return nil, nil
 }
 
-// GetTransactionCount returns the number of transactions the given address has sent for the given block number
-func (s *PublicTransactionPoolAPI) GetTransactionCount(address common.Address, blockNr rpc.BlockNumber) (*rpc.HexNumber, error) {
+// GetTransactionCount returns the number of transactions the given address or name has sent for the given block number.
+// For the pending block it is the nonce following the account's transactions in the pool, counting those queued
+// behind a nonce gap, so that the returned nonce never replaces a pooled transaction.
+func (s *PublicTransactionPoolAPI) GetTransactionCount(account names.Account, blockNr rpc.BlockNumber) (*rpc.HexNumber, error) {
+	address, err := account.Resolve(s.names)
+	if err != nil {
+		return nil, err
+	}
 	state, _, err := stateAndBlockByNumber(s.miner, s.bc, blockNr, s.chainDb)
 	if state == nil || err != nil {
 		return nil, err
 	}
-	return rpc.NewHexNumber(state.GetNonce(address)), nil
+	nonce := state.GetNonce(address)
+	// The pending count also covers the transactions still in the pool,
+	// including those queued behind a nonce gap
+	if blockNr == rpc.PendingBlockNumber {
+		if pooled := s.txPool.PendingNonce(address); pooled > nonce {
+			nonce = pooled
+		}
+	}
+	return rpc.NewHexNumber(nonce), nil
 }
 
 // getTransactionBlockData fetches the meta data for the given transaction from the chain database. This is useful to
@@ -1131,6 +2168,40 @@ func (s *PublicTransactionPoolAPI) GetTransactionReceipt(txHash common.Hash) (ma
 		return nil, nil
 	}
 
+	return rpcOutputReceipt(receipt, tx, txBlock, blockIndex, index), nil
+}
+
+// GetBlockReceipts returns the receipts of all transactions in the block with
+// the given number or hash, in the order of the transactions. It fails if the
+// block is known but its receipts aren't available, such as for the pending
+// block or blocks whose receipts weren't downloaded or were removed.
+func (s *PublicTransactionPoolAPI) GetBlockReceipts(blockNrOrHash rpc.BlockNumberOrHash) ([]map[string]interface{}, error) {
+	var block *types.Block
+	if blockNrOrHash.BlockHash != nil {
+		block = s.bc.GetBlock(*blockNrOrHash.BlockHash)
+	} else if blockNrOrHash.BlockNumber != nil {
+		block = blockByNumber(s.miner, s.bc, *blockNrOrHash.BlockNumber)
+	}
+	if block == nil {
+		return nil, nil
+	}
+
+	txs := block.Transactions()
+	receipts := s.bc.GetReceiptsByHash(block.Hash())
+	if len(receipts) != len(txs) {
+		return nil, fmt.Errorf("receipts of block #%d [%x…] are not available", block.NumberU64(), block.Hash().Bytes()[:4])
+	}
+
+	fields := make([]map[string]interface{}, len(receipts))
+	for i, receipt := range receipts {
+		fields[i] = rpcOutputReceipt(receipt, txs[i], block.Hash(), block.NumberU64(), uint64(i))
+	}
+	return fields, nil
+}
+
+// rpcOutputReceipt converts the receipt of the given transaction, included in
+// the given block at the given index, into its RPC representation.
+func rpcOutputReceipt(receipt *types.Receipt, tx *types.Transaction, blockHash common.Hash, blockNumber uint64, index uint64) map[string]interface{} {
 	var signer types.Signer = types.BasicSigner{}
 	if tx.Protected() {
 		signer = types.NewChainIdSigner(tx.ChainId())
@@ -1139,9 +2210,9 @@ func (s *PublicTransactionPoolAPI) GetTransactionReceipt(txHash common.Hash) (ma
 
 	fields := map[string]interface{}{
 		"root":              common.Bytes2Hex(receipt.PostState),
-		"blockHash":         txBlock,
-		"blockNumber":       rpc.NewHexNumber(blockIndex),
-		"transactionHash":   txHash,
+		"blockHash":         blockHash,
+		"blockNumber":       rpc.NewHexNumber(blockNumber),
+		"transactionHash":   tx.Hash(),
 		"transactionIndex":  rpc.NewHexNumber(index),
 		"from":              from,
 		"to":                tx.To(),
@@ -1160,7 +2231,7 @@ func (s *PublicTransactionPoolAPI) GetTransactionReceipt(txHash common.Hash) (ma
 		fields["contractAddress"] = receipt.ContractAddress
 	}
 
-	return fields, nil
+	return fields
 }
 
 // sign is a helper function that signs a transaction with the private key of the given address.
@@ -1176,13 +2247,49 @@ func (s *PublicTransactionPoolAPI) sign(addr common.Address, tx *types.Transacti
 
 // SendTxArgs represents the arguments to sumbit a new transaction into the transaction pool.
 type SendTxArgs struct {
-	From     common.Address  `json:"from"`
-	To       *common.Address `json:"to"`
-	Gas      *rpc.HexNumber  `json:"gas"`
-	GasPrice *rpc.HexNumber  `json:"gasPrice"`
-	Value    *rpc.HexNumber  `json:"value"`
-	Data     string          `json:"data"`
-	Nonce    *rpc.HexNumber  `json:"nonce"`
+	From       common.Address    `json:"from"`
+	To         *names.Account    `json:"to"`
+	Gas        *rpc.HexNumber    `json:"gas"`
+	GasPrice   *rpc.HexNumber    `json:"gasPrice"`
+	Value      *rpc.HexNumber    `json:"value"`
+	Data       string            `json:"data"`
+	Nonce      *rpc.HexNumber    `json:"nonce"`
+	AccessList *types.AccessList `json:"accessList"`
+}
+
+// newTransaction creates the unsigned transaction described by the given
+// fields: an access list transaction for the given chain if an access list is
+// given, a legacy transaction otherwise.
+func newTransaction(chainId *big.Int, nonce uint64, to *common.Address, value, gas, gasPrice *big.Int, data []byte, accessList *types.AccessList) *types.Transaction {
+	if accessList != nil {
+		return types.NewTx(&types.AccessListTx{
+			ChainID:    chainId,
+			Nonce:      nonce,
+			GasPrice:   gasPrice,
+			Gas:        gas,
+			To:         to,
+			Value:      value,
+			Data:       data,
+			AccessList: *accessList,
+			V:          new(big.Int),
+			R:          new(big.Int),
+			S:          new(big.Int),
+		})
+	}
+	if to == nil {
+		return types.NewContractCreation(nonce, value, gas, gasPrice, data)
+	}
+	return types.NewTransaction(nonce, *to, value, gas, gasPrice, data)
+}
+
+// resolveRecipient resolves the recipient of a call or transaction, if it was
+// given by name, through the configured name resolver.
+func resolveRecipient(to *names.Account, resolver names.Resolver) error {
+	if to == nil {
+		return nil
+	}
+	_, err := to.Resolve(resolver)
+	return err
 }
 
 // prepareSendTxArgs is a helper function that fills in default values for unspecified tx fields.
@@ -1208,8 +2315,7 @@ func submitTransaction(bc *core.BlockChain, txPool *core.TxPool, tx *types.Trans
 		return common.Hash{}, err
 	}
 
-	txPool.SetLocal(signedTx)
-	if err := txPool.Add(signedTx); err != nil {
+	if err := txPool.AddLocal(signedTx); err != nil {
 		return common.Hash{}, err
 	}
 
@@ -1228,6 +2334,9 @@ func submitTransaction(bc *core.BlockChain, txPool *core.TxPool, tx *types.Trans
 // transaction pool.
 func (s *PublicTransactionPoolAPI) SendTransaction(args SendTxArgs) (common.Hash, error) {
 	args = prepareSendTxArgs(args, s.gpo)
+	if err := resolveRecipient(args.To, s.names); err != nil {
+		return common.Hash{}, err
+	}
 
 	s.txMu.Lock()
 	defer s.txMu.Unlock()
@@ -1236,12 +2345,7 @@ func (s *PublicTransactionPoolAPI) SendTransaction(args SendTxArgs) (common.Hash
 		args.Nonce = rpc.NewHexNumber(s.txPool.State().GetNonce(args.From))
 	}
 
-	var tx *types.Transaction
-	if args.To == nil {
-		tx = types.NewContractCreation(args.Nonce.Uint64(), args.Value.BigInt(), args.Gas.BigInt(), args.GasPrice.BigInt(), common.FromHex(args.Data))
-	} else {
-		tx = types.NewTransaction(args.Nonce.Uint64(), *args.To, args.Value.BigInt(), args.Gas.BigInt(), args.GasPrice.BigInt(), common.FromHex(args.Data))
-	}
+	tx := newTransaction(s.bc.Config().GetChainID(), args.Nonce.Uint64(), args.To.Recipient(), args.Value.BigInt(), args.Gas.BigInt(), args.GasPrice.BigInt(), common.FromHex(args.Data), args.AccessList)
 
 	signer := s.bc.Config().GetSigner(s.bc.CurrentBlock().Number())
 	tx.SetSigner(signer)
@@ -1256,29 +2360,107 @@ func (s *PublicTransactionPoolAPI) SendTransaction(args SendTxArgs) (common.Hash
 
 // SendRawTransaction will add the signed transaction to the transaction pool.
 // The sender is responsible for signing the transaction and using the correct nonce.
+// Rejected transactions fail with error code -32003 and the machine readable
+// reason of the rejection in the error data, e.g. {"reason": "nonceTooLow"}.
 func (s *PublicTransactionPoolAPI) SendRawTransaction(encodedTx string) (string, error) {
 	tx := new(types.Transaction)
-	if err := rlp.DecodeBytes(common.FromHex(encodedTx), tx); err != nil {
-		return "", err
+	if err := tx.UnmarshalBinary(common.FromHex(encodedTx)); err != nil {
+		return "", &txRejectedError{err, txRejectedEncoding}
 	}
 
-	s.txPool.SetLocal(tx)
-	if err := s.txPool.Add(tx); err != nil {
+	if err := s.addRawTransaction(tx); err != nil {
 		return "", err
 	}
 
+	return tx.Hash().Hex(), nil
+}
+
+// txRejectedEncoding is the reason of the rejection of raw transactions which
+// can't be decoded.
+const txRejectedEncoding = "invalidEncoding"
+
+// txRejectedError is the error of raw transactions rejected by the transaction
+// pool. Its data holds the machine readable reason of the rejection, one of
+// those of core.TxRejectionReason or invalidEncoding, so that clients don't
+// need to match the error message.
+type txRejectedError struct {
+	err    error
+	reason string
+}
+
+func (e *txRejectedError) Error() string { return e.err.Error() }
+
+// Code returns the JSON-RPC error code of rejected transactions.
+func (e *txRejectedError) Code() int { return -32003 }
+
+// ErrorData returns the reason of the rejection.
+func (e *txRejectedError) ErrorData() interface{} {
+	return map[string]string{"reason": e.reason}
+}
+
+// addRawTransaction adds a signed transaction to the transaction pool. Errors
+// of transactions rejected by the pool are returned as txRejectedError.
+func (s *PublicTransactionPoolAPI) addRawTransaction(tx *types.Transaction) error {
+	if err := s.txPool.AddLocal(tx); err != nil {
+		if reason := core.TxRejectionReason(err); reason != "" {
+			return &txRejectedError{err, reason}
+		}
+		return err
+	}
+
 	if tx.To() == nil {
 		from, err := tx.From()
 		if err != nil {
-			return "", err
+			return err
 		}
 		addr := crypto.CreateAddress(from, tx.Nonce())
 		glog.V(logger.Info).Infof("Tx(%x) created: %x\n", tx.Hash(), addr)
 	} else {
 		glog.V(logger.Info).Infof("Tx(%x) to: %x\n", tx.Hash(), tx.To())
 	}
+	return nil
+}
 
-	return tx.Hash().Hex(), nil
+// maxRawTransactionBatch is the number of transactions eth_sendRawTransactionBatch
+// accepts at most in a single request.
+const maxRawTransactionBatch = 1000
+
+// RawTransactionResult is the outcome of one of the transactions of
+// eth_sendRawTransactionBatch.
+type RawTransactionResult struct {
+	Hash     *common.Hash `json:"hash"` // Hash of the transaction, nil if it couldn't be decoded
+	Accepted bool         `json:"accepted"`
+	Error    string       `json:"error,omitempty"`
+	Reason   string       `json:"reason,omitempty"` // Machine readable reason of the rejection, as in eth_sendRawTransaction errors
+}
+
+// SendRawTransactionBatch adds the given signed transactions to the transaction
+// pool in order, as eth_sendRawTransaction does for each, and reports for each
+// whether it was accepted, or the error it was rejected with. A rejected
+// transaction doesn't stop the following ones, which may however depend on it,
+// e.g. as the previous nonce of their sender.
+func (s *PublicTransactionPoolAPI) SendRawTransactionBatch(encodedTxs []string) ([]*RawTransactionResult, error) {
+	if len(encodedTxs) > maxRawTransactionBatch {
+		return nil, fmt.Errorf("batch of %d transactions exceeds %d", len(encodedTxs), maxRawTransactionBatch)
+	}
+	results := make([]*RawTransactionResult, len(encodedTxs))
+	for i, encodedTx := range encodedTxs {
+		tx := new(types.Transaction)
+		if err := tx.UnmarshalBinary(common.FromHex(encodedTx)); err != nil {
+			results[i] = &RawTransactionResult{Error: err.Error(), Reason: txRejectedEncoding}
+			continue
+		}
+		hash := tx.Hash()
+		if err := s.addRawTransaction(tx); err != nil {
+			results[i] = &RawTransactionResult{Hash: &hash, Error: err.Error()}
+			if rejected, ok := err.(*txRejectedError); ok {
+				results[i].Reason = rejected.reason
+			}
+			continue
+		}
+		results[i] = &RawTransactionResult{Hash: &hash, Accepted: true}
+	}
+	return results, nil
 }
 
 // Sign signs the given hash using the key that matches the address. The key must be
@@ -1290,13 +2472,14 @@ func (s *PublicTransactionPoolAPI) Sign(addr common.Address, hash common.Hash) (
 
 // SignTransactionArgs represents the arguments to sign a transaction.
 type SignTransactionArgs struct {
-	From     common.Address
-	To       *common.Address
-	Nonce    *rpc.HexNumber
-	Value    *rpc.HexNumber
-	Gas      *rpc.HexNumber
-	GasPrice *rpc.HexNumber
-	Data     string
+	From       common.Address
+	To         *names.Account
+	Nonce      *rpc.HexNumber
+	Value      *rpc.HexNumber
+	Gas        *rpc.HexNumber
+	GasPrice   *rpc.HexNumber
+	Data       string
+	AccessList *types.AccessList
 
 	BlockNumber int64
 }
@@ -1394,6 +2577,9 @@ func newTx(t *types.Transaction) *Tx {
 // The node needs to have the private key of the account corresponding with
 // the given from address and it needs to be unlocked.
 func (s *PublicTransactionPoolAPI) SignTransaction(args SignTransactionArgs) (*SignTransactionResult, error) {
+	if err := resolveRecipient(args.To, s.names); err != nil {
+		return nil, err
+	}
 	if args.Gas == nil {
 		args.Gas = rpc.NewHexNumber(defaultGas)
 	}
@@ -1411,19 +2597,14 @@ func (s *PublicTransactionPoolAPI) SignTransaction(args SignTransactionArgs) (*S
 		args.Nonce = rpc.NewHexNumber(s.txPool.State().GetNonce(args.From))
 	}
 
-	var tx *types.Transaction
-	if args.To == nil {
-		tx = types.NewContractCreation(args.Nonce.Uint64(), args.Value.BigInt(), args.Gas.BigInt(), args.GasPrice.BigInt(), common.FromHex(args.Data))
-	} else {
-		tx = types.NewTransaction(args.Nonce.Uint64(), *args.To, args.Value.BigInt(), args.Gas.BigInt(), args.GasPrice.BigInt(), common.FromHex(args.Data))
-	}
+	tx := newTransaction(s.bc.Config().GetChainID(), args.Nonce.Uint64(), args.To.Recipient(), args.Value.BigInt(), args.Gas.BigInt(), args.GasPrice.BigInt(), common.FromHex(args.Data), args.AccessList)
 
 	signedTx, err := s.sign(args.From, tx)
 	if err != nil {
 		return nil, err
 	}
 
-	data, err := rlp.EncodeToBytes(signedTx)
+	data, err := signedTx.MarshalBinary()
 	if err != nil {
 		return nil, err
 	}
@@ -1538,6 +2719,79 @@ func (api *PrivateAdminAPI) SetSolc(path string) (string, error) {
 	return solc.Info(), nil
 }
 
+// ChainParams is the chain configuration a node runs with.
+type ChainParams struct {
+	Name      string          `json:"name"`
+	NetworkID int             `json:"networkId"`
+	ChainID   *rpc.HexNumber  `json:"chainId"`
+	Genesis   common.Hash     `json:"genesis"`
+	Head      *rpc.HexNumber  `json:"head"`
+	Forks     core.Forks      `json:"forks"`     // Fork blocks along with the features they configure
+	BadHashes []*core.BadHash `json:"badHashes"` // Blocks refused for known consensus issues
+	Reward    *ChainReward    `json:"reward"`    // Reward schedule at the head block
+	Bootnodes []string        `json:"bootnodes"`
+}
+
+// ChainReward is the block reward schedule of a chain at a given block.
+type ChainReward struct {
+	Type            string          `json:"type"`            // "static", or "ecip1017" for rewards decreasing by era
+	EraLength       *rpc.HexNumber  `json:"eraLength"`       // Blocks per era, nil for a static reward
+	Era             *rpc.HexNumber  `json:"era"`             // Zero-based era of the block, nil for a static reward
+	NextEraBlock    *rpc.HexNumber  `json:"nextEraBlock"`    // First block of the next era, nil for a static reward
+	BlockReward     *rpc.HexNumber  `json:"blockReward"`     // Base reward of the miner of the block
+	TreasuryPercent *rpc.HexNumber  `json:"treasuryPercent"` // Percentage of the mining rewards diverted to the treasury
+	TreasuryAddress *common.Address `json:"treasuryAddress"` // Treasury credited with the diverted share, nil if burnt
+}
+
+// ChainParams returns the chain configuration the node runs with: its fork blocks and the features they configure, its
+// chain ID, the reward schedule at the head block and its bootnodes, so that external tools can verify the node runs the
+// expected rule set.
+func (api *PrivateAdminAPI) ChainParams() *ChainParams {
+	config, head := api.eth.chainConfig, api.eth.blockchain.CurrentBlock()
+	params := &ChainParams{
+		Name:      api.eth.chainName,
+		NetworkID: api.eth.netVersionId,
+		ChainID:   rpc.NewHexNumber(config.GetChainID()),
+		Genesis:   api.eth.blockchain.Genesis().Hash(),
+		Head:      rpc.NewHexNumber(head.Number()),
+		Forks:     config.Forks,
+		BadHashes: config.BadHashes,
+		Reward:    chainReward(config, head.Header()),
+		Bootnodes: make([]string, len(api.eth.bootnodes)),
+	}
+	for i, node := range api.eth.bootnodes {
+		params.Bootnodes[i] = node.String()
+	}
+	return params
+}
+
+// chainReward returns the reward schedule of the chain at the given block.
+func chainReward(config *core.ChainConfig, header *types.Header) *ChainReward {
+	reward := &ChainReward{
+		Type:        "static",
+		BlockReward: rpc.NewHexNumber(core.CalcBlockReward(config, header, nil).Base),
+	}
+	if feat, _, ok := config.HasFeature("reward"); ok {
+		reward.Type, _ = feat.GetString("type")
+		if eraLength, ok := feat.GetBigInt("era"); ok && eraLength.Sign() > 0 {
+			era := core.GetBlockEra(header.Number, eraLength)
+			reward.EraLength = rpc.NewHexNumber(eraLength)
+			reward.Era = rpc.NewHexNumber(era)
+			reward.NextEraBlock = rpc.NewHexNumber(new(big.Int).Add(new(big.Int).Mul(new(big.Int).Add(era, common.Big1), eraLength), common.Big1))
+		}
+	}
+	if feat, _, ok := config.GetFeature(header.Number, "treasury"); ok {
+		if percent, ok := feat.GetBigInt("percent"); ok {
+			reward.TreasuryPercent = rpc.NewHexNumber(percent)
+		}
+		if hex, ok := feat.GetString("address"); ok && common.IsHexAddress(hex) {
+			address := common.HexToAddress(hex)
+			reward.TreasuryAddress = &address
+		}
+	}
+	return reward
+}
+
 // ExportChain exports the current blockchain into a local file.
 func (api *PrivateAdminAPI) ExportChain(file string) (bool, error) {
 	// Make sure we can create the file to export into
@@ -1625,7 +2879,7 @@ func (api *PublicDebugAPI) DumpBlock(number uint64) (state.Dump, error) {
 	if block == nil {
 		return state.Dump{}, fmt.Errorf("block #%d not found", number)
 	}
-	stateDb, err := api.eth.BlockChain().StateAt(block.Root())
+	stateDb, err := stateAtBlock(api.eth.BlockChain(), block)
 	if err != nil {
 		return state.Dump{}, err
 	}
@@ -1638,13 +2892,20 @@ func (api *PublicDebugAPI) AccountExist(address common.Address, number uint64) (
 	if block == nil {
 		return false, fmt.Errorf("block #%d not found", number)
 	}
-	stateDb, err := api.eth.BlockChain().StateAt(block.Root())
+	stateDb, err := stateAtBlock(api.eth.BlockChain(), block)
 	if err != nil {
 		return false, err
 	}
 	return stateDb.Exist(address), nil
 }
 
+// AvailableStates returns the range of canonical blocks whose state the node
+// retains, besides the genesis block whose state is always retained. The states
+// of the blocks below it were skipped by a fast sync.
+func (api *PublicDebugAPI) AvailableStates() *AvailableStates {
+	return availableStates(api.eth.BlockChain())
+}
+
 // GetBlockRlp retrieves the RLP encoded for of a single block.
 func (api *PublicDebugAPI) GetBlockRlp(number uint64) (string, error) {
 	block := api.eth.BlockChain().GetBlockByNumber(number)
@@ -1786,6 +3047,23 @@ func (api *PublicDebugAPI) Vmodule(s string) (string, error) {
 	return ns, err
 }
 
+// SetCacheSize implements api method debug_setCacheSize, resizing the trie
+// node cache to the given number of megabytes on the fly. The database read
+// cache is sized when the database is opened and can't be changed.
+// As with debug_verbosity, debug.setCacheSize(0) returns the current size.
+func (api *PublicDebugAPI) SetCacheSize(mb uint64) (int, error) {
+	if mb == 0 {
+		return trie.CacheSize() / (1024 * 1024), nil
+	}
+	if mb > math.MaxInt32 {
+		return -1, fmt.Errorf("cache size too large: %d MB", mb)
+	}
+	trie.SetCacheSize(int(mb) * 1024 * 1024)
+	glog.V(logger.Warn).Infof("Set trie cache size: %dMB", mb)
+	glog.D(logger.Warn).Warnf("Set trie cache size: %dMB", mb)
+	return int(mb), nil
+}
+
 // ExecutionResult groups all structured logs emitted by the EVM
 // while replaying a transaction in debug mode as well as the amount of
 // gas used and the return value
@@ -1794,19 +3072,80 @@ type ExecutionResult struct {
 	ReturnValue string   `json:"returnValue"`
 }
 
-// TraceCall executes a call and returns the amount of gas and optionally returned values.
-func (s *PublicBlockChainAPI) TraceCall(args CallArgs, blockNr rpc.BlockNumber) (*ExecutionResult, error) {
+// TraceConfig holds the optional parameters of the trace methods.
+type TraceConfig struct {
+	// Tracer selects the tracer to run the execution with. Supported values are
+	// "callTracer", which lists all internal calls, contract creations and
+	// suicides, and "stateDiffTracer", which reports the balance, nonce, code
+	// and storage of every modified account before and after the execution.
+	// When empty, only gas usage and return value are reported.
+	Tracer string `json:"tracer"`
+}
+
+// traceMessage applies msg in the given environment, tracing it as requested by
+// config, and assembles the result. The statedb must be the one backing vmenv.
+func traceMessage(statedb *state.StateDB, vmenv *core.VMEnv, msg core.Message, gp *core.GasPool, config *TraceConfig) (interface{}, error) {
+	var (
+		callTracer *vm.CallTracer
+		pre        *state.StateDB
+	)
+	if config != nil {
+		switch config.Tracer {
+		case "":
+		case "callTracer":
+			callTracer = vm.NewCallTracer()
+			vmenv.SetTracer(callTracer)
+		case "stateDiffTracer":
+			pre = statedb.Copy()
+		default:
+			return nil, fmt.Errorf("unknown tracer %q", config.Tracer)
+		}
+	}
+	ret, gas, err := core.ApplyMessage(vmenv, msg, gp)
+	if gas == nil {
+		return nil, err
+	}
+	switch {
+	case callTracer != nil:
+		return callTracer.Result(), nil
+	case pre != nil:
+		return statedb.Diff(pre), nil
+	}
+	return &ExecutionResult{
+		Gas:         gas,
+		ReturnValue: fmt.Sprintf("%x", ret),
+	}, nil
+}
+
+// callEnv assembles the state, environment and message for executing a call
+// on top of the state of the given block number.
+func callEnv(config *core.ChainConfig, bc *core.BlockChain, m *miner.Miner, chainDb ethdb.Database, am *accounts.Manager, args CallArgs, blockNr rpc.BlockNumber) (*state.StateDB, *core.VMEnv, core.Message, error) {
 	// Fetch the state associated with the block number
-	stateDb, block, err := stateAndBlockByNumber(s.miner, s.bc, blockNr, s.chainDb)
+	stateDb, block, err := stateAndBlockByNumber(m, bc, blockNr, chainDb)
 	if stateDb == nil || err != nil {
-		return nil, err
+		return nil, nil, nil, err
 	}
 	stateDb = stateDb.Copy()
 
+	msg := newCallMsg(stateDb, am, args)
+	msg.from.SetBalance(common.MaxBig)
+	if msg.gas.Sign() == 0 {
+		msg.gas = big.NewInt(defaultCallGas)
+	}
+	if msg.gasPrice == nil || msg.gasPrice.Sign() == 0 {
+		msg.gasPrice = new(big.Int).Mul(big.NewInt(50), common.Shannon)
+	}
+	return stateDb, core.NewEnv(stateDb, config, bc, msg, block.Header()), msg, nil
+}
+
+// newCallMsg assembles the message of a call on top of the given state. The
+// call is made from the first account of the account manager if no sender is
+// given, and gets defaultCallGas if no gas is given.
+func newCallMsg(stateDb *state.StateDB, am *accounts.Manager, args CallArgs) callmsg {
 	// Retrieve the account state object to interact with
 	var from *state.StateObject
 	if args.From == (common.Address{}) {
-		accounts := s.am.Accounts()
+		accounts := am.Accounts()
 		if len(accounts) == 0 {
 			from = stateDb.GetOrNewStateObject(common.Address{})
 		} else {
@@ -1815,111 +3154,341 @@ func (s *PublicBlockChainAPI) TraceCall(args CallArgs, blockNr rpc.BlockNumber)
 	} else {
 		from = stateDb.GetOrNewStateObject(args.From)
 	}
-	from.SetBalance(common.MaxBig)
 
 	// Assemble the CALL invocation
 	msg := callmsg{
 		from:     from,
-		to:       args.To,
+		to:       args.To.Recipient(),
 		gas:      args.Gas.BigInt(),
 		gasPrice: args.GasPrice.BigInt(),
 		value:    args.Value.BigInt(),
 		data:     common.FromHex(args.Data),
 	}
-	if msg.gas.Sign() == 0 {
-		msg.gas = big.NewInt(50000000)
+	if args.AccessList != nil {
+		msg.accessList = *args.AccessList
 	}
-	if msg.gasPrice.Sign() == 0 {
-		msg.gasPrice = new(big.Int).Mul(big.NewInt(50), common.Shannon)
+	if msg.gas == nil {
+		msg.gas = big.NewInt(defaultCallGas)
 	}
+	return msg
+}
 
-	// Execute the call and return
-	vmenv := core.NewEnv(stateDb, s.config, s.bc, msg, block.Header())
-	gp := new(core.GasPool).AddGas(common.MaxBig)
+const (
+	// defaultCallGas is the gas of the calls not given any.
+	defaultCallGas = 50000000
 
-	ret, gas, err := core.ApplyMessage(vmenv, msg, gp)
-	return &ExecutionResult{
-		Gas:         gas,
-		ReturnValue: fmt.Sprintf("%x", ret),
-	}, nil
+	// maxMulticallCalls is the number of calls eth_multicall executes at most
+	// in a single request.
+	maxMulticallCalls = 100
+
+	// maxMulticallGas is the gas available to all the calls of a single
+	// eth_multicall request together, as much as a single call gets by default.
+	maxMulticallGas = defaultCallGas
+)
+
+// CallResult is the outcome of one of the calls of eth_multicall.
+type CallResult struct {
+	ReturnValue string         `json:"returnValue"`
+	GasUsed     *rpc.HexNumber `json:"gasUsed,omitempty"`
+	Error       string         `json:"error,omitempty"`
+}
+
+// Multicall executes the given calls in order on top of the state of the given
+// block number without altering it, every call seeing the state changes made
+// by the previous ones. A failing call doesn't stop the following ones, its
+// error is reported in its result instead. Unlike eth_call, the balance of the
+// senders isn't topped up, and the gas price defaults to zero.
+//
+// The calls share maxMulticallGas, the calls not given any gas getting what is
+// left of it, and there may be no more than maxMulticallCalls of them.
+func (s *PublicBlockChainAPI) Multicall(calls []CallArgs, blockNr rpc.BlockNumber) ([]*CallResult, error) {
+	if len(calls) > maxMulticallCalls {
+		return nil, fmt.Errorf("batch of %d calls exceeds %d", len(calls), maxMulticallCalls)
+	}
+	stateDb, block, err := stateAndBlockByNumber(s.miner, s.bc, blockNr, s.chainDb)
+	if stateDb == nil || err != nil {
+		return nil, err
+	}
+	stateDb = stateDb.Copy()
+
+	gp := new(core.GasPool).AddGas(big.NewInt(maxMulticallGas))
+	results := make([]*CallResult, len(calls))
+	for i, args := range calls {
+		if err := resolveRecipient(args.To, s.names); err != nil {
+			return nil, err
+		}
+		msg := newCallMsg(stateDb, s.am, args)
+		if args.Gas == nil {
+			msg.gas = new(big.Int).Set((*big.Int)(gp))
+		}
+		if msg.gasPrice == nil {
+			msg.gasPrice = new(big.Int)
+		}
+		vmenv := core.NewEnv(stateDb, s.config, s.bc, msg, block.Header())
+		st := core.NewStateTransition(vmenv, msg, gp)
+
+		left := new(big.Int).Set((*big.Int)(gp))
+		ret, _, gas, err := st.TransitionDb()
+		switch {
+		case err != nil:
+			// Invalid calls use no gas, even if they bought it
+			(*big.Int)(gp).Set(left)
+			results[i] = &CallResult{ReturnValue: "0x", Error: err.Error()}
+		case st.VMErr() == vm.ErrExecutionReverted:
+			results[i] = &CallResult{ReturnValue: common.ToHex(ret), GasUsed: rpc.NewHexNumber(gas), Error: newRevertError(ret).Error()}
+		case st.VMErr() != nil:
+			results[i] = &CallResult{ReturnValue: "0x", GasUsed: rpc.NewHexNumber(gas), Error: st.VMErr().Error()}
+		default:
+			results[i] = &CallResult{ReturnValue: common.ToHex(ret), GasUsed: rpc.NewHexNumber(gas)}
+		}
+		stateDb.IntermediateRoot(s.config.IsEIP161(block.Number()))
+	}
+	return results, nil
+}
+
+// AccessListResult is the result of eth_createAccessList: the access list of a
+// call and the gas the call uses along with it.
+type AccessListResult struct {
+	AccessList types.AccessList `json:"accessList"`
+	GasUsed    *rpc.HexNumber   `json:"gasUsed"`
+}
+
+// maxAccessListRounds is the number of times eth_createAccessList executes a call
+// at most, waiting for its access list to settle.
+const maxAccessListRounds = 16
+
+// errAccessListUnstable is returned by eth_createAccessList when the access list
+// of a call keeps changing with the list the call is executed with.
+var errAccessListUnstable = fmt.Errorf("access list not settled after %d executions", maxAccessListRounds)
+
+// CreateAccessList executes the given call on top of the state of the given
+// block number and returns the EIP-2930 access list of the accounts and storage
+// slots it accesses, extending the access list of the call if it has one. The
+// call is repeated with the recorded list until the list doesn't change.
+//
+// The gas used includes the intrinsic cost of the list, but accesses are priced
+// the same whether listed or not, as this client doesn't implement the EIP-2929
+// warm and cold access costs. It is not the gas the list saves on chains that do.
+func (s *PublicBlockChainAPI) CreateAccessList(args CallArgs, blockNr rpc.BlockNumber) (*AccessListResult, error) {
+	if err := resolveRecipient(args.To, s.names); err != nil {
+		return nil, err
+	}
+	for i := 0; i < maxAccessListRounds; i++ {
+		statedb, vmenv, msg, err := callEnv(s.config, s.bc, s.miner, s.chainDb, s.am, args, blockNr)
+		if statedb == nil || err != nil {
+			return nil, err
+		}
+		if !s.config.SupportsTxType(vmenv.BlockNumber(), types.AccessListTxType) {
+			return nil, fmt.Errorf("access list transactions are not enabled at block #%v", vmenv.BlockNumber())
+		}
+		from, _ := msg.From()
+		tracer := core.NewAccessListTracer(msg.AccessList(), from, msg.To())
+		vmenv.SetTracer(tracer)
+		_, gas, err := core.ApplyMessage(vmenv, msg, new(core.GasPool).AddGas(common.MaxBig))
+		if err != nil {
+			return nil, err
+		}
+		acl := tracer.AccessList()
+		if args.AccessList != nil && reflect.DeepEqual(acl, *args.AccessList) {
+			return &AccessListResult{AccessList: acl, GasUsed: rpc.NewHexNumber(gas)}, nil
+		}
+		args.AccessList = &acl
+	}
+	return nil, errAccessListUnstable
+}
+
+// TraceCall executes a call and returns the amount of gas and optionally returned values.
+func (s *PublicBlockChainAPI) TraceCall(args CallArgs, blockNr rpc.BlockNumber) (*ExecutionResult, error) {
+	if err := resolveRecipient(args.To, s.names); err != nil {
+		return nil, err
+	}
+	statedb, vmenv, msg, err := callEnv(s.config, s.bc, s.miner, s.chainDb, s.am, args, blockNr)
+	if statedb == nil || err != nil {
+		return nil, err
+	}
+	result, err := traceMessage(statedb, vmenv, msg, new(core.GasPool).AddGas(common.MaxBig), nil)
+	if err != nil {
+		return nil, err
+	}
+	return result.(*ExecutionResult), nil
+}
+
+// TraceCall executes a call on top of the state of the given block number
+// without altering it. The config selects the tracer to run the call with,
+// see TraceConfig.
+func (api *PublicDebugAPI) TraceCall(args CallArgs, blockNr rpc.BlockNumber, config *TraceConfig) (interface{}, error) {
+	if err := resolveRecipient(args.To, api.eth.names); err != nil {
+		return nil, err
+	}
+	statedb, vmenv, msg, err := callEnv(api.eth.chainConfig, api.eth.BlockChain(), api.eth.Miner(), api.eth.ChainDb(), api.eth.AccountManager(), args, blockNr)
+	if statedb == nil || err != nil {
+		return nil, err
+	}
+	return traceMessage(statedb, vmenv, msg, new(core.GasPool).AddGas(common.MaxBig), config)
 }
 
-// TraceTransaction returns the amount of gas and execution result of the given transaction.
-func (s *PublicDebugAPI) TraceTransaction(txHash common.Hash) (*ExecutionResult, error) {
-	var result *ExecutionResult
+// TraceTransaction returns the amount of gas and execution result of the given
+// transaction. The config selects the tracer to run the transaction with, see
+// TraceConfig.
+func (s *PublicDebugAPI) TraceTransaction(txHash common.Hash, config *TraceConfig) (interface{}, error) {
 	tx, blockHash, _, txIndex := core.GetTransaction(s.eth.ChainDb(), txHash)
 	if tx == nil {
-		return result, fmt.Errorf("tx '%x' not found", txHash)
+		return nil, fmt.Errorf("tx '%x' not found", txHash)
 	}
 
-	msg, vmenv, err := s.computeTxEnv(blockHash, int(txIndex))
+	msg, vmenv, statedb, err := s.computeTxEnv(blockHash, int(txIndex))
 	if err != nil {
 		return nil, err
 	}
+	return traceMessage(statedb, vmenv, msg, new(core.GasPool).AddGas(tx.Gas()), config)
+}
 
-	gp := new(core.GasPool).AddGas(tx.Gas())
-	ret, gas, err := core.ApplyMessage(vmenv, msg, gp)
-	return &ExecutionResult{
-		Gas:         gas,
-		ReturnValue: fmt.Sprintf("%x", ret),
-	}, nil
+// TraceBlockByNumber replays all transactions of the given block and returns
+// the trace of each of them, in order. The config selects the tracer to run
+// the transactions with, see TraceConfig.
+func (s *PublicDebugAPI) TraceBlockByNumber(number uint64, config *TraceConfig) ([]interface{}, error) {
+	block := s.eth.BlockChain().GetBlockByNumber(number)
+	if block == nil {
+		return nil, fmt.Errorf("block #%d not found", number)
+	}
+	return s.traceBlock(block, config)
+}
+
+// traceBlock replays the transactions of the given block on top of the state of
+// its parent, returning the trace of each with the given tracer.
+func (s *PublicDebugAPI) traceBlock(block *types.Block, config *TraceConfig) ([]interface{}, error) {
+	txs := block.Transactions()
+	if len(txs) == 0 {
+		return []interface{}{}, nil
+	}
+	msg, vmenv, statedb, err := s.computeTxEnv(block.Hash(), 0)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]interface{}, len(txs))
+	for i, tx := range txs {
+		if i > 0 {
+			if msg, vmenv, err = s.txEnv(statedb, block, tx); err != nil {
+				return nil, err
+			}
+		}
+		if results[i], err = traceMessage(statedb, vmenv, msg, new(core.GasPool).AddGas(tx.Gas()), config); err != nil {
+			return nil, fmt.Errorf("tx %x failed: %v", tx.Hash(), err)
+		}
+		statedb.IntermediateRoot(s.eth.chainConfig.IsEIP161(block.Number()))
+	}
+	return results, nil
+}
+
+// RevertReason is the result of debug_getRevertReason.
+type RevertReason struct {
+	Reverted bool   `json:"reverted"`
+	Reason   string `json:"reason,omitempty"` // decoded reason, if any
+	Output   string `json:"output,omitempty"` // output of the reverted transaction
+}
+
+// GetRevertReason replays the transaction with the given hash and reports
+// whether its execution was reverted by the REVERT opcode, along with the
+// reason it was reverted with. Reasons aren't stored along with the receipts,
+// the transaction is executed again on top of the state of its block.
+func (s *PublicDebugAPI) GetRevertReason(txHash common.Hash) (*RevertReason, error) {
+	tx, blockHash, _, txIndex := core.GetTransaction(s.eth.ChainDb(), txHash)
+	if tx == nil {
+		return nil, fmt.Errorf("tx '%x' not found", txHash)
+	}
+	msg, vmenv, _, err := s.computeTxEnv(blockHash, int(txIndex))
+	if err != nil {
+		return nil, err
+	}
+	st := core.NewStateTransition(vmenv, msg, new(core.GasPool).AddGas(tx.Gas()))
+	ret, _, _, err := st.TransitionDb()
+	if err != nil {
+		return nil, err
+	}
+	if st.VMErr() != vm.ErrExecutionReverted {
+		return &RevertReason{}, nil
+	}
+	result := &RevertReason{Reverted: true, Output: common.ToHex(ret)}
+	result.Reason, _ = abi.UnpackRevert(ret)
+	return result, nil
+}
+
+// ReplayBlock re-executes the imported block with the given number from the
+// state of its parent and reports any difference between the results and the
+// stored header and receipts.
+func (s *PublicDebugAPI) ReplayBlock(number uint64) (*core.ReplayResult, error) {
+	block := s.eth.BlockChain().GetBlockByNumber(number)
+	if block == nil {
+		return nil, fmt.Errorf("block #%d not found", number)
+	}
+	return s.eth.BlockChain().ReplayBlock(block.Hash())
+}
+
+// txEnv assembles the message and execution environment of a transaction
+// included in the given block, on top of the given state.
+func (s *PublicDebugAPI) txEnv(statedb *state.StateDB, block *types.Block, tx *types.Transaction) (core.Message, *core.VMEnv, error) {
+	// Assemble the transaction call message
+	// Retrieve the account state object to interact with
+	var from *state.StateObject
+	fromAddress, e := tx.From()
+	if e != nil {
+		return nil, nil, e
+	}
+	if fromAddress == (common.Address{}) {
+		from = statedb.GetOrNewStateObject(common.Address{})
+	} else {
+		from = statedb.GetOrNewStateObject(fromAddress)
+	}
+
+	msg := callmsg{
+		from:     from,
+		to:       tx.To(),
+		gas:      tx.Gas(),
+		gasPrice: tx.GasPrice(),
+		value:    tx.Value(),
+		data:     tx.Data(),
+	}
+	return msg, core.NewEnv(statedb, s.eth.chainConfig, s.eth.BlockChain(), msg, block.Header()), nil
 }
 
 // computeTxEnv returns the execution environment of a certain transaction.
-func (s *PublicDebugAPI) computeTxEnv(blockHash common.Hash, txIndex int) (core.Message, *core.VMEnv, error) {
+func (s *PublicDebugAPI) computeTxEnv(blockHash common.Hash, txIndex int) (core.Message, *core.VMEnv, *state.StateDB, error) {
 
 	// Create the parent state.
 	block := s.eth.BlockChain().GetBlock(blockHash)
 	if block == nil {
-		return nil, nil, fmt.Errorf("block %x not found", blockHash)
+		return nil, nil, nil, fmt.Errorf("block %x not found", blockHash)
 	}
 	parent := s.eth.BlockChain().GetBlock(block.ParentHash())
 	if parent == nil {
-		return nil, nil, fmt.Errorf("block parent %x not found", block.ParentHash())
+		return nil, nil, nil, fmt.Errorf("block parent %x not found", block.ParentHash())
 	}
 	statedb, err := s.eth.BlockChain().StateAt(parent.Root())
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 	txs := block.Transactions()
 
 	// Recompute transactions up to the target index.
 	for idx, tx := range txs {
-		// Assemble the transaction call message
-		// Retrieve the account state object to interact with
-		var from *state.StateObject
-		fromAddress, e := tx.From()
-		if e != nil {
-			return nil, nil, e
-		}
-		if fromAddress == (common.Address{}) {
-			from = statedb.GetOrNewStateObject(common.Address{})
-		} else {
-			from = statedb.GetOrNewStateObject(fromAddress)
-		}
-
-		msg := callmsg{
-			from:     from,
-			to:       tx.To(),
-			gas:      tx.Gas(),
-			gasPrice: tx.GasPrice(),
-			value:    tx.Value(),
-			data:     tx.Data(),
+		msg, vmenv, err := s.txEnv(statedb, block, tx)
+		if err != nil {
+			return nil, nil, nil, err
 		}
-
-		vmenv := core.NewEnv(statedb, s.eth.chainConfig, s.eth.BlockChain(), msg, block.Header())
 		if idx == txIndex {
-			return msg, vmenv, nil
+			return msg, vmenv, statedb, nil
 		}
 
 		gp := new(core.GasPool).AddGas(tx.Gas())
-		_, _, err := core.ApplyMessage(vmenv, msg, gp)
+		_, _, err = core.ApplyMessage(vmenv, msg, gp)
 		if err != nil {
-			return nil, nil, fmt.Errorf("tx %x failed: %v", tx.Hash(), err)
+			return nil, nil, nil, fmt.Errorf("tx %x failed: %v", tx.Hash(), err)
 		}
-		statedb.DeleteSuicides()
+		// Flush the changes into the state trie, just like the state processor
+		// does, so that copies of the state taken later on remain consistent.
+		statedb.IntermediateRoot(s.eth.chainConfig.IsEIP161(block.Number()))
 	}
-	return nil, nil, fmt.Errorf("tx index %d out of range for block %x", txIndex, blockHash)
+	return nil, nil, nil, fmt.Errorf("tx index %d out of range for block %x", txIndex, blockHash)
 }
 
 // PublicNetAPI offers network related RPC methods
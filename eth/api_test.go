@@ -0,0 +1,884 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/ellaism/go-ellaism/accounts"
+	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/core"
+	"github.com/ellaism/go-ellaism/core/state"
+	"github.com/ellaism/go-ellaism/core/types"
+	"github.com/ellaism/go-ellaism/core/vm"
+	"github.com/ellaism/go-ellaism/crypto"
+	"github.com/ellaism/go-ellaism/eth/names"
+	"github.com/ellaism/go-ellaism/ethdb"
+	"github.com/ellaism/go-ellaism/event"
+	"github.com/ellaism/go-ellaism/miner"
+	"github.com/ellaism/go-ellaism/p2p/discover"
+	"github.com/ellaism/go-ellaism/rpc"
+)
+
+// Tests that account change notifications are produced for the watched
+// accounts whose balance or nonce differ from the values last reported.
+func TestAccountChanges(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, db)
+
+	var (
+		payee   = common.Address{0x01}
+		sender  = common.Address{0x02}
+		idle    = common.Address{0x03}
+		watched = map[common.Address]*accountSnapshot{
+			payee:  {balance: new(big.Int)},
+			sender: {balance: big.NewInt(100)},
+			idle:   {balance: new(big.Int)},
+		}
+	)
+	statedb.AddBalance(payee, big.NewInt(10))
+	statedb.AddBalance(sender, big.NewInt(100))
+	statedb.SetNonce(sender, 1)
+
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1)})
+	changes := accountChanges(statedb, block, watched)
+	if len(changes) != 2 {
+		t.Fatalf("changes: have %d, want 2", len(changes))
+	}
+	for _, change := range changes {
+		switch change.Address {
+		case payee:
+			if change.Balance.BigInt().Cmp(big.NewInt(10)) != 0 {
+				t.Errorf("payee balance: have %v, want 10", change.Balance.BigInt())
+			}
+		case sender:
+			if change.Nonce.Int() != 1 {
+				t.Errorf("sender nonce: have %d, want 1", change.Nonce.Int())
+			}
+		default:
+			t.Errorf("unexpected change of %x", change.Address)
+		}
+		if change.BlockHash != block.Hash() || change.BlockNumber.Int() != 1 {
+			t.Errorf("change of %x reported for block #%d [%x]", change.Address, change.BlockNumber.Int(), change.BlockHash)
+		}
+	}
+	// Unchanged accounts aren't reported again
+	if changes := accountChanges(statedb, block, watched); len(changes) != 0 {
+		t.Fatalf("changes reported twice: %v", changes)
+	}
+	// Reverted changes are reported too
+	reverted, _ := state.New(common.Hash{}, db)
+	if changes := accountChanges(reverted, block, watched); len(changes) != 2 {
+		t.Fatalf("reverted changes: have %d, want 2", len(changes))
+	}
+}
+
+// Tests that the transfers of a call tree are walked in execution order,
+// leaving out failed calls with everything they called and the calls that
+// don't move value.
+func TestFrameTransfers(t *testing.T) {
+	frame := func(typ vm.OpCode, to byte, err error, calls ...*vm.CallFrame) *vm.CallFrame {
+		return &vm.CallFrame{Type: typ, To: common.Address{to}, Value: big.NewInt(1), Error: err, Calls: calls}
+	}
+	root := frame(vm.CALL, 1, nil,
+		frame(vm.CALL, 2, nil,
+			frame(vm.SUICIDE, 3, nil),
+		),
+		frame(vm.CALL, 4, errors.New("reverted"),
+			frame(vm.CALL, 5, nil),
+		),
+		frame(vm.DELEGATECALL, 6, nil,
+			frame(vm.CREATE, 7, nil),
+		),
+		frame(vm.CALLCODE, 8, nil),
+	)
+	type transfer struct {
+		typ   string
+		to    byte
+		depth int
+	}
+	transfers := func() []transfer {
+		var have []transfer
+		for _, record := range core.NewTraceRecords(0, root) {
+			if typ := transferType(record.Type); typ != "" && !record.Reverted {
+				have = append(have, transfer{typ, record.To[0], len(record.Address)})
+			}
+		}
+		return have
+	}
+	want := []transfer{{"call", 1, 0}, {"call", 2, 1}, {"suicide", 3, 2}, {"create", 7, 2}}
+	if have := transfers(); !reflect.DeepEqual(have, want) {
+		t.Errorf("transfers mismatch:\nhave %v\nwant %v", have, want)
+	}
+	// Nothing is transferred by a failed transaction
+	root.Error = errors.New("out of gas")
+	if have := transfers(); len(have) != 0 {
+		t.Errorf("transfers of failed transaction reported: %v", have)
+	}
+}
+
+// Tests that the transfers of a range of blocks report the value of the
+// transactions, their fees and the block rewards of the watched accounts.
+func TestGetTransfers(t *testing.T) {
+	var (
+		db, _       = ethdb.NewMemDatabase()
+		genesis     = core.WriteGenesisBlockForTesting(db, testBank)
+		chainConfig = core.MakeDiehardChainConfig()
+		signer      = types.NewChainIdSigner(chainConfig.GetChainID())
+		payee       = common.Address{0x01}
+		miner       = common.Address{0x02}
+	)
+	blockchain, err := core.NewBlockChain(db, chainConfig, new(core.FakePow), new(event.TypeMux))
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain, _ := core.GenerateChain(chainConfig, genesis, db, 2, func(i int, gen *core.BlockGen) {
+		gen.SetCoinbase(miner)
+		if i == 0 {
+			tx, _ := types.NewTransaction(gen.TxNonce(testBank.Address), payee, big.NewInt(1000), big.NewInt(21000), big.NewInt(1), nil).WithSigner(signer).SignECDSA(testBankKey)
+			gen.AddTx(tx)
+		}
+	})
+	if _, err := blockchain.InsertChain(chain); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	api := NewPublicEllaAPI(&Ethereum{chainConfig: chainConfig, blockchain: blockchain, chainDb: db})
+
+	transfers, err := api.GetTransfers(rpc.BlockNumber(0), rpc.LatestBlockNumber, []names.Account{{Address: payee}, {Address: miner}})
+	if err != nil {
+		t.Fatalf("failed to retrieve transfers: %v", err)
+	}
+	reward := core.CalcBlockReward(chainConfig, chain[0].Header(), nil).Miner()
+	want := []struct {
+		typ   string
+		block uint64
+		to    common.Address
+		value *big.Int
+	}{
+		{"call", 1, payee, big.NewInt(1000)},
+		{"fee", 1, miner, big.NewInt(21000)},
+		{"reward", 1, miner, reward},
+		{"reward", 2, miner, reward},
+	}
+	if len(transfers) != len(want) {
+		t.Fatalf("transfers: have %d, want %d", len(transfers), len(want))
+	}
+	for i, transfer := range transfers {
+		if transfer.Type != want[i].typ || transfer.BlockNumber.Uint64() != want[i].block || transfer.To != want[i].to || transfer.Value.BigInt().Cmp(want[i].value) != 0 {
+			t.Errorf("transfer %d: have %s #%d to %x of %v, want %s #%d to %x of %v", i,
+				transfer.Type, transfer.BlockNumber.Uint64(), transfer.To, transfer.Value.BigInt(),
+				want[i].typ, want[i].block, want[i].to, want[i].value)
+		}
+		if (transfer.TransactionHash == nil) != (transfer.Type == "reward") {
+			t.Errorf("transfer %d: transaction hash %v for %s", i, transfer.TransactionHash, transfer.Type)
+		}
+	}
+	if transfers[0].From == nil || *transfers[0].From != testBank.Address {
+		t.Errorf("transaction sender mismatch: have %v, want %x", transfers[0].From, testBank.Address)
+	}
+	// Ranges past the head or reversed are refused
+	if _, err := api.GetTransfers(rpc.BlockNumber(1), rpc.BlockNumber(5), []names.Account{{Address: payee}}); err == nil {
+		t.Error("range past the head accepted")
+	}
+	if _, err := api.GetTransfers(rpc.BlockNumber(2), rpc.BlockNumber(1), []names.Account{{Address: payee}}); err == nil {
+		t.Error("reversed range accepted")
+	}
+}
+
+// Tests that the chain parameters report the configuration of the node and the
+// reward schedule at its head block.
+func TestChainParams(t *testing.T) {
+	var (
+		db, _       = ethdb.NewMemDatabase()
+		genesis     = core.WriteGenesisBlockForTesting(db)
+		chainConfig = core.BundledChainConfig("mainnet").ChainConfig
+		bootnode    = discover.MustParseNode("enode://81fa361d25f157cd421c60dcc28d8dac5ef6a89476633339c5df30287474520caca09627da18543d9079b5b288698b542d56167aa5c09111e55acdbbdf2ef799@10.0.1.16:30303")
+	)
+	blockchain, err := core.NewBlockChain(db, chainConfig, new(core.FakePow), new(event.TypeMux))
+	if err != nil {
+		t.Fatal(err)
+	}
+	api := NewPrivateAdminAPI(&Ethereum{chainConfig: chainConfig, blockchain: blockchain, chainName: "Ellaism", netVersionId: 1, bootnodes: []*discover.Node{bootnode}})
+
+	params := api.ChainParams()
+	if params.Name != "Ellaism" || params.NetworkID != 1 || params.Genesis != genesis.Hash() || params.Head.Int() != 0 {
+		t.Errorf("chain mismatch: have %s network %d genesis %x head %d", params.Name, params.NetworkID, params.Genesis, params.Head.Int())
+	}
+	if params.ChainID.BigInt().Cmp(chainConfig.GetChainID()) != 0 {
+		t.Errorf("chain ID mismatch: have %v, want %v", params.ChainID.BigInt(), chainConfig.GetChainID())
+	}
+	if len(params.Forks) != len(chainConfig.Forks) {
+		t.Errorf("fork count mismatch: have %d, want %d", len(params.Forks), len(chainConfig.Forks))
+	}
+	if len(params.Bootnodes) != 1 || params.Bootnodes[0] != bootnode.String() {
+		t.Errorf("bootnodes mismatch: have %v, want %v", params.Bootnodes, bootnode)
+	}
+
+	// The reward decreases by era
+	for _, tt := range []struct {
+		number, era, next int64
+	}{
+		{1, 0, 10000001},
+		{10000000, 0, 10000001},
+		{10000001, 1, 20000001},
+	} {
+		reward := chainReward(chainConfig, &types.Header{Number: big.NewInt(tt.number)})
+		if reward.Type != "ecip1017" || reward.EraLength.Int() != 10000000 {
+			t.Fatalf("block #%d: reward type %s with era length %v", tt.number, reward.Type, reward.EraLength)
+		}
+		if reward.Era.Int() != int(tt.era) || reward.NextEraBlock.Int() != int(tt.next) {
+			t.Errorf("block #%d: era %d until #%d, want %d until #%d", tt.number, reward.Era.Int(), reward.NextEraBlock.Int(), tt.era, tt.next)
+		}
+		if want := core.GetBlockWinnerRewardByEra(big.NewInt(tt.era)); reward.BlockReward.BigInt().Cmp(want) != 0 {
+			t.Errorf("block #%d: reward %v, want %v", tt.number, reward.BlockReward.BigInt(), want)
+		}
+	}
+}
+
+// Tests that queries of states not retained fail with the range of the states
+// which are, and that the retained ones are served.
+func TestStatePrunedError(t *testing.T) {
+	var (
+		db, _       = ethdb.NewMemDatabase()
+		genesis     = core.WriteGenesisBlockForTesting(db, testBank)
+		chainConfig = core.MakeDiehardChainConfig()
+		miner       = common.Address{0x02}
+	)
+	blockchain, err := core.NewBlockChain(db, chainConfig, new(core.FakePow), new(event.TypeMux))
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain, _ := core.GenerateChain(chainConfig, genesis, db, 4, func(i int, gen *core.BlockGen) {
+		gen.SetCoinbase(miner)
+	})
+	if _, err := blockchain.InsertChain(chain); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	blockchain.Stop()
+
+	// Drop the states below block #3, as skipped by a fast sync
+	db.Delete(chain[0].Root().Bytes())
+	db.Delete(chain[1].Root().Bytes())
+	if blockchain, err = core.NewBlockChain(db, chainConfig, new(core.FakePow), new(event.TypeMux)); err != nil {
+		t.Fatal(err)
+	}
+	defer blockchain.Stop()
+
+	api := &PublicBlockChainAPI{bc: blockchain, chainDb: db}
+	_, err = api.GetCode(names.Account{Address: miner}, rpc.BlockNumber(2))
+	pruned, ok := err.(*statePrunedError)
+	if !ok {
+		t.Fatalf("error mismatch: have %v, want state pruned error", err)
+	}
+	if pruned.Code() != -32001 || pruned.available.Oldest.Uint64() != 3 || pruned.available.OldestHash != chain[2].Hash() || pruned.available.Latest.Uint64() != 4 {
+		t.Errorf("error data mismatch: code %d, oldest #%d, latest #%d", pruned.Code(), pruned.available.Oldest.Uint64(), pruned.available.Latest.Uint64())
+	}
+	if code, err := api.GetCode(names.Account{Address: miner}, rpc.BlockNumber(3)); err != nil || code != "0x" {
+		t.Errorf("retained state: have %q, %v", code, err)
+	}
+	if balance, err := api.GetBalance(names.Account{Address: testBank.Address}, rpc.BlockNumber(0)); err != nil || balance.Cmp(testBank.Balance) != 0 {
+		t.Errorf("genesis state: have %v, %v", balance, err)
+	}
+	debug := NewPublicDebugAPI(&Ethereum{blockchain: blockchain})
+	if states := debug.AvailableStates(); states.Oldest.Uint64() != 3 || states.LatestHash != chain[3].Hash() {
+		t.Errorf("available states mismatch: oldest #%d, latest %x", states.Oldest.Uint64(), states.LatestHash)
+	}
+}
+
+// Tests that eth_accounts lists the accounts to callers over HTTP and WebSocket
+// only if enabled, and always to local callers.
+func TestAccountsExposure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "eth-accounts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	am, err := accounts.NewManager(dir, accounts.LightScryptN, accounts.LightScryptP, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := am.NewAccount("secret"); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		transport string
+		remote    bool
+		listed    bool
+	}{
+		{"inproc", false, true},
+		{"ipc", false, true},
+		{"http", false, false},
+		{"ws", false, false},
+		{"http", true, true},
+		{"ws", true, true},
+	}
+	for i, tt := range tests {
+		server := rpc.NewServer()
+		if err := server.RegisterName("eth", NewPublicAccountAPI(am, tt.remote)); err != nil {
+			t.Fatal(err)
+		}
+		server.SetTransport(tt.transport)
+
+		clientConn, serverConn := net.Pipe()
+		go server.ServeCodec(rpc.NewJSONCodec(serverConn), rpc.OptionMethodInvocation)
+
+		if err := json.NewEncoder(clientConn).Encode(json.RawMessage(`{"jsonrpc":"2.0","id":1,"method":"eth_accounts","params":[]}`)); err != nil {
+			t.Fatal(err)
+		}
+		var response struct {
+			Result []common.Address `json:"result"`
+		}
+		if err := json.NewDecoder(clientConn).Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+		clientConn.Close()
+
+		if listed := len(response.Result) == 1; listed != tt.listed || response.Result == nil {
+			t.Errorf("test %d: %s with remote exposure %v: accounts %v, want listed %v", i, tt.transport, tt.remote, response.Result, tt.listed)
+		}
+	}
+}
+
+// Tests that eth_coinbase doesn't report the first account in place of an unset
+// etherbase to callers over HTTP and WebSocket, unless the accounts are exposed.
+func TestEtherbaseExposure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "eth-etherbase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	am, err := accounts.NewManager(dir, accounts.LightScryptN, accounts.LightScryptP, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	account, err := am.NewAccount("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	etherbase := common.Address{0x01}
+	tests := []struct {
+		transport string
+		remote    bool
+		etherbase common.Address
+		want      common.Address // zero if an error is expected
+	}{
+		{"ipc", false, common.Address{}, account.Address},
+		{"http", false, common.Address{}, common.Address{}},
+		{"ws", false, common.Address{}, common.Address{}},
+		{"http", true, common.Address{}, account.Address},
+		{"http", false, etherbase, etherbase},
+	}
+	for i, tt := range tests {
+		server := rpc.NewServer()
+		eth := &Ethereum{accountManager: am, remoteAccounts: tt.remote, etherbase: tt.etherbase}
+		if err := server.RegisterName("eth", NewPublicEthereumAPI(eth)); err != nil {
+			t.Fatal(err)
+		}
+		server.SetTransport(tt.transport)
+
+		clientConn, serverConn := net.Pipe()
+		go server.ServeCodec(rpc.NewJSONCodec(serverConn), rpc.OptionMethodInvocation)
+
+		if err := json.NewEncoder(clientConn).Encode(json.RawMessage(`{"jsonrpc":"2.0","id":1,"method":"eth_coinbase","params":[]}`)); err != nil {
+			t.Fatal(err)
+		}
+		var response struct {
+			Result common.Address   `json:"result"`
+			Error  *json.RawMessage `json:"error"`
+		}
+		if err := json.NewDecoder(clientConn).Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+		clientConn.Close()
+
+		if response.Result != tt.want || (response.Error != nil) != (tt.want == common.Address{}) {
+			t.Errorf("test %d: %s with remote exposure %v: coinbase %x, error %s, want %x", i, tt.transport, tt.remote, response.Result, response.Error, tt.want)
+		}
+	}
+}
+
+// Tests that accounts are only unlocked for callers over network facing
+// transports if insecure unlocking is allowed.
+func TestUnlockAccountTransport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "eth-unlock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	am, err := accounts.NewManager(dir, accounts.LightScryptN, accounts.LightScryptP, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	account, err := am.NewAccount("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		transport string
+		insecure  bool
+		unlocked  bool
+	}{
+		{"inproc", false, true},
+		{"ipc", false, true},
+		{"http", false, false},
+		{"ws", false, false},
+		{"http", true, true},
+		{"ws", true, true},
+	}
+	for i, tt := range tests {
+		am.Lock(account.Address)
+
+		server := rpc.NewServer()
+		if err := server.RegisterName("personal", &PrivateAccountAPI{am: am, insecureUnlock: tt.insecure}); err != nil {
+			t.Fatal(err)
+		}
+		server.SetTransport(tt.transport)
+
+		clientConn, serverConn := net.Pipe()
+		go server.ServeCodec(rpc.NewJSONCodec(serverConn), rpc.OptionMethodInvocation)
+
+		request := `{"jsonrpc":"2.0","id":1,"method":"personal_unlockAccount","params":["` + account.Address.Hex() + `","secret"]}`
+		if err := json.NewEncoder(clientConn).Encode(json.RawMessage(request)); err != nil {
+			t.Fatal(err)
+		}
+		var response struct {
+			Result bool             `json:"result"`
+			Error  *json.RawMessage `json:"error"`
+		}
+		if err := json.NewDecoder(clientConn).Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+		clientConn.Close()
+
+		if response.Result != tt.unlocked || (response.Error == nil) != tt.unlocked {
+			t.Errorf("test %d: %s with insecure unlock %v: result %v, error %s, want unlocked %v", i, tt.transport, tt.insecure, response.Result, response.Error, tt.unlocked)
+		}
+		if _, err := am.Sign(account.Address, make([]byte, 32)); (err == nil) != tt.unlocked {
+			t.Errorf("test %d: %s with insecure unlock %v: account unlocked %v, want %v", i, tt.transport, tt.insecure, err == nil, tt.unlocked)
+		}
+	}
+}
+
+// Tests that the transactions of a batch are added to the pool in order, each
+// rejection being reported without stopping the following transactions.
+func TestSendRawTransactionBatch(t *testing.T) {
+	var (
+		db, _       = ethdb.NewMemDatabase()
+		genesis     = core.WriteGenesisBlockForTesting(db, testBank)
+		chainConfig = core.MakeDiehardChainConfig()
+		signer      = types.NewChainIdSigner(chainConfig.GetChainID())
+		mux         = new(event.TypeMux)
+	)
+	blockchain, err := core.NewBlockChain(db, chainConfig, new(core.FakePow), mux)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer blockchain.Stop()
+
+	config := core.DefaultTxPoolConfig
+	config.Journal = ""
+	pool := core.NewTxPool(chainConfig, config, mux, blockchain.State, func() *big.Int { return genesis.GasLimit() }, blockchain.CurrentBlock().Number())
+	defer pool.Stop()
+	api := &PublicTransactionPoolAPI{txPool: pool}
+
+	encode := func(nonce uint64) (string, common.Hash) {
+		tx, _ := types.NewTransaction(nonce, common.Address{0x01}, big.NewInt(1), big.NewInt(21000), big.NewInt(1), nil).WithSigner(signer).SignECDSA(testBankKey)
+		data, err := tx.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return common.ToHex(data), tx.Hash()
+	}
+	first, firstHash := encode(0)
+	second, secondHash := encode(1)
+
+	results, err := api.SendRawTransactionBatch([]string{first, "0x1234", first, second})
+	if err != nil {
+		t.Fatalf("failed to send batch: %v", err)
+	}
+	if len(results) != 4 {
+		t.Fatalf("results: have %d, want 4", len(results))
+	}
+	if r := results[0]; !r.Accepted || *r.Hash != firstHash || r.Error != "" {
+		t.Errorf("first transaction result mismatch: %+v", r)
+	}
+	if r := results[1]; r.Accepted || r.Hash != nil || r.Error == "" || r.Reason != "invalidEncoding" {
+		t.Errorf("undecodable transaction result mismatch: %+v", r)
+	}
+	if r := results[2]; r.Accepted || *r.Hash != firstHash || r.Error == "" || r.Reason != "alreadyKnown" {
+		t.Errorf("duplicate transaction result mismatch: %+v", r)
+	}
+	if r := results[3]; !r.Accepted || *r.Hash != secondHash || r.Error != "" {
+		t.Errorf("second transaction result mismatch: %+v", r)
+	}
+	if pending, _ := pool.Stats(); pending != 2 {
+		t.Errorf("pending transactions: have %d, want 2", pending)
+	}
+	if _, err := api.SendRawTransactionBatch(make([]string, maxRawTransactionBatch+1)); err == nil {
+		t.Errorf("oversized batch accepted")
+	}
+	// Rejected raw transactions fail with the reason in the error data
+	for _, test := range []struct {
+		encoded string
+		reason  string
+	}{{first, "alreadyKnown"}, {"0x1234", "invalidEncoding"}} {
+		_, err := api.SendRawTransaction(test.encoded)
+		rejected, ok := err.(*txRejectedError)
+		if !ok {
+			t.Errorf("%s: error mismatch: have %v, want rejection", test.reason, err)
+			continue
+		}
+		if rejected.Code() != -32003 || rejected.ErrorData().(map[string]string)["reason"] != test.reason {
+			t.Errorf("%s: rejection mismatch: code %d, data %v", test.reason, rejected.Code(), rejected.ErrorData())
+		}
+	}
+}
+
+// newCallTestAPI creates a blockchain API on top of a chain made of the genesis
+// block crediting the test bank, with REVERT enabled.
+func newCallTestAPI(t *testing.T) (*PublicBlockChainAPI, func()) {
+	var (
+		db, _       = ethdb.NewMemDatabase()
+		chainConfig = core.MakeDiehardChainConfig()
+	)
+	core.WriteGenesisBlockForTesting(db, testBank)
+	chainConfig.Forks = append(chainConfig.Forks, &core.Fork{Name: "Byzantium", Block: big.NewInt(0), Features: []*core.ForkFeature{{ID: "eip140"}}})
+
+	blockchain, err := core.NewBlockChain(db, chainConfig, new(core.FakePow), new(event.TypeMux))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &PublicBlockChainAPI{config: chainConfig, bc: blockchain, chainDb: db}, blockchain.Stop
+}
+
+var (
+	// revertingInitCode deploys code reverting every call to it.
+	revertingInitCode = "0x6460006000fd6000526005601bf3"
+
+	// loopingInitCode loops until out of gas.
+	loopingInitCode = "0x5b600056"
+)
+
+// Tests that the calls of eth_multicall run in order on a single state, each
+// seeing the changes of the previous ones, with failures reported per call.
+func TestMulticall(t *testing.T) {
+	api, stop := newCallTestAPI(t)
+	defer stop()
+
+	var (
+		payee    = common.Address{0x01}
+		next     = common.Address{0x02}
+		deployer = common.Address{0x03}
+		contract = crypto.CreateAddress(deployer, 0)
+	)
+	results, err := api.Multicall([]CallArgs{
+		{From: testBank.Address, To: &names.Account{Address: payee}, Value: *rpc.NewHexNumber(1000)},
+		{From: payee, To: &names.Account{Address: next}, Value: *rpc.NewHexNumber(400)},
+		{From: payee, To: &names.Account{Address: next}, Value: *rpc.NewHexNumber(700)},
+		{From: deployer, Data: revertingInitCode},
+		{From: testBank.Address, To: &names.Account{Address: contract}},
+	}, rpc.LatestBlockNumber)
+	if err != nil {
+		t.Fatalf("multicall failed: %v", err)
+	}
+	if len(results) != 5 {
+		t.Fatalf("results: have %d, want 5", len(results))
+	}
+	// The payee spends what the first call credited it, but no more
+	if r := results[1]; r.Error != "" || r.GasUsed.Int64() != 21000 {
+		t.Errorf("dependent call result mismatch: %+v", r)
+	}
+	if r := results[2]; r.Error == "" || r.GasUsed != nil {
+		t.Errorf("overdrawing call result mismatch: %+v", r)
+	}
+	// The reverting call reports the revert along with its gas
+	if r := results[3]; r.Error != "" {
+		t.Errorf("deployment failed: %+v", r)
+	}
+	if r := results[4]; r.Error != vm.ErrExecutionReverted.Error() || r.GasUsed == nil {
+		t.Errorf("reverting call result mismatch: %+v", r)
+	}
+	// The state of the block isn't altered
+	if balance, _ := api.GetBalance(names.Account{Address: payee}, rpc.LatestBlockNumber); balance.Sign() != 0 {
+		t.Errorf("multicall altered the state: payee balance %v", balance)
+	}
+}
+
+// Tests that eth_multicall bounds the number of calls and the gas they use
+// together, and that calls given no gas get what is left of it.
+func TestMulticallLimits(t *testing.T) {
+	api, stop := newCallTestAPI(t)
+	defer stop()
+
+	if _, err := api.Multicall(make([]CallArgs, maxMulticallCalls+1), rpc.LatestBlockNumber); err == nil {
+		t.Errorf("oversized multicall accepted")
+	}
+	transfer := CallArgs{From: testBank.Address, To: &names.Account{Address: common.Address{0x01}}}
+	overGassed := transfer
+	overGassed.Gas = rpc.NewHexNumber(maxMulticallGas + 1)
+
+	results, err := api.Multicall([]CallArgs{
+		overGassed,
+		transfer,
+		{From: common.Address{0x02}, Data: loopingInitCode},
+		transfer,
+	}, rpc.LatestBlockNumber)
+	if err != nil {
+		t.Fatalf("multicall failed: %v", err)
+	}
+	if r := results[0]; r.Error == "" {
+		t.Errorf("call over the gas cap succeeded: %+v", r)
+	}
+	if r := results[1]; r.Error != "" || r.GasUsed.Int64() != 21000 {
+		t.Errorf("call after a rejected one failed: %+v", r)
+	}
+	// The looping call uses all the gas left, none remains for the next one
+	if r := results[2]; r.GasUsed == nil || r.GasUsed.Int64() != maxMulticallGas-21000 {
+		t.Errorf("looping call result mismatch: %+v", r)
+	}
+	if r := results[3]; r.Error == "" {
+		t.Errorf("call past the gas cap succeeded: %+v", r)
+	}
+}
+
+// Tests that eth_call only defaults the gas of calls not given any, and runs
+// calls given zero gas as such.
+func TestCallZeroGas(t *testing.T) {
+	api, stop := newCallTestAPI(t)
+	defer stop()
+
+	call := CallArgs{From: testBank.Address, To: &names.Account{Address: common.Address{0x01}}, GasPrice: rpc.NewHexNumber(1)}
+	if _, err := api.Call(call, rpc.LatestBlockNumber); err != nil {
+		t.Errorf("call without gas failed: %v", err)
+	}
+	call.Gas = rpc.NewHexNumber(0)
+	if _, err := api.Call(call, rpc.LatestBlockNumber); err == nil {
+		t.Errorf("call with zero gas succeeded")
+	}
+}
+
+// Tests that eth_createAccessList returns the list a call settles on, and gives
+// up on calls whose accesses keep changing with the list.
+func TestCreateAccessList(t *testing.T) {
+	api, stop := newCallTestAPI(t)
+	defer stop()
+	api.config.Forks = append(api.config.Forks, &core.Fork{Name: "Berlin", Block: big.NewInt(0), Features: []*core.ForkFeature{{ID: "eip2930"}}})
+
+	// Reading a fixed slot settles on the list holding it
+	deployer := common.Address{0x01}
+	result, err := api.CreateAccessList(CallArgs{From: deployer, Data: "0x60015400"}, rpc.LatestBlockNumber)
+	if err != nil {
+		t.Fatalf("access list creation failed: %v", err)
+	}
+	want := types.AccessList{{Address: crypto.CreateAddress(deployer, 0), StorageKeys: []common.Hash{common.BigToHash(big.NewInt(1))}}}
+	if !reflect.DeepEqual(result.AccessList, want) {
+		t.Errorf("access list mismatch: have %v, want %v", result.AccessList, want)
+	}
+	// Reading the slot of the gas left never does, as every list costs more
+	if _, err := api.CreateAccessList(CallArgs{From: deployer, Data: "0x5a5400"}, rpc.LatestBlockNumber); err != errAccessListUnstable {
+		t.Errorf("error mismatch: have %v, want %v", err, errAccessListUnstable)
+	}
+}
+
+// simulationBackend is the backend of a miner providing the pending state to
+// the transaction simulations.
+type simulationBackend struct {
+	am   *accounts.Manager
+	bc   *core.BlockChain
+	pool *core.TxPool
+	db   ethdb.Database
+	mux  *event.TypeMux
+}
+
+func (b *simulationBackend) AccountManager() *accounts.Manager { return b.am }
+func (b *simulationBackend) BlockChain() *core.BlockChain      { return b.bc }
+func (b *simulationBackend) TxPool() *core.TxPool              { return b.pool }
+func (b *simulationBackend) ChainDb() ethdb.Database           { return b.db }
+func (b *simulationBackend) DappDb() ethdb.Database            { return b.db }
+func (b *simulationBackend) EventMux() *event.TypeMux          { return b.mux }
+
+// newSimulationTestAPI creates a blockchain API whose pending state is that of
+// a block in which the test bank deployed revertingInitCode, using nonce 0.
+func newSimulationTestAPI(t *testing.T) (*PublicBlockChainAPI, func()) {
+	dir, err := ioutil.TempDir("", "eth-simulate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		db, _       = ethdb.NewMemDatabase()
+		genesis     = core.WriteGenesisBlockForTesting(db, testBank)
+		chainConfig = core.MakeDiehardChainConfig()
+		signer      = types.NewChainIdSigner(chainConfig.GetChainID())
+		mux         = new(event.TypeMux)
+	)
+	chainConfig.Forks = append(chainConfig.Forks, &core.Fork{Name: "Byzantium", Block: big.NewInt(0), Features: []*core.ForkFeature{{ID: "eip140"}}})
+
+	blocks, _ := core.GenerateChain(chainConfig, genesis, db, 1, func(i int, b *core.BlockGen) {
+		tx, _ := types.NewContractCreation(0, new(big.Int), big.NewInt(100000), big.NewInt(1), common.FromHex(revertingInitCode)).WithSigner(signer).SignECDSA(testBankKey)
+		b.AddTx(tx)
+	})
+	blockchain, err := core.NewBlockChain(db, chainConfig, new(core.FakePow), mux)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := blockchain.InsertChain(blocks); err != nil {
+		t.Fatal(err)
+	}
+	config := core.DefaultTxPoolConfig
+	config.Journal = ""
+	pool := core.NewTxPool(chainConfig, config, mux, blockchain.State, func() *big.Int { return genesis.GasLimit() }, blockchain.CurrentBlock().Number())
+
+	am, err := accounts.NewManager(dir, accounts.LightScryptN, accounts.LightScryptP, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend := &simulationBackend{am: am, bc: blockchain, pool: pool, db: db, mux: mux}
+	api := &PublicBlockChainAPI{
+		config:  chainConfig,
+		bc:      blockchain,
+		chainDb: db,
+		miner:   miner.New(backend, chainConfig, mux, blockchain.Engine()),
+	}
+	return api, func() {
+		pool.Stop()
+		blockchain.Stop()
+		os.RemoveAll(dir)
+	}
+}
+
+// Tests that eth_simulateTransaction reports the outcome and state changes of
+// valid transactions, and rejects those that couldn't be included in a block.
+func TestSimulateTransaction(t *testing.T) {
+	api, stop := newSimulationTestAPI(t)
+	defer stop()
+
+	var (
+		payee    = common.Address{0x01}
+		contract = crypto.CreateAddress(testBank.Address, 0)
+	)
+	transfer := func(from common.Address, nonce uint64, value int64) SendTxArgs {
+		return SendTxArgs{
+			From:     from,
+			To:       &names.Account{Address: payee},
+			Nonce:    rpc.NewHexNumber(nonce),
+			GasPrice: rpc.NewHexNumber(1),
+			Value:    rpc.NewHexNumber(value),
+		}
+	}
+	// A valid transfer reports the changes to the sender and the payee
+	result, err := api.SimulateTransaction(transfer(testBank.Address, 1, 1000))
+	if err != nil {
+		t.Fatalf("simulation failed: %v", err)
+	}
+	if !result.Success || result.Error != "" || result.GasUsed.Int64() != 21000 {
+		t.Fatalf("transfer result mismatch: %+v", result)
+	}
+	sender := result.StateDiff[testBank.Address]
+	if sender == nil || sender.Pre == nil || sender.Post == nil {
+		t.Fatalf("sender diff mismatch: %+v", sender)
+	}
+	if sender.Pre.Nonce != 1 || sender.Post.Nonce != 2 {
+		t.Errorf("sender nonce mismatch: have %d→%d, want 1→2", sender.Pre.Nonce, sender.Post.Nonce)
+	}
+	pre, _ := new(big.Int).SetString(sender.Pre.Balance, 10)
+	post, _ := new(big.Int).SetString(sender.Post.Balance, 10)
+	if spent := new(big.Int).Sub(pre, post); spent.Int64() != 1000+21000 {
+		t.Errorf("sender spent %v, want %d", spent, 1000+21000)
+	}
+	if payee := result.StateDiff[payee]; payee == nil || payee.Pre != nil || payee.Post == nil || payee.Post.Balance != "1000" {
+		t.Errorf("payee diff mismatch: %+v", payee)
+	}
+	// Transactions which couldn't be included fail without any changes
+	for name, args := range map[string]SendTxArgs{
+		"nonce too low":        transfer(testBank.Address, 0, 1000),
+		"nonce too high":       transfer(testBank.Address, 2, 1000),
+		"insufficient balance": transfer(common.Address{0x02}, 0, 1000),
+	} {
+		result, err := api.SimulateTransaction(args)
+		if err != nil {
+			t.Errorf("%s: simulation failed: %v", name, err)
+			continue
+		}
+		if result.Success || result.Error == "" || result.GasUsed != nil || result.StateDiff != nil {
+			t.Errorf("%s: result mismatch: %+v", name, result)
+		}
+	}
+	// A reverting transaction fails but still pays for its gas
+	result, err = api.SimulateTransaction(SendTxArgs{
+		From:     testBank.Address,
+		To:       &names.Account{Address: contract},
+		Nonce:    rpc.NewHexNumber(1),
+		GasPrice: rpc.NewHexNumber(1),
+		Gas:      rpc.NewHexNumber(100000),
+	})
+	if err != nil {
+		t.Fatalf("simulation failed: %v", err)
+	}
+	if result.Success || result.Error != vm.ErrExecutionReverted.Error() || result.GasUsed == nil {
+		t.Fatalf("reverting transaction result mismatch: %+v", result)
+	}
+	if sender := result.StateDiff[testBank.Address]; sender == nil || sender.Post.Nonce != 2 {
+		t.Errorf("reverting transaction sender diff mismatch: %+v", sender)
+	}
+	if _, ok := result.StateDiff[contract]; ok {
+		t.Errorf("reverting transaction changed the contract")
+	}
+}
+
+// Tests that eth_simulateRawTransaction checks signed transactions against the
+// pending state like eth_simulateTransaction.
+func TestSimulateRawTransaction(t *testing.T) {
+	api, stop := newSimulationTestAPI(t)
+	defer stop()
+
+	signer := types.NewChainIdSigner(api.config.GetChainID())
+	encode := func(nonce uint64, key *ecdsa.PrivateKey) string {
+		tx, _ := types.NewTransaction(nonce, common.Address{0x01}, big.NewInt(1000), big.NewInt(21000), big.NewInt(1), nil).WithSigner(signer).SignECDSA(key)
+		data, err := tx.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return common.ToHex(data)
+	}
+	if result, err := api.SimulateRawTransaction(encode(1, testBankKey)); err != nil || !result.Success || result.StateDiff[common.Address{0x01}] == nil {
+		t.Errorf("valid transaction result mismatch: %+v, %v", result, err)
+	}
+	poorKey, _ := crypto.GenerateKey()
+	for name, encoded := range map[string]string{
+		"nonce too low":        encode(0, testBankKey),
+		"nonce too high":       encode(2, testBankKey),
+		"insufficient balance": encode(0, poorKey),
+	} {
+		if result, err := api.SimulateRawTransaction(encoded); err != nil || result.Success || result.Error == "" || result.StateDiff != nil {
+			t.Errorf("%s: result mismatch: %+v, %v", name, result, err)
+		}
+	}
+	if _, err := api.SimulateRawTransaction("0x1234"); err == nil {
+		t.Errorf("undecodable transaction simulated")
+	}
+}
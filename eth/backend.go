@@ -21,9 +21,11 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"math/big"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strconv"
 	"sync"
 	"time"
@@ -34,10 +36,12 @@ import (
 	"github.com/ellaism/go-ellaism/common/compiler"
 	"github.com/ellaism/go-ellaism/common/httpclient"
 	"github.com/ellaism/go-ellaism/common/registrar/ethreg"
+	"github.com/ellaism/go-ellaism/consensus/clique"
 	"github.com/ellaism/go-ellaism/core"
 	"github.com/ellaism/go-ellaism/core/types"
 	"github.com/ellaism/go-ellaism/eth/downloader"
 	"github.com/ellaism/go-ellaism/eth/filters"
+	"github.com/ellaism/go-ellaism/eth/names"
 	"github.com/ellaism/go-ellaism/ethdb"
 	"github.com/ellaism/go-ellaism/event"
 	"github.com/ellaism/go-ellaism/logger"
@@ -45,8 +49,10 @@ import (
 	"github.com/ellaism/go-ellaism/miner"
 	"github.com/ellaism/go-ellaism/node"
 	"github.com/ellaism/go-ellaism/p2p"
+	"github.com/ellaism/go-ellaism/p2p/discover"
 	"github.com/ellaism/go-ellaism/rlp"
 	"github.com/ellaism/go-ellaism/rpc"
+	"github.com/ellaism/go-ellaism/trie"
 )
 
 const (
@@ -57,28 +63,63 @@ const (
 	autoDAGepochHeight   = epochLength / 2
 )
 
+// errMiningHeaderOnly is returned when mining is started in header-only mode,
+// which has no state to build blocks on.
+var (
+	errMiningHeaderOnly = errors.New("mining is not available in header-only mode")
+	errNoEtherbase      = errors.New("etherbase address must be explicitly specified")
+)
+
 type Config struct {
 	ChainConfig *core.ChainConfig // chain configuration
 
-	NetworkId int // Network ID to use for selecting peers to connect to
-	Genesis   *core.GenesisDump
-	FastSync  bool // Enables the state download based fast synchronisation algorithm
+	NetworkId  int // Network ID to use for selecting peers to connect to
+	Genesis    *core.GenesisDump
+	FastSync   bool // Enables the state download based fast synchronisation algorithm
+	HeaderOnly bool // Syncs and validates only the header chain, without bodies, receipts or state
+
+	ChainName string           // Name of the chain, reported by admin_chainParams
+	Bootnodes []*discover.Node // Bootstrap nodes of the chain, reported by admin_chainParams
+
+	OverrideChainConfig bool // Rewinds the chain to apply a chain config incompatible with the stored one
 
 	BlockChainVersion  int
 	SkipBcVersionCheck bool // e.g. blockchain export
-	DatabaseCache      int
+	DatabaseCache      int  // Megabytes of database read cache
 	DatabaseHandles    int
+	TrieCache          int  // Megabytes of trie nodes kept in memory
+	NoPreimages        bool // Skips recording the preimages of state trie keys
 
 	NatSpec   bool
 	DocRoot   string
 	AutoDAG   bool
 	PowTest   bool
 	PowShared bool
+	Clique    *clique.Config // Proof-of-authority parameters, replacing the proof of work if set
+	GasAudit  bool // Cross-checks the gas used by processed transactions against opcode metering
+
+	OpcodeMetrics bool // Exports per-opcode execution counts, gas and timings of processed blocks
+
+	FutureDrift time.Duration // Maximum time blocks may be ahead of the local clock to be queued until then, zero for the default
+
+	ForensicsDir string // Directory forensic bundles of state root mismatches are written to, relative to the data directory, none if empty
+
+	TraceIndex bool // Traces every imported block in the background to index the internal transfers of its transactions
+
+	FilterTimeout    time.Duration // Time after which filters which aren't polled are removed
+	FilterMaxBlocks  uint64        // Maximum number of blocks searched by a log query, zero if unlimited
+	FilterMaxResults int           // Maximum number of logs returned by a log query, zero if unlimited
+
+	NameRegistry common.Address // Registry contract names given in place of addresses are resolved through, zero if disabled
+
+	RemoteAccounts bool // Lists the keystore accounts in eth_accounts to callers over HTTP and WebSocket too
+	InsecureUnlock bool // Allows personal_unlockAccount to callers over HTTP and WebSocket
 
 	AccountManager *accounts.Manager
 	Etherbase      common.Address
 	GasPrice       *big.Int
 	MinerThreads   int
+	TxPool         core.TxPoolConfig // Transaction pool limits, unset ones default to core.DefaultTxPoolConfig
 	SolcPath       string
 
 	GpoMinGasPrice          *big.Int
@@ -92,6 +133,35 @@ type Config struct {
 	TestGenesisState ethdb.Database // Genesis state to seed the database with (testing only!)
 }
 
+// DefaultConfig contains the default settings of the Ethereum service, which
+// joins the main network as geth does when started without flags. Programs
+// embedding the client start from a copy of it, changing the chain fields to
+// those of another bundled or custom chain configuration as needed. If no
+// account manager is set, the keystore in the node's data directory is used.
+var DefaultConfig = Config{
+	ChainConfig:       core.DefaultConfigMainnet.ChainConfig,
+	Genesis:           core.DefaultConfigMainnet.Genesis,
+	NetworkId:         NetworkId,
+	BlockChainVersion: core.BlockChainVersion,
+	DatabaseCache:     96,
+	DatabaseHandles:   256,
+	TrieCache:         32,
+
+	GasPrice:     new(big.Int).Mul(big.NewInt(20), common.Shannon),
+	MinerThreads: runtime.NumCPU(),
+	TxPool:       core.DefaultTxPoolConfig,
+	SolcPath:     "solc",
+
+	GpoMinGasPrice:          new(big.Int).Mul(big.NewInt(20), common.Shannon),
+	GpoMaxGasPrice:          new(big.Int).Mul(big.NewInt(500), common.Shannon),
+	GpoFullBlockRatio:       80,
+	GpobaseStepDown:         10,
+	GpobaseStepUp:           100,
+	GpobaseCorrectionFactor: 110,
+
+	FilterTimeout: filters.DefaultConfig.Timeout,
+}
+
 type Ethereum struct {
 	chainConfig *core.ChainConfig
 	// Channel for shutting down the ethereum
@@ -119,13 +189,19 @@ type Ethereum struct {
 	GpobaseStepUp           int
 	GpobaseCorrectionFactor int
 
-	httpclient *httpclient.HTTPClient
+	httpclient   *httpclient.HTTPClient
+	filterConfig filters.Config
 
 	eventMux *event.TypeMux
 	miner    *miner.Miner
+	names    names.Resolver // Resolver of names given in place of addresses, nil if disabled
+
+	remoteAccounts bool // Whether eth_accounts lists the accounts to callers over HTTP and WebSocket
+	insecureUnlock bool // Whether personal_unlockAccount is allowed to callers over HTTP and WebSocket
 
 	Mining        bool
 	MinerThreads  int
+	headerOnly    bool
 	NatSpec       bool
 	AutoDAG       bool
 	PowTest       bool
@@ -133,6 +209,25 @@ type Ethereum struct {
 	etherbase     common.Address
 	netVersionId  int
 	netRPCService *PublicNetAPI
+	chainName     string
+	bootnodes     []*discover.Node
+
+	ephemeralKeyDir string // Temporary keystore of an ephemeral node, removed on stop
+
+	traceIndexer *traceIndexer // Background indexer of the internal transfers, nil if disabled
+
+	droppedTxs *droppedTxLog // Transactions dropped by the transaction pool
+}
+
+// Register adds an Ethereum service with the given config to a node, so that
+// programs can embed the client. Services registered after it can retrieve it
+// through their node.ServiceContext to use the chain, the transaction pool or
+// the miner and to offer their own protocols and APIs, and it can be retrieved
+// from the running node with node.Service.
+func Register(stack *node.Node, config *Config) error {
+	return stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
+		return New(ctx, config)
+	})
 }
 
 func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
@@ -141,10 +236,7 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 	if err != nil {
 		return nil, err
 	}
-	if err := upgradeChainDatabase(chainDb); err != nil {
-		return nil, err
-	}
-	if err := addMipmapBloomBins(chainDb); err != nil {
+	if err := UpgradeChainDatabase(chainDb); err != nil {
 		return nil, err
 	}
 
@@ -152,6 +244,12 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 	if err != nil {
 		return nil, err
 	}
+	trie.SetCacheSize(config.TrieCache * 1024 * 1024)
+	glog.V(logger.Info).Infof("Allotted %dMB cache to trie nodes", config.TrieCache)
+	trie.SetPreimageRecording(!config.NoPreimages)
+	if config.NoPreimages {
+		glog.V(logger.Info).Infoln("Not recording the preimages of state trie keys")
+	}
 
 	glog.V(logger.Info).Infof("Protocol Versions: %v, Network Id: %v, Chain Id: %v", ProtocolVersions, config.NetworkId, config.ChainConfig.GetChainID())
 	glog.D(logger.Warn).Infof("Protocol Versions: %v, Network Id: %v, Chain Id: %v", logger.ColorGreen(fmt.Sprintf("%v", ProtocolVersions)), logger.ColorGreen(strconv.Itoa(config.NetworkId)), logger.ColorGreen(config.ChainConfig.GetChainID().String()))
@@ -192,6 +290,8 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 		accountManager:          config.AccountManager,
 		etherbase:               config.Etherbase,
 		netVersionId:            config.NetworkId,
+		chainName:               config.ChainName,
+		bootnodes:               config.Bootnodes,
 		NatSpec:                 config.NatSpec,
 		MinerThreads:            config.MinerThreads,
 		SolcPath:                config.SolcPath,
@@ -204,6 +304,26 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 		GpobaseStepUp:           config.GpobaseStepUp,
 		GpobaseCorrectionFactor: config.GpobaseCorrectionFactor,
 		httpclient:              httpclient.New(config.DocRoot),
+		headerOnly:              config.HeaderOnly,
+		remoteAccounts:          config.RemoteAccounts,
+		insecureUnlock:          config.InsecureUnlock,
+		filterConfig: filters.Config{
+			Timeout:    config.FilterTimeout,
+			MaxBlocks:  config.FilterMaxBlocks,
+			MaxResults: config.FilterMaxResults,
+		},
+	}
+	if eth.accountManager == nil {
+		keydir := ctx.ResolvePath("keystore")
+		if keydir == "" {
+			if keydir, err = ioutil.TempDir("", "ellaism-keystore"); err != nil {
+				return nil, err
+			}
+			eth.ephemeralKeyDir = keydir
+		}
+		if eth.accountManager, err = accounts.NewManager(keydir, accounts.StandardScryptN, accounts.StandardScryptP, false); err != nil {
+			return nil, err
+		}
 	}
 	switch {
 	case config.PowTest:
@@ -238,6 +358,8 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 		genName = "morden testnet"
 	} else if fmt.Sprintf("%x", genesis.Hash()) == "d4e56740f876aef8c010b86a40d5f56745a118d0906a34e69aec8c0db1cb8fa3" {
 		genName = "mainnet"
+	} else if fmt.Sprintf("%x", genesis.Hash()) == "b776d5530af9267ffc8ea1195ffaa105ff2893bdb2bd5a9aa2f88a6fe0d48df6" {
+		genName = "dev"
 	} else {
 		genName = "custom"
 	}
@@ -250,25 +372,71 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 
 	eth.chainConfig = config.ChainConfig
 
-	eth.blockchain, err = core.NewBlockChain(chainDb, eth.chainConfig, eth.pow, eth.EventMux())
+	// Refuse a chain config changing blocks the chain holds, unless told to rewind
+	err = core.SetupChainConfig(chainDb, eth.chainConfig)
+	compat, _ := err.(*core.ConfigCompatError)
+	switch {
+	case compat != nil && !config.OverrideChainConfig:
+		return nil, fmt.Errorf("%v; start with --override.chainconfig to rewind the chain", compat)
+	case compat == nil && err != nil:
+		return nil, err
+	}
+
+	if config.Clique != nil {
+		glog.V(logger.Info).Infof("Consensus: clique proof-of-authority with a %d second period", config.Clique.Period)
+		eth.blockchain, err = core.NewBlockChainWithEngine(chainDb, eth.chainConfig, clique.New(config.Clique, chainDb), eth.EventMux())
+	} else {
+		eth.blockchain, err = core.NewBlockChain(chainDb, eth.chainConfig, eth.pow, eth.EventMux())
+	}
 	if err != nil {
 		if err == core.ErrNoGenesis {
 			return nil, fmt.Errorf(`No chain found. Please initialise a new chain using the "init" subcommand.`)
 		}
 		return nil, err
 	}
+	if compat != nil {
+		if err := eth.blockchain.ApplyChainConfig(compat); err != nil {
+			return nil, err
+		}
+	}
+	if config.FutureDrift > 0 {
+		eth.blockchain.SetFutureDrift(config.FutureDrift)
+	}
+	eth.blockchain.SetForensicsDir(ctx.ResolvePath(config.ForensicsDir))
+	if config.GasAudit || config.OpcodeMetrics {
+		processor := core.NewStateProcessor(eth.chainConfig, eth.blockchain)
+		processor.SetGasAudit(config.GasAudit)
+		processor.SetOpcodeMetrics(config.OpcodeMetrics)
+		eth.blockchain.SetProcessor(processor)
+	}
 	eth.gpo = NewGasPriceOracle(eth)
 
-	newPool := core.NewTxPool(eth.chainConfig, eth.EventMux(), eth.blockchain.State, eth.blockchain.GasLimit)
+	poolConfig := config.TxPool
+	poolConfig.Journal = ctx.ResolvePath(poolConfig.Journal)
+	newPool := core.NewTxPool(eth.chainConfig, poolConfig, eth.EventMux(), eth.blockchain.State, eth.blockchain.GasLimit, eth.blockchain.CurrentBlock().Number())
 	eth.txPool = newPool
+	eth.droppedTxs = newDroppedTxLog(eth.txPool, chainDb)
 
-	if eth.protocolManager, err = NewProtocolManager(eth.chainConfig, config.FastSync, config.NetworkId, eth.eventMux, eth.txPool, eth.pow, eth.blockchain, chainDb); err != nil {
+	if eth.protocolManager, err = NewProtocolManager(eth.chainConfig, config.FastSync, config.HeaderOnly, config.NetworkId, eth.eventMux, eth.txPool, eth.blockchain.Engine(), eth.blockchain, chainDb); err != nil {
 		return nil, err
 	}
-	eth.miner = miner.New(eth, eth.chainConfig, eth.EventMux(), eth.pow)
+	if config.FutureDrift > 0 {
+		eth.protocolManager.fetcher.SetFutureDrift(config.FutureDrift)
+	}
+	if config.TraceIndex {
+		eth.traceIndexer = newTraceIndexer(eth)
+	}
+	eth.miner = miner.New(eth, eth.chainConfig, eth.EventMux(), eth.blockchain.Engine())
 	if err = eth.miner.SetGasPrice(config.GasPrice); err != nil {
 		return nil, err
 	}
+	if config.NameRegistry != (common.Address{}) {
+		registry, err := names.NewRegistry(config.NameRegistry, NewContractBackend(eth))
+		if err != nil {
+			return nil, err
+		}
+		eth.names = registry
+	}
 
 	return eth, nil
 }
@@ -276,7 +444,7 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 // APIs returns the collection of RPC services the ethereum package offers.
 // NOTE, some of these services probably need to be moved to somewhere else.
 func (s *Ethereum) APIs() []rpc.API {
-	return []rpc.API{
+	apis := []rpc.API{
 		{
 			Namespace: "eth",
 			Version:   "1.0",
@@ -285,7 +453,7 @@ func (s *Ethereum) APIs() []rpc.API {
 		}, {
 			Namespace: "eth",
 			Version:   "1.0",
-			Service:   NewPublicAccountAPI(s.accountManager),
+			Service:   NewPublicAccountAPI(s.accountManager, s.remoteAccounts),
 			Public:    true,
 		}, {
 			Namespace: "personal",
@@ -295,7 +463,7 @@ func (s *Ethereum) APIs() []rpc.API {
 		}, {
 			Namespace: "eth",
 			Version:   "1.0",
-			Service:   NewPublicBlockChainAPI(s.chainConfig, s.blockchain, s.miner, s.chainDb, s.gpo, s.eventMux, s.accountManager),
+			Service:   NewPublicBlockChainAPI(s.chainConfig, s.blockchain, s.miner, s.chainDb, s.gpo, s.eventMux, s.accountManager, s.names),
 			Public:    true,
 		}, {
 			Namespace: "eth",
@@ -312,6 +480,11 @@ func (s *Ethereum) APIs() []rpc.API {
 			Version:   "1.0",
 			Service:   downloader.NewPublicDownloaderAPI(s.protocolManager.downloader, s.eventMux),
 			Public:    true,
+		}, {
+			Namespace: "ella",
+			Version:   "1.0",
+			Service:   NewPublicEllaAPI(s),
+			Public:    true,
 		}, {
 			Namespace: "miner",
 			Version:   "1.0",
@@ -325,7 +498,7 @@ func (s *Ethereum) APIs() []rpc.API {
 		}, {
 			Namespace: "eth",
 			Version:   "1.0",
-			Service:   filters.NewPublicFilterAPI(s.chainDb, s.eventMux),
+			Service:   filters.NewPublicFilterAPI(s.chainDb, s.eventMux, s.filterConfig),
 			Public:    true,
 		}, {
 			Namespace: "admin",
@@ -336,6 +509,11 @@ func (s *Ethereum) APIs() []rpc.API {
 			Version:   "1.0",
 			Service:   NewPublicDebugAPI(s),
 			Public:    true,
+		}, {
+			Namespace: "trace",
+			Version:   "1.0",
+			Service:   NewPublicTraceAPI(s),
+			Public:    true,
 		}, {
 			Namespace: "net",
 			Version:   "1.0",
@@ -347,19 +525,39 @@ func (s *Ethereum) APIs() []rpc.API {
 			Service:   ethreg.NewPrivateRegistarAPI(s.chainConfig, s.blockchain, s.chainDb, s.txPool, s.accountManager),
 		},
 	}
+	// Append the APIs of the proof-of-authority engine
+	if engine, ok := s.blockchain.Engine().(*clique.Clique); ok {
+		apis = append(apis, engine.APIs(s.blockchain)...)
+	}
+	return apis
 }
 
 func (s *Ethereum) ResetWithGenesisBlock(gb *types.Block) {
 	s.blockchain.ResetWithGenesisBlock(gb)
 }
 
+// authorizeSigner lets a proof-of-authority engine seal blocks with the
+// etherbase, which must be an unlocked local account. It does nothing for
+// other engines.
+func (s *Ethereum) authorizeSigner(eb common.Address) error {
+	engine, ok := s.blockchain.Engine().(*clique.Clique)
+	if !ok {
+		return nil
+	}
+	if !s.accountManager.HasAddress(eb) {
+		return fmt.Errorf("signer %x is not a local account", eb)
+	}
+	engine.Authorize(eb, s.accountManager.Sign)
+	return nil
+}
+
 func (s *Ethereum) Etherbase() (eb common.Address, err error) {
 	eb = s.etherbase
 	if (eb == common.Address{}) {
 		firstAccount, err := s.AccountManager().AccountByIndex(0)
 		eb = firstAccount.Address
 		if err != nil {
-			return eb, fmt.Errorf("etherbase address must be explicitly specified")
+			return eb, errNoEtherbase
 		}
 	}
 	return eb, nil
@@ -400,12 +598,20 @@ func (s *Ethereum) Start(srvr *p2p.Server) error {
 	}
 	s.protocolManager.Start()
 	s.netRPCService = NewPublicNetAPI(srvr, s.NetVersion())
+	if s.traceIndexer != nil {
+		s.traceIndexer.Start()
+	}
+	s.droppedTxs.Start()
 	return nil
 }
 
 // Stop implements node.Service, terminating all internal goroutines used by the
 // Ethereum protocol.
 func (s *Ethereum) Stop() error {
+	if s.traceIndexer != nil {
+		s.traceIndexer.Stop()
+	}
+	s.droppedTxs.Stop()
 	s.blockchain.Stop()
 	s.protocolManager.Stop()
 	s.txPool.Stop()
@@ -416,6 +622,9 @@ func (s *Ethereum) Stop() error {
 
 	s.chainDb.Close()
 	s.dappDb.Close()
+	if s.ephemeralKeyDir != "" {
+		os.RemoveAll(s.ephemeralKeyDir)
+	}
 	close(s.shutdownChan)
 
 	return nil
@@ -518,6 +727,16 @@ func dagFiles(epoch uint64) (string, string) {
 	return dag, "full-R" + dag
 }
 
+// UpgradeChainDatabase upgrades the key layout of a chain database written by
+// older versions in place: blocks stored combined are split into headers and
+// bodies, and the log bloom bins are added if missing.
+func UpgradeChainDatabase(db ethdb.Database) error {
+	if err := upgradeChainDatabase(db); err != nil {
+		return err
+	}
+	return addMipmapBloomBins(db)
+}
+
 // upgradeChainDatabase ensures that the chain database stores block split into
 // separate header and body entries.
 func upgradeChainDatabase(db ethdb.Database) error {
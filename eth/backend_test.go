@@ -25,6 +25,9 @@ import (
 	"github.com/ellaism/go-ellaism/core/types"
 	"github.com/ellaism/go-ellaism/core/vm"
 	"github.com/ellaism/go-ellaism/ethdb"
+	"github.com/ellaism/go-ellaism/node"
+	"github.com/ellaism/go-ellaism/p2p"
+	"github.com/ellaism/go-ellaism/rpc"
 )
 
 func TestMipmapUpgrade(t *testing.T) {
@@ -81,3 +84,89 @@ func TestMipmapUpgrade(t *testing.T) {
 		t.Error("setting-mipmap-version not written to database")
 	}
 }
+
+// chainInfoService is a service of a program embedding the client, offering an
+// API on the chain of the Ethereum service it's registered after.
+type chainInfoService struct {
+	ethereum *Ethereum
+}
+
+func (s *chainInfoService) Protocols() []p2p.Protocol { return nil }
+func (s *chainInfoService) Start(*p2p.Server) error   { return nil }
+func (s *chainInfoService) Stop() error               { return nil }
+
+func (s *chainInfoService) APIs() []rpc.API {
+	return []rpc.API{{Namespace: "chaininfo", Version: "1.0", Service: &ChainInfoAPI{s.ethereum}, Public: true}}
+}
+
+type ChainInfoAPI struct {
+	ethereum *Ethereum
+}
+
+func (api *ChainInfoAPI) Genesis() common.Hash {
+	return api.ethereum.BlockChain().Genesis().Hash()
+}
+
+// Tests that the Ethereum service can be embedded in a node, along with services
+// using its chain and offering their own APIs.
+func TestRegister(t *testing.T) {
+	stack, err := node.New(&node.Config{ListenAddr: ":0", NoDiscovery: true})
+	if err != nil {
+		t.Fatalf("failed to create node: %v", err)
+	}
+	config := DefaultConfig
+	config.ChainConfig = core.DefaultConfigDev.ChainConfig
+	config.Genesis = core.DefaultConfigDev.Genesis
+	config.NetworkId = core.DefaultConfigDev.Network
+	config.PowTest = true
+	if err := Register(stack, &config); err != nil {
+		t.Fatalf("failed to register Ethereum service: %v", err)
+	}
+	if err := stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
+		var ethereum *Ethereum
+		if err := ctx.Service(&ethereum); err != nil {
+			return nil, err
+		}
+		return &chainInfoService{ethereum}, nil
+	}); err != nil {
+		t.Fatalf("failed to register embedding service: %v", err)
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start node: %v", err)
+	}
+	defer stack.Stop()
+
+	var ethereum *Ethereum
+	if err := stack.Service(&ethereum); err != nil {
+		t.Fatalf("failed to retrieve Ethereum service: %v", err)
+	}
+	db, _ := ethdb.NewMemDatabase()
+	genesis, err := core.WriteGenesisBlock(db, core.DefaultConfigDev.Genesis)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash := ethereum.BlockChain().Genesis().Hash(); hash != genesis.Hash() {
+		t.Errorf("genesis mismatch: have %x, want %x", hash, genesis.Hash())
+	}
+	if pending, queued := ethereum.TxPool().Stats(); pending != 0 || queued != 0 {
+		t.Errorf("transaction pool not empty: %d pending, %d queued", pending, queued)
+	}
+	if ethereum.AccountManager() == nil {
+		t.Error("no account manager for ephemeral node")
+	}
+
+	client, err := stack.Attach()
+	if err != nil {
+		t.Fatalf("failed to attach to node: %v", err)
+	}
+	defer client.Close()
+	modules, err := client.SupportedModules()
+	if err != nil {
+		t.Fatalf("failed to retrieve modules: %v", err)
+	}
+	for _, module := range []string{"eth", "chaininfo"} {
+		if _, ok := modules[module]; !ok {
+			t.Errorf("module %q not offered, have %v", module, modules)
+		}
+	}
+}
@@ -19,12 +19,20 @@ package eth
 import (
 	"math/big"
 
+	"github.com/ellaism/go-ellaism/accounts/abi/bind"
 	"github.com/ellaism/go-ellaism/common"
 	"github.com/ellaism/go-ellaism/core/types"
-	"github.com/ellaism/go-ellaism/rlp"
+	"github.com/ellaism/go-ellaism/core/vm"
+	"github.com/ellaism/go-ellaism/eth/filters"
+	"github.com/ellaism/go-ellaism/eth/names"
+	"github.com/ellaism/go-ellaism/ethdb"
+	"github.com/ellaism/go-ellaism/event"
 	"github.com/ellaism/go-ellaism/rpc"
 )
 
+// This nil assignment ensures compile time that ContractBackend implements bind.ContractBackend.
+var _ bind.ContractBackend = (*ContractBackend)(nil)
+
 // ContractBackend implements bind.ContractBackend with direct calls to Ethereum
 // internals to support operating on contracts within subprotocols like eth and
 // swarm.
@@ -36,6 +44,9 @@ type ContractBackend struct {
 	eapi  *PublicEthereumAPI        // Wrapper around the Ethereum object to access metadata
 	bcapi *PublicBlockChainAPI      // Wrapper around the blockchain to access chain data
 	txapi *PublicTransactionPoolAPI // Wrapper around the transaction pool to access transaction data
+
+	chainDb  ethdb.Database // Chain database to search for past logs
+	eventMux *event.TypeMux // Event mux the logs of imported blocks are posted on
 }
 
 // NewContractBackend creates a new native contract backend using an existing
@@ -43,8 +54,11 @@ type ContractBackend struct {
 func NewContractBackend(eth *Ethereum) *ContractBackend {
 	return &ContractBackend{
 		eapi:  NewPublicEthereumAPI(eth),
-		bcapi: NewPublicBlockChainAPI(eth.chainConfig, eth.blockchain, eth.miner, eth.chainDb, eth.gpo, eth.eventMux, eth.accountManager),
+		bcapi: NewPublicBlockChainAPI(eth.chainConfig, eth.blockchain, eth.miner, eth.chainDb, eth.gpo, eth.eventMux, eth.accountManager, eth.names),
 		txapi: NewPublicTransactionPoolAPI(eth),
+
+		chainDb:  eth.chainDb,
+		eventMux: eth.eventMux,
 	}
 }
 
@@ -55,7 +69,7 @@ func (b *ContractBackend) HasCode(contract common.Address, pending bool) (bool,
 	if pending {
 		block = rpc.PendingBlockNumber
 	}
-	out, err := b.bcapi.GetCode(contract, block)
+	out, err := b.bcapi.GetCode(names.Account{Address: contract}, block)
 	return len(common.FromHex(out)) > 0, err
 }
 
@@ -65,7 +79,7 @@ func (b *ContractBackend) HasCode(contract common.Address, pending bool) (bool,
 func (b *ContractBackend) ContractCall(contract common.Address, data []byte, pending bool) ([]byte, error) {
 	// Convert the input args to the API spec
 	args := CallArgs{
-		To:   &contract,
+		To:   &names.Account{Address: contract},
 		Data: common.ToHex(data),
 	}
 	block := rpc.LatestBlockNumber
@@ -80,7 +94,7 @@ func (b *ContractBackend) ContractCall(contract common.Address, data []byte, pen
 // PendingAccountNonce implements bind.ContractTransactor retrieving the current
 // pending nonce associated with an account.
 func (b *ContractBackend) PendingAccountNonce(account common.Address) (uint64, error) {
-	out, err := b.txapi.GetTransactionCount(account, rpc.PendingBlockNumber)
+	out, err := b.txapi.GetTransactionCount(names.Account{Address: account}, rpc.PendingBlockNumber)
 	return out.Uint64(), err
 }
 
@@ -96,9 +110,13 @@ func (b *ContractBackend) SuggestGasPrice() (*big.Int, error) {
 // requirement as other transactions may be added or removed by miners, but it
 // should provide a basis for setting a reasonable default.
 func (b *ContractBackend) EstimateGasLimit(sender common.Address, contract *common.Address, value *big.Int, data []byte) (*big.Int, error) {
+	var to *names.Account
+	if contract != nil {
+		to = &names.Account{Address: *contract}
+	}
 	out, err := b.bcapi.EstimateGas(CallArgs{
 		From:  sender,
-		To:    contract,
+		To:    to,
 		Value: *rpc.NewHexNumber(value),
 		Data:  common.ToHex(data),
 	})
@@ -108,7 +126,37 @@ func (b *ContractBackend) EstimateGasLimit(sender common.Address, contract *comm
 // SendTransaction implements bind.ContractTransactor injects the transaction
 // into the pending pool for execution.
 func (b *ContractBackend) SendTransaction(tx *types.Transaction) error {
-	raw, _ := rlp.EncodeToBytes(tx)
+	raw, _ := tx.MarshalBinary()
 	_, err := b.txapi.SendRawTransaction(common.ToHex(raw))
 	return err
 }
+
+// FilterLogs implements bind.ContractFilterer searching the local chain for
+// logs matching the query.
+func (b *ContractBackend) FilterLogs(query bind.FilterQuery) ([]*vm.Log, error) {
+	return b.logFilter(query).Find()
+}
+
+// SubscribeFilterLogs implements bind.ContractFilterer streaming the logs
+// matching the query as new blocks are imported.
+func (b *ContractBackend) SubscribeFilterLogs(query bind.FilterQuery, ch chan<- *vm.Log) (bind.Subscription, error) {
+	return filters.SubscribeLogs(b.eventMux, b.logFilter(query), ch), nil
+}
+
+// logFilter converts a binding filter query into a chain log filter.
+func (b *ContractBackend) logFilter(query bind.FilterQuery) *filters.Filter {
+	filter := filters.New(b.chainDb)
+
+	filter.SetBeginBlock(0)
+	if query.FromBlock != nil {
+		filter.SetBeginBlock(query.FromBlock.Int64())
+	}
+	filter.SetEndBlock(-1)
+	if query.ToBlock != nil {
+		filter.SetEndBlock(query.ToBlock.Int64())
+	}
+	filter.SetAddresses(query.Addresses)
+	filter.SetTopics(query.Topics)
+
+	return filter
+}
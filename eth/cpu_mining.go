@@ -29,12 +29,19 @@ import (
 const disabledInfo = "Set GO_OPENCL and re-build to enable."
 
 func (s *Ethereum) StartMining(threads int, gpus string) error {
+	if s.headerOnly {
+		return errMiningHeaderOnly
+	}
 	eb, err := s.Etherbase()
 	if err != nil {
 		err = fmt.Errorf("Cannot start mining without etherbase address: %v", err)
 		glog.V(logger.Error).Infoln(err)
 		return err
 	}
+	if err := s.authorizeSigner(eb); err != nil {
+		glog.V(logger.Error).Infoln(err)
+		return err
+	}
 
 	if gpus != "" {
 		return errors.New("GPU mining disabled. " + disabledInfo)
@@ -0,0 +1,138 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/ellaism/go-ellaism/accounts/abi"
+	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/crypto"
+	"github.com/ellaism/go-ellaism/rpc"
+)
+
+// DeployArgs are the arguments of eth_deployContract.
+type DeployArgs struct {
+	From     common.Address    `json:"from"`
+	Bytecode string            `json:"bytecode"` // Creation code of the contract, without the constructor arguments
+	ABI      json.RawMessage   `json:"abi"`      // ABI of the contract, as JSON or a string holding it; optional without arguments
+	Args     []json.RawMessage `json:"args"`     // Constructor arguments, in the JSON form of abi.Type.ParseJSON
+	Gas      *rpc.HexNumber    `json:"gas"`      // Estimated on top of the pending state if not given
+	GasPrice *rpc.HexNumber    `json:"gasPrice"`
+	Value    *rpc.HexNumber    `json:"value"`
+	Nonce    *rpc.HexNumber    `json:"nonce"`
+	Send     bool              `json:"send"` // Whether to add the signed transaction to the pool
+}
+
+// DeployResult is the signed deployment transaction of eth_deployContract.
+type DeployResult struct {
+	Address common.Address `json:"address"` // Address the contract is created at
+	Data    string         `json:"data"`    // Creation code followed by the packed constructor arguments
+	Raw     string         `json:"raw"`
+	Tx      *Tx            `json:"tx"`
+	Hash    *common.Hash   `json:"hash"` // Hash of the transaction if it was sent, nil otherwise
+}
+
+// DeployContract packs the given constructor arguments after the creation code
+// of a contract, estimates the gas of the deployment if not given, signs it
+// with the from account, which must be unlocked, and adds it to the transaction
+// pool if send is set. The address the contract is created at is returned along
+// with the signed transaction, so that tools which can't pack arguments or sign
+// transactions themselves can deploy contracts.
+func (s *PublicTransactionPoolAPI) DeployContract(args DeployArgs) (*DeployResult, error) {
+	code := common.FromHex(args.Bytecode)
+	if len(code) == 0 {
+		return nil, errors.New("empty bytecode")
+	}
+	packed, err := packConstructor(args.ABI, args.Args)
+	if err != nil {
+		return nil, err
+	}
+	data := append(code, packed...)
+
+	if args.Value == nil {
+		args.Value = rpc.NewHexNumber(0)
+	}
+	if args.GasPrice == nil {
+		args.GasPrice = rpc.NewHexNumber(s.gpo.SuggestPrice())
+	}
+	if args.Gas == nil {
+		chain := &PublicBlockChainAPI{config: s.bc.Config(), bc: s.bc, chainDb: s.chainDb, am: s.am, miner: s.miner, gpo: s.gpo, names: s.names}
+		gas, err := chain.EstimateGas(CallArgs{From: args.From, GasPrice: args.GasPrice, Value: *args.Value, Data: common.ToHex(data)})
+		if err != nil {
+			return nil, fmt.Errorf("gas estimation failed: %v", err)
+		}
+		args.Gas = gas
+	}
+
+	s.txMu.Lock()
+	defer s.txMu.Unlock()
+
+	if args.Nonce == nil {
+		args.Nonce = rpc.NewHexNumber(s.txPool.State().GetNonce(args.From))
+	}
+	tx := newTransaction(s.bc.Config().GetChainID(), args.Nonce.Uint64(), nil, args.Value.BigInt(), args.Gas.BigInt(), args.GasPrice.BigInt(), data, nil)
+	signedTx, err := s.sign(args.From, tx)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := signedTx.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	result := &DeployResult{
+		Address: crypto.CreateAddress(args.From, signedTx.Nonce()),
+		Data:    common.ToHex(data),
+		Raw:     common.ToHex(raw),
+		Tx:      newTx(signedTx),
+	}
+	if args.Send {
+		if err := s.addRawTransaction(signedTx); err != nil {
+			return nil, err
+		}
+		hash := signedTx.Hash()
+		result.Hash = &hash
+	}
+	return result, nil
+}
+
+// packConstructor packs the given constructor arguments with the given ABI,
+// which may be a JSON string holding the ABI, as compilers output it.
+func packConstructor(definition json.RawMessage, args []json.RawMessage) ([]byte, error) {
+	if len(bytes.TrimSpace(definition)) == 0 || bytes.Equal(definition, []byte("null")) {
+		if len(args) > 0 {
+			return nil, errors.New("constructor arguments given without ABI")
+		}
+		return nil, nil
+	}
+	var encoded string
+	if err := json.Unmarshal(definition, &encoded); err == nil {
+		definition = json.RawMessage(encoded)
+	}
+	parsed, err := abi.JSON(bytes.NewReader(definition))
+	if err != nil {
+		return nil, fmt.Errorf("invalid ABI: %v", err)
+	}
+	packed, err := parsed.PackJSON("", args)
+	if err != nil {
+		return nil, fmt.Errorf("invalid constructor arguments: %v", err)
+	}
+	return packed, nil
+}
@@ -0,0 +1,116 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/ellaism/go-ellaism/accounts"
+	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/core"
+	"github.com/ellaism/go-ellaism/crypto"
+	"github.com/ellaism/go-ellaism/ethdb"
+	"github.com/ellaism/go-ellaism/event"
+	"github.com/ellaism/go-ellaism/rpc"
+)
+
+// Tests that deployments are packed with their constructor arguments, signed
+// and optionally sent, predicting the address of the contract.
+func TestDeployContract(t *testing.T) {
+	var (
+		db, _       = ethdb.NewMemDatabase()
+		genesis     = core.WriteGenesisBlockForTesting(db, testBank)
+		chainConfig = core.MakeDiehardChainConfig()
+	)
+	blockchain, err := core.NewBlockChain(db, chainConfig, new(core.FakePow), new(event.TypeMux))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer blockchain.Stop()
+
+	config := core.DefaultTxPoolConfig
+	config.Journal = ""
+	pool := core.NewTxPool(chainConfig, config, new(event.TypeMux), blockchain.State, func() *big.Int { return genesis.GasLimit() }, blockchain.CurrentBlock().Number())
+	defer pool.Stop()
+
+	dir, err := ioutil.TempDir("", "eth-deploy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	am, err := accounts.NewManager(dir, accounts.LightScryptN, accounts.LightScryptP, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	account, err := am.ImportECDSA(testBankKey, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := am.Unlock(account, "secret"); err != nil {
+		t.Fatal(err)
+	}
+	api := &PublicTransactionPoolAPI{chainDb: db, bc: blockchain, am: am, txPool: pool, txMu: new(sync.Mutex)}
+
+	args := DeployArgs{
+		From:     testBank.Address,
+		Bytecode: "0x6000",
+		ABI:      json.RawMessage(`"[{\"type\":\"constructor\",\"inputs\":[{\"name\":\"supply\",\"type\":\"uint256\"}]}]"`),
+		Args:     []json.RawMessage{json.RawMessage(`"0x05"`)},
+		Gas:      rpc.NewHexNumber(100000),
+		GasPrice: rpc.NewHexNumber(1),
+		Nonce:    rpc.NewHexNumber(0),
+		Send:     true,
+	}
+	result, err := api.DeployContract(args)
+	if err != nil {
+		t.Fatalf("failed to deploy: %v", err)
+	}
+	if want := crypto.CreateAddress(testBank.Address, 0); result.Address != want {
+		t.Errorf("contract address mismatch: have %x, want %x", result.Address, want)
+	}
+	if want := "0x6000" + common.Bytes2Hex(common.LeftPadBytes([]byte{5}, 32)); result.Data != want || result.Tx.Data != want {
+		t.Errorf("deployment data mismatch: have %s (tx %s), want %s", result.Data, result.Tx.Data, want)
+	}
+	if result.Hash == nil || pool.GetTransaction(*result.Hash) == nil {
+		t.Errorf("sent deployment missing from the pool")
+	}
+	// Deployments only signed are left to the caller to send
+	args.Nonce, args.Send = rpc.NewHexNumber(1), false
+	if result, err = api.DeployContract(args); err != nil {
+		t.Fatalf("failed to sign deployment: %v", err)
+	}
+	if want := crypto.CreateAddress(testBank.Address, 1); result.Address != want || result.Hash != nil {
+		t.Errorf("signed deployment mismatch: address %x (want %x), hash %v", result.Address, want, result.Hash)
+	}
+	if pending, _ := pool.Stats(); pending != 1 {
+		t.Errorf("pending transactions: have %d, want 1", pending)
+	}
+	// Arguments must match the constructor and need its ABI
+	args.Args = []json.RawMessage{json.RawMessage(`"five"`)}
+	if _, err := api.DeployContract(args); err == nil {
+		t.Errorf("deployment with invalid arguments signed")
+	}
+	args.ABI = nil
+	if _, err := api.DeployContract(args); err == nil {
+		t.Errorf("deployment with arguments but no ABI signed")
+	}
+}
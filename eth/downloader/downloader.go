@@ -72,6 +72,9 @@ var (
 	fsPivotInterval        = 512  // Number of headers out of which to randomize the pivot point
 	fsMinFullBlocks        = 1024 // Number of blocks to retrieve fully even in fast sync
 	fsCriticalTrials       = 10   // Number of times to retry in the cricical section before bailing
+
+	minStateFetch  = 64               // Amount of node state values to request at least, even from peers of unknown throughput
+	stateBloomSize = 16 * 1024 * 1024 // Size in bytes of the bloom of the state trie nodes stored locally
 )
 
 var (
@@ -116,6 +119,8 @@ type Downloader struct {
 	queue *queue   // Scheduler for selecting the hashes to download
 	peers *peerSet // Set of active peers from which download can proceed
 
+	stateBloom *trie.SyncBloom // Bloom of the trie nodes stored by the state syncs (loaded by the first fast sync)
+
 	fsPivotLock  *types.Header // Pivot header on critical section entry (cannot change between retries)
 	fsPivotFails int           // Number of fast sync failures in the critical section
 
@@ -184,7 +189,7 @@ func New(stateDb ethdb.Database, mux *event.TypeMux, hasHeader headerCheckFn, ha
 	dl := &Downloader{
 		mode:             FullSync,
 		mux:              mux,
-		queue:            newQueue(stateDb),
+		queue:            newQueue(stateDb, nil),
 		peers:            newPeerSet(),
 		rttEstimate:      uint64(rttMaxEstimate),
 		rttConfidence:    uint64(1000000),
@@ -374,8 +379,18 @@ func (d *Downloader) synchronise(id string, hash common.Hash, td *big.Int, mode
 	if atomic.CompareAndSwapInt32(&d.notified, 0, 1) {
 		glog.V(logger.Info).Infoln("Block synchronisation started")
 	}
+	// Load the bloom of the stored state trie nodes on the first fast sync, and
+	// persist the nodes the previous sync added to it
+	if mode == FastSync && d.stateBloom == nil {
+		d.stateBloom = trie.NewSyncBloom(stateBloomSize, d.queue.stateDatabase)
+	}
+	if d.stateBloom != nil {
+		if err := d.stateBloom.Flush(); err != nil {
+			glog.V(logger.Warn).Warnf("failed to persist the state sync bloom: %v", err)
+		}
+	}
 	// Reset the queue, peer set and wake channels to clean any internal leftover state
-	d.queue = newQueue(d.queue.stateDatabase)
+	d.queue = newQueue(d.queue.stateDatabase, d.stateBloom)
 	d.peers.Reset()
 
 	for _, ch := range []chan bool{d.bodyWakeCh, d.receiptWakeCh, d.stateWakeCh} {
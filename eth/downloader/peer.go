@@ -300,12 +300,13 @@ func (p *peer) ReceiptCapacity(targetRTT time.Duration) int {
 }
 
 // NodeDataCapacity retrieves the peers state download allowance based on its
-// previously discovered throughput.
+// previously discovered throughput. State is requested in large batches even
+// from peers of unknown throughput, its entries being small.
 func (p *peer) NodeDataCapacity(targetRTT time.Duration) int {
 	p.lock.RLock()
 	defer p.lock.RUnlock()
 
-	return int(math.Min(1+math.Max(1, p.stateThroughput*float64(targetRTT)/float64(time.Second)), float64(MaxStateFetch)))
+	return int(math.Min(1+math.Max(float64(minStateFetch), p.stateThroughput*float64(targetRTT)/float64(time.Second)), float64(MaxStateFetch)))
 }
 
 // MarkLacking appends a new entity to the set of items (blocks, receipts, states)
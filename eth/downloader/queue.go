@@ -109,6 +109,10 @@ type queue struct {
 	stateProcessors int32            // [eth/63] Number of currently running state processors
 	stateSchedLock  sync.RWMutex     // [eth/63] Lock serialising access to the state scheduler
 
+	stateBloom  *trie.SyncBloom // [eth/63] Bloom of the trie nodes stored by the state syncs
+	stateRoot   common.Hash     // [eth/63] Root of the state being synced
+	stateHealed bool            // [eth/63] Whether the heal phase of the state sync ran
+
 	resultCache  []*fetchResult // Downloaded but not yet delivered fetch results
 	resultOffset uint64         // Offset of the first cached fetch result in the block chain
 
@@ -117,7 +121,7 @@ type queue struct {
 }
 
 // newQueue creates a new download queue for scheduling block retrieval.
-func newQueue(stateDb ethdb.Database) *queue {
+func newQueue(stateDb ethdb.Database, stateBloom *trie.SyncBloom) *queue {
 	q := &queue{
 		headerPendPool:   make(map[string]*fetchRequest),
 		headerContCh:     make(chan bool),
@@ -133,6 +137,7 @@ func newQueue(stateDb ethdb.Database) *queue {
 		stateTaskQueue:   prque.New(),
 		statePendPool:    make(map[string]*fetchRequest),
 		stateDatabase:    stateDb,
+		stateBloom:       stateBloom,
 		resultCache:      make([]*fetchResult, blockCacheLimit),
 		done:             make(chan struct{}),
 	}
@@ -347,7 +352,7 @@ func (q *queue) Schedule(headers []*types.Header, from uint64) []*types.Header {
 		if q.mode == FastSync && header.Number.Uint64() == q.fastSyncPivot {
 			// Pivoting point of the fast sync, retrieve the state tries
 			q.stateSchedLock.Lock()
-			q.stateScheduler = state.NewStateSync(header.Root, q.stateDatabase)
+			q.startStateSync(header.Root)
 			q.stateSchedLock.Unlock()
 		}
 		inserts = append(inserts, header)
@@ -1036,28 +1041,57 @@ func (q *queue) deliverNodeData(results []trie.SyncResult, callback func(error,
 	// might be waiting for the pivot block state to get completed.
 	defer q.active.Signal()
 
-	// Process results one by one to permit task fetches in between
-	for i, result := range results {
-		q.stateSchedLock.Lock()
-
-		if q.stateScheduler == nil {
-			// Syncing aborted since this async delivery started, bail out
-			q.stateSchedLock.Unlock()
-			callback(errNoFetchesPending, i)
-			return
-		}
-		if _, err := q.stateScheduler.Process([]trie.SyncResult{result}); err != nil {
-			// Processing a state result failed, bail out
+	// Process the results in one batch, written to the database at once
+	q.stateSchedLock.Lock()
+	if q.stateScheduler == nil {
+		// Syncing aborted since this async delivery started, bail out
+		q.stateSchedLock.Unlock()
+		callback(errNoFetchesPending, 0)
+		return
+	}
+	if i, err := q.stateScheduler.Process(results); err != nil {
+		// Processing a state result failed, bail out
+		q.stateSchedLock.Unlock()
+		callback(err, i)
+		return
+	}
+	// Once all known entries are retrieved, heal the state of the entries an
+	// interrupted sync may have left out before accepting it as complete
+	if q.stateScheduler.Pending() == 0 && !q.stateHealed {
+		if err := q.healState(); err != nil {
 			q.stateSchedLock.Unlock()
-			callback(err, i)
+			callback(err, len(results))
 			return
 		}
-		// Item processing succeeded, release the lock (temporarily)
-		q.stateSchedLock.Unlock()
 	}
+	q.stateSchedLock.Unlock()
+
 	callback(nil, len(results))
 }
 
+// healState runs the heal phase of the state sync, walking the synced state
+// and scheduling the retrieval of its missing entries. Once the state is found
+// complete, the bloom of the stored nodes is persisted.
+//
+// Note, this method expects the state scheduler lock to be already held for
+// writing.
+func (q *queue) healState() error {
+	start := time.Now()
+	healed, err := q.stateScheduler.Heal(q.stateRoot)
+	if err != nil {
+		return err
+	}
+	glog.V(logger.Info).Infof("state heal of %x scheduled %d missing entries in %v", q.stateRoot[:4], healed, time.Since(start))
+	if healed > 0 {
+		return nil
+	}
+	q.stateHealed = true
+	if q.stateBloom != nil {
+		return q.stateBloom.Flush()
+	}
+	return nil
+}
+
 // Prepare configures the result cache to allow accepting and caching inbound
 // fetch results.
 func (q *queue) Prepare(offset uint64, mode SyncMode, pivot uint64, head *types.Header) {
@@ -1073,6 +1107,16 @@ func (q *queue) Prepare(offset uint64, mode SyncMode, pivot uint64, head *types.
 
 	// If long running fast sync, also start up a head stateretrieval immediately
 	if mode == FastSync && pivot > 0 {
-		q.stateScheduler = state.NewStateSync(head.Root, q.stateDatabase)
+		q.startStateSync(head.Root)
 	}
 }
+
+// startStateSync replaces the state scheduler with one syncing the given root.
+//
+// Note, this method expects the state scheduler lock to be already held for
+// writing.
+func (q *queue) startStateSync(root common.Hash) {
+	q.stateScheduler = state.NewStateSync(root, q.stateDatabase, q.stateBloom)
+	q.stateRoot = root
+	q.stateHealed = false
+}
@@ -0,0 +1,200 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/core"
+	"github.com/ellaism/go-ellaism/ethdb"
+	"github.com/ellaism/go-ellaism/event"
+	"github.com/ellaism/go-ellaism/rpc"
+)
+
+// droppedTxLogSize is the number of dropped transactions remembered for
+// txpool_droppedSince.
+const droppedTxLogSize = 4096
+
+// DroppedTransaction is a transaction which left the transaction pool without
+// being mined. The reason is one of:
+//   - "replaced": another transaction of the sender with the same nonce was mined
+//   - "invalidated": the sender can't pay for the transaction anymore as of a new head
+//   - "evicted": the transaction pool limits were exceeded
+//   - "expired": the sender had no transaction queued or promoted for the pool lifetime
+//   - "removed": the transaction was removed on request, e.g. by eth_resend
+type DroppedTransaction struct {
+	Cursor uint64         `json:"cursor"` // Position of the transaction in the log of dropped transactions
+	Hash   common.Hash    `json:"hash"`
+	From   common.Address `json:"from"`
+	Nonce  *rpc.HexNumber `json:"nonce"`
+	Reason string         `json:"reason"`
+}
+
+// DroppedTransactions is the result of txpool_droppedSince.
+type DroppedTransactions struct {
+	Dropped []*DroppedTransaction `json:"dropped"`
+	Next    uint64                `json:"next"`   // Cursor to pass for the transactions dropped after these
+	Missed  bool                  `json:"missed"` // Whether transactions dropped after the given cursor were forgotten
+}
+
+// droppedTxLog keeps the last transactions dropped by the transaction pool,
+// numbered by a cursor, and notifies subscribers of them. Transactions dropped
+// for their nonce being used by a new head are only logged, as replaced, if
+// they aren't the transaction mined with that nonce.
+type droppedTxLog struct {
+	pool    *core.TxPool
+	chainDb ethdb.Database
+
+	mu      sync.RWMutex
+	entries []*DroppedTransaction // Ring buffer of the last dropped transactions
+	next    uint64                // Cursor of the next dropped transaction
+	feed    event.Feed            // Delivers []*DroppedTransaction as they're logged
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+func newDroppedTxLog(pool *core.TxPool, chainDb ethdb.Database) *droppedTxLog {
+	return &droppedTxLog{
+		pool:    pool,
+		chainDb: chainDb,
+		entries: make([]*DroppedTransaction, droppedTxLogSize),
+		quit:    make(chan struct{}),
+	}
+}
+
+// Start starts logging the transactions dropped by the pool.
+func (l *droppedTxLog) Start() {
+	ch := make(chan core.DroppedTxsEvent, 16)
+	sub := l.pool.SubscribeDroppedTxsEvent(ch)
+
+	l.wg.Add(1)
+	go func() {
+		defer l.wg.Done()
+		defer sub.Unsubscribe()
+
+		for {
+			select {
+			case ev := <-ch:
+				l.add(ev.Txs)
+			case <-sub.Err():
+				return
+			case <-l.quit:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops logging the transactions dropped by the pool.
+func (l *droppedTxLog) Stop() {
+	close(l.quit)
+	l.wg.Wait()
+}
+
+// add logs the given dropped transactions and notifies the subscribers.
+func (l *droppedTxLog) add(txs []core.DroppedTx) {
+	var logged []*DroppedTransaction
+
+	l.mu.Lock()
+	for _, drop := range txs {
+		reason := drop.Reason
+		if reason == core.TxDropNonceUsed {
+			if tx, _, _, _ := core.GetTransaction(l.chainDb, drop.Tx.Hash()); tx != nil {
+				continue
+			}
+			reason = "replaced"
+		}
+		from, _ := drop.Tx.From() // already validated by the pool
+		entry := &DroppedTransaction{
+			Cursor: l.next,
+			Hash:   drop.Tx.Hash(),
+			From:   from,
+			Nonce:  rpc.NewHexNumber(drop.Tx.Nonce()),
+			Reason: reason,
+		}
+		l.entries[l.next%droppedTxLogSize] = entry
+		l.next++
+		logged = append(logged, entry)
+	}
+	l.mu.Unlock()
+
+	if len(logged) > 0 {
+		l.feed.Send(logged)
+	}
+}
+
+// since returns the logged transactions from the given cursor on.
+func (l *droppedTxLog) since(cursor uint64) *DroppedTransactions {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	result := &DroppedTransactions{Dropped: []*DroppedTransaction{}, Next: l.next}
+	if cursor > l.next {
+		cursor = l.next
+	}
+	if l.next > droppedTxLogSize && cursor < l.next-droppedTxLogSize {
+		cursor, result.Missed = l.next-droppedTxLogSize, true
+	}
+	for ; cursor < l.next; cursor++ {
+		result.Dropped = append(result.Dropped, l.entries[cursor%droppedTxLogSize])
+	}
+	return result
+}
+
+// DroppedSince returns the transactions dropped by the transaction pool from the
+// given cursor on, 0 for the oldest remembered, along with the cursor to pass to
+// get those dropped afterwards. Only the last 4096 dropped transactions are
+// remembered; if some dropped after the given cursor were forgotten, missed is
+// set.
+func (s *PublicTxPoolAPI) DroppedSince(cursor uint64) *DroppedTransactions {
+	return s.e.droppedTxs.since(cursor)
+}
+
+// DroppedTransactions creates a subscription notifying of the transactions
+// dropped by the transaction pool as they're dropped, in batches.
+func (s *PublicTransactionPoolAPI) DroppedTransactions(ctx context.Context) (rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return nil, rpc.ErrNotificationsUnsupported
+	}
+	ch := make(chan []*DroppedTransaction, 16)
+	sub := s.droppedTxs.feed.Subscribe(ch)
+
+	subscription, err := notifier.NewSubscription(func(string) {
+		sub.Unsubscribe()
+	})
+	if err != nil {
+		sub.Unsubscribe()
+		return nil, err
+	}
+	go func() {
+		for {
+			select {
+			case dropped := <-ch:
+				if subscription.Notify(dropped) == rpc.ErrNotificationNotFound {
+					return
+				}
+			case <-sub.Err():
+				return
+			}
+		}
+	}()
+	return subscription, nil
+}
@@ -0,0 +1,89 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/core"
+	"github.com/ellaism/go-ellaism/core/types"
+	"github.com/ellaism/go-ellaism/ethdb"
+)
+
+// Tests that dropped transactions are logged with their reason, except those
+// mined, and can be listed from a cursor.
+func TestDroppedTxLog(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+
+	transfer := func(nonce uint64) *types.Transaction {
+		tx, _ := types.NewTransaction(nonce, common.Address{0x01}, big.NewInt(1), big.NewInt(21000), big.NewInt(1), nil).SignECDSA(testBankKey)
+		return tx
+	}
+	mined, replaced, evicted := transfer(0), transfer(0), transfer(1)
+	if err := core.WriteTransactions(db, types.NewBlock(&types.Header{Number: big.NewInt(1)}, []*types.Transaction{mined}, nil, nil)); err != nil {
+		t.Fatalf("failed to write transactions: %v", err)
+	}
+	log := newDroppedTxLog(nil, db)
+
+	notified := make(chan []*DroppedTransaction, 1)
+	sub := log.feed.Subscribe(notified)
+	defer sub.Unsubscribe()
+
+	log.add([]core.DroppedTx{
+		{Tx: mined, Reason: core.TxDropNonceUsed},
+		{Tx: replaced, Reason: core.TxDropNonceUsed},
+		{Tx: evicted, Reason: core.TxDropEvicted},
+	})
+	if dropped := <-notified; len(dropped) != 2 {
+		t.Fatalf("notified transactions mismatch: have %d, want %d", len(dropped), 2)
+	}
+	result := log.since(0)
+	if len(result.Dropped) != 2 || result.Next != 2 || result.Missed {
+		t.Fatalf("dropped transactions mismatch: have %d (next %d, missed %v), want 2 (next 2, missed false)", len(result.Dropped), result.Next, result.Missed)
+	}
+	for i, want := range []struct {
+		hash   common.Hash
+		reason string
+	}{{replaced.Hash(), "replaced"}, {evicted.Hash(), core.TxDropEvicted}} {
+		if have := result.Dropped[i]; have.Cursor != uint64(i) || have.Hash != want.hash || have.From != testBank.Address || have.Reason != want.reason {
+			t.Errorf("dropped tx %d mismatch: have %+v, want hash %x, reason %s", i, have, want.hash, want.reason)
+		}
+	}
+	if result := log.since(1); len(result.Dropped) != 1 || result.Dropped[0].Hash != evicted.Hash() {
+		t.Errorf("dropped transactions since 1 mismatch: have %v", result.Dropped)
+	}
+	if result := log.since(5); len(result.Dropped) != 0 || result.Next != 2 {
+		t.Errorf("dropped transactions since 5 mismatch: have %v, next %d", result.Dropped, result.Next)
+	}
+	// Overflow the log and check that the forgotten transactions are reported
+	drops := make([]core.DroppedTx, droppedTxLogSize)
+	for i := range drops {
+		drops[i] = core.DroppedTx{Tx: evicted, Reason: core.TxDropEvicted}
+	}
+	log.add(drops)
+	<-notified
+
+	result = log.since(1)
+	if len(result.Dropped) != droppedTxLogSize || !result.Missed || result.Dropped[0].Cursor != 2 {
+		t.Errorf("overflown log mismatch: have %d (missed %v), want %d (missed true) from cursor 2", len(result.Dropped), result.Missed, droppedTxLogSize)
+	}
+	if result := log.since(2); result.Missed {
+		t.Errorf("remembered transactions reported missed")
+	}
+}
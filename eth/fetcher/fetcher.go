@@ -38,6 +38,8 @@ const (
 	fetchTimeout  = 5 * time.Second        // Maximum allotted time to return an explicitly requested block
 	maxUncleDist  = 7                      // Maximum allowed backward distance from the chain head
 	maxQueueDist  = 32                     // Maximum allowed distance from the chain head to queue
+	maxFutureWait = 30 * time.Second       // Default maximum time to hold a block with a future timestamp
+	maxFutures    = 256                    // Maximum number of blocks held for their future timestamp
 	hashLimit     = 256                    // Maximum number of unique blocks a peer may have announced
 	blockLimit    = 64                     // Maximum number of unique blocks a per may have delivered
 )
@@ -115,8 +117,11 @@ type Fetcher struct {
 	headerFilter chan chan *headerFilterTask
 	bodyFilter   chan chan *bodyFilterTask
 
-	done chan common.Hash
-	quit chan struct{}
+	done   chan common.Hash
+	orphan chan *inject     // Blocks whose parent is unknown, to hold until it arrives
+	future chan *inject     // Blocks with a future timestamp, to hold until then
+	ready  chan common.Hash // Held future blocks whose timestamp has come
+	quit   chan struct{}
 
 	// Announce states
 	announces  map[string]int              // Per peer announce counts to prevent memory exhaustion
@@ -130,6 +135,12 @@ type Fetcher struct {
 	queues map[string]int          // Per peer block counts to prevent memory exhaustion
 	queued map[common.Hash]*inject // Set of already queued blocks (to dedup imports)
 
+	// Blocks held until importable, still accounted as queued
+	orphans map[common.Hash][]*inject // Blocks waiting for their unknown parent, by parent hash
+	futures map[common.Hash]*inject   // Blocks waiting for their future timestamp
+
+	futureDrift time.Duration // Maximum time to hold a block with a future timestamp
+
 	// Callbacks
 	getBlock       blockRetrievalFn   // Retrieves a block from the local chain
 	validateBlock  blockValidatorFn   // Checks if a block's headers have a valid proof of work
@@ -155,6 +166,9 @@ func New(getBlock blockRetrievalFn, validateBlock blockValidatorFn, broadcastBlo
 		headerFilter:   make(chan chan *headerFilterTask),
 		bodyFilter:     make(chan chan *bodyFilterTask),
 		done:           make(chan common.Hash),
+		orphan:         make(chan *inject),
+		future:         make(chan *inject),
+		ready:          make(chan common.Hash),
 		quit:           make(chan struct{}),
 		announces:      make(map[string]int),
 		announced:      make(map[common.Hash][]*announce),
@@ -164,6 +178,9 @@ func New(getBlock blockRetrievalFn, validateBlock blockValidatorFn, broadcastBlo
 		queue:          prque.New(),
 		queues:         make(map[string]int),
 		queued:         make(map[common.Hash]*inject),
+		orphans:        make(map[common.Hash][]*inject),
+		futures:        make(map[common.Hash]*inject),
+		futureDrift:    maxFutureWait,
 		getBlock:       getBlock,
 		validateBlock:  validateBlock,
 		broadcastBlock: broadcastBlock,
@@ -173,6 +190,13 @@ func New(getBlock blockRetrievalFn, validateBlock blockValidatorFn, broadcastBlo
 	}
 }
 
+// SetFutureDrift sets the maximum time a block's timestamp may be ahead of the
+// local clock for the block to be held until then rather than discarded. It
+// must be called before the fetcher is started.
+func (f *Fetcher) SetFutureDrift(drift time.Duration) {
+	f.futureDrift = drift
+}
+
 // Start boots up the announcement based synchroniser, accepting and processing
 // hash notifications and block fetches until termination requested.
 func (f *Fetcher) Start() {
@@ -284,6 +308,8 @@ func (f *Fetcher) loop() {
 	completeTimer := time.NewTimer(0)
 	defer fetchTimer.Stop()
 
+	var orphanHeight uint64 // Chain height when the held orphans were last checked
+
 	for {
 		// Clean up any expired block fetches
 		for hash, announce := range f.fetching {
@@ -291,8 +317,13 @@ func (f *Fetcher) loop() {
 				f.forgetHash(hash)
 			}
 		}
-		// Import any queued blocks that could potentially fit
+		// Requeue any orphans whose parent was imported by other means
 		height := f.chainHeight()
+		if height != orphanHeight {
+			orphanHeight = height
+			f.checkOrphans(height)
+		}
+		// Import any queued blocks that could potentially fit
 		for !f.queue.Empty() {
 			op := f.queue.PopItem().(*inject)
 			if f.queueChangeHook != nil {
@@ -372,6 +403,38 @@ func (f *Fetcher) loop() {
 			// A pending import finished, remove all traces of the notification
 			f.forgetHash(hash)
 			f.forgetBlock(hash)
+			f.releaseOrphans(hash)
+
+		case op := <-f.orphan:
+			// A block's parent is unknown, hold it until the parent is imported
+			parent := op.block.ParentHash()
+			f.orphans[parent] = append(f.orphans[parent], op)
+
+		case op := <-f.future:
+			// A block's timestamp is in the future, hold it until then unless
+			// too many blocks are held already
+			hash := op.block.Hash()
+			if len(f.futures) >= maxFutures {
+				glog.V(logger.Debug).Infof("Peer %s: block #%d [%s] is in the future, too many held, discarding", op.origin, op.block.NumberU64(), hash.Hex())
+				f.forgetHash(hash)
+				f.forgetBlock(hash)
+				f.releaseOrphans(hash)
+				break
+			}
+			f.futures[hash] = op
+			time.AfterFunc(time.Unix(op.block.Time().Int64(), 0).Sub(time.Now()), func() {
+				select {
+				case f.ready <- hash:
+				case <-f.quit:
+				}
+			})
+
+		case hash := <-f.ready:
+			// A held block's timestamp has come, schedule it for import
+			if op := f.futures[hash]; op != nil {
+				delete(f.futures, hash)
+				f.requeue(op)
+			}
 
 		case <-fetchTimer.C:
 			// At least one block's timer ran out, check for needing retrieval
@@ -675,28 +738,27 @@ func (f *Fetcher) enqueue(peer string, block *types.Block) {
 
 // insert spawns a new goroutine to run a block insertion into the chain. If the
 // block's number is at the same height as the current import phase, it updates
-// the phase states accordingly.
+// the phase states accordingly. Blocks which can't be imported yet, because
+// their parent is unknown or their timestamp in the future, are handed back to
+// the fetcher to hold until they can.
 func (f *Fetcher) insert(peer string, block *types.Block) {
 	hash := block.Hash()
 
 	// Run the import on a new thread
 	glog.V(logger.Debug).Infof("Peer %s: importing block #%d [%s]", peer, block.NumberU64(), hash.Hex())
 	go func() {
-
-		haveParent := true
-
+		held := false
 		defer func() {
-			if haveParent {
+			if !held {
 				f.done <- hash
 			}
 		}()
 
-		// If the parent's unknown, abort insertion, and don't forget the hash and block;
-		// use queue gap fill to get unknown parent.
+		// If the parent's unknown, hold the block until the parent arrives
 		parent := f.getBlock(block.ParentHash())
 		if parent == nil {
-			glog.V(logger.Debug).Infof("Peer %s: parent [%s] of block #%d [%s] unknown", peer, block.ParentHash().Hex(), block.NumberU64(), hash.Hex())
-			haveParent = false
+			glog.V(logger.Debug).Infof("Peer %s: parent [%s] of block #%d [%s] unknown, holding", peer, block.ParentHash().Hex(), block.NumberU64(), hash.Hex())
+			held = f.hold(f.orphan, &inject{origin: peer, block: block})
 			return
 		}
 		// Quickly validate the header and propagate the block if it passes
@@ -707,7 +769,14 @@ func (f *Fetcher) insert(peer string, block *types.Block) {
 			go f.broadcastBlock(block, true)
 
 		case core.BlockFutureErr:
-			// Weird future block, don't fail, but neither propagate
+			// Hold the block until its timestamp, unless too far in the future
+			if wait := time.Unix(block.Time().Int64(), 0).Sub(time.Now()); wait > f.futureDrift {
+				glog.V(logger.Debug).Infof("Peer %s: block #%d [%s] is %v in the future, discarding", peer, block.NumberU64(), hash.Hex(), wait)
+				return
+			}
+			glog.V(logger.Debug).Infof("Peer %s: block #%d [%s] is in the future, holding", peer, block.NumberU64(), hash.Hex())
+			held = f.hold(f.future, &inject{origin: peer, block: block})
+			return
 
 		default:
 			// Something went very wrong, drop the peer
@@ -731,6 +800,68 @@ func (f *Fetcher) insert(peer string, block *types.Block) {
 	}()
 }
 
+// hold hands a block which can't be imported yet back to the fetcher loop,
+// reporting whether the fetcher took it.
+func (f *Fetcher) hold(ch chan *inject, op *inject) bool {
+	select {
+	case ch <- op:
+		return true
+	case <-f.quit:
+		return false
+	}
+}
+
+// requeue schedules a held block for import again.
+func (f *Fetcher) requeue(op *inject) {
+	f.queue.Push(op, -float32(op.block.NumberU64()))
+	if f.queueChangeHook != nil {
+		f.queueChangeHook(op.block.Hash(), true)
+	}
+}
+
+// releaseOrphans requeues the blocks waiting for the given parent if it was
+// imported, or forgets them if it wasn't.
+func (f *Fetcher) releaseOrphans(parent common.Hash) {
+	ops := f.orphans[parent]
+	if ops == nil {
+		return
+	}
+	delete(f.orphans, parent)
+
+	imported := f.getBlock(parent) != nil
+	for _, op := range ops {
+		if imported {
+			f.requeue(op)
+		} else {
+			f.forgetBlock(op.block.Hash())
+		}
+	}
+}
+
+// checkOrphans requeues the orphans whose parent is known by now, and forgets
+// the ones too far behind the chain head to import.
+func (f *Fetcher) checkOrphans(height uint64) {
+	for parent, ops := range f.orphans {
+		if f.getBlock(parent) != nil {
+			f.releaseOrphans(parent)
+			continue
+		}
+		kept := ops[:0]
+		for _, op := range ops {
+			if op.block.NumberU64()+maxUncleDist < height {
+				f.forgetBlock(op.block.Hash())
+			} else {
+				kept = append(kept, op)
+			}
+		}
+		if len(kept) == 0 {
+			delete(f.orphans, parent)
+		} else {
+			f.orphans[parent] = kept
+		}
+	}
+}
+
 // forgetHash removes all traces of a block announcement from the fetcher's
 // internal state.
 func (f *Fetcher) forgetHash(hash common.Hash) {
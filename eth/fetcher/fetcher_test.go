@@ -141,8 +141,12 @@ func (f *fetcherTester) getBlock(hash common.Hash) *types.Block {
 	return f.blocks[hash]
 }
 
-// verifyBlock is a nop placeholder for the block header verification.
+// verifyBlock is a placeholder for the block header verification, only
+// rejecting blocks from the future.
 func (f *fetcherTester) verifyBlock(block *types.Block, parent *types.Block) error {
+	if block.Time().Cmp(big.NewInt(time.Now().Unix())) > 0 {
+		return core.BlockFutureErr
+	}
 	return nil
 }
 
@@ -168,8 +172,9 @@ func (f *fetcherTester) insertChain(blocks types.Blocks) (int, error) {
 		if _, ok := f.blocks[block.ParentHash()]; !ok {
 			return i, errors.New("unknown parent")
 		}
-		// Discard any new blocks if the same height already exists
+		// Keep any new blocks as side blocks if the same height already exists
 		if block.NumberU64() <= f.blocks[f.hashes[len(f.hashes)-1]].NumberU64() {
+			f.blocks[block.Hash()] = block
 			return i, nil
 		}
 		// Otherwise build our current chain
@@ -551,6 +556,96 @@ func testImportDeduplication(t *testing.T, protocol int) {
 	}
 }
 
+// Tests that blocks propagated before their parents are held until the parents
+// get imported, instead of being dropped.
+func TestOrphanImport(t *testing.T) {
+	hashes, blocks := makeChain(2, 0, genesis)
+	sideHashes, sideBlocks := makeChain(3, 1, genesis)
+
+	tester := newTester()
+	tester.insertChain(types.Blocks{blocks[hashes[1]], blocks[hashes[0]]})
+
+	imported := make(chan *types.Block, 3)
+	tester.fetcher.importedHook = func(block *types.Block) { imported <- block }
+
+	// Propagate the side chain in reverse, its blocks can only import in order
+	for i := 0; i < 3; i++ {
+		tester.fetcher.Enqueue("valid", sideBlocks[sideHashes[i]])
+		if i < 2 {
+			verifyImportEvent(t, imported, false)
+		}
+	}
+	for i := 2; i >= 0; i-- {
+		select {
+		case block := <-imported:
+			if block.Hash() != sideHashes[i] {
+				t.Fatalf("imported block #%d [%x], want #%d [%x]", block.NumberU64(), block.Hash(), 3-i, sideHashes[i])
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("block #%d: import timeout", 3-i)
+		}
+	}
+	verifyImportDone(t, imported)
+}
+
+// Tests that blocks held for their parents get imported when the parents are
+// imported by other means than the fetcher, such as the downloader.
+func TestOrphanExternalParentImport(t *testing.T) {
+	hashes, blocks := makeChain(3, 0, genesis)
+	sideHashes, sideBlocks := makeChain(3, 1, genesis)
+
+	tester := newTester()
+	tester.insertChain(types.Blocks{blocks[hashes[2]], blocks[hashes[1]]})
+
+	imported := make(chan *types.Block, 1)
+	tester.fetcher.importedHook = func(block *types.Block) { imported <- block }
+
+	tester.fetcher.Enqueue("valid", sideBlocks[sideHashes[0]])
+	verifyImportEvent(t, imported, false)
+
+	// Import the parents as side blocks and advance the head past them
+	tester.insertChain(types.Blocks{sideBlocks[sideHashes[2]]})
+	tester.insertChain(types.Blocks{sideBlocks[sideHashes[1]]})
+	tester.insertChain(types.Blocks{blocks[hashes[0]]})
+
+	// Any event wakes up the fetcher to notice the new head
+	tester.fetcher.Enqueue("valid", blocks[hashes[0]])
+	verifyImportEvent(t, imported, true)
+}
+
+// Tests that blocks with a timestamp slightly in the future are held until
+// then, instead of being dropped.
+func TestFutureBlockImport(t *testing.T) {
+	_, blocks := makeChain(1, 0, genesis)
+	var block *types.Block
+	for _, b := range blocks {
+		if b != genesis {
+			header := types.CopyHeader(b.Header())
+			header.Time = big.NewInt(time.Now().Unix() + 2)
+			block = types.NewBlockWithHeader(header).WithBody(b.Transactions(), b.Uncles())
+		}
+	}
+	tester := newTester()
+
+	imported := make(chan *types.Block, 1)
+	tester.fetcher.importedHook = func(block *types.Block) { imported <- block }
+
+	tester.fetcher.Enqueue("valid", block)
+	select {
+	case <-imported:
+		t.Fatal("future block imported early")
+	case <-time.After(500 * time.Millisecond):
+	}
+	select {
+	case b := <-imported:
+		if b.Hash() != block.Hash() {
+			t.Fatalf("imported block [%x], want [%x]", b.Hash(), block.Hash())
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("future block not imported")
+	}
+}
+
 // Tests that blocks with numbers much lower or higher than out current head get
 // discarded to prevent wasting resources on useless blocks from faulty peers.
 func TestDistantPropagationDiscarding(t *testing.T) {
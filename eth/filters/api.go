@@ -34,9 +34,17 @@ import (
 	"github.com/ellaism/go-ellaism/rpc"
 )
 
-var (
-	filterTickerTime = 5 * time.Minute
-)
+// Config holds the lifetime and query limits of the filter API.
+type Config struct {
+	Timeout    time.Duration // Time after which filters which aren't polled are removed
+	MaxBlocks  uint64        // Maximum number of blocks searched by a log query, zero if unlimited
+	MaxResults int           // Maximum number of logs returned by a log query, zero if unlimited
+}
+
+// DefaultConfig contains the default filter API settings.
+var DefaultConfig = Config{
+	Timeout: 5 * time.Minute,
+}
 
 // byte will be inferred
 const (
@@ -49,7 +57,8 @@ const (
 // PublicFilterAPI offers support to create and manage filters. This will allow external clients to retrieve various
 // information related to the Ethereum protocol such als blocks, transactions and logs.
 type PublicFilterAPI struct {
-	mux *event.TypeMux
+	mux    *event.TypeMux
+	config Config
 
 	quit    chan struct{}
 	chainDb ethdb.Database
@@ -69,10 +78,16 @@ type PublicFilterAPI struct {
 	transactionQueue map[int]*hashQueue
 }
 
-// NewPublicFilterAPI returns a new PublicFilterAPI instance.
-func NewPublicFilterAPI(chainDb ethdb.Database, mux *event.TypeMux) *PublicFilterAPI {
+// NewPublicFilterAPI returns a new PublicFilterAPI instance with the given
+// lifetime and query limits.
+func NewPublicFilterAPI(chainDb ethdb.Database, mux *event.TypeMux, config Config) *PublicFilterAPI {
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultConfig.Timeout
+	}
 	svc := &PublicFilterAPI{
 		mux:              mux,
+		config:           config,
+		quit:             make(chan struct{}),
 		chainDb:          chainDb,
 		filterManager:    NewFilterSystem(mux),
 		filterMapping:    make(map[string]int),
@@ -89,47 +104,75 @@ func (s *PublicFilterAPI) Stop() {
 	close(s.quit)
 }
 
-// start the work loop, wait and process events.
+// start the work loop, periodically removing the filters which weren't polled
+// within the timeout.
 func (s *PublicFilterAPI) start() {
-	timer := time.NewTicker(2 * time.Second)
+	interval := 2 * time.Second
+	if s.config.Timeout < 2*interval {
+		interval = s.config.Timeout / 2
+	}
+	timer := time.NewTicker(interval)
 	defer timer.Stop()
-done:
+
 	for {
 		select {
 		case <-timer.C:
-			s.filterManager.Lock() // lock order like filterLoop()
-			s.logMu.Lock()
-			for id, filter := range s.logQueue {
-				if time.Since(filter.timeout) > filterTickerTime {
-					s.filterManager.Remove(id)
-					delete(s.logQueue, id)
-				}
-			}
-			s.logMu.Unlock()
+			s.removeExpired()
+		case <-s.quit:
+			return
+		}
+	}
+}
 
-			s.blockMu.Lock()
-			for id, filter := range s.blockQueue {
-				if time.Since(filter.timeout) > filterTickerTime {
-					s.filterManager.Remove(id)
-					delete(s.blockQueue, id)
-				}
-			}
-			s.blockMu.Unlock()
+// removeExpired removes the filters which weren't polled within the timeout,
+// along with their queued results and external identifiers. Log filters of
+// subscriptions don't expire, they are removed once unsubscribed.
+func (s *PublicFilterAPI) removeExpired() {
+	s.filterManager.Lock() // lock order like filterLoop()
+	defer s.filterManager.Unlock()
 
-			s.transactionMu.Lock()
-			for id, filter := range s.transactionQueue {
-				if time.Since(filter.timeout) > filterTickerTime {
-					s.filterManager.Remove(id)
-					delete(s.transactionQueue, id)
-				}
-			}
-			s.transactionMu.Unlock()
-			s.filterManager.Unlock()
-		case <-s.quit:
-			break done
+	expired := make(map[int]struct{})
+
+	s.logMu.Lock()
+	for id, filter := range s.logQueue {
+		if !filter.subscribed && filter.expired(s.config.Timeout) {
+			expired[id] = struct{}{}
+			delete(s.logQueue, id)
+		}
+	}
+	s.logMu.Unlock()
+
+	s.blockMu.Lock()
+	for id, filter := range s.blockQueue {
+		if filter.expired(s.config.Timeout) {
+			expired[id] = struct{}{}
+			delete(s.blockQueue, id)
 		}
 	}
+	s.blockMu.Unlock()
 
+	s.transactionMu.Lock()
+	for id, filter := range s.transactionQueue {
+		if filter.expired(s.config.Timeout) {
+			expired[id] = struct{}{}
+			delete(s.transactionQueue, id)
+		}
+	}
+	s.transactionMu.Unlock()
+
+	if len(expired) == 0 {
+		return
+	}
+	for id := range expired {
+		s.filterManager.Remove(id)
+	}
+	s.filterMapMu.Lock()
+	for externalId, id := range s.filterMapping {
+		if _, ok := expired[id]; ok {
+			delete(s.filterMapping, externalId)
+		}
+	}
+	s.filterMapMu.Unlock()
 }
 
 // NewBlockFilter create a new filter that returns blocks that are included into the canonical chain.
@@ -219,13 +262,14 @@ func (s *PublicFilterAPI) newLogFilter(earliest, latest int64, addresses []commo
 	}
 
 	s.logMu.Lock()
-	s.logQueue[id] = &logQueue{timeout: time.Now()}
+	s.logQueue[id] = &logQueue{timeout: time.Now(), subscribed: callback != nil}
 	s.logMu.Unlock()
 
 	filter.SetBeginBlock(earliest)
 	filter.SetEndBlock(latest)
 	filter.SetAddresses(addresses)
 	filter.SetTopics(topics)
+	filter.SetLimits(s.config.MaxBlocks, s.config.MaxResults)
 	filter.LogCallback = func(log *vm.Log, removed bool) {
 		if callback != nil {
 			callback(log, removed)
@@ -300,6 +344,22 @@ type NewFilterArgs struct {
 	Topics    [][]common.Hash
 }
 
+// filterBlockNumber returns the block number a filter bound stands for, the
+// latest block unless a block number or a confirmation tag is given.
+func filterBlockNumber(number *rpc.BlockNumber) rpc.BlockNumber {
+	if number == nil {
+		return rpc.LatestBlockNumber
+	}
+	switch *number {
+	case rpc.SafeBlockNumber, rpc.ConfirmedBlockNumber:
+		return *number
+	}
+	if number.Int64() < 0 {
+		return rpc.LatestBlockNumber
+	}
+	return *number
+}
+
 // UnmarshalJSON sets *args fields with given data.
 func (args *NewFilterArgs) UnmarshalJSON(data []byte) error {
 	type input struct {
@@ -314,17 +374,8 @@ func (args *NewFilterArgs) UnmarshalJSON(data []byte) error {
 		return err
 	}
 
-	if raw.From == nil || raw.From.Int64() < 0 {
-		args.FromBlock = rpc.LatestBlockNumber
-	} else {
-		args.FromBlock = *raw.From
-	}
-
-	if raw.ToBlock == nil || raw.ToBlock.Int64() < 0 {
-		args.ToBlock = rpc.LatestBlockNumber
-	} else {
-		args.ToBlock = *raw.ToBlock
-	}
+	args.FromBlock = filterBlockNumber(raw.From)
+	args.ToBlock = filterBlockNumber(raw.ToBlock)
 
 	args.Addresses = []common.Address{}
 
@@ -438,15 +489,21 @@ func (s *PublicFilterAPI) NewFilter(args NewFilterArgs) (string, error) {
 	return externalId, nil
 }
 
-// GetLogs returns the logs matching the given argument.
-func (s *PublicFilterAPI) GetLogs(args NewFilterArgs) []vmlog {
+// GetLogs returns the logs matching the given argument. It fails if the query
+// exceeds the block range or result limits.
+func (s *PublicFilterAPI) GetLogs(args NewFilterArgs) ([]vmlog, error) {
 	filter := New(s.chainDb)
 	filter.SetBeginBlock(args.FromBlock.Int64())
 	filter.SetEndBlock(args.ToBlock.Int64())
 	filter.SetAddresses(args.Addresses)
 	filter.SetTopics(args.Topics)
+	filter.SetLimits(s.config.MaxBlocks, s.config.MaxResults)
 
-	return toRPCLogs(filter.Find(), false)
+	logs, err := filter.Find()
+	if err != nil {
+		return nil, err
+	}
+	return toRPCLogs(logs, false), nil
 }
 
 // UninstallFilter removes the filter with the given filter id.
@@ -494,11 +551,24 @@ func (s *PublicFilterAPI) UninstallFilter(filterId string) bool {
 
 // getFilterType is a helper utility that determine the type of filter for the given filter id.
 func (s *PublicFilterAPI) getFilterType(id int) byte {
-	if _, ok := s.blockQueue[id]; ok {
+	s.blockMu.RLock()
+	_, ok := s.blockQueue[id]
+	s.blockMu.RUnlock()
+	if ok {
 		return blockFilterTy
-	} else if _, ok := s.transactionQueue[id]; ok {
+	}
+
+	s.transactionMu.RLock()
+	_, ok = s.transactionQueue[id]
+	s.transactionMu.RUnlock()
+	if ok {
 		return transactionFilterTy
-	} else if _, ok := s.logQueue[id]; ok {
+	}
+
+	s.logMu.RLock()
+	_, ok = s.logQueue[id]
+	s.logMu.RUnlock()
+	if ok {
 		return logFilterTy
 	}
 
@@ -519,8 +589,8 @@ func (s *PublicFilterAPI) blockFilterChanged(id int) []common.Hash {
 // transactionFilterChanged returns a collection of transaction hashes for the pending
 // transaction filter with the given id.
 func (s *PublicFilterAPI) transactionFilterChanged(id int) []common.Hash {
-	s.blockMu.Lock()
-	defer s.blockMu.Unlock()
+	s.transactionMu.Lock()
+	defer s.transactionMu.Unlock()
 
 	if s.transactionQueue[id] != nil {
 		return s.transactionQueue[id].get()
@@ -539,20 +609,25 @@ func (s *PublicFilterAPI) logFilterChanged(id int) []vmlog {
 	return nil
 }
 
-// GetFilterLogs returns the logs for the filter with the given id.
-func (s *PublicFilterAPI) GetFilterLogs(filterId string) []vmlog {
+// GetFilterLogs returns the logs for the filter with the given id. It fails if
+// the query exceeds the block range or result limits.
+func (s *PublicFilterAPI) GetFilterLogs(filterId string) ([]vmlog, error) {
 	s.filterMapMu.RLock()
 	id, ok := s.filterMapping[filterId]
 	s.filterMapMu.RUnlock()
 	if !ok {
-		return toRPCLogs(nil, false)
+		return toRPCLogs(nil, false), nil
 	}
 
 	if filter := s.filterManager.Get(id); filter != nil {
-		return toRPCLogs(filter.Find(), false)
+		logs, err := filter.Find()
+		if err != nil {
+			return nil, err
+		}
+		return toRPCLogs(logs, false), nil
 	}
 
-	return toRPCLogs(nil, false)
+	return toRPCLogs(nil, false), nil
 }
 
 // GetFilterChanges returns the logs for the filter with the given id since last time is was called.
@@ -586,8 +661,17 @@ type vmlog struct {
 type logQueue struct {
 	mu sync.Mutex
 
-	logs    []vmlog
-	timeout time.Time
+	logs       []vmlog
+	timeout    time.Time
+	subscribed bool // whether the logs are delivered to a subscription
+}
+
+// expired returns whether the queue wasn't polled within the timeout.
+func (l *logQueue) expired(timeout time.Duration) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return time.Since(l.timeout) > timeout
 }
 
 func (l *logQueue) add(logs ...vmlog) {
@@ -614,6 +698,14 @@ type hashQueue struct {
 	timeout time.Time
 }
 
+// expired returns whether the queue wasn't polled within the timeout.
+func (l *hashQueue) expired(timeout time.Duration) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return time.Since(l.timeout) > timeout
+}
+
 func (l *hashQueue) add(hashes ...common.Hash) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
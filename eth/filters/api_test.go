@@ -20,9 +20,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/ellaism/go-ellaism/common"
 	"github.com/ellaism/go-ellaism/eth/filters"
+	"github.com/ellaism/go-ellaism/ethdb"
+	"github.com/ellaism/go-ellaism/event"
 	"github.com/ellaism/go-ellaism/rpc"
 )
 
@@ -196,3 +199,37 @@ func TestUnmarshalJSONNewFilterArgs(t *testing.T) {
 		)
 	}
 }
+
+func TestFilterExpiry(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	api := filters.NewPublicFilterAPI(db, new(event.TypeMux), filters.Config{Timeout: 50 * time.Millisecond})
+	defer api.Stop()
+
+	blockId, err := api.NewBlockFilter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	logId, err := api.NewFilter(filters.NewFilterArgs{FromBlock: rpc.LatestBlockNumber, ToBlock: rpc.LatestBlockNumber})
+	if err != nil {
+		t.Fatal(err)
+	}
+	polledId, err := api.NewPendingTransactionFilter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// keep polling one of the filters so that it doesn't expire
+	for i := 0; i < 10; i++ {
+		time.Sleep(20 * time.Millisecond)
+		api.GetFilterChanges(polledId)
+	}
+
+	if api.UninstallFilter(blockId) {
+		t.Error("expected block filter to be expired")
+	}
+	if api.UninstallFilter(logId) {
+		t.Error("expected log filter to be expired")
+	}
+	if !api.UninstallFilter(polledId) {
+		t.Error("expected polled filter to be installed")
+	}
+}
@@ -17,6 +17,7 @@
 package filters
 
 import (
+	"fmt"
 	"math"
 	"time"
 
@@ -25,6 +26,7 @@ import (
 	"github.com/ellaism/go-ellaism/core/types"
 	"github.com/ellaism/go-ellaism/core/vm"
 	"github.com/ellaism/go-ellaism/ethdb"
+	"github.com/ellaism/go-ellaism/rpc"
 )
 
 type AccountChange struct {
@@ -40,6 +42,9 @@ type Filter struct {
 	addresses  []common.Address
 	topics     [][]common.Hash
 
+	maxBlocks  uint64 // maximum number of blocks searched by Find, zero if unlimited
+	maxResults int    // maximum number of logs returned by Find, zero if unlimited
+
 	BlockCallback       func(*types.Block, vm.Logs)
 	TransactionCallback func(*types.Transaction)
 	LogCallback         func(*vm.Log, bool)
@@ -70,31 +75,56 @@ func (self *Filter) SetTopics(topics [][]common.Hash) {
 	self.topics = topics
 }
 
+// SetLimits sets the maximum number of blocks Find searches and the maximum
+// number of logs it returns, zero meaning unlimited. Queries exceeding them
+// fail instead of returning partial results.
+func (self *Filter) SetLimits(maxBlocks uint64, maxResults int) {
+	self.maxBlocks = maxBlocks
+	self.maxResults = maxResults
+}
+
 // Run filters logs with the current parameters set
-func (self *Filter) Find() vm.Logs {
+func (self *Filter) Find() (vm.Logs, error) {
 	latestBlock := core.GetBlock(self.db, core.GetHeadBlockHash(self.db))
 	if latestBlock == nil {
-		return vm.Logs{}
+		return vm.Logs{}, nil
 	}
-	var beginBlockNo uint64 = uint64(self.begin)
-	if self.begin == -1 {
-		beginBlockNo = latestBlock.NumberU64()
+	// Negative bounds stand for the latest block, or below it for the
+	// confirmation tags
+	bound := func(number int64) uint64 {
+		if number = int64(rpc.BlockNumber(number).Resolve(latestBlock.NumberU64())); number < 0 {
+			return latestBlock.NumberU64()
+		}
+		return uint64(number)
 	}
-	var endBlockNo uint64 = uint64(self.end)
-	if self.end == -1 {
-		endBlockNo = latestBlock.NumberU64()
+	beginBlockNo, endBlockNo := bound(self.begin), bound(self.end)
+
+	if self.maxBlocks > 0 && endBlockNo >= beginBlockNo && endBlockNo-beginBlockNo >= self.maxBlocks {
+		return nil, fmt.Errorf("query exceeds limits: %d blocks, the maximum is %d", endBlockNo-beginBlockNo+1, self.maxBlocks)
 	}
 
 	// if no addresses are present we can't make use of fast search which
 	// uses the mipmap bloom filters to check for fast inclusion and uses
 	// higher range probability in order to ensure at least a false positive
+	limit := -1
+	if self.maxResults > 0 {
+		limit = self.maxResults
+	}
+	var logs vm.Logs
 	if len(self.addresses) == 0 {
-		return self.getLogs(beginBlockNo, endBlockNo)
+		logs = self.getLogs(beginBlockNo, endBlockNo, limit)
+	} else {
+		logs = self.mipFind(beginBlockNo, endBlockNo, 0, limit)
 	}
-	return self.mipFind(beginBlockNo, endBlockNo, 0)
+	if limit >= 0 && len(logs) > limit {
+		return nil, fmt.Errorf("query exceeds limits: more than %d logs", self.maxResults)
+	}
+	return logs, nil
 }
 
-func (self *Filter) mipFind(start, end uint64, depth int) (logs vm.Logs) {
+// mipFind collects the logs of the given block range using the mipmap bloom
+// filters, stopping once more than limit logs are found unless limit is -1.
+func (self *Filter) mipFind(start, end uint64, depth int, limit int) (logs vm.Logs) {
 	level := core.MIPMapLevels[depth]
 	// normalise numerator so we can work in level specific batches and
 	// work with the proper range checks
@@ -108,10 +138,17 @@ func (self *Filter) mipFind(start, end uint64, depth int) (logs vm.Logs) {
 				// normalised values.
 				start := uint64(math.Max(float64(num), float64(start)))
 				end := uint64(math.Min(float64(num+level-1), float64(end)))
+				remaining := -1
+				if limit >= 0 {
+					remaining = limit - len(logs)
+				}
 				if depth+1 == len(core.MIPMapLevels) {
-					logs = append(logs, self.getLogs(start, end)...)
+					logs = append(logs, self.getLogs(start, end, remaining)...)
 				} else {
-					logs = append(logs, self.mipFind(start, end, depth+1)...)
+					logs = append(logs, self.mipFind(start, end, depth+1, remaining)...)
+				}
+				if limit >= 0 && len(logs) > limit {
+					return logs
 				}
 				// break so we don't check the same range for each
 				// possible address. Checks on multiple addresses
@@ -124,7 +161,9 @@ func (self *Filter) mipFind(start, end uint64, depth int) (logs vm.Logs) {
 	return logs
 }
 
-func (self *Filter) getLogs(start, end uint64) (logs vm.Logs) {
+// getLogs collects the logs of the given block range, stopping once more than
+// limit logs are found unless limit is -1.
+func (self *Filter) getLogs(start, end uint64, limit int) (logs vm.Logs) {
 	for i := start; i <= end; i++ {
 		var block *types.Block
 		hash := core.GetCanonicalHash(self.db, i)
@@ -147,6 +186,9 @@ func (self *Filter) getLogs(start, end uint64) (logs vm.Logs) {
 				unfiltered = append(unfiltered, receipt.Logs...)
 			}
 			logs = append(logs, self.FilterLogs(unfiltered)...)
+			if limit >= 0 && len(logs) > limit {
+				return logs
+			}
 		}
 	}
 
@@ -183,7 +225,7 @@ Logs:
 		}
 
 		for i, topics := range self.topics {
-			var match bool
+			match := len(topics) == 0 // empty rule set == wildcard
 			for _, topic := range topics {
 				// common.Hash{} is a match all (wildcard)
 				if (topic == common.Hash{}) || log.Topics[i] == topic {
@@ -220,7 +262,7 @@ func (self *Filter) bloomFilter(block *types.Block) bool {
 	}
 
 	for _, sub := range self.topics {
-		var included bool
+		included := len(sub) == 0 // empty rule set == wildcard
 		for _, topic := range sub {
 			if (topic == common.Hash{}) || types.BloomLookup(block.Bloom(), topic[:]) {
 				included = true
@@ -54,7 +54,7 @@ type FilterSystem struct {
 	// generic is an ugly hack for Get
 	generic map[int]*Filter
 
-	sub event.Subscription
+	sub *event.TypeMuxSubscription
 }
 
 // NewFilterSystem returns a newly allocated filter manager
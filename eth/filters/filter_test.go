@@ -112,7 +112,7 @@ func BenchmarkMipmaps(b *testing.B) {
 	filter.SetEndBlock(-1)
 
 	for i := 0; i < b.N; i++ {
-		logs := filter.Find()
+		logs, _ := filter.Find()
 		if len(logs) != 4 {
 			b.Fatal("expected 4 log, got", len(logs))
 		}
@@ -213,7 +213,7 @@ func TestFilters(t *testing.T) {
 	filter.SetBeginBlock(0)
 	filter.SetEndBlock(-1)
 
-	logs := filter.Find()
+	logs, _ := filter.Find()
 	if len(logs) != 4 {
 		t.Error("expected 4 log, got", len(logs))
 	}
@@ -223,7 +223,7 @@ func TestFilters(t *testing.T) {
 	filter.SetTopics([][]common.Hash{{hash3}})
 	filter.SetBeginBlock(900)
 	filter.SetEndBlock(999)
-	logs = filter.Find()
+	logs, _ = filter.Find()
 	if len(logs) != 1 {
 		t.Error("expected 1 log, got", len(logs))
 	}
@@ -236,7 +236,7 @@ func TestFilters(t *testing.T) {
 	filter.SetTopics([][]common.Hash{{hash3}})
 	filter.SetBeginBlock(990)
 	filter.SetEndBlock(-1)
-	logs = filter.Find()
+	logs, _ = filter.Find()
 	if len(logs) != 1 {
 		t.Error("expected 1 log, got", len(logs))
 	}
@@ -249,7 +249,7 @@ func TestFilters(t *testing.T) {
 	filter.SetBeginBlock(1)
 	filter.SetEndBlock(10)
 
-	logs = filter.Find()
+	logs, _ = filter.Find()
 	if len(logs) != 2 {
 		t.Error("expected 2 log, got", len(logs))
 	}
@@ -260,7 +260,7 @@ func TestFilters(t *testing.T) {
 	filter.SetBeginBlock(0)
 	filter.SetEndBlock(-1)
 
-	logs = filter.Find()
+	logs, _ = filter.Find()
 	if len(logs) != 0 {
 		t.Error("expected 0 log, got", len(logs))
 	}
@@ -271,7 +271,7 @@ func TestFilters(t *testing.T) {
 	filter.SetBeginBlock(0)
 	filter.SetEndBlock(-1)
 
-	logs = filter.Find()
+	logs, _ = filter.Find()
 	if len(logs) != 0 {
 		t.Error("expected 0 log, got", len(logs))
 	}
@@ -281,8 +281,46 @@ func TestFilters(t *testing.T) {
 	filter.SetBeginBlock(0)
 	filter.SetEndBlock(-1)
 
-	logs = filter.Find()
+	logs, _ = filter.Find()
 	if len(logs) != 0 {
 		t.Error("expected 0 log, got", len(logs))
 	}
+
+	// block range limit
+	filter = New(db)
+	filter.SetAddresses([]common.Address{addr})
+	filter.SetBeginBlock(0)
+	filter.SetEndBlock(-1)
+	filter.SetLimits(100, 0)
+
+	if _, err := filter.Find(); err == nil {
+		t.Error("expected block range limit error")
+	}
+
+	filter.SetBeginBlock(901)
+	if logs, err := filter.Find(); err != nil {
+		t.Error("unexpected error:", err)
+	} else if len(logs) != 2 {
+		t.Error("expected 2 log, got", len(logs))
+	}
+
+	// result limit, with and without the mipmap bloom filters
+	for _, addresses := range [][]common.Address{{addr}, nil} {
+		filter = New(db)
+		filter.SetAddresses(addresses)
+		filter.SetBeginBlock(0)
+		filter.SetEndBlock(-1)
+		filter.SetLimits(0, 3)
+
+		if _, err := filter.Find(); err == nil {
+			t.Error("expected result limit error")
+		}
+
+		filter.SetLimits(0, 4)
+		if logs, err := filter.Find(); err != nil {
+			t.Error("unexpected error:", err)
+		} else if len(logs) != 4 {
+			t.Error("expected 4 log, got", len(logs))
+		}
+	}
 }
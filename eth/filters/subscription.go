@@ -0,0 +1,77 @@
+// Copyright 2016 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package filters
+
+import (
+	"sync"
+
+	"github.com/ellaism/go-ellaism/core/vm"
+	"github.com/ellaism/go-ellaism/event"
+)
+
+// LogSubscription streams the logs of newly imported blocks that match a filter
+// to a channel, until it is unsubscribed.
+type LogSubscription struct {
+	sub  *event.TypeMuxSubscription
+	err  chan error
+	quit chan struct{}
+	once sync.Once
+}
+
+// SubscribeLogs delivers the logs posted on the mux that match the addresses
+// and topics of the filter to ch. The block range of the filter is ignored.
+func SubscribeLogs(mux *event.TypeMux, filter *Filter, ch chan<- *vm.Log) *LogSubscription {
+	s := &LogSubscription{
+		sub:  mux.Subscribe(vm.Logs(nil)),
+		err:  make(chan error),
+		quit: make(chan struct{}),
+	}
+	go s.loop(filter, ch)
+	return s
+}
+
+// loop forwards the matching logs until the mux subscription ends.
+func (s *LogSubscription) loop(filter *Filter, ch chan<- *vm.Log) {
+	for ev := range s.sub.Chan() {
+		logs, ok := ev.Data.(vm.Logs)
+		if !ok {
+			continue
+		}
+		for _, log := range filter.FilterLogs(logs) {
+			select {
+			case ch <- log:
+			case <-s.quit:
+				return
+			}
+		}
+	}
+}
+
+// Err returns a channel that is closed when the subscription is unsubscribed.
+// Local subscriptions don't fail, so no error is ever sent.
+func (s *LogSubscription) Err() <-chan error {
+	return s.err
+}
+
+// Unsubscribe stops the delivery of logs. It can be called more than once.
+func (s *LogSubscription) Unsubscribe() {
+	s.once.Do(func() {
+		close(s.quit)
+		s.sub.Unsubscribe()
+		close(s.err)
+	})
+}
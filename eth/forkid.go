@@ -0,0 +1,139 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"math"
+	"sort"
+
+	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/core"
+)
+
+var (
+	// errRemoteStale is returned by the fork filter if a remote peer runs the
+	// rules of one of our past forks, but doesn't know of the next one.
+	errRemoteStale = errors.New("remote needs update")
+
+	// errLocalIncompatibleOrStale is returned by the fork filter if a remote
+	// peer is on a chain we don't know of, or passed a fork we haven't.
+	errLocalIncompatibleOrStale = errors.New("local incompatible or needs update")
+)
+
+// forkID is the fork identifier of EIP-2124, exchanged in the status message of
+// eth/64 and later so that peers on other chains are told apart before any
+// block is exchanged: the CRC32 checksum of the genesis hash and of the blocks
+// of the forks passed, and the block of the next fork, 0 if none is known.
+type forkID struct {
+	Hash [4]byte
+	Next uint64
+}
+
+// forkBlocks returns the distinct blocks of the forks of a chain configuration
+// in ascending order, leaving out the forks active from the genesis.
+func forkBlocks(config *core.ChainConfig) []uint64 {
+	var blocks []uint64
+	for _, fork := range config.Forks {
+		if fork.Block == nil || fork.Block.Sign() == 0 {
+			continue
+		}
+		blocks = append(blocks, fork.Block.Uint64())
+	}
+	sort.Slice(blocks, func(i, j int) bool { return blocks[i] < blocks[j] })
+
+	unique := blocks[:0]
+	for i, block := range blocks {
+		if i == 0 || block != blocks[i-1] {
+			unique = append(unique, block)
+		}
+	}
+	return unique
+}
+
+// forkChecksums returns the checksums of the genesis hash followed by every
+// prefix of the fork blocks.
+func forkChecksums(genesis common.Hash, forks []uint64) [][4]byte {
+	sums := make([][4]byte, len(forks)+1)
+
+	hash := crc32.ChecksumIEEE(genesis[:])
+	binary.BigEndian.PutUint32(sums[0][:], hash)
+	for i, fork := range forks {
+		var blob [8]byte
+		binary.BigEndian.PutUint64(blob[:], fork)
+		hash = crc32.Update(hash, crc32.IEEETable, blob[:])
+		binary.BigEndian.PutUint32(sums[i+1][:], hash)
+	}
+	return sums
+}
+
+// newForkID returns the fork identifier of a chain at the given head.
+func newForkID(config *core.ChainConfig, genesis common.Hash, head uint64) forkID {
+	forks := forkBlocks(config)
+	sums := forkChecksums(genesis, forks)
+	for i, fork := range forks {
+		if head < fork {
+			return forkID{Hash: sums[i], Next: fork}
+		}
+	}
+	return forkID{Hash: sums[len(forks)]}
+}
+
+// newForkFilter returns a function validating the fork identifiers of remote
+// peers against a chain, whose head is retrieved at every check. A peer is
+// accepted if it's on the same fork and doesn't announce a next fork already
+// passed locally, if it's on a past fork and announces the next one, or if it's
+// on a future fork, being synced further than the local node.
+func newForkFilter(config *core.ChainConfig, genesis common.Hash, headfn func() uint64) func(forkID) error {
+	forks := forkBlocks(config)
+	sums := forkChecksums(genesis, forks)
+	forks = append(forks, math.MaxUint64) // The last fork is never passed
+
+	return func(id forkID) error {
+		head := headfn()
+		for i, fork := range forks {
+			if head >= fork {
+				continue
+			}
+			// First fork not passed: the remote peer is on the same fork, a past or
+			// a future one, or on another chain
+			if sums[i] == id.Hash {
+				if id.Next > 0 && head >= id.Next {
+					return errLocalIncompatibleOrStale
+				}
+				return nil
+			}
+			for j := 0; j < i; j++ {
+				if sums[j] == id.Hash {
+					if forks[j] != id.Next {
+						return errRemoteStale
+					}
+					return nil
+				}
+			}
+			for j := i + 1; j < len(sums); j++ {
+				if sums[j] == id.Hash {
+					return nil
+				}
+			}
+			return errLocalIncompatibleOrStale
+		}
+		return nil
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/core"
+)
+
+// forkTestConfig returns a chain configuration with forks at the given blocks.
+func forkTestConfig(blocks ...int64) *core.ChainConfig {
+	config := new(core.ChainConfig)
+	for _, block := range blocks {
+		config.Forks = append(config.Forks, &core.Fork{Block: big.NewInt(block)})
+	}
+	return config
+}
+
+// Tests that fork IDs are computed as specified by EIP-2124, checked against
+// the Homestead transition of its mainnet test vectors.
+func TestForkID(t *testing.T) {
+	genesis := common.HexToHash("0xd4e56740f876aef8c010b86a40d5f56745a118d0906a34e69aec8c0db1cb8fa3")
+	config := forkTestConfig(0, 1150000, 1150000)
+
+	tests := []struct {
+		head uint64
+		want forkID
+	}{
+		{0, forkID{Hash: [4]byte{0xfc, 0x64, 0xec, 0x04}, Next: 1150000}},
+		{1149999, forkID{Hash: [4]byte{0xfc, 0x64, 0xec, 0x04}, Next: 1150000}},
+		{1150000, forkID{Hash: [4]byte{0x97, 0xc2, 0xc3, 0x4c}}},
+	}
+	for _, tt := range tests {
+		if id := newForkID(config, genesis, tt.head); id != tt.want {
+			t.Errorf("head %d: fork ID mismatch: have %x, want %x", tt.head, id, tt.want)
+		}
+	}
+}
+
+// Tests that remote fork IDs are accepted if they are on the same chain as the
+// local one, and rejected otherwise.
+func TestForkFilter(t *testing.T) {
+	genesis := common.Hash{1}
+	config := forkTestConfig(10, 20)
+	sums := forkChecksums(genesis, forkBlocks(config))
+
+	filter := newForkFilter(config, genesis, func() uint64 { return 15 })
+	tests := []struct {
+		id   forkID
+		want error
+	}{
+		// Same fork, next fork unknown or not passed yet
+		{forkID{Hash: sums[1]}, nil},
+		{forkID{Hash: sums[1], Next: 20}, nil},
+		{forkID{Hash: sums[1], Next: 30}, nil},
+		// Same fork, next fork already passed locally
+		{forkID{Hash: sums[1], Next: 12}, errLocalIncompatibleOrStale},
+		// Past fork, aware of the next one or not
+		{forkID{Hash: sums[0], Next: 10}, nil},
+		{forkID{Hash: sums[0]}, errRemoteStale},
+		// Future fork
+		{forkID{Hash: sums[2]}, nil},
+		// Another chain
+		{forkID{Hash: [4]byte{1, 2, 3, 4}}, errLocalIncompatibleOrStale},
+	}
+	for _, tt := range tests {
+		if err := filter(tt.id); err != tt.want {
+			t.Errorf("fork ID %x: error mismatch: have %v, want %v", tt.id, err, tt.want)
+		}
+	}
+}
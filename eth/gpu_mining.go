@@ -27,6 +27,7 @@ import (
 
 	"github.com/ethereumproject/ethash"
 	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/core"
 	"github.com/ellaism/go-ellaism/core/types"
 	"github.com/ellaism/go-ellaism/logger"
 	"github.com/ellaism/go-ellaism/logger/glog"
@@ -34,12 +35,19 @@ import (
 )
 
 func (s *Ethereum) StartMining(threads int, gpus string) error {
+	if s.headerOnly {
+		return errMiningHeaderOnly
+	}
 	eb, err := s.Etherbase()
 	if err != nil {
 		err = fmt.Errorf("Cannot start mining without etherbase address: %v", err)
 		glog.V(logger.Error).Infoln(err)
 		return err
 	}
+	if err := s.authorizeSigner(eb); err != nil {
+		glog.V(logger.Error).Infoln(err)
+		return err
+	}
 
 	// GPU mining
 	if gpus != "" {
@@ -56,7 +64,7 @@ func (s *Ethereum) StartMining(threads int, gpus string) error {
 		}
 
 		// TODO: re-creating miner is a bit ugly
-		s.miner = miner.New(s, s.chainConfig, s.EventMux(), ethash.NewCL(ids))
+		s.miner = miner.New(s, s.chainConfig, s.EventMux(), core.NewEthash(s.chainConfig, ethash.NewCL(ids)))
 		go s.miner.Start(eb, len(ids))
 		return nil
 	}
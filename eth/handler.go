@@ -26,6 +26,7 @@ import (
 	"time"
 
 	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/consensus"
 	"github.com/ellaism/go-ellaism/core"
 	"github.com/ellaism/go-ellaism/core/types"
 	"github.com/ellaism/go-ellaism/eth/downloader"
@@ -36,13 +37,16 @@ import (
 	"github.com/ellaism/go-ellaism/logger/glog"
 	"github.com/ellaism/go-ellaism/p2p"
 	"github.com/ellaism/go-ellaism/p2p/discover"
-	"github.com/ellaism/go-ellaism/pow"
 	"github.com/ellaism/go-ellaism/rlp"
 )
 
 const (
-	softResponseLimit = 2 * 1024 * 1024 // Target maximum size of returned blocks, headers or node data.
-	estHeaderRlpSize  = 500             // Approximate size of an RLP encoded block header
+	softResponseLimit  = 2 * 1024 * 1024              // Target maximum size of returned blocks, headers or node data.
+	estHeaderRlpSize   = 500                          // Approximate size of an RLP encoded block header
+	maxNodeDataLookups = 2 * downloader.MaxStateFetch // Maximum number of node data hashes looked up per request, found or not
+
+	// txChanSize is the size of channel listening to NewTxsEvent.
+	txChanSize = 4096
 )
 
 // errIncompatibleConfig is returned if the requested protocols and configs are
@@ -56,8 +60,9 @@ func errResp(code errCode, format string, v ...interface{}) error {
 type ProtocolManager struct {
 	networkId int
 
-	fastSync uint32 // Flag whether fast sync is enabled (gets disabled if we already have blocks)
-	synced   uint32 // Flag whether we're considered synchronised (enables transaction processing)
+	fastSync   uint32 // Flag whether fast sync is enabled (gets disabled if we already have blocks)
+	synced     uint32 // Flag whether we're considered synchronised (enables transaction processing)
+	headerOnly bool   // Flag whether only the header chain is synced, without bodies, receipts or state
 
 	txpool      txPool
 	blockchain  *core.BlockChain
@@ -67,12 +72,16 @@ type ProtocolManager struct {
 	downloader *downloader.Downloader
 	fetcher    *fetcher.Fetcher
 	peers      *peerSet
+	txRequests *txRequests
+	forkFilter func(forkID) error // Validates the forks of the chains of eth/64 peers
+	repairs    *blockRepairs
 
 	SubProtocols []p2p.Protocol
 
 	eventMux      *event.TypeMux
+	txCh          chan core.NewTxsEvent
 	txSub         event.Subscription
-	minedBlockSub event.Subscription
+	minedBlockSub *event.TypeMuxSubscription
 
 	// channels for fetcher, syncer, txsyncLoop
 	newPeerCh   chan *peer
@@ -88,8 +97,8 @@ type ProtocolManager struct {
 }
 
 // NewProtocolManager returns a new ethereum sub protocol manager. The Ethereum sub protocol manages peers capable
-// with the ethereum network.
-func NewProtocolManager(config *core.ChainConfig, fastSync bool, networkId int, mux *event.TypeMux, txpool txPool, pow pow.PoW, blockchain *core.BlockChain, chaindb ethdb.Database) (*ProtocolManager, error) {
+// with the ethereum network. In header-only mode only the header chain is synced and validated.
+func NewProtocolManager(config *core.ChainConfig, fastSync bool, headerOnly bool, networkId int, mux *event.TypeMux, txpool txPool, engine consensus.Engine, blockchain *core.BlockChain, chaindb ethdb.Database) (*ProtocolManager, error) {
 	// Create the protocol manager with the base fields
 	manager := &ProtocolManager{
 		networkId:   networkId,
@@ -99,12 +108,19 @@ func NewProtocolManager(config *core.ChainConfig, fastSync bool, networkId int,
 		chaindb:     chaindb,
 		chainConfig: config,
 		peers:       newPeerSet(),
+		txRequests:  newTxRequests(),
+		repairs:     newBlockRepairs(),
 		newPeerCh:   make(chan *peer),
 		noMorePeers: make(chan struct{}),
 		txsyncCh:    make(chan *txsync),
 		quitSync:    make(chan struct{}),
+		headerOnly:  headerOnly,
 	}
 	// Figure out whether to allow fast sync or not
+	if fastSync && headerOnly {
+		glog.V(logger.Warn).Infoln("Header-only mode, fast sync disabled")
+		fastSync = false
+	}
 	if fastSync && blockchain.CurrentBlock().NumberU64() > 0 {
 		glog.V(logger.Warn).Infoln("Blockchain not empty, fast sync disabled")
 		glog.D(logger.Warn).Warnln("Blockchain not empty. Fast sync disabled.")
@@ -114,6 +130,9 @@ func NewProtocolManager(config *core.ChainConfig, fastSync bool, networkId int,
 		manager.fastSync = uint32(1)
 		glog.D(logger.Warn).Infoln("Fast sync mode enabled.")
 	}
+	manager.forkFilter = newForkFilter(config, blockchain.Genesis().Hash(), func() uint64 {
+		return manager.currentHead().Number.Uint64()
+	})
 	// Initiate a sub-protocol for every implemented version we can handle
 	manager.SubProtocols = make([]p2p.Protocol, 0, len(ProtocolVersions))
 	for i, version := range ProtocolVersions {
@@ -159,16 +178,30 @@ func NewProtocolManager(config *core.ChainConfig, fastSync bool, networkId int,
 		manager.removePeer)
 
 	validator := func(block *types.Block, parent *types.Block) error {
-		return core.ValidateHeader(config, pow, block.Header(), parent.Header(), true, false)
+		return core.ValidateHeader(config, engine, blockchain, block.Header(), parent.Header(), true, false)
 	}
 	heighter := func() uint64 {
-		return blockchain.CurrentBlock().NumberU64()
+		return manager.currentHead().Number.Uint64()
 	}
 	inserter := func(blocks types.Blocks) (int, error) {
 		atomic.StoreUint32(&manager.synced, 1) // Mark initial sync done on any fetcher import
+		if headerOnly {
+			return manager.insertHeaders(blocks)
+		}
 		return manager.insertChain(blocks)
 	}
-	manager.fetcher = fetcher.New(blockchain.GetBlock, validator, manager.BroadcastBlock, heighter, inserter, manager.removePeer)
+	getBlock, broadcaster := blockchain.GetBlock, manager.BroadcastBlock
+	if headerOnly {
+		// Only the headers of blocks are known, and their bodies can't be served
+		getBlock = func(hash common.Hash) *types.Block {
+			if header := blockchain.GetHeader(hash); header != nil {
+				return types.NewBlockWithHeader(header)
+			}
+			return nil
+		}
+		broadcaster = func(*types.Block, bool) {}
+	}
+	manager.fetcher = fetcher.New(getBlock, validator, broadcaster, heighter, inserter, manager.removePeer)
 
 	if blockchain.Genesis().Hash().Hex() == defaultGenesisHash && networkId == 1 {
 		manager.badBlockReportingEnabled = false
@@ -185,6 +218,25 @@ func (pm *ProtocolManager) insertChain(blocks types.Blocks) (i int, err error) {
 	return i, err
 }
 
+// insertHeaders imports the headers of the given blocks in header-only mode,
+// verifying the proof-of-work of each.
+func (pm *ProtocolManager) insertHeaders(blocks types.Blocks) (int, error) {
+	headers := make([]*types.Header, len(blocks))
+	for i, block := range blocks {
+		headers[i] = block.Header()
+	}
+	return pm.blockchain.InsertHeaderChain(headers, 1)
+}
+
+// currentHead returns the head of the chain the node follows: the head block,
+// or the head header in header-only mode.
+func (pm *ProtocolManager) currentHead() *types.Header {
+	if pm.headerOnly {
+		return pm.blockchain.CurrentHeader()
+	}
+	return pm.blockchain.CurrentBlock().Header()
+}
+
 func (pm *ProtocolManager) removePeer(id string) {
 	// Short circuit if the peer was already removed
 	peer := pm.peers.Peer(id)
@@ -206,7 +258,8 @@ func (pm *ProtocolManager) removePeer(id string) {
 
 func (pm *ProtocolManager) Start() {
 	// broadcast transactions
-	pm.txSub = pm.eventMux.Subscribe(core.TxPreEvent{})
+	pm.txCh = make(chan core.NewTxsEvent, txChanSize)
+	pm.txSub = pm.txpool.SubscribeNewTxsEvent(pm.txCh)
 	go pm.txBroadcastLoop()
 	// broadcast mined blocks
 	pm.minedBlockSub = pm.eventMux.Subscribe(core.NewMinedBlockEvent{})
@@ -215,6 +268,11 @@ func (pm *ProtocolManager) Start() {
 	// start sync handlers
 	go pm.syncer()
 	go pm.txsyncLoop()
+
+	// refetch the block data found missing from the database
+	if !pm.headerOnly {
+		go pm.repairLoop()
+	}
 }
 
 func (pm *ProtocolManager) Stop() {
@@ -253,7 +311,8 @@ func (pm *ProtocolManager) handle(p *peer) error {
 
 	// Execute the Ethereum handshake
 	td, head, genesis := pm.blockchain.Status()
-	if err := p.Handshake(pm.networkId, td, head, genesis); err != nil {
+	forkID := newForkID(pm.chainConfig, genesis, pm.currentHead().Number.Uint64())
+	if err := p.Handshake(pm.networkId, td, head, genesis, forkID, pm.forkFilter); err != nil {
 		glog.V(logger.Debug).Infof("%v: handshake failed: %v", p, err)
 		return err
 	}
@@ -482,6 +541,7 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 		// Filter out any explicitly requested bodies, deliver the rest to the downloader
 		filter := len(trasactions) > 0 || len(uncles) > 0
 		if filter {
+			trasactions, uncles = pm.repairBodies(trasactions, uncles)
 			trasactions, uncles = pm.fetcher.FilterBodies(trasactions, uncles, time.Now())
 		}
 		if len(trasactions) > 0 || len(uncles) > 0 || !filter {
@@ -503,7 +563,7 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 			bytes int
 			data  [][]byte
 		)
-		for bytes < softResponseLimit && len(data) < downloader.MaxStateFetch {
+		for lookups := 0; bytes < softResponseLimit && len(data) < downloader.MaxStateFetch && lookups < maxNodeDataLookups; lookups++ {
 			// Retrieve the hash of the next state entry
 			if err := msgStream.Decode(&hash); err == rlp.EOL {
 				break
@@ -549,7 +609,7 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 				return errResp(ErrDecode, "msg %v: %v", msg, err)
 			}
 			// Retrieve the requested block's receipts, skipping if unknown to us
-			results := core.GetBlockReceipts(pm.chaindb, hash)
+			results := pm.blockchain.GetReceiptsByHash(hash)
 			if results == nil {
 				if header := pm.blockchain.GetHeader(hash); header == nil || header.ReceiptHash != types.EmptyRootHash {
 					continue
@@ -571,9 +631,15 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 		if err := msg.Decode(&receipts); err != nil {
 			return errResp(ErrDecode, "msg %v: %v", msg, err)
 		}
-		// Deliver all to the downloader
-		if err := pm.downloader.DeliverReceipts(p.id, receipts); err != nil {
-			glog.V(logger.Core).Warnf("failed to deliver receipts: %v", err)
+		// Filter out the receipts requested for repair, deliver the rest to the downloader
+		filter := len(receipts) > 0
+		if filter {
+			receipts = pm.repairReceipts(receipts)
+		}
+		if len(receipts) > 0 || !filter {
+			if err := pm.downloader.DeliverReceipts(p.id, receipts); err != nil {
+				glog.V(logger.Core).Warnf("failed to deliver receipts: %v", err)
+			}
 		}
 
 	case msg.Code == NewBlockHashesMsg:
@@ -650,8 +716,7 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 			// Schedule a sync if above ours. Note, this will not fire a sync for a gap of
 			// a singe block (as the true TD is below the propagated block), however this
 			// scenario should easily be covered by the fetcher.
-			currentBlock := pm.blockchain.CurrentBlock()
-			if localTd := pm.blockchain.GetTd(currentBlock.Hash()); trueTD.Cmp(localTd) > 0 {
+			if localTd := pm.blockchain.GetTd(pm.currentHead().Hash()); trueTD.Cmp(localTd) > 0 {
 				glog.V(logger.Info).Infof("Peer %s: localTD=%v (<) peerTrueTD=%v, synchronising", p.id, localTd, trueTD)
 				go pm.synchronise(p)
 			} else {
@@ -663,7 +728,7 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 
 	case msg.Code == TxMsg:
 		// Transactions arrived, make sure we have a valid and fresh chain to handle them
-		if atomic.LoadUint32(&pm.synced) == 0 {
+		if atomic.LoadUint32(&pm.synced) == 0 || pm.headerOnly {
 			break
 		}
 		// Transactions can be processed, parse all of them and deliver to the pool
@@ -680,6 +745,86 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 		}
 		pm.txpool.AddTransactions(txs)
 
+	case p.version >= eth65 && msg.Code == NewPooledTransactionHashesMsg:
+		// Transactions were announced, make sure we have a valid and fresh chain to handle them
+		if atomic.LoadUint32(&pm.synced) == 0 || pm.headerOnly {
+			break
+		}
+		var hashes []common.Hash
+		if err := msg.Decode(&hashes); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		if len(hashes) > maxTxAnnounce {
+			return errResp(ErrMsgTooLarge, "%d transactions announced (> %d)", len(hashes), maxTxAnnounce)
+		}
+		// Mark the announced transactions and request the ones we don't have yet
+		unknown := make([]common.Hash, 0, len(hashes))
+		for _, hash := range hashes {
+			p.MarkTransaction(hash)
+			if pm.txpool.GetTransaction(hash) == nil {
+				unknown = append(unknown, hash)
+			}
+		}
+		for fetch := pm.txRequests.schedule(p.id, unknown); len(fetch) > 0; {
+			n := len(fetch)
+			if n > maxTxFetch {
+				n = maxTxFetch
+			}
+			if err := p.RequestTxs(fetch[:n]); err != nil {
+				return err
+			}
+			fetch = fetch[n:]
+		}
+
+	case p.version >= eth65 && msg.Code == GetPooledTransactionsMsg:
+		// Decode the retrieval message
+		msgStream := rlp.NewStream(msg.Payload, uint64(msg.Size))
+		if _, err := msgStream.List(); err != nil {
+			return err
+		}
+		// Gather transactions until the fetch or network limits is reached
+		var (
+			hash  common.Hash
+			bytes common.StorageSize
+			txs   types.Transactions
+		)
+		for bytes < softResponseLimit && len(txs) < maxTxFetch {
+			// Retrieve the hash of the next transaction
+			if err := msgStream.Decode(&hash); err == rlp.EOL {
+				break
+			} else if err != nil {
+				return errResp(ErrDecode, "msg %v: %v", msg, err)
+			}
+			// Retrieve the requested transaction, skipping those no longer pooled
+			if tx := pm.txpool.GetTransaction(hash); tx != nil {
+				txs = append(txs, tx)
+				bytes += tx.Size()
+			}
+		}
+		return p.SendPooledTransactions(txs)
+
+	case p.version >= eth65 && msg.Code == PooledTransactionsMsg:
+		// Requested transactions arrived, deliver them to the pool if we can handle them
+		var txs []*types.Transaction
+		if err := msg.Decode(&txs); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		for i, tx := range txs {
+			if tx == nil {
+				return errResp(ErrDecode, "transaction %d is nil", i)
+			}
+			p.MarkTransaction(tx.Hash())
+		}
+		// Drop the transactions we didn't request from the peer
+		requested := pm.txRequests.deliver(p.id, txs)
+		if len(requested) < len(txs) {
+			glog.V(logger.Debug).Infof("%v: dropped %d unrequested transactions", p, len(txs)-len(requested))
+		}
+		if len(requested) == 0 || atomic.LoadUint32(&pm.synced) == 0 || pm.headerOnly {
+			break
+		}
+		pm.txpool.AddTransactions(requested)
+
 	default:
 		return errResp(ErrInvalidMsgCode, "%v", msg.Code)
 	}
@@ -687,7 +832,10 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 }
 
 // BroadcastBlock will either propagate a block to a subset of it's peers, or
-// will only announce it's availability (depending what's requested).
+// will only announce it's availability (depending what's requested). Peers
+// known to have the block, including the ones it was propagated to, are
+// skipped. The block is queued for each peer, so slow peers don't delay the
+// broadcast to the others.
 func (pm *ProtocolManager) BroadcastBlock(block *types.Block, propagate bool) {
 	hash := block.Hash()
 	peers := pm.peers.PeersWithoutBlock(hash)
@@ -702,32 +850,63 @@ func (pm *ProtocolManager) BroadcastBlock(block *types.Block, propagate bool) {
 			glog.V(logger.Error).Infof("propagating dangling block #%d [%x]", block.NumberU64(), hash[:4])
 			return
 		}
-		// Send the block to a subset of our peers
+		// Send the block to a subset of our peers, announcing it to the rest
 		transfer := peers[:int(math.Sqrt(float64(len(peers))))]
 		for _, peer := range transfer {
-			peer.SendNewBlock(block, td)
+			peer.AsyncSendNewBlock(block, td)
 		}
+		peers = peers[len(transfer):]
 		glog.V(logger.Detail).Infof("propagated block %x to %d peers in %v", hash[:4], len(transfer), time.Since(block.ReceivedAt))
 	}
 	// Otherwise if the block is indeed in our own chain, announce it
 	if pm.blockchain.HasBlock(hash) {
 		for _, peer := range peers {
-			peer.SendNewBlockHashes([]common.Hash{hash}, []uint64{block.NumberU64()})
+			peer.AsyncSendNewBlockHash(block)
 		}
 		glog.V(logger.Detail).Infof("announced block %x to %d peers in %v", hash[:4], len(peers), time.Since(block.ReceivedAt))
 	}
 }
 
-// BroadcastTx will propagate a transaction to all peers which are not known to
-// already have the given transaction.
-func (pm *ProtocolManager) BroadcastTx(hash common.Hash, tx *types.Transaction) {
-	// Broadcast transaction to a batch of peers not knowing about it
-	peers := pm.peers.PeersWithoutTx(hash)
-	//FIXME include this again: peers = peers[:int(math.Sqrt(float64(len(peers))))]
-	for _, peer := range peers {
-		peer.SendTransactions(types.Transactions{tx})
+// BroadcastTxs will propagate a batch of transactions to the peers which are
+// not known to already have them. Peers running eth/65 or later receive the
+// transactions themselves from a square root of them only, and announcements
+// of their hashes otherwise, leaving it to them to pull the ones they miss;
+// large transactions are only announced to them, so that they're transferred
+// once to each peer even if relayed by many. Older peers, unable to pull
+// transactions, receive all of them.
+func (pm *ProtocolManager) BroadcastTxs(txs types.Transactions) {
+	var (
+		txset = make(map[*peer]types.Transactions)
+		annos = make(map[*peer][]common.Hash)
+	)
+	for _, tx := range txs {
+		var pullers []*peer
+		for _, peer := range pm.peers.PeersWithoutTx(tx.Hash()) {
+			if peer.version >= eth65 {
+				pullers = append(pullers, peer)
+			} else {
+				txset[peer] = append(txset[peer], tx)
+			}
+		}
+		direct := int(math.Sqrt(float64(len(pullers))))
+		if tx.Size() > txAnnounceSize {
+			direct = 0
+		}
+		for i, peer := range pullers {
+			if i < direct {
+				txset[peer] = append(txset[peer], tx)
+			} else {
+				annos[peer] = append(annos[peer], tx.Hash())
+			}
+		}
+	}
+	for peer, txs := range txset {
+		peer.SendTransactions(txs)
+	}
+	for peer, hashes := range annos {
+		peer.SendNewPooledTransactionHashes(hashes)
 	}
-	glog.V(logger.Detail).Infof("broadcast tx [%s] to %d peers", tx.Hash().Hex(), len(peers))
+	glog.V(logger.Detail).Infof("broadcast %d txs to %d peers, announced to %d peers", len(txs), len(txset), len(annos))
 }
 
 // Mined broadcast loop
@@ -743,10 +922,14 @@ func (self *ProtocolManager) minedBroadcastLoop() {
 }
 
 func (self *ProtocolManager) txBroadcastLoop() {
-	// automatically stops if unsubscribe
-	for obj := range self.txSub.Chan() {
-		event := obj.Data.(core.TxPreEvent)
-		self.BroadcastTx(event.Tx.Hash(), event.Tx)
+	for {
+		select {
+		case event := <-self.txCh:
+			self.BroadcastTxs(event.Txs)
+		// Err() channel will be closed when unsubscribing.
+		case <-self.txSub.Err():
+			return
+		}
 	}
 }
 
@@ -761,10 +944,11 @@ type EthNodeInfo struct {
 
 // NodeInfo retrieves some protocol metadata about the running host node.
 func (self *ProtocolManager) NodeInfo() *EthNodeInfo {
+	head := self.currentHead().Hash()
 	return &EthNodeInfo{
 		Network:    self.networkId,
-		Difficulty: self.blockchain.GetTd(self.blockchain.CurrentBlock().Hash()),
+		Difficulty: self.blockchain.GetTd(head),
 		Genesis:    self.blockchain.Genesis().Hash(),
-		Head:       self.blockchain.CurrentBlock().Hash(),
+		Head:       head,
 	}
 }
@@ -17,9 +17,11 @@
 package eth
 
 import (
+	"fmt"
 	"math/big"
 	"math/rand"
 	"testing"
+	"time"
 
 	"github.com/ellaism/go-ellaism/common"
 	"github.com/ellaism/go-ellaism/core"
@@ -388,6 +390,25 @@ func testGetNodeData(t *testing.T, protocol int) {
 	}
 }
 
+// Tests that node data requests stop looking hashes up past the lookup limit,
+// whether they were found or not.
+func TestGetNodeDataLookupLimit(t *testing.T) {
+	pm := newTestProtocolManagerMust(t, false, 0, nil, nil)
+	peer, _ := newTestPeer("peer", 63, pm, true)
+	defer peer.close()
+
+	hashes := make([]common.Hash, maxNodeDataLookups, maxNodeDataLookups+1)
+	for i := range hashes {
+		hashes[i] = common.BigToHash(big.NewInt(int64(i + 1)))
+	}
+	hashes = append(hashes, pm.blockchain.CurrentBlock().Root())
+
+	p2p.Send(peer.app, GetNodeDataMsg, hashes)
+	if err := p2p.ExpectMsg(peer.app, NodeDataMsg, [][]byte{}); err != nil {
+		t.Errorf("node data response: %v", err)
+	}
+}
+
 // Tests that the transaction receipts can be retrieved based on hashes.
 func TestGetReceipt63(t *testing.T) { testGetReceipt(t, 63) }
 
@@ -445,3 +466,151 @@ func testGetReceipt(t *testing.T, protocol int) {
 		t.Errorf("receipts mismatch: %v", err)
 	}
 }
+
+// Tests that the bodies and receipts reported missing from the database are
+// requested from the peers and stored back once delivered.
+func TestRepairMissingBlockData(t *testing.T) {
+	generator := func(i int, block *core.BlockGen) {
+		tx, _ := types.NewTransaction(block.TxNonce(testBank.Address), common.Address{0xaa}, big.NewInt(1000), core.TxGas, nil, nil).SignECDSA(testBankKey)
+		block.AddTx(tx)
+	}
+	pm := newTestProtocolManagerMust(t, false, 3, generator, nil)
+	defer pm.Stop()
+	peer, _ := newTestPeer("peer", eth63, pm, true)
+	defer peer.close()
+
+	lostBody, lostReceipts := pm.blockchain.GetBlockByNumber(1), pm.blockchain.GetBlockByNumber(2)
+	receipts := core.GetBlockReceipts(pm.chaindb, lostReceipts.Hash())
+	core.DeleteBody(pm.chaindb, lostBody.Hash())
+	core.DeleteBlockReceipts(pm.chaindb, lostReceipts.Hash())
+
+	pm.eventMux.Post(core.MissingBlockDataEvent{Header: lostBody.Header()})
+	pm.eventMux.Post(core.MissingBlockDataEvent{Header: lostReceipts.Header(), Receipts: true})
+
+	// Answer the repair requests, in whichever order they come
+	for i := 0; i < 2; i++ {
+		msg, err := peer.app.ReadMsg()
+		if err != nil {
+			t.Fatalf("failed to read repair request: %v", err)
+		}
+		var hashes []common.Hash
+		if err := msg.Decode(&hashes); err != nil {
+			t.Fatalf("failed to decode repair request: %v", err)
+		}
+		switch {
+		case msg.Code == GetBlockBodiesMsg && len(hashes) == 1 && hashes[0] == lostBody.Hash():
+			p2p.Send(peer.app, BlockBodiesMsg, []*blockBody{{lostBody.Transactions(), lostBody.Uncles()}})
+		case msg.Code == GetReceiptsMsg && len(hashes) == 1 && hashes[0] == lostReceipts.Hash():
+			p2p.Send(peer.app, ReceiptsMsg, []types.Receipts{receipts})
+		default:
+			t.Fatalf("unexpected request: code %#x, hashes %x", msg.Code, hashes)
+		}
+	}
+	for i := 0; core.GetBody(pm.chaindb, lostBody.Hash()) == nil || core.GetBlockReceipts(pm.chaindb, lostReceipts.Hash()) == nil; i++ {
+		if i == 100 {
+			t.Fatal("lost data not repaired")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// newBroadcastTestManager creates a protocol manager whose chain has the given
+// number of blocks, generated with the manager's own chain configuration.
+func newBroadcastTestManager(t *testing.T, blocks int) *ProtocolManager {
+	pm := newTestProtocolManagerMust(t, false, 0, nil, nil)
+	chain, _ := core.GenerateChain(pm.chainConfig, pm.blockchain.Genesis(), pm.chaindb, blocks, nil)
+	if _, err := pm.blockchain.InsertChain(chain); err != nil {
+		pm.Stop()
+		t.Fatal(err)
+	}
+	return pm
+}
+
+// Tests that a block is propagated in full to the square root of the peers and
+// announced to the rest, without any peer receiving it twice.
+func TestBroadcastBlock(t *testing.T) {
+	pm := newBroadcastTestManager(t, 1)
+	defer pm.Stop()
+
+	const total = 9
+	var peers []*testPeer
+	for i := 0; i < total; i++ {
+		peer, _ := newTestPeer(fmt.Sprintf("peer #%d", i), 63, pm, true)
+		defer peer.close()
+		peers = append(peers, peer)
+	}
+	for pm.peers.Len() < total {
+		time.Sleep(time.Millisecond)
+	}
+	// Collect the messages received by each peer
+	msgs := make(chan uint64, 2*total)
+	for _, peer := range peers {
+		go func(peer *testPeer) {
+			for {
+				msg, err := peer.app.ReadMsg()
+				if err != nil {
+					return
+				}
+				msg.Discard()
+				msgs <- msg.Code
+			}
+		}(peer)
+	}
+	block := pm.blockchain.CurrentBlock()
+	pm.BroadcastBlock(block, true)
+	pm.BroadcastBlock(block, false)
+
+	counts := make(map[uint64]int)
+	for i := 0; i < total; i++ {
+		select {
+		case code := <-msgs:
+			counts[code]++
+		case <-time.After(time.Second):
+			t.Fatalf("%d peers received the block, want %d", i, total)
+		}
+	}
+	select {
+	case code := <-msgs:
+		t.Fatalf("block broadcast more than once, extra message code %d", code)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if counts[NewBlockMsg] != 3 || counts[NewBlockHashesMsg] != total-3 {
+		t.Errorf("block propagated to %d and announced to %d peers, want 3 and %d", counts[NewBlockMsg], counts[NewBlockHashesMsg], total-3)
+	}
+}
+
+// Tests that a peer not reading its messages doesn't hold up the broadcast of
+// blocks to the other peers.
+func TestBroadcastBlockSlowPeer(t *testing.T) {
+	pm := newBroadcastTestManager(t, maxQueuedAnns+2)
+	defer pm.Stop()
+
+	slow, _ := newTestPeer("slow", 63, pm, true)
+	defer slow.close()
+	fast, _ := newTestPeer("fast", 63, pm, true)
+	defer fast.close()
+	for pm.peers.Len() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	// Announce more blocks than the slow peer can queue, one at a time
+	for i := uint64(1); i <= pm.blockchain.CurrentBlock().NumberU64(); i++ {
+		done := make(chan struct{})
+		go func() {
+			pm.BroadcastBlock(pm.blockchain.GetBlockByNumber(i), false)
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatalf("block #%d: broadcast blocked by a slow peer", i)
+		}
+		msg, err := fast.app.ReadMsg()
+		if err != nil {
+			t.Fatalf("block #%d: read error: %v", i, err)
+		}
+		if msg.Code != NewBlockHashesMsg {
+			t.Fatalf("block #%d: got code %d, want NewBlockHashesMsg", i, msg.Code)
+		}
+		msg.Discard()
+	}
+}
@@ -69,7 +69,7 @@ func newTestProtocolManager(fastSync bool, blocks int, generator func(int, *core
 		panic(err)
 	}
 
-	pm, err := NewProtocolManager(chainConfig, fastSync, NetworkId, evmux, &testTxPool{added: newtx}, pow, blockchain, db)
+	pm, err := NewProtocolManager(chainConfig, fastSync, false, NetworkId, evmux, &testTxPool{added: newtx}, blockchain.Engine(), blockchain, db)
 	if err != nil {
 		return nil, err
 	}
@@ -93,6 +93,7 @@ func newTestProtocolManagerMust(t *testing.T, fastSync bool, blocks int, generat
 type testTxPool struct {
 	pool  []*types.Transaction        // Collection of all transactions
 	added chan<- []*types.Transaction // Notification channel for new transactions
+	feed  event.Feed                  // Feed of new transactions, never sent on to keep broadcasts explicit
 
 	lock sync.RWMutex // Protects the transaction pool
 }
@@ -109,6 +110,12 @@ func (p *testTxPool) AddTransactions(txs []*types.Transaction) {
 	}
 }
 
+// SubscribeNewTxsEvent subscribes to the transactions added to the pool. Tests
+// broadcast transactions themselves, so no events are ever sent.
+func (p *testTxPool) SubscribeNewTxsEvent(ch chan<- core.NewTxsEvent) event.Subscription {
+	return p.feed.Subscribe(ch)
+}
+
 // GetTransactions returns all the transactions known to the pool
 func (p *testTxPool) GetTransactions() types.Transactions {
 	p.lock.RLock()
@@ -120,6 +127,20 @@ func (p *testTxPool) GetTransactions() types.Transactions {
 	return txs
 }
 
+// GetTransaction returns the transaction with the given hash, or nil if it's
+// not in the pool
+func (p *testTxPool) GetTransaction(hash common.Hash) *types.Transaction {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	for _, tx := range p.pool {
+		if tx.Hash() == hash {
+			return tx
+		}
+	}
+	return nil
+}
+
 // newTestTransaction create a new dummy transaction.
 func newTestTransaction(from *ecdsa.PrivateKey, nonce uint64, datasize int) *types.Transaction {
 	tx := types.NewTransaction(nonce, common.Address{}, big.NewInt(0), big.NewInt(100000), big.NewInt(0), make([]byte, datasize))
@@ -159,21 +180,32 @@ func newTestPeer(name string, version int, pm *ProtocolManager, shake bool) (*te
 	// Execute any implicitly requested handshakes and return
 	if shake {
 		td, head, genesis := pm.blockchain.Status()
-		tp.handshake(nil, td, head, genesis)
+		tp.handshake(nil, td, head, genesis, newForkID(pm.chainConfig, genesis, pm.blockchain.CurrentBlock().NumberU64()))
 	}
 	return tp, errc
 }
 
 // handshake simulates a trivial handshake that expects the same state from the
-// remote side as we are simulating locally.
-func (p *testPeer) handshake(t *testing.T, td *big.Int, head common.Hash, genesis common.Hash) {
-	msg := &statusData{
+// remote side as we are simulating locally, on the fork of the given fork ID
+// from eth/64 on.
+func (p *testPeer) handshake(t *testing.T, td *big.Int, head common.Hash, genesis common.Hash, fork forkID) {
+	var msg interface{} = &statusData{
 		ProtocolVersion: uint32(p.version),
 		NetworkId:       uint32(NetworkId),
 		TD:              td,
 		CurrentBlock:    head,
 		GenesisBlock:    genesis,
 	}
+	if p.version >= eth64 {
+		msg = &statusData64{
+			ProtocolVersion: uint32(p.version),
+			NetworkId:       uint32(NetworkId),
+			TD:              td,
+			CurrentBlock:    head,
+			GenesisBlock:    genesis,
+			ForkID:          fork,
+		}
+	}
 	if err := p2p.ExpectMsg(p.app, StatusMsg, msg); err != nil {
 		t.Fatalf("status recv: %v", err)
 	}
@@ -0,0 +1,143 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package names implements the resolution of human readable names, such as
+// "wallet.ella", into account addresses.
+//
+// Names are resolved through an ENS-style registry contract: the registry maps
+// the hash of a name to the resolver contract responsible for it, which in turn
+// maps it to the address the name stands for.
+package names
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/crypto"
+)
+
+var (
+	// ErrNoResolver is returned when a name is given but no resolver is
+	// configured to look it up with.
+	ErrNoResolver = errors.New("name resolution is not configured")
+
+	// ErrNotFound is returned when a name isn't registered or doesn't resolve
+	// to an address.
+	ErrNotFound = errors.New("name not found")
+)
+
+// Resolver resolves names into the addresses they stand for.
+type Resolver interface {
+	Resolve(name string) (common.Address, error)
+}
+
+// IsName reports whether s is a name to be resolved rather than an address: a
+// dot separated list of non-empty labels that isn't a hex encoded address.
+func IsName(s string) bool {
+	if common.IsHexAddress(s) || !strings.Contains(s, ".") {
+		return false
+	}
+	for _, label := range strings.Split(s, ".") {
+		if label == "" || strings.ContainsAny(label, " \t\r\n") {
+			return false
+		}
+	}
+	return true
+}
+
+// NameHash computes the node a name is registered under, by recursively hashing
+// its labels from the top level one down, as done by ENS.
+func NameHash(name string) common.Hash {
+	var node common.Hash
+	if name == "" {
+		return node
+	}
+	labels := strings.Split(name, ".")
+	for i := len(labels) - 1; i >= 0; i-- {
+		label := crypto.Keccak256([]byte(labels[i]))
+		node = crypto.Keccak256Hash(node[:], label)
+	}
+	return node
+}
+
+// Account is an account given either by its address or by a name resolving to
+// it, which the RPC API accepts in place of plain addresses.
+type Account struct {
+	Name    string         // Name the account was given by, empty if given by address
+	Address common.Address // Address of the account, set once the name is resolved
+}
+
+// UnmarshalJSON parses an account from either a hex encoded address or a name.
+func (a *Account) UnmarshalJSON(input []byte) error {
+	var s string
+	if err := json.Unmarshal(input, &s); err != nil {
+		return err
+	}
+	if IsName(s) {
+		*a = Account{Name: s}
+		return nil
+	}
+	if !common.IsHexAddress(s) {
+		return fmt.Errorf("invalid address or name: %q", s)
+	}
+	*a = Account{Address: common.HexToAddress(s)}
+	return nil
+}
+
+// MarshalJSON serializes the account as the name or address it was given by.
+func (a Account) MarshalJSON() ([]byte, error) {
+	if a.Name != "" {
+		return json.Marshal(a.Name)
+	}
+	return json.Marshal(a.Address)
+}
+
+// Resolve looks up the address of an account given by name through resolver,
+// storing and returning it. Accounts given by address are returned as is.
+func (a *Account) Resolve(resolver Resolver) (common.Address, error) {
+	if a.Name == "" {
+		return a.Address, nil
+	}
+	if resolver == nil {
+		return common.Address{}, ErrNoResolver
+	}
+	address, err := resolver.Resolve(a.Name)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("failed to resolve %q: %v", a.Name, err)
+	}
+	a.Address = address
+	return address, nil
+}
+
+// Recipient returns the address of a possibly nil, already resolved account,
+// as the recipient of a call or transaction: nil for contract creations.
+func (a *Account) Recipient() *common.Address {
+	if a == nil {
+		return nil
+	}
+	address := a.Address
+	return &address
+}
+
+func (a Account) String() string {
+	if a.Name != "" {
+		return a.Name
+	}
+	return a.Address.Hex()
+}
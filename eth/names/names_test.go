@@ -0,0 +1,119 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package names
+
+import (
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ellaism/go-ellaism/accounts/abi"
+	"github.com/ellaism/go-ellaism/accounts/abi/bind"
+	"github.com/ellaism/go-ellaism/accounts/abi/bind/backends"
+	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/core"
+	"github.com/ellaism/go-ellaism/crypto"
+)
+
+// Tests that names are hashed into the same nodes as by ENS.
+func TestNameHash(t *testing.T) {
+	tests := []struct {
+		name string
+		node common.Hash
+	}{
+		{"", common.Hash{}},
+		{"eth", common.HexToHash("0x93cdeb708b7545dc668eb9280176169d1c33cfd8ed6f04690a0bcc88a93fc4ae")},
+		{"foo.eth", common.HexToHash("0xde9b09fd7c5f901e23a3f19fecc54828e9c848539801e86591bd9801b019f84f")},
+	}
+	for _, tt := range tests {
+		if node := NameHash(tt.name); node != tt.node {
+			t.Errorf("name %q: node mismatch: have %x, want %x", tt.name, node, tt.node)
+		}
+	}
+}
+
+// Tests that accounts are parsed from both addresses and names.
+func TestAccountJSON(t *testing.T) {
+	tests := []struct {
+		input   string
+		account Account
+		fail    bool
+	}{
+		{input: `"0x00000000000000000000000000000000000000aa"`, account: Account{Address: common.HexToAddress("0xaa")}},
+		{input: `"wallet.ella"`, account: Account{Name: "wallet.ella"}},
+		{input: `"sub.wallet.ella"`, account: Account{Name: "sub.wallet.ella"}},
+		{input: `"wallet"`, fail: true},
+		{input: `"wallet..ella"`, fail: true},
+		{input: `"0xaa"`, fail: true},
+		{input: `12`, fail: true},
+	}
+	for _, tt := range tests {
+		var account Account
+		err := json.Unmarshal([]byte(tt.input), &account)
+		if tt.fail != (err != nil) {
+			t.Errorf("input %s: error mismatch: have %v, want failure %v", tt.input, err, tt.fail)
+			continue
+		}
+		if account != tt.account {
+			t.Errorf("input %s: account mismatch: have %+v, want %+v", tt.input, account, tt.account)
+		}
+	}
+	account := Account{Name: "wallet.ella"}
+	if _, err := account.Resolve(nil); err != ErrNoResolver {
+		t.Errorf("resolution without resolver: have %v, want %v", err, ErrNoResolver)
+	}
+}
+
+// echoCode assembles the deployment code of a contract answering every call
+// with its own address, acting as both the registry and the resolver.
+var echoCode = common.FromHex("600a600c600039600a6000f3" + "3060005260206000f3")
+
+// Tests that names are resolved through the registry and resolver contracts.
+func TestRegistryResolve(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	auth := bind.NewKeyedTransactor(key)
+	sim := backends.NewSimulatedBackend(core.GenesisAccount{Address: auth.From, Balance: big.NewInt(1000000000000000000)})
+
+	registry, err := NewRegistry(common.HexToAddress("0x01"), sim)
+	if err != nil {
+		t.Fatalf("failed to create registry: %v", err)
+	}
+	if _, err := registry.Resolve("wallet.ella"); err != bind.ErrNoCode {
+		t.Errorf("missing registry error mismatch: have %v, want %v", err, bind.ErrNoCode)
+	}
+	parsed, err := abi.JSON(strings.NewReader(RegistryABI))
+	if err != nil {
+		t.Fatalf("failed to parse registry ABI: %v", err)
+	}
+	address, _, _, err := bind.DeployContract(auth, parsed, echoCode, sim)
+	if err != nil {
+		t.Fatalf("failed to deploy registry: %v", err)
+	}
+	sim.Commit()
+
+	if registry, err = NewRegistry(address, sim); err != nil {
+		t.Fatalf("failed to create registry: %v", err)
+	}
+	resolved, err := registry.Resolve("wallet.ella")
+	if err != nil {
+		t.Fatalf("failed to resolve name: %v", err)
+	}
+	if resolved != address {
+		t.Errorf("resolved address mismatch: have %x, want %x", resolved, address)
+	}
+}
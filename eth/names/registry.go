@@ -0,0 +1,81 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package names
+
+import (
+	"strings"
+
+	"github.com/ellaism/go-ellaism/accounts/abi"
+	"github.com/ellaism/go-ellaism/accounts/abi/bind"
+	"github.com/ellaism/go-ellaism/common"
+)
+
+// RegistryABI is the part of the ENS registry interface used to find the
+// resolver of a name.
+const RegistryABI = `[{"constant":true,"inputs":[{"name":"node","type":"bytes32"}],"name":"resolver","outputs":[{"name":"","type":"address"}],"type":"function"}]`
+
+// ResolverABI is the part of the ENS resolver interface used to look up the
+// address of a name.
+const ResolverABI = `[{"constant":true,"inputs":[{"name":"node","type":"bytes32"}],"name":"addr","outputs":[{"name":"","type":"address"}],"type":"function"}]`
+
+// Registry resolves names through an ENS-style registry contract, executing the
+// lookups as calls against the chain.
+type Registry struct {
+	registry    *bind.BoundContract
+	resolverABI abi.ABI
+	caller      bind.ContractCaller
+}
+
+// NewRegistry creates a resolver looking names up in the registry contract at
+// the given address, calling it through caller.
+func NewRegistry(address common.Address, caller bind.ContractCaller) (*Registry, error) {
+	registryABI, err := abi.JSON(strings.NewReader(RegistryABI))
+	if err != nil {
+		return nil, err
+	}
+	resolverABI, err := abi.JSON(strings.NewReader(ResolverABI))
+	if err != nil {
+		return nil, err
+	}
+	return &Registry{
+		registry:    bind.NewBoundContract(address, registryABI, caller, nil, nil),
+		resolverABI: resolverABI,
+		caller:      caller,
+	}, nil
+}
+
+// Resolve implements Resolver, looking up the resolver of the name in the
+// registry and the address of the name in the resolver.
+func (r *Registry) Resolve(name string) (common.Address, error) {
+	node := NameHash(name)
+
+	var resolver common.Address
+	if err := r.registry.Call(nil, &resolver, "resolver", node); err != nil {
+		return common.Address{}, err
+	}
+	if resolver == (common.Address{}) {
+		return common.Address{}, ErrNotFound
+	}
+	var address common.Address
+	if err := bind.NewBoundContract(resolver, r.resolverABI, r.caller, nil, nil).Call(nil, &address, "addr", node); err != nil {
+		return common.Address{}, err
+	}
+	if address == (common.Address{}) {
+		return common.Address{}, ErrNotFound
+	}
+	return address, nil
+}
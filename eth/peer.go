@@ -20,6 +20,7 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"math/rand"
 	"sync"
 	"time"
 
@@ -41,9 +42,17 @@ var (
 const (
 	maxKnownTxs      = 32768 // Maximum transactions hashes to keep in the known list (prevent DOS)
 	maxKnownBlocks   = 1024  // Maximum block hashes to keep in the known list (prevent DOS)
+	maxQueuedProps   = 4     // Maximum number of block propagations to queue up before dropping broadcasts
+	maxQueuedAnns    = 4     // Maximum number of block announcements to queue up before dropping broadcasts
 	handshakeTimeout = 5 * time.Second
 )
 
+// propEvent is a block propagation, waiting for its turn in the broadcast queue.
+type propEvent struct {
+	block *types.Block
+	td    *big.Int
+}
+
 // PeerInfo represents a short summary of the Ethereum sub-protocol metadata known
 // about a connected peer.
 type PeerInfo struct {
@@ -67,6 +76,10 @@ type peer struct {
 
 	knownTxs    *set.Set // Set of transaction hashes known to be known by this peer
 	knownBlocks *set.Set // Set of block hashes known to be known by this peer
+
+	queuedProps chan *propEvent   // Queue of blocks to propagate to the peer
+	queuedAnns  chan *types.Block // Queue of blocks to announce to the peer
+	term        chan struct{}     // Termination channel to stop the broadcaster
 }
 
 func newPeer(version int, p *p2p.Peer, rw p2p.MsgReadWriter) *peer {
@@ -79,9 +92,41 @@ func newPeer(version int, p *p2p.Peer, rw p2p.MsgReadWriter) *peer {
 		id:          fmt.Sprintf("%x", id[:8]),
 		knownTxs:    set.New(),
 		knownBlocks: set.New(),
+		queuedProps: make(chan *propEvent, maxQueuedProps),
+		queuedAnns:  make(chan *types.Block, maxQueuedAnns),
+		term:        make(chan struct{}),
 	}
 }
 
+// broadcast is a write loop sending the queued block propagations and
+// announcements to the remote peer, so that a slow peer doesn't hold up the
+// broadcasts to the others.
+func (p *peer) broadcast() {
+	for {
+		select {
+		case prop := <-p.queuedProps:
+			if err := p.SendNewBlock(prop.block, prop.td); err != nil {
+				return
+			}
+			glog.V(logger.Detail).Infof("%v: propagated block #%d [%x]", p, prop.block.NumberU64(), prop.block.Hash().Bytes()[:4])
+
+		case block := <-p.queuedAnns:
+			if err := p.SendNewBlockHashes([]common.Hash{block.Hash()}, []uint64{block.NumberU64()}); err != nil {
+				return
+			}
+			glog.V(logger.Detail).Infof("%v: announced block #%d [%x]", p, block.NumberU64(), block.Hash().Bytes()[:4])
+
+		case <-p.term:
+			return
+		}
+	}
+}
+
+// close signals the broadcast goroutine to terminate.
+func (p *peer) close() {
+	close(p.term)
+}
+
 // Info gathers and returns a collection of metadata known about a peer.
 func (p *peer) Info() *PeerInfo {
 	hash, td := p.Head()
@@ -136,16 +181,45 @@ func (p *peer) MarkTransaction(hash common.Hash) {
 // in its transaction hash set for future reference.
 func (p *peer) SendTransactions(txs types.Transactions) error {
 	for _, tx := range txs {
-		p.knownTxs.Add(tx.Hash())
+		p.MarkTransaction(tx.Hash())
 	}
 	return p2p.Send(p.rw, TxMsg, txs)
 }
 
+// SendNewPooledTransactionHashes announces the availability of a number of
+// transactions through hash notifications, leaving it to the peer to request
+// the ones it doesn't have. Long announcements are split, as peers reject
+// those of more than maxTxAnnounce hashes.
+func (p *peer) SendNewPooledTransactionHashes(hashes []common.Hash) error {
+	for _, hash := range hashes {
+		p.MarkTransaction(hash)
+	}
+	for len(hashes) > 0 {
+		n := len(hashes)
+		if n > maxTxAnnounce {
+			n = maxTxAnnounce
+		}
+		if err := p2p.Send(p.rw, NewPooledTransactionHashesMsg, hashes[:n]); err != nil {
+			return err
+		}
+		hashes = hashes[n:]
+	}
+	return nil
+}
+
+// SendPooledTransactions sends a batch of transactions requested by the peer.
+func (p *peer) SendPooledTransactions(txs types.Transactions) error {
+	for _, tx := range txs {
+		p.MarkTransaction(tx.Hash())
+	}
+	return p2p.Send(p.rw, PooledTransactionsMsg, txs)
+}
+
 // SendNewBlockHashes announces the availability of a number of blocks through
 // a hash notification.
 func (p *peer) SendNewBlockHashes(hashes []common.Hash, numbers []uint64) error {
 	for _, hash := range hashes {
-		p.knownBlocks.Add(hash)
+		p.MarkBlock(hash)
 	}
 	request := make(newBlockHashesData, len(hashes))
 	for i := 0; i < len(hashes); i++ {
@@ -157,10 +231,32 @@ func (p *peer) SendNewBlockHashes(hashes []common.Hash, numbers []uint64) error
 
 // SendNewBlock propagates an entire block to a remote peer.
 func (p *peer) SendNewBlock(block *types.Block, td *big.Int) error {
-	p.knownBlocks.Add(block.Hash())
+	p.MarkBlock(block.Hash())
 	return p2p.Send(p.rw, NewBlockMsg, []interface{}{block, td})
 }
 
+// AsyncSendNewBlock queues an entire block for propagation to the peer. If the
+// peer's broadcast queue is full, the block is dropped.
+func (p *peer) AsyncSendNewBlock(block *types.Block, td *big.Int) {
+	select {
+	case p.queuedProps <- &propEvent{block: block, td: td}:
+		p.MarkBlock(block.Hash())
+	default:
+		glog.V(logger.Debug).Infof("%v: dropping block propagation #%d [%x]", p, block.NumberU64(), block.Hash().Bytes()[:4])
+	}
+}
+
+// AsyncSendNewBlockHash queues the announcement of a block to the peer. If the
+// peer's broadcast queue is full, the announcement is dropped.
+func (p *peer) AsyncSendNewBlockHash(block *types.Block) {
+	select {
+	case p.queuedAnns <- block:
+		p.MarkBlock(block.Hash())
+	default:
+		glog.V(logger.Debug).Infof("%v: dropping block announcement #%d [%x]", p, block.NumberU64(), block.Hash().Bytes()[:4])
+	}
+}
+
 // SendBlockHeaders sends a batch of block headers to the remote peer.
 func (p *peer) SendBlockHeaders(headers []*types.Header) error {
 	return p2p.Send(p.rw, BlockHeadersMsg, headers)
@@ -230,14 +326,32 @@ func (p *peer) RequestReceipts(hashes []common.Hash) error {
 	return p2p.Send(p.rw, GetReceiptsMsg, hashes)
 }
 
+// RequestTxs fetches a batch of announced transactions from the remote peer.
+func (p *peer) RequestTxs(hashes []common.Hash) error {
+	glog.V(logger.Debug).Infof("%v fetching %v transactions first=%s", p, len(hashes), hashes[0].Hex())
+	return p2p.Send(p.rw, GetPooledTransactionsMsg, hashes)
+}
+
 // Handshake executes the eth protocol handshake, negotiating version number,
-// network IDs, difficulties, head and genesis blocks.
-func (p *peer) Handshake(network int, td *big.Int, head common.Hash, genesis common.Hash) error {
+// network IDs, difficulties, head and genesis blocks, and from eth/64 on the
+// forks of the chains, which the remote one must pass the fork filter with.
+func (p *peer) Handshake(network int, td *big.Int, head common.Hash, genesis common.Hash, fork forkID, forkFilter func(forkID) error) error {
 	// Send out own handshake in a new thread
 	errc := make(chan error, 2)
-	var status statusData // safe to read after two values have been received from errc
+	var status statusData64 // safe to read after two values have been received from errc
 
 	go func() {
+		if p.version >= eth64 {
+			errc <- p2p.Send(p.rw, StatusMsg, &statusData64{
+				ProtocolVersion: uint32(p.version),
+				NetworkId:       uint32(network),
+				TD:              td,
+				CurrentBlock:    head,
+				GenesisBlock:    genesis,
+				ForkID:          fork,
+			})
+			return
+		}
 		errc <- p2p.Send(p.rw, StatusMsg, &statusData{
 			ProtocolVersion: uint32(p.version),
 			NetworkId:       uint32(network),
@@ -247,7 +361,7 @@ func (p *peer) Handshake(network int, td *big.Int, head common.Hash, genesis com
 		})
 	}()
 	go func() {
-		errc <- p.readStatus(network, &status, genesis)
+		errc <- p.readStatus(network, &status, genesis, forkFilter)
 	}()
 	timeout := time.NewTimer(handshakeTimeout)
 	defer timeout.Stop()
@@ -265,7 +379,7 @@ func (p *peer) Handshake(network int, td *big.Int, head common.Hash, genesis com
 	return nil
 }
 
-func (p *peer) readStatus(network int, status *statusData, genesis common.Hash) (err error) {
+func (p *peer) readStatus(network int, status *statusData64, genesis common.Hash, forkFilter func(forkID) error) (err error) {
 	msg, err := p.rw.ReadMsg()
 	if err != nil {
 		return err
@@ -276,9 +390,23 @@ func (p *peer) readStatus(network int, status *statusData, genesis common.Hash)
 	if msg.Size > ProtocolMaxMsgSize {
 		return errResp(ErrMsgTooLarge, "%v > %v", msg.Size, ProtocolMaxMsgSize)
 	}
-	// Decode the handshake and make sure everything matches
-	if err := msg.Decode(&status); err != nil {
-		return errResp(ErrDecode, "msg %v: %v", msg, err)
+	// Decode the handshake of the negotiated version and make sure everything matches
+	if p.version >= eth64 {
+		if err := msg.Decode(status); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+	} else {
+		var legacy statusData
+		if err := msg.Decode(&legacy); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		*status = statusData64{
+			ProtocolVersion: legacy.ProtocolVersion,
+			NetworkId:       legacy.NetworkId,
+			TD:              legacy.TD,
+			CurrentBlock:    legacy.CurrentBlock,
+			GenesisBlock:    legacy.GenesisBlock,
+		}
 	}
 	if status.GenesisBlock != genesis {
 		return errResp(ErrGenesisBlockMismatch, "%x (!= %x…)", status.GenesisBlock, genesis.Bytes()[:8])
@@ -289,6 +417,11 @@ func (p *peer) readStatus(network int, status *statusData, genesis common.Hash)
 	if int(status.ProtocolVersion) != p.version {
 		return errResp(ErrProtocolVersionMismatch, "%d (!= %d)", status.ProtocolVersion, p.version)
 	}
+	if p.version >= eth64 {
+		if err := forkFilter(status.ForkID); err != nil {
+			return errResp(ErrForkIDRejected, "%v", err)
+		}
+	}
 	return nil
 }
 
@@ -327,6 +460,8 @@ func (ps *peerSet) Register(p *peer) error {
 		return errAlreadyRegistered
 	}
 	ps.peers[p.id] = p
+	go p.broadcast()
+
 	return nil
 }
 
@@ -336,10 +471,13 @@ func (ps *peerSet) Unregister(id string) error {
 	ps.lock.Lock()
 	defer ps.lock.Unlock()
 
-	if _, ok := ps.peers[id]; !ok {
+	p, ok := ps.peers[id]
+	if !ok {
 		return errNotRegistered
 	}
 	delete(ps.peers, id)
+	p.close()
+
 	return nil
 }
 
@@ -406,6 +544,24 @@ func (ps *peerSet) BestPeer() *peer {
 	return bestPeer
 }
 
+// RandomPeer retrieves a random peer from the set, nil if the set is empty.
+func (ps *peerSet) RandomPeer() *peer {
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+
+	if len(ps.peers) == 0 {
+		return nil
+	}
+	i := rand.Intn(len(ps.peers))
+	for _, p := range ps.peers {
+		if i == 0 {
+			return p
+		}
+		i--
+	}
+	return nil
+}
+
 // Close disconnects all peers.
 // No new peers can be registered after Close has returned.
 func (ps *peerSet) Close() {
@@ -89,10 +89,62 @@ func testStatusMsgErrors(t *testing.T, protocol int) {
 	}
 }
 
+// Tests that eth/64 peers exchange the status with fork IDs, and are dropped if
+// on another chain or if they send the status of older versions.
+func TestStatusMsgErrors64(t *testing.T) {
+	pm := newTestProtocolManagerMust(t, false, 0, nil, nil)
+	td, currentBlock, genesis := pm.blockchain.Status()
+	defer pm.Stop()
+
+	fork := newForkID(pm.chainConfig, genesis, pm.blockchain.CurrentBlock().NumberU64())
+	tests := []struct {
+		data      interface{}
+		wantError error
+	}{
+		{
+			data:      statusData64{64, NetworkId, td, currentBlock, common.Hash{3}, fork},
+			wantError: errResp(ErrGenesisBlockMismatch, "0300000000000000000000000000000000000000000000000000000000000000 (!= %x…)", genesis.Bytes()[:8]),
+		},
+		{
+			data:      statusData64{64, NetworkId, td, currentBlock, genesis, forkID{Hash: [4]byte{1, 2, 3, 4}}},
+			wantError: errResp(ErrForkIDRejected, "%v", errLocalIncompatibleOrStale),
+		},
+	}
+	for i, test := range tests {
+		p, errc := newTestPeer("peer", 64, pm, false)
+		go p2p.Send(p.app, StatusMsg, test.data)
+
+		select {
+		case err := <-errc:
+			if err == nil {
+				t.Errorf("test %d: protocol returned nil error, want %q", i, test.wantError)
+			} else if err.Error() != test.wantError.Error() {
+				t.Errorf("test %d: wrong error: got %q, want %q", i, err, test.wantError)
+			}
+		case <-time.After(2 * time.Second):
+			t.Errorf("protocol did not shut down withing 2 seconds")
+		}
+		p.close()
+	}
+	// The status of older versions lacks the fork ID
+	p, errc := newTestPeer("peer", 64, pm, false)
+	defer p.close()
+	go p2p.Send(p.app, StatusMsg, statusData{64, NetworkId, td, currentBlock, genesis})
+	select {
+	case err := <-errc:
+		if err == nil {
+			t.Errorf("legacy status accepted")
+		}
+	case <-time.After(2 * time.Second):
+		t.Errorf("protocol did not shut down withing 2 seconds")
+	}
+}
+
 // This test checks that received transactions are added to the local pool.
 func TestRecvTransactions61(t *testing.T) { testRecvTransactions(t, 61) }
 func TestRecvTransactions62(t *testing.T) { testRecvTransactions(t, 62) }
 func TestRecvTransactions63(t *testing.T) { testRecvTransactions(t, 63) }
+func TestRecvTransactions65(t *testing.T) { testRecvTransactions(t, 65) }
 
 func testRecvTransactions(t *testing.T, protocol int) {
 	txAdded := make(chan []*types.Transaction)
@@ -122,6 +174,7 @@ func testRecvTransactions(t *testing.T, protocol int) {
 func TestSendTransactions61(t *testing.T) { testSendTransactions(t, 61) }
 func TestSendTransactions62(t *testing.T) { testSendTransactions(t, 62) }
 func TestSendTransactions63(t *testing.T) { testSendTransactions(t, 63) }
+func TestSendTransactions65(t *testing.T) { testSendTransactions(t, 65) }
 
 func testSendTransactions(t *testing.T, protocol int) {
 	pm := newTestProtocolManagerMust(t, false, 0, nil, nil)
@@ -145,18 +198,31 @@ func testSendTransactions(t *testing.T, protocol int) {
 			seen[tx.Hash()] = false
 		}
 		for n := 0; n < len(alltxs) && !t.Failed(); {
-			var txs []*types.Transaction
+			// Peers able to pull transactions only get their hashes
+			var hashes []common.Hash
 			msg, err := p.app.ReadMsg()
 			if err != nil {
 				t.Errorf("%v: read error: %v", p.Peer, err)
-			} else if msg.Code != TxMsg {
-				t.Errorf("%v: got code %d, want TxMsg", p.Peer, msg.Code)
-			}
-			if err := msg.Decode(&txs); err != nil {
-				t.Errorf("%v: %v", p.Peer, err)
+			} else if protocol >= eth65 {
+				if msg.Code != NewPooledTransactionHashesMsg {
+					t.Errorf("%v: got code %d, want NewPooledTransactionHashesMsg", p.Peer, msg.Code)
+				}
+				if err := msg.Decode(&hashes); err != nil {
+					t.Errorf("%v: %v", p.Peer, err)
+				}
+			} else {
+				var txs []*types.Transaction
+				if msg.Code != TxMsg {
+					t.Errorf("%v: got code %d, want TxMsg", p.Peer, msg.Code)
+				}
+				if err := msg.Decode(&txs); err != nil {
+					t.Errorf("%v: %v", p.Peer, err)
+				}
+				for _, tx := range txs {
+					hashes = append(hashes, tx.Hash())
+				}
 			}
-			for _, tx := range txs {
-				hash := tx.Hash()
+			for _, hash := range hashes {
 				seentx, want := seen[hash]
 				if seentx {
 					t.Errorf("%v: got tx more than once: %x", p.Peer, hash)
@@ -178,6 +244,232 @@ func testSendTransactions(t *testing.T, protocol int) {
 }
 
 // Tests that the custom union field encoder and decoder works correctly.
+// Tests that large transactions are announced to eth/65 peers and served on
+// request, while small ones and older peers get the transactions themselves.
+func TestAnnounceTransactions(t *testing.T) {
+	pm := newTestProtocolManagerMust(t, false, 0, nil, nil)
+	defer pm.Stop()
+
+	p65, _ := newTestPeer("peer #65", 65, pm, true)
+	defer p65.close()
+	p63, _ := newTestPeer("peer #63", 63, pm, true)
+	defer p63.close()
+	for pm.peers.Len() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// Broadcasts are sent synchronously, so read them from both peers at once.
+	expect := func(large, small *types.Transaction) {
+		var wg sync.WaitGroup
+		check := func(p *testPeer, code uint64, content interface{}) {
+			defer wg.Done()
+			if err := p2p.ExpectMsg(p.app, code, content); err != nil {
+				t.Errorf("%v: %v", p.Peer, err)
+			}
+		}
+		wg.Add(2)
+		if large != nil {
+			go check(p65, NewPooledTransactionHashesMsg, []common.Hash{large.Hash()})
+			go check(p63, TxMsg, []*types.Transaction{large})
+			pm.BroadcastTxs(types.Transactions{large})
+		} else {
+			go check(p65, TxMsg, []*types.Transaction{small})
+			go check(p63, TxMsg, []*types.Transaction{small})
+		}
+		if small != nil {
+			pm.BroadcastTxs(types.Transactions{small})
+		}
+		wg.Wait()
+	}
+	large := newTestTransaction(testAccount, 0, txAnnounceSize)
+	pm.txpool.AddTransactions([]*types.Transaction{large})
+	expect(large, nil)
+
+	// Announced transactions are served, unknown ones skipped.
+	if err := p2p.Send(p65.app, GetPooledTransactionsMsg, []common.Hash{{0x01}, large.Hash()}); err != nil {
+		t.Fatalf("send error: %v", err)
+	}
+	if err := p2p.ExpectMsg(p65.app, PooledTransactionsMsg, []*types.Transaction{large}); err != nil {
+		t.Errorf("pooled transactions: %v", err)
+	}
+	// Broadcasting again doesn't resend to peers knowing the transaction, and
+	// small transactions are sent to all peers.
+	pm.BroadcastTxs(types.Transactions{large})
+	expect(nil, newTestTransaction(testAccount, 1, 0))
+}
+
+// Tests that small transactions are sent to a square root of the eth/65 peers
+// and announced to the rest, while older peers all get them.
+func TestBroadcastTransactionsSqrt(t *testing.T) {
+	pm := newTestProtocolManagerMust(t, false, 0, nil, nil)
+	defer pm.Stop()
+
+	var pullers []*testPeer
+	for i := 0; i < 4; i++ {
+		p, _ := newTestPeer(fmt.Sprintf("peer #%d", i), 65, pm, true)
+		defer p.close()
+		pullers = append(pullers, p)
+	}
+	legacy, _ := newTestPeer("peer #63", 63, pm, true)
+	defer legacy.close()
+	for pm.peers.Len() < 5 {
+		time.Sleep(time.Millisecond)
+	}
+
+	tx := newTestTransaction(testAccount, 0, 0)
+	var (
+		wg    sync.WaitGroup
+		lock  sync.Mutex
+		codes = make(map[uint64]int)
+	)
+	for _, p := range pullers {
+		wg.Add(1)
+		go func(p *testPeer) {
+			defer wg.Done()
+			msg, err := p.app.ReadMsg()
+			if err != nil {
+				t.Errorf("%v: read error: %v", p.Peer, err)
+				return
+			}
+			msg.Discard()
+
+			lock.Lock()
+			codes[msg.Code]++
+			lock.Unlock()
+		}(p)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := p2p.ExpectMsg(legacy.app, TxMsg, []*types.Transaction{tx}); err != nil {
+			t.Errorf("%v: %v", legacy.Peer, err)
+		}
+	}()
+	pm.BroadcastTxs(types.Transactions{tx})
+	wg.Wait()
+
+	if codes[TxMsg] != 2 || codes[NewPooledTransactionHashesMsg] != 2 {
+		t.Errorf("eth/65 peers: %d got the transaction, %d the announcement, want 2 and 2", codes[TxMsg], codes[NewPooledTransactionHashesMsg])
+	}
+}
+
+// Tests that announced transactions are pulled from only one of the peers
+// announcing them, and delivered to the pool.
+func TestPullTransactions(t *testing.T) {
+	txAdded := make(chan []*types.Transaction)
+	pm := newTestProtocolManagerMust(t, false, 0, nil, txAdded)
+	pm.synced = 1 // mark synced to accept transactions
+	defer pm.Stop()
+
+	first, _ := newTestPeer("first", 65, pm, true)
+	defer first.close()
+	second, _ := newTestPeer("second", 65, pm, true)
+	defer second.close()
+
+	tx := newTestTransaction(testAccount, 0, txAnnounceSize)
+	if err := p2p.Send(first.app, NewPooledTransactionHashesMsg, []common.Hash{tx.Hash()}); err != nil {
+		t.Fatalf("send error: %v", err)
+	}
+	if err := p2p.ExpectMsg(first.app, GetPooledTransactionsMsg, []common.Hash{tx.Hash()}); err != nil {
+		t.Fatalf("first request: %v", err)
+	}
+	// The second announcement is ignored while the transaction is in flight, so
+	// the reply to a subsequent query is the next message the peer gets.
+	if err := p2p.Send(second.app, NewPooledTransactionHashesMsg, []common.Hash{tx.Hash()}); err != nil {
+		t.Fatalf("send error: %v", err)
+	}
+	if err := p2p.Send(second.app, GetPooledTransactionsMsg, []common.Hash{}); err != nil {
+		t.Fatalf("send error: %v", err)
+	}
+	if err := p2p.ExpectMsg(second.app, PooledTransactionsMsg, []*types.Transaction{}); err != nil {
+		t.Errorf("second peer: %v", err)
+	}
+
+	if err := p2p.Send(first.app, PooledTransactionsMsg, []*types.Transaction{tx}); err != nil {
+		t.Fatalf("send error: %v", err)
+	}
+	select {
+	case added := <-txAdded:
+		if len(added) != 1 || added[0].Hash() != tx.Hash() {
+			t.Errorf("added transactions %v, want %x", added, tx.Hash())
+		}
+	case <-time.After(2 * time.Second):
+		t.Errorf("pulled transaction not added within 2 seconds")
+	}
+	// Once known, the transaction is no longer requested.
+	if err := p2p.Send(second.app, NewPooledTransactionHashesMsg, []common.Hash{tx.Hash()}); err != nil {
+		t.Fatalf("send error: %v", err)
+	}
+	if err := p2p.Send(second.app, GetPooledTransactionsMsg, []common.Hash{}); err != nil {
+		t.Fatalf("send error: %v", err)
+	}
+	if err := p2p.ExpectMsg(second.app, PooledTransactionsMsg, []*types.Transaction{}); err != nil {
+		t.Errorf("second peer: %v", err)
+	}
+}
+
+// Tests that pooled transactions are only accepted from the peers they were
+// requested from.
+func TestUnrequestedPooledTransactions(t *testing.T) {
+	txAdded := make(chan []*types.Transaction)
+	pm := newTestProtocolManagerMust(t, false, 0, nil, txAdded)
+	pm.synced = 1 // mark synced to accept transactions
+	defer pm.Stop()
+
+	first, _ := newTestPeer("first", 65, pm, true)
+	defer first.close()
+	second, _ := newTestPeer("second", 65, pm, true)
+	defer second.close()
+
+	tx := newTestTransaction(testAccount, 0, txAnnounceSize)
+	if err := p2p.Send(first.app, NewPooledTransactionHashesMsg, []common.Hash{tx.Hash()}); err != nil {
+		t.Fatalf("send error: %v", err)
+	}
+	if err := p2p.ExpectMsg(first.app, GetPooledTransactionsMsg, []common.Hash{tx.Hash()}); err != nil {
+		t.Fatalf("first request: %v", err)
+	}
+	// Deliveries of other peers are dropped
+	if err := p2p.Send(second.app, PooledTransactionsMsg, []*types.Transaction{tx}); err != nil {
+		t.Fatalf("send error: %v", err)
+	}
+	select {
+	case added := <-txAdded:
+		t.Fatalf("unrequested transactions added: %v", added)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if err := p2p.Send(first.app, PooledTransactionsMsg, []*types.Transaction{tx}); err != nil {
+		t.Fatalf("send error: %v", err)
+	}
+	select {
+	case added := <-txAdded:
+		if len(added) != 1 || added[0].Hash() != tx.Hash() {
+			t.Errorf("added transactions %v, want %x", added, tx.Hash())
+		}
+	case <-time.After(2 * time.Second):
+		t.Errorf("requested transaction not added within 2 seconds")
+	}
+}
+
+// Tests that peers announcing too many transactions at once are dropped.
+func TestAnnounceTooManyTransactions(t *testing.T) {
+	pm := newTestProtocolManagerMust(t, false, 0, nil, nil)
+	pm.synced = 1 // mark synced to accept transactions
+	defer pm.Stop()
+
+	p, errc := newTestPeer("peer", 65, pm, true)
+	defer p.close()
+
+	go p2p.Send(p.app, NewPooledTransactionHashesMsg, make([]common.Hash, maxTxAnnounce+1))
+	select {
+	case err := <-errc:
+		if want := errResp(ErrMsgTooLarge, "%d transactions announced (> %d)", maxTxAnnounce+1, maxTxAnnounce); err == nil || err.Error() != want.Error() {
+			t.Errorf("error mismatch: have %v, want %v", err, want)
+		}
+	case <-time.After(2 * time.Second):
+		t.Errorf("peer not dropped within 2 seconds")
+	}
+}
+
 func TestGetBlockHeadersDataEncodeDecode(t *testing.T) {
 	// Create a "random" hash for testing
 	var hash common.Hash
@@ -0,0 +1,236 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/core"
+	"github.com/ellaism/go-ellaism/core/types"
+	"github.com/ellaism/go-ellaism/logger"
+	"github.com/ellaism/go-ellaism/logger/glog"
+)
+
+const (
+	repairFetchTimeout = 5 * time.Second // Time after which an unanswered repair request is sent to another peer
+	repairCycle        = time.Second     // Time between checks for repairs to request
+	maxRepairFetch     = 64              // Maximum number of bodies or receipts to request in one message
+	maxRepairs         = 1024            // Maximum number of blocks to track for repair
+)
+
+// blockRepair is a block missing its body or receipts.
+type blockRepair struct {
+	header    *types.Header
+	requested time.Time // Time the data was last requested, zero if never
+}
+
+// blockRepairs tracks the canonical blocks found missing their body or their
+// receipts in the database, to refetch the data from the peers.
+type blockRepairs struct {
+	bodies   map[common.Hash]*blockRepair
+	receipts map[common.Hash]*blockRepair
+	lock     sync.Mutex
+}
+
+func newBlockRepairs() *blockRepairs {
+	return &blockRepairs{
+		bodies:   make(map[common.Hash]*blockRepair),
+		receipts: make(map[common.Hash]*blockRepair),
+	}
+}
+
+// add schedules the body or the receipts of the block with the given header to
+// be refetched. Blocks over the tracking allowance are dropped, they will be
+// reported again if still missing data.
+func (r *blockRepairs) add(header *types.Header, receipts bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	repairs := r.bodies
+	if receipts {
+		repairs = r.receipts
+	}
+	if len(r.bodies)+len(r.receipts) >= maxRepairs {
+		return
+	}
+	if _, ok := repairs[header.Hash()]; !ok {
+		repairs[header.Hash()] = &blockRepair{header: header}
+	}
+}
+
+// due returns the hashes of the blocks whose body or receipts were never
+// requested or whose request timed out, and marks them as requested.
+func (r *blockRepairs) due(receipts bool) []common.Hash {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	repairs := r.bodies
+	if receipts {
+		repairs = r.receipts
+	}
+	now := time.Now()
+
+	var hashes []common.Hash
+	for hash, repair := range repairs {
+		if len(hashes) >= maxRepairFetch {
+			break
+		}
+		if now.Sub(repair.requested) > repairFetchTimeout {
+			repair.requested = now
+			hashes = append(hashes, hash)
+		}
+	}
+	return hashes
+}
+
+// pending returns whether any block is waiting for repair.
+func (r *blockRepairs) pending() bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return len(r.bodies)+len(r.receipts) > 0
+}
+
+// matchBodies takes the delivered bodies matching the roots of the blocks
+// waiting for their body, returning them by block hash along with the bodies
+// left over.
+func (r *blockRepairs) matchBodies(transactions [][]*types.Transaction, uncles [][]*types.Header) (map[common.Hash]*types.Body, [][]*types.Transaction, [][]*types.Header) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if len(r.bodies) == 0 {
+		return nil, transactions, uncles
+	}
+	matched := make(map[common.Hash]*types.Body)
+	restTxs, restUncles := transactions[:0], uncles[:0]
+	for i := range transactions {
+		txSha, uncleSha := types.DeriveSha(types.Transactions(transactions[i])), types.CalcUncleHash(uncles[i])
+
+		found := false
+		for hash, repair := range r.bodies {
+			if repair.header.TxHash == txSha && repair.header.UncleHash == uncleSha {
+				matched[hash] = &types.Body{Transactions: transactions[i], Uncles: uncles[i]}
+				delete(r.bodies, hash)
+				found = true
+				break
+			}
+		}
+		if !found {
+			restTxs, restUncles = append(restTxs, transactions[i]), append(restUncles, uncles[i])
+		}
+	}
+	return matched, restTxs, restUncles
+}
+
+// matchReceipts takes the delivered receipts matching the receipt roots of the
+// blocks waiting for their receipts, returning them by block hash along with
+// the receipts left over.
+func (r *blockRepairs) matchReceipts(receipts [][]*types.Receipt) (map[common.Hash]types.Receipts, [][]*types.Receipt) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if len(r.receipts) == 0 {
+		return nil, receipts
+	}
+	matched := make(map[common.Hash]types.Receipts)
+	rest := receipts[:0]
+	for _, list := range receipts {
+		receiptSha := types.DeriveSha(types.Receipts(list))
+
+		found := false
+		for hash, repair := range r.receipts {
+			if repair.header.ReceiptHash == receiptSha {
+				matched[hash] = list
+				delete(r.receipts, hash)
+				found = true
+				break
+			}
+		}
+		if !found {
+			rest = append(rest, list)
+		}
+	}
+	return matched, rest
+}
+
+// repairLoop refetches from the peers the bodies and receipts of the canonical
+// blocks found missing from the database.
+func (pm *ProtocolManager) repairLoop() {
+	sub := pm.eventMux.Subscribe(core.MissingBlockDataEvent{})
+	defer sub.Unsubscribe()
+
+	ticker := time.NewTicker(repairCycle)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case obj, ok := <-sub.Chan():
+			if !ok {
+				return
+			}
+			if ev, ok := obj.Data.(core.MissingBlockDataEvent); ok {
+				pm.repairs.add(ev.Header, ev.Receipts)
+			}
+
+		case <-ticker.C:
+			if !pm.repairs.pending() {
+				continue
+			}
+			if p := pm.peers.RandomPeer(); p != nil {
+				if hashes := pm.repairs.due(false); len(hashes) > 0 {
+					glog.V(logger.Debug).Infof("%v: requesting %d missing bodies", p, len(hashes))
+					go p.RequestBodies(hashes)
+				}
+				if p.version >= eth63 {
+					if hashes := pm.repairs.due(true); len(hashes) > 0 {
+						glog.V(logger.Debug).Infof("%v: requesting %d missing receipts", p, len(hashes))
+						go p.RequestReceipts(hashes)
+					}
+				}
+			}
+
+		case <-pm.quitSync:
+			return
+		}
+	}
+}
+
+// repairBodies stores the delivered bodies which were missing from the
+// database, returning the others.
+func (pm *ProtocolManager) repairBodies(transactions [][]*types.Transaction, uncles [][]*types.Header) ([][]*types.Transaction, [][]*types.Header) {
+	matched, transactions, uncles := pm.repairs.matchBodies(transactions, uncles)
+	for hash, body := range matched {
+		if err := pm.blockchain.RepairBody(hash, body); err != nil {
+			glog.V(logger.Error).Errorf("failed to repair body of block %x: %v", hash, err)
+		}
+	}
+	return transactions, uncles
+}
+
+// repairReceipts stores the delivered receipts which were missing from the
+// database, returning the others.
+func (pm *ProtocolManager) repairReceipts(receipts [][]*types.Receipt) [][]*types.Receipt {
+	matched, receipts := pm.repairs.matchReceipts(receipts)
+	for hash, list := range matched {
+		if err := pm.blockchain.RepairReceipts(hash, list); err != nil {
+			glog.V(logger.Error).Errorf("failed to repair receipts of block %x: %v", hash, err)
+		}
+	}
+	return receipts
+}
@@ -66,15 +66,22 @@ func (pm *ProtocolManager) txsyncLoop() {
 		done    = make(chan error, 1) // result of the send
 	)
 
-	// send starts a sending a pack of transactions from the sync.
+	// send starts a sending a pack of transactions from the sync. Peers able
+	// to pull transactions only get their hashes, as they may know most of
+	// them already.
 	send := func(s *txsync) {
 		// Fill pack with transactions up to the target size.
+		announce := s.p.version >= eth65
 		size := common.StorageSize(0)
 		pack.p = s.p
 		pack.txs = pack.txs[:0]
 		for i := 0; i < len(s.txs) && size < txsyncPackSize; i++ {
 			pack.txs = append(pack.txs, s.txs[i])
-			size += s.txs[i].Size()
+			if announce {
+				size += common.HashLength
+			} else {
+				size += s.txs[i].Size()
+			}
 		}
 		// Remove the transactions that will be sent.
 		s.txs = s.txs[:copy(s.txs, s.txs[len(pack.txs):])]
@@ -82,8 +89,17 @@ func (pm *ProtocolManager) txsyncLoop() {
 			delete(pending, s.p.ID())
 		}
 		// Send the pack in the background.
-		glog.V(logger.Detail).Infof("%v: sending %d transactions (%v)", s.p.Peer, len(pack.txs), size)
 		sending = true
+		if announce {
+			hashes := make([]common.Hash, len(pack.txs))
+			for i, tx := range pack.txs {
+				hashes[i] = tx.Hash()
+			}
+			glog.V(logger.Detail).Infof("%v: announcing %d transactions", s.p.Peer, len(hashes))
+			go func() { done <- pack.p.SendNewPooledTransactionHashes(hashes) }()
+			return
+		}
+		glog.V(logger.Detail).Infof("%v: sending %d transactions (%v)", s.p.Peer, len(pack.txs), size)
 		go func() { done <- pack.p.SendTransactions(pack.txs) }()
 	}
 
@@ -168,8 +184,7 @@ func (pm *ProtocolManager) synchronise(peer *peer) {
 	}
 
 	// Make sure the peer's TD is higher than our own
-	currentBlock := pm.blockchain.CurrentBlock()
-	td := pm.blockchain.GetTd(currentBlock.Hash())
+	td := pm.blockchain.GetTd(pm.currentHead().Hash())
 	// Stored block's td should never be nil or non-positive.
 	if td == nil || td.Sign() < 1 {
 		glog.Fatalf("Found invalid TD=%v for current block in database. Exiting.\nCheck available disk space and restart to attempt database recovery.", td)
@@ -181,7 +196,9 @@ func (pm *ProtocolManager) synchronise(peer *peer) {
 
 	// Otherwise try to sync with the downloader
 	mode := downloader.FullSync
-	if atomic.LoadUint32(&pm.fastSync) == 1 {
+	if pm.headerOnly {
+		mode = downloader.LightSync
+	} else if atomic.LoadUint32(&pm.fastSync) == 1 {
 		mode = downloader.FastSync
 	}
 	if !pm.downloader.Synchronise(peer.id, pHead, pTd, mode) {
@@ -58,3 +58,29 @@ func TestFastSyncDisabling(t *testing.T) {
 		t.Fatalf("fast sync not disabled after successful synchronisation")
 	}
 }
+
+// Tests that a header-only node syncs the header chain, and nothing else.
+func TestHeaderOnlySync(t *testing.T) {
+	pmHeader := newTestProtocolManagerMust(t, false, 0, nil, nil)
+	pmHeader.headerOnly = true // Only synchronisation is exercised, not the fetcher
+
+	pmFull := newTestProtocolManagerMust(t, false, 1024, nil, nil)
+
+	io1, io2 := p2p.MsgPipe()
+
+	go pmFull.handle(pmFull.newPeer(63, p2p.NewPeer(discover.NodeID{}, "header", nil), io2))
+	go pmHeader.handle(pmHeader.newPeer(63, p2p.NewPeer(discover.NodeID{}, "full", nil), io1))
+
+	time.Sleep(250 * time.Millisecond)
+	pmHeader.synchronise(pmHeader.peers.BestPeer())
+
+	if head := pmHeader.blockchain.CurrentHeader(); head.Hash() != pmFull.blockchain.CurrentBlock().Hash() {
+		t.Fatalf("head header mismatch: have #%d, want #%d", head.Number, pmFull.blockchain.CurrentBlock().Number())
+	}
+	if num := pmHeader.blockchain.CurrentBlock().NumberU64(); num != 0 {
+		t.Fatalf("head block imported in header-only mode: have #%d, want #0", num)
+	}
+	if info := pmHeader.NodeInfo(); info.Head != pmFull.blockchain.CurrentBlock().Hash() {
+		t.Fatalf("node info head mismatch: have %x", info.Head)
+	}
+}
@@ -0,0 +1,464 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/core"
+	"github.com/ellaism/go-ellaism/core/types"
+	"github.com/ellaism/go-ellaism/core/vm"
+	"github.com/ellaism/go-ellaism/rpc"
+)
+
+// maxTraceFilterBlocks is the number of blocks trace_filter searches at most in
+// a single request.
+const maxTraceFilterBlocks = 1000
+
+// ParityTrace is a call frame of a transaction or a reward of a block in the
+// trace format of OpenEthereum (formerly Parity).
+type ParityTrace struct {
+	Action              interface{}  `json:"action"`
+	BlockHash           *common.Hash `json:"blockHash,omitempty"`
+	BlockNumber         *uint64      `json:"blockNumber,omitempty"`
+	Error               string       `json:"error,omitempty"`
+	Result              interface{}  `json:"result"`
+	Subtraces           int          `json:"subtraces"`
+	TraceAddress        []uint       `json:"traceAddress"`
+	TransactionHash     *common.Hash `json:"transactionHash"`
+	TransactionPosition *uint64      `json:"transactionPosition"`
+	Type                string       `json:"type"` // "call", "create", "suicide" or "reward"
+
+	from *common.Address // Sender matched by trace_filter, nil for rewards
+	to   common.Address  // Recipient matched by trace_filter
+}
+
+// ParityCallAction is the action of a message call trace.
+type ParityCallAction struct {
+	CallType string         `json:"callType"` // "call", "callcode" or "delegatecall"
+	From     common.Address `json:"from"`
+	Gas      *rpc.HexNumber `json:"gas"`
+	Input    string         `json:"input"`
+	To       common.Address `json:"to"`
+	Value    *rpc.HexNumber `json:"value"`
+}
+
+// ParityCallResult is the result of a successful message call trace.
+type ParityCallResult struct {
+	GasUsed *rpc.HexNumber `json:"gasUsed"`
+	Output  string         `json:"output"`
+}
+
+// ParityCreateAction is the action of a contract creation trace.
+type ParityCreateAction struct {
+	From  common.Address `json:"from"`
+	Gas   *rpc.HexNumber `json:"gas"`
+	Init  string         `json:"init"`
+	Value *rpc.HexNumber `json:"value"`
+}
+
+// ParityCreateResult is the result of a successful contract creation trace.
+type ParityCreateResult struct {
+	Address common.Address `json:"address"`
+	Code    string         `json:"code"`
+	GasUsed *rpc.HexNumber `json:"gasUsed"`
+}
+
+// ParitySuicideAction is the action of a suicide trace.
+type ParitySuicideAction struct {
+	Address       common.Address `json:"address"`
+	Balance       *rpc.HexNumber `json:"balance"`
+	RefundAddress common.Address `json:"refundAddress"`
+}
+
+// ParityRewardAction is the action of a reward trace.
+type ParityRewardAction struct {
+	Author     common.Address `json:"author"`
+	RewardType string         `json:"rewardType"` // "block", "uncle" or "external" for the treasury share
+	Value      *rpc.HexNumber `json:"value"`
+}
+
+// newParityTraces flattens a call frame and the frames of the calls it made into
+// traces, in execution order. The address is the position of the frame in the
+// tree of calls of its transaction.
+func newParityTraces(frame *vm.CallFrame, address []uint) []*ParityTrace {
+	trace := &ParityTrace{
+		Subtraces:    len(frame.Calls),
+		TraceAddress: address,
+	}
+	from := frame.From
+	trace.from, trace.to = &from, frame.To
+
+	switch frame.Type {
+	case vm.CREATE, vm.CREATE2:
+		trace.Type = "create"
+		trace.Action = &ParityCreateAction{
+			From:  frame.From,
+			Gas:   rpc.NewHexNumber(frame.Gas),
+			Init:  fmt.Sprintf("0x%x", frame.Input),
+			Value: rpc.NewHexNumber(frame.Value),
+		}
+		if frame.Error == nil {
+			trace.Result = &ParityCreateResult{
+				Address: frame.To,
+				Code:    fmt.Sprintf("0x%x", frame.Output),
+				GasUsed: rpc.NewHexNumber(frame.GasUsed),
+			}
+		}
+	case vm.SUICIDE:
+		trace.Type = "suicide"
+		trace.Action = &ParitySuicideAction{
+			Address:       frame.From,
+			Balance:       rpc.NewHexNumber(frame.Value),
+			RefundAddress: frame.To,
+		}
+	default:
+		trace.Type = "call"
+		trace.Action = &ParityCallAction{
+			CallType: strings.ToLower(frame.Type.String()),
+			From:     frame.From,
+			Gas:      rpc.NewHexNumber(frame.Gas),
+			Input:    fmt.Sprintf("0x%x", frame.Input),
+			To:       frame.To,
+			Value:    rpc.NewHexNumber(frame.Value),
+		}
+		if frame.Error == nil {
+			trace.Result = &ParityCallResult{
+				GasUsed: rpc.NewHexNumber(frame.GasUsed),
+				Output:  fmt.Sprintf("0x%x", frame.Output),
+			}
+		}
+	}
+	if frame.Error != nil {
+		trace.Error = parityError(frame.Error)
+	}
+	traces := []*ParityTrace{trace}
+	for i, call := range frame.Calls {
+		child := make([]uint, len(address)+1)
+		copy(child, address)
+		child[len(address)] = uint(i)
+		traces = append(traces, newParityTraces(call, child)...)
+	}
+	return traces
+}
+
+// parityError returns the error message OpenEthereum reports for the given
+// execution error.
+func parityError(err error) string {
+	switch err {
+	case vm.ErrExecutionReverted:
+		return "Reverted"
+	case vm.OutOfGasError, vm.CodeStoreOutOfGasError:
+		return "Out of gas"
+	}
+	return err.Error()
+}
+
+// PublicTraceAPI provides the trace_* methods of OpenEthereum, which most block
+// explorers and analytics tools use to list the internal transactions of the
+// chain.
+type PublicTraceAPI struct {
+	eth   *Ethereum
+	debug *PublicDebugAPI // Replays the transactions to trace them
+}
+
+// NewPublicTraceAPI creates a new RPC service with the OpenEthereum trace methods.
+func NewPublicTraceAPI(eth *Ethereum) *PublicTraceAPI {
+	return &PublicTraceAPI{eth: eth, debug: NewPublicDebugAPI(eth)}
+}
+
+// Block returns the traces of the transactions of the block with the given
+// number, followed by the traces of its rewards, or nil if the block is unknown.
+func (api *PublicTraceAPI) Block(blockNr rpc.BlockNumber) ([]*ParityTrace, error) {
+	if blockNr == rpc.PendingBlockNumber {
+		return nil, errors.New("traces of the pending block are not supported")
+	}
+	block := blockByNumber(api.eth.Miner(), api.eth.BlockChain(), blockNr)
+	if block == nil {
+		return nil, nil
+	}
+	traces, err := api.transactionTraces(block)
+	if err != nil {
+		return nil, err
+	}
+	return append(traces, api.rewardTraces(block)...), nil
+}
+
+// Transaction returns the traces of the transaction with the given hash, or nil
+// if the transaction is unknown.
+func (api *PublicTraceAPI) Transaction(hash common.Hash) ([]*ParityTrace, error) {
+	tx, blockHash, _, index := core.GetTransaction(api.eth.ChainDb(), hash)
+	if tx == nil {
+		return nil, nil
+	}
+	block := api.eth.BlockChain().GetBlock(blockHash)
+	if block == nil {
+		return nil, fmt.Errorf("block %x not found", blockHash)
+	}
+	msg, vmenv, statedb, err := api.debug.computeTxEnv(blockHash, int(index))
+	if err != nil {
+		return nil, err
+	}
+	trace, err := traceMessage(statedb, vmenv, msg, new(core.GasPool).AddGas(tx.Gas()), &TraceConfig{Tracer: "callTracer"})
+	if err != nil {
+		return nil, err
+	}
+	frame, ok := trace.(*vm.CallFrame)
+	if !ok || frame == nil {
+		return nil, fmt.Errorf("no call frame traced for tx %x", hash)
+	}
+	traces := newParityTraces(frame, []uint{})
+	setParityTxTraces(traces, block, tx.Hash(), index)
+	return traces, nil
+}
+
+// TraceFilterArgs are the criteria of trace_filter. Empty address lists match
+// any address.
+type TraceFilterArgs struct {
+	FromBlock   *rpc.BlockNumber `json:"fromBlock"` // Defaults to the latest block
+	ToBlock     *rpc.BlockNumber `json:"toBlock"`   // Defaults to the latest block
+	FromAddress []common.Address `json:"fromAddress"`
+	ToAddress   []common.Address `json:"toAddress"`
+	After       *uint64          `json:"after"` // Number of matching traces to skip
+	Count       *uint64          `json:"count"` // Number of matching traces to return at most
+}
+
+// Filter returns the traces of the canonical blocks of the given range whose
+// sender is one of the given from addresses and whose recipient is one of the
+// given to addresses. The sender of a suicide is the suicided contract and its
+// recipient the refund address; the recipient of a contract creation is the
+// created contract; rewards have no sender and their author as recipient. The
+// transactions of the blocks indexed by the trace index are only replayed if
+// one of their call frames involves the given addresses, those of the blocks
+// not indexed are always replayed.
+func (api *PublicTraceAPI) Filter(args TraceFilterArgs) ([]*ParityTrace, error) {
+	fromBlock, toBlock := rpc.LatestBlockNumber, rpc.LatestBlockNumber
+	if args.FromBlock != nil {
+		fromBlock = *args.FromBlock
+	}
+	if args.ToBlock != nil {
+		toBlock = *args.ToBlock
+	}
+	if fromBlock == rpc.PendingBlockNumber || toBlock == rpc.PendingBlockNumber {
+		return nil, errors.New("traces of the pending block are not supported")
+	}
+	bc := api.eth.BlockChain()
+	from, to := blockByNumber(api.eth.Miner(), bc, fromBlock), blockByNumber(api.eth.Miner(), bc, toBlock)
+	if from == nil || to == nil {
+		return nil, errors.New("block range not found")
+	}
+	first, last := from.NumberU64(), to.NumberU64()
+	if first > last {
+		return nil, fmt.Errorf("invalid block range #%d-#%d", first, last)
+	}
+	if last-first >= maxTraceFilterBlocks {
+		return nil, fmt.Errorf("block range #%d-#%d exceeds %d blocks", first, last, maxTraceFilterBlocks)
+	}
+	filter := newTraceFilter(args)
+
+	var (
+		traces = []*ParityTrace{}
+		skip   uint64
+	)
+	if args.After != nil {
+		skip = *args.After
+	}
+	for number := first; number <= last; number++ {
+		block := bc.GetBlockByNumber(number)
+		if block == nil {
+			return nil, fmt.Errorf("block #%d not found", number)
+		}
+		var blockTraces []*ParityTrace
+		if records, indexed := core.GetBlockTraces(api.eth.ChainDb(), block.Hash()); !indexed || filter.matchRecords(records) {
+			var err error
+			if blockTraces, err = api.transactionTraces(block); err != nil {
+				return nil, fmt.Errorf("block #%d: %v", number, err)
+			}
+		}
+		for _, trace := range append(blockTraces, api.rewardTraces(block)...) {
+			if !filter.match(trace.from, trace.to) {
+				continue
+			}
+			if skip > 0 {
+				skip--
+				continue
+			}
+			if args.Count != nil && uint64(len(traces)) >= *args.Count {
+				return traces, nil
+			}
+			traces = append(traces, trace)
+		}
+	}
+	return traces, nil
+}
+
+// TraceCallResult is the result of trace_call.
+type TraceCallResult struct {
+	Output    string         `json:"output"`
+	StateDiff interface{}    `json:"stateDiff"` // Not supported, always nil
+	Trace     []*ParityTrace `json:"trace"`
+	VmTrace   interface{}    `json:"vmTrace"` // Not supported, always nil
+}
+
+// Call executes a call on top of the state of the given block number without
+// altering it and returns its output, along with its traces if the trace types
+// include "trace". The "vmTrace" and "stateDiff" trace types aren't supported.
+func (api *PublicTraceAPI) Call(args CallArgs, traceTypes []string, blockNr rpc.BlockNumber) (*TraceCallResult, error) {
+	var traced bool
+	for _, typ := range traceTypes {
+		switch typ {
+		case "trace":
+			traced = true
+		case "vmTrace", "stateDiff":
+			return nil, fmt.Errorf("trace type %q not supported", typ)
+		default:
+			return nil, fmt.Errorf("unknown trace type %q", typ)
+		}
+	}
+	if err := resolveRecipient(args.To, api.eth.names); err != nil {
+		return nil, err
+	}
+	statedb, vmenv, msg, err := callEnv(api.eth.chainConfig, api.eth.BlockChain(), api.eth.Miner(), api.eth.ChainDb(), api.eth.AccountManager(), args, blockNr)
+	if statedb == nil || err != nil {
+		return nil, err
+	}
+	tracer := vm.NewCallTracer()
+	vmenv.SetTracer(tracer)
+	ret, _, err := core.ApplyMessage(vmenv, msg, new(core.GasPool).AddGas(common.MaxBig))
+	if err != nil {
+		return nil, err
+	}
+	result := &TraceCallResult{Output: fmt.Sprintf("0x%x", ret)}
+	if traced && tracer.Result() != nil {
+		result.Trace = newParityTraces(tracer.Result(), []uint{})
+	}
+	return result, nil
+}
+
+// transactionTraces replays the transactions of the given block and returns
+// their traces, in order.
+func (api *PublicTraceAPI) transactionTraces(block *types.Block) ([]*ParityTrace, error) {
+	frames, err := api.debug.traceBlock(block, &TraceConfig{Tracer: "callTracer"})
+	if err != nil {
+		return nil, err
+	}
+	traces := []*ParityTrace{}
+	for i, tx := range block.Transactions() {
+		frame, ok := frames[i].(*vm.CallFrame)
+		if !ok || frame == nil {
+			return nil, fmt.Errorf("no call frame traced for tx %x", tx.Hash())
+		}
+		txTraces := newParityTraces(frame, []uint{})
+		setParityTxTraces(txTraces, block, tx.Hash(), uint64(i))
+		traces = append(traces, txTraces...)
+	}
+	return traces, nil
+}
+
+// setParityTxTraces sets the block and transaction the given traces are part of.
+func setParityTxTraces(traces []*ParityTrace, block *types.Block, txHash common.Hash, txIndex uint64) {
+	var (
+		blockHash   = block.Hash()
+		blockNumber = block.NumberU64()
+	)
+	for _, trace := range traces {
+		trace.BlockHash, trace.BlockNumber = &blockHash, &blockNumber
+		trace.TransactionHash, trace.TransactionPosition = &txHash, &txIndex
+	}
+}
+
+// rewardTraces returns the traces of the rewards credited for the given block:
+// the reward of its miner, those of the miners of its uncles and the treasury
+// share, if any.
+func (api *PublicTraceAPI) rewardTraces(block *types.Block) []*ParityTrace {
+	// The genesis block isn't rewarded
+	if block.NumberU64() == 0 {
+		return nil
+	}
+	var (
+		blockHash   = block.Hash()
+		blockNumber = block.NumberU64()
+		reward      = core.CalcBlockReward(api.eth.chainConfig, block.Header(), block.Uncles())
+		traces      []*ParityTrace
+	)
+	add := func(author common.Address, typ string, value *big.Int) {
+		if value == nil || value.Sign() == 0 {
+			return
+		}
+		traces = append(traces, &ParityTrace{
+			Action:       &ParityRewardAction{Author: author, RewardType: typ, Value: rpc.NewHexNumber(value)},
+			BlockHash:    &blockHash,
+			BlockNumber:  &blockNumber,
+			TraceAddress: []uint{},
+			Type:         "reward",
+			to:           author,
+		})
+	}
+	add(block.Coinbase(), "block", reward.Miner())
+	for i, uncle := range block.Uncles() {
+		add(uncle.Coinbase, "uncle", reward.Uncle[i])
+	}
+	if reward.TreasuryAddress != nil {
+		add(*reward.TreasuryAddress, "external", reward.Treasury)
+	}
+	return traces
+}
+
+// traceFilter matches traces against the addresses of trace_filter.
+type traceFilter struct {
+	from map[common.Address]bool
+	to   map[common.Address]bool
+}
+
+func newTraceFilter(args TraceFilterArgs) *traceFilter {
+	filter := &traceFilter{
+		from: make(map[common.Address]bool, len(args.FromAddress)),
+		to:   make(map[common.Address]bool, len(args.ToAddress)),
+	}
+	for _, address := range args.FromAddress {
+		filter.from[address] = true
+	}
+	for _, address := range args.ToAddress {
+		filter.to[address] = true
+	}
+	return filter
+}
+
+// match reports whether a trace with the given sender and recipient matches the
+// filter. Traces without a sender only match filters without from addresses.
+func (f *traceFilter) match(from *common.Address, to common.Address) bool {
+	if len(f.from) > 0 && (from == nil || !f.from[*from]) {
+		return false
+	}
+	return len(f.to) == 0 || f.to[to]
+}
+
+// matchRecords reports whether any of the given trace index records, and thus
+// the traces of the transactions of their block, match the filter.
+func (f *traceFilter) matchRecords(records []*core.TraceRecord) bool {
+	for _, record := range records {
+		from := record.From
+		if f.match(&from, record.To) {
+			return true
+		}
+	}
+	return false
+}
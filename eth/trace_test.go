@@ -0,0 +1,155 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/core"
+	"github.com/ellaism/go-ellaism/core/types"
+	"github.com/ellaism/go-ellaism/crypto"
+	"github.com/ellaism/go-ellaism/ethdb"
+	"github.com/ellaism/go-ellaism/event"
+	"github.com/ellaism/go-ellaism/rpc"
+)
+
+// Tests that the trace methods report the call frames of transactions and the
+// rewards of blocks in the OpenEthereum trace format.
+func TestParityTraces(t *testing.T) {
+	var (
+		db, _       = ethdb.NewMemDatabase()
+		genesis     = core.WriteGenesisBlockForTesting(db, testBank)
+		chainConfig = core.MakeDiehardChainConfig()
+		signer      = types.NewChainIdSigner(chainConfig.GetChainID())
+		payee       = common.BytesToAddress([]byte{0xbb})
+		miner       = common.Address{0x02}
+		mux         = new(event.TypeMux)
+
+		// Contract creation code sending 5 wei to the payee
+		code = common.Hex2Bytes("6000600060006000600560bb5af100")
+	)
+	blockchain, err := core.NewBlockChain(db, chainConfig, new(core.FakePow), mux)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer blockchain.Stop()
+
+	var creation *types.Transaction
+	chain, _ := core.GenerateChain(chainConfig, genesis, db, 3, func(i int, gen *core.BlockGen) {
+		gen.SetCoinbase(miner)
+		if i == 1 {
+			creation, _ = types.NewContractCreation(gen.TxNonce(testBank.Address), big.NewInt(10), big.NewInt(100000), big.NewInt(1), code).WithSigner(signer).SignECDSA(testBankKey)
+			gen.AddTx(creation)
+		}
+	})
+	if _, err := blockchain.InsertChain(chain); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	eth := &Ethereum{chainConfig: chainConfig, blockchain: blockchain, chainDb: db, eventMux: mux}
+	api := NewPublicTraceAPI(eth)
+	contract := crypto.CreateAddress(testBank.Address, 0)
+
+	// The traces of a block list its call frames in execution order, then its rewards
+	traces, err := api.Block(rpc.BlockNumber(2))
+	if err != nil {
+		t.Fatalf("failed to trace block: %v", err)
+	}
+	if len(traces) != 3 {
+		t.Fatalf("block traces: have %d, want 3", len(traces))
+	}
+	if tr := traces[0]; tr.Type != "create" || tr.Subtraces != 1 || len(tr.TraceAddress) != 0 || *tr.TransactionHash != creation.Hash() || *tr.TransactionPosition != 0 || *tr.BlockNumber != 2 {
+		t.Errorf("creation trace mismatch: %+v", tr)
+	}
+	if action := traces[0].Action.(*ParityCreateAction); action.From != testBank.Address || action.Value.BigInt().Cmp(big.NewInt(10)) != 0 {
+		t.Errorf("creation action mismatch: %+v", action)
+	}
+	if result := traces[0].Result.(*ParityCreateResult); result.Address != contract {
+		t.Errorf("created contract mismatch: have %x, want %x", result.Address, contract)
+	}
+	if tr := traces[1]; tr.Type != "call" || tr.Subtraces != 0 || !reflect.DeepEqual(tr.TraceAddress, []uint{0}) || tr.Error != "" {
+		t.Errorf("call trace mismatch: %+v", tr)
+	}
+	if action := traces[1].Action.(*ParityCallAction); action.CallType != "call" || action.From != contract || action.To != payee || action.Value.BigInt().Cmp(big.NewInt(5)) != 0 {
+		t.Errorf("call action mismatch: %+v", action)
+	}
+	reward := core.CalcBlockReward(chainConfig, chain[1].Header(), nil).Miner()
+	if tr := traces[2]; tr.Type != "reward" || tr.TransactionHash != nil || tr.Result != nil {
+		t.Errorf("reward trace mismatch: %+v", tr)
+	}
+	if action := traces[2].Action.(*ParityRewardAction); action.Author != miner || action.RewardType != "block" || action.Value.BigInt().Cmp(reward) != 0 {
+		t.Errorf("reward action mismatch: %+v", action)
+	}
+	// The traces of a transaction are those of its block
+	txTraces, err := api.Transaction(creation.Hash())
+	if err != nil {
+		t.Fatalf("failed to trace transaction: %v", err)
+	}
+	if !reflect.DeepEqual(txTraces, traces[:2]) {
+		t.Errorf("transaction traces mismatch: have %+v, want %+v", txTraces, traces[:2])
+	}
+	// Filters match the senders and recipients of the traces, whether the
+	// blocks were indexed or not
+	first, last := rpc.BlockNumber(1), rpc.LatestBlockNumber
+	for _, indexed := range []bool{false, true} {
+		if indexed {
+			newTraceIndexer(eth).index()
+		}
+		filtered, err := api.Filter(TraceFilterArgs{FromBlock: &first, ToBlock: &last, ToAddress: []common.Address{payee}})
+		if err != nil {
+			t.Fatalf("indexed %v: failed to filter traces: %v", indexed, err)
+		}
+		if len(filtered) != 1 || !reflect.DeepEqual(filtered[0], traces[1]) {
+			t.Errorf("indexed %v: payee traces mismatch: %+v", indexed, filtered)
+		}
+		filtered, err = api.Filter(TraceFilterArgs{FromBlock: &first, ToBlock: &last, ToAddress: []common.Address{miner}})
+		if err != nil {
+			t.Fatalf("indexed %v: failed to filter traces: %v", indexed, err)
+		}
+		if len(filtered) != 3 {
+			t.Errorf("indexed %v: reward traces: have %d, want 3", indexed, len(filtered))
+		}
+		after, count := uint64(1), uint64(1)
+		filtered, err = api.Filter(TraceFilterArgs{FromBlock: &first, ToBlock: &last, ToAddress: []common.Address{miner}, After: &after, Count: &count})
+		if err != nil {
+			t.Fatalf("indexed %v: failed to filter traces: %v", indexed, err)
+		}
+		if len(filtered) != 1 || *filtered[0].BlockNumber != 2 {
+			t.Errorf("indexed %v: paginated reward traces mismatch: %+v", indexed, filtered)
+		}
+		filtered, err = api.Filter(TraceFilterArgs{FromBlock: &first, ToBlock: &last, FromAddress: []common.Address{contract}})
+		if err != nil {
+			t.Fatalf("indexed %v: failed to filter traces: %v", indexed, err)
+		}
+		if len(filtered) != 1 || filtered[0].Type != "call" {
+			t.Errorf("indexed %v: contract traces mismatch: %+v", indexed, filtered)
+		}
+	}
+	// Calls are traced on top of the state of the given block
+	result, err := api.Call(CallArgs{From: testBank.Address, Value: *rpc.NewHexNumber(10), Data: common.ToHex(code)}, []string{"trace"}, rpc.LatestBlockNumber)
+	if err != nil {
+		t.Fatalf("failed to trace call: %v", err)
+	}
+	if len(result.Trace) != 2 || result.Trace[0].Type != "create" || result.Trace[1].Type != "call" || result.Trace[1].TransactionHash != nil {
+		t.Errorf("call traces mismatch: %+v", result.Trace)
+	}
+	if _, err := api.Call(CallArgs{From: testBank.Address}, []string{"vmTrace"}, rpc.LatestBlockNumber); err == nil {
+		t.Errorf("unsupported trace type accepted")
+	}
+}
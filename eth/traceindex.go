@@ -0,0 +1,168 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ellaism/go-ellaism/core"
+	"github.com/ellaism/go-ellaism/core/types"
+	"github.com/ellaism/go-ellaism/core/vm"
+	"github.com/ellaism/go-ellaism/logger"
+	"github.com/ellaism/go-ellaism/logger/glog"
+)
+
+// traceIndexLogInterval is the time between two progress reports of the trace
+// indexer while it catches up with the chain.
+const traceIndexLogInterval = 8 * time.Second
+
+// traceIndexer traces every canonical block once in the background, as soon as
+// it's imported, and stores the trace records of its transactions, so that the
+// internal transfers of historical blocks can be queried without executing the
+// blocks again. Indexing starts from the oldest block the parent state of which
+// is available, and resumes where it stopped on restart.
+type traceIndexer struct {
+	eth   *Ethereum
+	debug *PublicDebugAPI // Replays the blocks to trace them
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+func newTraceIndexer(eth *Ethereum) *traceIndexer {
+	return &traceIndexer{
+		eth:   eth,
+		debug: NewPublicDebugAPI(eth),
+		quit:  make(chan struct{}),
+	}
+}
+
+// Start starts indexing the chain in the background.
+func (ix *traceIndexer) Start() {
+	ix.wg.Add(1)
+	go ix.loop()
+}
+
+// Stop stops the indexing, waiting for the block being indexed if any.
+func (ix *traceIndexer) Stop() {
+	close(ix.quit)
+	ix.wg.Wait()
+}
+
+// loop indexes the blocks imported so far and then those becoming the head of
+// the chain, until stopped.
+func (ix *traceIndexer) loop() {
+	defer ix.wg.Done()
+
+	sub := ix.eth.EventMux().Subscribe(core.ChainHeadEvent{})
+	defer sub.Unsubscribe()
+
+	for {
+		ix.index()
+		select {
+		case _, ok := <-sub.Chan():
+			if !ok {
+				return
+			}
+		case <-ix.quit:
+			return
+		}
+	}
+}
+
+// next returns the number of the next canonical block to index. Blocks reorged
+// into the canonical chain since they were last indexed are indexed again.
+func (ix *traceIndexer) next() uint64 {
+	db, bc := ix.eth.ChainDb(), ix.eth.BlockChain()
+
+	var number uint64
+	if header := bc.GetHeader(core.GetTraceIndexHead(db)); header != nil {
+		number = header.Number.Uint64()
+	}
+	for number > 0 {
+		if _, indexed := core.GetBlockTraces(db, core.GetCanonicalHash(db, number)); indexed {
+			break
+		}
+		number--
+	}
+	// Blocks are traced on top of the state of their parent
+	if oldest := bc.OldestState().NumberU64(); oldest > 1 && number < oldest {
+		number = oldest
+	}
+	return number + 1
+}
+
+// index indexes the canonical blocks up to the head of the chain.
+func (ix *traceIndexer) index() {
+	var (
+		db, bc  = ix.eth.ChainDb(), ix.eth.BlockChain()
+		indexed int
+		logged  = time.Now()
+	)
+	for number := ix.next(); number <= bc.CurrentBlock().NumberU64(); number++ {
+		select {
+		case <-ix.quit:
+			return
+		default:
+		}
+		block := bc.GetBlockByNumber(number)
+		if block == nil {
+			return
+		}
+		records, err := ix.trace(block)
+		if err != nil {
+			glog.V(logger.Warn).Warnf("Trace index: failed to trace block #%d [%x…]: %v", number, block.Hash().Bytes()[:4], err)
+			return
+		}
+		if err := core.WriteBlockTraces(db, block.Hash(), records); err != nil {
+			glog.V(logger.Error).Errorf("Trace index: failed to store traces of block #%d [%x…]: %v", number, block.Hash().Bytes()[:4], err)
+			return
+		}
+		if err := core.WriteTraceIndexHead(db, block.Hash()); err != nil {
+			glog.V(logger.Error).Errorf("Trace index: failed to store head #%d [%x…]: %v", number, block.Hash().Bytes()[:4], err)
+			return
+		}
+		indexed++
+		if time.Since(logged) > traceIndexLogInterval {
+			glog.V(logger.Info).Infof("Trace index: indexed %d blocks up to #%d [%x…]", indexed, number, block.Hash().Bytes()[:4])
+			indexed, logged = 0, time.Now()
+		}
+	}
+	if indexed > 0 {
+		glog.V(logger.Debug).Infof("Trace index: indexed %d blocks up to #%d", indexed, bc.CurrentBlock().NumberU64())
+	}
+}
+
+// trace replays the transactions of a block and returns the trace records of
+// their call frames.
+func (ix *traceIndexer) trace(block *types.Block) ([]*core.TraceRecord, error) {
+	traces, err := ix.debug.traceBlock(block, &TraceConfig{Tracer: "callTracer"})
+	if err != nil {
+		return nil, err
+	}
+	var records []*core.TraceRecord
+	for i, trace := range traces {
+		frame, ok := trace.(*vm.CallFrame)
+		if !ok || frame == nil {
+			return nil, fmt.Errorf("no call frame traced for tx %d", i)
+		}
+		records = append(records, core.NewTraceRecords(i, frame)...)
+	}
+	return records, nil
+}
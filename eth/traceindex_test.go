@@ -0,0 +1,111 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/core"
+	"github.com/ellaism/go-ellaism/core/types"
+	"github.com/ellaism/go-ellaism/core/vm"
+	"github.com/ellaism/go-ellaism/crypto"
+	"github.com/ellaism/go-ellaism/eth/names"
+	"github.com/ellaism/go-ellaism/ethdb"
+	"github.com/ellaism/go-ellaism/event"
+	"github.com/ellaism/go-ellaism/rpc"
+)
+
+// Tests that the trace indexer stores the call frames of the transactions of
+// every canonical block, which the transfer queries are then served from.
+func TestTraceIndexer(t *testing.T) {
+	var (
+		db, _       = ethdb.NewMemDatabase()
+		genesis     = core.WriteGenesisBlockForTesting(db, testBank)
+		chainConfig = core.MakeDiehardChainConfig()
+		signer      = types.NewChainIdSigner(chainConfig.GetChainID())
+		payee       = common.BytesToAddress([]byte{0xbb})
+		mux         = new(event.TypeMux)
+
+		// Contract creation code sending 5 wei to the payee
+		code = common.Hex2Bytes("6000600060006000600560bb5af100")
+	)
+	blockchain, err := core.NewBlockChain(db, chainConfig, new(core.FakePow), mux)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer blockchain.Stop()
+
+	chain, _ := core.GenerateChain(chainConfig, genesis, db, 3, func(i int, gen *core.BlockGen) {
+		if i == 1 {
+			tx, _ := types.NewContractCreation(gen.TxNonce(testBank.Address), big.NewInt(10), big.NewInt(100000), big.NewInt(1), code).WithSigner(signer).SignECDSA(testBankKey)
+			gen.AddTx(tx)
+		}
+	})
+	if _, err := blockchain.InsertChain(chain); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	eth := &Ethereum{chainConfig: chainConfig, blockchain: blockchain, chainDb: db, eventMux: mux}
+	indexer := newTraceIndexer(eth)
+	if next := indexer.next(); next != 1 {
+		t.Fatalf("first block to index: have #%d, want #1", next)
+	}
+	indexer.index()
+
+	if head := core.GetTraceIndexHead(db); head != chain[2].Hash() {
+		t.Errorf("index head mismatch: have %x, want %x", head, chain[2].Hash())
+	}
+	for i, block := range chain {
+		if _, indexed := core.GetBlockTraces(db, block.Hash()); !indexed {
+			t.Errorf("block #%d not indexed", i+1)
+		}
+	}
+	records, _ := core.GetBlockTraces(db, chain[1].Hash())
+	contract := crypto.CreateAddress(testBank.Address, 0)
+	if len(records) != 2 {
+		t.Fatalf("trace records: have %d, want 2", len(records))
+	}
+	if r := records[0]; r.Type != vm.CREATE || r.From != testBank.Address || r.To != contract || r.Value.Cmp(big.NewInt(10)) != 0 || len(r.Address) != 0 || r.Reverted {
+		t.Errorf("creation record mismatch: %+v", r)
+	}
+	if r := records[1]; r.Type != vm.CALL || r.From != contract || r.To != payee || r.Value.Cmp(big.NewInt(5)) != 0 || len(r.Address) != 1 || r.Address[0] != 0 || r.Reverted {
+		t.Errorf("call record mismatch: %+v", r)
+	}
+	// Blocks reorged into the chain, the traces of which are missing, are indexed again
+	for _, block := range chain[1:] {
+		db.Delete(append([]byte("traces-block-"), block.Hash().Bytes()...))
+	}
+	if next := indexer.next(); next != 2 {
+		t.Errorf("next block to index after reorg: have #%d, want #2", next)
+	}
+	indexer.index()
+	if _, indexed := core.GetBlockTraces(db, chain[1].Hash()); !indexed {
+		t.Errorf("block #2 not indexed again")
+	}
+	// The transfers are served from the index, without the state to replay
+	// the blocks on
+	db.Delete(chain[0].Root().Bytes())
+	api := NewPublicEllaAPI(eth)
+	transfers, err := api.GetTransfers(rpc.BlockNumber(2), rpc.BlockNumber(2), []names.Account{{Address: payee}})
+	if err != nil {
+		t.Fatalf("failed to retrieve transfers: %v", err)
+	}
+	if len(transfers) != 1 || transfers[0].Type != "call" || transfers[0].Depth != 1 || *transfers[0].From != contract || transfers[0].Value.BigInt().Cmp(big.NewInt(5)) != 0 {
+		t.Errorf("indexed transfers mismatch: %v", transfers)
+	}
+}
@@ -0,0 +1,229 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/core"
+	"github.com/ellaism/go-ellaism/core/types"
+	"github.com/ellaism/go-ellaism/rpc"
+)
+
+const (
+	txFinalityInterval = 2 * time.Second  // Time between two checks for dropped transactions
+	defaultTxWaitTime  = time.Minute      // Time eth_waitForTransaction waits if no timeout is given
+	maxTxWaitTime      = 10 * time.Minute // Time eth_waitForTransaction waits at most
+)
+
+// TxFinality is the status of a transaction watched by eth_waitForTransaction
+// and eth_subscribe("transactionFinality"). The status is one of:
+//   - "pending": the transaction is in the transaction pool
+//   - "mined": the transaction is in the canonical chain, with fewer confirmations than requested
+//   - "confirmed": the transaction is in the canonical chain with the requested confirmations
+//   - "replaced": another transaction of the sender with the same nonce is in the canonical chain
+//   - "dropped": the transaction is neither in the canonical chain nor in the transaction pool
+//
+// The status is final once confirmed, dropped, or replaced by a transaction
+// with the requested confirmations; until then, reorgs may change it.
+type TxFinality struct {
+	Hash          common.Hash            `json:"hash"`
+	Status        string                 `json:"status"`
+	Final         bool                   `json:"final"`
+	Confirmations uint64                 `json:"confirmations"`         // Number of canonical blocks from the one including the transaction to the head
+	BlockHash     *common.Hash           `json:"blockHash,omitempty"`   // Canonical block including the transaction, if mined
+	BlockNumber   *rpc.HexNumber         `json:"blockNumber,omitempty"` // Number of the block including the transaction, if mined
+	Receipt       map[string]interface{} `json:"receipt,omitempty"`     // Receipt of the transaction, if mined
+}
+
+// sameAs reports whether two statuses of a transaction are the same.
+func (f *TxFinality) sameAs(other *TxFinality) bool {
+	if other == nil || f.Status != other.Status || f.Final != other.Final || f.Confirmations != other.Confirmations {
+		return false
+	}
+	return (f.BlockHash == nil) == (other.BlockHash == nil) && (f.BlockHash == nil || *f.BlockHash == *other.BlockHash)
+}
+
+// watchedTx is a transaction watched for finality.
+type watchedTx struct {
+	tx            *types.Transaction
+	from          common.Address
+	confirmations uint64
+}
+
+// newWatchedTx looks up the transaction with the given hash, in the chain or the
+// transaction pool, to watch it until it has the given number of confirmations.
+func (s *PublicTransactionPoolAPI) newWatchedTx(hash common.Hash, confirmations uint64) (*watchedTx, error) {
+	tx, _, err := getTransaction(s.chainDb, s.txPool, hash)
+	if err != nil {
+		return nil, err
+	}
+	if tx == nil {
+		return nil, fmt.Errorf("transaction %x not found", hash)
+	}
+	var signer types.Signer = types.BasicSigner{}
+	if tx.Protected() {
+		signer = types.NewChainIdSigner(tx.ChainId())
+	}
+	from, err := types.Sender(signer, tx)
+	if err != nil {
+		return nil, err
+	}
+	if confirmations == 0 {
+		confirmations = 1
+	}
+	return &watchedTx{tx: tx, from: from, confirmations: confirmations}, nil
+}
+
+// txFinality returns the status of a watched transaction at the current head of
+// the chain.
+func (s *PublicTransactionPoolAPI) txFinality(w *watchedTx) (*TxFinality, error) {
+	var (
+		hash   = w.tx.Hash()
+		head   = s.bc.CurrentBlock()
+		result = &TxFinality{Hash: hash}
+	)
+	// Mined transactions are confirmed as soon as enough blocks are on top of theirs
+	if tx, blockHash, number, index := core.GetTransaction(s.chainDb, hash); tx != nil && core.GetCanonicalHash(s.chainDb, number) == blockHash && number <= head.NumberU64() {
+		result.Status = "mined"
+		result.Confirmations = head.NumberU64() - number + 1
+		result.BlockHash, result.BlockNumber = &blockHash, rpc.NewHexNumber(number)
+		if result.Confirmations >= w.confirmations {
+			result.Status, result.Final = "confirmed", true
+		}
+		if receipt := core.GetReceipt(s.chainDb, hash); receipt != nil {
+			result.Receipt = rpcOutputReceipt(receipt, tx, blockHash, number, index)
+		}
+		return result, nil
+	}
+	if s.txPool.GetTransaction(hash) != nil {
+		result.Status = "pending"
+		return result, nil
+	}
+	// Neither mined nor pending, the transaction was replaced if its nonce was
+	// used, which is final once the state enough blocks below the head agrees
+	statedb, err := s.bc.State()
+	if err != nil {
+		return nil, err
+	}
+	if statedb.GetNonce(w.from) <= w.tx.Nonce() {
+		result.Status, result.Final = "dropped", true
+		return result, nil
+	}
+	result.Status = "replaced"
+	if head.NumberU64()+1 >= w.confirmations {
+		if block := s.bc.GetBlockByNumber(head.NumberU64() + 1 - w.confirmations); block != nil {
+			if statedb, err := s.bc.StateAt(block.Root()); err == nil && statedb.GetNonce(w.from) > w.tx.Nonce() {
+				result.Final = true
+			}
+		}
+	}
+	return result, nil
+}
+
+// watchTxFinality reports the status of a watched transaction whenever it
+// changes, checking it at every new head of the chain and every
+// txFinalityInterval for dropped transactions, until the status is final, report
+// returns false or done is closed.
+func (s *PublicTransactionPoolAPI) watchTxFinality(w *watchedTx, done <-chan struct{}, report func(*TxFinality) bool) error {
+	heads := make(chan core.ChainHeadEvent, 16)
+	headSub := s.bc.SubscribeChainHeadEvent(heads)
+	defer headSub.Unsubscribe()
+
+	ticker := time.NewTicker(txFinalityInterval)
+	defer ticker.Stop()
+
+	var last *TxFinality
+	for {
+		result, err := s.txFinality(w)
+		if err != nil {
+			return err
+		}
+		if !result.sameAs(last) {
+			if !report(result) || result.Final {
+				return nil
+			}
+			last = result
+		}
+		select {
+		case <-heads:
+		case <-ticker.C:
+		case <-headSub.Err():
+			return nil
+		case <-done:
+			return nil
+		}
+	}
+}
+
+// WaitForTransaction waits until the transaction with the given hash has the
+// given number of confirmations, at least one, or is dropped or replaced by a
+// transaction with that many confirmations, and returns its status. If that
+// takes longer than the given timeout in seconds, one minute by default and ten
+// at most, the status at the timeout is returned instead, which isn't final.
+func (s *PublicTransactionPoolAPI) WaitForTransaction(ctx context.Context, hash common.Hash, confirmations uint64, timeout *uint64) (*TxFinality, error) {
+	w, err := s.newWatchedTx(hash, confirmations)
+	if err != nil {
+		return nil, err
+	}
+	wait := defaultTxWaitTime
+	if timeout != nil {
+		wait = time.Duration(*timeout) * time.Second
+	}
+	if wait > maxTxWaitTime {
+		wait = maxTxWaitTime
+	}
+	ctx, cancel := context.WithTimeout(ctx, wait)
+	defer cancel()
+
+	var last *TxFinality
+	err = s.watchTxFinality(w, ctx.Done(), func(result *TxFinality) bool {
+		last = result
+		return true
+	})
+	return last, err
+}
+
+// TransactionFinality creates a subscription notifying of the status of the
+// transaction with the given hash whenever it changes, until it has the given
+// number of confirmations, at least one, or is dropped or replaced by a
+// transaction with that many confirmations. Reorgs moving the transaction to
+// another block or back to the transaction pool are notified as they happen.
+func (s *PublicTransactionPoolAPI) TransactionFinality(ctx context.Context, hash common.Hash, confirmations uint64) (rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return nil, rpc.ErrNotificationsUnsupported
+	}
+	w, err := s.newWatchedTx(hash, confirmations)
+	if err != nil {
+		return nil, err
+	}
+	done := make(chan struct{})
+	subscription, err := notifier.NewSubscription(func(string) {
+		close(done)
+	})
+	if err != nil {
+		return nil, err
+	}
+	go s.watchTxFinality(w, done, func(result *TxFinality) bool {
+		return subscription.Notify(result) != rpc.ErrNotificationNotFound
+	})
+	return subscription, nil
+}
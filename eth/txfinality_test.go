@@ -0,0 +1,123 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/core"
+	"github.com/ellaism/go-ellaism/core/types"
+	"github.com/ellaism/go-ellaism/ethdb"
+	"github.com/ellaism/go-ellaism/event"
+)
+
+// Tests that the status of a watched transaction follows it from the pool into
+// the chain and out of it again on reorgs.
+func TestTxFinality(t *testing.T) {
+	var (
+		db, _       = ethdb.NewMemDatabase()
+		genesis     = core.WriteGenesisBlockForTesting(db, testBank)
+		chainConfig = core.MakeDiehardChainConfig()
+		signer      = types.NewChainIdSigner(chainConfig.GetChainID())
+	)
+	blockchain, err := core.NewBlockChain(db, chainConfig, new(core.FakePow), new(event.TypeMux))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer blockchain.Stop()
+
+	// The pool isn't notified of the chain, so its content is up to the test
+	config := core.DefaultTxPoolConfig
+	config.Journal = ""
+	pool := core.NewTxPool(chainConfig, config, new(event.TypeMux), blockchain.State, func() *big.Int { return genesis.GasLimit() }, blockchain.CurrentBlock().Number())
+	defer pool.Stop()
+	api := &PublicTransactionPoolAPI{chainDb: db, bc: blockchain, txPool: pool}
+
+	transfer := func(value int64) *types.Transaction {
+		tx, _ := types.NewTransaction(0, common.Address{0x01}, big.NewInt(value), big.NewInt(21000), big.NewInt(1), nil).WithSigner(signer).SignECDSA(testBankKey)
+		return tx
+	}
+	watched, replacement := transfer(1), transfer(2)
+
+	check := func(stage string, w *watchedTx, status string, final bool, confirmations uint64) {
+		result, err := api.txFinality(w)
+		if err != nil {
+			t.Fatalf("%s: failed to get status: %v", stage, err)
+		}
+		if result.Status != status || result.Final != final || result.Confirmations != confirmations {
+			t.Errorf("%s: status mismatch: have %s (final %v, %d confirmations), want %s (final %v, %d confirmations)", stage, result.Status, result.Final, result.Confirmations, status, final, confirmations)
+		}
+		if mined := result.Status == "mined" || result.Status == "confirmed"; mined != (result.Receipt != nil) {
+			t.Errorf("%s: receipt mismatch: %v", stage, result.Receipt)
+		}
+	}
+	if _, err := api.newWatchedTx(watched.Hash(), 3); err == nil {
+		t.Fatalf("unknown transaction watched")
+	}
+	if err := pool.AddLocal(watched); err != nil {
+		t.Fatal(err)
+	}
+	w, err := api.newWatchedTx(watched.Hash(), 3)
+	if err != nil {
+		t.Fatalf("failed to watch transaction: %v", err)
+	}
+	check("pool", w, "pending", false, 0)
+
+	result, err := api.WaitForTransaction(context.Background(), watched.Hash(), 3, new(uint64))
+	if err != nil || result.Status != "pending" {
+		t.Errorf("wait timed out with %+v, %v; want pending status", result, err)
+	}
+	pool.RemoveTx(watched.Hash())
+	check("removed", w, "dropped", true, 0)
+
+	// Mine the transaction and confirm it
+	chain, _ := core.GenerateChain(chainConfig, genesis, db, 3, func(i int, gen *core.BlockGen) {
+		if i == 0 {
+			gen.AddTx(watched)
+		}
+	})
+	if _, err := blockchain.InsertChain(chain[:2]); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	check("mined", w, "mined", false, 2)
+	if _, err := blockchain.InsertChain(chain[2:]); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	check("confirmed", w, "confirmed", true, 3)
+
+	// Reorg to a chain where the nonce is used by another transaction
+	fork, _ := core.GenerateChain(chainConfig, genesis, db, 6, func(i int, gen *core.BlockGen) {
+		gen.SetCoinbase(common.Address{0x02})
+		if i == 3 {
+			gen.AddTx(replacement)
+		}
+	})
+	if _, err := blockchain.InsertChain(fork[:4]); err != nil {
+		t.Fatalf("failed to insert fork: %v", err)
+	}
+	if blockchain.CurrentBlock().Hash() != fork[3].Hash() {
+		t.Fatalf("fork not canonical")
+	}
+	check("replacing", w, "replaced", false, 0)
+	if _, err := blockchain.InsertChain(fork[4:]); err != nil {
+		t.Fatalf("failed to insert fork: %v", err)
+	}
+	check("replaced", w, "replaced", true, 0)
+}
@@ -0,0 +1,95 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/core/types"
+)
+
+const (
+	txAnnounceSize = 4 * 1024        // Transactions larger than this are announced to eth/65 peers instead of sent
+	txFetchTimeout = 5 * time.Second // Time after which an unanswered transaction request may be retried
+	maxTxFetch     = 256             // Maximum number of transactions to request or serve in one message
+	maxTxRequests  = 4096            // Maximum number of transaction requests to track before ignoring announcements
+	maxTxAnnounce  = 4096            // Maximum number of transaction hashes to announce in one message
+)
+
+// txRequest is an announced transaction requested from a peer.
+type txRequest struct {
+	peer string    // Peer the transaction was requested from
+	time time.Time // Time the transaction was requested
+}
+
+// txRequests tracks the announced transactions requested from peers, so that
+// a transaction announced by several peers is only pulled from one of them,
+// and only the transactions requested from a peer are accepted from it.
+type txRequests struct {
+	pending map[common.Hash]txRequest // In-flight transaction requests
+	lock    sync.Mutex
+}
+
+func newTxRequests() *txRequests {
+	return &txRequests{pending: make(map[common.Hash]txRequest)}
+}
+
+// schedule returns the hashes which aren't already being fetched, or whose
+// request timed out, and marks them as being fetched from the given peer.
+// Hashes over the tracking allowance are dropped.
+func (r *txRequests) schedule(peer string, hashes []common.Hash) []common.Hash {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	now := time.Now()
+	for hash, req := range r.pending {
+		if now.Sub(req.time) > txFetchTimeout {
+			delete(r.pending, hash)
+		}
+	}
+	var fetch []common.Hash
+	for _, hash := range hashes {
+		if len(r.pending) >= maxTxRequests {
+			break
+		}
+		if _, ok := r.pending[hash]; ok {
+			continue
+		}
+		r.pending[hash] = txRequest{peer: peer, time: now}
+		fetch = append(fetch, hash)
+	}
+	return fetch
+}
+
+// deliver marks the transactions delivered by a peer as no longer being
+// fetched, and returns the ones which were requested from it.
+func (r *txRequests) deliver(peer string, txs []*types.Transaction) []*types.Transaction {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	var requested []*types.Transaction
+	for _, tx := range txs {
+		hash := tx.Hash()
+		if req, ok := r.pending[hash]; ok && req.peer == peer {
+			delete(r.pending, hash)
+			requested = append(requested, tx)
+		}
+	}
+	return requested
+}
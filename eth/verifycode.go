@@ -0,0 +1,161 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/crypto"
+	"github.com/ellaism/go-ellaism/eth/names"
+	"github.com/ellaism/go-ellaism/rpc"
+)
+
+// metadataKeys are the CBOR encoded keys of the content hashes the Solidity
+// compiler appends to the runtime code of contracts, one of which identifies
+// the metadata trailer.
+var metadataKeys = [][]byte{
+	append([]byte{0x64}, "ipfs"...),
+	append([]byte{0x65}, "bzzr0"...),
+	append([]byte{0x65}, "bzzr1"...),
+}
+
+// solcKey is the CBOR encoded key of the compiler version in the metadata
+// trailer, followed by the 3 byte version.
+var solcKey = append([]byte{0x64}, "solc"...)
+
+// CodeVerification is the result of ella_verifyCode. The status is one of:
+//   - "exact": the code at the address is the given code
+//   - "metadata": the codes only differ in their metadata trailers, which hash the
+//     sources and compiler settings but don't change how the contract executes
+//   - "mismatch": the codes differ
+//   - "noCode": no code is deployed at the address
+type CodeVerification struct {
+	Address         common.Address `json:"address"`
+	BlockNumber     *rpc.HexNumber `json:"blockNumber"`
+	Status          string         `json:"status"`
+	Match           bool           `json:"match"`                   // Whether the status is exact or metadata
+	CodeHash        common.Hash    `json:"codeHash"`                // Hash of the code at the address
+	Size            int            `json:"size"`                    // Size of the code at the address
+	Metadata        string         `json:"metadata,omitempty"`      // Metadata trailer of the code at the address
+	Compiler        string         `json:"compiler,omitempty"`      // Solidity version in the metadata trailer of the code at the address
+	GivenSize       int            `json:"givenSize"`               // Size of the given code
+	GivenMetadata   string         `json:"givenMetadata,omitempty"` // Metadata trailer of the given code
+	FirstDifference *int           `json:"firstDifference"`         // Offset of the first differing byte of the codes without their metadata, if any
+}
+
+// VerifyCode compares the runtime code deployed at the given address, as of the
+// given block or the latest by default, with the given compiled runtime code,
+// and reports whether they match, ignoring the metadata trailers the Solidity
+// compiler appends. Contracts with immutable variables or linked libraries
+// only match if those are filled in the given code as they were on deployment.
+func (s *PublicEllaAPI) VerifyCode(account names.Account, code string, blockNr *rpc.BlockNumber) (*CodeVerification, error) {
+	address, err := account.Resolve(s.names)
+	if err != nil {
+		return nil, err
+	}
+	given := common.FromHex(code)
+	if len(given) == 0 {
+		return nil, errors.New("empty code")
+	}
+	number := rpc.LatestBlockNumber
+	if blockNr != nil {
+		number = *blockNr
+	}
+	statedb, block, err := stateAndBlockByNumber(s.miner, s.bc, number, s.chainDb)
+	if statedb == nil || err != nil {
+		return nil, err
+	}
+	result := verifyCode(statedb.GetCode(address), given)
+	result.Address, result.BlockNumber = address, rpc.NewHexNumber(block.Number())
+	return result, nil
+}
+
+// verifyCode compares deployed runtime code with the given runtime code.
+func verifyCode(deployed, given []byte) *CodeVerification {
+	result := &CodeVerification{
+		CodeHash:  crypto.Keccak256Hash(deployed),
+		Size:      len(deployed),
+		GivenSize: len(given),
+	}
+	deployedBody, deployedMetadata := splitMetadata(deployed)
+	givenBody, givenMetadata := splitMetadata(given)
+	if len(deployedMetadata) > 0 {
+		result.Metadata = common.ToHex(deployedMetadata)
+		result.Compiler = compilerVersion(deployedMetadata)
+	}
+	if len(givenMetadata) > 0 {
+		result.GivenMetadata = common.ToHex(givenMetadata)
+	}
+	switch {
+	case len(deployed) == 0:
+		result.Status = "noCode"
+	case bytes.Equal(deployed, given):
+		result.Status, result.Match = "exact", true
+	case bytes.Equal(deployedBody, givenBody):
+		result.Status, result.Match = "metadata", true
+	default:
+		result.Status = "mismatch"
+		offset := 0
+		for offset < len(deployedBody) && offset < len(givenBody) && deployedBody[offset] == givenBody[offset] {
+			offset++
+		}
+		result.FirstDifference = &offset
+	}
+	return result
+}
+
+// splitMetadata splits runtime code into its body and the metadata trailer
+// appended by the Solidity compiler, if any: a CBOR map holding a content hash,
+// followed by its length in 2 bytes.
+func splitMetadata(code []byte) ([]byte, []byte) {
+	if len(code) < 2 {
+		return code, nil
+	}
+	size := int(code[len(code)-2])<<8 | int(code[len(code)-1])
+	start := len(code) - 2 - size
+	if size == 0 || start < 0 {
+		return code, nil
+	}
+	// The trailer is a CBOR map of up to 23 entries
+	metadata := code[start:]
+	if metadata[0] < 0xa1 || metadata[0] > 0xb7 {
+		return code, nil
+	}
+	for _, key := range metadataKeys {
+		if bytes.Contains(metadata[:size], key) {
+			return code[:start], metadata
+		}
+	}
+	return code, nil
+}
+
+// compilerVersion returns the Solidity version held by a metadata trailer, or
+// an empty string if it has none.
+func compilerVersion(metadata []byte) string {
+	index := bytes.Index(metadata, solcKey)
+	if index < 0 || len(metadata) < index+len(solcKey)+4 {
+		return ""
+	}
+	version := metadata[index+len(solcKey):]
+	if version[0] != 0x43 { // Release versions are 3 bytes, prereleases strings
+		return ""
+	}
+	return fmt.Sprintf("%d.%d.%d", version[1], version[2], version[3])
+}
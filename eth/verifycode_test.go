@@ -0,0 +1,102 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/core"
+	"github.com/ellaism/go-ellaism/core/types"
+	"github.com/ellaism/go-ellaism/crypto"
+	"github.com/ellaism/go-ellaism/eth/names"
+	"github.com/ellaism/go-ellaism/ethdb"
+	"github.com/ellaism/go-ellaism/event"
+	"github.com/ellaism/go-ellaism/rpc"
+)
+
+// withMetadata appends a Solidity metadata trailer holding the given content
+// hash byte and compiler version 0.8.4 to the given code.
+func withMetadata(body []byte, hash byte) []byte {
+	metadata := []byte{0xa2, 0x64, 'i', 'p', 'f', 's', 0x58, 0x22}
+	metadata = append(metadata, bytes.Repeat([]byte{hash}, 34)...)
+	metadata = append(metadata, 0x64, 's', 'o', 'l', 'c', 0x43, 0x00, 0x08, 0x04)
+	code := append(append([]byte{}, body...), metadata...)
+	return append(code, byte(len(metadata)>>8), byte(len(metadata)))
+}
+
+// Tests that deployed code is verified against compiled code regardless of the
+// metadata trailers, and that differing code is reported.
+func TestVerifyCode(t *testing.T) {
+	var (
+		db, _       = ethdb.NewMemDatabase()
+		genesis     = core.WriteGenesisBlockForTesting(db, testBank)
+		chainConfig = core.MakeDiehardChainConfig()
+		signer      = types.NewChainIdSigner(chainConfig.GetChainID())
+		body        = common.FromHex("0x6001600055")
+		runtime     = withMetadata(body, 0x01)
+	)
+	blockchain, err := core.NewBlockChain(db, chainConfig, new(core.FakePow), new(event.TypeMux))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer blockchain.Stop()
+
+	// Deploy the runtime code with init code copying it to memory and returning it
+	init := append([]byte{0x60, byte(len(runtime)), 0x80, 0x60, 0x0b, 0x60, 0x00, 0x39, 0x60, 0x00, 0xf3}, runtime...)
+	chain, _ := core.GenerateChain(chainConfig, genesis, db, 1, func(i int, gen *core.BlockGen) {
+		tx, _ := types.NewContractCreation(gen.TxNonce(testBank.Address), new(big.Int), big.NewInt(200000), big.NewInt(1), init).WithSigner(signer).SignECDSA(testBankKey)
+		gen.AddTx(tx)
+	})
+	if _, err := blockchain.InsertChain(chain); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	contract := crypto.CreateAddress(testBank.Address, 0)
+	api := NewPublicEllaAPI(&Ethereum{chainConfig: chainConfig, blockchain: blockchain, chainDb: db})
+
+	genesisNr := rpc.BlockNumber(0)
+	tests := []struct {
+		code    []byte
+		blockNr *rpc.BlockNumber
+		status  string
+		match   bool
+		diff    int
+	}{
+		{runtime, nil, "exact", true, -1},
+		{withMetadata(body, 0x02), nil, "metadata", true, -1},
+		{body, nil, "metadata", true, -1},
+		{withMetadata(common.FromHex("0x6001600155"), 0x01), nil, "mismatch", false, 3},
+		{runtime, &genesisNr, "noCode", false, -1},
+	}
+	for i, test := range tests {
+		result, err := api.VerifyCode(names.Account{Address: contract}, common.ToHex(test.code), test.blockNr)
+		if err != nil {
+			t.Fatalf("test %d: failed to verify code: %v", i, err)
+		}
+		if result.Status != test.status || result.Match != test.match {
+			t.Errorf("test %d: status mismatch: have %s (match %v), want %s (match %v)", i, result.Status, result.Match, test.status, test.match)
+		}
+		if test.diff >= 0 && (result.FirstDifference == nil || *result.FirstDifference != test.diff) {
+			t.Errorf("test %d: first difference mismatch: have %v, want %d", i, result.FirstDifference, test.diff)
+		}
+		if test.status != "noCode" && (result.Size != len(runtime) || result.Compiler != "0.8.4" || result.Metadata == "") {
+			t.Errorf("test %d: deployed code mismatch: size %d, compiler %q, metadata %q", i, result.Size, result.Compiler, result.Metadata)
+		}
+	}
+}
@@ -3944,6 +3944,18 @@ var outputPostFormatter = function(post){
     return post;
 };
 
+/**
+ * Checks whether the given string is a name, such as wallet.ella, to be resolved
+ * into an address by the node
+ *
+ * @method isName
+ * @param {String} name
+ * @returns {Boolean}
+ */
+var isName = function (name) {
+    return typeof name === 'string' && /^[^.\s]+(\.[^.\s]+)+$/.test(name);
+};
+
 var inputAddressFormatter = function (address) {
     var iban = new Iban(address);
     if (iban.isValid() && iban.isDirect()) {
@@ -3952,6 +3964,9 @@ var inputAddressFormatter = function (address) {
         return address;
     } else if (utils.isAddress(address)) {
         return '0x' + address;
+    } else if (isName(address)) {
+        // names are resolved into addresses by the node
+        return address;
     }
     throw new Error('invalid address');
 };
@@ -19,13 +19,16 @@ package web3ext
 
 var Modules = map[string]string{
 	"admin":    Admin_JS,
+	"clique":   Clique_JS,
 	"debug":    Debug_JS,
+	"ella":     Ella_JS,
 	"eth":      Eth_JS,
 	"miner":    Miner_JS,
 	"net":      Net_JS,
 	"personal": Personal_JS,
 	"rpc":      RPC_JS,
 	"shh":      Shh_JS,
+	"trace":    Trace_JS,
 	"txpool":   TxPool_JS,
 }
 
@@ -144,6 +147,58 @@ web3._extend({
 		new web3._extend.Property({
 			name: 'datadir',
 			getter: 'admin_datadir'
+		}),
+		new web3._extend.Property({
+			name: 'chainParams',
+			getter: 'admin_chainParams'
+		})
+	]
+});
+`
+
+const Clique_JS = `
+web3._extend({
+	property: 'clique',
+	methods:
+	[
+		new web3._extend.Method({
+			name: 'getSnapshot',
+			call: 'clique_getSnapshot',
+			params: 1,
+			inputFormatter: [null]
+		}),
+		new web3._extend.Method({
+			name: 'getSnapshotAtHash',
+			call: 'clique_getSnapshotAtHash',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'getSigners',
+			call: 'clique_getSigners',
+			params: 1,
+			inputFormatter: [null]
+		}),
+		new web3._extend.Method({
+			name: 'getSignersAtHash',
+			call: 'clique_getSignersAtHash',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'propose',
+			call: 'clique_propose',
+			params: 2
+		}),
+		new web3._extend.Method({
+			name: 'discard',
+			call: 'clique_discard',
+			params: 1
+		})
+	],
+	properties:
+	[
+		new web3._extend.Property({
+			name: 'proposals',
+			getter: 'clique_proposals'
 		})
 	]
 });
@@ -197,11 +252,45 @@ web3._extend({
 			params: 1,
 			inputFormatter: [web3._extend.formatters.inputOptionalStringFormatter]
 		}),
+		new web3._extend.Method({
+			name: 'setCacheSize',
+			call: 'debug_setCacheSize',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputOptionalNumberFormatter]
+		}),
 		new web3._extend.Method({
 			name: 'traceTransaction',
 			call: 'debug_traceTransaction',
+			params: 2,
+			inputFormatter: [null, null]
+		}),
+		new web3._extend.Method({
+			name: 'traceBlockByNumber',
+			call: 'debug_traceBlockByNumber',
+			params: 2,
+			inputFormatter: [null, null]
+		}),
+		new web3._extend.Method({
+			name: 'replayBlock',
+			call: 'debug_replayBlock',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'availableStates',
+			call: 'debug_availableStates',
+			params: 0
+		}),
+		new web3._extend.Method({
+			name: 'getRevertReason',
+			call: 'debug_getRevertReason',
 			params: 1
 		}),
+		new web3._extend.Method({
+			name: 'traceCall',
+			call: 'debug_traceCall',
+			params: 3,
+			inputFormatter: [web3._extend.formatters.inputCallFormatter, web3._extend.formatters.inputDefaultBlockNumberFormatter, null]
+		}),
 		new web3._extend.Method({
 			name: 'accountExist',
 			call: 'debug_accountExist',
@@ -212,6 +301,55 @@ web3._extend({
 });
 `
 
+const Ella_JS = `
+web3._extend({
+	property: 'ella',
+	methods:
+	[
+		new web3._extend.Method({
+			name: 'getBlockReward',
+			call: 'ella_getBlockReward',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'getBlockRewardByHash',
+			call: 'ella_getBlockRewardByHash',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'resolveName',
+			call: 'ella_resolveName',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'getBlockTransactions',
+			call: 'ella_getBlockTransactions',
+			params: 4,
+			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter, web3._extend.utils.fromDecimal, web3._extend.utils.fromDecimal, null]
+		}),
+		new web3._extend.Method({
+			name: 'getUncleByHash',
+			call: 'ella_getUncleByHash',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'getTransfers',
+			call: 'ella_getTransfers',
+			params: 3,
+			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter, web3._extend.formatters.inputBlockNumberFormatter, null]
+		}),
+		new web3._extend.Method({
+			name: 'verifyCode',
+			call: 'ella_verifyCode',
+			params: 3,
+			inputFormatter: [null, null, web3._extend.formatters.inputDefaultBlockNumberFormatter]
+		})
+	],
+	properties: []
+});
+`
+
 const Eth_JS = `
 web3._extend({
 	property: 'eth',
@@ -251,6 +389,71 @@ web3._extend({
 			name: 'chainId',
 			call: 'eth_chainId',
 			params: 0
+		}),
+		new web3._extend.Method({
+			name: 'createAccessList',
+			call: 'eth_createAccessList',
+			params: 2,
+			inputFormatter: [web3._extend.formatters.inputCallFormatter, web3._extend.formatters.inputDefaultBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'simulateTransaction',
+			call: 'eth_simulateTransaction',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputTransactionFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'simulateRawTransaction',
+			call: 'eth_simulateRawTransaction',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'sendRawTransactionBatch',
+			call: 'eth_sendRawTransactionBatch',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'deployContract',
+			call: 'eth_deployContract',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'waitForTransaction',
+			call: 'eth_waitForTransaction',
+			params: 3
+		}),
+		new web3._extend.Method({
+			name: 'multicall',
+			call: 'eth_multicall',
+			params: 2,
+			inputFormatter: [null, web3._extend.formatters.inputDefaultBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'getBlockReceipts',
+			call: 'eth_getBlockReceipts',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'getProof',
+			call: 'eth_getProof',
+			params: 3,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, null, web3._extend.formatters.inputDefaultBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'getHeaderByNumber',
+			call: 'eth_getHeaderByNumber',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'getHeaderByHash',
+			call: 'eth_getHeaderByHash',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'getTotalDifficulty',
+			call: 'eth_getTotalDifficulty',
+			params: 1
 		})
 	],
 	properties:
@@ -353,6 +556,24 @@ web3._extend({
 			call: 'personal_signAndSendTransaction',
 			params: 2,
 			inputFormatter: [web3._extend.formatters.inputTransactionFormatter, null]
+		}),
+		new web3._extend.Method({
+			name: 'openWallet',
+			call: 'personal_openWallet',
+			params: 2,
+			inputFormatter: [null, null]
+		}),
+		new web3._extend.Method({
+			name: 'closeWallet',
+			call: 'personal_closeWallet',
+			params: 1
+		})
+	],
+	properties:
+	[
+		new web3._extend.Property({
+			name: 'listWallets',
+			getter: 'personal_listWallets'
 		})
 	]
 });
@@ -387,10 +608,54 @@ web3._extend({
 });
 `
 
+const Trace_JS = `
+web3._extend({
+	property: 'trace',
+	methods:
+	[
+		new web3._extend.Method({
+			name: 'block',
+			call: 'trace_block',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'transaction',
+			call: 'trace_transaction',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'filter',
+			call: 'trace_filter',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'call',
+			call: 'trace_call',
+			params: 3,
+			inputFormatter: [web3._extend.formatters.inputCallFormatter, null, web3._extend.formatters.inputDefaultBlockNumberFormatter]
+		})
+	]
+});
+`
+
 const TxPool_JS = `
 web3._extend({
 	property: 'txpool',
-	methods: [],
+	methods:
+	[
+		new web3._extend.Method({
+			name: 'nextNonce',
+			call: 'txpool_nextNonce',
+			params: 1,
+			outputFormatter: web3._extend.utils.toDecimal
+		}),
+		new web3._extend.Method({
+			name: 'droppedSince',
+			call: 'txpool_droppedSince',
+			params: 1
+		})
+	],
 	properties:
 	[
 		new web3._extend.Property({
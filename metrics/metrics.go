@@ -112,11 +112,22 @@ var (
 	FetchBroadcastDOS   = metrics.NewRegisteredMeter("fetch/broadcast/dos", reg)
 )
 
+var (
+	ChainCommitTimer = metrics.NewRegisteredTimer("chain/commit", reg)
+)
+
+var (
+	EVMCallDepthLimit = metrics.NewRegisteredMeter("evm/limit/calldepth", reg) // Frames of the processed blocks aborted by the call depth limit
+	EVMStackLimit     = metrics.NewRegisteredMeter("evm/limit/stack", reg)     // Frames of the processed blocks aborted by the stack limit
+)
+
 var (
 	P2PIn       = metrics.NewRegisteredMeter("p2p/in", reg)
 	P2PInBytes  = metrics.NewRegisteredMeter("p2p/in/bytes", reg)
 	P2POut      = metrics.NewRegisteredMeter("p2p/out", reg)
 	P2POutBytes = metrics.NewRegisteredMeter("p2p/out/bytes", reg)
+
+	P2PClockDrift = metrics.GetOrRegisterGauge("p2p/clock/drift", reg) // Milliseconds the local clock was last measured ahead of NTP time
 )
 
 var (
@@ -128,6 +139,29 @@ var (
 	NumGoRoutines = metrics.GetOrRegisterGauge("runtime/goroutines", reg)
 )
 
+// MarkOpcode records the executions of an EVM opcode within a block. Their
+// count and gas used are added to meters, while the average time taken per
+// execution and per unit of gas are added to histograms, so that the time an
+// opcode takes on this hardware can be compared with its gas price.
+func MarkOpcode(name string, count, gas uint64, elapsed time.Duration) {
+	if count == 0 {
+		return
+	}
+	prefix := "evm/opcode/" + name
+	metrics.GetOrRegisterMeter(prefix+"/count", reg).Mark(int64(count))
+	metrics.GetOrRegisterMeter(prefix+"/gas", reg).Mark(int64(gas))
+	reg.GetOrRegister(prefix+"/time", newOpcodeHistogram).(metrics.Histogram).Update(int64(elapsed) / int64(count))
+	if gas > 0 {
+		reg.GetOrRegister(prefix+"/timepergas", newOpcodeHistogram).(metrics.Histogram).Update(int64(elapsed) / int64(gas))
+	}
+}
+
+// newOpcodeHistogram creates a histogram of opcode timings in nanoseconds,
+// biased towards the recently processed blocks.
+func newOpcodeHistogram() metrics.Histogram {
+	return metrics.NewHistogram(metrics.NewExpDecaySample(1028, 0.015))
+}
+
 // diskStats is the per process disk I/O statistics.
 type diskStats struct {
 	ReadCount  int64 // Number of read operations executed
@@ -21,10 +21,9 @@ import (
 
 	"sync/atomic"
 
-	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/consensus"
 	"github.com/ellaism/go-ellaism/logger"
 	"github.com/ellaism/go-ellaism/logger/glog"
-	"github.com/ellaism/go-ellaism/pow"
 )
 
 type CpuAgent struct {
@@ -35,23 +34,25 @@ type CpuAgent struct {
 	quitCurrentOp chan struct{}
 	returnCh      chan<- *Result
 
-	index int
-	pow   pow.PoW
+	index  int
+	engine consensus.Engine
+	chain  consensus.ChainReader
 
 	isMining int32 // isMining indicates whether the agent is currently mining
 }
 
-func NewCpuAgent(index int, pow pow.PoW) *CpuAgent {
+func NewCpuAgent(index int, engine consensus.Engine, chain consensus.ChainReader) *CpuAgent {
 	miner := &CpuAgent{
-		pow:   pow,
-		index: index,
+		engine: engine,
+		chain:  chain,
+		index:  index,
 	}
 
 	return miner
 }
 
 func (self *CpuAgent) Work() chan<- *Work            { return self.workCh }
-func (self *CpuAgent) Pow() pow.PoW                  { return self.pow }
+func (self *CpuAgent) Engine() consensus.Engine      { return self.engine }
 func (self *CpuAgent) SetReturnCh(ch chan<- *Result) { self.returnCh = ch }
 
 func (self *CpuAgent) Stop() {
@@ -118,9 +119,11 @@ func (self *CpuAgent) mine(work *Work, stop <-chan struct{}) {
 	glog.V(logger.Debug).Infof("(re)started agent[%d]. mining...\n", self.index)
 
 	// Mine
-	nonce, mixDigest := self.pow.Search(work.Block, stop, self.index)
-	if nonce != 0 {
-		block := work.Block.WithMiningResult(nonce, common.BytesToHash(mixDigest))
+	block, err := self.engine.Seal(self.chain, work.Block, stop)
+	if err != nil {
+		glog.V(logger.Warn).Infof("Block sealing failed: %v", err)
+	}
+	if block != nil {
 		self.returnCh <- &Result{work, block}
 	} else {
 		self.returnCh <- nil
@@ -128,5 +131,8 @@ func (self *CpuAgent) mine(work *Work, stop <-chan struct{}) {
 }
 
 func (self *CpuAgent) GetHashRate() int64 {
-	return self.pow.GetHashrate()
+	if pow, ok := self.engine.(consensus.PoW); ok {
+		return pow.Hashrate()
+	}
+	return 0
 }
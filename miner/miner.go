@@ -23,6 +23,7 @@ import (
 	"sync/atomic"
 
 	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/consensus"
 	"github.com/ellaism/go-ellaism/core"
 	"github.com/ellaism/go-ellaism/core/state"
 	"github.com/ellaism/go-ellaism/core/types"
@@ -30,7 +31,6 @@ import (
 	"github.com/ellaism/go-ellaism/event"
 	"github.com/ellaism/go-ellaism/logger"
 	"github.com/ellaism/go-ellaism/logger/glog"
-	"github.com/ellaism/go-ellaism/pow"
 )
 
 // HeaderExtra is a freeform description.
@@ -47,14 +47,14 @@ type Miner struct {
 	coinbase common.Address
 	mining   int32
 	eth      core.Backend
-	pow      pow.PoW
+	engine   consensus.Engine // Engine sealing the mined blocks
 
 	canStart    int32 // can start indicates whether we can start the mining operation
 	shouldStart int32 // should start indicates whether we should start after sync
 }
 
-func New(eth core.Backend, config *core.ChainConfig, mux *event.TypeMux, pow pow.PoW) *Miner {
-	miner := &Miner{eth: eth, mux: mux, pow: pow, worker: newWorker(config, common.Address{}, eth), canStart: 1}
+func New(eth core.Backend, config *core.ChainConfig, mux *event.TypeMux, engine consensus.Engine) *Miner {
+	miner := &Miner{eth: eth, mux: mux, engine: engine, worker: newWorker(config, common.Address{}, eth), canStart: 1}
 	go miner.update()
 
 	return miner
@@ -122,7 +122,7 @@ func (self *Miner) Start(coinbase common.Address, threads int) {
 	atomic.StoreInt32(&self.mining, 1)
 
 	for i := 0; i < threads; i++ {
-		self.worker.register(NewCpuAgent(i, self.pow))
+		self.worker.register(NewCpuAgent(i, self.engine, self.eth.BlockChain()))
 	}
 
 	mlogMiner.Send(mlogMinerStart.SetDetailValues(
@@ -164,7 +164,9 @@ func (self *Miner) Mining() bool {
 }
 
 func (self *Miner) HashRate() (tot int64) {
-	tot += self.pow.GetHashrate()
+	if pow, ok := self.engine.(consensus.PoW); ok {
+		tot += pow.Hashrate()
+	}
 	// do we care this might race? is it worth we're rewriting some
 	// aspects of the worker/locking up agents so we can get an accurate
 	// hashrate?
@@ -17,7 +17,6 @@
 package miner
 
 import (
-	"fmt"
 	"log"
 	"math/big"
 	"sync"
@@ -26,6 +25,7 @@ import (
 
 	"github.com/ellaism/go-ellaism/accounts"
 	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/consensus"
 	"github.com/ellaism/go-ellaism/core"
 	"github.com/ellaism/go-ellaism/core/state"
 	"github.com/ellaism/go-ellaism/core/types"
@@ -61,12 +61,10 @@ type uint64RingBuffer struct {
 type Work struct {
 	config             *core.ChainConfig
 	signer             types.Signer
-	state              *state.StateDB // apply state changes here
-	ancestors          *set.Set       // ancestor set (used for checking uncle parent validity)
-	family             *set.Set       // family set (used for checking uncle invalidity)
-	uncles             *set.Set       // uncle set
-	remove             *set.Set       // tx which will be removed
-	tcount             int            // tx count in cycle
+	state              *state.StateDB    // apply state changes here
+	family             *core.UncleFamily // family of the block (used for checking uncle validity)
+	remove             *set.Set          // tx which will be removed
+	tcount             int               // tx count in cycle
 	ignoredTransactors *set.Set
 	lowGasTransactors  *set.Set
 	ownedAccounts      *set.Set
@@ -95,7 +93,7 @@ type worker struct {
 
 	// update loop
 	mux    *event.TypeMux
-	events event.Subscription
+	events *event.TypeMuxSubscription
 	wg     sync.WaitGroup
 
 	agents map[Agent]struct{}
@@ -259,13 +257,18 @@ func (self *worker) wait() {
 			}
 			block := result.Block
 			work := result.Work
+			mined := core.NewMinedBlockEvent{
+				Block:  block,
+				Reward: core.CalcBlockReward(self.config, block.Header(), block.Uncles()),
+				Fees:   core.BlockFees(block, work.receipts),
+			}
 
 			if self.fullValidation {
 				if _, err := self.chain.InsertChain(types.Blocks{block}); err != nil {
 					log.Println("mine: ignoring invalid block #%d (%x) received:", block.Number(), block.Hash(), err)
 					continue
 				}
-				go self.mux.Post(core.NewMinedBlockEvent{Block: block})
+				go self.mux.Post(mined)
 			} else {
 				work.state.Commit()
 				parent := self.chain.GetBlock(block.ParentHash())
@@ -274,8 +277,7 @@ func (self *worker) wait() {
 					continue
 				}
 
-				auxValidator := self.eth.BlockChain().AuxValidator()
-				if err := core.ValidateHeader(self.config, auxValidator, block.Header(), parent.Header(), true, false); err != nil && err != core.BlockFutureErr {
+				if err := core.ValidateHeader(self.config, self.chain.Engine(), self.chain, block.Header(), parent.Header(), true, false); err != nil && err != core.BlockFutureErr {
 					glog.V(logger.Error).Infoln("Invalid header on mined block:", err)
 					continue
 				}
@@ -308,7 +310,7 @@ func (self *worker) wait() {
 
 				// broadcast before waiting for validation
 				go func(block *types.Block, logs vm.Logs, receipts []*types.Receipt) {
-					self.mux.Post(core.NewMinedBlockEvent{Block: block})
+					self.mux.Post(mined)
 					self.mux.Post(core.ChainEvent{Block: block, Hash: block.Hash(), Logs: logs})
 
 					if stat == core.CanonStatTy {
@@ -341,6 +343,7 @@ func (self *worker) wait() {
 				))
 			}
 			glog.V(logger.Info).Infof("🔨  Mined %sblock (#%v / %x). %s", stale, block.Number(), block.Hash().Bytes()[:4], confirm)
+			glog.V(logger.Info).Infof("Block #%v reward: %v wei (base %v + uncle inclusion %v - treasury %v), fees: %v wei", block.Number(), mined.Reward.Miner(), mined.Reward.Base, mined.Reward.Uncles, mined.Reward.Treasury, mined.Fees)
 
 			self.commitNewWork()
 		}
@@ -370,21 +373,10 @@ func (self *worker) makeCurrent(parent *types.Block, header *types.Header) error
 		config:    self.config,
 		signer:    types.NewChainIdSigner(self.config.GetChainID()),
 		state:     state,
-		ancestors: set.New(),
-		family:    set.New(),
-		uncles:    set.New(),
+		family:    core.NewUncleFamily(self.chain.GetBlocksFromHash(parent.Hash(), core.MaxUncleDepth)),
 		header:    header,
 		createdAt: time.Now(),
 	}
-
-	// when 08 is processed ancestors contain 07 (quick block)
-	for _, ancestor := range self.chain.GetBlocksFromHash(parent.Hash(), 7) {
-		for _, uncle := range ancestor.Uncles() {
-			work.family.Add(uncle.Hash())
-		}
-		work.family.Add(ancestor.Hash())
-		work.ancestors.Add(ancestor.Hash())
-	}
 	accounts := self.eth.AccountManager().Accounts()
 
 	// Keep track of transactions which return errors so they can be removed
@@ -473,13 +465,17 @@ func (self *worker) commitNewWork() {
 	header := &types.Header{
 		ParentHash: parent.Hash(),
 		Number:     num.Add(num, common.Big1),
-		Difficulty: core.CalcDifficulty(self.config, uint64(tstamp), parent.Time().Uint64(), parent.Number(), parent.Difficulty()),
 		GasLimit:   core.CalcGasLimit(parent),
 		GasUsed:    new(big.Int),
 		Coinbase:   self.coinbase,
 		Extra:      HeaderExtra,
 		Time:       big.NewInt(tstamp),
 	}
+	// Let the engine set its fields, such as the difficulty
+	if err := self.chain.Engine().Prepare(self.chain, header); err != nil {
+		glog.V(logger.Error).Infof("Failed to prepare header for mining: %v", err)
+		return
+	}
 	previous := self.current
 	// Could potentially happen if starting to mine in an odd state.
 	err := self.makeCurrent(parent, header)
@@ -534,8 +530,10 @@ func (self *worker) commitNewWork() {
 		uncles    []*types.Header
 		badUncles []common.Hash
 	)
+	_, pow := self.chain.Engine().(consensus.PoW)
 	for hash, uncle := range self.possibleUncles {
-		if len(uncles) == 2 {
+		// Only proof-of-work allows uncles
+		if !pow || len(uncles) == core.MaxUncles {
 			break
 		}
 		if err := self.commitUncle(work, uncle.Header()); err != nil {
@@ -555,8 +553,8 @@ func (self *worker) commitNewWork() {
 
 	if atomic.LoadInt32(&self.mining) == 1 {
 		// commit state root after all state transitions.
-		core.AccumulateRewards(work.config, work.state, header, uncles)
-		header.Root = work.state.IntermediateRoot()
+		self.chain.Engine().Finalize(self.chain, header, work.state, uncles)
+		header.Root = work.state.IntermediateRoot(work.config.IsEIP161(header.Number))
 	}
 
 	// create the new block whose nonce will be mined.
@@ -591,20 +589,8 @@ func (self *worker) commitUncle(work *Work, uncle *types.Header) error {
 			))
 		}()
 	}
-	if work.uncles.Has(hash) {
-		e = core.UncleError("Uncle not unique")
-		return e
-	}
-	if !work.ancestors.Has(uncle.ParentHash) {
-		e = core.UncleError(fmt.Sprintf("Uncle's parent unknown (%x)", uncle.ParentHash[0:4]))
-		return e
-	}
-	if work.family.Has(hash) {
-		e = core.UncleError(fmt.Sprintf("Uncle already in family (%x)", hash))
-		return e
-	}
-	work.uncles.Add(uncle.Hash())
-	return nil
+	e = work.family.Add(uncle)
+	return e
 }
 
 func (env *Work) commitTransactions(mux *event.TypeMux, transactions types.Transactions, gasPrice *big.Int, bc *core.BlockChain) {
@@ -623,6 +609,11 @@ func (env *Work) commitTransactions(mux *event.TypeMux, transactions types.Trans
 			glog.V(logger.Detail).Infof("Transaction (%x) is replay protected, but we haven't yet hardforked. Transaction will be ignored until we hardfork.\n", tx.Hash())
 			continue
 		}
+		// Likewise ignore typed transactions until the fork enabling their type.
+		if !env.config.SupportsTxType(env.header.Number, tx.Type()) {
+			glog.V(logger.Detail).Infof("Transaction (%x) is of type %d, which isn't enabled yet. Transaction will be ignored until we hardfork.\n", tx.Hash(), tx.Type())
+			continue
+		}
 
 		// Check if it falls within margin. Txs from owned accounts are always processed.
 		if tx.GasPrice().Cmp(gasPrice) < 0 && !env.ownedAccounts.Has(from) {
@@ -18,7 +18,9 @@ package node
 
 import (
 	"crypto/ecdsa"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net"
@@ -26,17 +28,23 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+	"time"
 
+	"github.com/ellaism/go-ellaism/accounts"
 	"github.com/ellaism/go-ellaism/common"
 	"github.com/ellaism/go-ellaism/crypto"
 	"github.com/ellaism/go-ellaism/logger"
 	"github.com/ellaism/go-ellaism/logger/glog"
 	"github.com/ellaism/go-ellaism/p2p/discover"
 	"github.com/ellaism/go-ellaism/p2p/nat"
+	"github.com/ellaism/go-ellaism/rpc"
 )
 
 var (
 	datadirPrivateKey   = "nodekey"            // Path within the datadir to the node's private key
+	datadirEncryptedKey = "nodekey.json"       // Path within the datadir to the node's encrypted private key
+	datadirIPCToken     = "ipctoken"           // Path within the datadir to the IPC auth token
+	datadirEncIPCToken  = "ipctoken.json"      // Path within the datadir to the encrypted IPC auth token
 	datadirStaticNodes  = "static-nodes.json"  // Path within the datadir to the static node list
 	datadirTrustedNodes = "trusted-nodes.json" // Path within the datadir to the trusted node list
 	datadirNodeDatabase = "nodes"              // Path within the datadir to store the node infos
@@ -59,12 +67,27 @@ type Config struct {
 	// relative), then that specific path is enforced. An empty path disables IPC.
 	IPCPath string
 
+	// IPCMode is the file mode of the IPC socket on Unix platforms. Zero restricts
+	// it to the user running the node (0600).
+	IPCMode os.FileMode
+
+	// IPCGroup is the name or id of the group to hand the IPC socket to on Unix
+	// platforms, letting its members connect if IPCMode allows the group. The
+	// directory of the socket must be accessible to the group as well.
+	IPCGroup string
+
 	// This field should be a valid secp256k1 private key that will be used for both
 	// remote peer identification as well as network traffic encryption. If no key
 	// is configured, the preset one is loaded from the data dir, generating it if
 	// needed.
 	PrivateKey *ecdsa.PrivateKey
 
+	// NodeKeyPassword, if set, encrypts the node key persisted in the data dir as
+	// the keystore does the account keys. A plaintext key found there is moved
+	// into the encrypted file, which the node then fails to start without. The
+	// IPC auth token is encrypted the same way.
+	NodeKeyPassword string
+
 	// Name sets the node name of this server.
 	Name string
 
@@ -98,6 +121,11 @@ type Config struct {
 	// Zero defaults to preset values.
 	MaxPendingPeers int
 
+	// ClockCheck is the interval of the NTP measurements of the local clock
+	// drift, reported in the node info and warned about if large. Zero disables
+	// them.
+	ClockCheck time.Duration
+
 	// HTTPHost is the host interface on which to start the HTTP RPC server. If this
 	// field is empty, no HTTP API endpoint will be started.
 	HTTPHost string
@@ -135,6 +163,60 @@ type Config struct {
 	// If the module list is empty, all RPC API endpoints designated public will be
 	// exposed.
 	WSModules []string
+
+	// WSMaxConnections is the maximum number of websocket clients served at the
+	// same time. Zero means unlimited.
+	WSMaxConnections int
+
+	// WSMaxSubscriptions is the maximum number of subscriptions a websocket client
+	// may hold. Zero means unlimited.
+	WSMaxSubscriptions int
+
+	// WSIdleTimeout is the time after which websocket connections that have
+	// neither sent a request nor held a subscription are closed. Zero disables it.
+	WSIdleTimeout time.Duration
+
+	// IPCAuth requires the IPC clients to authenticate with the token persisted
+	// in the data dir, generated if missing, before any other call. Local users
+	// allowed to open the socket then also need to read the token, or to know
+	// NodeKeyPassword if it encrypts the token.
+	IPCAuth bool
+
+	// RPCAccessLog is the file to append a line to for each RPC call served over
+	// any interface, giving its method, parameter size, latency and error. An
+	// empty path disables the access log.
+	RPCAccessLog string
+
+	// RPCSlowQuery is the latency from which RPC calls are reported as slow in the
+	// node log, whether or not the access log is enabled. Zero disables it.
+	RPCSlowQuery time.Duration
+
+	// RPCAuditLog is the file to append an entry to for each call of the personal
+	// and admin namespaces, and of the methods signing with the node's accounts
+	// or setting its etherbase, served over any interface. The entries are
+	// chained by MAC so that tampering with the file is detected when it is
+	// reopened. An empty path disables the audit log.
+	RPCAuditLog string
+
+	// RPCAuditKey is the file holding the key of the audit log MACs, generated
+	// if missing. It is required by the audit log, and must be out of the data
+	// directory, where whoever may tamper with the audit log can't read it.
+	RPCAuditKey string
+
+	// RPCLimits are the rate and concurrency limits enforced on the RPC calls
+	// served over HTTP and websockets. IPC and in-process calls are local, and
+	// left unlimited.
+	RPCLimits []rpc.LimitRule
+
+	// RPCTLSCert and RPCTLSKey are the PEM files of the certificate and private
+	// key to serve the HTTP and websocket endpoints over TLS with. If both are
+	// empty, the endpoints are served in plaintext.
+	RPCTLSCert string
+	RPCTLSKey  string
+
+	// RPCTLSClientCA is the PEM file of the authorities the clients of the TLS
+	// endpoints must present a certificate from. Empty accepts any client.
+	RPCTLSClientCA string
 }
 
 // IPCEndpoint resolves an IPC endpoint based on a configured value, taking into
@@ -202,10 +284,22 @@ func (c *Config) NodeKey() *ecdsa.PrivateKey {
 		}
 		return key
 	}
-	// Fall back to persistent key from the data directory
+	// Fall back to persistent key from the data directory, encrypted if requested
+	if c.NodeKeyPassword != "" {
+		key, err := loadEncryptedNodeKey(c.DataDir, c.NodeKeyPassword)
+		if err != nil {
+			glog.Fatalf("Failed to load encrypted node key: %v", err)
+		}
+		return key
+	}
+	if _, err := os.Stat(filepath.Join(c.DataDir, datadirEncryptedKey)); err == nil {
+		glog.Fatalf("Node key in %s is encrypted, a password is required", c.DataDir)
+	}
 	keyfile := filepath.Join(c.DataDir, datadirPrivateKey)
 	if key, err := crypto.LoadECDSA(keyfile); err == nil {
 		return key
+	} else if !os.IsNotExist(err) {
+		glog.Fatalf("Failed to load node key: %v", err)
 	}
 	// No persistent key found, generate and store a new one
 	key, err := crypto.GenerateKey()
@@ -218,6 +312,112 @@ func (c *Config) NodeKey() *ecdsa.PrivateKey {
 	return key
 }
 
+// nodeKeyScryptN and nodeKeyScryptP are the scrypt parameters encrypting the node
+// key, lightened by the tests.
+var (
+	nodeKeyScryptN = accounts.StandardScryptN
+	nodeKeyScryptP = accounts.StandardScryptP
+)
+
+// loadEncryptedNodeKey decrypts the node key stored in the given data directory.
+// If there is none, the plaintext key is encrypted and removed, or a new key is
+// generated if there is no plaintext key either.
+func loadEncryptedNodeKey(datadir, password string) (*ecdsa.PrivateKey, error) {
+	return loadEncryptedKey(datadir, datadirPrivateKey, datadirEncryptedKey, password, true)
+}
+
+// IPCAuthToken returns the token the IPC clients have to authenticate with, or
+// an empty string if they don't have to. The token is loaded from the data dir,
+// where it is generated if missing.
+func (c *Config) IPCAuthToken() (string, error) {
+	if !c.IPCAuth {
+		return "", nil
+	}
+	if c.DataDir == "" {
+		return "", errors.New("IPC authentication requires a data directory")
+	}
+	return loadIPCAuthToken(c.DataDir, c.NodeKeyPassword, true)
+}
+
+// ReadIPCAuthToken reads the token the IPC clients of the node running in the
+// given data directory authenticate with, decrypting it with the node key
+// password if it is encrypted.
+func ReadIPCAuthToken(datadir, password string) (string, error) {
+	return loadIPCAuthToken(datadir, password, false)
+}
+
+// loadIPCAuthToken loads the IPC auth token stored in the given data directory,
+// a random 256 bit secret kept like the node key, in plaintext or encrypted with
+// the password if there is one. If there is no token and create is set, a new
+// one is generated.
+func loadIPCAuthToken(datadir, password string, create bool) (string, error) {
+	var (
+		key *ecdsa.PrivateKey
+		err error
+	)
+	if password != "" {
+		key, err = loadEncryptedKey(datadir, datadirIPCToken, datadirEncIPCToken, password, create)
+	} else {
+		key, err = loadPlainKey(datadir, datadirIPCToken, datadirEncIPCToken, create)
+	}
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(crypto.FromECDSA(key)), nil
+}
+
+// loadPlainKey loads the plaintext key stored in the given data directory under
+// plainname, failing if it is encrypted under encname. If there is none and
+// create is set, a new key is generated and stored.
+func loadPlainKey(datadir, plainname, encname string, create bool) (*ecdsa.PrivateKey, error) {
+	if _, err := os.Stat(filepath.Join(datadir, encname)); err == nil {
+		return nil, fmt.Errorf("%s in %s is encrypted, a password is required", plainname, datadir)
+	}
+	keyfile := filepath.Join(datadir, plainname)
+	key, err := crypto.LoadECDSA(keyfile)
+	if err == nil || !os.IsNotExist(err) || !create {
+		return key, err
+	}
+	if key, err = crypto.GenerateKey(); err != nil {
+		return nil, err
+	}
+	return key, crypto.SaveECDSA(keyfile, key)
+}
+
+// loadEncryptedKey decrypts the key stored in the given data directory under
+// encname. If there is none, the plaintext key stored under plainname is
+// encrypted and removed, or a new key is generated if there is no plaintext key
+// either and create is set.
+func loadEncryptedKey(datadir, plainname, encname, password string, create bool) (*ecdsa.PrivateKey, error) {
+	keyfile := filepath.Join(datadir, encname)
+	if keyjson, err := ioutil.ReadFile(keyfile); err == nil {
+		return accounts.Web3PrivateKey(keyjson, password)
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	plainfile := filepath.Join(datadir, plainname)
+	key, err := crypto.LoadECDSA(plainfile)
+	if err != nil {
+		if !os.IsNotExist(err) || !create {
+			return nil, err
+		}
+		if key, err = crypto.GenerateKey(); err != nil {
+			return nil, err
+		}
+	}
+	keyjson, err := accounts.EncryptPrivateKey(key, password, nodeKeyScryptN, nodeKeyScryptP)
+	if err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(keyfile, keyjson, 0600); err != nil {
+		return nil, err
+	}
+	if err := os.Remove(plainfile); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return key, nil
+}
+
 // StaticNodes returns a list of node enode URLs configured as static nodes.
 func (c *Config) StaticNodes() []*discover.Node {
 	return c.parsePersistentNodes(datadirStaticNodes)
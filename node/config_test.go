@@ -24,6 +24,7 @@ import (
 	"runtime"
 	"testing"
 
+	"github.com/ellaism/go-ellaism/accounts"
 	"github.com/ellaism/go-ellaism/crypto"
 )
 
@@ -150,3 +151,116 @@ func TestNodeKeyPersistency(t *testing.T) {
 		t.Fatalf("ephemeral node key persisted to disk")
 	}
 }
+
+// Tests that a plaintext node key is moved into an encrypted file when a password
+// is configured, and that the encrypted key is then loaded with it.
+func TestEncryptedNodeKey(t *testing.T) {
+	nodeKeyScryptN, nodeKeyScryptP = 2, 1
+	defer func() { nodeKeyScryptN, nodeKeyScryptP = accounts.StandardScryptN, accounts.StandardScryptP }()
+
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary data directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("failed to generate node key: %v", err)
+	}
+	if err := crypto.SaveECDSA(filepath.Join(dir, datadirPrivateKey), key); err != nil {
+		t.Fatalf("failed to persist node key: %v", err)
+	}
+	loaded, err := loadEncryptedNodeKey(dir, "secret")
+	if err != nil {
+		t.Fatalf("failed to encrypt node key: %v", err)
+	}
+	if !bytes.Equal(crypto.FromECDSA(loaded), crypto.FromECDSA(key)) {
+		t.Fatalf("encrypted node key mismatch")
+	}
+	if _, err := os.Stat(filepath.Join(dir, datadirPrivateKey)); !os.IsNotExist(err) {
+		t.Fatalf("plaintext node key left in data directory: %v", err)
+	}
+	if _, err := loadEncryptedNodeKey(dir, "wrong"); err == nil {
+		t.Fatalf("node key decrypted with wrong password")
+	}
+	loaded, err = loadEncryptedNodeKey(dir, "secret")
+	if err != nil {
+		t.Fatalf("failed to decrypt node key: %v", err)
+	}
+	if !bytes.Equal(crypto.FromECDSA(loaded), crypto.FromECDSA(key)) {
+		t.Fatalf("decrypted node key mismatch")
+	}
+}
+
+// Tests that corrupt plaintext node keys are reported instead of being replaced
+// by new keys, which would change the identity of the node.
+func TestCorruptNodeKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary data directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	keyfile := filepath.Join(dir, datadirPrivateKey)
+	if err := ioutil.WriteFile(keyfile, []byte("corrupt"), 0600); err != nil {
+		t.Fatalf("failed to write node key: %v", err)
+	}
+	if _, err := loadEncryptedNodeKey(dir, "secret"); err == nil {
+		t.Fatalf("corrupt node key encrypted")
+	}
+	if blob, err := ioutil.ReadFile(keyfile); err != nil || string(blob) != "corrupt" {
+		t.Fatalf("corrupt node key replaced: %q, %v", blob, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, datadirEncryptedKey)); !os.IsNotExist(err) {
+		t.Fatalf("encrypted node key created: %v", err)
+	}
+}
+
+// Tests that the IPC auth token is generated once and persisted in the data
+// directory, in plaintext or encrypted with the node key password.
+func TestIPCAuthToken(t *testing.T) {
+	nodeKeyScryptN, nodeKeyScryptP = 2, 1
+	defer func() { nodeKeyScryptN, nodeKeyScryptP = accounts.StandardScryptN, accounts.StandardScryptP }()
+
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary data directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if token, err := (&Config{DataDir: dir}).IPCAuthToken(); err != nil || token != "" {
+		t.Fatalf("token of disabled IPC authentication: %q, %v", token, err)
+	}
+	if _, err := ReadIPCAuthToken(dir, ""); err == nil {
+		t.Fatalf("missing token read")
+	}
+	config := &Config{DataDir: dir, IPCAuth: true}
+	token, err := config.IPCAuthToken()
+	if err != nil || len(token) != 64 {
+		t.Fatalf("failed to generate token: %q, %v", token, err)
+	}
+	if read, err := ReadIPCAuthToken(dir, ""); err != nil || read != token {
+		t.Fatalf("plaintext token mismatch: have %q, want %q, error %v", read, token, err)
+	}
+	// Setting a password encrypts the existing token
+	config.NodeKeyPassword = "secret"
+	if encrypted, err := config.IPCAuthToken(); err != nil || encrypted != token {
+		t.Fatalf("encrypted token mismatch: have %q, want %q, error %v", encrypted, token, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, datadirIPCToken)); !os.IsNotExist(err) {
+		t.Fatalf("plaintext token left in data directory: %v", err)
+	}
+	if _, err := ReadIPCAuthToken(dir, ""); err == nil {
+		t.Fatalf("encrypted token read without password")
+	}
+	if _, err := ReadIPCAuthToken(dir, "wrong"); err == nil {
+		t.Fatalf("encrypted token read with wrong password")
+	}
+	if read, err := ReadIPCAuthToken(dir, "secret"); err != nil || read != token {
+		t.Fatalf("decrypted token mismatch: have %q, want %q, error %v", read, token, err)
+	}
+	if _, err := (&Config{IPCAuth: true}).IPCAuthToken(); err == nil {
+		t.Fatalf("token generated without data directory")
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ellaism/go-ellaism/logger"
+	"github.com/ellaism/go-ellaism/logger/glog"
+)
+
+// datadirVersion is the path within the datadir to the version of its layout.
+const datadirVersion = "VERSION"
+
+// DataDirMigration upgrades the layout of a data directory, such as the keys of
+// its databases or the location of its files, from the previous version.
+// Migrations must leave layouts they don't apply to untouched, as data
+// directories without a version file may hold any layout up to the first
+// versioned one.
+type DataDirMigration struct {
+	Version int                    // Layout version the migration upgrades to
+	Name    string                 // Description of the change in layout
+	Migrate func(dir string) error // Upgrades the data directory in place
+}
+
+// DataDirTooNewError is returned when a data directory has a layout written by
+// a newer version of the client than the migrations know about.
+type DataDirTooNewError struct {
+	Dir              string
+	Version, Current int
+}
+
+// Error generates a textual representation of the layout version error.
+func (e *DataDirTooNewError) Error() string {
+	return fmt.Sprintf("data directory %s has layout version %d, newer than the supported version %d", e.Dir, e.Version, e.Current)
+}
+
+// DataDirVersion returns the layout version of a data directory, or 0 if the
+// data directory is not versioned yet.
+func DataDirVersion(dir string) (int, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, datadirVersion))
+	if os.IsNotExist(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	version, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || version < 0 {
+		return 0, fmt.Errorf("invalid data directory version file %s: %q", filepath.Join(dir, datadirVersion), data)
+	}
+	return version, nil
+}
+
+// WriteDataDirVersion records the layout version of a data directory.
+func WriteDataDirVersion(dir string, version int) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	path := filepath.Join(dir, datadirVersion)
+	if err := ioutil.WriteFile(path+".tmp", []byte(strconv.Itoa(version)+"\n"), 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// MigrateDataDir upgrades a data directory to the latest layout version, running
+// in order the migrations to versions past the one it was written with. The
+// version is recorded after each migration, so that an interrupted upgrade
+// resumes where it stopped. Migrations must be given by ascending version.
+func MigrateDataDir(dir string, migrations []DataDirMigration) error {
+	version, err := DataDirVersion(dir)
+	if err != nil {
+		return err
+	}
+	current := 0
+	if len(migrations) > 0 {
+		current = migrations[len(migrations)-1].Version
+	}
+	if version > current {
+		return &DataDirTooNewError{Dir: dir, Version: version, Current: current}
+	}
+	for _, migration := range migrations {
+		if migration.Version <= version {
+			continue
+		}
+		glog.V(logger.Info).Infof("Upgrading data directory %s to layout version %d: %s", dir, migration.Version, migration.Name)
+		if version > 0 {
+			glog.D(logger.Warn).Infof("Upgrading data directory to layout version %d: %s", migration.Version, migration.Name)
+		}
+
+		start := time.Now()
+		if err := migration.Migrate(dir); err != nil {
+			return fmt.Errorf("data directory migration to version %d (%s) failed: %v", migration.Version, migration.Name, err)
+		}
+		if err := WriteDataDirVersion(dir, migration.Version); err != nil {
+			return err
+		}
+		glog.V(logger.Info).Infof("Upgraded data directory to layout version %d in %v", migration.Version, time.Since(start))
+	}
+	return nil
+}
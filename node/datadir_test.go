@@ -0,0 +1,91 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// Tests that data directory migrations run once each and in order, that a
+// failed migration is resumed, and that newer layouts are refused.
+func TestMigrateDataDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary data dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		ran  []int
+		fail = true
+	)
+	migrations := []DataDirMigration{
+		{Version: 1, Name: "first", Migrate: func(string) error { ran = append(ran, 1); return nil }},
+		{Version: 2, Name: "second", Migrate: func(string) error {
+			if fail {
+				return errors.New("interrupted")
+			}
+			ran = append(ran, 2)
+			return nil
+		}},
+		{Version: 3, Name: "third", Migrate: func(string) error { ran = append(ran, 3); return nil }},
+	}
+
+	// An unversioned data directory runs all migrations, stopping at a failure
+	if err := MigrateDataDir(dir, migrations); err == nil {
+		t.Fatal("failed migration not reported")
+	}
+	if version, _ := DataDirVersion(dir); version != 1 {
+		t.Fatalf("version after failed migration: have %d, want 1", version)
+	}
+	// The next run resumes at the failed migration
+	fail = false
+	if err := MigrateDataDir(dir, migrations); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	if version, _ := DataDirVersion(dir); version != 3 {
+		t.Fatalf("version after migration: have %d, want 3", version)
+	}
+	if !reflect.DeepEqual(ran, []int{1, 2, 3}) {
+		t.Fatalf("migrations run: have %v, want [1 2 3]", ran)
+	}
+	// Up to date data directories are left alone
+	if err := MigrateDataDir(dir, migrations); err != nil {
+		t.Fatalf("failed to migrate up to date data dir: %v", err)
+	}
+	if len(ran) != 3 {
+		t.Fatalf("migrations rerun: %v", ran)
+	}
+	// Layouts written by newer versions are refused
+	if err := MigrateDataDir(dir, migrations[:2]); err == nil {
+		t.Fatal("migrated data dir with newer layout")
+	} else if _, ok := err.(*DataDirTooNewError); !ok {
+		t.Fatalf("newer layout error mismatch: have %v, want *DataDirTooNewError", err)
+	}
+	// Unreadable version files are reported
+	if err := ioutil.WriteFile(filepath.Join(dir, datadirVersion), []byte("two\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DataDirVersion(dir); err == nil {
+		t.Fatal("invalid version file not reported")
+	}
+}
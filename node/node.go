@@ -18,13 +18,18 @@
 package node
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"net"
+	"net/http"
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/ellaism/go-ellaism/event"
 	"github.com/ellaism/go-ellaism/logger"
@@ -42,6 +47,10 @@ var (
 	datadirInUseErrnos = map[uint]bool{11: true, 32: true, 35: true}
 )
 
+// rpcShutdownTimeout is the time given to the HTTP requests in flight to complete
+// when an HTTP or websocket endpoint is stopped.
+const rpcShutdownTimeout = 3 * time.Second
+
 // Node represents a P2P node into which arbitrary (uniquely typed) services might
 // be registered.
 type Node struct {
@@ -57,9 +66,11 @@ type Node struct {
 	rpcAPIs       []rpc.API   // List of APIs currently provided by the node
 	inprocHandler *rpc.Server // In-process RPC request handler to process the API requests
 
-	ipcEndpoint string       // IPC endpoint to listen at (empty = IPC disabled)
-	ipcListener net.Listener // IPC RPC listener socket to serve API requests
-	ipcHandler  *rpc.Server  // IPC RPC request handler to process the API requests
+	ipcEndpoint string             // IPC endpoint to listen at (empty = IPC disabled)
+	ipcPerms    rpc.IPCPermissions // Permissions of the IPC socket
+	ipcToken    string             // Token the IPC clients authenticate with (empty = no authentication)
+	ipcListener net.Listener       // IPC RPC listener socket to serve API requests
+	ipcHandler  *rpc.Server        // IPC RPC request handler to process the API requests
 
 	httpHost      string       // HTTP hostname
 	httpPort      int          // HTTP post
@@ -68,14 +79,31 @@ type Node struct {
 	httpCors      string       // HTTP RPC Cross-Origin Resource Sharing header
 	httpListener  net.Listener // HTTP RPC listener socket to server API requests
 	httpHandler   *rpc.Server  // HTTP RPC request handler to process the API requests
-
-	wsHost      string       // Websocket host
-	wsPort      int          // Websocket post
-	wsEndpoint  string       // Websocket endpoint (interface + port) to listen at (empty = websocket disabled)
-	wsWhitelist []string     // Websocket RPC modules to allow through this endpoint
-	wsOrigins   string       // Websocket RPC allowed origin domains
-	wsListener  net.Listener // Websocket RPC listener socket to server API requests
-	wsHandler   *rpc.Server  // Websocket RPC request handler to process the API requests
+	httpServer    *http.Server // HTTP server serving the HTTP RPC listener
+
+	wsHost        string        // Websocket host
+	wsPort        int           // Websocket post
+	wsEndpoint    string        // Websocket endpoint (interface + port) to listen at (empty = websocket disabled)
+	wsWhitelist   []string      // Websocket RPC modules to allow through this endpoint
+	wsOrigins     string        // Websocket RPC allowed origin domains
+	wsMaxConns    int           // Maximum number of websocket clients (0 = unlimited)
+	wsMaxSubs     int           // Maximum number of subscriptions per websocket client (0 = unlimited)
+	wsIdleTimeout time.Duration // Time after which idle websocket clients are dropped (0 = never)
+	wsListener    net.Listener  // Websocket RPC listener socket to server API requests
+	wsHandler     *rpc.Server   // Websocket RPC request handler to process the API requests
+	wsServer      *http.Server  // HTTP server upgrading the websocket RPC connections
+
+	tlsCert     string // Certificate served by the HTTP and websocket endpoints (empty = plaintext)
+	tlsKey      string // Private key of the TLS certificate
+	tlsClientCA string // Authorities the clients must present a certificate from (empty = any client)
+
+	accessLogPath string         // File to record the RPC calls in (empty = no access log)
+	slowQuery     time.Duration  // Latency from which RPC calls are reported (0 = disabled)
+	accessLog     *rpc.AccessLog // Access log shared by the RPC endpoints, nil if disabled
+	auditLogPath  string         // File to record the privileged RPC calls in (empty = no audit log)
+	auditKeyPath  string         // File holding the key of the audit log MACs
+	auditLog      *rpc.AuditLog  // Audit log shared by the RPC endpoints, nil if disabled
+	limiter       *rpc.Limiter   // Limits shared by the network RPC endpoints, nil if unlimited
 
 	stop chan struct{} // Channel to wait for termination notifications
 	lock sync.RWMutex
@@ -94,6 +122,10 @@ func New(conf *Config) (*Node, error) {
 	if conf.DataDir != "" {
 		nodeDbPath = filepath.Join(conf.DataDir, datadirNodeDatabase)
 	}
+	ipcToken, err := conf.IPCAuthToken()
+	if err != nil {
+		return nil, err
+	}
 	return &Node{
 		datadir: conf.DataDir,
 		serverConfig: p2p.Config{
@@ -110,9 +142,12 @@ func New(conf *Config) (*Node, error) {
 			NoDial:          conf.NoDial,
 			MaxPeers:        conf.MaxPeers,
 			MaxPendingPeers: conf.MaxPendingPeers,
+			ClockCheck:      conf.ClockCheck,
 		},
 		serviceFuncs:  []ServiceConstructor{},
 		ipcEndpoint:   conf.IPCEndpoint(),
+		ipcPerms:      rpc.IPCPermissions{Mode: conf.IPCMode, Group: conf.IPCGroup},
+		ipcToken:      ipcToken,
 		httpHost:      conf.HTTPHost,
 		httpPort:      conf.HTTPPort,
 		httpEndpoint:  conf.HTTPEndpoint(),
@@ -123,6 +158,17 @@ func New(conf *Config) (*Node, error) {
 		wsEndpoint:    conf.WSEndpoint(),
 		wsWhitelist:   conf.WSModules,
 		wsOrigins:     conf.WSOrigins,
+		wsMaxConns:    conf.WSMaxConnections,
+		wsMaxSubs:     conf.WSMaxSubscriptions,
+		wsIdleTimeout: conf.WSIdleTimeout,
+		tlsCert:       conf.RPCTLSCert,
+		tlsKey:        conf.RPCTLSKey,
+		tlsClientCA:   conf.RPCTLSClientCA,
+		accessLogPath: conf.RPCAccessLog,
+		slowQuery:     conf.RPCSlowQuery,
+		auditLogPath:  conf.RPCAuditLog,
+		auditKeyPath:  conf.RPCAuditKey,
+		limiter:       newLimiter(conf.RPCLimits),
 		eventmux:      new(event.TypeMux),
 	}, nil
 }
@@ -223,23 +269,43 @@ func (n *Node) startRPC(services map[reflect.Type]Service) error {
 	for _, service := range services {
 		apis = append(apis, service.APIs()...)
 	}
+	// Open the access log shared by the endpoints, if requested
+	if n.accessLogPath != "" || n.slowQuery > 0 {
+		accessLog, err := rpc.OpenAccessLog(n.accessLogPath, n.slowQuery)
+		if err != nil {
+			return err
+		}
+		n.accessLog = accessLog
+	}
+	if n.auditLogPath != "" {
+		auditLog, err := n.openAuditLog()
+		if err != nil {
+			n.closeRPCLogs()
+			return err
+		}
+		n.auditLog = auditLog
+	}
 	// Start the various API endpoints, terminating all in case of errors
 	if err := n.startInProc(apis); err != nil {
+		n.closeRPCLogs()
 		return err
 	}
 	if err := n.startIPC(apis); err != nil {
 		n.stopInProc()
+		n.closeRPCLogs()
 		return err
 	}
 	if err := n.startHTTP(n.httpEndpoint, apis, n.httpWhitelist, n.httpCors); err != nil {
 		n.stopIPC()
 		n.stopInProc()
+		n.closeRPCLogs()
 		return err
 	}
 	if err := n.startWS(n.wsEndpoint, apis, n.wsWhitelist, n.wsOrigins); err != nil {
 		n.stopHTTP()
 		n.stopIPC()
 		n.stopInProc()
+		n.closeRPCLogs()
 		return err
 	}
 	// All API endpoints started successfully
@@ -247,10 +313,90 @@ func (n *Node) startRPC(services map[reflect.Type]Service) error {
 	return nil
 }
 
+// openAuditLog opens the audit log with the key stored in the configured key
+// file, which is required and must be out of the data directory.
+func (n *Node) openAuditLog() (*rpc.AuditLog, error) {
+	if n.auditKeyPath == "" {
+		return nil, errors.New("audit log requires a key file")
+	}
+	if n.datadir != "" && withinDir(n.datadir, n.auditKeyPath) {
+		return nil, fmt.Errorf("audit log key %s must be out of the data directory", n.auditKeyPath)
+	}
+	key, err := rpc.LoadAuditKey(n.auditKeyPath)
+	if err != nil {
+		return nil, err
+	}
+	return rpc.OpenAuditLog(n.auditLogPath, key)
+}
+
+// withinDir returns whether the given path is inside dir, following the symbolic
+// links of both as far as they exist.
+func withinDir(dir, path string) bool {
+	resolve := func(path string) string {
+		path, _ = filepath.Abs(path)
+		for rest := ""; ; {
+			if resolved, err := filepath.EvalSymlinks(path); err == nil {
+				return filepath.Join(resolved, rest)
+			}
+			parent := filepath.Dir(path)
+			if parent == path {
+				return filepath.Join(path, rest)
+			}
+			rest = filepath.Join(filepath.Base(path), rest)
+			path = parent
+		}
+	}
+	rel, err := filepath.Rel(resolve(dir), resolve(path))
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// newRPCServer creates an RPC server for the given transport, recording its
+// calls in the access and audit logs if there are any, and limiting them if the
+// transport is network facing.
+func (n *Node) newRPCServer(transport string) *rpc.Server {
+	handler := rpc.NewServer()
+	handler.SetTransport(transport)
+	if n.accessLog != nil {
+		handler.SetAccessLog(n.accessLog, transport)
+	}
+	if n.auditLog != nil {
+		handler.SetAuditLog(n.auditLog, transport)
+	}
+	if n.limiter != nil && rpc.IsRemoteTransport(transport) {
+		handler.SetLimiter(n.limiter)
+	}
+	return handler
+}
+
+// newLimiter creates the limiter enforcing the given rules, or nil if there are
+// none.
+func newLimiter(rules []rpc.LimitRule) *rpc.Limiter {
+	if len(rules) == 0 {
+		return nil
+	}
+	return rpc.NewLimiter(rules)
+}
+
+// closeRPCLogs closes the RPC access and audit logs, if open.
+func (n *Node) closeRPCLogs() {
+	if n.accessLog != nil {
+		if err := n.accessLog.Close(); err != nil {
+			glog.V(logger.Error).Infof("Failed to close RPC access log: %v", err)
+		}
+		n.accessLog = nil
+	}
+	if n.auditLog != nil {
+		if err := n.auditLog.Close(); err != nil {
+			glog.V(logger.Error).Infof("Failed to close RPC audit log: %v", err)
+		}
+		n.auditLog = nil
+	}
+}
+
 // startInProc initializes an in-process RPC endpoint.
 func (n *Node) startInProc(apis []rpc.API) error {
 	// Register all the APIs exposed by the services
-	handler := rpc.NewServer()
+	handler := n.newRPCServer("inproc")
 	for _, api := range apis {
 		if err := handler.RegisterName(api.Namespace, api.Service); err != nil {
 			return err
@@ -276,7 +422,10 @@ func (n *Node) startIPC(apis []rpc.API) error {
 		return nil
 	}
 	// Register all the APIs exposed by the services
-	handler := rpc.NewServer()
+	handler := n.newRPCServer("ipc")
+	if n.ipcToken != "" {
+		handler.SetAuthToken(n.ipcToken)
+	}
 	for _, api := range apis {
 		if err := handler.RegisterName(api.Namespace, api.Service); err != nil {
 			return err
@@ -288,7 +437,7 @@ func (n *Node) startIPC(apis []rpc.API) error {
 		listener net.Listener
 		err      error
 	)
-	if listener, err = rpc.CreateIPCListener(n.ipcEndpoint); err != nil {
+	if listener, err = rpc.CreateIPCListenerWithPermissions(n.ipcEndpoint, n.ipcPerms); err != nil {
 		return err
 	}
 	go func() {
@@ -346,7 +495,7 @@ func (n *Node) startHTTP(endpoint string, apis []rpc.API, modules []string, cors
 		whitelist[module] = true
 	}
 	// Register all the APIs exposed by the services
-	handler := rpc.NewServer()
+	handler := n.newRPCServer("http")
 	for _, api := range apis {
 		if whitelist[api.Namespace] || (len(whitelist) == 0 && api.Public) {
 			if err := handler.RegisterName(api.Namespace, api.Service); err != nil {
@@ -360,16 +509,18 @@ func (n *Node) startHTTP(endpoint string, apis []rpc.API, modules []string, cors
 		listener net.Listener
 		err      error
 	)
-	if listener, err = net.Listen("tcp", endpoint); err != nil {
+	if listener, err = n.listenRPC(endpoint); err != nil {
 		return err
 	}
-	go rpc.NewHTTPServer(cors, handler).Serve(listener)
-	glog.V(logger.Info).Infof("HTTP endpoint opened: http://%s", endpoint)
-	glog.D(logger.Warn).Infof("HTTP endpoint: http://%s", logger.ColorGreen(endpoint))
+	server := rpc.NewHTTPServer(cors, handler)
+	go server.Serve(listener)
+	glog.V(logger.Info).Infof("HTTP endpoint opened: %s://%s", n.rpcScheme("http"), endpoint)
+	glog.D(logger.Warn).Infof("HTTP endpoint: %s://%s", n.rpcScheme("http"), logger.ColorGreen(endpoint))
 
 	// All listeners booted successfully
 	n.httpEndpoint = endpoint
 	n.httpListener = listener
+	n.httpServer = server
 	n.httpHandler = handler
 	n.httpCors = cors
 
@@ -379,11 +530,12 @@ func (n *Node) startHTTP(endpoint string, apis []rpc.API, modules []string, cors
 // stopHTTP terminates the HTTP RPC endpoint.
 func (n *Node) stopHTTP() {
 	if n.httpListener != nil {
+		shutdownRPCServer(n.httpServer)
 		n.httpListener.Close()
-		n.httpListener = nil
+		n.httpListener, n.httpServer = nil, nil
 
-		glog.V(logger.Info).Infof("HTTP endpoint closed: http://%s", n.httpEndpoint)
-		glog.D(logger.Warn).Warnf("HTTP endpoint closed: http://%s", n.httpEndpoint)
+		glog.V(logger.Info).Infof("HTTP endpoint closed: %s://%s", n.rpcScheme("http"), n.httpEndpoint)
+		glog.D(logger.Warn).Warnf("HTTP endpoint closed: %s://%s", n.rpcScheme("http"), n.httpEndpoint)
 	}
 	if n.httpHandler != nil {
 		n.httpHandler.Stop()
@@ -403,7 +555,8 @@ func (n *Node) startWS(endpoint string, apis []rpc.API, modules []string, wsOrig
 		whitelist[module] = true
 	}
 	// Register all the APIs exposed by the services
-	handler := rpc.NewServer()
+	handler := n.newRPCServer("ws")
+	handler.SetConnectionLimits(n.wsMaxSubs, n.wsIdleTimeout)
 	for _, api := range apis {
 		if whitelist[api.Namespace] || (len(whitelist) == 0 && api.Public) {
 			if err := handler.RegisterName(api.Namespace, api.Service); err != nil {
@@ -417,16 +570,18 @@ func (n *Node) startWS(endpoint string, apis []rpc.API, modules []string, wsOrig
 		listener net.Listener
 		err      error
 	)
-	if listener, err = net.Listen("tcp", endpoint); err != nil {
+	if listener, err = n.listenRPC(endpoint); err != nil {
 		return err
 	}
-	go rpc.NewWSServer(wsOrigins, handler).Serve(listener)
-	glog.V(logger.Info).Infof("WebSocket endpoint opened: ws://%s", endpoint)
-	glog.D(logger.Warn).Infof("WebSocket endpoint opened: ws://%s", logger.ColorGreen(endpoint))
+	server := rpc.NewWSServer(wsOrigins, n.wsMaxConns, handler)
+	go server.Serve(listener)
+	glog.V(logger.Info).Infof("WebSocket endpoint opened: %s://%s", n.rpcScheme("ws"), endpoint)
+	glog.D(logger.Warn).Infof("WebSocket endpoint opened: %s://%s", n.rpcScheme("ws"), logger.ColorGreen(endpoint))
 
 	// All listeners booted successfully
 	n.wsEndpoint = endpoint
 	n.wsListener = listener
+	n.wsServer = server
 	n.wsHandler = handler
 	n.wsOrigins = wsOrigins
 
@@ -436,11 +591,12 @@ func (n *Node) startWS(endpoint string, apis []rpc.API, modules []string, wsOrig
 // stopWS terminates the websocket RPC endpoint.
 func (n *Node) stopWS() {
 	if n.wsListener != nil {
+		shutdownRPCServer(n.wsServer)
 		n.wsListener.Close()
-		n.wsListener = nil
+		n.wsListener, n.wsServer = nil, nil
 
-		glog.V(logger.Info).Infof("WebSocket endpoint closed: ws://%s", n.wsEndpoint)
-		glog.V(logger.Warn).Warnf("WebSocket endpoint closed: ws://%s", n.wsEndpoint)
+		glog.V(logger.Info).Infof("WebSocket endpoint closed: %s://%s", n.rpcScheme("ws"), n.wsEndpoint)
+		glog.V(logger.Warn).Warnf("WebSocket endpoint closed: %s://%s", n.rpcScheme("ws"), n.wsEndpoint)
 	}
 	if n.wsHandler != nil {
 		n.wsHandler.Stop()
@@ -448,6 +604,18 @@ func (n *Node) stopWS() {
 	}
 }
 
+// shutdownRPCServer stops an HTTP server from accepting connections, closing
+// its idle ones and waiting up to rpcShutdownTimeout for the requests in flight
+// to complete. Hijacked websocket connections are left to the RPC handler.
+func shutdownRPCServer(server *http.Server) {
+	ctx, cancel := context.WithTimeout(context.Background(), rpcShutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		glog.V(logger.Warn).Infof("RPC endpoint shutdown: %v", err)
+	}
+}
+
 // Stop terminates a running node along with all it's services. In the node was
 // not started, an error is returned.
 func (n *Node) Stop() error {
@@ -462,6 +630,7 @@ func (n *Node) Stop() error {
 	n.stopWS()
 	n.stopHTTP()
 	n.stopIPC()
+	n.closeRPCLogs()
 	n.rpcAPIs = nil
 
 	failure := &StopError{
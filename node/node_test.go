@@ -20,6 +20,7 @@ import (
 	"errors"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 	"time"
@@ -597,3 +598,43 @@ func TestAPIGather(t *testing.T) {
 		}
 	}
 }
+
+// Tests that the audit log key is only accepted out of the data directory, even
+// when reached through a symbolic link.
+func TestAuditKeyOutOfDatadir(t *testing.T) {
+	root, err := ioutil.TempDir("", "node-auditkey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	datadir := filepath.Join(root, "data")
+	if err := os.Mkdir(datadir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(datadir, filepath.Join(root, "link")); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		key    string
+		within bool
+	}{
+		{filepath.Join(datadir, "auditlog.key"), true},
+		{filepath.Join(datadir, "keys", "auditlog.key"), true},
+		{filepath.Join(root, "link", "auditlog.key"), true},
+		{filepath.Join(root, "auditlog.key"), false},
+		{filepath.Join(root, "data2", "auditlog.key"), false},
+	}
+	for _, tt := range tests {
+		if within := withinDir(datadir, tt.key); within != tt.within {
+			t.Errorf("%s: within data directory %v, want %v", tt.key, within, tt.within)
+		}
+	}
+	stack, err := New(&Config{DataDir: datadir, RPCAuditLog: filepath.Join(root, "audit.log"), RPCAuditKey: filepath.Join(datadir, "auditlog.key")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stack.openAuditLog(); err == nil {
+		t.Errorf("audit log opened with a key in the data directory")
+	}
+}
@@ -45,6 +45,19 @@ func (ctx *ServiceContext) OpenDatabase(name string, cache int, handles int) (et
 	return ethdb.NewLDBDatabase(filepath.Join(ctx.datadir, name), cache, handles)
 }
 
+// ResolvePath resolves a path relative to the node's data directory. Absolute
+// paths are returned as is, while relative ones resolve to the empty string if
+// the node is an ephemeral one.
+func (ctx *ServiceContext) ResolvePath(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	if ctx.datadir == "" || path == "" {
+		return ""
+	}
+	return filepath.Join(ctx.datadir, path)
+}
+
 // Service retrieves a currently running service registered of a specific type.
 func (ctx *ServiceContext) Service(service interface{}) error {
 	element := reflect.ValueOf(service).Elem()
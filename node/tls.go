@@ -0,0 +1,87 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+)
+
+var errTLSKeyPair = errors.New("TLS certificate and key must be given together")
+
+// loadTLSConfig creates the TLS configuration of the RPC endpoints from the PEM
+// encoded certificate and key files. If clientCAFile is set, clients must
+// present a certificate signed by one of the authorities it holds.
+func loadTLSConfig(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	if certFile == "" || keyFile == "" {
+		return nil, errTLSKeyPair
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS key pair: %v", err)
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if clientCAFile != "" {
+		pem, err := ioutil.ReadFile(clientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read TLS client CA: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in TLS client CA %s", clientCAFile)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
+
+// listenRPC opens a TCP listener for an RPC endpoint, serving TLS over it if the
+// node is configured with a certificate.
+func (n *Node) listenRPC(endpoint string) (net.Listener, error) {
+	var config *tls.Config
+	if n.tlsCert != "" || n.tlsKey != "" || n.tlsClientCA != "" {
+		var err error
+		if config, err = loadTLSConfig(n.tlsCert, n.tlsKey, n.tlsClientCA); err != nil {
+			return nil, err
+		}
+	}
+	listener, err := net.Listen("tcp", endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if config != nil {
+		listener = tls.NewListener(listener, config)
+	}
+	return listener, nil
+}
+
+// rpcScheme returns the secure variant of an RPC URL scheme if the endpoints
+// are served over TLS.
+func (n *Node) rpcScheme(scheme string) string {
+	if n.tlsCert == "" {
+		return scheme
+	}
+	return scheme + "s"
+}
@@ -0,0 +1,89 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"time"
+
+	"github.com/ellaism/go-ellaism/logger"
+	"github.com/ellaism/go-ellaism/logger/glog"
+	"github.com/ellaism/go-ellaism/metrics"
+	"github.com/ellaism/go-ellaism/p2p/discover"
+)
+
+// measureClockDrift measures the drift of the local clock, warning about large
+// ones. It is replaced by tests.
+var measureClockDrift = discover.CheckClockDrift
+
+// ClockDriftInfo is the last measurement of the local clock drift.
+type ClockDriftInfo struct {
+	Drift    string    `json:"drift"`    // Drift from the NTP time, positive if the local clock is ahead
+	Measured time.Time `json:"measured"` // Time the drift was measured at
+}
+
+// ClockDrift returns the last measurement of the local clock drift, or nil if
+// the clock isn't checked or no measurement succeeded yet.
+func (srv *Server) ClockDrift() *ClockDriftInfo {
+	srv.clockLock.RLock()
+	defer srv.clockLock.RUnlock()
+
+	return srv.clockDrift
+}
+
+// clockLoop measures the local clock drift when the server starts and then
+// every clock check interval, until the server stops. Clock skew silently
+// breaks block acceptance and mining, so large drifts are warned about.
+func (srv *Server) clockLoop() {
+	defer srv.loopWG.Done()
+
+	type measurement struct {
+		drift time.Duration
+		err   error
+	}
+	done := make(chan measurement, 1) // Buffered so measurements outlasting the server don't leak
+	measure := func() {
+		drift, err := measureClockDrift()
+		done <- measurement{drift, err}
+	}
+	go measure()
+
+	timer := time.NewTimer(0)
+	<-timer.C
+	defer timer.Stop()
+
+	for {
+		select {
+		case m := <-done:
+			if m.err != nil {
+				glog.V(logger.Debug).Infof("NTP clock drift check failed: %v", m.err)
+			} else {
+				metrics.P2PClockDrift.Update(int64(m.drift / time.Millisecond))
+
+				srv.clockLock.Lock()
+				srv.clockDrift = &ClockDriftInfo{Drift: m.drift.String(), Measured: time.Now()}
+				srv.clockLock.Unlock()
+			}
+			timer.Reset(srv.ClockCheck)
+
+		case <-timer.C:
+			go measure()
+
+		case <-srv.quit:
+			return
+		}
+	}
+}
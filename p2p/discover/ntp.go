@@ -43,12 +43,13 @@ func (s durationSlice) Len() int           { return len(s) }
 func (s durationSlice) Less(i, j int) bool { return s[i] < s[j] }
 func (s durationSlice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
 
-// checkClockDrift queries an NTP server for clock drifts and warns the user if
-// one large enough is detected.
-func checkClockDrift() {
+// CheckClockDrift queries an NTP server for clock drifts and warns the user if
+// one large enough is detected. It returns the measured drift, positive if the
+// local clock is ahead.
+func CheckClockDrift() (time.Duration, error) {
 	drift, err := sntpDrift(ntpChecks)
 	if err != nil {
-		return
+		return 0, err
 	}
 	if drift < -driftThreshold || drift > driftThreshold {
 		warning := fmt.Sprintf("System clock seems off by %v, which can prevent network connectivity", drift)
@@ -62,6 +63,7 @@ func checkClockDrift() {
 	} else {
 		glog.V(logger.Debug).Infof("Sanity NTP check reported %v drift, all ok", drift)
 	}
+	return drift, nil
 }
 
 // sntpDrift does a naive time resolution against an NTP server and returns the
@@ -113,6 +113,11 @@ type Config struct {
 
 	// If NoDial is true, the server will not dial any peers.
 	NoDial bool
+
+	// ClockCheck is the interval of the NTP measurements of the local clock
+	// drift, the first of which is done when the server starts. Zero disables
+	// them.
+	ClockCheck time.Duration
 }
 
 // Server manages all peer connections.
@@ -142,7 +147,10 @@ type Server struct {
 	posthandshake chan *conn
 	addpeer       chan *conn
 	delpeer       chan *Peer
-	loopWG        sync.WaitGroup // loop, listenLoop
+	loopWG        sync.WaitGroup // loop, listenLoop, clockLoop
+
+	clockLock  sync.RWMutex
+	clockDrift *ClockDriftInfo // Last measurement of the local clock drift, nil if none yet
 }
 
 type peerOpFunc func(map[discover.NodeID]*Peer)
@@ -362,6 +370,10 @@ func (srv *Server) Start() (err error) {
 		glog.V(logger.Warn).Warnln("Server will be kind of useless, neither dialing nor listening.")
 	}
 
+	if srv.ClockCheck > 0 {
+		srv.loopWG.Add(1)
+		go srv.clockLoop()
+	}
 	srv.loopWG.Add(1)
 	go srv.run(dialer)
 	srv.running = true
@@ -722,6 +734,8 @@ type NodeInfo struct {
 	} `json:"ports"`
 	ListenAddr string                 `json:"listenAddr"`
 	Protocols  map[string]interface{} `json:"protocols"`
+
+	ClockDrift *ClockDriftInfo `json:"clockDrift,omitempty"` // Last NTP measurement of the local clock drift
 }
 
 // Info gathers and returns a collection of metadata known about the host.
@@ -739,6 +753,7 @@ func (srv *Server) NodeInfo() *NodeInfo {
 	}
 	info.Ports.Discovery = int(node.UDP)
 	info.Ports.Listener = int(node.TCP)
+	info.ClockDrift = srv.ClockDrift()
 
 	// Gather all the running protocol infos (only once per protocol type)
 	for _, proto := range srv.Protocols {
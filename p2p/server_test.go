@@ -493,3 +493,35 @@ func randomID() (id discover.NodeID) {
 	}
 	return id
 }
+
+// Tests that the local clock drift is measured when the server starts, and
+// reported in the node info.
+func TestServerClockCheck(t *testing.T) {
+	measured := make(chan struct{}, 1)
+	defer func(measure func() (time.Duration, error)) { measureClockDrift = measure }(measureClockDrift)
+	measureClockDrift = func() (time.Duration, error) {
+		defer func() { measured <- struct{}{} }()
+		return 3 * time.Second, nil
+	}
+	srv := &Server{Config: Config{Name: "test", MaxPeers: 10, PrivateKey: newkey(), NoDial: true, ClockCheck: time.Hour}}
+	if srv.NodeInfo().ClockDrift != nil {
+		t.Fatalf("clock drift reported before the check")
+	}
+	if err := srv.Start(); err != nil {
+		t.Fatalf("could not start server: %v", err)
+	}
+	defer srv.Stop()
+
+	select {
+	case <-measured:
+	case <-time.After(time.Second):
+		t.Fatalf("clock drift not measured at startup")
+	}
+	for i := 0; i < 100 && srv.ClockDrift() == nil; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	info := srv.NodeInfo().ClockDrift
+	if info == nil || info.Drift != "3s" {
+		t.Fatalf("clock drift mismatch: have %+v, want 3s", info)
+	}
+}
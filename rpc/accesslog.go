@@ -0,0 +1,99 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ellaism/go-ellaism/logger"
+	"github.com/ellaism/go-ellaism/logger/glog"
+)
+
+// AccessLog records the RPC calls served by the servers it is attached to, one
+// line per call, and reports the calls slower than a threshold in the node log.
+type AccessLog struct {
+	lock sync.Mutex
+	out  io.Writer     // Destination of the access lines, nil if only tracing slow calls
+	slow time.Duration // Latency from which calls are reported as slow, 0 = disabled
+}
+
+// NewAccessLog creates an access log writing to out, which may be nil to only
+// trace the calls taking at least slow, if non zero.
+func NewAccessLog(out io.Writer, slow time.Duration) *AccessLog {
+	return &AccessLog{out: out, slow: slow}
+}
+
+// OpenAccessLog creates an access log appending to the file at path, created if
+// missing. An empty path only traces the slow calls.
+func OpenAccessLog(path string, slow time.Duration) (*AccessLog, error) {
+	if path == "" {
+		return NewAccessLog(nil, slow), nil
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return NewAccessLog(file, slow), nil
+}
+
+// Close closes the destination of the access lines, if it is closable.
+func (l *AccessLog) Close() error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	closer, ok := l.out.(io.Closer)
+	l.out = nil
+	if ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// record logs a served call: the transport and client it came through, the
+// method called, the size of its parameters, how long it took and its error.
+func (l *AccessLog) record(transport, remote, method string, size int, latency time.Duration, err error) {
+	if remote == "" {
+		remote = "-"
+	}
+	if method == "" {
+		method = "-"
+	}
+	failure := "-"
+	if err != nil {
+		failure = fmt.Sprintf("%q", err.Error())
+	}
+	if l.slow > 0 && latency >= l.slow {
+		glog.V(logger.Warn).Warnf("Slow RPC call %s over %s from %s: took %v, %d bytes of params, error %s", method, transport, remote, latency, size, failure)
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.out != nil {
+		fmt.Fprintf(l.out, "%s %s %s %s params=%d latency=%v error=%s\n",
+			time.Now().UTC().Format(time.RFC3339Nano), transport, remote, method, size, latency, failure)
+	}
+}
+
+// remoteAddresser is implemented by the connections and codecs able to tell
+// which client they are serving.
+type remoteAddresser interface {
+	RemoteAddr() string
+}
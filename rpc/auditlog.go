@@ -0,0 +1,369 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ellaism/go-ellaism/logger"
+	"github.com/ellaism/go-ellaism/logger/glog"
+)
+
+// auditedMethods are the methods outside the personal and admin namespaces
+// which use the keys of the node or change where its rewards go, and as such
+// are recorded in the audit log.
+var auditedMethods = map[string]bool{
+	"eth_sendTransaction": true,
+	"eth_sign":            true,
+	"eth_signTransaction": true,
+	"eth_deployContract":  true,
+	"miner_setEtherbase":  true,
+}
+
+// IsAuditedMethod returns whether calls to the given method are recorded in the
+// audit log: all the methods of the personal and admin namespaces, and those
+// signing with the accounts of the node or setting its etherbase.
+func IsAuditedMethod(method string) bool {
+	return strings.HasPrefix(method, "personal_") || strings.HasPrefix(method, "admin_") || auditedMethods[method]
+}
+
+// auditKeyLength is the length of the keys of the audit entry MACs.
+const auditKeyLength = 32
+
+// The events of the audit entries: each audited call is recorded before it is
+// served, and its outcome once it has been.
+const (
+	AuditCall   = "call"
+	AuditResult = "result"
+)
+
+// errAuditLogClosed is returned when recording a call in a closed audit log.
+var errAuditLogClosed = errors.New("audit log closed")
+
+// AuditEntry is a line of the audit log. Each entry holds the MAC of the one
+// before it, so that lines removed, inserted or altered after the fact break
+// the chain.
+type AuditEntry struct {
+	Time      string `json:"time"`
+	Event     string `json:"event"` // AuditCall or AuditResult
+	Transport string `json:"transport"`
+	Remote    string `json:"remote"`
+	Method    string `json:"method"`
+	Call      string `json:"call,omitempty"` // MAC of the call entry of a result entry
+	Error     string `json:"error,omitempty"`
+	Prev      string `json:"prev"`           // MAC of the previous entry, empty for the first one
+	Hash      string `json:"hash,omitempty"` // HMAC-SHA256 of the entry encoded without its MAC
+}
+
+// mac computes the MAC of the entry with the given key, which covers all its
+// fields but Hash.
+func (e AuditEntry) mac(key []byte) string {
+	e.Hash = ""
+	blob, _ := json.Marshal(e)
+	mac := hmac.New(sha256.New, key)
+	mac.Write(blob)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// AuditHead is the end of an audit log chain: the number of entries in the log
+// and the MAC of the last one.
+type AuditHead struct {
+	Count uint64 `json:"count"`
+	Hash  string `json:"hash"`
+}
+
+// AuditLog records the privileged RPC calls served by the servers it is attached
+// to as a chain of JSON lines, each authenticated by a MAC which covers the MAC
+// of the line before it. The parameters of the calls aren't recorded since they
+// may hold passphrases and keys.
+//
+// A call is recorded before it is served, and isn't served if that fails, so a
+// call missing from the log didn't run. Its outcome is recorded once served in
+// an entry of its own. Entries are synced to disk as they are written.
+//
+// The key of the MACs is kept out of the log, so that the chain can't be mended
+// after tampering without it. The head of the chain is stored in a file next to
+// the log, so that cutting trailing entries off the log is detected as well.
+//
+// What a successful check guarantees is thus that whoever altered the log since
+// it was written, if anyone, held the key, or rolled back both the log and its
+// head to an earlier state. The head is also written to the node log whenever
+// the audit log is opened and closed, so that the latter can be detected by
+// comparing it against a copy of those kept elsewhere.
+type AuditLog struct {
+	lock     sync.Mutex
+	out      io.Writer
+	key      []byte    // Key of the entry MACs
+	head     AuditHead // End of the chain written so far
+	headPath string    // File to store the head of the chain in, empty if none
+}
+
+// NewAuditLog creates an audit log writing to out, continuing the chain from the
+// given head, or starting a new one if empty.
+func NewAuditLog(out io.Writer, key []byte, head AuditHead) *AuditLog {
+	return &AuditLog{out: out, key: key, head: head}
+}
+
+// OpenAuditLog creates an audit log appending to the file at path, created if
+// missing, continuing the chain of the entries already in it. The entries are
+// checked with the given key, and against the head stored in path.head, which
+// is kept up to date.
+//
+// An entry left incomplete at the end of the file by a crash is cut off, as is
+// the head falling behind the log when the node crashed before updating it.
+func OpenAuditLog(path string, key []byte) (*AuditLog, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	head, err := openAuditChain(file, path+".head", key)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("audit log %s: %v", path, err)
+	}
+	glog.V(logger.Info).Infof("Opened RPC audit log %s: %d entries, head %s", path, head.Count, head.Hash)
+
+	log := NewAuditLog(file, key, head)
+	log.headPath = path + ".head"
+	return log, nil
+}
+
+// openAuditChain repairs the audit log file after a crash, then checks its chain
+// with the given key and against the head stored in the given file, returning
+// the head of the chain.
+func openAuditChain(file *os.File, headPath string, key []byte) (AuditHead, error) {
+	blob, err := ioutil.ReadAll(file)
+	if err != nil {
+		return AuditHead{}, err
+	}
+	// Cut off the incomplete entry of an interrupted write
+	if size := bytes.LastIndexByte(blob, '\n') + 1; size < len(blob) {
+		glog.V(logger.Warn).Infof("Cutting %d bytes of incomplete RPC audit entry off %s", len(blob)-size, file.Name())
+		if err := file.Truncate(int64(size)); err != nil {
+			return AuditHead{}, err
+		}
+		if err := file.Sync(); err != nil {
+			return AuditHead{}, err
+		}
+		blob = blob[:size]
+	}
+	// The head is stored as soon as the log is created, and thus missing only
+	// if the log is new
+	stored, err := readAuditHead(headPath)
+	if os.IsNotExist(err) && len(blob) == 0 {
+		err = writeAuditHead(headPath, stored)
+	}
+	if err != nil {
+		return AuditHead{}, err
+	}
+	head, at, err := verifyAuditChain(bytes.NewReader(blob), key, stored.Count)
+	if err != nil {
+		return AuditHead{}, err
+	}
+	if head.Count < stored.Count || at != stored.Hash {
+		return AuditHead{}, fmt.Errorf("log truncated: %d entries ending with %s, want %d ending with %s", head.Count, head.Hash, stored.Count, stored.Hash)
+	}
+	// Entries are synced before the head, which may thus be behind
+	if head != stored {
+		glog.V(logger.Warn).Infof("Advancing RPC audit log head from %d to %d entries", stored.Count, head.Count)
+		if err := writeAuditHead(headPath, head); err != nil {
+			return AuditHead{}, err
+		}
+	}
+	return head, nil
+}
+
+// readAuditHead reads the audit log head stored in the file at path.
+func readAuditHead(path string) (AuditHead, error) {
+	var head AuditHead
+
+	blob, err := ioutil.ReadFile(path)
+	if err != nil {
+		return head, err
+	}
+	if err := json.Unmarshal(blob, &head); err != nil {
+		return head, fmt.Errorf("invalid head %s: %v", path, err)
+	}
+	return head, nil
+}
+
+// VerifyAuditLog checks the chain of the audit entries read from r with the
+// given key, returning its head or an error pointing at the first broken entry.
+// Entries cut off the end of the chain aren't detected, the head has to be
+// checked against the expected one for that.
+func VerifyAuditLog(r io.Reader, key []byte) (AuditHead, error) {
+	head, _, err := verifyAuditChain(r, key, 0)
+	return head, err
+}
+
+// verifyAuditChain checks the chain of the audit entries read from r with the
+// given key, returning its head along with the MAC of the entry at the given
+// count, if the chain is that long.
+func verifyAuditChain(r io.Reader, key []byte, count uint64) (head AuditHead, at string, err error) {
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		var entry AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return AuditHead{}, "", fmt.Errorf("line %d: invalid entry: %v", line, err)
+		}
+		if entry.Prev != head.Hash {
+			return AuditHead{}, "", fmt.Errorf("line %d: chain broken: previous MAC %s, want %s", line, entry.Prev, head.Hash)
+		}
+		if mac := entry.mac(key); !hmac.Equal([]byte(entry.Hash), []byte(mac)) {
+			return AuditHead{}, "", fmt.Errorf("line %d: entry altered: MAC %s, want %s", line, entry.Hash, mac)
+		}
+		head = AuditHead{Count: head.Count + 1, Hash: entry.Hash}
+		if head.Count == count {
+			at = head.Hash
+		}
+	}
+	return head, at, scanner.Err()
+}
+
+// LoadAuditKey loads the hex encoded audit log key stored in the file at path,
+// generating and storing a new one if the file doesn't exist.
+func LoadAuditKey(path string) ([]byte, error) {
+	blob, err := ioutil.ReadFile(path)
+	if err == nil {
+		key, err := hex.DecodeString(strings.TrimSpace(string(blob)))
+		if err != nil || len(key) != auditKeyLength {
+			return nil, fmt.Errorf("invalid audit key %s", path)
+		}
+		return key, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+	key := make([]byte, auditKeyLength)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(path, []byte(hex.EncodeToString(key)), 0600); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// Close closes the destination of the audit entries, if it is closable.
+func (l *AuditLog) Close() error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.headPath != "" {
+		glog.V(logger.Info).Infof("Closing RPC audit log: %d entries, head %s", l.head.Count, l.head.Hash)
+	}
+	closer, ok := l.out.(io.Closer)
+	l.out = nil
+	if ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// begin records a call about to be served: the transport and client it came
+// through and the method called. It returns the MAC of the entry, to record the
+// outcome of the call with. The call mustn't be served if it fails.
+func (l *AuditLog) begin(transport, remote, method string) (string, error) {
+	return l.write(AuditEntry{Event: AuditCall, Transport: transport, Remote: remote, Method: method})
+}
+
+// end records the outcome of a served call whose entry has the given MAC.
+func (l *AuditLog) end(call, transport, remote, method string, err error) {
+	entry := AuditEntry{Event: AuditResult, Transport: transport, Remote: remote, Method: method, Call: call}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	if _, err := l.write(entry); err != nil {
+		glog.V(logger.Error).Infof("Failed to record outcome of RPC call %s: %v", method, err)
+	}
+}
+
+// write chains the given entry to the log, syncing it to disk if the log is a
+// file, and returns its MAC.
+func (l *AuditLog) write(entry AuditEntry) (string, error) {
+	if entry.Remote == "" {
+		entry.Remote = "-"
+	}
+	entry.Time = time.Now().UTC().Format(time.RFC3339Nano)
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.out == nil {
+		return "", errAuditLogClosed
+	}
+	entry.Prev = l.head.Hash
+	entry.Hash = entry.mac(l.key)
+
+	blob, err := json.Marshal(entry)
+	if err != nil {
+		return "", err
+	}
+	if _, err := l.out.Write(append(blob, '\n')); err != nil {
+		return "", err
+	}
+	if file, ok := l.out.(*os.File); ok {
+		if err := file.Sync(); err != nil {
+			return "", err
+		}
+	}
+	l.head = AuditHead{Count: l.head.Count + 1, Hash: entry.Hash}
+	if l.headPath != "" {
+		if err := writeAuditHead(l.headPath, l.head); err != nil {
+			glog.V(logger.Error).Infof("Failed to store RPC audit log head: %v", err)
+		}
+	}
+	return entry.Hash, nil
+}
+
+// writeAuditHead replaces the audit log head stored in the file at path.
+func writeAuditHead(path string, head AuditHead) error {
+	blob, err := json.Marshal(head)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path+".tmp", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(blob); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
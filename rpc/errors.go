@@ -95,3 +95,30 @@ func (e *shutdownError) Code() int {
 func (e *shutdownError) Error() string {
 	return "server is shutting down"
 }
+
+// issued when a call is received on a connection which has not authenticated
+// yet, on servers requiring it.
+type unauthenticatedError struct{}
+
+func (e *unauthenticatedError) Code() int {
+	return -32001
+}
+
+func (e *unauthenticatedError) Error() string {
+	return "connection not authenticated, call " + authenticateMethod + " first"
+}
+
+// issued when a call exceeds the rate or concurrency limit of its method,
+// the JSON-RPC counterpart of HTTP 429.
+type limitExceededError struct {
+	method string
+	reason string
+}
+
+func (e *limitExceededError) Code() int {
+	return -32005
+}
+
+func (e *limitExceededError) Error() string {
+	return fmt.Sprintf("limit exceeded for %s: %s", e.method, e.reason)
+}
@@ -82,6 +82,12 @@ func (client *httpClient) SupportedModules() (map[string]string, error) {
 type httpReadWriteNopCloser struct {
 	io.Reader
 	io.Writer
+	remote string // Address of the client sending the request
+}
+
+// RemoteAddr returns the address of the client sending the request.
+func (t *httpReadWriteNopCloser) RemoteAddr() string {
+	return t.remote
 }
 
 // Close does nothing and returns always nil
@@ -105,7 +111,7 @@ func newJSONHTTPHandler(srv *Server) http.HandlerFunc {
 		// create a codec that reads direct from the request body until
 		// EOF and writes the response to w and order the server to process
 		// a single request.
-		codec := NewJSONCodec(&httpReadWriteNopCloser{r.Body, w})
+		codec := NewJSONCodec(&httpReadWriteNopCloser{r.Body, w, r.RemoteAddr})
 		defer codec.Close()
 		srv.ServeSingleRequest(codec, OptionMethodInvocation)
 	}
@@ -18,18 +18,34 @@ package rpc
 
 import (
 	"encoding/json"
+	"fmt"
 	"net"
+	"os"
 )
 
+// IPCPermissions controls which local users may connect to a Unix IPC socket.
+// They are ignored for Windows named pipes.
+type IPCPermissions struct {
+	Mode  os.FileMode // Permission bits of the socket, 0 = owner only (0600)
+	Group string      // Name or id of the group to own the socket, empty = leave as created
+}
+
 // CreateIPCListener creates an listener, on Unix platforms this is a unix socket, on Windows this is a named pipe
 func CreateIPCListener(endpoint string) (net.Listener, error) {
-	return ipcListen(endpoint)
+	return ipcListen(endpoint, IPCPermissions{})
+}
+
+// CreateIPCListenerWithPermissions creates an IPC listener like CreateIPCListener,
+// giving the Unix socket the requested permissions.
+func CreateIPCListenerWithPermissions(endpoint string, perms IPCPermissions) (net.Listener, error) {
+	return ipcListen(endpoint, perms)
 }
 
 // ipcClient represent an IPC RPC client. It will connect to a given endpoint and tries to communicate with a node using
 // JSON serialization.
 type ipcClient struct {
 	endpoint string
+	token    string // Token authenticating the connections, empty if none
 	conn     net.Conn
 	out      *json.Encoder
 	in       *json.Decoder
@@ -39,11 +55,60 @@ type ipcClient struct {
 // On Unix it assumes the endpoint is the full path to a unix socket, and Windows the endpoint is an identifier for a
 // named pipe.
 func newIPCClient(endpoint string) (Client, error) {
-	conn, err := newIPCConnection(endpoint)
-	if err != nil {
+	return NewIPCClient(endpoint, "")
+}
+
+// NewIPCClient creates an IPC client like newIPCClient, authenticating each of
+// its connections with the given token if it isn't empty.
+func NewIPCClient(endpoint, token string) (Client, error) {
+	client := &ipcClient{endpoint: endpoint, token: token}
+	if err := client.connect(); err != nil {
 		return nil, err
 	}
-	return &ipcClient{endpoint: endpoint, conn: conn, in: json.NewDecoder(conn), out: json.NewEncoder(conn)}, nil
+	return client, nil
+}
+
+// connect opens a connection to the endpoint of the client, authenticated if
+// the client has a token.
+func (client *ipcClient) connect() error {
+	conn, err := newIPCConnection(client.endpoint)
+	if err != nil {
+		return err
+	}
+	client.conn = conn
+	client.in = json.NewDecoder(conn)
+	client.out = json.NewEncoder(conn)
+
+	if client.token == "" {
+		return nil
+	}
+	if err := client.authenticate(); err != nil {
+		conn.Close()
+		return err
+	}
+	return nil
+}
+
+// authenticate authenticates the connection of the client with its token.
+func (client *ipcClient) authenticate() error {
+	params, _ := json.Marshal([]string{client.token})
+	req := JSONRequest{
+		Id:      []byte("1"),
+		Version: "2.0",
+		Method:  authenticateMethod,
+		Payload: params,
+	}
+	if err := client.out.Encode(req); err != nil {
+		return err
+	}
+	var response JSONResponse
+	if err := client.in.Decode(&response); err != nil {
+		return err
+	}
+	if response.Error != nil {
+		return fmt.Errorf("authentication failed: %s", response.Error.Message)
+	}
+	return nil
 }
 
 // Send will serialize the given message and send it to the server.
@@ -56,15 +121,9 @@ func (client *ipcClient) Send(msg interface{}) error {
 	// retry once
 	client.conn.Close()
 
-	conn, err := newIPCConnection(client.endpoint)
-	if err != nil {
+	if err := client.connect(); err != nil {
 		return err
 	}
-
-	client.conn = conn
-	client.in = json.NewDecoder(conn)
-	client.out = json.NewEncoder(conn)
-
 	return client.out.Encode(msg)
 }
 
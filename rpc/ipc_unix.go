@@ -21,11 +21,14 @@ package rpc
 import (
 	"net"
 	"os"
+	"os/user"
 	"path/filepath"
+	"strconv"
 )
 
-// ipcListen will create a Unix socket on the given endpoint.
-func ipcListen(endpoint string) (net.Listener, error) {
+// ipcListen will create a Unix socket on the given endpoint, with the given
+// permissions.
+func ipcListen(endpoint string, perms IPCPermissions) (net.Listener, error) {
 	// Ensure the IPC path exists and remove any previous leftover
 	if err := os.MkdirAll(filepath.Dir(endpoint), 0751); err != nil {
 		return nil, err
@@ -35,10 +38,40 @@ func ipcListen(endpoint string) (net.Listener, error) {
 	if err != nil {
 		return nil, err
 	}
-	os.Chmod(endpoint, 0600)
+	mode := perms.Mode
+	if mode == 0 {
+		mode = 0600
+	}
+	if err := os.Chmod(endpoint, mode); err != nil {
+		l.Close()
+		return nil, err
+	}
+	if perms.Group != "" {
+		gid, err := lookupGroup(perms.Group)
+		if err != nil {
+			l.Close()
+			return nil, err
+		}
+		if err := os.Chown(endpoint, -1, gid); err != nil {
+			l.Close()
+			return nil, err
+		}
+	}
 	return l, nil
 }
 
+// lookupGroup resolves a group name or numeric id to its id.
+func lookupGroup(group string) (int, error) {
+	if gid, err := strconv.Atoi(group); err == nil {
+		return gid, nil
+	}
+	g, err := user.LookupGroup(group)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(g.Gid)
+}
+
 // newIPCConnection will connect to a Unix socket on the given endpoint.
 func newIPCConnection(endpoint string) (net.Conn, error) {
 	return net.DialUnix("unix", nil, &net.UnixAddr{Name: endpoint, Net: "unix"})
@@ -0,0 +1,129 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// +build darwin dragonfly freebsd linux nacl netbsd openbsd solaris
+
+package rpc
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+)
+
+func TestIPCPermissions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rpc-ipc-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		perms IPCPermissions
+		mode  os.FileMode
+	}{
+		{IPCPermissions{}, 0600},
+		{IPCPermissions{Mode: 0660, Group: strconv.Itoa(os.Getgid())}, 0660},
+	}
+	for i, tt := range tests {
+		endpoint := filepath.Join(dir, "test.ipc")
+		listener, err := CreateIPCListenerWithPermissions(endpoint, tt.perms)
+		if err != nil {
+			t.Fatalf("test %d: failed to listen: %v", i, err)
+		}
+		info, err := os.Stat(endpoint)
+		listener.Close()
+		if err != nil {
+			t.Fatalf("test %d: %v", i, err)
+		}
+		if mode := info.Mode().Perm(); mode != tt.mode {
+			t.Errorf("test %d: mode mismatch: have %o, want %o", i, mode, tt.mode)
+		}
+		if gid := info.Sys().(*syscall.Stat_t).Gid; int(gid) != os.Getgid() {
+			t.Errorf("test %d: group mismatch: have %d, want %d", i, gid, os.Getgid())
+		}
+	}
+	if _, err := CreateIPCListenerWithPermissions(filepath.Join(dir, "bad.ipc"), IPCPermissions{Group: "no-such-group-exists"}); err == nil {
+		t.Errorf("unknown group accepted")
+	}
+}
+
+// Tests that servers requiring authentication only serve the connections which
+// authenticated with their token.
+func TestIPCAuthentication(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rpc-ipc-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	server := NewServer()
+	if err := server.RegisterName("test", new(Service)); err != nil {
+		t.Fatal(err)
+	}
+	server.SetAuthToken("token")
+
+	endpoint := filepath.Join(dir, "test.ipc")
+	listener, err := CreateIPCListener(endpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.ServeCodec(NewJSONCodec(conn), OptionMethodInvocation)
+		}
+	}()
+	call := func(client Client) *JSONError {
+		if err := client.Send(JSONRequest{Id: []byte("2"), Version: "2.0", Method: "test_echo", Payload: []byte(`["x",1,{"S":"d"}]`)}); err != nil {
+			t.Fatal(err)
+		}
+		var response JSONResponse
+		if err := client.Recv(&response); err != nil {
+			t.Fatal(err)
+		}
+		return response.Error
+	}
+	// Unauthenticated connections are refused every call
+	client, err := NewIPCClient(endpoint, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := call(client); err == nil || err.Code != new(unauthenticatedError).Code() {
+		t.Errorf("unauthenticated call error mismatch: have %+v, want %v", err, new(unauthenticatedError))
+	}
+	client.Close()
+
+	// Authenticating with a wrong token fails, with the right one succeeds
+	if _, err := NewIPCClient(endpoint, "wrong"); err == nil {
+		t.Errorf("connection authenticated with the wrong token")
+	}
+	client, err = NewIPCClient(endpoint, "token")
+	if err != nil {
+		t.Fatalf("failed to authenticate: %v", err)
+	}
+	defer client.Close()
+	if err := call(client); err != nil {
+		t.Errorf("authenticated call failed: %+v", err)
+	}
+}
@@ -25,8 +25,9 @@ import (
 	winio "github.com/microsoft/go-winio"
 )
 
-// ipcListen will create a named pipe on the given endpoint.
-func ipcListen(endpoint string) (net.Listener, error) {
+// ipcListen will create a named pipe on the given endpoint. Unix permissions
+// don't apply to named pipes.
+func ipcListen(endpoint string, perms IPCPermissions) (net.Listener, error) {
 	return winio.ListenPipe(endpoint, &winio.PipeConfig{})
 }
 
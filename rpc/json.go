@@ -20,6 +20,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"net"
 	"reflect"
 	"strconv"
 	"strings"
@@ -64,6 +65,7 @@ type JSONError struct {
 type jsonSubscription struct {
 	Subscription string      `json:"subscription"`
 	Result       interface{} `json:"result,omitempty"`
+	Error        *JSONError  `json:"error,omitempty"`
 }
 
 // JSON-RPC notification
@@ -92,6 +94,20 @@ func NewJSONCodec(rwc io.ReadWriteCloser) ServerCodec {
 	return &jsonCodec{closed: make(chan interface{}), d: d, e: json.NewEncoder(rwc), rw: rwc}
 }
 
+// RemoteAddr returns the address of the client on the other end of the
+// connection, or an empty string if unknown.
+func (c *jsonCodec) RemoteAddr() string {
+	switch rw := c.rw.(type) {
+	case remoteAddresser:
+		return rw.RemoteAddr()
+	case net.Conn:
+		if addr := rw.RemoteAddr(); addr != nil {
+			return addr.String()
+		}
+	}
+	return ""
+}
+
 // isBatch returns true when the first non-whitespace characters is '['
 func isBatch(msg json.RawMessage) bool {
 	for _, c := range msg {
@@ -313,6 +329,13 @@ func (c *jsonCodec) CreateErrorResponseWithInfo(id interface{}, err RPCError, in
 		Error: &JSONError{Code: err.Code(), Message: err.Error(), Data: info}}
 }
 
+// CreateErrorNotification will create a JSON-RPC notification telling the subscriber of the given
+// subscription id that it ended with the given error.
+func (c *jsonCodec) CreateErrorNotification(subid string, err RPCError) interface{} {
+	return &jsonNotification{Version: JSONRPCVersion, Method: notificationMethod,
+		Params: jsonSubscription{Subscription: subid, Error: &JSONError{Code: err.Code(), Message: err.Error()}}}
+}
+
 // CreateNotification will create a JSON-RPC notification with the given subscription id and event as params.
 func (c *jsonCodec) CreateNotification(subid string, event interface{}) interface{} {
 	if isHexNum(reflect.TypeOf(event)) {
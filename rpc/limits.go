@@ -0,0 +1,219 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LimitRule caps the calls to the methods matching a pattern.
+type LimitRule struct {
+	Pattern    string  // Method such as "eth_call", namespace such as "debug_*", or "*" for all
+	Rate       float64 // Calls per second allowed on average, 0 = unlimited
+	Concurrent int     // Calls executing at the same time, 0 = unlimited
+}
+
+// ParseLimits parses a comma separated list of limit rules, each given as
+// pattern=rate:concurrent where either limit may be left empty, such as
+// "eth_call=50:8,eth_getLogs=5:2,debug_*=:1".
+func ParseLimits(spec string) ([]LimitRule, error) {
+	var rules []LimitRule
+	for _, field := range strings.Split(spec, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		parts := strings.SplitN(field, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid limit %q, want pattern=rate:concurrent", field)
+		}
+		rule := LimitRule{Pattern: parts[0]}
+		limits := strings.SplitN(parts[1], ":", 2)
+		if limits[0] != "" {
+			rate, err := strconv.ParseFloat(limits[0], 64)
+			if err != nil || rate < 0 || math.IsInf(rate, 0) {
+				return nil, fmt.Errorf("invalid rate in limit %q", field)
+			}
+			rule.Rate = rate
+		}
+		if len(limits) == 2 && limits[1] != "" {
+			concurrent, err := strconv.Atoi(limits[1])
+			if err != nil || concurrent < 0 {
+				return nil, fmt.Errorf("invalid concurrency in limit %q", field)
+			}
+			rule.Concurrent = concurrent
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// limitSweepInterval is how often the limits of idle clients are dropped.
+const limitSweepInterval = time.Minute
+
+// limit is the state of a rule for a client: a token bucket refilled at the rate
+// of the rule, holding up to a second worth of calls, and the count of the calls
+// running.
+type limit struct {
+	rule LimitRule
+
+	lock    sync.Mutex
+	tokens  float64
+	updated time.Time
+	running int
+}
+
+// idle reports whether the limit is back to the state of a new one, so that it
+// can be dropped without affecting the client.
+func (lim *limit) idle(now time.Time) bool {
+	lim.lock.Lock()
+	defer lim.lock.Unlock()
+
+	if lim.running > 0 {
+		return false
+	}
+	rate := lim.rule.Rate
+	return rate == 0 || lim.tokens+now.Sub(lim.updated).Seconds()*rate >= burst(rate)
+}
+
+// limitKey identifies the limit of a rule for a client.
+type limitKey struct {
+	client  string
+	pattern string
+}
+
+// Limiter enforces rate and concurrency limits on the RPC methods. Each call is
+// subject to the most specific rule matching its method: an exact match, then
+// the wildcard of its namespace, then "*". Every client is held to the rules on
+// its own, so that one client exhausting a limit doesn't affect the others.
+type Limiter struct {
+	rules map[string]LimitRule
+
+	lock   sync.Mutex
+	limits map[limitKey]*limit
+	swept  time.Time
+}
+
+// NewLimiter creates a limiter enforcing the given rules.
+func NewLimiter(rules []LimitRule) *Limiter {
+	l := &Limiter{
+		rules:  make(map[string]LimitRule),
+		limits: make(map[limitKey]*limit),
+		swept:  time.Now(),
+	}
+	for _, rule := range rules {
+		l.rules[rule.Pattern] = rule
+	}
+	return l
+}
+
+// burst returns how many calls a rate allows at once.
+func burst(rate float64) float64 {
+	return math.Max(1, math.Ceil(rate))
+}
+
+// find returns the most specific rule matching method, if any.
+func (l *Limiter) find(method string) (LimitRule, bool) {
+	if rule, ok := l.rules[method]; ok {
+		return rule, true
+	}
+	if i := strings.Index(method, serviceMethodSeparator); i >= 0 {
+		if rule, ok := l.rules[method[:i+1]+"*"]; ok {
+			return rule, true
+		}
+	}
+	rule, ok := l.rules["*"]
+	return rule, ok
+}
+
+// get returns the limit of rule for client, creating it if the client has none.
+// The limits of idle clients are dropped every now and then, bounding the state
+// to that of the clients calling lately. The limiter lock must be held.
+func (l *Limiter) get(client string, rule LimitRule) *limit {
+	now := time.Now()
+	if now.Sub(l.swept) >= limitSweepInterval {
+		for key, lim := range l.limits {
+			if lim.idle(now) {
+				delete(l.limits, key)
+			}
+		}
+		l.swept = now
+	}
+	key := limitKey{client, rule.Pattern}
+	lim, ok := l.limits[key]
+	if !ok {
+		lim = &limit{rule: rule, tokens: burst(rule.Rate), updated: now}
+		l.limits[key] = lim
+	}
+	return lim
+}
+
+// acquire admits a call to method by client, returning the function to call
+// once it is done, or an error if the call exceeds the limits of its method.
+func (l *Limiter) acquire(client, method string) (func(), *limitExceededError) {
+	rule, ok := l.find(method)
+	if !ok {
+		return func() {}, nil
+	}
+	// Lock the limit before releasing the limiter, so that it isn't dropped as
+	// idle before the call is counted
+	l.lock.Lock()
+	lim := l.get(client, rule)
+	lim.lock.Lock()
+	l.lock.Unlock()
+	defer lim.lock.Unlock()
+
+	if rate := lim.rule.Rate; rate > 0 {
+		now := time.Now()
+		lim.tokens = math.Min(burst(rate), lim.tokens+now.Sub(lim.updated).Seconds()*rate)
+		lim.updated = now
+		if lim.tokens < 1 {
+			return nil, &limitExceededError{method, fmt.Sprintf("more than %v calls per second", rate)}
+		}
+	}
+	if max := lim.rule.Concurrent; max > 0 && lim.running >= max {
+		return nil, &limitExceededError{method, fmt.Sprintf("more than %d concurrent calls", max)}
+	}
+	if lim.rule.Rate > 0 {
+		lim.tokens--
+	}
+	lim.running++
+
+	return func() {
+		lim.lock.Lock()
+		lim.running--
+		lim.lock.Unlock()
+	}, nil
+}
+
+// limitClient identifies the client served by codec to the limiter: the host it
+// connects from, so that its connections share the limits, or the connection
+// itself if the host is unknown.
+func limitClient(codec ServerCodec) string {
+	if addr, ok := codec.(remoteAddresser); ok {
+		if host, _, err := net.SplitHostPort(addr.RemoteAddr()); err == nil {
+			return host
+		}
+	}
+	return fmt.Sprintf("%p", codec)
+}
@@ -0,0 +1,133 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestParseLimits(t *testing.T) {
+	rules, err := ParseLimits("eth_call=50:8, eth_getLogs=0.5 ,debug_*=:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []LimitRule{
+		{Pattern: "eth_call", Rate: 50, Concurrent: 8},
+		{Pattern: "eth_getLogs", Rate: 0.5},
+		{Pattern: "debug_*", Concurrent: 1},
+	}
+	if !reflect.DeepEqual(rules, want) {
+		t.Errorf("rules mismatch: have %+v, want %+v", rules, want)
+	}
+	for _, spec := range []string{"eth_call", "=1", "eth_call=x", "eth_call=1:-1", "eth_call=-1"} {
+		if _, err := ParseLimits(spec); err == nil {
+			t.Errorf("invalid limits %q accepted", spec)
+		}
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	limiter := NewLimiter([]LimitRule{
+		{Pattern: "eth_call", Rate: 2},
+		{Pattern: "debug_*", Concurrent: 1},
+		{Pattern: "*", Rate: 1000},
+	})
+	// The rate allows a second worth of calls at once
+	for i := 0; i < 2; i++ {
+		if _, err := limiter.acquire("10.0.0.1", "eth_call"); err != nil {
+			t.Fatalf("call %d: %v", i, err)
+		}
+	}
+	if _, err := limiter.acquire("10.0.0.1", "eth_call"); err == nil || err.Code() != -32005 {
+		t.Errorf("call over the rate: have %v, want limit exceeded", err)
+	}
+	// Other methods fall back to the catch-all rule
+	if _, err := limiter.acquire("10.0.0.1", "eth_blockNumber"); err != nil {
+		t.Errorf("call under the catch-all rule: %v", err)
+	}
+	// Namespace rules cap the concurrent calls of the namespace
+	release, err := limiter.acquire("10.0.0.1", "debug_traceTransaction")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := limiter.acquire("10.0.0.1", "debug_traceBlock"); err == nil {
+		t.Errorf("concurrent call over the cap admitted")
+	}
+	release()
+	if _, err := limiter.acquire("10.0.0.1", "debug_traceBlock"); err != nil {
+		t.Errorf("call after release: %v", err)
+	}
+}
+
+// Tests that every client is held to the limits on its own.
+func TestLimiterClients(t *testing.T) {
+	limiter := NewLimiter([]LimitRule{{Pattern: "eth_call", Rate: 1, Concurrent: 1}})
+
+	release, err := limiter.acquire("10.0.0.1", "eth_call")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := limiter.acquire("10.0.0.1", "eth_call"); err == nil {
+		t.Errorf("call over the limits of the client admitted")
+	}
+	if _, err := limiter.acquire("10.0.0.2", "eth_call"); err != nil {
+		t.Errorf("call of another client: %v", err)
+	}
+	release()
+}
+
+// Tests that the limits of idle clients are dropped, and only those.
+func TestLimiterSweep(t *testing.T) {
+	limiter := NewLimiter([]LimitRule{{Pattern: "*", Concurrent: 1}})
+
+	release, err := limiter.acquire("10.0.0.1", "eth_call")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done, err := limiter.acquire("10.0.0.2", "eth_call")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done()
+	limiter.swept = limiter.swept.Add(-limitSweepInterval)
+	if _, err := limiter.acquire("10.0.0.3", "eth_call"); err != nil {
+		t.Fatal(err)
+	}
+	for client, want := range map[string]bool{"10.0.0.1": true, "10.0.0.2": false, "10.0.0.3": true} {
+		if _, ok := limiter.limits[limitKey{client, "*"}]; ok != want {
+			t.Errorf("client %s: limit kept %v, want %v", client, ok, want)
+		}
+	}
+	release()
+}
+
+func TestLimitClient(t *testing.T) {
+	codec := NewJSONCodec(&httpReadWriteNopCloser{nil, nil, "10.0.0.1:30303"})
+	if client := limitClient(codec); client != "10.0.0.1" {
+		t.Errorf("client mismatch: have %q, want %q", client, "10.0.0.1")
+	}
+	// Connections from unknown hosts are limited on their own
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+	codec1, codec2 := NewJSONCodec(c1), NewJSONCodec(c2)
+	if limitClient(codec1) == limitClient(codec2) {
+		t.Errorf("connections from unknown hosts share a client")
+	}
+}
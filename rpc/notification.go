@@ -38,6 +38,9 @@ var (
 
 	// errNotificationQueueFull is returns when there are too many notifications in the queue
 	errNotificationQueueFull = errors.New("too many pending notifications")
+
+	// errTooManySubscriptions is returned when a connection already holds as many subscriptions as allowed
+	errTooManySubscriptions = errors.New("too many subscriptions on this connection")
 )
 
 // unsubSignal is a signal that the subscription is unsubscribed. It is used to flush buffered
@@ -65,6 +68,20 @@ type Notifier interface {
 
 type notifierKey struct{}
 
+type transportKey struct{}
+
+// TransportFromContext returns the name of the transport the call in ctx came
+// through: "inproc", "ipc", "http" or "ws", or an empty string if unknown.
+func TransportFromContext(ctx context.Context) string {
+	transport, _ := ctx.Value(transportKey{}).(string)
+	return transport
+}
+
+// IsRemoteTransport reports whether the given transport is network facing.
+func IsRemoteTransport(transport string) bool {
+	return transport == "http" || transport == "ws"
+}
+
 // NotifierFromContext returns the Notifier value stored in ctx, if any.
 func NotifierFromContext(ctx context.Context) (Notifier, bool) {
 	n, ok := ctx.Value(notifierKey{}).(Notifier)
@@ -118,6 +135,7 @@ type bufferedNotifier struct {
 	queueSize     int                              // max number of items in queue
 	queue         chan *notification               // notification queue
 	stopped       bool                             // indication if this notifier is ordered to stop
+	maxSubs       int                              // max number of subscriptions, 0 = unlimited
 }
 
 // newBufferedNotifier returns a notifier that queues notifications in an internal queue
@@ -150,6 +168,9 @@ func (n *bufferedNotifier) NewSubscription(callback UnsubscribeCallback) (Subscr
 	if n.stopped {
 		return nil, errNotifierStopped
 	}
+	if n.maxSubs > 0 && len(n.subscriptions) >= n.maxSubs {
+		return nil, errTooManySubscriptions
+	}
 
 	sub := &bufferedSubscription{
 		id:               id,
@@ -165,6 +186,35 @@ func (n *bufferedNotifier) NewSubscription(callback UnsubscribeCallback) (Subscr
 	return sub, nil
 }
 
+// end tells the client its active subscriptions ended with the given error,
+// ahead of the connection being closed.
+func (n *bufferedNotifier) end(err RPCError) {
+	n.mu.Lock()
+	var ids []string
+	for id, sub := range n.subscriptions {
+		select {
+		case <-sub.pending: // active, the client knows the id
+			ids = append(ids, id)
+		default:
+		}
+	}
+	n.mu.Unlock()
+
+	for _, id := range ids {
+		if err := n.codec.Write(n.codec.CreateErrorNotification(id, err)); err != nil {
+			return
+		}
+	}
+}
+
+// count returns the number of subscriptions held by the connection.
+func (n *bufferedNotifier) count() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return len(n.subscriptions)
+}
+
 // Remove the given subscription. If subscription is not found notificationNotFoundErr is returned.
 func (n *bufferedNotifier) Unsubscribe(subid string) error {
 	n.mu.Lock()
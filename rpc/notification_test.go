@@ -117,3 +117,107 @@ func TestNotifications(t *testing.T) {
 		t.Error("unsubscribe callback not called after closing connection")
 	}
 }
+
+func TestSubscriptionLimit(t *testing.T) {
+	server := NewServer()
+	if err := server.RegisterName("eth", &NotificationTestService{}); err != nil {
+		t.Fatalf("unable to register test service %v", err)
+	}
+	server.SetConnectionLimits(1, 0)
+
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+
+	go server.ServeCodec(NewJSONCodec(serverConn), OptionMethodInvocation|OptionSubscriptions)
+
+	out := json.NewEncoder(clientConn)
+	in := json.NewDecoder(clientConn)
+
+	for i := 0; i < 2; i++ {
+		request := map[string]interface{}{
+			"id":      i,
+			"method":  "eth_subscribe",
+			"version": "2.0",
+			"params":  []interface{}{"someSubscription", 0, 0},
+		}
+		if err := out.Encode(request); err != nil {
+			t.Fatal(err)
+		}
+		var response map[string]interface{}
+		if err := in.Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+		if _, failed := response["error"]; failed != (i == 1) {
+			t.Errorf("subscription %d: response %v", i, response)
+		}
+	}
+}
+
+func TestIdleTimeout(t *testing.T) {
+	server := NewServer()
+	server.SetConnectionLimits(0, 100*time.Millisecond)
+
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+
+	go server.ServeCodec(NewJSONCodec(serverConn), OptionMethodInvocation|OptionSubscriptions)
+
+	closed := make(chan error)
+	go func() {
+		var response interface{}
+		closed <- json.NewDecoder(clientConn).Decode(&response)
+	}()
+	select {
+	case err := <-closed:
+		if err == nil {
+			t.Errorf("unexpected message on idle connection")
+		}
+	case <-time.After(2 * time.Second):
+		t.Errorf("idle connection not closed")
+	}
+}
+
+func TestStopEndsSubscriptions(t *testing.T) {
+	server := NewServer()
+	if err := server.RegisterName("eth", &NotificationTestService{}); err != nil {
+		t.Fatalf("unable to register test service %v", err)
+	}
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+
+	go server.ServeCodec(NewJSONCodec(serverConn), OptionMethodInvocation|OptionSubscriptions)
+
+	out := json.NewEncoder(clientConn)
+	in := json.NewDecoder(clientConn)
+
+	request := map[string]interface{}{
+		"id":      1,
+		"method":  "eth_subscribe",
+		"version": "2.0",
+		"params":  []interface{}{"someSubscription", 0, 0},
+	}
+	if err := out.Encode(request); err != nil {
+		t.Fatal(err)
+	}
+	var response JSONResponse
+	if err := in.Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	subid, _ := response.Result.(string)
+
+	go server.Stop()
+
+	var notification struct {
+		Method string
+		Params jsonSubscription
+	}
+	if err := in.Decode(&notification); err != nil {
+		t.Fatalf("no notification before shutdown: %v", err)
+	}
+	if notification.Method != notificationMethod || notification.Params.Subscription != subid || notification.Params.Error == nil {
+		t.Fatalf("unexpected notification: %+v", notification)
+	}
+	if err := in.Decode(new(interface{})); err == nil {
+		t.Errorf("connection not closed after shutdown")
+	}
+}
@@ -18,9 +18,13 @@ package rpc
 
 import (
 	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"reflect"
 	"runtime"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -31,12 +35,14 @@ import (
 )
 
 const (
-	stopPendingRequestTimeout = 3 * time.Second // give pending requests stopPendingRequestTimeout the time to finish when the server is stopped
+	stopPendingRequestTimeout = 3 * time.Second       // give pending requests stopPendingRequestTimeout the time to finish when the server is stopped
+	stopPendingPollInterval   = 10 * time.Millisecond // interval at which a stopping server checks for pending requests
+	stopNotifyTimeout         = time.Second           // time given to subscribers to take the notification that the server stops
 
 	notificationBufferSize = 10000 // max buffered notifications before codec is closed
 
 	MetadataApi     = "rpc"
-	DefaultIPCApis  = "admin,debug,eth,miner,net,personal,shh,txpool,web3"
+	DefaultIPCApis  = "admin,clique,debug,eth,ella,miner,net,personal,shh,txpool,web3"
 	DefaultHTTPApis = "eth,net,web3"
 )
 
@@ -51,12 +57,50 @@ const (
 	OptionSubscriptions = 1 << iota // support pub sub
 )
 
+// SetAccessLog records the calls served from now on in the given access log,
+// tagged with the transport the server is serving.
+func (s *Server) SetAccessLog(log *AccessLog, transport string) {
+	s.accessLog, s.transport = log, transport
+}
+
+// SetAuditLog records the privileged calls served from now on in the given
+// audit log, tagged with the transport the server is serving.
+func (s *Server) SetAuditLog(log *AuditLog, transport string) {
+	s.auditLog, s.transport = log, transport
+}
+
+// SetTransport sets the name of the transport the server is serving, which the
+// callbacks retrieve with TransportFromContext.
+func (s *Server) SetTransport(transport string) {
+	s.transport = transport
+}
+
+// SetAuthToken requires the connections served from now on to authenticate with
+// the given token, calling rpc_authenticate, before any other call.
+func (s *Server) SetAuthToken(token string) {
+	s.authToken = token
+}
+
+// SetLimiter enforces the limits of the given limiter on the calls served from
+// now on. A limiter may be shared by several servers.
+func (s *Server) SetLimiter(limiter *Limiter) {
+	s.limiter = limiter
+}
+
+// SetConnectionLimits caps the number of subscriptions each connection may hold
+// and closes the connections that have neither sent a request nor held any
+// subscription for idleTimeout. Zero values disable the respective limit.
+func (s *Server) SetConnectionLimits(maxSubscriptions int, idleTimeout time.Duration) {
+	s.maxSubscriptions, s.idleTimeout = maxSubscriptions, idleTimeout
+}
+
 // NewServer will create a new server instance with no registered handlers.
 func NewServer() *Server {
 	server := &Server{
 		services:      make(serviceRegistry),
 		subscriptions: make(subscriptionRegistry),
 		codecs:        set.New(),
+		notifiers:     make(map[ServerCodec]*bufferedNotifier),
 		run:           1,
 	}
 
@@ -74,6 +118,35 @@ type RPCService struct {
 	server *Server
 }
 
+// authenticateMethod is the method authenticating connections.
+const authenticateMethod = MetadataApi + "_authenticate"
+
+// errInvalidAuthToken is returned to the connections authenticating with a
+// token other than the one of the server.
+var errInvalidAuthToken = errors.New("invalid auth token")
+
+// connAuth is the authentication state of a connection to a server requiring
+// it.
+type connAuth struct {
+	done int32 // Whether the connection authenticated, accessed atomically
+}
+
+type authKey struct{}
+
+// Authenticate authenticates the connection of the call with the given token,
+// allowing it to call the other methods of servers requiring authentication.
+func (s *RPCService) Authenticate(ctx context.Context, token string) (bool, error) {
+	auth, required := ctx.Value(authKey{}).(*connAuth)
+	if !required {
+		return true, nil
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(s.server.authToken)) != 1 {
+		return false, errInvalidAuthToken
+	}
+	atomic.StoreInt32(&auth.done, 1)
+	return true, nil
+}
+
 // Modules returns the list of RPC services with their version number
 func (s *RPCService) Modules() map[string]string {
 	modules := make(map[string]string)
@@ -148,6 +221,7 @@ func (s *Server) serveRequest(codec ServerCodec, singleShot bool, options CodecO
 
 		s.codecsMu.Lock()
 		s.codecs.Remove(codec)
+		delete(s.notifiers, codec)
 		s.codecsMu.Unlock()
 
 		return
@@ -155,12 +229,19 @@ func (s *Server) serveRequest(codec ServerCodec, singleShot bool, options CodecO
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
+	ctx = context.WithValue(ctx, transportKey{}, s.transport)
+	if s.authToken != "" {
+		ctx = context.WithValue(ctx, authKey{}, new(connAuth))
+	}
 
 	// if the codec supports notification include a notifier that callbacks can use
 	// to send notification to clients. It is thight to the codec/connection. If the
 	// connection is closed the notifier will stop and cancels all active subscriptions.
+	var notifier *bufferedNotifier
 	if options&OptionSubscriptions == OptionSubscriptions {
-		ctx = context.WithValue(ctx, notifierKey{}, newBufferedNotifier(codec, notificationBufferSize))
+		notifier = newBufferedNotifier(codec, notificationBufferSize)
+		notifier.maxSubs = s.maxSubscriptions
+		ctx = context.WithValue(ctx, notifierKey{}, notifier)
 	}
 	s.codecsMu.Lock()
 	if atomic.LoadInt32(&s.run) != 1 { // server stopped
@@ -168,20 +249,38 @@ func (s *Server) serveRequest(codec ServerCodec, singleShot bool, options CodecO
 		return &shutdownError{}
 	}
 	s.codecs.Add(codec)
+	if notifier != nil {
+		s.notifiers[codec] = notifier
+	}
 	s.codecsMu.Unlock()
 
+	// track the activity of long lived connections to close them once idle
+	var activity *connActivity
+	if !singleShot && s.idleTimeout > 0 {
+		activity = &connActivity{last: time.Now()}
+		go s.closeIdle(ctx, codec, notifier, activity)
+	}
+
 	// test if the server is ordered to stop
 	for atomic.LoadInt32(&s.run) == 1 {
 		reqs, batch, err := s.readRequest(codec)
+		if activity != nil {
+			activity.begin()
+		}
 		if err != nil {
 			glog.V(logger.Debug).Infof("%v\n", err)
 			codec.Write(codec.CreateErrorResponse(nil, err))
 			return nil
 		}
+		// count the request as pending before checking for shutdown, so that
+		// a stopping server either waits for it or it is refused
+		atomic.AddInt32(&s.pending, 1)
 
 		// check if server is ordered to shutdown and return an error
 		// telling the client that his request failed.
 		if atomic.LoadInt32(&s.run) != 1 {
+			defer atomic.AddInt32(&s.pending, -1)
+
 			err = &shutdownError{}
 			if batch {
 				resps := make([]interface{}, len(reqs))
@@ -197,20 +296,93 @@ func (s *Server) serveRequest(codec ServerCodec, singleShot bool, options CodecO
 
 		if singleShot && batch {
 			s.execBatch(ctx, codec, reqs)
+			atomic.AddInt32(&s.pending, -1)
 			return nil
 		} else if singleShot && !batch {
 			s.exec(ctx, codec, reqs[0])
+			atomic.AddInt32(&s.pending, -1)
 			return nil
 		} else if !singleShot && batch {
-			go s.execBatch(ctx, codec, reqs)
+			go func() {
+				s.execBatch(ctx, codec, reqs)
+				activity.end()
+				atomic.AddInt32(&s.pending, -1)
+			}()
 		} else {
-			go s.exec(ctx, codec, reqs[0])
+			go func() {
+				s.exec(ctx, codec, reqs[0])
+				activity.end()
+				atomic.AddInt32(&s.pending, -1)
+			}()
 		}
 	}
 
 	return nil
 }
 
+// connActivity tracks when a connection was last active, and how many of its
+// requests are still being served.
+type connActivity struct {
+	lock    sync.Mutex
+	last    time.Time
+	pending int
+}
+
+// begin marks the start of a request. It is a no-op on a nil tracker.
+func (a *connActivity) begin() {
+	if a != nil {
+		a.lock.Lock()
+		a.last = time.Now()
+		a.pending++
+		a.lock.Unlock()
+	}
+}
+
+// end marks the end of a request. It is a no-op on a nil tracker.
+func (a *connActivity) end() {
+	if a != nil {
+		a.lock.Lock()
+		a.last = time.Now()
+		a.pending--
+		a.lock.Unlock()
+	}
+}
+
+// idleSince returns when the connection became idle, and false if it is
+// serving requests.
+func (a *connActivity) idleSince() (time.Time, bool) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	return a.last, a.pending == 0
+}
+
+// closeIdle closes the codec once the connection has neither served a request
+// nor held a subscription for the idle timeout of the server, until ctx is
+// cancelled.
+func (s *Server) closeIdle(ctx context.Context, codec ServerCodec, notifier *bufferedNotifier, activity *connActivity) {
+	ticker := time.NewTicker(s.idleTimeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if notifier != nil && notifier.count() > 0 {
+				activity.begin()
+				activity.end()
+				continue
+			}
+			if since, idle := activity.idleSince(); idle && time.Since(since) >= s.idleTimeout {
+				glog.V(logger.Debug).Infof("closing RPC connection idle for %v", time.Since(since))
+				codec.Close()
+				return
+			}
+		}
+	}
+}
+
 // ServeCodec reads incoming requests from codec, calls the appropriate callback and writes the
 // response back using the given codec. It will block until the codec is closed or the server is
 // stopped. In either case the codec is closed.
@@ -226,18 +398,47 @@ func (s *Server) ServeSingleRequest(codec ServerCodec, options CodecOption) {
 	s.serveRequest(codec, true, options)
 }
 
-// Stop will stop reading new requests, wait for stopPendingRequestTimeout to allow pending requests to finish,
-// close all codecs which will cancels pending requests/subscriptions.
+// Stop will stop reading new requests, wait up to stopPendingRequestTimeout for the pending requests to finish,
+// tell the subscribers their subscriptions end and close all codecs, which cancels the subscriptions. It returns
+// once the codecs are closed.
 func (s *Server) Stop() {
 	if atomic.CompareAndSwapInt32(&s.run, 1, 0) {
 		glog.V(logger.Debug).Infoln("RPC Server shutdown initiatied")
-		time.AfterFunc(stopPendingRequestTimeout, func() {
-			s.codecsMu.Lock()
-			defer s.codecsMu.Unlock()
-			s.codecs.Each(func(c interface{}) bool {
-				c.(ServerCodec).Close()
-				return true
-			})
+
+		deadline := time.Now().Add(stopPendingRequestTimeout)
+		for atomic.LoadInt32(&s.pending) > 0 && time.Now().Before(deadline) {
+			time.Sleep(stopPendingPollInterval)
+		}
+		if pending := atomic.LoadInt32(&s.pending); pending > 0 {
+			glog.V(logger.Warn).Infof("RPC server stopping with %d requests still pending", pending)
+		}
+		// tell the subscribers, not waiting on the clients too slow to take it
+		s.codecsMu.Lock()
+		var wg sync.WaitGroup
+		for _, notifier := range s.notifiers {
+			wg.Add(1)
+			go func(notifier *bufferedNotifier) {
+				defer wg.Done()
+				notifier.end(&shutdownError{})
+			}(notifier)
+		}
+		s.codecsMu.Unlock()
+
+		done := make(chan struct{})
+		go func() {
+			wg.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(stopNotifyTimeout):
+		}
+		s.codecsMu.Lock()
+		defer s.codecsMu.Unlock()
+
+		s.codecs.Each(func(c interface{}) bool {
+			c.(ServerCodec).Close()
+			return true
 		})
 	}
 }
@@ -256,33 +457,50 @@ func (s *Server) createSubscription(ctx context.Context, c ServerCodec, req *ser
 	return reply[0].Interface().(Subscription).ID(), nil
 }
 
-// handle executes a request and returns the response from the callback.
-func (s *Server) handle(ctx context.Context, codec ServerCodec, req *serverRequest) (interface{}, func()) {
+// handle executes a request and returns the response from the callback, along
+// with the error the call failed with, if any.
+func (s *Server) handle(ctx context.Context, codec ServerCodec, req *serverRequest) (interface{}, func(), error) {
 	if req.err != nil {
-		return codec.CreateErrorResponse(&req.id, req.err), nil
+		return codec.CreateErrorResponse(&req.id, req.err), nil, req.err
+	}
+	if auth, required := ctx.Value(authKey{}).(*connAuth); required && atomic.LoadInt32(&auth.done) == 0 && req.method != authenticateMethod {
+		rpcErr := new(unauthenticatedError)
+		return codec.CreateErrorResponse(&req.id, rpcErr), nil, rpcErr
 	}
 
 	if req.isUnsubscribe { // cancel subscription, first param must be the subscription id
 		if len(req.args) >= 1 && req.args[0].Kind() == reflect.String {
 			notifier, supported := NotifierFromContext(ctx)
 			if !supported { // interface doesn't support subscriptions (e.g. http)
-				return codec.CreateErrorResponse(&req.id, &callbackError{ErrNotificationsUnsupported.Error()}), nil
+				rpcErr := &callbackError{ErrNotificationsUnsupported.Error()}
+				return codec.CreateErrorResponse(&req.id, rpcErr), nil, rpcErr
 			}
 
 			subid := req.args[0].String()
 			if err := notifier.Unsubscribe(subid); err != nil {
-				return codec.CreateErrorResponse(&req.id, &callbackError{err.Error()}), nil
+				rpcErr := &callbackError{err.Error()}
+				return codec.CreateErrorResponse(&req.id, rpcErr), nil, rpcErr
 			}
 
-			return codec.CreateResponse(req.id, true), nil
+			return codec.CreateResponse(req.id, true), nil, nil
 		}
-		return codec.CreateErrorResponse(&req.id, &invalidParamsError{"Expected subscription id as first argument"}), nil
+		rpcErr := &invalidParamsError{"Expected subscription id as first argument"}
+		return codec.CreateErrorResponse(&req.id, rpcErr), nil, rpcErr
+	}
+
+	if s.limiter != nil {
+		release, err := s.limiter.acquire(limitClient(codec), req.method)
+		if err != nil {
+			return codec.CreateErrorResponse(&req.id, err), nil, err
+		}
+		defer release()
 	}
 
 	if req.callb.isSubscribe {
 		subid, err := s.createSubscription(ctx, codec, req)
 		if err != nil {
-			return codec.CreateErrorResponse(&req.id, &callbackError{err.Error()}), nil
+			rpcErr := &callbackError{err.Error()}
+			return codec.CreateErrorResponse(&req.id, rpcErr), nil, rpcErr
 		}
 
 		// active the subscription after the sub id was successful sent to the client
@@ -291,7 +509,7 @@ func (s *Server) handle(ctx context.Context, codec ServerCodec, req *serverReque
 			notifier.(*bufferedNotifier).activate(subid)
 		}
 
-		return codec.CreateResponse(req.id, subid), activateSub
+		return codec.CreateResponse(req.id, subid), activateSub, nil
 	}
 
 	// regular RPC call, prepare arguments
@@ -299,7 +517,7 @@ func (s *Server) handle(ctx context.Context, codec ServerCodec, req *serverReque
 		rpcErr := &invalidParamsError{fmt.Sprintf("%s%s%s expects %d parameters, got %d",
 			req.svcname, serviceMethodSeparator, req.callb.method.Name,
 			len(req.callb.argTypes), len(req.args))}
-		return codec.CreateErrorResponse(&req.id, rpcErr), nil
+		return codec.CreateErrorResponse(&req.id, rpcErr), nil, rpcErr
 	}
 
 	arguments := []reflect.Value{req.callb.rcvr}
@@ -313,28 +531,75 @@ func (s *Server) handle(ctx context.Context, codec ServerCodec, req *serverReque
 	// execute RPC method and return result
 	reply := req.callb.method.Func.Call(arguments)
 	if len(reply) == 0 {
-		return codec.CreateResponse(req.id, nil), nil
+		return codec.CreateResponse(req.id, nil), nil, nil
 	}
 
 	if req.callb.errPos >= 0 { // test if method returned an error
 		if !reply[req.callb.errPos].IsNil() {
 			e := reply[req.callb.errPos].Interface().(error)
-			res := codec.CreateErrorResponse(&req.id, &callbackError{e.Error()})
-			return res, nil
+			return callbackErrorResponse(codec, &req.id, e), nil, e
 		}
 	}
-	return codec.CreateResponse(req.id, reply[0].Interface()), nil
+	return codec.CreateResponse(req.id, reply[0].Interface()), nil, nil
+}
+
+// callbackErrorResponse creates the response to a callback failing with err,
+// keeping the code of errors implementing RPCError and the data of errors
+// implementing DataError.
+func callbackErrorResponse(codec ServerCodec, id interface{}, err error) interface{} {
+	rpcErr, ok := err.(RPCError)
+	if !ok {
+		rpcErr = &callbackError{err.Error()}
+	}
+	if e, ok := err.(DataError); ok {
+		return codec.CreateErrorResponseWithInfo(id, rpcErr, e.ErrorData())
+	}
+	return codec.CreateErrorResponse(id, rpcErr)
+}
+
+// serve handles a request, recording it in the access and audit logs if there
+// are any. Audited calls are recorded before they are handled, and refused if
+// that fails.
+func (s *Server) serve(ctx context.Context, codec ServerCodec, req *serverRequest) (interface{}, func()) {
+	if s.accessLog == nil && s.auditLog == nil {
+		response, callback, _ := s.handle(ctx, codec, req)
+		return response, callback
+	}
+	var remote string
+	if addr, ok := codec.(remoteAddresser); ok {
+		remote = addr.RemoteAddr()
+	}
+	audited := s.auditLog != nil && IsAuditedMethod(req.method)
+
+	var (
+		start    = time.Now()
+		response interface{}
+		callback func()
+		call     string
+		err      error
+	)
+	if audited {
+		if call, err = s.auditLog.begin(s.transport, remote, req.method); err != nil {
+			glog.V(logger.Error).Infof("Refusing RPC call %s, failed to audit it: %v", req.method, err)
+			rpcErr := &callbackError{"audit log unavailable"}
+			response, err = codec.CreateErrorResponse(&req.id, rpcErr), rpcErr
+		}
+	}
+	if response == nil {
+		response, callback, err = s.handle(ctx, codec, req)
+		if audited {
+			s.auditLog.end(call, s.transport, remote, req.method, err)
+		}
+	}
+	if s.accessLog != nil {
+		s.accessLog.record(s.transport, remote, req.method, req.size, time.Since(start), err)
+	}
+	return response, callback
 }
 
 // exec executes the given request and writes the result back using the codec.
 func (s *Server) exec(ctx context.Context, codec ServerCodec, req *serverRequest) {
-	var response interface{}
-	var callback func()
-	if req.err != nil {
-		response = codec.CreateErrorResponse(&req.id, req.err)
-	} else {
-		response, callback = s.handle(ctx, codec, req)
-	}
+	response, callback := s.serve(ctx, codec, req)
 
 	if err := codec.Write(response); err != nil {
 		glog.V(logger.Error).Infof("%v\n", err)
@@ -353,13 +618,9 @@ func (s *Server) execBatch(ctx context.Context, codec ServerCodec, requests []*s
 	responses := make([]interface{}, len(requests))
 	var callbacks []func()
 	for i, req := range requests {
-		if req.err != nil {
-			responses[i] = codec.CreateErrorResponse(&req.id, req.err)
-		} else {
-			var callback func()
-			if responses[i], callback = s.handle(ctx, codec, req); callback != nil {
-				callbacks = append(callbacks, callback)
-			}
+		var callback func()
+		if responses[i], callback = s.serve(ctx, codec, req); callback != nil {
+			callbacks = append(callbacks, callback)
 		}
 	}
 
@@ -439,5 +700,12 @@ func (s *Server) readRequest(codec ServerCodec) ([]*serverRequest, bool, RPCErro
 		requests[i] = &serverRequest{id: r.id, err: &methodNotFoundError{r.service, r.method}}
 	}
 
+	// remember what was asked for, for the access log
+	for i, r := range reqs {
+		requests[i].method = r.fullName()
+		if params, ok := r.params.(json.RawMessage); ok {
+			requests[i].size = len(params)
+		}
+	}
 	return requests, batch, nil
 }
@@ -17,12 +17,18 @@
 package rpc
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"github.com/ellaism/go-ellaism/logger/glog"
+	"io/ioutil"
 	"net"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
-	"github.com/ellaism/go-ellaism/logger/glog"
+	"time"
 )
 
 type Service struct{}
@@ -158,3 +164,329 @@ func TestServerMethodExecution(t *testing.T) {
 func TestServerMethodWithCtx(t *testing.T) {
 	testServerMethodExecution(t, "echoWithCtx")
 }
+
+func TestServerAccessLog(t *testing.T) {
+	server := NewServer()
+	if err := server.RegisterName("test", new(Service)); err != nil {
+		t.Fatal(err)
+	}
+	out := new(bytes.Buffer)
+	server.SetAccessLog(NewAccessLog(out, 0), "pipe")
+
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+
+	go server.ServeCodec(NewJSONCodec(serverConn), OptionMethodInvocation)
+
+	enc, dec := json.NewEncoder(clientConn), json.NewDecoder(clientConn)
+	requests := []string{
+		`{"jsonrpc":"2.0","id":1,"method":"test_echo","params":["abc",1,{"S":"d"}]}`,
+		`{"jsonrpc":"2.0","id":2,"method":"test_missing","params":[]}`,
+	}
+	for _, request := range requests {
+		if err := enc.Encode(json.RawMessage(request)); err != nil {
+			t.Fatal(err)
+		}
+		var response json.RawMessage
+		if err := dec.Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("access log lines mismatch: have %d, want 2:\n%s", len(lines), out)
+	}
+	if fields := strings.Fields(lines[0]); len(fields) != 7 || fields[1] != "pipe" || fields[3] != "test_echo" || fields[4] != "params=19" || fields[6] != "error=-" {
+		t.Errorf("successful call logged as %q", lines[0])
+	}
+	if !strings.Contains(lines[1], " test_missing params=2 ") || !strings.Contains(lines[1], "does not exist") {
+		t.Errorf("failed call logged as %q", lines[1])
+	}
+}
+
+func TestServerAuditLog(t *testing.T) {
+	server := NewServer()
+	if err := server.RegisterName("personal", new(Service)); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.RegisterName("test", new(Service)); err != nil {
+		t.Fatal(err)
+	}
+	out, key := new(bytes.Buffer), []byte("key")
+	server.SetAuditLog(NewAuditLog(out, key, AuditHead{}), "pipe")
+
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+
+	go server.ServeCodec(NewJSONCodec(serverConn), OptionMethodInvocation)
+
+	enc, dec := json.NewEncoder(clientConn), json.NewDecoder(clientConn)
+	requests := []string{
+		`{"jsonrpc":"2.0","id":1,"method":"personal_echo","params":["secret",1,{"S":"d"}]}`,
+		`{"jsonrpc":"2.0","id":2,"method":"test_echo","params":["abc",1,{"S":"d"}]}`,
+		`{"jsonrpc":"2.0","id":3,"method":"personal_missing","params":[]}`,
+	}
+	for _, request := range requests {
+		if err := enc.Encode(json.RawMessage(request)); err != nil {
+			t.Fatal(err)
+		}
+		var response json.RawMessage
+		if err := dec.Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Only the privileged calls are audited, without their parameters
+	log := out.String()
+	if strings.Contains(log, "secret") || strings.Contains(log, "test_echo") {
+		t.Errorf("audit log holds unexpected data:\n%s", log)
+	}
+	lines := strings.Split(strings.TrimSpace(log), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("audit log lines mismatch: have %d, want 4:\n%s", len(lines), log)
+	}
+	entries := make([]AuditEntry, len(lines))
+	for i, line := range lines {
+		if err := json.Unmarshal([]byte(line), &entries[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Each call is recorded before it is served, then its outcome
+	first, second := entries[0], entries[3]
+	if first.Event != AuditCall || first.Transport != "pipe" || first.Method != "personal_echo" || first.Prev != "" {
+		t.Errorf("successful call audited as %+v", first)
+	}
+	if e := entries[1]; e.Event != AuditResult || e.Method != "personal_echo" || e.Call != first.Hash || e.Error != "" || e.Prev != first.Hash {
+		t.Errorf("successful call outcome audited as %+v", e)
+	}
+	if e := entries[2]; e.Event != AuditCall || e.Method != "personal_missing" || e.Error != "" {
+		t.Errorf("failed call audited as %+v", e)
+	}
+	if second.Event != AuditResult || second.Call != entries[2].Hash || !strings.Contains(second.Error, "does not exist") || second.Prev != entries[2].Hash {
+		t.Errorf("failed call outcome audited as %+v", second)
+	}
+	// Check that the chain verifies, and that tampering with it is detected
+	if head, err := VerifyAuditLog(strings.NewReader(log), key); err != nil || head != (AuditHead{4, second.Hash}) {
+		t.Errorf("audit log verification failed: head %+v, error %v", head, err)
+	}
+	tampered := strings.Replace(log, "personal_echo", "personal_ecHo", 1)
+	if _, err := VerifyAuditLog(strings.NewReader(tampered), key); err == nil {
+		t.Errorf("altered audit entry verified")
+	}
+	if _, err := VerifyAuditLog(strings.NewReader(lines[1]+"\n"), key); err == nil {
+		t.Errorf("audit log missing its first entry verified")
+	}
+	// Entries whose MACs were recomputed without the key don't verify
+	first.Method = "personal_ecHo"
+	first.Hash = first.mac([]byte("other"))
+	forged, _ := json.Marshal(first)
+	if _, err := VerifyAuditLog(strings.NewReader(string(forged)+"\n"), key); err == nil {
+		t.Errorf("audit entry forged without the key verified")
+	}
+}
+
+// Tests that audit log files continue their chain when reopened, and that
+// tampering with them, including cutting entries off their end, is detected.
+func TestOpenAuditLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rpc-auditlog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	key, err := LoadAuditKey(filepath.Join(dir, "key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reloaded, err := LoadAuditKey(filepath.Join(dir, "key")); err != nil || !bytes.Equal(reloaded, key) {
+		t.Fatalf("audit key not persisted: have %x, want %x, error %v", reloaded, key, err)
+	}
+	path := filepath.Join(dir, "audit.log")
+	for i := 0; i < 2; i++ {
+		log, err := OpenAuditLog(path, key)
+		if err != nil {
+			t.Fatalf("failed to open audit log %d: %v", i, err)
+		}
+		for _, method := range []string{"personal_unlockAccount", "admin_addPeer"} {
+			call, err := log.begin("ipc", "", method)
+			if err != nil {
+				t.Fatalf("failed to audit %s: %v", method, err)
+			}
+			log.end(call, "ipc", "", method, nil)
+		}
+		if err := log.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	blob, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head, err := VerifyAuditLog(bytes.NewReader(blob), key); err != nil || head.Count != 8 {
+		t.Fatalf("reopened audit log verification failed: head %+v, error %v", head, err)
+	}
+	// Opening fails with another key, or once the last entry is cut off
+	if _, err := OpenAuditLog(path, make([]byte, len(key))); err == nil {
+		t.Errorf("audit log opened with the wrong key")
+	}
+	lines := strings.SplitAfter(string(blob), "\n")
+	if err := ioutil.WriteFile(path, []byte(strings.Join(lines[:3], "")), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenAuditLog(path, key); err == nil {
+		t.Errorf("truncated audit log opened")
+	}
+}
+
+// Tests that audit log files recover from a crash while writing an entry or
+// the head, but not from losing their head.
+func TestOpenAuditLogRecovery(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rpc-auditlog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	key, path := []byte("key"), filepath.Join(dir, "audit.log")
+	log, err := OpenAuditLog(path, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	call, err := log.begin("ipc", "", "personal_unlockAccount")
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.end(call, "ipc", "", "personal_unlockAccount", nil)
+	head := log.head
+	log.Close()
+
+	// An entry cut short, and one written without updating the head
+	next := AuditEntry{Event: AuditCall, Transport: "ipc", Remote: "-", Method: "admin_addPeer", Prev: head.Hash}
+	next.Hash = next.mac(key)
+	blob, _ := json.Marshal(next)
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Write(append(blob, '\n'))
+	file.Write(blob[:len(blob)/2])
+	file.Close()
+
+	if log, err = OpenAuditLog(path, key); err != nil {
+		t.Fatalf("failed to reopen crashed audit log: %v", err)
+	}
+	if want := (AuditHead{head.Count + 1, next.Hash}); log.head != want {
+		t.Errorf("recovered head mismatch: have %+v, want %+v", log.head, want)
+	}
+	log.Close()
+	if stored, err := readAuditHead(path + ".head"); err != nil || stored != log.head {
+		t.Errorf("stored head mismatch: have %+v, want %+v, error %v", stored, log.head, err)
+	}
+	// A log whose head is gone isn't trusted
+	if err := os.Remove(path + ".head"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenAuditLog(path, key); err == nil {
+		t.Errorf("audit log opened without its head")
+	}
+}
+
+// Tests that audited calls aren't served once they can't be recorded.
+func TestServerAuditLogClosed(t *testing.T) {
+	server := NewServer()
+	service := new(Service)
+	if err := server.RegisterName("personal", service); err != nil {
+		t.Fatal(err)
+	}
+	log := NewAuditLog(new(bytes.Buffer), []byte("key"), AuditHead{})
+	log.Close()
+	server.SetAuditLog(log, "pipe")
+
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+
+	go server.ServeCodec(NewJSONCodec(serverConn), OptionMethodInvocation)
+
+	if err := json.NewEncoder(clientConn).Encode(json.RawMessage(`{"jsonrpc":"2.0","id":1,"method":"personal_echo","params":["abc",1,{"S":"d"}]}`)); err != nil {
+		t.Fatal(err)
+	}
+	var response JSONResponse
+	if err := json.NewDecoder(clientConn).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Error == nil || response.Error.Message != "audit log unavailable" {
+		t.Errorf("unaudited call not refused: %+v", response)
+	}
+}
+
+type TransportService struct{}
+
+func (s *TransportService) Transport(ctx context.Context) string {
+	return TransportFromContext(ctx)
+}
+
+func TestServerTransport(t *testing.T) {
+	server := NewServer()
+	if err := server.RegisterName("test", new(TransportService)); err != nil {
+		t.Fatal(err)
+	}
+	server.SetTransport("ws")
+
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+
+	go server.ServeCodec(NewJSONCodec(serverConn), OptionMethodInvocation)
+
+	if err := json.NewEncoder(clientConn).Encode(json.RawMessage(`{"jsonrpc":"2.0","id":1,"method":"test_transport","params":[]}`)); err != nil {
+		t.Fatal(err)
+	}
+	var response struct {
+		Result string `json:"result"`
+	}
+	if err := json.NewDecoder(clientConn).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Result != "ws" {
+		t.Errorf("transport mismatch: have %q, want %q", response.Result, "ws")
+	}
+}
+
+type SlowService struct{}
+
+func (s *SlowService) Sleep(ms int) int {
+	time.Sleep(time.Duration(ms) * time.Millisecond)
+	return ms
+}
+
+func TestServerStopDrains(t *testing.T) {
+	server := NewServer()
+	if err := server.RegisterName("test", new(SlowService)); err != nil {
+		t.Fatal(err)
+	}
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+
+	go server.ServeCodec(NewJSONCodec(serverConn), OptionMethodInvocation)
+
+	if err := json.NewEncoder(clientConn).Encode(json.RawMessage(`{"jsonrpc":"2.0","id":1,"method":"test_sleep","params":[200]}`)); err != nil {
+		t.Fatal(err)
+	}
+	// Stop while the call is in flight, it should still be answered
+	time.Sleep(50 * time.Millisecond)
+	stopped := make(chan struct{})
+	go func() {
+		server.Stop()
+		close(stopped)
+	}()
+	var response JSONResponse
+	if err := json.NewDecoder(clientConn).Decode(&response); err != nil {
+		t.Fatalf("in flight call not answered: %v", err)
+	}
+	if response.Error != nil || response.Result != float64(200) {
+		t.Errorf("unexpected response: %+v", response)
+	}
+	select {
+	case <-stopped:
+	case <-time.After(stopPendingRequestTimeout + stopNotifyTimeout + time.Second):
+		t.Errorf("server not stopped")
+	}
+}
@@ -17,13 +17,16 @@
 package rpc
 
 import (
+	"encoding/json"
 	"fmt"
 	"math"
 	"math/big"
 	"reflect"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/ellaism/go-ellaism/common"
 	"gopkg.in/fatih/set.v0"
 )
 
@@ -61,6 +64,8 @@ type serverRequest struct {
 	args          []reflect.Value
 	isUnsubscribe bool
 	err           RPCError
+	method        string // Method as requested, for the access log
+	size          int    // Size of the raw parameters, for the access log
 }
 
 type serviceRegistry map[string]*service       // collection of services
@@ -73,9 +78,20 @@ type Server struct {
 	services      serviceRegistry
 	subscriptions subscriptionRegistry
 
-	run      int32
-	codecsMu sync.Mutex
-	codecs   *set.Set
+	run       int32
+	pending   int32 // Number of requests being served
+	codecsMu  sync.Mutex
+	codecs    *set.Set
+	notifiers map[ServerCodec]*bufferedNotifier // Notifiers of the codecs supporting subscriptions
+
+	accessLog *AccessLog // Records the calls served, nil if disabled
+	auditLog  *AuditLog  // Records the privileged calls served, nil if disabled
+	transport string     // Transport served, tagging the log entries and passed to the callbacks
+	limiter   *Limiter   // Limits enforced on the calls, nil if unlimited
+	authToken string     // Token the connections authenticate with before any other call, empty if not required
+
+	maxSubscriptions int           // Subscriptions a connection may hold, 0 = unlimited
+	idleTimeout      time.Duration // Time after which idle connections are closed, 0 = never
 }
 
 // rpcRequest represents a raw incoming RPC request
@@ -87,6 +103,20 @@ type rpcRequest struct {
 	params   interface{}
 }
 
+// fullName returns the method as requested on the wire, including the name of
+// the subscription for subscribe requests.
+func (r *rpcRequest) fullName() string {
+	switch {
+	case r.isPubSub && r.method == unsubscribeMethod:
+		return unsubscribeMethod
+	case r.isPubSub:
+		return fmt.Sprintf("%s(%s)", subscribeMethod, r.method)
+	case r.service == "" && r.method == "":
+		return ""
+	}
+	return r.service + serviceMethodSeparator + r.method
+}
+
 // RPCError implements RPC error, is add support for error codec over regular go errors
 type RPCError interface {
 	// RPC error code
@@ -95,6 +125,14 @@ type RPCError interface {
 	Error() string
 }
 
+// DataError is implemented by errors carrying additional information, which
+// is returned in the data field of the error response. Callbacks can return
+// errors implementing RPCError as well to set the error code.
+type DataError interface {
+	Error() string
+	ErrorData() interface{}
+}
+
 // ServerCodec implements reading, parsing and writing RPC messages for the server side of
 // a RPC session. Implementations must be go-routine safe since the codec can be called in
 // multiple go-routines concurrently.
@@ -111,6 +149,8 @@ type ServerCodec interface {
 	CreateErrorResponseWithInfo(id interface{}, err RPCError, info interface{}) interface{}
 	// Create notification response
 	CreateNotification(string, interface{}) interface{}
+	// Create notification ending a subscription with an error
+	CreateErrorNotification(string, RPCError) interface{}
 	// Write msg to client.
 	Write(interface{}) error
 	// Close underlying data stream
@@ -214,12 +254,22 @@ var (
 type BlockNumber int64
 
 const (
-	PendingBlockNumber = BlockNumber(-2)
-	LatestBlockNumber  = BlockNumber(-1)
+	ConfirmedBlockNumber = BlockNumber(-4)
+	SafeBlockNumber      = BlockNumber(-3)
+	PendingBlockNumber   = BlockNumber(-2)
+	LatestBlockNumber    = BlockNumber(-1)
+)
+
+// SafeBlockDepth and ConfirmedBlockDepth are the number of blocks below the head
+// of the chain the "safe" and "confirmed" block tags stand for, sparing clients
+// from hardcoding confirmation counts.
+var (
+	SafeBlockDepth      uint64 = 12
+	ConfirmedBlockDepth uint64 = 120
 )
 
 // UnmarshalJSON parses the given JSON fragement into a BlockNumber. It supports:
-// - "latest", "earliest" or "pending" as string arguments
+// - "latest", "earliest", "pending", "safe" or "confirmed" as string arguments
 // - the block number
 // Returned errors:
 // - an invalid block number error when the given argument isn't a known strings
@@ -256,6 +306,16 @@ func (bn *BlockNumber) UnmarshalJSON(data []byte) error {
 			return nil
 		}
 
+		if strBlockNumber == "safe" {
+			*bn = SafeBlockNumber
+			return nil
+		}
+
+		if strBlockNumber == "confirmed" {
+			*bn = ConfirmedBlockNumber
+			return nil
+		}
+
 		return fmt.Errorf(`invalid blocknumber %s`, data)
 	}
 
@@ -271,6 +331,50 @@ func (bn *BlockNumber) Int64() int64 {
 	return (int64)(*bn)
 }
 
+// Resolve returns the block number the "safe" and "confirmed" tags stand for
+// given the number of the chain head, the genesis if the chain is shorter than
+// their depth. Other block numbers are returned as is.
+func (bn BlockNumber) Resolve(head uint64) BlockNumber {
+	var depth uint64
+	switch bn {
+	case SafeBlockNumber:
+		depth = SafeBlockDepth
+	case ConfirmedBlockNumber:
+		depth = ConfirmedBlockDepth
+	default:
+		return bn
+	}
+	if head < depth {
+		return 0
+	}
+	return BlockNumber(head - depth)
+}
+
+// BlockNumberOrHash identifies a block either by number, including the
+// "latest", "earliest", "pending", "safe" and "confirmed" tags, or by hash.
+type BlockNumberOrHash struct {
+	BlockNumber *BlockNumber
+	BlockHash   *common.Hash
+}
+
+// UnmarshalJSON parses the given JSON fragment into a BlockNumberOrHash. A 32
+// byte hex string is taken as a block hash, anything else as a block number.
+func (bnh *BlockNumberOrHash) UnmarshalJSON(data []byte) error {
+	var hash common.Hash
+	if input := strings.TrimSpace(string(data)); len(input) == 2+2+2*common.HashLength {
+		if err := json.Unmarshal(data, &hash); err == nil {
+			*bnh = BlockNumberOrHash{BlockHash: &hash}
+			return nil
+		}
+	}
+	var number BlockNumber
+	if err := json.Unmarshal(data, &number); err != nil {
+		return err
+	}
+	*bnh = BlockNumberOrHash{BlockNumber: &number}
+	return nil
+}
+
 // Client defines the interface for go client that wants to connect to a geth RPC endpoint
 type Client interface {
 	// SupportedModules returns the collection of API's the server offers
@@ -71,3 +71,49 @@ func TestHexNumberMarshalJSON(t *testing.T) {
 		t.Fatalf("Invalid json.Marshal, expected '%s', got '%s'", exp, got)
 	}
 }
+
+func TestBlockNumberOrHashUnmarshalJSON(t *testing.T) {
+	hash := "0x5c504ed432cb51138bcf09aa5e8a410dd4a1e204ef84bfed1be16dfba1b22060"
+	numbers := map[string]BlockNumber{`"0x4d2"`: 1234, "1234": 1234, `"latest"`: LatestBlockNumber, `"pending"`: PendingBlockNumber, `"safe"`: SafeBlockNumber, `"confirmed"`: ConfirmedBlockNumber}
+	for input, want := range numbers {
+		var bnh BlockNumberOrHash
+		if err := json.Unmarshal([]byte(input), &bnh); err != nil {
+			t.Fatalf("%s: %v", input, err)
+		}
+		if bnh.BlockHash != nil || bnh.BlockNumber == nil || *bnh.BlockNumber != want {
+			t.Fatalf("%s: expected block number %d, got %+v", input, want, bnh)
+		}
+	}
+
+	var bnh BlockNumberOrHash
+	if err := json.Unmarshal([]byte(`"`+hash+`"`), &bnh); err != nil {
+		t.Fatal(err)
+	}
+	if bnh.BlockNumber != nil || bnh.BlockHash == nil || bnh.BlockHash.Hex() != hash {
+		t.Fatalf("expected block hash %s, got %+v", hash, bnh)
+	}
+
+	if err := json.Unmarshal([]byte(`"0xzz"`), &bnh); err == nil {
+		t.Fatal("expected error for invalid input")
+	}
+}
+
+func TestBlockNumberResolve(t *testing.T) {
+	tests := []struct {
+		number BlockNumber
+		head   uint64
+		want   BlockNumber
+	}{
+		{SafeBlockNumber, 1000, BlockNumber(1000 - SafeBlockDepth)},
+		{ConfirmedBlockNumber, 1000, BlockNumber(1000 - ConfirmedBlockDepth)},
+		{ConfirmedBlockNumber, ConfirmedBlockDepth - 1, 0},
+		{LatestBlockNumber, 1000, LatestBlockNumber},
+		{PendingBlockNumber, 1000, PendingBlockNumber},
+		{BlockNumber(5), 1000, BlockNumber(5)},
+	}
+	for i, tt := range tests {
+		if have := tt.number.Resolve(tt.head); have != tt.want {
+			t.Errorf("test %d: have %d, want %d", i, have, tt.want)
+		}
+	}
+}
@@ -17,11 +17,19 @@
 package rpc
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"os"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/ellaism/go-ellaism/logger"
 	"github.com/ellaism/go-ellaism/logger/glog"
@@ -29,9 +37,18 @@ import (
 	"gopkg.in/fatih/set.v0"
 )
 
+const (
+	wsPingInterval     = 30 * time.Second // Interval between the keepalive pings sent to clients
+	wsPingWriteTimeout = 10 * time.Second // Time a client has to take a ping before being dropped
+	wsPongTimeout      = 40 * time.Second // Time a client has between pongs before being dropped
+)
+
+var errNoHijacker = errors.New("websocket: response does not implement http.Hijacker")
+
 // wsReaderWriterCloser reads and write payloads from and to a websocket  connection.
 type wsReaderWriterCloser struct {
-	c *websocket.Conn
+	c   *websocket.Conn
+	wmu sync.Mutex // Serializes the writes, as pings change the payload type
 }
 
 // Read will read incoming payload data into p.
@@ -41,14 +58,167 @@ func (rw *wsReaderWriterCloser) Read(p []byte) (int, error) {
 
 // Write writes p to the websocket.
 func (rw *wsReaderWriterCloser) Write(p []byte) (int, error) {
+	rw.wmu.Lock()
+	defer rw.wmu.Unlock()
+
 	return rw.c.Write(p)
 }
 
+// ping sends a ping frame to the client, which it answers with a pong.
+func (rw *wsReaderWriterCloser) ping() error {
+	rw.wmu.Lock()
+	defer rw.wmu.Unlock()
+
+	rw.c.SetWriteDeadline(time.Now().Add(wsPingWriteTimeout))
+	defer rw.c.SetWriteDeadline(time.Time{})
+
+	payloadType := rw.c.PayloadType
+	rw.c.PayloadType = websocket.PingFrame
+	defer func() { rw.c.PayloadType = payloadType }()
+
+	_, err := rw.c.Write(nil)
+	return err
+}
+
+// keepalive pings the client periodically until done is closed, keeping the
+// connection open through proxies and closing it once the client can't be
+// reached anymore. Clients not answering the pings are dropped by the pong
+// tracking of the connection.
+func (rw *wsReaderWriterCloser) keepalive(done <-chan struct{}) {
+	ticker := time.NewTicker(wsPingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := rw.ping(); err != nil {
+				glog.V(logger.Debug).Infof("WS-RPC client %s unreachable: %v", rw.RemoteAddr(), err)
+				rw.Close()
+				return
+			}
+		}
+	}
+}
+
 // Close closes the websocket connection.
 func (rw *wsReaderWriterCloser) Close() error {
 	return rw.c.Close()
 }
 
+// RemoteAddr returns the address of the client that opened the websocket.
+func (rw *wsReaderWriterCloser) RemoteAddr() string {
+	if req := rw.c.Request(); req != nil {
+		return req.RemoteAddr
+	}
+	return ""
+}
+
+// wsPongTracker is a websocket handler tracking the pongs clients answer the
+// keepalive pings with. The read deadline of a client connection is extended by
+// wsPongTimeout on every pong, so the connection is closed once a pong is
+// missed.
+type wsPongTracker struct {
+	http.Handler
+}
+
+func (t wsPongTracker) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	t.Handler.ServeHTTP(wsPongHijacker{w}, req)
+}
+
+// wsPongHijacker hijacks the connection of a websocket client, reading it
+// through a wsPongReader.
+type wsPongHijacker struct {
+	http.ResponseWriter
+}
+
+func (w wsPongHijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errNoHijacker
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	extend := func() { conn.SetReadDeadline(time.Now().Add(wsPongTimeout)) }
+	extend()
+
+	// Anything the HTTP server already buffered is part of the frame stream
+	buffered, _ := rw.Reader.Peek(rw.Reader.Buffered())
+	reader := &wsPongReader{r: io.MultiReader(bytes.NewReader(buffered), conn), pong: extend}
+	return conn, bufio.NewReadWriter(bufio.NewReader(reader), rw.Writer), nil
+}
+
+// wsPongReader reads the frames sent by a websocket client, calling pong for
+// every pong frame.
+type wsPongReader struct {
+	r    io.Reader
+	pong func()
+
+	header []byte // Header of the frame being read
+	skip   uint64 // Payload bytes left of the frame being read
+}
+
+func (r *wsPongReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.scan(p[:n])
+	return n, err
+}
+
+// scan follows the frame boundaries through the given data of the stream.
+func (r *wsPongReader) scan(data []byte) {
+	for len(data) > 0 {
+		if r.skip > 0 {
+			n := uint64(len(data))
+			if n > r.skip {
+				n = r.skip
+			}
+			data, r.skip = data[n:], r.skip-n
+			continue
+		}
+		r.header, data = append(r.header, data[0]), data[1:]
+
+		length, ok := wsFrameLength(r.header)
+		if !ok {
+			continue
+		}
+		if r.header[0]&0x0f == websocket.PongFrame {
+			r.pong()
+		}
+		r.header, r.skip = r.header[:0], length
+	}
+}
+
+// wsFrameLength returns the payload length of a frame given its header, and
+// whether the header is complete.
+func wsFrameLength(header []byte) (uint64, bool) {
+	if len(header) < 2 {
+		return 0, false
+	}
+	size, length := 2, uint64(header[1]&0x7f)
+	switch length {
+	case 126:
+		size += 2
+	case 127:
+		size += 8
+	}
+	if header[1]&0x80 != 0 {
+		size += 4 // masking key
+	}
+	if len(header) < size {
+		return 0, false
+	}
+	switch length {
+	case 126:
+		length = uint64(binary.BigEndian.Uint16(header[2:]))
+	case 127:
+		length = binary.BigEndian.Uint64(header[2:])
+	}
+	return length, true
+}
+
 // wsHandshakeValidator returns a handler that verifies the origin during the
 // websocket upgrade process. When a '*' is specified as an allowed origins all
 // connections are accepted.
@@ -57,6 +227,7 @@ func wsHandshakeValidator(allowedOrigins []string) func(*websocket.Config, *http
 	allowAllOrigins := false
 
 	for _, origin := range allowedOrigins {
+		origin = strings.TrimSpace(origin)
 		if origin == "*" {
 			allowAllOrigins = true
 		}
@@ -87,16 +258,43 @@ func wsHandshakeValidator(allowedOrigins []string) func(*websocket.Config, *http
 	return f
 }
 
-// NewWSServer creates a new websocket RPC server around an API provider.
-func NewWSServer(allowedOrigins string, handler *Server) *http.Server {
+// NewWSServer creates a new websocket RPC server around an API provider, serving
+// at most maxConnections clients at the same time if non zero. Clients are
+// pinged periodically to keep their connections alive, and dropped if they
+// don't answer.
+func NewWSServer(allowedOrigins string, maxConnections int, handler *Server) *http.Server {
+	var connections int32 // Number of clients being served
+
+	validateOrigin := wsHandshakeValidator(strings.Split(allowedOrigins, ","))
 	return &http.Server{
-		Handler: websocket.Server{
-			Handshake: wsHandshakeValidator(strings.Split(allowedOrigins, ",")),
+		Handler: wsPongTracker{websocket.Server{
+			Handshake: func(cfg *websocket.Config, req *http.Request) error {
+				if err := validateOrigin(cfg, req); err != nil {
+					return err
+				}
+				if maxConnections > 0 && int(atomic.LoadInt32(&connections)) >= maxConnections {
+					glog.V(logger.Debug).Infof("WS-RPC connection from %s refused: %d clients connected\n", req.RemoteAddr, maxConnections)
+					return fmt.Errorf("too many connections")
+				}
+				return nil
+			},
 			Handler: func(conn *websocket.Conn) {
-				handler.ServeCodec(NewJSONCodec(&wsReaderWriterCloser{conn}),
-					OptionMethodInvocation|OptionSubscriptions)
+				// The handshake check is racy, recheck now that the connection is counted
+				if n := atomic.AddInt32(&connections, 1); maxConnections > 0 && int(n) > maxConnections {
+					atomic.AddInt32(&connections, -1)
+					conn.Close()
+					return
+				}
+				defer atomic.AddInt32(&connections, -1)
+
+				rw := &wsReaderWriterCloser{c: conn}
+				done := make(chan struct{})
+				defer close(done)
+				go rw.keepalive(done)
+
+				handler.ServeCodec(NewJSONCodec(rw), OptionMethodInvocation|OptionSubscriptions)
 			},
-		},
+		}},
 	}
 }
 
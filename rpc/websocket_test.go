@@ -0,0 +1,110 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// wsTestFrame encodes a masked client frame.
+func wsTestFrame(opcode byte, payload []byte) []byte {
+	frame := []byte{0x80 | opcode}
+	switch {
+	case len(payload) < 126:
+		frame = append(frame, 0x80|byte(len(payload)))
+	case len(payload) <= 0xffff:
+		frame = append(frame, 0x80|126, byte(len(payload)>>8), byte(len(payload)))
+	default:
+		frame = append(frame, 0x80|127, 0, 0, 0, 0, byte(len(payload)>>24), byte(len(payload)>>16), byte(len(payload)>>8), byte(len(payload)))
+	}
+	frame = append(frame, 1, 2, 3, 4)
+	return append(frame, payload...)
+}
+
+func TestWSPongReader(t *testing.T) {
+	// Payloads full of pong headers must not be mistaken for pongs
+	fake := bytes.Repeat([]byte{0x80 | websocket.PongFrame, 0}, 40000)
+
+	var stream []byte
+	stream = append(stream, wsTestFrame(websocket.TextFrame, fake[:200])...)
+	stream = append(stream, wsTestFrame(websocket.PongFrame, []byte("pong"))...)
+	stream = append(stream, wsTestFrame(websocket.BinaryFrame, fake)...)
+	stream = append(stream, wsTestFrame(websocket.PingFrame, nil)...)
+	stream = append(stream, wsTestFrame(websocket.PongFrame, nil)...)
+	stream = append(stream, wsTestFrame(websocket.TextFrame, fake[:10])...)
+
+	for _, chunked := range []bool{false, true} {
+		var (
+			pongs  int
+			source io.Reader = bytes.NewReader(stream)
+		)
+		if chunked {
+			source = iotest.OneByteReader(source)
+		}
+		reader := &wsPongReader{r: source, pong: func() { pongs++ }}
+		if _, err := ioutil.ReadAll(reader); err != nil {
+			t.Fatal(err)
+		}
+		if pongs != 2 {
+			t.Errorf("chunked %v: pong count mismatch: have %d, want 2", chunked, pongs)
+		}
+	}
+}
+
+// Tests that clients are served through the pong tracking connection.
+func TestWSServerPongTracking(t *testing.T) {
+	server := NewServer()
+	if err := server.RegisterName("test", new(Service)); err != nil {
+		t.Fatal(err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpServer := NewWSServer("*", 0, server)
+	go httpServer.Serve(listener)
+	defer listener.Close()
+
+	endpoint := "ws://" + listener.Addr().String()
+	conn, err := websocket.Dial(endpoint, "", "http://localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Write([]byte(`{"jsonrpc":"2.0","id":1,"method":"test_echo","params":["hello",1,{"S":"x"}]}`)); err != nil {
+		t.Fatal(err)
+	}
+	reply := make([]byte, 1024)
+	n, err := conn.Read(reply)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(reply[:n]), `"hello"`) {
+		t.Errorf("reply mismatch: %s", reply[:n])
+	}
+}
@@ -54,6 +54,7 @@ type btJSON struct {
 	Pre                map[string]btAccount
 	PostState          map[string]btAccount
 	Lastblockhash      string
+	Network            string
 }
 
 type btBlock struct {
@@ -148,6 +149,10 @@ func runBlockTests(homesteadBlock, gasPriceFork *big.Int, bt map[string]*BlockTe
 			glog.Infoln("Skipping block test", name)
 			continue
 		}
+		if network := test.Json.Network; network != "" && ForkConfigs[network] == nil {
+			glog.Infoln("Skipping block test", name, "for unsupported network", network)
+			continue
+		}
 		// test the block
 		if err := runBlockTest(homesteadBlock, gasPriceFork, test); err != nil {
 			return fmt.Errorf("%s: %v", name, err)
@@ -176,7 +181,14 @@ func runBlockTest(homesteadBlock, gasPriceFork *big.Int, test *BlockTest) error
 		core.DefaultConfigMainnet.ChainConfig.ForkByName("GasReprice").Block = gasPriceFork
 	}
 
-	chain, err := core.NewBlockChain(db, core.DefaultConfigMainnet.ChainConfig, ethash.NewShared(), evmux)
+	// Tests naming the network they were generated for run under the rules
+	// of that network instead of the mainnet ones
+	config := core.DefaultConfigMainnet.ChainConfig
+	if network, ok := ForkConfigs[test.Json.Network]; ok {
+		config = network
+	}
+
+	chain, err := core.NewBlockChain(db, config, ethash.NewShared(), evmux)
 	if err != nil {
 		return err
 	}
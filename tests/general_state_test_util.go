@@ -0,0 +1,200 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tests
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+
+	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/core"
+	"github.com/ellaism/go-ellaism/core/types"
+	"github.com/ellaism/go-ellaism/crypto"
+	"github.com/ellaism/go-ellaism/ethdb"
+	"github.com/ellaism/go-ellaism/logger/glog"
+	"github.com/ellaism/go-ellaism/rlp"
+)
+
+// GeneralStateTest is a test in the GeneralStateTests format. The transaction
+// lists alternatives for its data, gas limit and value, and every post state
+// selects one combination of them and holds the expected results under the
+// rules of a fork.
+type GeneralStateTest struct {
+	Env         VmEnv
+	Pre         map[string]Account
+	Transaction gstTransaction
+	Post        map[string][]gstPostState
+}
+
+type gstTransaction struct {
+	Data      []string
+	GasLimit  []string
+	GasPrice  string
+	Nonce     string
+	SecretKey string
+	To        string
+	Value     []string
+}
+
+type gstPostState struct {
+	Hash    string
+	Logs    string
+	Indexes struct {
+		Data  int
+		Gas   int
+		Value int
+	}
+}
+
+// RunGeneralStateTest runs all tests of the given GeneralStateTests file for
+// every fork with a known chain configuration.
+func RunGeneralStateTest(p string, skipTests []string) error {
+	tests := make(map[string]GeneralStateTest)
+	if err := readJsonFile(p, &tests); err != nil {
+		return err
+	}
+
+	skipTest := make(map[string]bool, len(skipTests))
+	for _, name := range skipTests {
+		skipTest[name] = true
+	}
+
+	for name, test := range tests {
+		if skipTest[name] {
+			glog.Infoln("Skipping state test", name)
+			continue
+		}
+		for fork, posts := range test.Post {
+			config, ok := ForkConfigs[fork]
+			if !ok {
+				glog.Infoln("Skipping state test", name, "for unsupported fork", fork)
+				continue
+			}
+			for i, post := range posts {
+				if err := runGeneralStateTest(config, test, post); err != nil {
+					return fmt.Errorf("%s (%s #%d): %v", name, fork, i, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// runGeneralStateTest applies the transaction selected by the post state to
+// the test's pre state through the state processor and checks the results.
+func runGeneralStateTest(config *core.ChainConfig, test GeneralStateTest, post gstPostState) error {
+	db, _ := ethdb.NewMemDatabase()
+	statedb := makePreState(db, test.Pre)
+
+	header, err := test.Env.header()
+	if err != nil {
+		return err
+	}
+	tx, err := test.Transaction.toTransaction(post, config.GetSigner(header.Number))
+	if err != nil {
+		return err
+	}
+
+	// Invalid transactions must leave the state untouched
+	snapshot := statedb.Snapshot()
+	statedb.StartRecord(tx.Hash(), common.Hash{}, 0)
+	gp := new(core.GasPool).AddGas(header.GasLimit)
+	if _, _, _, err := core.ApplyTransaction(config, nil, gp, statedb, header, tx, new(big.Int)); err != nil {
+		statedb.RevertToSnapshot(snapshot)
+	}
+
+	if root := statedb.IntermediateRoot(config.IsEIP161(header.Number)); root != common.HexToHash(post.Hash) {
+		return fmt.Errorf("post state root mismatch: have %x, want %s", root, post.Hash)
+	}
+	if post.Logs != "" {
+		data, err := rlp.EncodeToBytes(statedb.Logs())
+		if err != nil {
+			return err
+		}
+		if hash := crypto.Keccak256Hash(data); hash != common.HexToHash(post.Logs) {
+			return fmt.Errorf("logs hash mismatch: have %x, want %s", hash, post.Logs)
+		}
+	}
+	return nil
+}
+
+// header returns the header of the block the test transaction is executed in.
+func (env VmEnv) header() (*types.Header, error) {
+	header := &types.Header{
+		ParentHash: common.HexToHash(env.PreviousHash),
+		Coinbase:   common.HexToAddress(env.CurrentCoinbase),
+	}
+	var ok bool
+	if header.Difficulty, ok = new(big.Int).SetString(env.CurrentDifficulty, 0); !ok {
+		return nil, fmt.Errorf("malformed currentDifficulty %q", env.CurrentDifficulty)
+	}
+	if header.GasLimit, ok = new(big.Int).SetString(env.CurrentGasLimit, 0); !ok {
+		return nil, fmt.Errorf("malformed currentGasLimit %q", env.CurrentGasLimit)
+	}
+	if header.Number, ok = new(big.Int).SetString(env.CurrentNumber, 0); !ok {
+		return nil, fmt.Errorf("malformed currentNumber %q", env.CurrentNumber)
+	}
+	switch timestamp := env.CurrentTimestamp.(type) {
+	case float64:
+		header.Time = big.NewInt(int64(timestamp))
+	case string:
+		if header.Time, ok = new(big.Int).SetString(timestamp, 0); !ok {
+			return nil, fmt.Errorf("malformed currentTimestamp %q", timestamp)
+		}
+	default:
+		return nil, fmt.Errorf("malformed currentTimestamp %v", env.CurrentTimestamp)
+	}
+	return header, nil
+}
+
+// toTransaction returns the signed transaction selected by the indexes of the
+// given post state.
+func (tx gstTransaction) toTransaction(post gstPostState, signer types.Signer) (*types.Transaction, error) {
+	if post.Indexes.Data >= len(tx.Data) || post.Indexes.Gas >= len(tx.GasLimit) || post.Indexes.Value >= len(tx.Value) {
+		return nil, fmt.Errorf("transaction indexes out of range: %+v", post.Indexes)
+	}
+	data := common.FromHex(tx.Data[post.Indexes.Data])
+	gas, ok := new(big.Int).SetString(tx.GasLimit[post.Indexes.Gas], 0)
+	if !ok {
+		return nil, fmt.Errorf("malformed gasLimit %q", tx.GasLimit[post.Indexes.Gas])
+	}
+	value, ok := new(big.Int).SetString(tx.Value[post.Indexes.Value], 0)
+	if !ok {
+		return nil, fmt.Errorf("malformed value %q", tx.Value[post.Indexes.Value])
+	}
+	price, ok := new(big.Int).SetString(tx.GasPrice, 0)
+	if !ok {
+		return nil, fmt.Errorf("malformed gasPrice %q", tx.GasPrice)
+	}
+	nonce, err := strconv.ParseUint(tx.Nonce, 0, 64)
+	if err != nil {
+		return nil, fmt.Errorf("malformed nonce %q: %v", tx.Nonce, err)
+	}
+	key := common.FromHex(tx.SecretKey)
+	if len(key) != 32 {
+		return nil, fmt.Errorf("malformed secretKey %q", tx.SecretKey)
+	}
+
+	var unsigned *types.Transaction
+	if len(tx.To) > 2 {
+		unsigned = types.NewTransaction(nonce, common.HexToAddress(tx.To), value, gas, price, data)
+	} else {
+		unsigned = types.NewContractCreation(nonce, value, gas, price, data)
+	}
+	return unsigned.WithSigner(signer).SignECDSA(crypto.ToECDSA(key))
+}
@@ -34,6 +34,8 @@ var (
 	vmTestDir          = filepath.Join(baseDir, "VMTests")
 	rlpTestDir         = filepath.Join(baseDir, "RLPTests")
 
+	generalStateTestDir = filepath.Join(baseDir, "GeneralStateTests")
+
 	BlockSkipTests = []string{
 		// These tests are not valid, as they are out of scope for RLP and
 		// the consensus protocol.
@@ -54,6 +56,8 @@ var (
 	TransSkipTests = []string{"TransactionWithHihghNonce256"}
 	StateSkipTests = []string{}
 	VmSkipTests    = []string{}
+
+	GeneralStateSkipTests = []string{}
 )
 
 func readJson(reader io.Reader, value interface{}) error {
@@ -146,6 +146,42 @@ type VmTest struct {
 	PostStateRoot string
 }
 
+// ForkConfigs maps the fork names used by the GeneralStateTests and the
+// network names used by the BlockchainTests to the chain configurations
+// implementing the same rules. Forks without a mapping are not supported.
+var ForkConfigs = map[string]*core.ChainConfig{
+	"Homestead": makeForkConfig("homestead"),
+	"EIP150":    makeForkConfig("eip150"),
+	"Diehard":   makeForkConfig("eip160"),
+}
+
+// makeForkConfig returns a chain configuration with the Homestead difficulty
+// and the given gas table active from the genesis block.
+func makeForkConfig(gasTable string) *core.ChainConfig {
+	return &core.ChainConfig{
+		Forks: []*core.Fork{
+			{
+				Name:  "Homestead",
+				Block: big.NewInt(0),
+				Features: []*core.ForkFeature{
+					{
+						ID: "difficulty",
+						Options: core.ChainFeatureConfigOptions{
+							"type": "homestead",
+						},
+					},
+					{
+						ID: "gastable",
+						Options: core.ChainFeatureConfigOptions{
+							"type": gasTable,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
 type RuleSet struct {
 	HomesteadBlock           *big.Int
 	HomesteadGasRepriceBlock *big.Int
@@ -156,42 +192,27 @@ type RuleSet struct {
 func (r RuleSet) IsHomestead(n *big.Int) bool {
 	return n.Cmp(r.HomesteadBlock) >= 0
 }
+
+// The test suites don't cover the rules introduced after the Diehard fork.
+func (r RuleSet) IsEIP161(n *big.Int) bool   { return false }
+func (r RuleSet) MaxCodeSize(n *big.Int) int { return 0 }
+func (r RuleSet) IsEIP1014(n *big.Int) bool  { return false }
+func (r RuleSet) IsEIP145(n *big.Int) bool   { return false }
+func (r RuleSet) IsEIP1052(n *big.Int) bool  { return false }
+func (r RuleSet) IsEIP1344(n *big.Int) bool  { return false }
+func (r RuleSet) IsEIP1884(n *big.Int) bool  { return false }
+func (r RuleSet) IsEIP152(n *big.Int) bool   { return false }
+func (r RuleSet) IsEIP140(n *big.Int) bool   { return false }
+func (r RuleSet) ChainID() *big.Int          { return new(big.Int) }
+
 func (r RuleSet) GasTable(num *big.Int) *vm.GasTable {
 	if r.HomesteadGasRepriceBlock == nil || num == nil || num.Cmp(r.HomesteadGasRepriceBlock) < 0 {
-		return &vm.GasTable{
-			ExtcodeSize:     big.NewInt(20),
-			ExtcodeCopy:     big.NewInt(20),
-			Balance:         big.NewInt(20),
-			SLoad:           big.NewInt(50),
-			Calls:           big.NewInt(40),
-			Suicide:         big.NewInt(0),
-			ExpByte:         big.NewInt(10),
-			CreateBySuicide: nil,
-		}
+		return core.DefaultHomeSteadGasTable
 	}
 	if r.DiehardBlock == nil || num == nil || num.Cmp(r.DiehardBlock) < 0 {
-		return &vm.GasTable{
-			ExtcodeSize:     big.NewInt(700),
-			ExtcodeCopy:     big.NewInt(700),
-			Balance:         big.NewInt(400),
-			SLoad:           big.NewInt(200),
-			Calls:           big.NewInt(700),
-			Suicide:         big.NewInt(5000),
-			ExpByte:         big.NewInt(10),
-			CreateBySuicide: big.NewInt(25000),
-		}
-	}
-
-	return &vm.GasTable{
-		ExtcodeSize:     big.NewInt(700),
-		ExtcodeCopy:     big.NewInt(700),
-		Balance:         big.NewInt(400),
-		SLoad:           big.NewInt(200),
-		Calls:           big.NewInt(700),
-		Suicide:         big.NewInt(5000),
-		ExpByte:         big.NewInt(50),
-		CreateBySuicide: big.NewInt(25000),
+		return core.DefaultGasRepriceGasTable
 	}
+	return core.DefaultDiehardGasTable
 }
 
 type Env struct {
@@ -263,9 +284,13 @@ func (self *Env) Difficulty() *big.Int     { return self.difficulty }
 func (self *Env) Db() vm.Database          { return self.state }
 func (self *Env) GasLimit() *big.Int       { return self.gasLimit }
 func (self *Env) VmType() vm.Type          { return vm.StdVmTy }
+func (self *Env) Tracer() vm.Tracer        { return nil }
 func (self *Env) GetHash(n uint64) common.Hash {
 	return common.BytesToHash(crypto.Keccak256([]byte(big.NewInt(int64(n)).String())))
 }
+func (self *Env) OpcodeMeter() vm.OpcodeMeter {
+	return nil
+}
 func (self *Env) AddLog(log *vm.Log) {
 	self.state.AddLog(log)
 }
@@ -338,6 +363,18 @@ func (self *Env) Create(caller vm.ContractRef, data []byte, gas, price, value *b
 	}
 }
 
+func (self *Env) Create2(caller vm.ContractRef, data []byte, gas, price, value, salt *big.Int) ([]byte, common.Address, error) {
+	if self.vmTest {
+		caller.ReturnGas(gas, price)
+
+		obj := self.state.GetOrNewStateObject(crypto.CreateAddress2(caller.Address(), common.BigToHash(salt), crypto.Keccak256(data)))
+
+		return nil, obj.Address(), nil
+	} else {
+		return core.Create2(self, caller, data, gas, price, value, salt)
+	}
+}
+
 type Message struct {
 	from              common.Address
 	to                *common.Address
@@ -359,3 +396,4 @@ func (self Message) Gas() *big.Int                         { return self.gas }
 func (self Message) Value() *big.Int                       { return self.value }
 func (self Message) Nonce() uint64                         { return self.nonce }
 func (self Message) Data() []byte                          { return self.data }
+func (self Message) AccessList() types.AccessList          { return nil }
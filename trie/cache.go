@@ -0,0 +1,119 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package trie
+
+import (
+	"container/list"
+	"sync"
+)
+
+// nodes is the cache of encoded trie nodes shared by all tries of the
+// process. It is disabled until given a size with SetCacheSize.
+var nodes = newNodeCache(0)
+
+// SetCacheSize sets the number of bytes of encoded trie nodes kept in memory
+// after being read from the database, evicting the least recently used nodes
+// beyond it. A size of zero disables the cache.
+//
+// Nodes are cached by hash regardless of the database they were read from,
+// so the cache should only be enabled in processes using a single database.
+func SetCacheSize(size int) {
+	nodes.resize(size)
+}
+
+// CacheSize returns the size in bytes of the trie node cache.
+func CacheSize() int {
+	nodes.lock.Lock()
+	defer nodes.lock.Unlock()
+	return nodes.capacity
+}
+
+// nodeCache is an LRU cache of encoded nodes keyed by hash, bounded by the
+// total size of the nodes.
+type nodeCache struct {
+	capacity int                      // Maximum total size of the cached nodes
+	size     int                      // Current total size of the cached nodes
+	items    map[string]*list.Element // Cached nodes by hash
+	lru      *list.List               // Cached nodes, most recently used first
+
+	lock sync.Mutex
+}
+
+type nodeCacheEntry struct {
+	hash string
+	enc  []byte
+}
+
+func newNodeCache(capacity int) *nodeCache {
+	return &nodeCache{
+		capacity: capacity,
+		items:    make(map[string]*list.Element),
+		lru:      list.New(),
+	}
+}
+
+// get returns the encoded node with the given hash, if cached.
+func (c *nodeCache) get(hash []byte) ([]byte, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if elem, ok := c.items[string(hash)]; ok {
+		c.lru.MoveToFront(elem)
+		return elem.Value.(*nodeCacheEntry).enc, true
+	}
+	return nil, false
+}
+
+// add caches the encoded node with the given hash. enc must not be modified
+// afterwards.
+func (c *nodeCache) add(hash []byte, enc []byte) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if len(enc) > c.capacity {
+		return
+	}
+	if elem, ok := c.items[string(hash)]; ok {
+		c.lru.MoveToFront(elem)
+		return
+	}
+	entry := &nodeCacheEntry{hash: string(hash), enc: enc}
+	c.items[entry.hash] = c.lru.PushFront(entry)
+	c.size += len(enc)
+	c.evict()
+}
+
+// resize changes the capacity of the cache, evicting nodes if it shrinks.
+func (c *nodeCache) resize(capacity int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.capacity = capacity
+	c.evict()
+}
+
+// evict drops the least recently used nodes until the cache fits its
+// capacity. The lock must be held.
+func (c *nodeCache) evict() {
+	for c.size > c.capacity {
+		elem := c.lru.Back()
+		entry := elem.Value.(*nodeCacheEntry)
+		c.lru.Remove(elem)
+		delete(c.items, entry.hash)
+		c.size -= len(entry.enc)
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package trie
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ellaism/go-ellaism/ethdb"
+)
+
+// Tests that the node cache evicts the least recently used nodes once over
+// its size, and when shrunk.
+func TestNodeCacheEviction(t *testing.T) {
+	cache := newNodeCache(10)
+	cache.add([]byte("a"), make([]byte, 4))
+	cache.add([]byte("b"), make([]byte, 4))
+	cache.get([]byte("a"))
+	cache.add([]byte("c"), make([]byte, 4))
+
+	if _, ok := cache.get([]byte("b")); ok {
+		t.Error("least recently used node not evicted")
+	}
+	for _, hash := range []string{"a", "c"} {
+		if _, ok := cache.get([]byte(hash)); !ok {
+			t.Errorf("node %s evicted", hash)
+		}
+	}
+	cache.add([]byte("d"), make([]byte, 11))
+	if _, ok := cache.get([]byte("d")); ok {
+		t.Error("node larger than the cache was cached")
+	}
+
+	cache.resize(4)
+	if _, ok := cache.get([]byte("a")); ok {
+		t.Error("node not evicted when shrinking the cache")
+	}
+	if cache.size != 4 {
+		t.Errorf("cache size mismatch: have %d, want 4", cache.size)
+	}
+}
+
+// Tests that nodes read from the database are served from the cache.
+func TestNodeCacheResolve(t *testing.T) {
+	SetCacheSize(1024 * 1024)
+	defer SetCacheSize(0)
+
+	db, trie, content := makeTestTrie()
+	root, _ := trie.Commit()
+
+	// Read everything once to fill the cache, then drop the database
+	trie, _ = New(root, db)
+	for key := range content {
+		trie.Get([]byte(key))
+	}
+	empty, _ := ethdb.NewMemDatabase()
+	trie, err := New(root, empty)
+	if err != nil {
+		t.Fatalf("failed to open trie from the cache: %v", err)
+	}
+	for key, want := range content {
+		if have, err := trie.TryGet([]byte(key)); err != nil || !bytes.Equal(have, want) {
+			t.Errorf("entry %x: have %x (%v), want %x", key, have, err, want)
+		}
+	}
+}
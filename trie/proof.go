@@ -18,8 +18,11 @@ package trie
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 
+	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/crypto/sha3"
 	"github.com/ellaism/go-ellaism/logger"
 	"github.com/ellaism/go-ellaism/logger/glog"
 	"github.com/ellaism/go-ellaism/rlp"
@@ -83,3 +86,79 @@ func (t *Trie) Prove(key []byte) []rlp.RawValue {
 	}
 	return proof
 }
+
+// VerifyProof checks merkle proofs. The given proof must contain the
+// value for key in a trie with the given root hash. VerifyProof
+// returns an error if the proof contains invalid trie nodes or the
+// wrong value, and a nil value if the proof shows the trie doesn't
+// contain key.
+func VerifyProof(rootHash common.Hash, key []byte, proof []rlp.RawValue) (value []byte, err error) {
+	key = compactHexDecode(key)
+	sha := sha3.NewKeccak256()
+	wantHash := rootHash.Bytes()
+	for i, buf := range proof {
+		sha.Reset()
+		sha.Write(buf)
+		if !bytes.Equal(sha.Sum(nil), wantHash) {
+			return nil, fmt.Errorf("bad proof node %d: hash mismatch", i)
+		}
+		n, err := decodeNode(wantHash, buf)
+		if err != nil {
+			return nil, fmt.Errorf("bad proof node %d: %v", i, err)
+		}
+		keyrest, cld, err := get(n, key)
+		if err != nil {
+			return nil, fmt.Errorf("bad proof node %d: %v", i, err)
+		}
+		switch cld := cld.(type) {
+		case nil:
+			if i != len(proof)-1 {
+				return nil, fmt.Errorf("key mismatch at proof node %d", i)
+			} else {
+				// The trie doesn't contain the key.
+				return nil, nil
+			}
+		case hashNode:
+			key = keyrest
+			wantHash = cld
+		case valueNode:
+			if i != len(proof)-1 {
+				return nil, errors.New("additional nodes at end of proof")
+			}
+			return cld, nil
+		}
+	}
+	return nil, errors.New("unexpected end of proof")
+}
+
+// get follows key down from the proof node tn. It returns the rest of the key
+// along with the hash of the next proof node if the path continues there, the
+// value if the path ends at one, or nil if the node proves the key isn't in the
+// trie. Nodes not fitting the key, which well formed proofs don't hold, are
+// reported as errors.
+func get(tn node, key []byte) ([]byte, node, error) {
+	for len(key) > 0 {
+		switch n := tn.(type) {
+		case *shortNode:
+			if len(key) < len(n.Key) || !bytes.Equal(n.Key, key[:len(n.Key)]) {
+				return nil, nil, nil
+			}
+			tn = n.Val
+			key = key[len(n.Key):]
+		case *fullNode:
+			tn = n.Children[key[0]]
+			key = key[1:]
+		case hashNode:
+			return key, n, nil
+		case nil:
+			return key, nil, nil
+		default:
+			return nil, nil, fmt.Errorf("%T: invalid node: %v", tn, tn)
+		}
+	}
+	value, ok := tn.(valueNode)
+	if !ok {
+		return nil, nil, fmt.Errorf("%T: invalid node at end of key: %v", tn, tn)
+	}
+	return nil, value, nil
+}
@@ -17,15 +17,41 @@
 package trie
 
 import (
+	"sync/atomic"
+
 	"github.com/ellaism/go-ellaism/common"
 	"github.com/ellaism/go-ellaism/logger"
 	"github.com/ellaism/go-ellaism/logger/glog"
+	"github.com/ellaism/go-ellaism/rlp"
 )
 
-var secureKeyPrefix = []byte("secure-key-")
+// SecureKeyPrefix prefixes the database keys of the preimages of secure trie
+// keys, which are followed by the keccak256 hash of the preimage.
+var SecureKeyPrefix = []byte("secure-key-")
 
 const secureKeyLength = 11 + 32 // Length of the above prefix + 32byte hash
 
+// recordPreimages is non-zero if secure tries write the preimages of their keys
+// to the database when committed.
+var recordPreimages int32 = 1
+
+// SetPreimageRecording sets whether secure tries write the preimages of their
+// keys to the database when committed. Without them, the keys of the tries
+// committed since can only be looked up in memory, until the next commit.
+func SetPreimageRecording(enabled bool) {
+	var v int32
+	if enabled {
+		v = 1
+	}
+	atomic.StoreInt32(&recordPreimages, v)
+}
+
+// PreimageRecording returns whether secure tries write the preimages of their
+// keys to the database when committed.
+func PreimageRecording() bool {
+	return atomic.LoadInt32(&recordPreimages) != 0
+}
+
 // SecureTrie wraps a trie with key hashing. In a secure trie, all
 // access operations hash the key using keccak256. This prevents
 // calling code from creating long chains of nodes that
@@ -164,16 +190,19 @@ func (t *SecureTrie) NodeIterator() *NodeIterator {
 }
 
 // CommitTo writes all nodes and the secure hash pre-images to the given database.
-// Nodes are stored with their sha3 hash as the key.
+// Nodes are stored with their sha3 hash as the key. The pre-images are only
+// written if preimage recording is enabled.
 //
 // Committing flushes nodes from memory. Subsequent Get calls will load nodes from
 // the trie's database. Calling code must ensure that the changes made to db are
 // written back to the trie's attached database before using the trie.
 func (t *SecureTrie) CommitTo(db DatabaseWriter) (root common.Hash, err error) {
 	if len(t.getSecKeyCache()) > 0 {
-		for hk, key := range t.secKeyCache {
-			if err := db.Put(t.secKey([]byte(hk)), key); err != nil {
-				return common.Hash{}, err
+		if PreimageRecording() {
+			for hk, key := range t.secKeyCache {
+				if err := db.Put(t.secKey([]byte(hk)), key); err != nil {
+					return common.Hash{}, err
+				}
 			}
 		}
 		t.secKeyCache = make(map[string][]byte)
@@ -185,11 +214,17 @@ func (t *SecureTrie) CommitTo(db DatabaseWriter) (root common.Hash, err error) {
 // The caller must not hold onto the return value because it will become
 // invalid on the next call to hashKey or secKey.
 func (t *SecureTrie) secKey(key []byte) []byte {
-	buf := append(t.secKeyBuf[:0], secureKeyPrefix...)
+	buf := append(t.secKeyBuf[:0], SecureKeyPrefix...)
 	buf = append(buf, key...)
 	return buf
 }
 
+// Prove constructs a merkle proof for key, which is hashed like for all
+// other access operations. See Trie.Prove for the format of the proof.
+func (t *SecureTrie) Prove(key []byte) []rlp.RawValue {
+	return t.trie.Prove(t.hashKey(key))
+}
+
 // hashKey returns the hash of key as an ephemeral buffer.
 // The caller must not hold onto the return value because it will become
 // invalid on the next call to hashKey or secKey.
@@ -106,6 +106,27 @@ func TestSecureGetKey(t *testing.T) {
 	}
 }
 
+func TestSecurePreimageRecording(t *testing.T) {
+	defer SetPreimageRecording(true)
+
+	for _, record := range []bool{true, false} {
+		SetPreimageRecording(record)
+
+		trie := newEmptySecure()
+		trie.Update([]byte("foo"), []byte("bar"))
+		if _, err := trie.Commit(); err != nil {
+			t.Fatalf("record %v: commit failed: %v", record, err)
+		}
+		k := trie.GetKey(crypto.Keccak256([]byte("foo")))
+		if record && !bytes.Equal(k, []byte("foo")) {
+			t.Errorf("record %v: GetKey returned %q, want %q", record, k, "foo")
+		}
+		if !record && k != nil {
+			t.Errorf("record %v: GetKey returned %q, want nil", record, k)
+		}
+	}
+}
+
 func TestSecureTrieConcurrency(t *testing.T) {
 	// Create an initial trie and copy if for concurrent access
 	_, trie, _ := makeTestSecureTrie()
@@ -61,14 +61,20 @@ type TrieSync struct {
 	database ethdb.Database           // State database for storing all the assembled node data
 	requests map[common.Hash]*request // Pending requests pertaining to a key hash
 	queue    *prque.Prque             // Priority queue with the pending requests
+	bloom    *SyncBloom               // Bloom of the nodes stored in the database (nil = look all up)
+
+	membatch map[common.Hash][]byte // Nodes committed by the batch being processed, not yet in the database
 }
 
-// NewTrieSync creates a new trie data download scheduler.
-func NewTrieSync(root common.Hash, database ethdb.Database, callback TrieSyncLeafCallback) *TrieSync {
+// NewTrieSync creates a new trie data download scheduler. The bloom, if any,
+// spares the database lookups of the nodes it knows to be missing.
+func NewTrieSync(root common.Hash, database ethdb.Database, callback TrieSyncLeafCallback, bloom *SyncBloom) *TrieSync {
 	ts := &TrieSync{
 		database: database,
 		requests: make(map[common.Hash]*request),
 		queue:    prque.New(),
+		bloom:    bloom,
+		membatch: make(map[common.Hash][]byte),
 	}
 	ts.AddSubTrie(root, 0, common.Hash{}, callback)
 	return ts
@@ -81,8 +87,7 @@ func (s *TrieSync) AddSubTrie(root common.Hash, depth int, parent common.Hash, c
 		return
 	}
 	key := root.Bytes()
-	blob, _ := s.database.Get(key)
-	if local, err := decodeNode(key, blob); local != nil && err == nil {
+	if local, err := decodeNode(key, s.local(key)); local != nil && err == nil {
 		return
 	}
 	// Assemble the new sub-trie sync request
@@ -114,7 +119,7 @@ func (s *TrieSync) AddRawEntry(hash common.Hash, depth int, parent common.Hash)
 	if hash == emptyState {
 		return
 	}
-	if blob, _ := s.database.Get(hash.Bytes()); blob != nil {
+	if blob := s.local(hash.Bytes()); blob != nil {
 		return
 	}
 	// Assemble the new sub-trie sync request
@@ -143,8 +148,16 @@ func (s *TrieSync) Missing(max int) []common.Hash {
 	return requests
 }
 
-// Process injects a batch of retrieved trie nodes data.
-func (s *TrieSync) Process(results []SyncResult) (int, error) {
+// Process injects a batch of retrieved trie nodes data. The nodes completed by
+// the batch are written to the database at once.
+func (s *TrieSync) Process(results []SyncResult) (index int, err error) {
+	batch := s.database.NewBatch()
+	defer func() {
+		if werr := batch.Write(); werr != nil && err == nil {
+			index, err = len(results), werr
+		}
+		s.membatch = make(map[common.Hash][]byte)
+	}()
 	for i, item := range results {
 		// If the item was not requested, bail out
 		request := s.requests[item.Hash]
@@ -154,7 +167,9 @@ func (s *TrieSync) Process(results []SyncResult) (int, error) {
 		// If the item is a raw entry request, commit directly
 		if request.object == nil {
 			request.data = item.Data
-			s.commit(request, nil)
+			if err := s.commit(request, batch); err != nil {
+				return i, err
+			}
 			continue
 		}
 		// Decode the node data content and update the request
@@ -171,7 +186,9 @@ func (s *TrieSync) Process(results []SyncResult) (int, error) {
 			return i, err
 		}
 		if len(requests) == 0 && request.deps == 0 {
-			s.commit(request, nil)
+			if err := s.commit(request, batch); err != nil {
+				return i, err
+			}
 			continue
 		}
 		request.deps += len(requests)
@@ -245,8 +262,7 @@ func (s *TrieSync) children(req *request) ([]*request, error) {
 		// If the child references another node, resolve or schedule
 		if node, ok := (*child.node).(hashNode); ok {
 			// Try to resolve the node from the local database
-			blob, _ := s.database.Get(node)
-			if local, err := decodeNode(node[:], blob); local != nil && err == nil {
+			if local, err := decodeNode(node[:], s.local(node)); local != nil && err == nil {
 				*child.node = local
 				continue
 			}
@@ -263,21 +279,20 @@ func (s *TrieSync) children(req *request) ([]*request, error) {
 	return requests, nil
 }
 
-// commit finalizes a retrieval request and stores it into the database. If any
-// of the referencing parent requests complete due to this commit, they are also
-// committed themselves.
-func (s *TrieSync) commit(req *request, batch ethdb.Batch) (err error) {
-	// Create a new batch if none was specified
-	if batch == nil {
-		batch = s.database.NewBatch()
-		defer func() {
-			err = batch.Write()
-		}()
-	}
+// commit finalizes a retrieval request and stores it into the database batch.
+// If any of the referencing parent requests complete due to this commit, they
+// are also committed themselves.
+func (s *TrieSync) commit(req *request, batch ethdb.Batch) error {
 	// Write the node content to disk
 	if err := batch.Put(req.hash[:], req.data); err != nil {
 		return err
 	}
+	s.membatch[req.hash] = req.data
+	if s.bloom != nil {
+		if err := s.bloom.Add(req.hash[:]); err != nil {
+			return err
+		}
+	}
 	delete(s.requests, req.hash)
 
 	// Check all parents for completion
@@ -291,3 +306,86 @@ func (s *TrieSync) commit(req *request, batch ethdb.Batch) (err error) {
 	}
 	return nil
 }
+
+// local retrieves a node from the batch being processed or the database. The
+// database lookup is skipped if the bloom knows the node is missing.
+func (s *TrieSync) local(hash []byte) []byte {
+	if blob, ok := s.membatch[common.BytesToHash(hash)]; ok {
+		return blob
+	}
+	if s.bloom != nil && !s.bloom.Contains(hash) {
+		return nil
+	}
+	blob, _ := s.database.Get(hash)
+	return blob
+}
+
+// Heal walks the trie of the given root as stored in the database, scheduling
+// the retrieval of the subtries missing from it, which an interrupted sync may
+// have left behind. Missing subtries are synced with the given callback, while
+// the leaf function is invoked with the values stored, for the caller to heal
+// the entries they reference. It returns the number of subtries scheduled.
+func (s *TrieSync) Heal(root common.Hash, depth int, callback TrieSyncLeafCallback, leaf func(value []byte) (int, error)) (int, error) {
+	if root == emptyRoot {
+		return 0, nil
+	}
+	return s.heal(hashNode(root.Bytes()), depth, callback, leaf)
+}
+
+// HealRawEntry schedules the retrieval of a raw state entry if it is missing
+// from the database, returning the number of entries scheduled.
+func (s *TrieSync) HealRawEntry(hash common.Hash, depth int) int {
+	if hash == emptyState || s.requests[hash] != nil {
+		return 0
+	}
+	if blob, _ := s.database.Get(hash.Bytes()); blob != nil {
+		return 0
+	}
+	s.AddRawEntry(hash, depth, common.Hash{})
+	return 1
+}
+
+func (s *TrieSync) heal(n node, depth int, callback TrieSyncLeafCallback, leaf func([]byte) (int, error)) (int, error) {
+	switch n := n.(type) {
+	case nil:
+		return 0, nil
+
+	case valueNode:
+		if leaf == nil {
+			return 0, nil
+		}
+		return leaf(n)
+
+	case *shortNode:
+		return s.heal(n.Val, depth+len(n.Key), callback, leaf)
+
+	case *fullNode:
+		healed := 0
+		for _, child := range n.Children {
+			count, err := s.heal(child, depth+1, callback, leaf)
+			if err != nil {
+				return healed, err
+			}
+			healed += count
+		}
+		return healed, nil
+
+	case hashNode:
+		hash := common.BytesToHash(n)
+		if s.requests[hash] != nil {
+			return 0, nil
+		}
+		// The bloom isn't consulted, it misses the nodes not stored by a sync
+		blob, _ := s.database.Get(n)
+		local, err := decodeNode(n, blob)
+		if local == nil || err != nil {
+			object := node(n)
+			s.schedule(&request{object: &object, hash: hash, depth: depth, callback: callback})
+			return 1, nil
+		}
+		return s.heal(local, depth, callback, leaf)
+
+	default:
+		panic(fmt.Sprintf("unknown node: %+v", n))
+	}
+}
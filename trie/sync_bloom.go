@@ -0,0 +1,120 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package trie
+
+import (
+	"encoding/binary"
+	"sync"
+
+	"github.com/ellaism/go-ellaism/common"
+	"github.com/ellaism/go-ellaism/ethdb"
+)
+
+// syncBloomKey is the database key the sync bloom is persisted under.
+var syncBloomKey = []byte("trie-sync-bloom")
+
+// syncBloomFlushInterval is the number of nodes added to the sync bloom after
+// which it is persisted, bounding the nodes retrieved again after a crash.
+const syncBloomFlushInterval = 100000
+
+// SyncBloom is a bloom filter of the trie nodes stored in a database by the
+// trie syncs, persisted across restarts. Nodes it doesn't contain are known to
+// be missing without looking them up in the database, which for a state sync
+// is the case of most of them.
+//
+// The bloom is expected to be created along with the state sync of an empty
+// database. Nodes written since by other means, or since it was last persisted
+// if the node crashed, are missing from it; they only get retrieved again.
+type SyncBloom struct {
+	database ethdb.Database
+	bits     []byte
+	added    int // Nodes added since the bloom was last persisted
+	lock     sync.RWMutex
+}
+
+// NewSyncBloom loads the sync bloom persisted in the database, or creates an
+// empty one of the given size in bytes if none was persisted with that size.
+func NewSyncBloom(size int, database ethdb.Database) *SyncBloom {
+	bloom := &SyncBloom{database: database}
+	if blob, _ := database.Get(syncBloomKey); len(blob) == size {
+		bloom.bits = blob
+	} else {
+		bloom.bits = make([]byte, size)
+	}
+	return bloom
+}
+
+// indexes returns the bits of a node hash in the bloom. Node hashes being
+// uniformly distributed, each of their 8 byte words indexes a bit.
+func (b *SyncBloom) indexes(hash []byte) [common.HashLength / 8]uint64 {
+	var (
+		idx  [common.HashLength / 8]uint64
+		key  = common.LeftPadBytes(hash, common.HashLength)
+		size = uint64(len(b.bits)) * 8
+	)
+	for i := range idx {
+		idx[i] = binary.BigEndian.Uint64(key[i*8:]) % size
+	}
+	return idx
+}
+
+// Add marks a node as stored, persisting the bloom once enough were added.
+func (b *SyncBloom) Add(hash []byte) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	for _, bit := range b.indexes(hash) {
+		b.bits[bit/8] |= 1 << (bit % 8)
+	}
+	if b.added++; b.added >= syncBloomFlushInterval {
+		return b.flush()
+	}
+	return nil
+}
+
+// Contains returns whether a node may be stored. False positives are possible,
+// false negatives only for nodes not stored by a trie sync.
+func (b *SyncBloom) Contains(hash []byte) bool {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	for _, bit := range b.indexes(hash) {
+		if b.bits[bit/8]&(1<<(bit%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Flush persists the bloom if any node was added since it last was.
+func (b *SyncBloom) Flush() error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.added == 0 {
+		return nil
+	}
+	return b.flush()
+}
+
+func (b *SyncBloom) flush() error {
+	if err := b.database.Put(syncBloomKey, b.bits); err != nil {
+		return err
+	}
+	b.added = 0
+	return nil
+}
@@ -93,7 +93,7 @@ func TestEmptyTrieSync(t *testing.T) {
 
 	for i, trie := range []*Trie{emptyA, emptyB} {
 		db, _ := ethdb.NewMemDatabase()
-		if req := NewTrieSync(common.BytesToHash(trie.Root()), db, nil).Missing(1); len(req) != 0 {
+		if req := NewTrieSync(common.BytesToHash(trie.Root()), db, nil, nil).Missing(1); len(req) != 0 {
 			t.Errorf("test %d: content requested for empty trie: %v", i, req)
 		}
 	}
@@ -110,7 +110,7 @@ func testIterativeTrieSync(t *testing.T, batch int) {
 
 	// Create a destination trie and sync with the scheduler
 	dstDb, _ := ethdb.NewMemDatabase()
-	sched := NewTrieSync(common.BytesToHash(srcTrie.Root()), dstDb, nil)
+	sched := NewTrieSync(common.BytesToHash(srcTrie.Root()), dstDb, nil, nil)
 
 	queue := append([]common.Hash{}, sched.Missing(batch)...)
 	for len(queue) > 0 {
@@ -139,7 +139,7 @@ func TestIterativeDelayedTrieSync(t *testing.T) {
 
 	// Create a destination trie and sync with the scheduler
 	dstDb, _ := ethdb.NewMemDatabase()
-	sched := NewTrieSync(common.BytesToHash(srcTrie.Root()), dstDb, nil)
+	sched := NewTrieSync(common.BytesToHash(srcTrie.Root()), dstDb, nil, nil)
 
 	queue := append([]common.Hash{}, sched.Missing(10000)...)
 	for len(queue) > 0 {
@@ -173,7 +173,7 @@ func testIterativeRandomTrieSync(t *testing.T, batch int) {
 
 	// Create a destination trie and sync with the scheduler
 	dstDb, _ := ethdb.NewMemDatabase()
-	sched := NewTrieSync(common.BytesToHash(srcTrie.Root()), dstDb, nil)
+	sched := NewTrieSync(common.BytesToHash(srcTrie.Root()), dstDb, nil, nil)
 
 	queue := make(map[common.Hash]struct{})
 	for _, hash := range sched.Missing(batch) {
@@ -210,7 +210,7 @@ func TestIterativeRandomDelayedTrieSync(t *testing.T) {
 
 	// Create a destination trie and sync with the scheduler
 	dstDb, _ := ethdb.NewMemDatabase()
-	sched := NewTrieSync(common.BytesToHash(srcTrie.Root()), dstDb, nil)
+	sched := NewTrieSync(common.BytesToHash(srcTrie.Root()), dstDb, nil, nil)
 
 	queue := make(map[common.Hash]struct{})
 	for _, hash := range sched.Missing(10000) {
@@ -253,7 +253,7 @@ func TestDuplicateAvoidanceTrieSync(t *testing.T) {
 
 	// Create a destination trie and sync with the scheduler
 	dstDb, _ := ethdb.NewMemDatabase()
-	sched := NewTrieSync(common.BytesToHash(srcTrie.Root()), dstDb, nil)
+	sched := NewTrieSync(common.BytesToHash(srcTrie.Root()), dstDb, nil, nil)
 
 	queue := append([]common.Hash{}, sched.Missing(0)...)
 	requested := make(map[common.Hash]struct{})
@@ -289,7 +289,7 @@ func TestIncompleteTrieSync(t *testing.T) {
 
 	// Create a destination trie and sync with the scheduler
 	dstDb, _ := ethdb.NewMemDatabase()
-	sched := NewTrieSync(common.BytesToHash(srcTrie.Root()), dstDb, nil)
+	sched := NewTrieSync(common.BytesToHash(srcTrie.Root()), dstDb, nil, nil)
 
 	added := []common.Hash{}
 	queue := append([]common.Hash{}, sched.Missing(1)...)
@@ -331,3 +331,83 @@ func TestIncompleteTrieSync(t *testing.T) {
 		dstDb.Put(key, value)
 	}
 }
+
+// syncTrie retrieves the nodes scheduled by a sync from the source database
+// until none are missing.
+func syncTrie(t *testing.T, sched *TrieSync, srcDb ethdb.Database) {
+	for queue := sched.Missing(100); len(queue) > 0; queue = sched.Missing(100) {
+		results := make([]SyncResult, len(queue))
+		for i, hash := range queue {
+			data, err := srcDb.Get(hash.Bytes())
+			if err != nil {
+				t.Fatalf("failed to retrieve node data for %x: %v", hash, err)
+			}
+			results[i] = SyncResult{hash, data}
+		}
+		if index, err := sched.Process(results); err != nil {
+			t.Fatalf("failed to process result #%d: %v", index, err)
+		}
+	}
+}
+
+// Tests that the nodes stored by a sync are added to its bloom, which is
+// persisted and loaded back.
+func TestTrieSyncBloom(t *testing.T) {
+	srcDb, srcTrie, srcData := makeTestTrie()
+
+	dstDb, _ := ethdb.NewMemDatabase()
+	bloom := NewSyncBloom(1024, dstDb)
+	syncTrie(t, NewTrieSync(common.BytesToHash(srcTrie.Root()), dstDb, nil, bloom), srcDb)
+	checkTrieContents(t, dstDb, srcTrie.Root(), srcData)
+
+	for _, key := range dstDb.Keys() {
+		if len(key) == common.HashLength && !bloom.Contains(key) {
+			t.Errorf("stored node %x missing from the bloom", key)
+		}
+	}
+	if err := bloom.Flush(); err != nil {
+		t.Fatalf("failed to persist the bloom: %v", err)
+	}
+	if loaded := NewSyncBloom(1024, dstDb); !bytes.Equal(loaded.bits, bloom.bits) {
+		t.Errorf("loaded bloom mismatch")
+	}
+	if resized := NewSyncBloom(2048, dstDb); resized.Contains(srcTrie.Root()) {
+		t.Errorf("bloom of another size loaded")
+	}
+}
+
+// Tests that healing a trie schedules the nodes missing from it, and finds
+// nothing to heal once they're retrieved.
+func TestTrieSyncHeal(t *testing.T) {
+	srcDb, srcTrie, srcData := makeTestTrie()
+	root := common.BytesToHash(srcTrie.Root())
+
+	dstDb, _ := ethdb.NewMemDatabase()
+	syncTrie(t, NewTrieSync(root, dstDb, nil, nil), srcDb)
+
+	// Drop some inner nodes, leaving the root in place
+	var dropped int
+	for _, key := range dstDb.Keys() {
+		if len(key) == common.HashLength && !bytes.Equal(key, root[:]) && dropped < 5 {
+			dstDb.Delete(key)
+			dropped++
+		}
+	}
+	sched := NewTrieSync(root, dstDb, nil, nil)
+	if missing := sched.Missing(0); len(missing) != 0 {
+		t.Fatalf("existing root synced again: %d nodes requested", len(missing))
+	}
+	healed, err := sched.Heal(root, 0, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to heal trie: %v", err)
+	}
+	if healed == 0 || healed > dropped {
+		t.Errorf("healed subtries: have %d, want 1 to %d", healed, dropped)
+	}
+	syncTrie(t, sched, srcDb)
+	checkTrieContents(t, dstDb, srcTrie.Root(), srcData)
+
+	if healed, err := sched.Heal(root, 0, nil, nil); healed != 0 || err != nil {
+		t.Errorf("complete trie healed: %d subtries (%v)", healed, err)
+	}
+}
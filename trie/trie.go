@@ -90,10 +90,12 @@ func New(root common.Hash, db Database) (*Trie, error) {
 		if db == nil {
 			panic("trie.New: cannot use existing root without a database")
 		}
-		if v, _ := trie.db.Get(root[:]); len(v) == 0 {
-			return nil, &MissingNodeError{
-				RootHash: root,
-				NodeHash: root,
+		if _, cached := nodes.get(root[:]); !cached {
+			if v, _ := trie.db.Get(root[:]); len(v) == 0 {
+				return nil, &MissingNodeError{
+					RootHash: root,
+					NodeHash: root,
+				}
 			}
 		}
 		trie.root = hashNode(root.Bytes())
@@ -416,6 +418,9 @@ func (t *Trie) resolve(n node, prefix, suffix []byte) (node, error) {
 }
 
 func (t *Trie) resolveHash(n hashNode, prefix, suffix []byte) (node, error) {
+	if enc, ok := nodes.get(n); ok {
+		return mustDecodeNode(n, enc), nil
+	}
 	enc, err := t.db.Get(n)
 	if err != nil || enc == nil {
 		return nil, &MissingNodeError{
@@ -426,6 +431,7 @@ func (t *Trie) resolveHash(n hashNode, prefix, suffix []byte) (node, error) {
 			SuffixLen: len(suffix),
 		}
 	}
+	nodes.add(n, enc)
 	dec := mustDecodeNode(n, enc)
 	return dec, nil
 }